@@ -62,6 +62,9 @@ It has these top-level messages:
 	ROAResult
 	Vrf
 	Global
+	RibJournalArguments
+	RibJournalEntry
+	RibSnapshot
 */
 package gobgpapi
 
@@ -1010,6 +1013,10 @@ type TimersState struct {
 	NegotiatedHoldTime           uint64 `protobuf:"varint,5,opt,name=negotiated_hold_time" json:"negotiated_hold_time,omitempty"`
 	Uptime                       uint64 `protobuf:"varint,6,opt,name=uptime" json:"uptime,omitempty"`
 	Downtime                     uint64 `protobuf:"varint,7,opt,name=downtime" json:"downtime,omitempty"`
+	HoldTimer                    uint64 `protobuf:"varint,8,opt,name=hold_timer" json:"hold_timer,omitempty"`
+	KeepaliveTimer               uint64 `protobuf:"varint,9,opt,name=keepalive_timer" json:"keepalive_timer,omitempty"`
+	ConnectRetryTimer            uint64 `protobuf:"varint,10,opt,name=connect_retry_timer" json:"connect_retry_timer,omitempty"`
+	IdleHoldTimer                uint64 `protobuf:"varint,11,opt,name=idle_hold_timer" json:"idle_hold_timer,omitempty"`
 }
 
 func (m *TimersState) Reset()                    { *m = TimersState{} }
@@ -1417,6 +1424,53 @@ func (m *Global) String() string            { return proto.CompactTextString(m)
 func (*Global) ProtoMessage()               {}
 func (*Global) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{52} }
 
+type RibJournalArguments struct {
+	Since uint64 `protobuf:"varint,1,opt,name=since" json:"since,omitempty"`
+}
+
+func (m *RibJournalArguments) Reset()                    { *m = RibJournalArguments{} }
+func (m *RibJournalArguments) String() string            { return proto.CompactTextString(m) }
+func (*RibJournalArguments) ProtoMessage()               {}
+func (*RibJournalArguments) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{53} }
+
+type RibJournalEntry struct {
+	Sequence  uint64 `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
+	Type      string `protobuf:"bytes,2,opt,name=type" json:"type,omitempty"`
+	Prefix    string `protobuf:"bytes,3,opt,name=prefix" json:"prefix,omitempty"`
+	Family    uint32 `protobuf:"varint,4,opt,name=family" json:"family,omitempty"`
+	Path      *Path  `protobuf:"bytes,5,opt,name=path" json:"path,omitempty"`
+	Timestamp int64  `protobuf:"varint,6,opt,name=timestamp" json:"timestamp,omitempty"`
+}
+
+func (m *RibJournalEntry) Reset()                    { *m = RibJournalEntry{} }
+func (m *RibJournalEntry) String() string            { return proto.CompactTextString(m) }
+func (*RibJournalEntry) ProtoMessage()               {}
+func (*RibJournalEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{54} }
+
+func (m *RibJournalEntry) GetPath() *Path {
+	if m != nil {
+		return m.Path
+	}
+	return nil
+}
+
+type RibSnapshot struct {
+	Sequence uint64  `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
+	Paths    []*Path `protobuf:"bytes,2,rep,name=paths" json:"paths,omitempty"`
+}
+
+func (m *RibSnapshot) Reset()                    { *m = RibSnapshot{} }
+func (m *RibSnapshot) String() string            { return proto.CompactTextString(m) }
+func (*RibSnapshot) ProtoMessage()               {}
+func (*RibSnapshot) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{55} }
+
+func (m *RibSnapshot) GetPaths() []*Path {
+	if m != nil {
+		return m.Paths
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Error)(nil), "gobgpapi.Error")
 	proto.RegisterType((*Arguments)(nil), "gobgpapi.Arguments")
@@ -1471,6 +1525,9 @@ func init() {
 	proto.RegisterType((*ROAResult)(nil), "gobgpapi.ROAResult")
 	proto.RegisterType((*Vrf)(nil), "gobgpapi.Vrf")
 	proto.RegisterType((*Global)(nil), "gobgpapi.Global")
+	proto.RegisterType((*RibJournalArguments)(nil), "gobgpapi.RibJournalArguments")
+	proto.RegisterType((*RibJournalEntry)(nil), "gobgpapi.RibJournalEntry")
+	proto.RegisterType((*RibSnapshot)(nil), "gobgpapi.RibSnapshot")
 	proto.RegisterEnum("gobgpapi.Resource", Resource_name, Resource_value)
 	proto.RegisterEnum("gobgpapi.Operation", Operation_name, Operation_value)
 	proto.RegisterEnum("gobgpapi.DefinedType", DefinedType_name, DefinedType_value)
@@ -1531,6 +1588,9 @@ type GobgpApiClient interface {
 	ModPolicy(ctx context.Context, in *ModPolicyArguments, opts ...grpc.CallOption) (*Error, error)
 	GetPolicyAssignment(ctx context.Context, in *PolicyAssignment, opts ...grpc.CallOption) (*PolicyAssignment, error)
 	ModPolicyAssignment(ctx context.Context, in *ModPolicyAssignmentArguments, opts ...grpc.CallOption) (*Error, error)
+	MonitorRibJournal(ctx context.Context, in *Arguments, opts ...grpc.CallOption) (GobgpApi_MonitorRibJournalClient, error)
+	GetRibJournal(ctx context.Context, in *RibJournalArguments, opts ...grpc.CallOption) (GobgpApi_GetRibJournalClient, error)
+	GetRibSnapshot(ctx context.Context, in *Arguments, opts ...grpc.CallOption) (*RibSnapshot, error)
 }
 
 type gobgpApiClient struct {
@@ -2184,6 +2244,79 @@ func (c *gobgpApiClient) ModPolicyAssignment(ctx context.Context, in *ModPolicyA
 	return out, nil
 }
 
+func (c *gobgpApiClient) MonitorRibJournal(ctx context.Context, in *Arguments, opts ...grpc.CallOption) (GobgpApi_MonitorRibJournalClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_GobgpApi_serviceDesc.Streams[13], c.cc, "/gobgpapi.GobgpApi/MonitorRibJournal", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gobgpApiMonitorRibJournalClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type GobgpApi_MonitorRibJournalClient interface {
+	Recv() (*RibJournalEntry, error)
+	grpc.ClientStream
+}
+
+type gobgpApiMonitorRibJournalClient struct {
+	grpc.ClientStream
+}
+
+func (x *gobgpApiMonitorRibJournalClient) Recv() (*RibJournalEntry, error) {
+	m := new(RibJournalEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *gobgpApiClient) GetRibJournal(ctx context.Context, in *RibJournalArguments, opts ...grpc.CallOption) (GobgpApi_GetRibJournalClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_GobgpApi_serviceDesc.Streams[14], c.cc, "/gobgpapi.GobgpApi/GetRibJournal", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gobgpApiGetRibJournalClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type GobgpApi_GetRibJournalClient interface {
+	Recv() (*RibJournalEntry, error)
+	grpc.ClientStream
+}
+
+type gobgpApiGetRibJournalClient struct {
+	grpc.ClientStream
+}
+
+func (x *gobgpApiGetRibJournalClient) Recv() (*RibJournalEntry, error) {
+	m := new(RibJournalEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *gobgpApiClient) GetRibSnapshot(ctx context.Context, in *Arguments, opts ...grpc.CallOption) (*RibSnapshot, error) {
+	out := new(RibSnapshot)
+	err := grpc.Invoke(ctx, "/gobgpapi.GobgpApi/GetRibSnapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for GobgpApi service
 
 type GobgpApiServer interface {
@@ -2225,6 +2358,9 @@ type GobgpApiServer interface {
 	ModPolicy(context.Context, *ModPolicyArguments) (*Error, error)
 	GetPolicyAssignment(context.Context, *PolicyAssignment) (*PolicyAssignment, error)
 	ModPolicyAssignment(context.Context, *ModPolicyAssignmentArguments) (*Error, error)
+	MonitorRibJournal(*Arguments, GobgpApi_MonitorRibJournalServer) error
+	GetRibJournal(*RibJournalArguments, GobgpApi_GetRibJournalServer) error
+	GetRibSnapshot(context.Context, *Arguments) (*RibSnapshot, error)
 }
 
 func RegisterGobgpApiServer(s *grpc.Server, srv GobgpApiServer) {
@@ -2809,6 +2945,60 @@ func _GobgpApi_ModPolicyAssignment_Handler(srv interface{}, ctx context.Context,
 	return out, nil
 }
 
+func _GobgpApi_MonitorRibJournal_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Arguments)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GobgpApiServer).MonitorRibJournal(m, &gobgpApiMonitorRibJournalServer{stream})
+}
+
+type GobgpApi_MonitorRibJournalServer interface {
+	Send(*RibJournalEntry) error
+	grpc.ServerStream
+}
+
+type gobgpApiMonitorRibJournalServer struct {
+	grpc.ServerStream
+}
+
+func (x *gobgpApiMonitorRibJournalServer) Send(m *RibJournalEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _GobgpApi_GetRibJournal_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RibJournalArguments)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GobgpApiServer).GetRibJournal(m, &gobgpApiGetRibJournalServer{stream})
+}
+
+type GobgpApi_GetRibJournalServer interface {
+	Send(*RibJournalEntry) error
+	grpc.ServerStream
+}
+
+type gobgpApiGetRibJournalServer struct {
+	grpc.ServerStream
+}
+
+func (x *gobgpApiGetRibJournalServer) Send(m *RibJournalEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _GobgpApi_GetRibSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Arguments)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(GobgpApiServer).GetRibSnapshot(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _GobgpApi_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "gobgpapi.GobgpApi",
 	HandlerType: (*GobgpApiServer)(nil),
@@ -2913,6 +3103,10 @@ var _GobgpApi_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ModPolicyAssignment",
 			Handler:    _GobgpApi_ModPolicyAssignment_Handler,
 		},
+		{
+			MethodName: "GetRibSnapshot",
+			Handler:    _GobgpApi_GetRibSnapshot_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -2980,228 +3174,249 @@ var _GobgpApi_serviceDesc = grpc.ServiceDesc{
 			Handler:       _GobgpApi_GetPolicies_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "MonitorRibJournal",
+			Handler:       _GobgpApi_MonitorRibJournal_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetRibJournal",
+			Handler:       _GobgpApi_GetRibJournal_Handler,
+			ServerStreams: true,
+		},
 	},
 }
 
 var fileDescriptor0 = []byte{
-	// 3498 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xbc, 0x1a, 0x5d, 0x6f, 0xdb, 0xd6,
-	0x35, 0xfa, 0xb4, 0x74, 0x24, 0xd9, 0x32, 0xed, 0xa4, 0x8e, 0xfa, 0x95, 0x72, 0x5d, 0x92, 0xba,
-	0x4d, 0x9a, 0xa6, 0x6d, 0x56, 0xb4, 0x1d, 0x36, 0x59, 0x62, 0x62, 0x35, 0xb6, 0xa5, 0xca, 0xb2,
-	0xdb, 0x02, 0x03, 0x08, 0x5a, 0xa2, 0x64, 0x2e, 0x92, 0xc8, 0x92, 0x54, 0x12, 0x03, 0x7b, 0xdb,
-	0xd3, 0xfe, 0xc2, 0x30, 0x0c, 0xd8, 0x1e, 0xf6, 0x13, 0xf6, 0xb2, 0x97, 0x01, 0xfb, 0x01, 0xfb,
-	0x07, 0x7b, 0xd9, 0x43, 0x81, 0xfd, 0x8a, 0x9d, 0x73, 0xee, 0xa5, 0x48, 0x4a, 0x74, 0x62, 0xa7,
-	0xc3, 0x5e, 0x6c, 0xf1, 0xf2, 0x7c, 0xdd, 0xf3, 0x7d, 0xee, 0x25, 0x94, 0x46, 0xf6, 0xc9, 0xc8,
-	0xb9, 0xeb, 0xb8, 0xb6, 0x6f, 0x2b, 0x05, 0x7e, 0x30, 0x1c, 0x4b, 0x35, 0x20, 0xa7, 0xb9, 0xae,
-	0xed, 0x2a, 0xb7, 0x20, 0xdb, 0xb7, 0x07, 0xe6, 0x56, 0xea, 0x46, 0xea, 0xf6, 0xea, 0xfd, 0xeb,
-	0x77, 0x03, 0x88, 0xbb, 0xfc, 0x5a, 0xfc, 0x6d, 0x20, 0x80, 0x52, 0x82, 0xcc, 0xc4, 0x1b, 0x6d,
-	0xa5, 0x11, 0xae, 0xa8, 0xaa, 0x50, 0x8c, 0xbe, 0x59, 0x39, 0x3c, 0x6a, 0x34, 0xb4, 0xc3, 0xc3,
-	0xea, 0x15, 0xa5, 0x00, 0xd9, 0x87, 0xf5, 0xd6, 0x5e, 0x35, 0xa5, 0xb6, 0xa1, 0x58, 0x77, 0x47,
-	0xb3, 0x89, 0x39, 0xf5, 0x3d, 0xe5, 0x5d, 0x28, 0xb8, 0xa6, 0x67, 0xcf, 0xdc, 0x7e, 0xc0, 0x4a,
-	0x09, 0x59, 0x75, 0xe5, 0x1b, 0x65, 0x15, 0xf2, 0x43, 0x63, 0x62, 0x8d, 0xcf, 0x98, 0x4d, 0x45,
-	0x29, 0x43, 0x76, 0x6a, 0x4c, 0xcc, 0xad, 0x0c, 0x33, 0xfd, 0x7d, 0x0a, 0xaa, 0xfb, 0xf6, 0xa0,
-	0x63, 0xf8, 0xa7, 0x21, 0xe1, 0x9b, 0x50, 0xb4, 0x1d, 0xd3, 0x35, 0x7c, 0xcb, 0x9e, 0x4a, 0xca,
-	0x1b, 0x21, 0xe5, 0x76, 0xf0, 0x2a, 0x26, 0x40, 0xfa, 0x5c, 0x01, 0x62, 0x0c, 0x95, 0x37, 0x20,
-	0xeb, 0x20, 0xb3, 0xad, 0x2c, 0x3e, 0x95, 0xee, 0xaf, 0x86, 0xf0, 0x24, 0x02, 0xc1, 0xce, 0x66,
-	0xd6, 0x60, 0x2b, 0x87, 0x6f, 0xcb, 0xea, 0xdb, 0xb0, 0x26, 0x65, 0x43, 0x62, 0x8e, 0x3d, 0xf5,
-	0xcc, 0x39, 0x40, 0x8a, 0x01, 0x86, 0xb0, 0x2e, 0x01, 0xbc, 0xcb, 0xaa, 0x25, 0x90, 0x8a, 0x75,
-	0xaf, 0xbc, 0x09, 0x39, 0x92, 0xca, 0x43, 0x21, 0x33, 0xcb, 0x62, 0xa9, 0xbf, 0x82, 0x4d, 0xe4,
-	0x73, 0x60, 0x5a, 0xa3, 0xd3, 0x13, 0xdb, 0xbd, 0xbc, 0xa2, 0x68, 0xd3, 0xa6, 0xe9, 0x32, 0xb3,
-	0x38, 0x75, 0x5c, 0x55, 0x1d, 0x28, 0xef, 0xbb, 0xfe, 0x8f, 0xb5, 0x6b, 0x15, 0x0a, 0xd6, 0xd4,
-	0x37, 0xdd, 0xa7, 0xc6, 0x98, 0x55, 0x9d, 0x55, 0xb6, 0xa0, 0x3a, 0x95, 0x22, 0xeb, 0xc6, 0x60,
-	0x80, 0x34, 0x3d, 0x56, 0x7b, 0x51, 0x7d, 0xcc, 0x8a, 0x8d, 0x31, 0xbd, 0xe8, 0x56, 0x90, 0xcd,
-	0xd0, 0x1a, 0x9b, 0xa1, 0xee, 0xd4, 0x7f, 0xa4, 0x98, 0xda, 0xce, 0xc4, 0xb9, 0x3c, 0xb5, 0x35,
-	0x58, 0x09, 0x24, 0x13, 0x86, 0x40, 0xb3, 0x38, 0xb6, 0xeb, 0xf3, 0x0e, 0x2a, 0xca, 0x67, 0x90,
-	0xf5, 0xcf, 0x1c, 0x93, 0xa5, 0x5e, 0xbd, 0xbf, 0x1d, 0x52, 0x58, 0xe0, 0x87, 0xcf, 0x53, 0xcb,
-	0xb7, 0x5d, 0x6b, 0x3a, 0xea, 0xd8, 0x63, 0xab, 0x7f, 0xa6, 0x7e, 0x48, 0x6e, 0x1d, 0x5f, 0x53,
-	0x56, 0x20, 0xd3, 0xe9, 0x6a, 0x22, 0x9e, 0x3a, 0xed, 0xc3, 0x5e, 0x35, 0x45, 0xbf, 0x76, 0xda,
-	0xbd, 0xdd, 0x6a, 0x5a, 0xfd, 0x8e, 0xe3, 0xa0, 0xeb, 0x3c, 0xb1, 0xfe, 0xd7, 0xbb, 0x50, 0x8f,
-	0x58, 0x3f, 0xc7, 0xee, 0xf0, 0xf2, 0x94, 0x6b, 0x90, 0x79, 0xea, 0x0e, 0xa5, 0xdf, 0x54, 0x42,
-	0x08, 0x24, 0xa6, 0xf6, 0xe1, 0x1a, 0x92, 0x6d, 0x9a, 0x43, 0x6b, 0x6a, 0x0e, 0x0e, 0xcd, 0x57,
-	0xb0, 0xe5, 0x3b, 0x90, 0xf1, 0x4c, 0x5f, 0x52, 0xdf, 0x0c, 0x21, 0x42, 0x9a, 0xea, 0x08, 0xae,
-	0x22, 0x93, 0x43, 0xdf, 0xf0, 0x4d, 0xa2, 0x7d, 0x79, 0x1e, 0x08, 0xe7, 0x05, 0xd8, 0x92, 0x53,
-	0x04, 0x6e, 0x4e, 0x58, 0xfd, 0x63, 0x0a, 0x14, 0x8a, 0x65, 0x36, 0xd5, 0xe5, 0xd9, 0xdc, 0x80,
-	0xbc, 0xc3, 0xa8, 0x92, 0x47, 0x35, 0x12, 0x63, 0xc2, 0xfa, 0xef, 0xc0, 0x75, 0xd7, 0x1c, 0x9a,
-	0xae, 0x6e, 0x3e, 0xb7, 0x3c, 0x1f, 0xbd, 0x42, 0x9f, 0xcb, 0xe5, 0xb1, 0xa1, 0x0a, 0xca, 0xeb,
-	0xb0, 0xe1, 0xa0, 0x15, 0x31, 0x86, 0xcc, 0xe8, 0x4b, 0xf2, 0xbe, 0x82, 0xfa, 0x14, 0xde, 0x08,
-	0xe5, 0xf3, 0x3c, 0x6b, 0x34, 0x7d, 0x35, 0x85, 0xdc, 0x05, 0x30, 0xe6, 0xe8, 0x52, 0xda, 0xda,
-	0xa2, 0xb4, 0x21, 0x03, 0x75, 0x00, 0x5b, 0xc8, 0xf7, 0xd1, 0xd8, 0x3e, 0x31, 0xc6, 0x0d, 0x7b,
-	0x3a, 0xb4, 0x46, 0xaf, 0xa4, 0x9d, 0x11, 0x13, 0x58, 0xd6, 0x8e, 0x20, 0xac, 0xfe, 0x33, 0x85,
-	0x31, 0x21, 0x33, 0xf0, 0x74, 0xec, 0x5a, 0x22, 0xc1, 0x52, 0x92, 0xc1, 0xbc, 0xe8, 0xbb, 0xe4,
-	0xd8, 0x19, 0x7c, 0xc6, 0x82, 0x65, 0x8c, 0x44, 0x2a, 0xcf, 0x10, 0xe8, 0x89, 0xe9, 0xf9, 0x42,
-	0x3f, 0xca, 0x06, 0x94, 0x2c, 0x4f, 0x7f, 0x66, 0xf9, 0xa7, 0x03, 0xd7, 0x78, 0xc6, 0x19, 0xbc,
-	0xa0, 0x28, 0x00, 0x98, 0x8f, 0xac, 0x81, 0x90, 0x30, 0x8f, 0x6b, 0x39, 0x4c, 0x86, 0x9b, 0x53,
-	0x5b, 0xb7, 0x26, 0x0e, 0x6e, 0xd4, 0xf2, 0x43, 0x8c, 0x15, 0xc6, 0x08, 0xd3, 0x5a, 0x81, 0x53,
-	0x00, 0x52, 0x10, 0x09, 0x4f, 0x37, 0xbc, 0xe9, 0x56, 0x91, 0xd7, 0xd6, 0xd1, 0xa7, 0xc4, 0x1a,
-	0x56, 0x02, 0xe0, 0x88, 0x13, 0x69, 0x09, 0xd3, 0x9f, 0x39, 0xd8, 0x2a, 0xb1, 0xbd, 0x8e, 0xa0,
-	0xd4, 0x34, 0xc9, 0xd0, 0x42, 0x05, 0xb4, 0x13, 0xb4, 0xbf, 0xf5, 0x9c, 0x77, 0x16, 0xc9, 0xf8,
-	0xe9, 0xa4, 0x8c, 0xaf, 0xbc, 0x06, 0x6b, 0x63, 0x7b, 0x3a, 0x42, 0x77, 0x11, 0x58, 0xa6, 0xf4,
-	0x11, 0xf5, 0x77, 0x29, 0xc8, 0xf5, 0x8c, 0x93, 0xb1, 0x89, 0x4a, 0x15, 0xc9, 0xe9, 0xa2, 0x35,
-	0x26, 0xdc, 0x99, 0x48, 0x6e, 0xef, 0x43, 0x79, 0x10, 0x0a, 0x48, 0x6e, 0x46, 0x82, 0x5c, 0x8d,
-	0x86, 0x61, 0x28, 0x3e, 0x6a, 0xd7, 0xb1, 0x3d, 0x5f, 0x97, 0x4e, 0xce, 0xda, 0x55, 0xff, 0x9d,
-	0x46, 0xa3, 0x61, 0x05, 0xe1, 0xdd, 0x13, 0x69, 0xcb, 0x14, 0xfb, 0x61, 0xe2, 0x86, 0xe3, 0x8c,
-	0xcf, 0x02, 0x84, 0x0c, 0xdb, 0x3d, 0x42, 0xbc, 0x4e, 0x6f, 0x65, 0x68, 0xdc, 0xa0, 0x7e, 0x65,
-	0x3a, 0x64, 0xaa, 0xa5, 0xe8, 0x4e, 0x88, 0x38, 0xf9, 0x9c, 0x72, 0x07, 0x2a, 0x26, 0xae, 0xe9,
-	0x93, 0xd9, 0xd8, 0xb7, 0x4e, 0x6d, 0x87, 0x4d, 0x59, 0xba, 0x7f, 0x2d, 0xd2, 0xda, 0xe0, 0xff,
-	0x7d, 0xf9, 0x56, 0xf9, 0x08, 0xd6, 0x5c, 0x7b, 0xe6, 0x9b, 0x3a, 0xea, 0x6e, 0x6c, 0xf6, 0x31,
-	0x0b, 0xb3, 0x99, 0x4a, 0xf7, 0xb7, 0x22, 0x5a, 0x22, 0x80, 0x6e, 0xf0, 0x1e, 0xc3, 0x33, 0x6b,
-	0x4d, 0x87, 0x36, 0x1b, 0x2f, 0x96, 0x22, 0x48, 0x06, 0x4e, 0x13, 0xe4, 0xc5, 0xbe, 0x35, 0x31,
-	0xd1, 0x19, 0xcb, 0x8b, 0x5e, 0xdc, 0xe3, 0x75, 0x8a, 0x07, 0xdf, 0x35, 0xa6, 0x1e, 0x27, 0xdf,
-	0xca, 0x22, 0xa5, 0x5e, 0xf0, 0x8a, 0xb4, 0x23, 0xe4, 0xe3, 0x58, 0x77, 0xb7, 0xd6, 0x16, 0xb5,
-	0xc3, 0xc2, 0x1d, 0xf2, 0x4b, 0xf5, 0xcf, 0x29, 0x28, 0x45, 0xb5, 0x75, 0x07, 0x8a, 0xd6, 0x34,
-	0xd0, 0x6b, 0xea, 0x65, 0xf1, 0x8b, 0xba, 0xa8, 0x98, 0xcf, 0x89, 0xab, 0x1e, 0x4b, 0x50, 0x2f,
-	0x41, 0xc1, 0xf0, 0x88, 0xa0, 0x64, 0x5e, 0x9a, 0x25, 0xfe, 0x90, 0x86, 0xc2, 0xdc, 0x5a, 0x57,
-	0xa1, 0x62, 0xcc, 0xfc, 0x53, 0xdd, 0xc1, 0x44, 0xf3, 0xcc, 0x76, 0x07, 0xd2, 0xe5, 0xd1, 0x87,
-	0xd0, 0xe1, 0xfa, 0xae, 0xe5, 0x70, 0x34, 0xa6, 0x83, 0xc0, 0x19, 0xdb, 0x7d, 0x63, 0x8c, 0xe1,
-	0x25, 0xfd, 0xf2, 0xdc, 0xb6, 0x81, 0xea, 0x1c, 0xb5, 0x31, 0x04, 0x9a, 0x0b, 0x82, 0x93, 0x17,
-	0x46, 0xa8, 0x4d, 0xe1, 0x13, 0x45, 0x0a, 0x4e, 0x5e, 0xe3, 0xd8, 0x58, 0x61, 0xb0, 0xeb, 0xb0,
-	0xee, 0x9a, 0x13, 0x1b, 0xb3, 0xaa, 0xe3, 0x5a, 0x4f, 0xd1, 0x94, 0x44, 0x41, 0x84, 0x77, 0x0d,
-	0x14, 0x61, 0x89, 0xe1, 0xd8, 0x70, 0xf4, 0x81, 0x31, 0x71, 0x30, 0x33, 0x73, 0x98, 0x17, 0x94,
-	0x6b, 0xb0, 0xea, 0x99, 0xd3, 0x81, 0xde, 0xb7, 0x27, 0x93, 0x19, 0xd6, 0xf2, 0x33, 0x76, 0x22,
-	0xe6, 0x4a, 0xe4, 0x10, 0xa9, 0x6f, 0x38, 0xe8, 0x30, 0x94, 0x98, 0x90, 0xab, 0xd8, 0x06, 0x2d,
-	0x95, 0x79, 0x09, 0x20, 0x8d, 0xe9, 0xa1, 0xc2, 0x3d, 0xca, 0xa7, 0x50, 0x8e, 0x39, 0x28, 0xee,
-	0x04, 0x5b, 0x18, 0x8c, 0x62, 0xa1, 0x9b, 0x82, 0xb2, 0x09, 0xe5, 0xc0, 0xb7, 0x75, 0xdf, 0x17,
-	0x79, 0xb2, 0xa2, 0xf6, 0x60, 0x75, 0xc1, 0x4d, 0xdf, 0x82, 0x6b, 0x0b, 0x9e, 0xad, 0xf7, 0x31,
-	0xec, 0x30, 0x93, 0x0b, 0x3a, 0x2a, 0xd4, 0x96, 0xdf, 0xcf, 0x3c, 0x4c, 0x4c, 0x94, 0xab, 0x04,
-	0xd5, 0x1f, 0x32, 0x50, 0x0c, 0xbd, 0xfa, 0xc7, 0x19, 0x0b, 0x7b, 0xc5, 0x09, 0x1a, 0x08, 0x93,
-	0xb2, 0x27, 0x5b, 0xea, 0x48, 0xf8, 0xee, 0xcb, 0x37, 0x89, 0x26, 0xcd, 0x2d, 0x9a, 0x34, 0x9f,
-	0x60, 0xd2, 0x95, 0x65, 0x93, 0x0a, 0xbb, 0x61, 0x2c, 0x7e, 0x3f, 0x33, 0x67, 0xc8, 0xb5, 0xb8,
-	0x18, 0x8b, 0x5f, 0xf3, 0x7a, 0xb2, 0xd1, 0xe1, 0x05, 0x46, 0x2f, 0x9d, 0x63, 0xf4, 0x32, 0xe3,
-	0xa0, 0x9a, 0x3c, 0x14, 0x1b, 0x75, 0x21, 0x4a, 0x33, 0x1b, 0xb6, 0x42, 0xf6, 0xf0, 0x66, 0x0e,
-	0xc5, 0x8a, 0x39, 0x20, 0xdb, 0x1b, 0x27, 0x98, 0x05, 0x7d, 0xca, 0x83, 0xab, 0xe8, 0x04, 0x2c,
-	0x3a, 0xe5, 0x2d, 0x81, 0xb2, 0x16, 0x68, 0xd6, 0x18, 0x4c, 0xac, 0x80, 0x4e, 0x35, 0xd0, 0xac,
-	0x6b, 0xf6, 0x4d, 0xeb, 0x29, 0x7a, 0xc4, 0x7a, 0xd0, 0x4f, 0x1b, 0xfd, 0xbe, 0xe9, 0x20, 0xe1,
-	0x2d, 0x25, 0x50, 0x8d, 0x31, 0xc0, 0x8c, 0xe0, 0x5b, 0x1e, 0xae, 0x6d, 0xf0, 0x5a, 0x05, 0x72,
-	0xb8, 0x13, 0xfd, 0xfb, 0xad, 0xcd, 0xe0, 0x71, 0x38, 0xb6, 0x1d, 0x6f, 0xeb, 0x2a, 0x5b, 0xba,
-	0x03, 0x85, 0xb9, 0x0d, 0x7e, 0x12, 0xe1, 0x20, 0xb2, 0xc6, 0xfa, 0x92, 0xa5, 0x94, 0xb7, 0x21,
-	0xeb, 0x85, 0x6d, 0xc1, 0x32, 0x80, 0xfa, 0xdb, 0x14, 0xac, 0x04, 0xc0, 0xe8, 0xb3, 0x07, 0xed,
-	0x5e, 0xeb, 0x61, 0xab, 0x51, 0xef, 0xb5, 0xda, 0x07, 0x4c, 0x35, 0x4b, 0x65, 0xe6, 0xa8, 0xd3,
-	0xac, 0xf7, 0x34, 0x26, 0x92, 0xa5, 0x22, 0xd4, 0xee, 0x68, 0x07, 0x72, 0x26, 0x40, 0x7d, 0x3c,
-	0xd6, 0xb4, 0x4e, 0x7d, 0xaf, 0x75, 0xac, 0xb1, 0xc3, 0x64, 0xc9, 0x05, 0xba, 0xda, 0xc3, 0xae,
-	0x76, 0xb8, 0xcb, 0x3e, 0xc1, 0x30, 0xcd, 0xd6, 0x61, 0xa3, 0xde, 0x6d, 0x6a, 0x4d, 0xf6, 0x8a,
-	0x2c, 0xed, 0xab, 0xd7, 0xee, 0xd5, 0xf7, 0xd8, 0x21, 0xb2, 0xea, 0x2d, 0xc8, 0x4b, 0x2b, 0xe3,
-	0x0b, 0x6b, 0xea, 0xcc, 0x84, 0xfb, 0x57, 0x88, 0x39, 0xaa, 0x83, 0x9e, 0x85, 0xab, 0x1f, 0x43,
-	0x7e, 0x9e, 0x9a, 0xf3, 0x7d, 0xee, 0x5e, 0xe4, 0xe6, 0xaf, 0x2d, 0x26, 0x6f, 0xd1, 0xdb, 0xa0,
-	0x43, 0xe7, 0x84, 0x5d, 0xd2, 0x8b, 0x39, 0x59, 0x80, 0x71, 0xd0, 0xa8, 0xbf, 0x81, 0x72, 0x0c,
-	0x0b, 0xbd, 0x03, 0xa9, 0x4f, 0x31, 0xe0, 0x30, 0xf0, 0x7c, 0xf7, 0x4c, 0xea, 0x02, 0x77, 0x72,
-	0x6a, 0x8f, 0x07, 0x3a, 0x95, 0x0d, 0xa9, 0x0e, 0xf4, 0xbd, 0x27, 0xa6, 0xe9, 0x60, 0x53, 0x82,
-	0x9e, 0xb9, 0x30, 0x30, 0xdd, 0x84, 0xb7, 0xd0, 0x2f, 0xac, 0xc9, 0x6c, 0xa2, 0xcf, 0x0d, 0x4d,
-	0xd9, 0x35, 0x84, 0x63, 0x8d, 0xa9, 0x7f, 0xc3, 0x8a, 0x10, 0x91, 0xe6, 0xff, 0xcb, 0x9d, 0xfb,
-	0x27, 0x73, 0x64, 0xfb, 0x96, 0x41, 0x3e, 0x1f, 0x72, 0xc8, 0x05, 0xe6, 0x9f, 0x39, 0xfc, 0x2c,
-	0x2c, 0x87, 0x6e, 0x3c, 0xb0, 0x9f, 0x4d, 0x79, 0x45, 0x18, 0xef, 0x4f, 0x29, 0x28, 0x86, 0xa5,
-	0x10, 0x65, 0x97, 0x29, 0x45, 0xe6, 0x05, 0x91, 0x7e, 0xd0, 0xd7, 0xc5, 0x32, 0x97, 0xd2, 0x74,
-	0x10, 0x82, 0x13, 0x7f, 0xa6, 0x0f, 0x2c, 0xaf, 0x8f, 0x61, 0xed, 0x9e, 0xc9, 0xae, 0x19, 0xdd,
-	0x90, 0x72, 0x17, 0xed, 0x68, 0x42, 0xa7, 0x1e, 0xd9, 0x20, 0x8e, 0x65, 0x92, 0x8e, 0x27, 0x1c,
-	0x8c, 0x3e, 0xb9, 0xce, 0x94, 0x45, 0xd2, 0x41, 0x17, 0xf4, 0xfb, 0xd8, 0x5d, 0x20, 0x14, 0x57,
-	0x0c, 0x75, 0x1b, 0x4a, 0x91, 0x12, 0x4c, 0x8d, 0x79, 0xb4, 0x5e, 0xc7, 0x52, 0xae, 0xba, 0x0f,
-	0xf9, 0x0e, 0xf7, 0x68, 0xa4, 0x70, 0xcb, 0xd1, 0x63, 0x6d, 0x1e, 0xf6, 0x71, 0x13, 0xc3, 0x7b,
-	0xa2, 0xe3, 0xc4, 0x3a, 0xc2, 0x24, 0x8b, 0x0a, 0x96, 0x9b, 0x59, 0x7c, 0x61, 0x3c, 0x97, 0xd3,
-	0xda, 0xf7, 0x00, 0xe1, 0xfc, 0x83, 0x51, 0x1b, 0x6d, 0xf2, 0xae, 0x2e, 0xcd, 0x48, 0x3d, 0x7c,
-	0xb9, 0xd0, 0xe7, 0xe1, 0xd3, 0x18, 0x27, 0x0c, 0x3e, 0x4a, 0x28, 0x62, 0x41, 0x28, 0xcc, 0x3b,
-	0x48, 0xd1, 0xe1, 0x45, 0x47, 0x13, 0x7e, 0xa3, 0x7e, 0x81, 0x69, 0xc2, 0xf0, 0xfb, 0xa7, 0xc4,
-	0xf0, 0x9d, 0x18, 0xc3, 0x48, 0xf7, 0xc2, 0x10, 0xcb, 0xec, 0xd4, 0x5d, 0x28, 0xd7, 0x3d, 0xea,
-	0x59, 0xf7, 0x78, 0x27, 0xca, 0xed, 0x18, 0x81, 0x48, 0xcf, 0x10, 0x85, 0x62, 0x3a, 0xe8, 0x2a,
-	0x62, 0xf7, 0x32, 0x58, 0xff, 0x92, 0x06, 0xc0, 0x78, 0x1a, 0x58, 0xdc, 0x8f, 0xa2, 0x3f, 0x82,
-	0x90, 0x5c, 0xa7, 0x21, 0x31, 0xb5, 0x54, 0x5c, 0x02, 0x89, 0x6f, 0x43, 0x79, 0x5e, 0x5c, 0xc2,
-	0x71, 0x32, 0x09, 0xf2, 0x2e, 0xac, 0x1a, 0x9e, 0x4e, 0x6d, 0xb7, 0x54, 0xbb, 0x6c, 0x6c, 0xae,
-	0x25, 0x0b, 0xa9, 0xdc, 0xc2, 0x4c, 0x2d, 0xe1, 0x89, 0x70, 0xf6, 0x5c, 0xc2, 0xef, 0x51, 0x00,
-	0xca, 0x7a, 0xc1, 0xa0, 0xb9, 0x73, 0x41, 0xef, 0xc0, 0xba, 0xf9, 0xdc, 0xd7, 0xe3, 0xe0, 0xf9,
-	0x73, 0xc1, 0xc9, 0x5d, 0x9d, 0x27, 0x16, 0xc6, 0xb5, 0x87, 0xed, 0x01, 0x7b, 0x67, 0x4e, 0x3d,
-	0x84, 0xb5, 0x46, 0x80, 0x5f, 0xef, 0x73, 0x7f, 0xfe, 0x7e, 0x4c, 0xeb, 0x6f, 0x86, 0x94, 0x16,
-	0x00, 0x59, 0xf1, 0x48, 0x34, 0xe0, 0x1f, 0x74, 0xec, 0x45, 0xb5, 0x0e, 0xc5, 0x7d, 0x73, 0x20,
-	0xc9, 0xfd, 0x34, 0x46, 0xee, 0xb5, 0x68, 0x1d, 0x18, 0x44, 0x08, 0x61, 0xf6, 0xc5, 0xbc, 0x30,
-	0x13, 0xae, 0x90, 0x51, 0x35, 0x58, 0x43, 0xfd, 0xb9, 0xa6, 0x83, 0x05, 0x54, 0x12, 0xa2, 0x81,
-	0xcd, 0x9b, 0x86, 0xd9, 0x99, 0x5e, 0x1a, 0x91, 0x80, 0x9e, 0x79, 0x26, 0xda, 0x62, 0xe8, 0x63,
-	0xe8, 0x7a, 0xbe, 0x1c, 0x71, 0x7e, 0xc0, 0x1a, 0x23, 0xd0, 0xbd, 0xb0, 0x53, 0x36, 0xfa, 0x91,
-	0x21, 0x73, 0xb1, 0x53, 0x96, 0xcc, 0x3e, 0x80, 0x62, 0x58, 0xb6, 0x85, 0x1b, 0x5c, 0x3f, 0x57,
-	0x13, 0xd8, 0x42, 0x64, 0x26, 0x58, 0x0b, 0x33, 0x8b, 0x6d, 0x7a, 0xa8, 0x85, 0x3b, 0x34, 0x2a,
-	0x53, 0x3c, 0xd3, 0x86, 0xa4, 0xf9, 0xaf, 0xc7, 0x7c, 0x25, 0xb6, 0xd7, 0x7b, 0xd4, 0x69, 0x47,
-	0x4c, 0x2b, 0xbd, 0xe0, 0x7c, 0x11, 0xd4, 0x11, 0x14, 0xe7, 0x27, 0x10, 0xf3, 0xb0, 0x12, 0x89,
-	0xe3, 0x36, 0x40, 0x7f, 0x1e, 0x0b, 0xcb, 0x47, 0x24, 0x91, 0x38, 0x51, 0x61, 0x45, 0x28, 0xc7,
-	0x93, 0x7b, 0x89, 0x94, 0x6d, 0xa9, 0x46, 0xf5, 0x17, 0x98, 0xa3, 0xc4, 0xf4, 0x10, 0xe7, 0x72,
-	0x0b, 0xa7, 0xdb, 0xf0, 0xa0, 0x41, 0x8c, 0xa2, 0x89, 0xc7, 0x23, 0x7f, 0x4d, 0x41, 0x75, 0x69,
-	0x4e, 0x50, 0x63, 0x5e, 0xb2, 0xb9, 0x38, 0x1e, 0xb0, 0x8b, 0xbc, 0xca, 0x19, 0x2d, 0xe5, 0x2c,
-	0xa2, 0x60, 0x25, 0xe6, 0x2c, 0xb1, 0x8f, 0x9b, 0xb0, 0x32, 0x30, 0x87, 0x06, 0x05, 0x45, 0xee,
-	0x05, 0x3e, 0xa1, 0xd6, 0x00, 0xf6, 0x5d, 0x3f, 0x68, 0x59, 0x90, 0xcf, 0xc0, 0xf0, 0x0d, 0x79,
-	0x7c, 0x7b, 0x0f, 0x0a, 0xdd, 0xce, 0xe3, 0x16, 0xcf, 0x2c, 0x91, 0x33, 0xb4, 0x54, 0x52, 0xa1,
-	0x10, 0x29, 0xea, 0x5f, 0x69, 0x28, 0x12, 0x8a, 0xa8, 0xbb, 0x61, 0xad, 0x4b, 0xf1, 0x81, 0x44,
-	0xb4, 0xd6, 0x71, 0x44, 0xd0, 0x0c, 0x80, 0x5d, 0x6c, 0x26, 0x38, 0xa0, 0xc0, 0x06, 0x0c, 0x3b,
-	0x6c, 0xdd, 0x72, 0x9e, 0x7e, 0xc2, 0xee, 0x54, 0x89, 0x2f, 0x3e, 0x90, 0x63, 0x0d, 0x0d, 0xdb,
-	0x22, 0xf3, 0x31, 0x64, 0x7e, 0x79, 0xf1, 0x81, 0x9c, 0x6c, 0x50, 0x02, 0x2c, 0x49, 0x16, 0xd6,
-	0xe6, 0x42, 0x10, 0x51, 0x41, 0x93, 0x27, 0x70, 0x8b, 0x2c, 0xc6, 0xc2, 0xf2, 0x03, 0xee, 0x83,
-	0x33, 0xa2, 0xa7, 0x25, 0x6c, 0x7d, 0x8a, 0xe5, 0x7c, 0x78, 0xc6, 0x2d, 0x70, 0x86, 0xd3, 0x83,
-	0xd1, 0x3f, 0xa5, 0x19, 0x82, 0x92, 0x53, 0x99, 0x17, 0xb1, 0x9e, 0xce, 0x17, 0xf9, 0x28, 0x9c,
-	0x1b, 0x60, 0x06, 0xa6, 0x76, 0xd9, 0x1e, 0xea, 0xac, 0xd8, 0x55, 0x5e, 0xc4, 0xbc, 0x60, 0xd2,
-	0x55, 0x02, 0x77, 0xbc, 0x19, 0xaa, 0xd0, 0x92, 0x0f, 0xf6, 0xec, 0x58, 0xb7, 0xab, 0x01, 0x26,
-	0x33, 0x90, 0x8b, 0xeb, 0x9c, 0x42, 0xf6, 0x20, 0x4b, 0xfa, 0x9d, 0x1f, 0x09, 0x2c, 0xa5, 0xfd,
-	0xb9, 0xc1, 0xd4, 0x78, 0xa3, 0xb6, 0x11, 0x07, 0x11, 0x6d, 0xda, 0x10, 0x32, 0xdd, 0x76, 0x9d,
-	0xac, 0x60, 0x78, 0x32, 0x07, 0xd1, 0x2c, 0xc1, 0x6a, 0xc4, 0x0a, 0x20, 0xd3, 0x10, 0x2a, 0x11,
-	0xcb, 0x2f, 0x3d, 0x67, 0x82, 0x67, 0x59, 0xc3, 0xc5, 0xd8, 0x79, 0xee, 0xf1, 0x44, 0x20, 0x8b,
-	0xfa, 0x77, 0x9c, 0xa8, 0x90, 0x51, 0x97, 0x93, 0xb4, 0xf2, 0x09, 0xa5, 0x39, 0xc3, 0x9b, 0x67,
-	0xab, 0x77, 0x23, 0x18, 0x01, 0xd0, 0xdd, 0xe3, 0xf9, 0xb1, 0x54, 0x97, 0x61, 0x97, 0x0f, 0x71,
-	0x51, 0x52, 0xf2, 0x24, 0xdc, 0xe4, 0xc4, 0x91, 0x27, 0x5e, 0x34, 0x3a, 0x78, 0x5c, 0x8f, 0xe8,
-	0x50, 0x8c, 0xc5, 0xe3, 0xd1, 0xd3, 0x76, 0xad, 0x11, 0x0e, 0x14, 0xf3, 0xb9, 0x38, 0xdc, 0x81,
-	0x98, 0x89, 0x3f, 0x03, 0xa0, 0x36, 0x2d, 0x52, 0x44, 0x2e, 0x20, 0x15, 0xef, 0x05, 0x31, 0xa7,
-	0xe6, 0xb3, 0x00, 0xb3, 0x70, 0x09, 0xcc, 0xd7, 0x21, 0xeb, 0xda, 0x06, 0xcd, 0x67, 0x99, 0xf8,
-	0xd9, 0x31, 0xe2, 0xa8, 0x8f, 0xa1, 0xba, 0xa4, 0x00, 0x08, 0x06, 0x87, 0xea, 0x15, 0x8c, 0xd3,
-	0xc2, 0x37, 0xad, 0xde, 0x6e, 0xb3, 0x5b, 0xff, 0xa6, 0x9a, 0x42, 0x77, 0x2a, 0x76, 0x34, 0xad,
-	0xab, 0x37, 0xdb, 0xdf, 0x1c, 0x54, 0xd3, 0xb8, 0x3b, 0xe8, 0x6a, 0xc7, 0x38, 0x42, 0x30, 0x70,
-	0x46, 0x6d, 0xc4, 0x89, 0x31, 0xf7, 0x02, 0x64, 0x0f, 0xda, 0x07, 0x44, 0x0a, 0x91, 0x71, 0x4a,
-	0xd1, 0x1f, 0xb6, 0x8f, 0x0e, 0x9a, 0x48, 0xab, 0x08, 0x39, 0x46, 0x45, 0x3a, 0x25, 0x58, 0x69,
-	0x1d, 0x88, 0x07, 0xaa, 0x5d, 0x99, 0x63, 0x77, 0xb8, 0x90, 0x1e, 0xd1, 0x71, 0x5c, 0x31, 0x35,
-	0xb3, 0x9a, 0xe5, 0xa1, 0x88, 0x2b, 0x7a, 0x2b, 0x5e, 0x92, 0x47, 0x2b, 0xae, 0xcf, 0x89, 0xaa,
-	0xac, 0x7e, 0x09, 0x79, 0x71, 0xa2, 0xb9, 0xe8, 0x74, 0x5c, 0xc5, 0xe6, 0x43, 0x38, 0xa7, 0x17,
-	0xea, 0xd2, 0xcc, 0xa9, 0x3e, 0x3f, 0xa9, 0xcf, 0x6d, 0x37, 0x30, 0x21, 0x05, 0x29, 0x11, 0xd5,
-	0xf1, 0x68, 0xaf, 0xbd, 0x53, 0xdf, 0xc3, 0x3d, 0xa0, 0xd0, 0x7b, 0xed, 0x06, 0xfe, 0x4c, 0xd1,
-	0x72, 0xbd, 0xf9, 0x95, 0xde, 0x3a, 0x10, 0x1b, 0xa0, 0xdf, 0xed, 0xa3, 0x5e, 0x35, 0x43, 0xb7,
-	0x0b, 0xc7, 0xdd, 0x87, 0xd5, 0xec, 0xf6, 0xaf, 0xa1, 0x18, 0x9e, 0xbc, 0xe2, 0x6a, 0xbd, 0xd9,
-	0x44, 0x12, 0xf8, 0xa3, 0xa9, 0x11, 0x01, 0x44, 0xc2, 0x1f, 0x7a, 0x7d, 0x6f, 0x4f, 0x50, 0xe8,
-	0x6a, 0x9d, 0xbd, 0x7a, 0x03, 0xf5, 0x48, 0xa4, 0xb5, 0x83, 0xfa, 0xce, 0x9e, 0x56, 0xcd, 0x32,
-	0x54, 0xeb, 0x90, 0x1f, 0x72, 0xc4, 0x1e, 0xc7, 0x33, 0xad, 0x57, 0xcd, 0x93, 0x36, 0x0f, 0xdb,
-	0x0f, 0x7b, 0xe2, 0x71, 0x65, 0x5b, 0xa7, 0x43, 0xce, 0xb0, 0x11, 0x45, 0x0a, 0x1d, 0x9c, 0xe5,
-	0x5a, 0xdf, 0x0a, 0x13, 0x1e, 0x68, 0xad, 0x47, 0xbb, 0x3b, 0xed, 0x2e, 0x72, 0x45, 0xf6, 0xbd,
-	0xfa, 0x23, 0x29, 0xf3, 0xa1, 0xde, 0xa9, 0xf7, 0x76, 0xab, 0x94, 0x27, 0x8a, 0x8d, 0xf6, 0xfe,
-	0xfe, 0xd1, 0x41, 0xab, 0xf7, 0x5d, 0x95, 0x06, 0x96, 0x8a, 0xf6, 0x6d, 0x4f, 0x0f, 0x97, 0x72,
-	0xdb, 0xef, 0x61, 0x57, 0x32, 0x6f, 0x3c, 0x69, 0x33, 0x07, 0xdf, 0x89, 0xcd, 0x90, 0xfc, 0xac,
-	0x0d, 0x34, 0xa1, 0xd6, 0xed, 0x55, 0xd3, 0xdb, 0xdb, 0x50, 0x5d, 0x6a, 0x31, 0xf3, 0x90, 0xd6,
-	0xbe, 0x46, 0x04, 0xfc, 0xff, 0x48, 0x43, 0x78, 0xfc, 0x8f, 0x3b, 0x4a, 0x6f, 0x7f, 0x28, 0xfb,
-	0x7b, 0x59, 0xb9, 0x43, 0x6f, 0x21, 0xf5, 0x36, 0x1a, 0x5a, 0xa7, 0x27, 0x88, 0x77, 0xb5, 0xaf,
-	0xb4, 0x06, 0x11, 0x3f, 0x82, 0x8d, 0xa4, 0x4e, 0x0a, 0x25, 0x9e, 0x4b, 0xab, 0x0b, 0x45, 0x6f,
-	0x42, 0x35, 0x5c, 0xea, 0x6a, 0xfb, 0xed, 0x63, 0x62, 0x7c, 0x15, 0xd6, 0xa3, 0xab, 0x42, 0xe5,
-	0xe9, 0xed, 0x3b, 0x50, 0x89, 0x77, 0x54, 0xa8, 0x9e, 0x7d, 0xad, 0xa9, 0xef, 0xb7, 0x89, 0xd4,
-	0x1a, 0x94, 0xe8, 0x21, 0x00, 0x4f, 0x6d, 0x7f, 0x00, 0x10, 0x29, 0xad, 0xb8, 0x19, 0x74, 0x03,
-	0x96, 0xb9, 0xb5, 0xdf, 0x69, 0x77, 0xa5, 0xcc, 0xda, 0xb7, 0xfc, 0x3b, 0x7d, 0xff, 0x3f, 0xeb,
-	0x50, 0x78, 0x44, 0x51, 0x57, 0x77, 0x2c, 0xe5, 0x73, 0x58, 0x7b, 0x64, 0xfa, 0xd1, 0x63, 0x7c,
-	0x25, 0x92, 0x32, 0xe7, 0x47, 0xfa, 0xb5, 0xe5, 0xa3, 0xf9, 0x2b, 0xca, 0x2e, 0x5f, 0x20, 0xc5,
-	0x70, 0xd5, 0xd8, 0x5d, 0x58, 0xe2, 0xed, 0x40, 0x6d, 0x6d, 0xe1, 0xe2, 0x19, 0x29, 0xfd, 0x0c,
-	0xca, 0x28, 0x45, 0x70, 0x91, 0xe9, 0x25, 0x8b, 0xb0, 0x78, 0x3f, 0x79, 0xe5, 0x5e, 0x0a, 0x73,
-	0x6a, 0x29, 0x82, 0x78, 0x41, 0x3c, 0xe5, 0x97, 0xa8, 0xc0, 0xf0, 0xde, 0x54, 0x79, 0x2b, 0x26,
-	0xf4, 0xd2, 0x75, 0x6a, 0x92, 0xc0, 0x1f, 0x60, 0x14, 0x9a, 0x7e, 0xd7, 0x3a, 0x51, 0x22, 0x2f,
-	0xf9, 0xfc, 0xbd, 0xb6, 0xb8, 0x80, 0xd0, 0x1f, 0x62, 0xa0, 0x50, 0x49, 0x4b, 0x96, 0x2f, 0x81,
-	0xfc, 0xc7, 0x18, 0x4e, 0xf6, 0xd0, 0xbf, 0x1c, 0xd2, 0xa7, 0x50, 0x9a, 0x23, 0xb5, 0xa6, 0x17,
-	0x46, 0x7b, 0x00, 0xe5, 0x39, 0x5a, 0x7b, 0x76, 0x71, 0x76, 0xf7, 0xa1, 0x70, 0x78, 0x3a, 0xf3,
-	0xa9, 0xb3, 0xb9, 0x30, 0xce, 0x3d, 0x74, 0x43, 0x3e, 0xdf, 0xbc, 0x30, 0xc6, 0x47, 0x98, 0x70,
-	0x2c, 0xef, 0x52, 0x28, 0x3b, 0x18, 0x2b, 0xe2, 0xf6, 0x5d, 0xa9, 0xc5, 0x2c, 0x1b, 0xfb, 0x9a,
-	0xa0, 0x76, 0x7d, 0xe9, 0x5d, 0x70, 0x9b, 0x8f, 0x34, 0xbe, 0xc4, 0xd1, 0x57, 0xde, 0xe0, 0x2b,
-	0xaf, 0x2f, 0x01, 0x7a, 0x2f, 0xe2, 0x7f, 0x3b, 0x45, 0xd5, 0x51, 0xde, 0xf2, 0x26, 0x7a, 0x48,
-	0xf2, 0x5d, 0x0a, 0xfb, 0x73, 0x93, 0x6e, 0x1b, 0x19, 0x73, 0x07, 0xdf, 0x34, 0x4e, 0x8d, 0xe9,
-	0xc8, 0x1c, 0x24, 0xef, 0xfc, 0x05, 0x54, 0xbe, 0x98, 0xdf, 0x32, 0x87, 0xe7, 0xb9, 0x17, 0x0e,
-	0xa9, 0x26, 0x7d, 0x54, 0x20, 0x84, 0x6f, 0xd7, 0xc3, 0x02, 0x9a, 0x4c, 0x60, 0x23, 0xa1, 0xe6,
-	0x33, 0x95, 0xcf, 0x39, 0x40, 0xb0, 0xc5, 0x56, 0x22, 0x53, 0x74, 0xf4, 0x66, 0xbf, 0xb6, 0x19,
-	0x5b, 0x0f, 0x0e, 0x12, 0x09, 0xf7, 0x01, 0xe4, 0xc5, 0x67, 0x00, 0x4a, 0xdc, 0x46, 0x31, 0xf4,
-	0x44, 0x4f, 0xce, 0x8b, 0x0b, 0xf8, 0x05, 0xbc, 0xe8, 0x95, 0x7c, 0xb2, 0x27, 0xaf, 0x50, 0x30,
-	0x53, 0x7f, 0xf9, 0x32, 0x2d, 0x11, 0x10, 0xcb, 0xf8, 0x19, 0x3b, 0x19, 0xe3, 0xc4, 0x9d, 0x2c,
-	0x76, 0x55, 0x7f, 0x4e, 0x0c, 0x10, 0x37, 0xec, 0x3f, 0x13, 0x99, 0x2d, 0x74, 0x44, 0xc4, 0xeb,
-	0x23, 0x96, 0x0f, 0xdb, 0x10, 0xef, 0xa5, 0x28, 0x74, 0x01, 0x1f, 0xaa, 0x90, 0x3a, 0x97, 0xb8,
-	0x2a, 0xa2, 0xb7, 0xfd, 0x49, 0xc2, 0xfd, 0x1c, 0x2a, 0xc8, 0x2a, 0x72, 0xd0, 0x94, 0x78, 0xfd,
-	0x5e, 0x4b, 0xbe, 0x94, 0xa7, 0xc4, 0xba, 0x1a, 0x43, 0xf7, 0x2e, 0x87, 0xcf, 0xde, 0x57, 0x89,
-	0x7d, 0x3d, 0xa0, 0xdc, 0x88, 0xc9, 0x9f, 0xf0, 0x59, 0x41, 0xd2, 0x36, 0x3e, 0xe7, 0x7a, 0x12,
-	0xce, 0xd0, 0x49, 0xb3, 0x6b, 0x2d, 0x71, 0xa0, 0x0d, 0x54, 0x30, 0x5f, 0xf1, 0x2e, 0x83, 0x8c,
-	0x1b, 0xd8, 0x81, 0x72, 0xf4, 0xcb, 0x04, 0xe5, 0xed, 0x98, 0xfc, 0xcb, 0x5f, 0x2c, 0x24, 0x27,
-	0xbd, 0x22, 0x8a, 0x20, 0x27, 0xda, 0xa5, 0x19, 0xb7, 0xb6, 0xb4, 0x22, 0x92, 0x7f, 0x80, 0x82,
-	0xe3, 0xf1, 0xc5, 0x90, 0x50, 0xda, 0x2f, 0xb1, 0x8f, 0x0a, 0xbe, 0x1e, 0x50, 0xde, 0x88, 0x27,
-	0xba, 0xf8, 0x27, 0x0f, 0x49, 0x72, 0xb6, 0x61, 0x63, 0x2e, 0x67, 0x64, 0xfe, 0x7f, 0xc1, 0x85,
-	0x60, 0xed, 0x45, 0x97, 0x85, 0x57, 0x94, 0x0e, 0x6c, 0x24, 0x7c, 0xcc, 0xa0, 0xdc, 0x4c, 0x12,
-	0x6c, 0xf9, 0x5b, 0x87, 0x04, 0x11, 0x4f, 0xf2, 0xfc, 0x35, 0xdc, 0xc7, 0xff, 0x0d, 0x00, 0x00,
-	0xff, 0xff, 0x1e, 0x2f, 0x9b, 0xe3, 0x1c, 0x27, 0x00, 0x00,
+	// 3680 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x3a, 0xdb, 0x72, 0x1b, 0x47,
+	0x76, 0x1c, 0xdc, 0xe7, 0x00, 0x20, 0x87, 0x4d, 0x52, 0x1e, 0xd1, 0x37, 0x7a, 0x56, 0x91, 0xb9,
+	0x5c, 0x4b, 0x96, 0xb5, 0x5e, 0xc5, 0xe5, 0x75, 0x36, 0x81, 0x80, 0x11, 0x05, 0x1b, 0xb7, 0x05,
+	0x40, 0xda, 0xae, 0x4a, 0xd5, 0xd4, 0x10, 0xd3, 0x00, 0x3b, 0x06, 0x66, 0x46, 0xd3, 0x03, 0x4a,
+	0xac, 0xca, 0x53, 0xf2, 0x94, 0x5f, 0x48, 0xa5, 0xb6, 0x2a, 0x79, 0xc8, 0x27, 0xe4, 0x39, 0x95,
+	0x7c, 0x40, 0xfe, 0x20, 0x2f, 0x79, 0xd8, 0xdf, 0x48, 0x75, 0xf7, 0xdc, 0x31, 0x94, 0x48, 0x6f,
+	0x2a, 0x2f, 0x24, 0xa6, 0xfb, 0xdc, 0xfa, 0xdc, 0xfa, 0x9c, 0xee, 0x86, 0xfa, 0xc2, 0xb9, 0x58,
+	0xb8, 0x8f, 0x5d, 0xcf, 0xf1, 0x1d, 0x54, 0xe3, 0x1f, 0xa6, 0x4b, 0x34, 0x13, 0xca, 0xba, 0xe7,
+	0x39, 0x1e, 0xfa, 0x14, 0x4a, 0x33, 0xc7, 0xc2, 0xaa, 0x74, 0x24, 0x1d, 0x6f, 0x3f, 0xbd, 0xff,
+	0x38, 0x84, 0x78, 0xcc, 0xa7, 0xc5, 0xdf, 0xb6, 0x63, 0x61, 0x54, 0x87, 0xe2, 0x8a, 0x2e, 0xd4,
+	0xc2, 0x91, 0x74, 0x2c, 0x6b, 0x1a, 0xc8, 0xc9, 0x99, 0xea, 0xe4, 0xac, 0xdd, 0xd6, 0x27, 0x13,
+	0x65, 0x0b, 0xd5, 0xa0, 0xf4, 0xa2, 0xd5, 0xed, 0x29, 0x92, 0x36, 0x04, 0xb9, 0xe5, 0x2d, 0xd6,
+	0x2b, 0x6c, 0xfb, 0x14, 0x3d, 0x80, 0x9a, 0x87, 0xa9, 0xb3, 0xf6, 0x66, 0x21, 0x2b, 0x14, 0xb3,
+	0x1a, 0x07, 0x33, 0x68, 0x1b, 0x2a, 0x73, 0x73, 0x45, 0x96, 0xd7, 0x9c, 0x4d, 0x13, 0x35, 0xa0,
+	0x64, 0x9b, 0x2b, 0xac, 0x16, 0x39, 0xd3, 0x7f, 0x94, 0x40, 0xe9, 0x3b, 0xd6, 0xc8, 0xf4, 0x2f,
+	0x63, 0xc2, 0x0f, 0x41, 0x76, 0x5c, 0xec, 0x99, 0x3e, 0x71, 0xec, 0x80, 0xf2, 0x5e, 0x4c, 0x79,
+	0x18, 0x4e, 0xa5, 0x04, 0x28, 0xdc, 0x28, 0x40, 0x8a, 0x21, 0xfa, 0x00, 0x4a, 0xae, 0xe9, 0x5f,
+	0xaa, 0xa5, 0x23, 0xe9, 0xb8, 0xfe, 0x74, 0x3b, 0x86, 0x67, 0x22, 0x30, 0xd8, 0xf5, 0x9a, 0x58,
+	0x6a, 0xf9, 0x48, 0x3a, 0x6e, 0x68, 0x1f, 0xc3, 0x4e, 0x20, 0xdb, 0x18, 0x53, 0xd7, 0xb1, 0x29,
+	0x8e, 0x00, 0x24, 0x0e, 0x30, 0x87, 0xdd, 0x00, 0x80, 0xde, 0x55, 0x2d, 0xa1, 0x54, 0x5c, 0xf7,
+	0xe8, 0x43, 0x28, 0x33, 0xa9, 0xa8, 0x5a, 0x3c, 0x2a, 0x6e, 0x8a, 0xa5, 0xfd, 0x35, 0xec, 0xf7,
+	0x1d, 0x6b, 0x80, 0xc9, 0xe2, 0xf2, 0xc2, 0xf1, 0xee, 0xae, 0x28, 0xb6, 0x68, 0x8c, 0x3d, 0xce,
+	0x2c, 0x4d, 0x1d, 0x63, 0x4f, 0x73, 0xa1, 0xd1, 0xf7, 0xfc, 0x3f, 0xd5, 0xae, 0x0a, 0xd4, 0x88,
+	0xed, 0x63, 0xef, 0xca, 0x5c, 0x72, 0x55, 0x97, 0x90, 0x0a, 0x8a, 0x1d, 0x88, 0x6c, 0x98, 0x96,
+	0xe5, 0x61, 0x4a, 0xb9, 0xda, 0x65, 0xed, 0x3b, 0xae, 0xd8, 0x14, 0xd3, 0xdb, 0x2e, 0x45, 0x81,
+	0xda, 0x9c, 0x2c, 0x71, 0xac, 0x3b, 0xed, 0x3f, 0x25, 0x4e, 0xed, 0xf9, 0xca, 0xbd, 0x3b, 0xb5,
+	0x1d, 0xa8, 0x86, 0x92, 0x09, 0x43, 0x34, 0xa0, 0xe4, 0x3a, 0x9e, 0xcf, 0x57, 0xd0, 0x44, 0x5f,
+	0x41, 0xc9, 0xbf, 0x76, 0x31, 0x97, 0x7a, 0xfb, 0xe9, 0x49, 0x4c, 0x21, 0xc3, 0xef, 0x71, 0xdf,
+	0xb1, 0x89, 0xef, 0x78, 0xc4, 0x5e, 0x8c, 0x9c, 0x25, 0x99, 0x5d, 0x6b, 0x9f, 0x83, 0x92, 0x1d,
+	0x43, 0x55, 0x28, 0x8e, 0xc6, 0xba, 0x88, 0xa7, 0xd1, 0x70, 0x32, 0x55, 0x24, 0xf6, 0xeb, 0xf9,
+	0x70, 0xfa, 0x52, 0x29, 0x68, 0x3f, 0xf2, 0x38, 0x18, 0xbb, 0x3f, 0x91, 0xff, 0xeb, 0x55, 0x68,
+	0x67, 0x5c, 0x3f, 0xe7, 0xde, 0xfc, 0xee, 0x94, 0x0f, 0xa1, 0x78, 0xe5, 0xcd, 0x03, 0xbf, 0x69,
+	0xc6, 0x10, 0xe7, 0xde, 0x5c, 0x9b, 0xc1, 0xbd, 0xbe, 0x63, 0x75, 0xf0, 0x9c, 0xd8, 0xd8, 0x9a,
+	0xe0, 0x9f, 0x61, 0xcb, 0x4f, 0xa0, 0x48, 0xb1, 0x1f, 0x50, 0xdf, 0x8f, 0x21, 0x62, 0x9a, 0xda,
+	0x02, 0x0e, 0xfa, 0x8e, 0x35, 0xf1, 0x4d, 0x1f, 0x33, 0xda, 0x77, 0xe7, 0xf1, 0x10, 0x64, 0x1a,
+	0x62, 0x07, 0x9c, 0x12, 0x70, 0x11, 0x61, 0xed, 0x0f, 0x12, 0x20, 0x16, 0xcb, 0xdc, 0x54, 0x77,
+	0x67, 0x73, 0x04, 0x15, 0x97, 0xa3, 0x06, 0x3c, 0x94, 0x44, 0x8c, 0x09, 0xeb, 0x7f, 0x02, 0xf7,
+	0x3d, 0x3c, 0xc7, 0x9e, 0x81, 0xdf, 0x10, 0xea, 0x13, 0x7b, 0x61, 0x44, 0x72, 0x51, 0x6e, 0xa8,
+	0x1a, 0x7a, 0x1f, 0xf6, 0x5c, 0x0f, 0x53, 0xec, 0x5d, 0xe1, 0xe4, 0x24, 0xf3, 0xbe, 0x9a, 0x76,
+	0x05, 0x1f, 0xc4, 0xf2, 0x51, 0x4a, 0x16, 0xf6, 0xcf, 0x53, 0xc8, 0x63, 0x00, 0x33, 0x42, 0x0f,
+	0xa4, 0x3d, 0xcc, 0x4a, 0x1b, 0x33, 0xd0, 0x2c, 0x50, 0xfb, 0x8e, 0x75, 0xba, 0x74, 0x2e, 0xcc,
+	0x65, 0xdb, 0xb1, 0xe7, 0x64, 0xf1, 0xb3, 0xb4, 0xb3, 0xe0, 0x04, 0x36, 0xb5, 0x23, 0x08, 0x6b,
+	0xff, 0x25, 0x41, 0x29, 0xcc, 0xc0, 0xf6, 0xd2, 0x23, 0x22, 0xc1, 0xb2, 0x24, 0xe3, 0x9a, 0xbe,
+	0xef, 0x31, 0xc7, 0x2e, 0x1e, 0x37, 0xd8, 0x86, 0x65, 0x2e, 0x44, 0x2a, 0x2f, 0x32, 0xd0, 0x0b,
+	0x4c, 0x7d, 0xa1, 0x1f, 0xb4, 0x07, 0x75, 0x42, 0x8d, 0xd7, 0xc4, 0xbf, 0xb4, 0x3c, 0xf3, 0x35,
+	0xcf, 0xe0, 0x35, 0x84, 0x00, 0xae, 0xcc, 0x25, 0xb1, 0x84, 0x84, 0x95, 0x23, 0xe9, 0xb8, 0x8c,
+	0x3e, 0x80, 0x7d, 0xdb, 0x31, 0xc8, 0xca, 0x5d, 0x92, 0x19, 0xf1, 0x63, 0x8c, 0x2a, 0xc7, 0x88,
+	0xd3, 0x5a, 0x8d, 0xa7, 0x00, 0x04, 0x20, 0x12, 0x9e, 0x61, 0x52, 0x5b, 0x95, 0xf9, 0xd8, 0x2e,
+	0xc8, 0xc1, 0x18, 0xb1, 0x54, 0xe0, 0x11, 0x27, 0xd2, 0x92, 0x8f, 0x3d, 0x6c, 0xa9, 0x75, 0x6e,
+	0xaf, 0x33, 0xa8, 0x77, 0x30, 0x33, 0xb4, 0x50, 0x01, 0x5b, 0x89, 0x87, 0xe7, 0xe4, 0x8d, 0x2a,
+	0xa5, 0x33, 0x7e, 0x21, 0x2f, 0xe3, 0xa3, 0xf7, 0x60, 0x67, 0xe9, 0xd8, 0x0b, 0xec, 0x19, 0x02,
+	0x0b, 0x07, 0x3e, 0xa2, 0xfd, 0x83, 0x04, 0xe5, 0xa9, 0x79, 0xb1, 0xc4, 0xe8, 0x28, 0x48, 0x4e,
+	0xb7, 0xdd, 0x63, 0xe2, 0x95, 0x89, 0xe4, 0xf6, 0x2b, 0x68, 0x58, 0xb1, 0x80, 0xcc, 0xcd, 0x98,
+	0x20, 0x07, 0xc9, 0x30, 0x8c, 0xc5, 0xdf, 0x83, 0xba, 0xeb, 0x50, 0xdf, 0x08, 0x9c, 0x9c, 0x6b,
+	0x57, 0xfb, 0x9f, 0x02, 0x94, 0xd8, 0x0e, 0xc2, 0x57, 0xcf, 0x48, 0x13, 0x2c, 0xd6, 0xc3, 0x89,
+	0x9b, 0xae, 0xbb, 0xbc, 0x0e, 0x11, 0x8a, 0x47, 0x52, 0x9a, 0x78, 0x8b, 0xcd, 0x06, 0xa1, 0x71,
+	0xc4, 0xea, 0x15, 0x7b, 0xce, 0xa9, 0xd6, 0x93, 0x2b, 0x61, 0xc4, 0x99, 0xcf, 0xa1, 0x47, 0xd0,
+	0xc4, 0x17, 0x0b, 0xd7, 0x58, 0xad, 0x97, 0x3e, 0xb9, 0x74, 0x5c, 0x6e, 0xca, 0xfa, 0xd3, 0x7b,
+	0x31, 0xa8, 0x7e, 0xb1, 0x70, 0xfb, 0xc1, 0x2c, 0xfa, 0x02, 0x76, 0x3c, 0x67, 0xed, 0x63, 0xc3,
+	0xc3, 0xf3, 0x25, 0x9e, 0xf9, 0x8e, 0xc7, 0xcd, 0x54, 0x7f, 0xaa, 0x26, 0xb4, 0xc4, 0x00, 0xc6,
+	0xe1, 0x3c, 0xfa, 0x04, 0x4a, 0xc4, 0x9e, 0x3b, 0x6a, 0x3d, 0x9b, 0x22, 0x98, 0x0c, 0x3c, 0x4d,
+	0x30, 0x2f, 0xf6, 0xc9, 0x0a, 0x7b, 0x54, 0x6d, 0x64, 0xbd, 0x78, 0xca, 0xc7, 0x59, 0x3c, 0xf8,
+	0x9e, 0x69, 0x53, 0x9e, 0x7c, 0x9b, 0x59, 0x4a, 0xd3, 0x70, 0x8a, 0x69, 0x47, 0xc8, 0xc7, 0x63,
+	0xdd, 0x53, 0x77, 0xb2, 0xda, 0xe1, 0xc2, 0x4d, 0xf8, 0xa4, 0xf6, 0x2f, 0x12, 0xd4, 0x93, 0xda,
+	0x7a, 0x04, 0x32, 0xb1, 0x43, 0xbd, 0x4a, 0xef, 0x8a, 0x5f, 0xf4, 0x05, 0x34, 0xf1, 0x1b, 0xc6,
+	0xd5, 0x48, 0x25, 0xa8, 0x77, 0xa0, 0x90, 0x55, 0x12, 0xa5, 0xf8, 0xce, 0x2c, 0xf1, 0x4f, 0x05,
+	0xa8, 0x45, 0xd6, 0x3a, 0x80, 0xa6, 0xb9, 0xf6, 0x2f, 0x0d, 0xd7, 0xa4, 0xf4, 0xb5, 0xe3, 0x59,
+	0x81, 0xcb, 0xef, 0x41, 0xdd, 0xc2, 0x74, 0xe6, 0x11, 0x97, 0x47, 0x63, 0x21, 0x0c, 0x9c, 0xa5,
+	0x33, 0x33, 0x97, 0x86, 0x49, 0x03, 0xbf, 0xbc, 0xb1, 0x6c, 0x60, 0xfb, 0x9c, 0x8b, 0xb1, 0xc7,
+	0x40, 0xcb, 0x61, 0x70, 0xf2, 0x81, 0x85, 0xe7, 0xac, 0x85, 0x4f, 0xc8, 0x2c, 0x38, 0xf9, 0x18,
+	0x8f, 0x8d, 0x2a, 0x07, 0xbb, 0x0f, 0xbb, 0x1e, 0x5e, 0x39, 0x57, 0xd8, 0x70, 0x3d, 0x72, 0x65,
+	0xfa, 0x2c, 0x96, 0x83, 0xf0, 0x3e, 0x04, 0x24, 0x2c, 0x31, 0x5f, 0x9a, 0xae, 0x61, 0x99, 0x2b,
+	0x97, 0xd8, 0x0b, 0x1e, 0xe6, 0x35, 0x74, 0x0f, 0xb6, 0x29, 0xb6, 0x2d, 0x63, 0xe6, 0xac, 0x56,
+	0x6b, 0x9b, 0xf8, 0xd7, 0x2a, 0x84, 0x5c, 0x19, 0x39, 0x1f, 0x1b, 0x33, 0xd3, 0x55, 0xeb, 0x3c,
+	0x31, 0xed, 0x82, 0x2c, 0x96, 0xc1, 0x86, 0x1a, 0x7c, 0x08, 0xa0, 0x40, 0x2c, 0xee, 0x05, 0xb2,
+	0xf6, 0x1b, 0x68, 0xa4, 0x1c, 0x74, 0x07, 0xaa, 0xd8, 0x66, 0x51, 0x2c, 0x74, 0x53, 0x43, 0xfb,
+	0xd0, 0x08, 0x7d, 0xdb, 0xf0, 0x7d, 0x91, 0x27, 0x9b, 0xda, 0x14, 0xb6, 0x33, 0x6e, 0xfa, 0x11,
+	0xdc, 0xcb, 0x78, 0xb6, 0x31, 0x5b, 0x12, 0x96, 0xc9, 0x05, 0x1d, 0x0d, 0x0e, 0x37, 0xe7, 0xd7,
+	0xd4, 0xc7, 0x1e, 0xcb, 0x55, 0x82, 0xea, 0x1f, 0x8b, 0x20, 0xc7, 0x5e, 0xfd, 0xa7, 0x19, 0xeb,
+	0x01, 0xd4, 0x56, 0x98, 0x52, 0x73, 0x81, 0xa9, 0x5a, 0xca, 0x86, 0x6f, 0x3f, 0x98, 0xc9, 0x35,
+	0x69, 0x39, 0x6b, 0xd2, 0x4a, 0x8e, 0x49, 0xab, 0x9b, 0x26, 0x15, 0x76, 0x3b, 0x82, 0xca, 0xab,
+	0x35, 0x5e, 0x63, 0xaa, 0xca, 0xd9, 0x58, 0xfc, 0x3d, 0x1f, 0xcf, 0x37, 0x3a, 0xbc, 0xc5, 0xe8,
+	0xf5, 0x1b, 0x8c, 0xde, 0xe0, 0x38, 0x07, 0xd0, 0xa4, 0x98, 0x52, 0xe2, 0xd8, 0x62, 0x6b, 0xe6,
+	0x86, 0x6d, 0x32, 0x7b, 0xd0, 0xb5, 0xcb, 0x62, 0x05, 0x5b, 0xcc, 0xf6, 0xe6, 0x05, 0x59, 0x12,
+	0x9f, 0xe5, 0xc1, 0xed, 0xa3, 0xa2, 0x10, 0x9d, 0xe5, 0x2d, 0x81, 0xb2, 0x13, 0x6a, 0xd6, 0xb4,
+	0x56, 0x24, 0xa4, 0xa3, 0x84, 0x9a, 0xf5, 0xf0, 0x0c, 0x93, 0x2b, 0x6c, 0xa9, 0xbb, 0x61, 0x3d,
+	0x6d, 0xce, 0x66, 0xd8, 0xf5, 0xb1, 0xa5, 0xa2, 0x50, 0x35, 0xa6, 0x75, 0x85, 0x3d, 0x9f, 0x50,
+	0x6c, 0xa9, 0x7b, 0x7c, 0xac, 0x09, 0x65, 0x67, 0xed, 0x1b, 0xaf, 0xd4, 0xfd, 0xf0, 0x73, 0xbe,
+	0x74, 0x5c, 0xaa, 0x1e, 0x70, 0x4b, 0x8f, 0xa0, 0x16, 0xd9, 0xe0, 0x17, 0x09, 0x0e, 0x22, 0x6b,
+	0xec, 0x6e, 0x58, 0x0a, 0x7d, 0x0c, 0x25, 0x1a, 0x97, 0x05, 0x9b, 0x00, 0xda, 0xdf, 0x4b, 0x50,
+	0x0d, 0x81, 0xf7, 0xa1, 0x31, 0x18, 0x4e, 0xbb, 0x2f, 0xba, 0xed, 0xd6, 0xb4, 0x3b, 0x1c, 0x70,
+	0xaa, 0x25, 0xb6, 0xcd, 0x9c, 0x8d, 0x3a, 0xad, 0xa9, 0xce, 0x89, 0x94, 0xd8, 0x26, 0x34, 0x1c,
+	0xe9, 0x83, 0xa0, 0x27, 0xd8, 0x05, 0xf9, 0x3b, 0x5d, 0x1f, 0xb5, 0x7a, 0xdd, 0x73, 0x9d, 0x3b,
+	0x4c, 0x89, 0xb9, 0xc0, 0x58, 0x7f, 0x31, 0xd6, 0x27, 0x2f, 0xd5, 0x72, 0x08, 0xd3, 0xe9, 0x4e,
+	0xda, 0xad, 0x71, 0x47, 0xef, 0x70, 0xaf, 0x28, 0xb1, 0x75, 0x4d, 0x87, 0xd3, 0x56, 0x8f, 0x3b,
+	0x44, 0x49, 0xfb, 0x14, 0x2a, 0x81, 0x95, 0x9b, 0x50, 0x26, 0xb6, 0xbb, 0x16, 0xee, 0xdf, 0x64,
+	0xcc, 0x9d, 0xb5, 0xcf, 0xbe, 0x85, 0xab, 0x9f, 0x43, 0x25, 0x4a, 0xcd, 0x95, 0x19, 0xaf, 0x5e,
+	0x54, 0x29, 0xbb, 0x75, 0x08, 0x08, 0x51, 0xdb, 0xa0, 0x07, 0x50, 0x16, 0x76, 0x29, 0x64, 0x73,
+	0xb2, 0x00, 0xe3, 0x41, 0xa3, 0xfd, 0x2d, 0x34, 0x52, 0x58, 0x07, 0xd0, 0x9c, 0x39, 0xb6, 0x8d,
+	0x67, 0xbe, 0xe1, 0x61, 0xdf, 0xbb, 0x0e, 0x74, 0xb1, 0x0b, 0xf2, 0xa5, 0xb3, 0xb4, 0x0c, 0xb6,
+	0x6d, 0x04, 0xea, 0x38, 0x04, 0xf4, 0x13, 0xc6, 0xae, 0xb9, 0x24, 0x57, 0xd8, 0xc8, 0x34, 0x4c,
+	0x0f, 0xe1, 0xa3, 0x15, 0xb1, 0xc9, 0x6a, 0xbd, 0x32, 0x22, 0x43, 0xb3, 0xec, 0x1a, 0xc3, 0x71,
+	0x8d, 0x69, 0x7f, 0x28, 0x40, 0x3d, 0x21, 0xcd, 0xff, 0x2f, 0x77, 0x5e, 0x3f, 0xe1, 0x85, 0xe3,
+	0x13, 0x93, 0xf9, 0x7c, 0xcc, 0xa1, 0x1c, 0x9a, 0x7f, 0xed, 0xf2, 0x6f, 0x61, 0x39, 0x05, 0x6a,
+	0x96, 0xf3, 0xda, 0xe6, 0x23, 0xdc, 0x78, 0xcc, 0x8d, 0x23, 0x24, 0x8f, 0x87, 0x73, 0x89, 0x95,
+	0x3b, 0xb1, 0x5c, 0x62, 0x42, 0xe6, 0x13, 0xef, 0xc3, 0x5e, 0x6a, 0x69, 0xc1, 0x24, 0x84, 0x58,
+	0xc4, 0x5a, 0x62, 0x23, 0x41, 0xae, 0xce, 0x15, 0xf4, 0xcf, 0x12, 0xc8, 0xf1, 0x6e, 0x7b, 0x00,
+	0xcd, 0x20, 0x6b, 0x05, 0xa9, 0x47, 0x64, 0x38, 0x04, 0x20, 0x86, 0x19, 0x50, 0xd0, 0xc4, 0x1e,
+	0x40, 0x73, 0xe5, 0xaf, 0x0d, 0x8b, 0xd0, 0x99, 0x73, 0x85, 0xbd, 0xeb, 0xa0, 0x30, 0xdf, 0x87,
+	0x06, 0x4b, 0x8f, 0x4c, 0xb8, 0x15, 0x3b, 0x58, 0x29, 0x85, 0xa9, 0x22, 0xd8, 0x07, 0xd2, 0x39,
+	0x6d, 0x0f, 0xea, 0xc1, 0x38, 0xa7, 0x2c, 0xf2, 0xda, 0x0e, 0x54, 0xfd, 0x99, 0x6b, 0xac, 0x28,
+	0x15, 0x9b, 0x92, 0x76, 0x02, 0xf5, 0xc4, 0x2e, 0xcf, 0x16, 0x9a, 0x2c, 0x09, 0x52, 0x59, 0x5d,
+	0xeb, 0x43, 0x65, 0xc4, 0xcb, 0x40, 0x66, 0x53, 0xe2, 0x1a, 0xa9, 0x4a, 0xf2, 0x3d, 0xd8, 0x59,
+	0x99, 0xf4, 0x27, 0x63, 0x89, 0xed, 0x85, 0x7f, 0x69, 0xac, 0x88, 0x1d, 0x2c, 0x26, 0x3b, 0x61,
+	0xbe, 0x09, 0x1a, 0xc2, 0x57, 0x00, 0x71, 0x8b, 0x85, 0x7e, 0x91, 0xaa, 0x23, 0x0f, 0x36, 0xda,
+	0xb0, 0xe9, 0xb5, 0x9b, 0x2d, 0x25, 0x1b, 0x50, 0x5a, 0x12, 0xea, 0xf3, 0xd3, 0x0a, 0x19, 0x69,
+	0x50, 0x8b, 0x8a, 0x54, 0x51, 0x44, 0x26, 0xbb, 0x1f, 0x3e, 0xa3, 0xfd, 0x16, 0x6a, 0x7d, 0xd3,
+	0x9f, 0x5d, 0x32, 0x86, 0x9f, 0xa4, 0x18, 0x26, 0x0a, 0x24, 0x0e, 0xb1, 0xc9, 0x4e, 0x7b, 0x09,
+	0x8d, 0x16, 0x65, 0x65, 0x71, 0x8f, 0xaf, 0x04, 0x1d, 0xa7, 0x08, 0x24, 0xca, 0x92, 0x24, 0x14,
+	0xa7, 0xb3, 0x0d, 0x15, 0xb1, 0xfa, 0x20, 0x1f, 0xfc, 0x6b, 0x01, 0xa0, 0xed, 0xd8, 0x16, 0xe1,
+	0x25, 0x2f, 0x7a, 0x08, 0x20, 0x24, 0x37, 0x58, 0x1f, 0x2a, 0x6d, 0xec, 0x5f, 0xa1, 0xc4, 0xc7,
+	0xd0, 0x88, 0xf6, 0xaf, 0xb8, 0x63, 0xcd, 0x83, 0x7c, 0x0c, 0xdb, 0x26, 0x35, 0x58, 0x65, 0x1f,
+	0xa8, 0x5d, 0x2d, 0x66, 0xd3, 0x4d, 0x6a, 0x29, 0x9f, 0x42, 0x3d, 0x84, 0x67, 0x84, 0x4b, 0x37,
+	0x12, 0xfe, 0x25, 0x34, 0xa3, 0x2d, 0x89, 0x83, 0x96, 0x6f, 0x04, 0x7d, 0x04, 0xbb, 0xf8, 0x8d,
+	0x6f, 0xa4, 0xc1, 0x2b, 0x37, 0x82, 0x33, 0x77, 0x75, 0x7f, 0x22, 0x86, 0x87, 0xe9, 0x7a, 0xe9,
+	0x73, 0xef, 0x2c, 0x6b, 0x13, 0xd8, 0x69, 0x87, 0xf8, 0xad, 0x19, 0x6f, 0x01, 0x7e, 0x95, 0xd2,
+	0xfa, 0x87, 0x31, 0xa5, 0x0c, 0x20, 0x57, 0xfc, 0x1e, 0xd4, 0x43, 0xfe, 0x61, 0x53, 0x20, 0x6b,
+	0x2d, 0x90, 0xfb, 0xd8, 0x0a, 0xc8, 0xfd, 0x59, 0x8a, 0xdc, 0x7b, 0xc9, 0xad, 0xc6, 0x4a, 0x10,
+	0x6a, 0x42, 0xf9, 0xca, 0x5c, 0xae, 0x85, 0x2b, 0x14, 0x35, 0x1d, 0x76, 0x5a, 0x74, 0xe4, 0x61,
+	0x17, 0xdb, 0x21, 0x21, 0xd6, 0x13, 0x52, 0x3b, 0xde, 0x00, 0xd8, 0xa4, 0x99, 0x08, 0xe8, 0x35,
+	0xc5, 0xc6, 0x12, 0xcf, 0x7d, 0x63, 0xe5, 0x50, 0x3f, 0xe8, 0xa2, 0xfe, 0x28, 0x41, 0x55, 0xa0,
+	0xd3, 0xb8, 0x18, 0x37, 0x67, 0x89, 0x3e, 0x36, 0x5b, 0x8c, 0x07, 0xcc, 0x3e, 0x03, 0x39, 0xae,
+	0x0c, 0x84, 0x1b, 0xdc, 0xbf, 0x51, 0x13, 0xe8, 0x08, 0x8a, 0x2b, 0x6c, 0x05, 0x2e, 0xb0, 0x97,
+	0xb3, 0x44, 0xf4, 0x88, 0x75, 0xe3, 0x86, 0x2b, 0x16, 0xa4, 0x96, 0xb2, 0x04, 0xb3, 0x6b, 0x7d,
+	0x02, 0xcd, 0x94, 0x69, 0xd5, 0x72, 0x16, 0x23, 0x23, 0x82, 0xb6, 0x00, 0x39, 0x3a, 0xe4, 0x88,
+	0xc2, 0x4a, 0x24, 0x8e, 0x63, 0x80, 0x59, 0x14, 0x0b, 0x9b, 0xa7, 0x30, 0x89, 0x38, 0xd1, 0xa0,
+	0x2a, 0x94, 0x43, 0xd5, 0x62, 0xb6, 0x32, 0x08, 0xd4, 0xa8, 0xfd, 0x25, 0x54, 0x82, 0x06, 0x25,
+	0xcd, 0xe5, 0x53, 0x80, 0xc4, 0x59, 0x86, 0xe8, 0x76, 0x73, 0x4f, 0x60, 0xfe, 0x4d, 0x02, 0x65,
+	0xa3, 0x15, 0xd1, 0x52, 0x5e, 0xb2, 0x9f, 0xed, 0x40, 0xb8, 0x8b, 0xfc, 0x9c, 0x63, 0x60, 0x96,
+	0xb3, 0x18, 0x05, 0x92, 0x9b, 0xb3, 0xc4, 0x3a, 0x1e, 0x42, 0xd5, 0xc2, 0x73, 0x93, 0x05, 0x45,
+	0xf9, 0x2d, 0x3e, 0xa1, 0x1d, 0x02, 0xf4, 0x3d, 0x3f, 0xac, 0x8a, 0x1a, 0x50, 0xb2, 0x4c, 0xdf,
+	0x0c, 0x4e, 0x88, 0x9f, 0x40, 0x6d, 0x3c, 0xfa, 0xae, 0xcb, 0xdb, 0xa2, 0xc4, 0x31, 0x9d, 0x94,
+	0xb7, 0x51, 0x88, 0x14, 0xf5, 0xdf, 0x05, 0x90, 0x19, 0x8a, 0xd8, 0xda, 0xe3, 0xed, 0x54, 0xe2,
+	0x67, 0x1e, 0xc9, 0xed, 0x94, 0x47, 0x04, 0x6b, 0x33, 0xd6, 0x6e, 0xb0, 0x4f, 0x71, 0x82, 0x33,
+	0xc7, 0xb3, 0x0c, 0xe2, 0x5e, 0x7d, 0xc9, 0xdd, 0xa9, 0x99, 0x1e, 0x7c, 0x16, 0x74, 0x4e, 0xac,
+	0x9f, 0x17, 0x99, 0x8f, 0x43, 0x56, 0x36, 0x07, 0x9f, 0x05, 0xcd, 0xd3, 0x36, 0x54, 0x28, 0xf6,
+	0x88, 0xb9, 0x0c, 0x2a, 0xef, 0x03, 0x68, 0x86, 0x75, 0xa4, 0xc0, 0x95, 0xb9, 0x18, 0x99, 0xe1,
+	0x67, 0x2a, 0x84, 0xc3, 0x02, 0xdb, 0xb0, 0x1d, 0x9f, 0xcc, 0xaf, 0xf9, 0x06, 0x5d, 0xe4, 0xe9,
+	0xc1, 0x9c, 0x5d, 0xb2, 0x36, 0x85, 0x25, 0xa7, 0x06, 0x1f, 0xbc, 0x07, 0xdb, 0xd1, 0x20, 0x3f,
+	0x6d, 0x57, 0x9b, 0x21, 0x30, 0xab, 0xc8, 0x9d, 0xb9, 0xc1, 0x15, 0xbb, 0xcd, 0x07, 0x9b, 0x50,
+	0xc6, 0xec, 0xb6, 0x82, 0x17, 0xd5, 0x45, 0xb6, 0x43, 0x07, 0x7c, 0x5e, 0xad, 0xd9, 0xbe, 0xad,
+	0x84, 0x98, 0x9c, 0x41, 0x30, 0xb8, 0xcb, 0x53, 0x48, 0x0f, 0x4a, 0x4c, 0xbf, 0xd1, 0xa9, 0xc3,
+	0x46, 0xda, 0x8f, 0x0c, 0xa6, 0xa5, 0x6b, 0xc1, 0xbd, 0x34, 0x88, 0xa8, 0x04, 0xe7, 0x50, 0x1c,
+	0x0f, 0x5b, 0xcc, 0x0a, 0x26, 0x0d, 0x72, 0x10, 0x6b, 0x57, 0xb8, 0x1a, 0x97, 0x38, 0xdc, 0x8a,
+	0xb7, 0xa1, 0xb2, 0x32, 0xf9, 0x77, 0x31, 0xfc, 0x16, 0x20, 0x41, 0x67, 0x7b, 0xe3, 0x09, 0x48,
+	0x28, 0x8b, 0xf6, 0xef, 0x45, 0x90, 0xc7, 0xc3, 0xd6, 0x98, 0x27, 0x69, 0xf4, 0x25, 0x4b, 0x73,
+	0x26, 0x8d, 0xb2, 0xd5, 0x83, 0x04, 0x46, 0x08, 0xf4, 0xf8, 0x3c, 0x3a, 0xf9, 0x1a, 0x73, 0xd8,
+	0xcd, 0x73, 0xe2, 0x5d, 0x90, 0x99, 0x27, 0x51, 0xdf, 0x5c, 0xb9, 0xc1, 0xa1, 0x1a, 0xeb, 0x4e,
+	0x28, 0xdf, 0x8f, 0xd8, 0xb9, 0x1b, 0x17, 0x8f, 0x77, 0xb7, 0x8e, 0x47, 0x16, 0xc4, 0x8e, 0x5b,
+	0xef, 0x78, 0x05, 0xa2, 0xed, 0xfe, 0x0a, 0x80, 0x55, 0x61, 0x89, 0x4d, 0xe4, 0x16, 0x52, 0xf1,
+	0xb5, 0x7c, 0x05, 0x60, 0xe3, 0xd7, 0x21, 0x66, 0xed, 0x0e, 0x98, 0xef, 0x43, 0xc9, 0x73, 0x4c,
+	0xd6, 0x02, 0x16, 0xd3, 0xc7, 0xd3, 0xe3, 0x61, 0x4b, 0xfb, 0x0e, 0x94, 0x0d, 0x05, 0x40, 0xd8,
+	0x9b, 0x28, 0x5b, 0xa8, 0x01, 0xb5, 0xef, 0xbb, 0xd3, 0x97, 0x9d, 0x71, 0xeb, 0x7b, 0x45, 0x42,
+	0x4d, 0x90, 0x47, 0xba, 0x3e, 0x36, 0x3a, 0xc3, 0xef, 0x07, 0x4a, 0x01, 0x6d, 0x03, 0x8c, 0xf5,
+	0xf3, 0x56, 0xaf, 0xcb, 0x81, 0x8b, 0x5a, 0x1b, 0x94, 0x0d, 0xee, 0x35, 0x28, 0x0d, 0x86, 0x03,
+	0x46, 0xaa, 0x09, 0xf2, 0x60, 0x38, 0x35, 0x5e, 0x0c, 0xcf, 0x06, 0x1d, 0x45, 0x42, 0x32, 0x94,
+	0x39, 0xaa, 0x52, 0x60, 0xd7, 0x68, 0xdd, 0x81, 0xf8, 0x60, 0x7b, 0x57, 0xf1, 0xdc, 0x9b, 0x67,
+	0xd2, 0x23, 0x40, 0xc1, 0x13, 0x8d, 0x39, 0x57, 0x73, 0x70, 0xee, 0xe2, 0x89, 0xda, 0x8a, 0x0f,
+	0x05, 0xa7, 0x37, 0x9e, 0xcf, 0x13, 0x55, 0x43, 0xfb, 0x06, 0x2a, 0xe2, 0xd0, 0x34, 0xeb, 0x74,
+	0x7c, 0x17, 0x8b, 0xfa, 0x7c, 0x9e, 0x5e, 0x58, 0x95, 0x86, 0x6d, 0x23, 0xba, 0x0c, 0x28, 0x6b,
+	0x0f, 0x60, 0x6f, 0x4c, 0x2e, 0xbe, 0x75, 0xd6, 0x9e, 0x6d, 0x2e, 0xe3, 0x93, 0xdc, 0x26, 0x94,
+	0x29, 0xb1, 0x83, 0x0b, 0x9f, 0x92, 0xf6, 0x77, 0x12, 0xec, 0xc4, 0x60, 0xba, 0xed, 0x7b, 0xd7,
+	0x2c, 0xf5, 0x50, 0xfc, 0x6a, 0x8d, 0x23, 0x28, 0xd4, 0x08, 0x92, 0x73, 0x74, 0xbe, 0x18, 0x78,
+	0x44, 0x31, 0x73, 0xde, 0x28, 0xf2, 0x50, 0x78, 0xf3, 0x56, 0xce, 0xbd, 0x79, 0x4b, 0xb9, 0x62,
+	0x85, 0x07, 0xea, 0xef, 0xa0, 0x3e, 0x26, 0x17, 0x13, 0xdb, 0x74, 0xe9, 0xa5, 0xe3, 0xe7, 0xf0,
+	0x7f, 0xfb, 0x19, 0xea, 0x49, 0x1b, 0x6a, 0x51, 0xf6, 0x07, 0xa8, 0x9c, 0xf6, 0x86, 0xcf, 0x5b,
+	0x3d, 0x65, 0x8b, 0xd9, 0xa7, 0x37, 0x6c, 0xb7, 0x7a, 0x8a, 0xc4, 0x86, 0x5b, 0x9d, 0x6f, 0x8d,
+	0xee, 0x40, 0xd8, 0x8a, 0xfd, 0x1e, 0x9e, 0x4d, 0x95, 0x22, 0xbb, 0xab, 0x39, 0x1f, 0xbf, 0x50,
+	0x4a, 0x27, 0x7f, 0x03, 0x72, 0x7c, 0x8e, 0x5d, 0x85, 0x62, 0xab, 0xd3, 0x51, 0xb6, 0xd8, 0x8f,
+	0x8e, 0xce, 0x08, 0xd4, 0xa1, 0xda, 0xd1, 0x7b, 0x46, 0xab, 0xd7, 0x13, 0x14, 0xc6, 0xfa, 0xa8,
+	0xd7, 0x6a, 0xeb, 0x0a, 0xcb, 0xcb, 0x15, 0x7d, 0xd0, 0x7a, 0xde, 0xd3, 0x95, 0x12, 0x87, 0xea,
+	0x4e, 0xf8, 0x47, 0x99, 0xb1, 0x1f, 0xeb, 0x13, 0x7d, 0xaa, 0x54, 0x98, 0xe3, 0x4c, 0x86, 0x2f,
+	0xa6, 0xe2, 0xb3, 0x7a, 0x62, 0x40, 0x3d, 0x59, 0x73, 0x03, 0x54, 0x46, 0x63, 0xfd, 0x45, 0xf7,
+	0x07, 0xe1, 0xad, 0x03, 0xbd, 0x7b, 0xfa, 0xf2, 0xf9, 0x70, 0xac, 0x48, 0x8c, 0xfd, 0xb4, 0x75,
+	0x1a, 0xc8, 0x3c, 0x31, 0x46, 0xad, 0xe9, 0x4b, 0x85, 0xa5, 0x44, 0xb9, 0x3d, 0xec, 0xf7, 0xcf,
+	0x06, 0xdd, 0xe9, 0x8f, 0x0a, 0x6b, 0xff, 0x9a, 0xfa, 0x0f, 0x53, 0x23, 0x1e, 0x2a, 0x9f, 0xfc,
+	0x12, 0xe4, 0xb8, 0xc6, 0x66, 0x8b, 0x19, 0xfc, 0x28, 0x16, 0xd3, 0xea, 0x05, 0xda, 0xe8, 0x0e,
+	0xce, 0xf5, 0xf1, 0x54, 0x29, 0x9c, 0x9c, 0x80, 0xb2, 0x51, 0x4d, 0x57, 0xa0, 0xa0, 0xff, 0x5e,
+	0xd9, 0x62, 0xff, 0x4f, 0x75, 0x45, 0x62, 0xff, 0x7b, 0xba, 0x52, 0x38, 0xf9, 0x1c, 0xea, 0x89,
+	0xfd, 0x30, 0x11, 0x18, 0x4c, 0xbd, 0xed, 0xb6, 0x3e, 0x9a, 0x0a, 0xe2, 0x63, 0xfd, 0x5b, 0xbd,
+	0xcd, 0x88, 0x9f, 0xc1, 0x5e, 0x5e, 0xd1, 0xb8, 0x0b, 0xcd, 0x48, 0x5a, 0x43, 0x28, 0x7a, 0x1f,
+	0x94, 0x78, 0x68, 0xac, 0xf7, 0x87, 0xe7, 0x8c, 0xf1, 0x01, 0xec, 0x26, 0x47, 0x85, 0xca, 0x0b,
+	0x27, 0x8f, 0xa0, 0x99, 0x2e, 0x1e, 0xeb, 0x50, 0xed, 0xeb, 0x1d, 0xa3, 0x3f, 0x64, 0xa4, 0x76,
+	0xa0, 0xce, 0x3e, 0x42, 0x70, 0xe9, 0xe4, 0x33, 0x80, 0x44, 0x15, 0x51, 0x81, 0x42, 0x77, 0x20,
+	0x64, 0xee, 0xf6, 0x47, 0xc3, 0x71, 0x20, 0xb3, 0xfe, 0x03, 0xff, 0x5d, 0x78, 0xfa, 0x1f, 0x7b,
+	0x50, 0x3b, 0x65, 0xfe, 0xd5, 0x72, 0x09, 0xfa, 0x1a, 0x76, 0x4e, 0xb1, 0x9f, 0xbc, 0x14, 0x41,
+	0x89, 0xdd, 0x21, 0x0a, 0xab, 0xc3, 0xcd, 0x8b, 0x8e, 0x2d, 0xf4, 0x92, 0x5f, 0xc7, 0xa5, 0x70,
+	0xb5, 0xd4, 0xcd, 0x62, 0xee, 0x5d, 0xcb, 0xe1, 0x4e, 0xe6, 0x1a, 0x5f, 0xdb, 0x42, 0x7f, 0x0e,
+	0x8d, 0x53, 0xec, 0x87, 0xd7, 0xc2, 0x34, 0x5f, 0x84, 0xec, 0x6d, 0xef, 0xd6, 0x13, 0x09, 0x7d,
+	0x09, 0xf5, 0x04, 0xe2, 0x2d, 0xf1, 0xd0, 0x5f, 0x41, 0x3d, 0x71, 0x0b, 0x8d, 0x3e, 0x4a, 0x09,
+	0xbd, 0x71, 0x39, 0x9d, 0x27, 0xf0, 0x67, 0x50, 0x39, 0xc5, 0xfe, 0x98, 0x5c, 0xa0, 0xc4, 0x24,
+	0xbf, 0xcd, 0x38, 0xcc, 0x0e, 0x68, 0x5b, 0xe8, 0x73, 0x28, 0x8f, 0xd9, 0xee, 0x9d, 0x2f, 0x5f,
+	0x0e, 0xf9, 0x5f, 0x83, 0x3c, 0x71, 0xe6, 0xfe, 0xdd, 0x90, 0x7e, 0x03, 0xf5, 0x08, 0xa9, 0x6b,
+	0xdf, 0x1a, 0xed, 0x19, 0x34, 0x22, 0xb4, 0xe1, 0xfa, 0xf6, 0xec, 0x9e, 0x42, 0x6d, 0x72, 0xb9,
+	0xf6, 0x59, 0x11, 0x77, 0x6b, 0x9c, 0x27, 0x50, 0xd1, 0xf9, 0x69, 0xf1, 0xad, 0x31, 0xbe, 0x80,
+	0x6a, 0x87, 0xd0, 0x3b, 0xa1, 0x3c, 0x87, 0x6a, 0xf0, 0x96, 0x01, 0x1d, 0xa6, 0x2c, 0x9b, 0x7a,
+	0x9b, 0x71, 0x78, 0x7f, 0x63, 0x2e, 0x7c, 0x1b, 0xa1, 0x6d, 0xa1, 0x6f, 0xa0, 0x16, 0x0c, 0x52,
+	0xf4, 0xfe, 0x06, 0x20, 0x7d, 0x1b, 0xff, 0x63, 0x89, 0x15, 0x02, 0xc1, 0x9d, 0x79, 0xae, 0x87,
+	0xe4, 0xdf, 0x4c, 0x71, 0x7f, 0xee, 0x00, 0x0a, 0x30, 0x9f, 0x63, 0xea, 0xb7, 0x2f, 0x4d, 0x7b,
+	0x81, 0xad, 0xfc, 0x95, 0xbf, 0x85, 0xca, 0x6f, 0xa3, 0x3b, 0xfb, 0xf8, 0x74, 0xfc, 0xd6, 0x21,
+	0xd5, 0x81, 0xfd, 0x00, 0x79, 0x3c, 0x6c, 0xc5, 0xb5, 0x42, 0x3e, 0x81, 0xbd, 0x9c, 0xf2, 0x86,
+	0x53, 0xf9, 0x9a, 0x07, 0x48, 0xdf, 0xf3, 0x51, 0xe2, 0xc0, 0x20, 0xf9, 0x4e, 0xe2, 0x70, 0x3f,
+	0x35, 0x1e, 0x1e, 0xcb, 0x32, 0xdc, 0x67, 0x50, 0x11, 0x8f, 0x2a, 0x50, 0xda, 0x46, 0x29, 0xf4,
+	0x5c, 0x4f, 0xae, 0x88, 0xe7, 0x0c, 0xe8, 0xfe, 0x8d, 0x0f, 0x1c, 0xf2, 0x3d, 0xb9, 0xca, 0x82,
+	0x99, 0x95, 0xd2, 0xef, 0xd2, 0x12, 0x03, 0xe2, 0x32, 0x7e, 0xc5, 0x9d, 0x8c, 0xe3, 0xa4, 0x9d,
+	0x2c, 0xf5, 0xf0, 0xe1, 0x86, 0x18, 0x60, 0xdc, 0x86, 0xad, 0x7c, 0x66, 0x99, 0xe2, 0x8f, 0xf1,
+	0xfa, 0x82, 0xcb, 0x77, 0xee, 0xcd, 0xe9, 0x3b, 0x51, 0xd8, 0x73, 0x86, 0x58, 0x85, 0xac, 0x48,
+	0x4b, 0xab, 0x22, 0xf9, 0x76, 0x22, 0x4f, 0xb8, 0xbf, 0x80, 0xe6, 0x29, 0xf6, 0x13, 0x67, 0x6a,
+	0xb9, 0x8f, 0x19, 0x0e, 0x73, 0x47, 0x79, 0x62, 0xdd, 0x4e, 0xa1, 0xd3, 0xbb, 0xe1, 0x73, 0xef,
+	0x6b, 0xa6, 0xde, 0x62, 0xa0, 0xa3, 0x94, 0xfc, 0x39, 0x8f, 0x34, 0xf2, 0x96, 0xf1, 0x35, 0xdf,
+	0x4f, 0xe2, 0xe3, 0x82, 0xbc, 0x36, 0xfd, 0x30, 0x6f, 0x30, 0x52, 0x41, 0x34, 0x42, 0xef, 0x82,
+	0xfc, 0x44, 0x42, 0xcf, 0xa1, 0x91, 0x7c, 0xe7, 0x81, 0x3e, 0x4e, 0xc9, 0xbf, 0xf9, 0xfe, 0x23,
+	0x3f, 0xe9, 0xc9, 0xa7, 0xd8, 0x0f, 0x9a, 0xf7, 0x8d, 0x76, 0xfe, 0x70, 0x63, 0x44, 0x24, 0xff,
+	0x10, 0x85, 0x60, 0x7a, 0x3b, 0xa4, 0x27, 0x12, 0xfa, 0x06, 0xe4, 0xe8, 0x2d, 0x06, 0xfa, 0x20,
+	0x9d, 0xe8, 0xd2, 0x0f, 0x48, 0xf2, 0xe4, 0x1c, 0xc2, 0x5e, 0x24, 0x67, 0xe2, 0xa8, 0xe3, 0x2d,
+	0xd7, 0xab, 0x87, 0x6f, 0x99, 0xd3, 0xb6, 0xd0, 0x08, 0xf6, 0x72, 0x9e, 0x86, 0xa0, 0x87, 0x79,
+	0x82, 0x6d, 0xbe, 0x1c, 0xc9, 0x13, 0xf1, 0x14, 0x76, 0xc3, 0x6c, 0x16, 0x75, 0x01, 0xf9, 0x51,
+	0x94, 0x08, 0x94, 0x4c, 0xc3, 0xc0, 0x35, 0xd5, 0xe7, 0x6e, 0x91, 0x20, 0xf2, 0x61, 0x1e, 0xfc,
+	0xad, 0xc9, 0xfd, 0x8e, 0x47, 0x4a, 0xb2, 0x2b, 0x78, 0x57, 0x92, 0x4f, 0xc0, 0x6a, 0x5b, 0x17,
+	0x15, 0xfe, 0x66, 0xf2, 0xd7, 0xff, 0x3b, 0x00, 0x35, 0x6c, 0x16, 0x97, 0x42, 0x29, 0x00, 0x00,
 }
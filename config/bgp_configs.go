@@ -789,6 +789,285 @@ func (v BmpRouteMonitoringPolicyType) Validate() error {
 	return nil
 }
 
+// typedef for identity gobgp:route-source-type
+type RouteSourceType string
+
+const (
+	ROUTE_SOURCE_TYPE_NONE  RouteSourceType = "none"
+	ROUTE_SOURCE_TYPE_BGP   RouteSourceType = "bgp"
+	ROUTE_SOURCE_TYPE_ZEBRA RouteSourceType = "zebra"
+)
+
+var RouteSourceTypeToIntMap = map[RouteSourceType]int{
+	ROUTE_SOURCE_TYPE_NONE:  0,
+	ROUTE_SOURCE_TYPE_BGP:   1,
+	ROUTE_SOURCE_TYPE_ZEBRA: 2,
+}
+
+func (v RouteSourceType) ToInt() int {
+	i, ok := RouteSourceTypeToIntMap[v]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+var IntToRouteSourceTypeMap = map[int]RouteSourceType{
+	0: ROUTE_SOURCE_TYPE_NONE,
+	1: ROUTE_SOURCE_TYPE_BGP,
+	2: ROUTE_SOURCE_TYPE_ZEBRA,
+}
+
+func (v RouteSourceType) Validate() error {
+	if _, ok := RouteSourceTypeToIntMap[v]; !ok {
+		return fmt.Errorf("invalid RouteSourceType: %s", v)
+	}
+	return nil
+}
+
+// typedef for identity gobgp:unusable-nexthop-action-type
+type UnusableNexthopActionType string
+
+const (
+	UNUSABLE_NEXTHOP_ACTION_TYPE_NONE   UnusableNexthopActionType = "none"
+	UNUSABLE_NEXTHOP_ACTION_TYPE_SELF   UnusableNexthopActionType = "self"
+	UNUSABLE_NEXTHOP_ACTION_TYPE_REJECT UnusableNexthopActionType = "reject"
+)
+
+var UnusableNexthopActionTypeToIntMap = map[UnusableNexthopActionType]int{
+	UNUSABLE_NEXTHOP_ACTION_TYPE_NONE:   0,
+	UNUSABLE_NEXTHOP_ACTION_TYPE_SELF:   1,
+	UNUSABLE_NEXTHOP_ACTION_TYPE_REJECT: 2,
+}
+
+func (v UnusableNexthopActionType) ToInt() int {
+	i, ok := UnusableNexthopActionTypeToIntMap[v]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+var IntToUnusableNexthopActionTypeMap = map[int]UnusableNexthopActionType{
+	0: UNUSABLE_NEXTHOP_ACTION_TYPE_NONE,
+	1: UNUSABLE_NEXTHOP_ACTION_TYPE_SELF,
+	2: UNUSABLE_NEXTHOP_ACTION_TYPE_REJECT,
+}
+
+func (v UnusableNexthopActionType) Validate() error {
+	if _, ok := UnusableNexthopActionTypeToIntMap[v]; !ok {
+		return fmt.Errorf("invalid UnusableNexthopActionType: %s", v)
+	}
+	return nil
+}
+
+// typedef for identity gobgp:ibgp-med-action-type
+type IbgpMedActionType string
+
+const (
+	IBGP_MED_ACTION_TYPE_PRESERVE IbgpMedActionType = "preserve"
+	IBGP_MED_ACTION_TYPE_ZERO     IbgpMedActionType = "zero"
+	IBGP_MED_ACTION_TYPE_IGP      IbgpMedActionType = "igp"
+)
+
+var IbgpMedActionTypeToIntMap = map[IbgpMedActionType]int{
+	IBGP_MED_ACTION_TYPE_PRESERVE: 0,
+	IBGP_MED_ACTION_TYPE_ZERO:     1,
+	IBGP_MED_ACTION_TYPE_IGP:      2,
+}
+
+func (v IbgpMedActionType) ToInt() int {
+	i, ok := IbgpMedActionTypeToIntMap[v]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+var IntToIbgpMedActionTypeMap = map[int]IbgpMedActionType{
+	0: IBGP_MED_ACTION_TYPE_PRESERVE,
+	1: IBGP_MED_ACTION_TYPE_ZERO,
+	2: IBGP_MED_ACTION_TYPE_IGP,
+}
+
+func (v IbgpMedActionType) Validate() error {
+	if _, ok := IbgpMedActionTypeToIntMap[v]; !ok {
+		return fmt.Errorf("invalid IbgpMedActionType: %s", v)
+	}
+	return nil
+}
+
+// typedef for identity gobgp:orf-mode-type
+type OrfModeType string
+
+const (
+	ORF_MODE_TYPE_NONE    OrfModeType = "none"
+	ORF_MODE_TYPE_SEND    OrfModeType = "send"
+	ORF_MODE_TYPE_RECEIVE OrfModeType = "receive"
+	ORF_MODE_TYPE_BOTH    OrfModeType = "both"
+)
+
+var OrfModeTypeToIntMap = map[OrfModeType]int{
+	ORF_MODE_TYPE_NONE:    0,
+	ORF_MODE_TYPE_SEND:    1,
+	ORF_MODE_TYPE_RECEIVE: 2,
+	ORF_MODE_TYPE_BOTH:    3,
+}
+
+func (v OrfModeType) ToInt() int {
+	i, ok := OrfModeTypeToIntMap[v]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+var IntToOrfModeTypeMap = map[int]OrfModeType{
+	0: ORF_MODE_TYPE_NONE,
+	1: ORF_MODE_TYPE_SEND,
+	2: ORF_MODE_TYPE_RECEIVE,
+	3: ORF_MODE_TYPE_BOTH,
+}
+
+func (v OrfModeType) Validate() error {
+	if _, ok := OrfModeTypeToIntMap[v]; !ok {
+		return fmt.Errorf("invalid OrfModeType: %s", v)
+	}
+	return nil
+}
+
+// typedef for identity gobgp:community-limit-action-type
+type CommunityLimitActionType string
+
+const (
+	COMMUNITY_LIMIT_ACTION_TYPE_WITHDRAW CommunityLimitActionType = "withdraw"
+	COMMUNITY_LIMIT_ACTION_TYPE_TRUNCATE CommunityLimitActionType = "truncate"
+)
+
+var CommunityLimitActionTypeToIntMap = map[CommunityLimitActionType]int{
+	COMMUNITY_LIMIT_ACTION_TYPE_WITHDRAW: 0,
+	COMMUNITY_LIMIT_ACTION_TYPE_TRUNCATE: 1,
+}
+
+func (v CommunityLimitActionType) ToInt() int {
+	i, ok := CommunityLimitActionTypeToIntMap[v]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+var IntToCommunityLimitActionTypeMap = map[int]CommunityLimitActionType{
+	0: COMMUNITY_LIMIT_ACTION_TYPE_WITHDRAW,
+	1: COMMUNITY_LIMIT_ACTION_TYPE_TRUNCATE,
+}
+
+func (v CommunityLimitActionType) Validate() error {
+	if _, ok := CommunityLimitActionTypeToIntMap[v]; !ok {
+		return fmt.Errorf("invalid CommunityLimitActionType: %s", v)
+	}
+	return nil
+}
+
+// typedef for identity gobgp:orphan-as4-path-action-type
+type OrphanAs4PathActionType string
+
+const (
+	ORPHAN_AS4_PATH_ACTION_TYPE_DISCARD           OrphanAs4PathActionType = "discard"
+	ORPHAN_AS4_PATH_ACTION_TYPE_TREAT_AS_WITHDRAW OrphanAs4PathActionType = "treat-as-withdraw"
+)
+
+var OrphanAs4PathActionTypeToIntMap = map[OrphanAs4PathActionType]int{
+	ORPHAN_AS4_PATH_ACTION_TYPE_DISCARD:           0,
+	ORPHAN_AS4_PATH_ACTION_TYPE_TREAT_AS_WITHDRAW: 1,
+}
+
+func (v OrphanAs4PathActionType) ToInt() int {
+	i, ok := OrphanAs4PathActionTypeToIntMap[v]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+var IntToOrphanAs4PathActionTypeMap = map[int]OrphanAs4PathActionType{
+	0: ORPHAN_AS4_PATH_ACTION_TYPE_DISCARD,
+	1: ORPHAN_AS4_PATH_ACTION_TYPE_TREAT_AS_WITHDRAW,
+}
+
+func (v OrphanAs4PathActionType) Validate() error {
+	if _, ok := OrphanAs4PathActionTypeToIntMap[v]; !ok {
+		return fmt.Errorf("invalid OrphanAs4PathActionType: %s", v)
+	}
+	return nil
+}
+
+// typedef for identity gobgp:min-hold-time-action-type
+type MinHoldTimeActionType string
+
+const (
+	MIN_HOLD_TIME_ACTION_TYPE_REJECT MinHoldTimeActionType = "reject"
+	MIN_HOLD_TIME_ACTION_TYPE_CLAMP  MinHoldTimeActionType = "clamp"
+)
+
+var MinHoldTimeActionTypeToIntMap = map[MinHoldTimeActionType]int{
+	MIN_HOLD_TIME_ACTION_TYPE_REJECT: 0,
+	MIN_HOLD_TIME_ACTION_TYPE_CLAMP:  1,
+}
+
+func (v MinHoldTimeActionType) ToInt() int {
+	i, ok := MinHoldTimeActionTypeToIntMap[v]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+var IntToMinHoldTimeActionTypeMap = map[int]MinHoldTimeActionType{
+	0: MIN_HOLD_TIME_ACTION_TYPE_REJECT,
+	1: MIN_HOLD_TIME_ACTION_TYPE_CLAMP,
+}
+
+func (v MinHoldTimeActionType) Validate() error {
+	if _, ok := MinHoldTimeActionTypeToIntMap[v]; !ok {
+		return fmt.Errorf("invalid MinHoldTimeActionType: %s", v)
+	}
+	return nil
+}
+
+// typedef for identity gobgp:update-rate-limit-action-type
+type UpdateRateLimitActionType string
+
+const (
+	UPDATE_RATE_LIMIT_ACTION_TYPE_THROTTLE UpdateRateLimitActionType = "throttle"
+	UPDATE_RATE_LIMIT_ACTION_TYPE_CEASE    UpdateRateLimitActionType = "cease"
+)
+
+var UpdateRateLimitActionTypeToIntMap = map[UpdateRateLimitActionType]int{
+	UPDATE_RATE_LIMIT_ACTION_TYPE_THROTTLE: 0,
+	UPDATE_RATE_LIMIT_ACTION_TYPE_CEASE:    1,
+}
+
+func (v UpdateRateLimitActionType) ToInt() int {
+	i, ok := UpdateRateLimitActionTypeToIntMap[v]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+var IntToUpdateRateLimitActionTypeMap = map[int]UpdateRateLimitActionType{
+	0: UPDATE_RATE_LIMIT_ACTION_TYPE_THROTTLE,
+	1: UPDATE_RATE_LIMIT_ACTION_TYPE_CEASE,
+}
+
+func (v UpdateRateLimitActionType) Validate() error {
+	if _, ok := UpdateRateLimitActionTypeToIntMap[v]; !ok {
+		return fmt.Errorf("invalid UpdateRateLimitActionType: %s", v)
+	}
+	return nil
+}
+
 // typedef for identity gobgp:rpki-validation-result-type
 type RpkiValidationResultType string
 
@@ -1033,6 +1312,8 @@ type AddPathsState struct {
 	Receive bool `mapstructure:"receive"`
 	// original -> bgp:send-max
 	SendMax uint8 `mapstructure:"send-max"`
+	// original -> gobgp:paths-limit-exceeded
+	PathsLimitExceeded uint32 `mapstructure:"paths-limit-exceeded"`
 }
 
 //struct for container bgp:config
@@ -1042,6 +1323,8 @@ type AddPathsConfig struct {
 	Receive bool `mapstructure:"receive"`
 	// original -> bgp:send-max
 	SendMax uint8 `mapstructure:"send-max"`
+	// original -> gobgp:paths-limit
+	PathsLimit uint16 `mapstructure:"paths-limit"`
 }
 
 //struct for container bgp:add-paths
@@ -1094,6 +1377,9 @@ type RouteReflectorConfig struct {
 	// original -> bgp:route-reflector-client
 	//bgp:route-reflector-client's original type is boolean
 	RouteReflectorClient bool `mapstructure:"route-reflector-client"`
+	// original -> gobgp:transparent-reflection
+	//gobgp:transparent-reflection's original type is boolean
+	TransparentReflection bool `mapstructure:"transparent-reflection"`
 }
 
 //struct for container bgp:route-reflector
@@ -1120,6 +1406,9 @@ type EbgpMultihopConfig struct {
 	Enabled bool `mapstructure:"enabled"`
 	// original -> bgp:multihop-ttl
 	MultihopTtl uint8 `mapstructure:"multihop-ttl"`
+	// original -> gobgp:rewrite-unresolvable-nexthop
+	//gobgp:rewrite-unresolvable-nexthop's original type is boolean
+	RewriteUnresolvableNexthop bool `mapstructure:"rewrite-unresolvable-nexthop"`
 }
 
 //struct for container bgp:ebgp-multihop
@@ -1213,6 +1502,9 @@ type TransportConfig struct {
 	// original -> bgp:local-address
 	//bgp:local-address's original type is union
 	LocalAddress string `mapstructure:"local-address"`
+	// original -> gobgp:local-port
+	//gobgp:local-port's original type is inet:port-number
+	LocalPort uint16 `mapstructure:"local-port"`
 }
 
 //struct for container bgp:transport
@@ -1270,6 +1562,23 @@ type TimersConfig struct {
 	// original -> gobgp:idle-hold-time-after-reset
 	//gobgp:idle-hold-time-after-reset's original type is decimal64
 	IdleHoldTimeAfterReset float64 `mapstructure:"idle-hold-time-after-reset"`
+	// original -> gobgp:connect-timeout
+	//gobgp:connect-timeout's original type is decimal64
+	ConnectTimeout float64 `mapstructure:"connect-timeout"`
+	// original -> gobgp:min-hold-time
+	//gobgp:min-hold-time's original type is decimal64
+	MinHoldTime float64 `mapstructure:"min-hold-time"`
+	// original -> gobgp:min-hold-time-action
+	MinHoldTimeAction MinHoldTimeActionType `mapstructure:"min-hold-time-action"`
+	// original -> gobgp:hold-timer-reset-interval
+	//gobgp:hold-timer-reset-interval's original type is decimal64
+	HoldTimerResetInterval float64 `mapstructure:"hold-timer-reset-interval"`
+	// original -> gobgp:derive-keepalive-interval
+	//gobgp:derive-keepalive-interval's original type is boolean
+	DeriveKeepaliveInterval bool `mapstructure:"derive-keepalive-interval"`
+	// original -> gobgp:min-notification-interval
+	//gobgp:min-notification-interval's original type is decimal64
+	MinNotificationInterval float64 `mapstructure:"min-notification-interval"`
 }
 
 //struct for container bgp:timers
@@ -1377,6 +1686,11 @@ type NeighborState struct {
 	EstablishedCount uint32 `mapstructure:"established-count"`
 	// original -> gobgp:flops
 	Flops uint32 `mapstructure:"flops"`
+	// original -> gobgp:originator-id-loops
+	OriginatorIdLoops uint32 `mapstructure:"originator-id-loops"`
+	// original -> gobgp:extended-nexthop
+	//gobgp:extended-nexthop's original type is boolean
+	ExtendedNexthop bool `mapstructure:"extended-nexthop"`
 }
 
 //struct for container bgp:config
@@ -1405,6 +1719,84 @@ type NeighborConfig struct {
 	// original -> bgp:neighbor-address
 	//bgp:neighbor-address's original type is inet:ip-address
 	NeighborAddress string `mapstructure:"neighbor-address"`
+	// original -> gobgp:honor-ebgp-local-pref
+	//gobgp:honor-ebgp-local-pref's original type is boolean
+	HonorEbgpLocalPref bool `mapstructure:"honor-ebgp-local-pref"`
+	// original -> gobgp:accept-missing-origin
+	//gobgp:accept-missing-origin's original type is boolean
+	AcceptMissingOrigin bool `mapstructure:"accept-missing-origin"`
+	// original -> gobgp:sort-ext-communities
+	//gobgp:sort-ext-communities's original type is boolean
+	SortExtCommunities bool `mapstructure:"sort-ext-communities"`
+	// original -> gobgp:strict-well-known-communities
+	//gobgp:strict-well-known-communities's original type is boolean
+	StrictWellKnownCommunities bool `mapstructure:"strict-well-known-communities"`
+	// original -> gobgp:clear-communities
+	ClearCommunities CommunityType `mapstructure:"clear-communities"`
+	// original -> gobgp:required-community
+	RequiredCommunityList []string `mapstructure:"required-community-list"`
+	// original -> gobgp:rpki-valid-community
+	RpkiValidCommunity string `mapstructure:"rpki-valid-community"`
+	// original -> gobgp:rpki-invalid-community
+	RpkiInvalidCommunity string `mapstructure:"rpki-invalid-community"`
+	// original -> gobgp:rpki-not-found-community
+	RpkiNotFoundCommunity string `mapstructure:"rpki-not-found-community"`
+	// original -> gobgp:unusable-nexthop-action
+	UnusableNexthopAction UnusableNexthopActionType `mapstructure:"unusable-nexthop-action"`
+	// original -> gobgp:ibgp-med-action
+	IbgpMedAction IbgpMedActionType `mapstructure:"ibgp-med-action"`
+	// original -> gobgp:attribute-transparency
+	//gobgp:attribute-transparency's original type is boolean
+	AttributeTransparency bool `mapstructure:"attribute-transparency"`
+	// original -> gobgp:max-as-path-length
+	MaxAsPathLength uint32 `mapstructure:"max-as-path-length"`
+	// original -> gobgp:max-communities
+	MaxCommunities uint32 `mapstructure:"max-communities"`
+	// original -> gobgp:max-ext-communities
+	MaxExtCommunities uint32 `mapstructure:"max-ext-communities"`
+	// original -> gobgp:community-limit-action
+	CommunityLimitAction CommunityLimitActionType `mapstructure:"community-limit-action"`
+	// original -> gobgp:orphan-as4-path-action
+	OrphanAs4PathAction OrphanAs4PathActionType `mapstructure:"orphan-as4-path-action"`
+	// original -> gobgp:withdraw-hold-time
+	WithdrawHoldTime uint32 `mapstructure:"withdraw-hold-time"`
+	// original -> gobgp:update-batch-window
+	UpdateBatchWindow uint32 `mapstructure:"update-batch-window"`
+	// original -> gobgp:advertise-to-source
+	//gobgp:advertise-to-source's original type is boolean
+	AdvertiseToSource bool `mapstructure:"advertise-to-source"`
+	// original -> gobgp:reject-as-trans
+	//gobgp:reject-as-trans's original type is boolean
+	RejectAsTrans bool `mapstructure:"reject-as-trans"`
+	// original -> gobgp:prefix-orf
+	PrefixOrf OrfModeType `mapstructure:"prefix-orf"`
+	// original -> gobgp:prefix-orf-set
+	PrefixOrfSet string `mapstructure:"prefix-orf-set"`
+	// original -> gobgp:local-router-id
+	//gobgp:local-router-id's original type is inet:ipv4-address
+	LocalRouterId string `mapstructure:"local-router-id"`
+	// original -> gobgp:max-med
+	MaxMed uint32 `mapstructure:"max-med"`
+	// original -> gobgp:collapse-as-path-prepends
+	//gobgp:collapse-as-path-prepends's original type is boolean
+	CollapseAsPathPrepends bool `mapstructure:"collapse-as-path-prepends"`
+	// original -> gobgp:extended-nexthop
+	//gobgp:extended-nexthop's original type is boolean
+	ExtendedNexthop bool `mapstructure:"extended-nexthop"`
+	// original -> gobgp:update-rate-limit
+	UpdateRateLimit uint32 `mapstructure:"update-rate-limit"`
+	// original -> gobgp:update-rate-burst
+	UpdateRateBurst uint32 `mapstructure:"update-rate-burst"`
+	// original -> gobgp:update-rate-limit-action
+	UpdateRateLimitAction UpdateRateLimitActionType `mapstructure:"update-rate-limit-action"`
+	// original -> gobgp:strip-attribute-on-egress
+	StripAttributeOnEgressList []uint8 `mapstructure:"strip-attribute-on-egress-list"`
+	// original -> gobgp:debug-messages
+	//gobgp:debug-messages's original type is boolean
+	DebugMessages bool `mapstructure:"debug-messages"`
+	// original -> gobgp:debug-path-attributes
+	//gobgp:debug-path-attributes's original type is boolean
+	DebugPathAttributes bool `mapstructure:"debug-path-attributes"`
 }
 
 //struct for container bgp:neighbor
@@ -1444,6 +1836,28 @@ type Neighbor struct {
 	RouteServer RouteServer `mapstructure:"route-server"`
 }
 
+//struct for container gobgp:oscillation-detector
+type OscillationDetector struct {
+	// original -> gobgp:enabled
+	//gobgp:enabled's original type is boolean
+	Enabled bool `mapstructure:"enabled"`
+	// original -> gobgp:threshold
+	Threshold uint32 `mapstructure:"threshold"`
+	// original -> gobgp:interval
+	Interval uint32 `mapstructure:"interval"`
+	// original -> gobgp:report-interval
+	ReportInterval uint32 `mapstructure:"report-interval"`
+}
+
+//struct for container gobgp:rib-journal
+type RibJournal struct {
+	// original -> gobgp:enabled
+	//gobgp:enabled's original type is boolean
+	Enabled bool `mapstructure:"enabled"`
+	// original -> gobgp:size
+	Size uint32 `mapstructure:"size"`
+}
+
 //struct for container gobgp:listen-config
 type ListenConfig struct {
 	// original -> gobgp:port
@@ -1469,12 +1883,29 @@ type Zebra struct {
 	Url string `mapstructure:"url"`
 	// original -> gobgp:redistribute-route-type
 	RedistributeRouteTypeList []InstallProtocolType `mapstructure:"redistribute-route-type-list"`
+	// original -> gobgp:nexthop-hold-down-time
+	NexthopHoldDownTime uint32 `mapstructure:"nexthop-hold-down-time"`
 }
 
 //struct for container gobgp:mrt
 type Mrt struct {
 	// original -> gobgp:file-name
 	FileName string `mapstructure:"file-name"`
+	// original -> gobgp:include-sent
+	//gobgp:include-sent's original type is boolean
+	IncludeSent bool `mapstructure:"include-sent"`
+	// original -> gobgp:rotation-interval
+	RotationInterval uint64 `mapstructure:"rotation-interval"`
+	// original -> gobgp:write-index
+	//gobgp:write-index's original type is boolean
+	WriteIndex bool `mapstructure:"write-index"`
+	// original -> gobgp:buffer-size
+	BufferSize uint32 `mapstructure:"buffer-size"`
+	// original -> gobgp:neighbor-address
+	// original type is list of inet:ip-address
+	NeighborAddressList []string `mapstructure:"neighbor-address-list"`
+	// original -> gobgp:afi-safi-name
+	AfiSafiNameList []string `mapstructure:"afi-safi-name-list"`
 }
 
 //struct for container gobgp:state
@@ -1508,6 +1939,9 @@ type Collector struct {
 	// original -> gobgp:enabled
 	//gobgp:enabled's original type is boolean
 	Enabled bool `mapstructure:"enabled"`
+	// original -> gobgp:receive-only
+	//gobgp:receive-only's original type is boolean
+	ReceiveOnly bool `mapstructure:"receive-only"`
 }
 
 //struct for container bgp-mp:l2vpn-evpn
@@ -1570,6 +2004,12 @@ type Ipv6UnicastConfig struct {
 	// original -> bgp-mp:send-default-route
 	//bgp-mp:send-default-route's original type is boolean
 	SendDefaultRoute bool `mapstructure:"send-default-route"`
+	// original -> gobgp:send-default-route-condition
+	SendDefaultRouteCondition string `mapstructure:"send-default-route-condition"`
+	// original -> gobgp:send-default-route-med
+	SendDefaultRouteMed uint32 `mapstructure:"send-default-route-med"`
+	// original -> gobgp:send-default-route-community
+	SendDefaultRouteCommunityList []string `mapstructure:"send-default-route-community-list"`
 }
 
 //struct for container bgp-mp:ipv6-unicast
@@ -1594,6 +2034,12 @@ type Ipv4UnicastConfig struct {
 	// original -> bgp-mp:send-default-route
 	//bgp-mp:send-default-route's original type is boolean
 	SendDefaultRoute bool `mapstructure:"send-default-route"`
+	// original -> gobgp:send-default-route-condition
+	SendDefaultRouteCondition string `mapstructure:"send-default-route-condition"`
+	// original -> gobgp:send-default-route-med
+	SendDefaultRouteMed uint32 `mapstructure:"send-default-route-med"`
+	// original -> gobgp:send-default-route-community
+	SendDefaultRouteCommunityList []string `mapstructure:"send-default-route-community-list"`
 }
 
 //struct for container bgp-mp:state
@@ -1687,6 +2133,10 @@ type AfiSafiState struct {
 	TotalPaths uint32 `mapstructure:"total-paths"`
 	// original -> bgp-op:total-prefixes
 	TotalPrefixes uint32 `mapstructure:"total-prefixes"`
+	// original -> gobgp:paths-limit
+	PathsLimit uint16 `mapstructure:"paths-limit"`
+	// original -> gobgp:peer-paths-limit
+	PeerPathsLimit uint16 `mapstructure:"peer-paths-limit"`
 }
 
 //struct for container bgp-mp:config
@@ -1709,6 +2159,9 @@ type MpGracefulRestartState struct {
 	// original -> bgp-op:advertised
 	//bgp-op:advertised's original type is boolean
 	Advertised bool `mapstructure:"advertised"`
+	// original -> gobgp:peer-forwarding-state-preserved
+	//gobgp:peer-forwarding-state-preserved's original type is boolean
+	PeerForwardingStatePreserved bool `mapstructure:"peer-forwarding-state-preserved"`
 }
 
 //struct for container bgp-mp:config
@@ -1716,6 +2169,9 @@ type MpGracefulRestartConfig struct {
 	// original -> bgp-mp:enabled
 	//bgp-mp:enabled's original type is boolean
 	Enabled bool `mapstructure:"enabled"`
+	// original -> gobgp:forwarding-state-preserved
+	//gobgp:forwarding-state-preserved's original type is boolean
+	ForwardingStatePreserved bool `mapstructure:"forwarding-state-preserved"`
 }
 
 //struct for container bgp-mp:graceful-restart
@@ -1802,6 +2258,11 @@ type GracefulRestartConfig struct {
 	// original -> bgp:helper-only
 	//bgp:helper-only's original type is boolean
 	HelperOnly bool `mapstructure:"helper-only"`
+	// original -> gobgp:notification-enabled
+	//gobgp:notification-enabled's original type is boolean
+	NotificationEnabled bool `mapstructure:"notification-enabled"`
+	// original -> gobgp:deferral-time
+	DeferralTime uint16 `mapstructure:"deferral-time"`
 }
 
 //struct for container bgp:graceful-restart
@@ -2006,6 +2467,12 @@ type GlobalState struct {
 	TotalPaths uint32 `mapstructure:"total-paths"`
 	// original -> bgp-op:total-prefixes
 	TotalPrefixes uint32 `mapstructure:"total-prefixes"`
+	// original -> gobgp:establishing-peers
+	EstablishingPeers uint32 `mapstructure:"establishing-peers"`
+	// original -> gobgp:queued-peers
+	QueuedPeers uint32 `mapstructure:"queued-peers"`
+	// original -> gobgp:pending-peers
+	PendingPeers uint32 `mapstructure:"pending-peers"`
 }
 
 //struct for container bgp:config
@@ -2016,6 +2483,22 @@ type GlobalConfig struct {
 	// original -> bgp:router-id
 	//bgp:router-id's original type is inet:ipv4-address
 	RouterId string `mapstructure:"router-id"`
+	// original -> gobgp:suppress-duplicate-updates
+	//gobgp:suppress-duplicate-updates's original type is boolean
+	SuppressDuplicateUpdates bool `mapstructure:"suppress-duplicate-updates"`
+	// original -> gobgp:max-establishing-peers
+	MaxEstablishingPeers uint32 `mapstructure:"max-establishing-peers"`
+	// original -> gobgp:fsm-reap-time
+	FsmReapTime uint32 `mapstructure:"fsm-reap-time"`
+	// original -> gobgp:max-peers
+	MaxPeers uint32 `mapstructure:"max-peers"`
+	// original -> gobgp:peer-startup-batch-size
+	PeerStartupBatchSize uint32 `mapstructure:"peer-startup-batch-size"`
+	// original -> gobgp:peer-startup-interval
+	//gobgp:peer-startup-interval's original type is decimal64
+	PeerStartupInterval float64 `mapstructure:"peer-startup-interval"`
+	// original -> gobgp:max-path-parent-depth
+	MaxPathParentDepth uint32 `mapstructure:"max-path-parent-depth"`
 }
 
 //struct for container bgp:global
@@ -2048,6 +2531,10 @@ type Global struct {
 	Zebra Zebra `mapstructure:"zebra"`
 	// original -> gobgp:mpls-label-range
 	MplsLabelRange MplsLabelRange `mapstructure:"mpls-label-range"`
+	// original -> gobgp:oscillation-detector
+	OscillationDetector OscillationDetector `mapstructure:"oscillation-detector"`
+	// original -> gobgp:rib-journal
+	RibJournal RibJournal `mapstructure:"rib-journal"`
 	// original -> gobgp:listen-config
 	ListenConfig ListenConfig `mapstructure:"listen-config"`
 }
@@ -2220,6 +2707,8 @@ type BgpConditions struct {
 	RouteType RouteType `mapstructure:"route-type"`
 	// original -> gobgp:rpki-validation-result
 	RpkiValidationResult RpkiValidationResultType `mapstructure:"rpki-validation-result"`
+	// original -> gobgp:match-source
+	MatchSource RouteSourceType `mapstructure:"match-source"`
 }
 
 //struct for container rpol:igp-conditions
@@ -13,8 +13,40 @@ const (
 	DEFAULT_CONNECT_RETRY             = 120
 	DEFAULT_MPLS_LABEL_MIN            = 16000
 	DEFAULT_MPLS_LABEL_MAX            = 1048575
+	DEFAULT_OSCILLATION_THRESHOLD     = 3
+	DEFAULT_OSCILLATION_INTERVAL      = 600
+	DEFAULT_OSCILLATION_REPORT        = 3600
+	DEFAULT_MRT_BUFFER_SIZE           = 1024
+	DEFAULT_UPDATE_RATE_BURST_SECONDS = 60
+	DEFAULT_FSM_REAP_TIME             = 120
+	MAX_FSM_REAP_TIME                 = 3600
+	DEFAULT_PEER_STARTUP_INTERVAL     = 1
+	DEFAULT_RIB_JOURNAL_SIZE          = 10000
 )
 
+// yaml is decoded as []interface{}
+// but toml is decoded as []map[string]interface{}.
+// currently, viper can't hide this difference.
+// handle the difference here.
+func extractArray(intf interface{}) ([]interface{}, error) {
+	if intf != nil {
+		list, ok := intf.([]interface{})
+		if ok {
+			return list, nil
+		}
+		l, ok := intf.([]map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid configuration: neither []interface{} nor []map[string]interface{}")
+		}
+		list = make([]interface{}, 0, len(l))
+		for _, m := range l {
+			list = append(list, m)
+		}
+		return list, nil
+	}
+	return nil, nil
+}
+
 func SetDefaultConfigValues(v *viper.Viper, b *Bgp) error {
 	if v == nil {
 		v = viper.New()
@@ -44,13 +76,33 @@ func SetDefaultConfigValues(v *viper.Viper, b *Bgp) error {
 		b.Global.ListenConfig.Port = bgp.BGP_PORT
 	}
 
+	for _, addr := range b.Global.ListenConfig.LocalAddressList {
+		if ParseAddress(addr) == nil {
+			return fmt.Errorf("invalid listen address %q", addr)
+		}
+	}
+
 	for idx, server := range b.Global.BmpServers {
+		if ParseAddress(server.Config.Address) == nil {
+			return fmt.Errorf("invalid address %q of bmp server #%d", server.Config.Address, idx+1)
+		}
 		if server.Config.Port == 0 {
 			server.Config.Port = bgp.BMP_DEFAULT_PORT
 		}
 		b.Global.BmpServers[idx] = server
 	}
 
+	for _, addr := range b.Global.Mrt.NeighborAddressList {
+		if ParseAddress(addr) == nil {
+			return fmt.Errorf("invalid mrt neighbor address %q", addr)
+		}
+	}
+	for _, name := range b.Global.Mrt.AfiSafiNameList {
+		if _, err := bgp.GetRouteFamily(name); err != nil {
+			return fmt.Errorf("invalid mrt address family %q", name)
+		}
+	}
+
 	if !v.IsSet("global.mpls-label-range.min-label") {
 		b.Global.MplsLabelRange.MinLabel = DEFAULT_MPLS_LABEL_MIN
 	}
@@ -59,27 +111,43 @@ func SetDefaultConfigValues(v *viper.Viper, b *Bgp) error {
 		b.Global.MplsLabelRange.MaxLabel = DEFAULT_MPLS_LABEL_MAX
 	}
 
-	// yaml is decoded as []interface{}
-	// but toml is decoded as []map[string]interface{}.
-	// currently, viper can't hide this difference.
-	// handle the difference here.
-	extractArray := func(intf interface{}) ([]interface{}, error) {
-		if intf != nil {
-			list, ok := intf.([]interface{})
-			if ok {
-				return list, nil
-			}
-			l, ok := intf.([]map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("invalid configuration: neither []interface{} nor []map[string]interface{}")
-			}
-			list = make([]interface{}, 0, len(l))
-			for _, m := range l {
-				list = append(list, m)
-			}
-			return list, nil
+	if !v.IsSet("global.oscillation-detector.threshold") {
+		b.Global.OscillationDetector.Threshold = DEFAULT_OSCILLATION_THRESHOLD
+	}
+
+	if !v.IsSet("global.oscillation-detector.interval") {
+		b.Global.OscillationDetector.Interval = DEFAULT_OSCILLATION_INTERVAL
+	}
+
+	if !v.IsSet("global.oscillation-detector.report-interval") {
+		b.Global.OscillationDetector.ReportInterval = DEFAULT_OSCILLATION_REPORT
+	}
+
+	if !v.IsSet("global.rib-journal.size") {
+		b.Global.RibJournal.Size = DEFAULT_RIB_JOURNAL_SIZE
+	} else if b.Global.RibJournal.Size == 0 {
+		return fmt.Errorf("invalid rib-journal size 0, it must be positive")
+	}
+
+	if !v.IsSet("global.config.fsm-reap-time") {
+		b.Global.Config.FsmReapTime = DEFAULT_FSM_REAP_TIME
+	} else if t := b.Global.Config.FsmReapTime; t == 0 || t > MAX_FSM_REAP_TIME {
+		return fmt.Errorf("invalid fsm-reap-time %d, it must be between 1 and %d", t, MAX_FSM_REAP_TIME)
+	}
+
+	if !v.IsSet("global.config.peer-startup-interval") {
+		b.Global.Config.PeerStartupInterval = DEFAULT_PEER_STARTUP_INTERVAL
+	} else if i := b.Global.Config.PeerStartupInterval; i <= 0 {
+		return fmt.Errorf("invalid peer-startup-interval %v, it must be positive", i)
+	}
+
+	if c := b.Global.Confederation.Config; c.Enabled {
+		if c.Identifier == 0 {
+			return fmt.Errorf("confederation identifier isn't configured")
+		}
+		if c.Identifier == b.Global.Config.As {
+			return fmt.Errorf("confederation identifier %d must differ from the local AS", c.Identifier)
 		}
-		return nil, nil
 	}
 
 	list, err := extractArray(v.Get("neighbors"))
@@ -87,6 +155,9 @@ func SetDefaultConfigValues(v *viper.Viper, b *Bgp) error {
 		return err
 	}
 	for idx, n := range b.Neighbors {
+		if ParseAddress(n.Config.NeighborAddress) == nil {
+			return fmt.Errorf("invalid neighbor address %q of neighbor #%d", n.Config.NeighborAddress, idx+1)
+		}
 		vv := viper.New()
 		if len(list) > idx {
 			vv.Set("neighbor", list[idx])
@@ -105,12 +176,10 @@ func SetDefaultConfigValues(v *viper.Viper, b *Bgp) error {
 		}
 
 		if !vv.IsSet("neighbor.afi-safis") {
-			if ip := net.ParseIP(n.Config.NeighborAddress); ip.To4() != nil {
+			if ip := ParseAddress(n.Config.NeighborAddress); ip.To4() != nil {
 				n.AfiSafis = []AfiSafi{defaultAfiSafi(AFI_SAFI_TYPE_IPV4_UNICAST, true)}
-			} else if ip.To16() != nil {
-				n.AfiSafis = []AfiSafi{defaultAfiSafi(AFI_SAFI_TYPE_IPV6_UNICAST, true)}
 			} else {
-				return fmt.Errorf("invalid neighbor address: %s", n.Config.NeighborAddress)
+				n.AfiSafis = []AfiSafi{defaultAfiSafi(AFI_SAFI_TYPE_IPV6_UNICAST, true)}
 			}
 		} else {
 			afs, err := extractArray(vv.Get("neighbor.afi-safis"))
@@ -138,6 +207,99 @@ func SetDefaultConfigValues(v *viper.Viper, b *Bgp) error {
 				n.Config.PeerType = PEER_TYPE_INTERNAL
 			}
 		}
+		if err := validatePeerRelation(&b.Global, &n); err != nil {
+			return err
+		}
+
+		if n.Config.UnusableNexthopAction == "" {
+			n.Config.UnusableNexthopAction = UNUSABLE_NEXTHOP_ACTION_TYPE_NONE
+		} else if err := n.Config.UnusableNexthopAction.Validate(); err != nil {
+			return err
+		}
+		if n.Config.IbgpMedAction == "" {
+			n.Config.IbgpMedAction = IBGP_MED_ACTION_TYPE_PRESERVE
+		} else if err := n.Config.IbgpMedAction.Validate(); err != nil {
+			return err
+		}
+
+		if id := n.Config.LocalRouterId; id != "" {
+			if ip := net.ParseIP(id).To4(); ip == nil || ip.IsUnspecified() {
+				return fmt.Errorf("invalid local-router-id %q of neighbor %s", id, n.Config.NeighborAddress)
+			}
+		}
+
+		if vv.IsSet("neighbor.transport.config.local-port") {
+			if p := vv.GetInt("neighbor.transport.config.local-port"); p < 0 || p > 65535 {
+				return fmt.Errorf("invalid local-port %d of neighbor %s", p, n.Config.NeighborAddress)
+			} else if p > 0 && int32(p) == b.Global.ListenConfig.Port {
+				return fmt.Errorf("local-port %d of neighbor %s is the listen port", p, n.Config.NeighborAddress)
+			}
+		}
+
+		if n.Config.UpdateRateLimit > 0 && n.Config.UpdateRateBurst == 0 {
+			n.Config.UpdateRateBurst = n.Config.UpdateRateLimit * DEFAULT_UPDATE_RATE_BURST_SECONDS
+		}
+		if n.Config.UpdateRateLimitAction == "" {
+			n.Config.UpdateRateLimitAction = UPDATE_RATE_LIMIT_ACTION_TYPE_THROTTLE
+		} else if err := n.Config.UpdateRateLimitAction.Validate(); err != nil {
+			return err
+		}
+
+		if n.Config.ClearCommunities == "" {
+			n.Config.ClearCommunities = COMMUNITY_TYPE_NONE
+		} else if err := n.Config.ClearCommunities.Validate(); err != nil {
+			return err
+		}
+
+		if n.Config.CommunityLimitAction == "" {
+			n.Config.CommunityLimitAction = COMMUNITY_LIMIT_ACTION_TYPE_WITHDRAW
+		} else if err := n.Config.CommunityLimitAction.Validate(); err != nil {
+			return err
+		}
+
+		if n.Config.OrphanAs4PathAction == "" {
+			n.Config.OrphanAs4PathAction = ORPHAN_AS4_PATH_ACTION_TYPE_DISCARD
+		} else if err := n.Config.OrphanAs4PathAction.Validate(); err != nil {
+			return err
+		}
+
+		if n.Timers.Config.MinHoldTimeAction == "" {
+			n.Timers.Config.MinHoldTimeAction = MIN_HOLD_TIME_ACTION_TYPE_REJECT
+		} else if err := n.Timers.Config.MinHoldTimeAction.Validate(); err != nil {
+			return err
+		}
+		if min := n.Timers.Config.MinHoldTime; min < 0 || (min > 0 && min > n.Timers.Config.HoldTime) {
+			return fmt.Errorf("invalid min-hold-time %v of neighbor %s, it must not exceed hold-time %v", min, n.Config.NeighborAddress, n.Timers.Config.HoldTime)
+		}
+		if i := n.Timers.Config.MinNotificationInterval; i < 0 {
+			return fmt.Errorf("invalid min-notification-interval %v of neighbor %s", i, n.Config.NeighborAddress)
+		}
+		if i := n.Timers.Config.HoldTimerResetInterval; i < 0 || (i > 0 && i >= n.Timers.Config.HoldTime) {
+			return fmt.Errorf("invalid hold-timer-reset-interval %v of neighbor %s, it must be less than hold-time %v", i, n.Config.NeighborAddress, n.Timers.Config.HoldTime)
+		}
+
+		for _, typ := range n.Config.StripAttributeOnEgressList {
+			switch bgp.BGPAttrType(typ) {
+			case bgp.BGP_ATTR_TYPE_ORIGIN, bgp.BGP_ATTR_TYPE_AS_PATH, bgp.BGP_ATTR_TYPE_NEXT_HOP, bgp.BGP_ATTR_TYPE_MP_REACH_NLRI, bgp.BGP_ATTR_TYPE_MP_UNREACH_NLRI:
+				return fmt.Errorf("attribute %s can't be stripped on egress to neighbor %s", bgp.BGPAttrType(typ), n.Config.NeighborAddress)
+			}
+		}
+
+		if c := n.GracefulRestart.Config; c.RestartTime > 4095 {
+			return fmt.Errorf("invalid restart-time %d of neighbor %s, it must not exceed 4095", c.RestartTime, n.Config.NeighborAddress)
+		} else if c.StaleRoutesTime < 0 {
+			return fmt.Errorf("invalid stale-routes-time %v of neighbor %s", c.StaleRoutesTime, n.Config.NeighborAddress)
+		}
+
+		if c := n.AddPaths.Config; c.PathsLimit > 0 && !c.Receive {
+			return fmt.Errorf("paths-limit of neighbor %s needs add-paths receive", n.Config.NeighborAddress)
+		}
+
+		if n.Config.PrefixOrf == "" {
+			n.Config.PrefixOrf = ORF_MODE_TYPE_NONE
+		} else if err := n.Config.PrefixOrf.Validate(); err != nil {
+			return err
+		}
 		b.Neighbors[idx] = n
 	}
 
@@ -149,3 +311,39 @@ func SetDefaultConfigValues(v *viper.Viper, b *Bgp) error {
 
 	return nil
 }
+
+// validatePeerRelation rejects the combinations of the peer type, route
+// reflector and confederation settings of the neighbor which make the
+// attribute handling toward it undefined.
+func validatePeerRelation(g *Global, n *Neighbor) error {
+	addr := n.Config.NeighborAddress
+	ibgp := n.Config.PeerAs == g.Config.As
+	switch n.Config.PeerType {
+	case PEER_TYPE_INTERNAL:
+		if !ibgp {
+			return fmt.Errorf("neighbor %s is internal but its peer-as %d differs from the local AS %d", addr, n.Config.PeerAs, g.Config.As)
+		}
+	case PEER_TYPE_EXTERNAL:
+		if ibgp {
+			return fmt.Errorf("neighbor %s is external but its peer-as %d is the local AS", addr, n.Config.PeerAs)
+		}
+	}
+	if n.RouteReflector.Config.RouteReflectorClient {
+		if !ibgp {
+			return fmt.Errorf("neighbor %s can't be a route-reflector-client because it's not an iBGP peer", addr)
+		}
+		if n.RouteServer.Config.RouteServerClient {
+			return fmt.Errorf("neighbor %s can't be both a route-reflector-client and a route-server-client", addr)
+		}
+	} else if n.RouteReflector.Config.TransparentReflection {
+		return fmt.Errorf("neighbor %s can't use transparent-reflection because it's not a route-reflector-client", addr)
+	}
+	if c := g.Confederation.Config; c.Enabled {
+		if n.Config.PeerAs == c.Identifier {
+			return fmt.Errorf("peer-as %d of neighbor %s is the confederation identifier", n.Config.PeerAs, addr)
+		}
+	} else if IsConfederationMember(g, n) {
+		return fmt.Errorf("neighbor %s is in a confederation member AS but confederation isn't enabled", addr)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestBgp() *Bgp {
+	return &Bgp{Global: Global{Config: GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}}
+}
+
+func TestNeighborAddress(t *testing.T) {
+	assert := assert.New(t)
+	set := func(addr string) (*Neighbor, error) {
+		b := newTestBgp()
+		b.Neighbors = []Neighbor{{Config: NeighborConfig{NeighborAddress: addr, PeerAs: 65001}}}
+		err := SetDefaultConfigValues(nil, b)
+		return &b.Neighbors[0], err
+	}
+
+	n, err := set("10.0.0.1")
+	assert.Nil(err)
+	assert.Equal(AFI_SAFI_TYPE_IPV4_UNICAST, n.AfiSafis[0].AfiSafiName)
+
+	n, err = set("2001:db8::1")
+	assert.Nil(err)
+	assert.Equal(AFI_SAFI_TYPE_IPV6_UNICAST, n.AfiSafis[0].AfiSafiName)
+
+	// link-local with the zone
+	n, err = set("fe80::1%eth0")
+	assert.Nil(err)
+	assert.Equal(AFI_SAFI_TYPE_IPV6_UNICAST, n.AfiSafis[0].AfiSafiName)
+
+	for _, addr := range []string{"", "10.0.0", "peer1", "10.0.0.0/24", "10.0.0.1%eth0", "fe80::1%"} {
+		_, err = set(addr)
+		assert.NotNil(err, addr)
+	}
+}
+
+func TestWatcherAddresses(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newTestBgp()
+	b.Global.ListenConfig.LocalAddressList = []string{"10.0.0.254", "fe80::1%eth0"}
+	b.Global.BmpServers = []BmpServer{{Config: BmpServerConfig{Address: "10.0.0.100"}}}
+	b.Global.Mrt = Mrt{NeighborAddressList: []string{"10.0.0.1"}, AfiSafiNameList: []string{"ipv4-unicast"}}
+	assert.Nil(SetDefaultConfigValues(nil, b))
+	assert.Equal(uint32(bgp.BMP_DEFAULT_PORT), b.Global.BmpServers[0].Config.Port)
+
+	b = newTestBgp()
+	b.Global.ListenConfig.LocalAddressList = []string{"10.0.0.254", "localhost"}
+	assert.NotNil(SetDefaultConfigValues(nil, b))
+
+	b = newTestBgp()
+	b.Global.BmpServers = []BmpServer{{Config: BmpServerConfig{Address: "bmp.example.com"}}}
+	assert.NotNil(SetDefaultConfigValues(nil, b))
+
+	b = newTestBgp()
+	b.Global.Mrt.NeighborAddressList = []string{"10.0.0"}
+	assert.NotNil(SetDefaultConfigValues(nil, b))
+
+	b = newTestBgp()
+	b.Global.Mrt.AfiSafiNameList = []string{"ipv4-unicas"}
+	assert.NotNil(SetDefaultConfigValues(nil, b))
+}
+
+func TestPathsLimit(t *testing.T) {
+	assert := assert.New(t)
+	b := newTestBgp()
+	b.Neighbors = []Neighbor{{Config: NeighborConfig{NeighborAddress: "10.0.0.1", PeerAs: 65001}}}
+	b.Neighbors[0].AddPaths.Config.PathsLimit = 4
+	assert.NotNil(SetDefaultConfigValues(nil, b))
+
+	b.Neighbors[0].AddPaths.Config.Receive = true
+	assert.Nil(SetDefaultConfigValues(nil, b))
+}
+
+func TestValidatePeerRelation(t *testing.T) {
+	assert := assert.New(t)
+	g := &newTestBgp().Global
+	neighbor := func(as uint32, peerType PeerType) *Neighbor {
+		return &Neighbor{Config: NeighborConfig{NeighborAddress: "10.0.0.1", PeerAs: as, PeerType: peerType}}
+	}
+
+	assert.Nil(validatePeerRelation(g, neighbor(65000, PEER_TYPE_INTERNAL)))
+	assert.Nil(validatePeerRelation(g, neighbor(65001, PEER_TYPE_EXTERNAL)))
+
+	// the peer type contradicts the peer-as
+	assert.NotNil(validatePeerRelation(g, neighbor(65001, PEER_TYPE_INTERNAL)))
+	assert.NotNil(validatePeerRelation(g, neighbor(65000, PEER_TYPE_EXTERNAL)))
+
+	// route-reflector-client of an eBGP peer
+	n := neighbor(65001, PEER_TYPE_EXTERNAL)
+	n.RouteReflector.Config.RouteReflectorClient = true
+	assert.NotNil(validatePeerRelation(g, n))
+
+	// both route-reflector-client and route-server-client
+	n = neighbor(65000, PEER_TYPE_INTERNAL)
+	n.RouteReflector.Config.RouteReflectorClient = true
+	assert.Nil(validatePeerRelation(g, n))
+	n.RouteServer.Config.RouteServerClient = true
+	assert.NotNil(validatePeerRelation(g, n))
+
+	// transparent-reflection without route-reflector-client
+	n = neighbor(65000, PEER_TYPE_INTERNAL)
+	n.RouteReflector.Config.TransparentReflection = true
+	assert.NotNil(validatePeerRelation(g, n))
+	n.RouteReflector.Config.RouteReflectorClient = true
+	assert.Nil(validatePeerRelation(g, n))
+
+	// confederation member AS without confederation
+	g.Confederation.Config.MemberAsList = []uint32{65010}
+	assert.NotNil(validatePeerRelation(g, neighbor(65010, PEER_TYPE_EXTERNAL)))
+	g.Confederation.Config.Enabled = true
+	g.Confederation.Config.Identifier = 65100
+	assert.Nil(validatePeerRelation(g, neighbor(65010, PEER_TYPE_EXTERNAL)))
+
+	// peer-as is the confederation identifier
+	assert.NotNil(validatePeerRelation(g, neighbor(65100, PEER_TYPE_EXTERNAL)))
+}
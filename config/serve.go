@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/spf13/viper"
+	"path/filepath"
 	"reflect"
 )
 
@@ -11,29 +13,37 @@ type BgpConfigSet struct {
 	Policy RoutingPolicy
 }
 
+// ReadConfigfile reads the config file, with the files it includes, and
+// returns the settings with the default values filled.
+func ReadConfigfile(path, format string) (*BgpConfigSet, error) {
+	b := Bgp{}
+	p := RoutingPolicy{}
+	v := viper.New()
+	m, err := readConfigfile(path, format, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range m {
+		v.Set(key, value)
+	}
+	if err := v.Unmarshal(&b); err != nil {
+		return nil, err
+	}
+	if err := SetDefaultConfigValues(v, &b); err != nil {
+		return nil, err
+	}
+	if err := v.Unmarshal(&p); err != nil {
+		return nil, err
+	}
+	return &BgpConfigSet{Bgp: b, Policy: p}, nil
+}
+
 func ReadConfigfileServe(path, format string, configCh chan BgpConfigSet, reloadCh chan bool) {
 	cnt := 0
 	for {
 		<-reloadCh
 
-		b := Bgp{}
-		p := RoutingPolicy{}
-		v := viper.New()
-		v.SetConfigFile(path)
-		v.SetConfigType(format)
-		err := v.ReadInConfig()
-		if err != nil {
-			goto ERROR
-		}
-		err = v.Unmarshal(&b)
-		if err != nil {
-			goto ERROR
-		}
-		err = SetDefaultConfigValues(v, &b)
-		if err != nil {
-			goto ERROR
-		}
-		err = v.Unmarshal(&p)
+		c, err := ReadConfigfile(path, format)
 		if err != nil {
 			goto ERROR
 		}
@@ -42,7 +52,7 @@ func ReadConfigfileServe(path, format string, configCh chan BgpConfigSet, reload
 			log.Info("finished reading the config file")
 		}
 		cnt++
-		configCh <- BgpConfigSet{Bgp: b, Policy: p}
+		configCh <- *c
 		continue
 
 	ERROR:
@@ -56,6 +66,69 @@ func ReadConfigfileServe(path, format string, configCh chan BgpConfigSet, reload
 	}
 }
 
+// readConfigfile reads the config file and the files listed in its
+// "include" and returns the merged settings. A relative path in "include"
+// is resolved from the directory of the including file. Lists like
+// neighbors are concatenated; other values must not be defined twice.
+func readConfigfile(path, format string, visited map[string]bool) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if visited[abs] {
+		return nil, fmt.Errorf("include cycle detected at %s", path)
+	}
+	visited[abs] = true
+	defer delete(visited, abs)
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType(format)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	m := v.AllSettings()
+	for _, inc := range v.GetStringSlice("include") {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		n, err := readConfigfile(inc, format, visited)
+		if err != nil {
+			return nil, err
+		}
+		if err := mergeConfigMap(m, n, ""); err != nil {
+			return nil, fmt.Errorf("can't include %s: %s", inc, err)
+		}
+	}
+	delete(m, "include")
+	return m, nil
+}
+
+func mergeConfigMap(dst, src map[string]interface{}, prefix string) error {
+	for k, s := range src {
+		d, ok := dst[k]
+		if !ok {
+			dst[k] = s
+			continue
+		}
+		dm, ok1 := d.(map[string]interface{})
+		sm, ok2 := s.(map[string]interface{})
+		if ok1 && ok2 {
+			if err := mergeConfigMap(dm, sm, prefix+k+"."); err != nil {
+				return err
+			}
+			continue
+		}
+		dl, err1 := extractArray(d)
+		sl, err2 := extractArray(s)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("%s%s is defined in multiple files", prefix, k)
+		}
+		dst[k] = append(dl, sl...)
+	}
+	return nil
+}
+
 func inSlice(n Neighbor, b []Neighbor) int {
 	for i, nb := range b {
 		if nb.Config.NeighborAddress == n.Config.NeighborAddress {
@@ -0,0 +1,169 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigfiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "gobgp-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadConfigfileInclude(t *testing.T) {
+	assert := assert.New(t)
+	dir := writeConfigfiles(t, map[string]string{
+		"gobgpd.toml": `include = ["peers/neighbors.toml"]
+[global.config]
+as = 65000
+router-id = "10.0.0.254"
+[[neighbors]]
+[neighbors.config]
+neighbor-address = "10.0.0.1"
+peer-as = 65001
+`,
+		// relative to the directory of the including file
+		"peers/neighbors.toml": `include = ["more.toml"]
+[[neighbors]]
+[neighbors.config]
+neighbor-address = "10.0.0.2"
+peer-as = 65002
+`,
+		"peers/more.toml": `[[neighbors]]
+[neighbors.config]
+neighbor-address = "10.0.0.3"
+peer-as = 65003
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	c, err := ReadConfigfile(filepath.Join(dir, "gobgpd.toml"), "toml")
+	assert.Nil(err)
+	assert.Equal(uint32(65000), c.Bgp.Global.Config.As)
+	addrs := make([]string, 0)
+	for _, n := range c.Bgp.Neighbors {
+		addrs = append(addrs, n.Config.NeighborAddress)
+	}
+	assert.Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, addrs)
+}
+
+func TestReadConfigfileIncludeCycle(t *testing.T) {
+	assert := assert.New(t)
+	dir := writeConfigfiles(t, map[string]string{
+		"gobgpd.toml": `include = ["a.toml"]
+[global.config]
+as = 65000
+router-id = "10.0.0.254"
+`,
+		"a.toml": `include = ["b.toml"]`,
+		"b.toml": `include = ["gobgpd.toml"]`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := ReadConfigfile(filepath.Join(dir, "gobgpd.toml"), "toml")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "include cycle")
+
+	// including the same file twice isn't a cycle
+	dir2 := writeConfigfiles(t, map[string]string{
+		"gobgpd.toml": `include = ["a.toml", "b.toml"]
+[global.config]
+as = 65000
+router-id = "10.0.0.254"
+`,
+		"a.toml": `include = ["policy.toml"]`,
+		"b.toml": `include = ["policy.toml"]`,
+		"policy.toml": `[[defined-sets.prefix-sets]]
+prefix-set-name = "ps0"
+`,
+	})
+	defer os.RemoveAll(dir2)
+
+	c, err := ReadConfigfile(filepath.Join(dir2, "gobgpd.toml"), "toml")
+	assert.Nil(err)
+	assert.Equal(2, len(c.Policy.DefinedSets.PrefixSets))
+}
+
+func TestReadConfigfileIncludeConflict(t *testing.T) {
+	assert := assert.New(t)
+	dir := writeConfigfiles(t, map[string]string{
+		"gobgpd.toml": `include = ["global.toml"]
+[global.config]
+as = 65000
+router-id = "10.0.0.254"
+`,
+		"global.toml": `[global.config]
+as = 65001
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := ReadConfigfile(filepath.Join(dir, "gobgpd.toml"), "toml")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "global.config.as is defined in multiple files")
+
+	_, err = ReadConfigfile(filepath.Join(dir, "missing.toml"), "toml")
+	assert.NotNil(err)
+}
+
+func TestMergeConfigMap(t *testing.T) {
+	assert := assert.New(t)
+	dst := map[string]interface{}{
+		"global": map[string]interface{}{
+			"config": map[string]interface{}{"as": 65000},
+		},
+		"neighbors": []map[string]interface{}{{"peer-as": 65001}},
+	}
+	src := map[string]interface{}{
+		"global": map[string]interface{}{
+			"apply-policy": map[string]interface{}{"config": map[string]interface{}{}},
+		},
+		"neighbors": []interface{}{map[string]interface{}{"peer-as": 65002}},
+		"bmp-servers": []interface{}{},
+	}
+	assert.Nil(mergeConfigMap(dst, src, ""))
+	global := dst["global"].(map[string]interface{})
+	assert.Equal(map[string]interface{}{"as": 65000}, global["config"])
+	assert.NotNil(global["apply-policy"])
+	assert.Equal([]interface{}{map[string]interface{}{"peer-as": 65001}, map[string]interface{}{"peer-as": 65002}}, dst["neighbors"])
+	assert.NotNil(dst["bmp-servers"])
+
+	// a value can't be merged with another one or with a table
+	err := mergeConfigMap(dst, map[string]interface{}{
+		"global": map[string]interface{}{"config": map[string]interface{}{"as": 65001}},
+	}, "")
+	assert.Equal("global.config.as is defined in multiple files", err.Error())
+	err = mergeConfigMap(dst, map[string]interface{}{"global": "x"}, "")
+	assert.Equal("global is defined in multiple files", err.Error())
+}
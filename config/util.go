@@ -18,8 +18,24 @@ package config
 import (
 	"fmt"
 	"github.com/osrg/gobgp/packet"
+	"net"
+	"strings"
 )
 
+// ParseAddress parses the IP address, which may be followed by the zone
+// of a link-local IPv6 address like fe80::1%eth0. It returns nil if the
+// address is invalid.
+func ParseAddress(addr string) net.IP {
+	if i := strings.LastIndex(addr, "%"); i >= 0 {
+		ip := net.ParseIP(addr[:i])
+		if ip == nil || ip.To4() != nil || i == len(addr)-1 {
+			return nil
+		}
+		return ip
+	}
+	return net.ParseIP(addr)
+}
+
 func IsConfederationMember(g *Global, p *Neighbor) bool {
 	if p.Config.PeerAs != g.Config.As {
 		for _, member := range g.Confederation.Config.MemberAsList {
@@ -31,6 +47,15 @@ func IsConfederationMember(g *Global, p *Neighbor) bool {
 	return false
 }
 
+// LocalRouterId returns the BGP Identifier used toward the neighbor,
+// which is the global router-id unless overridden for the neighbor.
+func LocalRouterId(g *Global, p *Neighbor) string {
+	if p.Config.LocalRouterId != "" {
+		return p.Config.LocalRouterId
+	}
+	return g.Config.RouterId
+}
+
 func IsEBGPPeer(g *Global, p *Neighbor) bool {
 	return p.Config.PeerAs != g.Config.As
 }
@@ -48,6 +48,7 @@ func main() {
 		CPUs          int    `long:"cpus" description:"specify the number of CPUs to be used"`
 		Ops           bool   `long:"openswitch" description:"openswitch mode"`
 		GrpcPort      int    `long:"grpc-port" description:"grpc port" default:"50051"`
+		MetricsAddr   string `long:"metrics-address" description:"serve prometheus metrics on the address (e.g. :9179)"`
 	}
 	_, err := flags.Parse(&opts)
 	if err != nil {
@@ -167,6 +168,14 @@ func main() {
 	}
 	go bgpServer.Serve()
 
+	if opts.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", bgpServer.MetricsHandler())
+		go func() {
+			log.Fatal(http.ListenAndServe(opts.MetricsAddr, mux))
+		}()
+	}
+
 	// start grpc Server
 	grpcServer := server.NewGrpcServer(opts.GrpcPort, bgpServer.GrpcReqCh)
 	go func() {
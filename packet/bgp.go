@@ -203,14 +203,17 @@ const (
 type BGPCapabilityCode uint8
 
 const (
-	BGP_CAP_MULTIPROTOCOL          BGPCapabilityCode = 1
-	BGP_CAP_ROUTE_REFRESH          BGPCapabilityCode = 2
-	BGP_CAP_CARRYING_LABEL_INFO    BGPCapabilityCode = 4
-	BGP_CAP_GRACEFUL_RESTART       BGPCapabilityCode = 64
-	BGP_CAP_FOUR_OCTET_AS_NUMBER   BGPCapabilityCode = 65
-	BGP_CAP_ADD_PATH               BGPCapabilityCode = 69
-	BGP_CAP_ENHANCED_ROUTE_REFRESH BGPCapabilityCode = 70
-	BGP_CAP_ROUTE_REFRESH_CISCO    BGPCapabilityCode = 128
+	BGP_CAP_MULTIPROTOCOL            BGPCapabilityCode = 1
+	BGP_CAP_ROUTE_REFRESH            BGPCapabilityCode = 2
+	BGP_CAP_OUTBOUND_ROUTE_FILTERING BGPCapabilityCode = 3
+	BGP_CAP_CARRYING_LABEL_INFO      BGPCapabilityCode = 4
+	BGP_CAP_EXTENDED_NEXTHOP         BGPCapabilityCode = 5
+	BGP_CAP_GRACEFUL_RESTART         BGPCapabilityCode = 64
+	BGP_CAP_FOUR_OCTET_AS_NUMBER     BGPCapabilityCode = 65
+	BGP_CAP_ADD_PATH                 BGPCapabilityCode = 69
+	BGP_CAP_ENHANCED_ROUTE_REFRESH   BGPCapabilityCode = 70
+	BGP_CAP_PATHS_LIMIT              BGPCapabilityCode = 76
+	BGP_CAP_ROUTE_REFRESH_CISCO      BGPCapabilityCode = 128
 )
 
 type ParameterCapabilityInterface interface {
@@ -314,6 +317,15 @@ type CapCarryingLabelInfo struct {
 	DefaultParameterCapability
 }
 
+const (
+	BGP_CAP_GRACEFUL_RESTART_FLAG_RESTART      = 0x08
+	BGP_CAP_GRACEFUL_RESTART_FLAG_NOTIFICATION = 0x04
+)
+
+const (
+	BGP_CAP_GRACEFUL_RESTART_TUPLE_FLAG_FORWARDING = 0x80
+)
+
 type CapGracefulRestartTuples struct {
 	AFI   uint16
 	SAFI  uint8
@@ -495,6 +507,273 @@ func NewCapAddPath(rf RouteFamily, mode BGPAddPathMode) *CapAddPath {
 	}
 }
 
+type BGPORFType uint8
+
+const (
+	ORF_TYPE_ADDRESS_PREFIX BGPORFType = 64 // RFC5292
+)
+
+type BGPORFMode uint8
+
+const (
+	ORF_RECEIVE BGPORFMode = 1
+	ORF_SEND    BGPORFMode = 2
+	ORF_BOTH    BGPORFMode = 3
+)
+
+func (m BGPORFMode) String() string {
+	switch m {
+	case ORF_RECEIVE:
+		return "receive"
+	case ORF_SEND:
+		return "send"
+	case ORF_BOTH:
+		return "receive/send"
+	default:
+		return fmt.Sprintf("unknown(%d)", m)
+	}
+}
+
+type CapOutboundRouteFilteringTuple struct {
+	Type BGPORFType `json:"type"`
+	Mode BGPORFMode `json:"mode"`
+}
+
+type CapOutboundRouteFilteringValue struct {
+	RouteFamily RouteFamily                      `json:"route_family"`
+	Tuples      []CapOutboundRouteFilteringTuple `json:"tuples"`
+}
+
+type CapOutboundRouteFiltering struct {
+	DefaultParameterCapability
+	CapValue []CapOutboundRouteFilteringValue
+}
+
+func (c *CapOutboundRouteFiltering) DecodeFromBytes(data []byte) error {
+	if err := c.DefaultParameterCapability.DecodeFromBytes(data); err != nil {
+		return err
+	}
+	data = data[2 : 2+c.CapLen]
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return fmt.Errorf("Not all CapabilityOutboundRouteFiltering bytes available")
+		}
+		v := CapOutboundRouteFilteringValue{
+			RouteFamily: AfiSafiToRouteFamily(binary.BigEndian.Uint16(data[0:2]), data[3]),
+		}
+		num := int(data[4])
+		data = data[5:]
+		if len(data) < num*2 {
+			return fmt.Errorf("Not all CapabilityOutboundRouteFiltering bytes available")
+		}
+		for i := 0; i < num; i++ {
+			v.Tuples = append(v.Tuples, CapOutboundRouteFilteringTuple{BGPORFType(data[0]), BGPORFMode(data[1])})
+			data = data[2:]
+		}
+		c.CapValue = append(c.CapValue, v)
+	}
+	return nil
+}
+
+func (c *CapOutboundRouteFiltering) Serialize() ([]byte, error) {
+	buf := make([]byte, 0, 7*len(c.CapValue))
+	for _, v := range c.CapValue {
+		vbuf := make([]byte, 5)
+		afi, safi := RouteFamilyToAfiSafi(v.RouteFamily)
+		binary.BigEndian.PutUint16(vbuf[0:2], afi)
+		vbuf[3] = safi
+		vbuf[4] = uint8(len(v.Tuples))
+		for _, t := range v.Tuples {
+			vbuf = append(vbuf, byte(t.Type), byte(t.Mode))
+		}
+		buf = append(buf, vbuf...)
+	}
+	c.DefaultParameterCapability.CapValue = buf
+	return c.DefaultParameterCapability.Serialize()
+}
+
+func (c *CapOutboundRouteFiltering) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code  BGPCapabilityCode                `json:"code"`
+		Value []CapOutboundRouteFilteringValue `json:"value"`
+	}{
+		Code:  c.Code(),
+		Value: c.CapValue,
+	})
+}
+
+// Mode returns the send/receive mode advertised for the ORF type of
+// the route family, or zero if it isn't advertised.
+func (c *CapOutboundRouteFiltering) Mode(rf RouteFamily, t BGPORFType) BGPORFMode {
+	for _, v := range c.CapValue {
+		if v.RouteFamily != rf {
+			continue
+		}
+		for _, tuple := range v.Tuples {
+			if tuple.Type == t {
+				return tuple.Mode
+			}
+		}
+	}
+	return 0
+}
+
+func NewCapOutboundRouteFiltering(values []CapOutboundRouteFilteringValue) *CapOutboundRouteFiltering {
+	return &CapOutboundRouteFiltering{
+		DefaultParameterCapability: DefaultParameterCapability{
+			CapCode: BGP_CAP_OUTBOUND_ROUTE_FILTERING,
+		},
+		CapValue: values,
+	}
+}
+
+type CapExtendedNexthopTuple struct {
+	NLRIAFI    uint16 `json:"nlri_afi"`
+	NLRISAFI   uint16 `json:"nlri_safi"`
+	NexthopAFI uint16 `json:"nexthop_afi"`
+}
+
+// CapExtendedNexthop is the Extended Next Hop Encoding capability
+// (RFC5549) which tells the families whose NLRI may be advertised with
+// a next hop of another address family.
+type CapExtendedNexthop struct {
+	DefaultParameterCapability
+	Tuples []CapExtendedNexthopTuple
+}
+
+func (c *CapExtendedNexthop) DecodeFromBytes(data []byte) error {
+	if err := c.DefaultParameterCapability.DecodeFromBytes(data); err != nil {
+		return err
+	}
+	data = data[2 : 2+c.CapLen]
+	if len(data)%6 != 0 {
+		return fmt.Errorf("Not all CapabilityExtendedNexthop bytes available")
+	}
+	for ; len(data) > 0; data = data[6:] {
+		c.Tuples = append(c.Tuples, CapExtendedNexthopTuple{
+			NLRIAFI:    binary.BigEndian.Uint16(data[0:2]),
+			NLRISAFI:   binary.BigEndian.Uint16(data[2:4]),
+			NexthopAFI: binary.BigEndian.Uint16(data[4:6]),
+		})
+	}
+	return nil
+}
+
+func (c *CapExtendedNexthop) Serialize() ([]byte, error) {
+	buf := make([]byte, 6*len(c.Tuples))
+	for i, t := range c.Tuples {
+		binary.BigEndian.PutUint16(buf[i*6:], t.NLRIAFI)
+		binary.BigEndian.PutUint16(buf[i*6+2:], t.NLRISAFI)
+		binary.BigEndian.PutUint16(buf[i*6+4:], t.NexthopAFI)
+	}
+	c.DefaultParameterCapability.CapValue = buf
+	return c.DefaultParameterCapability.Serialize()
+}
+
+func (c *CapExtendedNexthop) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code   BGPCapabilityCode         `json:"code"`
+		Tuples []CapExtendedNexthopTuple `json:"tuples"`
+	}{
+		Code:   c.Code(),
+		Tuples: c.Tuples,
+	})
+}
+
+// Has tells whether the NLRI of the route family may be advertised
+// with a next hop of the address family.
+func (c *CapExtendedNexthop) Has(rf RouteFamily, nexthopAFI uint16) bool {
+	afi, safi := RouteFamilyToAfiSafi(rf)
+	for _, t := range c.Tuples {
+		if t.NLRIAFI == afi && t.NLRISAFI == uint16(safi) && t.NexthopAFI == nexthopAFI {
+			return true
+		}
+	}
+	return false
+}
+
+func NewCapExtendedNexthop(tuples []CapExtendedNexthopTuple) *CapExtendedNexthop {
+	return &CapExtendedNexthop{
+		DefaultParameterCapability: DefaultParameterCapability{
+			CapCode: BGP_CAP_EXTENDED_NEXTHOP,
+		},
+		Tuples: tuples,
+	}
+}
+
+type CapPathsLimitValue struct {
+	RouteFamily RouteFamily `json:"route_family"`
+	Limit       uint16      `json:"limit"`
+}
+
+// CapPathsLimit is the Paths-Limit capability
+// (draft-abraitis-idr-addpath-paths-limit) which tells the maximum
+// number of paths per NLRI the speaker is willing to receive with
+// ADD-PATH for each family.
+type CapPathsLimit struct {
+	DefaultParameterCapability
+	CapValue []CapPathsLimitValue
+}
+
+func (c *CapPathsLimit) DecodeFromBytes(data []byte) error {
+	if err := c.DefaultParameterCapability.DecodeFromBytes(data); err != nil {
+		return err
+	}
+	data = data[2 : 2+c.CapLen]
+	if len(data)%5 != 0 {
+		return fmt.Errorf("Not all CapabilityPathsLimit bytes available")
+	}
+	for ; len(data) > 0; data = data[5:] {
+		c.CapValue = append(c.CapValue, CapPathsLimitValue{
+			RouteFamily: AfiSafiToRouteFamily(binary.BigEndian.Uint16(data[0:2]), data[2]),
+			Limit:       binary.BigEndian.Uint16(data[3:5]),
+		})
+	}
+	return nil
+}
+
+func (c *CapPathsLimit) Serialize() ([]byte, error) {
+	buf := make([]byte, 5*len(c.CapValue))
+	for i, v := range c.CapValue {
+		afi, safi := RouteFamilyToAfiSafi(v.RouteFamily)
+		binary.BigEndian.PutUint16(buf[i*5:], afi)
+		buf[i*5+2] = safi
+		binary.BigEndian.PutUint16(buf[i*5+3:], v.Limit)
+	}
+	c.DefaultParameterCapability.CapValue = buf
+	return c.DefaultParameterCapability.Serialize()
+}
+
+func (c *CapPathsLimit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code  BGPCapabilityCode    `json:"code"`
+		Value []CapPathsLimitValue `json:"value"`
+	}{
+		Code:  c.Code(),
+		Value: c.CapValue,
+	})
+}
+
+// Limit returns the paths limit advertised for the route family, or
+// zero if it isn't advertised.
+func (c *CapPathsLimit) Limit(rf RouteFamily) uint16 {
+	for _, v := range c.CapValue {
+		if v.RouteFamily == rf {
+			return v.Limit
+		}
+	}
+	return 0
+}
+
+func NewCapPathsLimit(values []CapPathsLimitValue) *CapPathsLimit {
+	return &CapPathsLimit{
+		DefaultParameterCapability: DefaultParameterCapability{
+			CapCode: BGP_CAP_PATHS_LIMIT,
+		},
+		CapValue: values,
+	}
+}
+
 type CapEnhancedRouteRefresh struct {
 	DefaultParameterCapability
 }
@@ -533,8 +812,12 @@ func DecodeCapability(data []byte) (ParameterCapabilityInterface, error) {
 		c = &CapMultiProtocol{}
 	case BGP_CAP_ROUTE_REFRESH:
 		c = &CapRouteRefresh{}
+	case BGP_CAP_OUTBOUND_ROUTE_FILTERING:
+		c = &CapOutboundRouteFiltering{}
 	case BGP_CAP_CARRYING_LABEL_INFO:
 		c = &CapCarryingLabelInfo{}
+	case BGP_CAP_EXTENDED_NEXTHOP:
+		c = &CapExtendedNexthop{}
 	case BGP_CAP_GRACEFUL_RESTART:
 		c = &CapGracefulRestart{}
 	case BGP_CAP_FOUR_OCTET_AS_NUMBER:
@@ -543,6 +826,8 @@ func DecodeCapability(data []byte) (ParameterCapabilityInterface, error) {
 		c = &CapAddPath{}
 	case BGP_CAP_ENHANCED_ROUTE_REFRESH:
 		c = &CapEnhancedRouteRefresh{}
+	case BGP_CAP_PATHS_LIMIT:
+		c = &CapPathsLimit{}
 	case BGP_CAP_ROUTE_REFRESH_CISCO:
 		c = &CapRouteRefreshCisco{}
 	default:
@@ -615,6 +900,9 @@ func (o *OptionParameterUnknown) Serialize() ([]byte, error) {
 	return append(buf, o.Value...), nil
 }
 
+// BGP_VERSION is the version of the protocol sent in OPEN messages.
+const BGP_VERSION = 4
+
 type BGPOpen struct {
 	Version     uint8
 	MyAS        uint16
@@ -681,7 +969,7 @@ func (msg *BGPOpen) Serialize() ([]byte, error) {
 func NewBGPOpenMessage(myas uint16, holdtime uint16, id string, optparams []OptionParameterInterface) *BGPMessage {
 	return &BGPMessage{
 		Header: BGPHeader{Type: BGP_MSG_OPEN},
-		Body:   &BGPOpen{4, myas, holdtime, net.ParseIP(id).To4(), 0, optparams},
+		Body:   &BGPOpen{BGP_VERSION, myas, holdtime, net.ParseIP(id).To4(), 0, optparams},
 	}
 }
 
@@ -749,6 +1037,17 @@ func (r *IPAddrPrefixDefault) MarshalJSON() ([]byte, error) {
 type IPAddrPrefix struct {
 	IPAddrPrefixDefault
 	addrlen uint8
+	// path identifier (RFC7911) which is decoded and serialized only
+	// when Add-Path is negotiated for the direction
+	PathIdentifier uint32
+}
+
+func (r *IPAddrPrefix) setPathIdentifier(id uint32) {
+	r.PathIdentifier = id
+}
+
+func (r *IPAddrPrefix) pathIdentifier() uint32 {
+	return r.PathIdentifier
 }
 
 func (r *IPAddrPrefix) DecodeFromBytes(data []byte) error {
@@ -784,8 +1083,8 @@ func (r *IPAddrPrefix) SAFI() uint8 {
 
 func NewIPAddrPrefix(length uint8, prefix string) *IPAddrPrefix {
 	return &IPAddrPrefix{
-		IPAddrPrefixDefault{length, net.ParseIP(prefix).To4()},
-		4,
+		IPAddrPrefixDefault: IPAddrPrefixDefault{length, net.ParseIP(prefix).To4()},
+		addrlen:             4,
 	}
 }
 
@@ -815,8 +1114,8 @@ func (r *IPv6AddrPrefix) String() string {
 func NewIPv6AddrPrefix(length uint8, prefix string) *IPv6AddrPrefix {
 	return &IPv6AddrPrefix{
 		IPAddrPrefix{
-			IPAddrPrefixDefault{length, net.ParseIP(prefix)},
-			16,
+			IPAddrPrefixDefault: IPAddrPrefixDefault{length, net.ParseIP(prefix)},
+			addrlen:             16,
 		},
 	}
 }
@@ -3068,6 +3367,12 @@ const (
 	_
 	_
 	BGP_ATTR_TYPE_AIGP // = 26
+	_
+	_
+	_
+	_
+	_
+	BGP_ATTR_TYPE_LARGE_COMMUNITY // = 32
 )
 
 // NOTIFICATION Error Code  RFC 4271 4.5.
@@ -3098,6 +3403,7 @@ const (
 	BGP_ERROR_SUB_UNSUPPORTED_OPTIONAL_PARAMETER
 	BGP_ERROR_SUB_AUTHENTICATION_FAILURE
 	BGP_ERROR_SUB_UNACCEPTABLE_HOLD_TIME
+	BGP_ERROR_SUB_UNSUPPORTED_CAPABILITY
 )
 
 // NOTIFICATION Error Subcode for BGP_ERROR_UPDATE_MESSAGE_ERROR
@@ -3122,12 +3428,17 @@ const (
 	BGP_ERROR_SUB_HOLD_TIMER_EXPIRED
 )
 
-// NOTIFICATION Error Subcode for BGP_ERROR_FSM_ERROR
+// NOTIFICATION Error Subcode for BGP_ERROR_FSM_ERROR (RFC 6608)
 const (
 	_ = iota
-	BGP_ERROR_SUB_FSM_ERROR
+	BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENSENT_STATE
+	BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENCONFIRM_STATE
+	BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_ESTABLISHED_STATE
 )
 
+// BGP_ERROR_SUB_FSM_ERROR is the subcode defined for OPENSENT.
+const BGP_ERROR_SUB_FSM_ERROR = BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENSENT_STATE
+
 // NOTIFICATION Error Subcode for BGP_ERROR_CEASE  (RFC 4486)
 const (
 	_ = iota
@@ -3139,6 +3450,7 @@ const (
 	BGP_ERROR_SUB_OTHER_CONFIGURATION_CHANGE
 	BGP_ERROR_SUB_CONNECTION_COLLISION_RESOLUTION
 	BGP_ERROR_SUB_OUT_OF_RESOURCES
+	BGP_ERROR_SUB_HARD_RESET // RFC8538
 )
 
 var pathAttrFlags map[BGPAttrType]BGPAttrFlag = map[BGPAttrType]BGPAttrFlag{
@@ -3160,6 +3472,7 @@ var pathAttrFlags map[BGPAttrType]BGPAttrFlag = map[BGPAttrType]BGPAttrFlag{
 	BGP_ATTR_TYPE_PMSI_TUNNEL:          BGP_ATTR_FLAG_TRANSITIVE | BGP_ATTR_FLAG_OPTIONAL,
 	BGP_ATTR_TYPE_TUNNEL_ENCAP:         BGP_ATTR_FLAG_TRANSITIVE | BGP_ATTR_FLAG_OPTIONAL,
 	BGP_ATTR_TYPE_AIGP:                 BGP_ATTR_FLAG_OPTIONAL,
+	BGP_ATTR_TYPE_LARGE_COMMUNITY:      BGP_ATTR_FLAG_TRANSITIVE | BGP_ATTR_FLAG_OPTIONAL,
 }
 
 type PathAttributeInterface interface {
@@ -3983,6 +4296,20 @@ var WellKnownCommunityValueMap = map[string]WellKnownCommunity{
 	WellKnownCommunityNameMap[COMMUNITY_NO_PEER]:                    COMMUNITY_NO_PEER,
 }
 
+// IsWellKnownCommunity tells whether the community is one of the
+// well-known communities in WellKnownCommunityNameMap.
+func IsWellKnownCommunity(v uint32) bool {
+	_, ok := WellKnownCommunityNameMap[WellKnownCommunity(v)]
+	return ok
+}
+
+// IsReservedCommunity tells whether the community is in the range
+// 0xFFFF0000 to 0xFFFFFFFF reserved for the well-known communities
+// (RFC1997).
+func IsReservedCommunity(v uint32) bool {
+	return v&0xffff0000 == 0xffff0000
+}
+
 func (p *PathAttributeCommunities) String() string {
 	l := []string{}
 	for _, v := range p.Value {
@@ -4143,6 +4470,7 @@ type PathAttributeMpReachNLRI struct {
 	AFI              uint16
 	SAFI             uint8
 	Value            []AddrPrefixInterface
+	options          *MarshallingOption
 }
 
 func (p *PathAttributeMpReachNLRI) DecodeFromBytes(data []byte) error {
@@ -4179,7 +4507,8 @@ func (p *PathAttributeMpReachNLRI) DecodeFromBytes(data []byte) error {
 		addrlen := 4
 		hasLinkLocal := false
 
-		if afi == AFI_IP6 {
+		// RFC5549, IPv4 NLRI may have an IPv6 next hop
+		if afi == AFI_IP6 || (afi == AFI_IP && (len(nexthopbin) == offset+16 || len(nexthopbin) == offset+32)) {
 			addrlen = 16
 			hasLinkLocal = len(nexthopbin) == offset+2*addrlen
 		}
@@ -4199,19 +4528,20 @@ func (p *PathAttributeMpReachNLRI) DecodeFromBytes(data []byte) error {
 		return NewMessageError(eCode, eSubCode, value, "no skip byte")
 	}
 	value = value[1:]
+	addPath := p.options.addPathReceive(AfiSafiToRouteFamily(afi, safi))
 	for len(value) > 0 {
 		prefix, err := NewPrefixFromRouteFamily(afi, safi)
 		if err != nil {
 			return NewMessageError(eCode, BGP_ERROR_SUB_ATTRIBUTE_FLAGS_ERROR, data[:p.PathAttribute.Len()], err.Error())
 		}
-		err = prefix.DecodeFromBytes(value)
+		l, err := decodeNlri(prefix, value, addPath)
 		if err != nil {
 			return err
 		}
-		if prefix.Len() > len(value) {
+		if l > len(value) {
 			return NewMessageError(eCode, eSubCode, value, "prefix length is incorrect")
 		}
-		value = value[prefix.Len():]
+		value = value[l:]
 		p.Value = append(p.Value, prefix)
 	}
 	return nil
@@ -4221,7 +4551,7 @@ func (p *PathAttributeMpReachNLRI) Serialize() ([]byte, error) {
 	afi := p.AFI
 	safi := p.SAFI
 	nexthoplen := 4
-	if afi == AFI_IP6 {
+	if afi == AFI_IP6 || (p.Nexthop != nil && p.Nexthop.To4() == nil) {
 		nexthoplen = 16
 		if p.LinkLocalNexthop != nil {
 			nexthoplen += 16
@@ -4244,8 +4574,9 @@ func (p *PathAttributeMpReachNLRI) Serialize() ([]byte, error) {
 		copy(buf[4+offset+len(p.Nexthop):], p.LinkLocalNexthop)
 	}
 	buf = append(buf, make([]byte, 1)...)
+	addPath := p.options.addPathSend(AfiSafiToRouteFamily(afi, safi))
 	for _, prefix := range p.Value {
-		pbuf, err := prefix.Serialize()
+		pbuf, err := encodeNlri(prefix, addPath)
 		if err != nil {
 			return nil, err
 		}
@@ -4305,9 +4636,10 @@ func NewPathAttributeMpReachNLRI(nexthop string, nlri []AddrPrefixInterface) *Pa
 
 type PathAttributeMpUnreachNLRI struct {
 	PathAttribute
-	AFI   uint16
-	SAFI  uint8
-	Value []AddrPrefixInterface
+	AFI     uint16
+	SAFI    uint8
+	Value   []AddrPrefixInterface
+	options *MarshallingOption
 }
 
 func (p *PathAttributeMpUnreachNLRI) DecodeFromBytes(data []byte) error {
@@ -4331,19 +4663,20 @@ func (p *PathAttributeMpUnreachNLRI) DecodeFromBytes(data []byte) error {
 	value = value[3:]
 	p.AFI = afi
 	p.SAFI = safi
+	addPath := p.options.addPathReceive(AfiSafiToRouteFamily(afi, safi))
 	for len(value) > 0 {
 		prefix, err := NewPrefixFromRouteFamily(afi, safi)
 		if err != nil {
 			return NewMessageError(eCode, BGP_ERROR_SUB_ATTRIBUTE_FLAGS_ERROR, data[:p.PathAttribute.Len()], err.Error())
 		}
-		err = prefix.DecodeFromBytes(value)
+		l, err := decodeNlri(prefix, value, addPath)
 		if err != nil {
 			return err
 		}
-		if prefix.Len() > len(value) {
+		if l > len(value) {
 			return NewMessageError(eCode, eSubCode, data[:p.PathAttribute.Len()], "prefix length is incorrect")
 		}
-		value = value[prefix.Len():]
+		value = value[l:]
 		p.Value = append(p.Value, prefix)
 	}
 	return nil
@@ -4353,8 +4686,9 @@ func (p *PathAttributeMpUnreachNLRI) Serialize() ([]byte, error) {
 	buf := make([]byte, 3)
 	binary.BigEndian.PutUint16(buf, p.AFI)
 	buf[2] = p.SAFI
+	addPath := p.options.addPathSend(AfiSafiToRouteFamily(p.AFI, p.SAFI))
 	for _, prefix := range p.Value {
-		pbuf, err := prefix.Serialize()
+		pbuf, err := encodeNlri(prefix, addPath)
 		if err != nil {
 			return nil, err
 		}
@@ -6004,6 +6338,112 @@ func NewPathAttributeAigp(values []AigpTLV) *PathAttributeAigp {
 	}
 }
 
+// LargeCommunity is a BGP Large Community (RFC8092), written as
+// ASN:LocalData1:LocalData2.
+type LargeCommunity struct {
+	ASN        uint32
+	LocalData1 uint32
+	LocalData2 uint32
+}
+
+func (c *LargeCommunity) String() string {
+	return fmt.Sprintf("%d:%d:%d", c.ASN, c.LocalData1, c.LocalData2)
+}
+
+func (c *LargeCommunity) Equal(rhs *LargeCommunity) bool {
+	return c.ASN == rhs.ASN && c.LocalData1 == rhs.LocalData1 && c.LocalData2 == rhs.LocalData2
+}
+
+func NewLargeCommunity(asn, data1, data2 uint32) *LargeCommunity {
+	return &LargeCommunity{
+		ASN:        asn,
+		LocalData1: data1,
+		LocalData2: data2,
+	}
+}
+
+// ParseLargeCommunity parses the large community written as
+// ASN:LocalData1:LocalData2.
+func ParseLargeCommunity(value string) (*LargeCommunity, error) {
+	elems := strings.Split(value, ":")
+	if len(elems) != 3 {
+		return nil, fmt.Errorf("invalid large community format: %s", value)
+	}
+	v := make([]uint32, 0, 3)
+	for _, elem := range elems {
+		e, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid large community format: %s", value)
+		}
+		v = append(v, uint32(e))
+	}
+	return NewLargeCommunity(v[0], v[1], v[2]), nil
+}
+
+type PathAttributeLargeCommunities struct {
+	PathAttribute
+	Values []*LargeCommunity
+}
+
+func (p *PathAttributeLargeCommunities) DecodeFromBytes(data []byte) error {
+	err := p.PathAttribute.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	if len(p.PathAttribute.Value)%12 != 0 {
+		eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+		eSubCode := uint8(BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR)
+		return NewMessageError(eCode, eSubCode, nil, "large communities length isn't correct")
+	}
+	value := p.PathAttribute.Value
+	p.Values = make([]*LargeCommunity, 0, len(value)/12)
+	for len(value) >= 12 {
+		p.Values = append(p.Values, NewLargeCommunity(binary.BigEndian.Uint32(value), binary.BigEndian.Uint32(value[4:]), binary.BigEndian.Uint32(value[8:])))
+		value = value[12:]
+	}
+	return nil
+}
+
+func (p *PathAttributeLargeCommunities) Serialize() ([]byte, error) {
+	buf := make([]byte, len(p.Values)*12)
+	for i, v := range p.Values {
+		binary.BigEndian.PutUint32(buf[i*12:], v.ASN)
+		binary.BigEndian.PutUint32(buf[i*12+4:], v.LocalData1)
+		binary.BigEndian.PutUint32(buf[i*12+8:], v.LocalData2)
+	}
+	p.PathAttribute.Value = buf
+	return p.PathAttribute.Serialize()
+}
+
+func (p *PathAttributeLargeCommunities) String() string {
+	l := make([]string, 0, len(p.Values))
+	for _, v := range p.Values {
+		l = append(l, v.String())
+	}
+	return fmt.Sprintf("{LargeCommunities: %s}", strings.Join(l, ", "))
+}
+
+func (p *PathAttributeLargeCommunities) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  BGPAttrType       `json:"type"`
+		Value []*LargeCommunity `json:"value"`
+	}{
+		Type:  p.GetType(),
+		Value: p.Values,
+	})
+}
+
+func NewPathAttributeLargeCommunities(values []*LargeCommunity) *PathAttributeLargeCommunities {
+	t := BGP_ATTR_TYPE_LARGE_COMMUNITY
+	return &PathAttributeLargeCommunities{
+		PathAttribute: PathAttribute{
+			Flags: pathAttrFlags[t],
+			Type:  t,
+		},
+		Values: values,
+	}
+}
+
 type PathAttributeUnknown struct {
 	PathAttribute
 }
@@ -6051,6 +6491,8 @@ func GetPathAttribute(data []byte) (PathAttributeInterface, error) {
 		return &PathAttributePmsiTunnel{}, nil
 	case BGP_ATTR_TYPE_AIGP:
 		return &PathAttributeAigp{}, nil
+	case BGP_ATTR_TYPE_LARGE_COMMUNITY:
+		return &PathAttributeLargeCommunities{}, nil
 	}
 	return &PathAttributeUnknown{}, nil
 }
@@ -6064,6 +6506,10 @@ type BGPUpdate struct {
 }
 
 func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
+	return msg.decodeFromBytes(data, nil)
+}
+
+func (msg *BGPUpdate) decodeFromBytes(data []byte, options *MarshallingOption) error {
 
 	// cache error codes
 	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
@@ -6082,18 +6528,19 @@ func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
 		return NewMessageError(eCode, eSubCode, nil, "withdrawn route length exceeds message length")
 	}
 
+	addPath := options.addPathReceive(RF_IPv4_UC)
 	msg.WithdrawnRoutes = make([]*IPAddrPrefix, 0, msg.WithdrawnRoutesLen)
 	for routelen := msg.WithdrawnRoutesLen; routelen > 0; {
 		w := &IPAddrPrefix{}
-		err := w.DecodeFromBytes(data)
+		l, err := decodeNlri(w, data, addPath)
 		if err != nil {
 			return err
 		}
-		routelen -= uint16(w.Len())
-		if len(data) < w.Len() {
+		routelen -= uint16(l)
+		if len(data) < l {
 			return NewMessageError(eCode, eSubCode, nil, "Withdrawn route length is short")
 		}
-		data = data[w.Len():]
+		data = data[l:]
 		msg.WithdrawnRoutes = append(msg.WithdrawnRoutes, w)
 	}
 
@@ -6116,6 +6563,12 @@ func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
 		if err != nil {
 			return err
 		}
+		switch a := p.(type) {
+		case *PathAttributeMpReachNLRI:
+			a.options = options
+		case *PathAttributeMpUnreachNLRI:
+			a.options = options
+		}
 		err = p.DecodeFromBytes(data)
 		if err != nil {
 			return err
@@ -6131,15 +6584,15 @@ func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
 	msg.NLRI = make([]*IPAddrPrefix, 0)
 	for restlen := len(data); restlen > 0; {
 		n := &IPAddrPrefix{}
-		err := n.DecodeFromBytes(data)
+		l, err := decodeNlri(n, data, addPath)
 		if err != nil {
 			return err
 		}
-		restlen -= n.Len()
-		if len(data) < n.Len() {
+		restlen -= l
+		if len(data) < l {
 			return NewMessageError(eCode, BGP_ERROR_SUB_INVALID_NETWORK_FIELD, nil, "NLRI length is short")
 		}
-		data = data[n.Len():]
+		data = data[l:]
 		msg.NLRI = append(msg.NLRI, n)
 	}
 
@@ -6147,9 +6600,16 @@ func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
 }
 
 func (msg *BGPUpdate) Serialize() ([]byte, error) {
+	return msg.serialize(nil)
+}
+
+// serialize encodes the message with the path identifiers of the
+// families Add-Path send is negotiated for.
+func (msg *BGPUpdate) serialize(options *MarshallingOption) ([]byte, error) {
+	addPath := options.addPathSend(RF_IPv4_UC)
 	wbuf := make([]byte, 2)
 	for _, w := range msg.WithdrawnRoutes {
-		onewbuf, err := w.Serialize()
+		onewbuf, err := encodeNlri(w, addPath)
 		if err != nil {
 			return nil, err
 		}
@@ -6160,6 +6620,20 @@ func (msg *BGPUpdate) Serialize() ([]byte, error) {
 
 	pbuf := make([]byte, 2)
 	for _, p := range msg.PathAttributes {
+		// the attributes can be shared by the messages to the
+		// peers, so the options are set on a copy
+		if options != nil {
+			switch a := p.(type) {
+			case *PathAttributeMpReachNLRI:
+				c := *a
+				c.options = options
+				p = &c
+			case *PathAttributeMpUnreachNLRI:
+				c := *a
+				c.options = options
+				p = &c
+			}
+		}
 		onepbuf, err := p.Serialize()
 		if err != nil {
 			return nil, err
@@ -6171,7 +6645,7 @@ func (msg *BGPUpdate) Serialize() ([]byte, error) {
 
 	buf := append(wbuf, pbuf...)
 	for _, n := range msg.NLRI {
-		nbuf, err := n.Serialize()
+		nbuf, err := encodeNlri(n, addPath)
 		if err != nil {
 			return nil, err
 		}
@@ -6187,6 +6661,33 @@ func NewBGPUpdateMessage(withdrawnRoutes []*IPAddrPrefix, pathattrs []PathAttrib
 	}
 }
 
+// NewEndOfRib returns the End-of-RIB marker (RFC4724) of the family.
+func NewEndOfRib(family RouteFamily) *BGPMessage {
+	if family == RF_IPv4_UC {
+		return NewBGPUpdateMessage(nil, nil, nil)
+	}
+	unreach := NewPathAttributeMpUnreachNLRI(nil)
+	unreach.AFI, unreach.SAFI = RouteFamilyToAfiSafi(family)
+	return NewBGPUpdateMessage(nil, []PathAttributeInterface{unreach}, nil)
+}
+
+// IsEndOfRib tells whether the message is the End-of-RIB marker
+// (RFC4724) and returns its family.
+func (msg *BGPUpdate) IsEndOfRib() (bool, RouteFamily) {
+	if len(msg.WithdrawnRoutes) != 0 || len(msg.NLRI) != 0 {
+		return false, RouteFamily(0)
+	}
+	switch len(msg.PathAttributes) {
+	case 0:
+		return true, RF_IPv4_UC
+	case 1:
+		if unreach, ok := msg.PathAttributes[0].(*PathAttributeMpUnreachNLRI); ok && len(unreach.Value) == 0 {
+			return true, AfiSafiToRouteFamily(unreach.AFI, unreach.SAFI)
+		}
+	}
+	return false, RouteFamily(0)
+}
+
 type BGPNotification struct {
 	ErrorCode    uint8
 	ErrorSubcode uint8
@@ -6238,10 +6739,109 @@ func NewBGPKeepAliveMessage() *BGPMessage {
 	}
 }
 
+type BGPORFWhen uint8
+
+const (
+	ORF_WHEN_IMMEDIATE BGPORFWhen = 1
+	ORF_WHEN_DEFER     BGPORFWhen = 2
+)
+
+type BGPORFAction uint8
+
+const (
+	ORF_ACTION_ADD        BGPORFAction = 0
+	ORF_ACTION_REMOVE     BGPORFAction = 1
+	ORF_ACTION_REMOVE_ALL BGPORFAction = 2
+)
+
+type BGPORFMatch uint8
+
+const (
+	ORF_MATCH_PERMIT BGPORFMatch = 0
+	ORF_MATCH_DENY   BGPORFMatch = 1
+)
+
+// ORFAddressPrefixEntry is an entry of the Address Prefix ORF
+// (RFC5292). Only Action is meaningful for ORF_ACTION_REMOVE_ALL.
+type ORFAddressPrefixEntry struct {
+	Action   BGPORFAction
+	Match    BGPORFMatch
+	Sequence uint32
+	MinLen   uint8
+	MaxLen   uint8
+	Length   uint8
+	Prefix   net.IP
+}
+
+func (e *ORFAddressPrefixEntry) decodeFromBytes(afi uint16, data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, fmt.Errorf("Not all ORFAddressPrefixEntry bytes available")
+	}
+	e.Action = BGPORFAction(data[0] >> 6)
+	e.Match = BGPORFMatch((data[0] >> 5) & 0x1)
+	if e.Action == ORF_ACTION_REMOVE_ALL {
+		return 1, nil
+	}
+	if len(data) < 8 {
+		return 0, fmt.Errorf("Not all ORFAddressPrefixEntry bytes available")
+	}
+	e.Sequence = binary.BigEndian.Uint32(data[1:5])
+	e.MinLen = data[5]
+	e.MaxLen = data[6]
+	e.Length = data[7]
+	addrlen := net.IPv4len
+	if afi == AFI_IP6 {
+		addrlen = net.IPv6len
+	}
+	if int(e.Length) > addrlen*8 {
+		return 0, fmt.Errorf("Invalid ORFAddressPrefixEntry prefix length: %d", e.Length)
+	}
+	n := (int(e.Length) + 7) / 8
+	if len(data) < 8+n {
+		return 0, fmt.Errorf("Not all ORFAddressPrefixEntry bytes available")
+	}
+	e.Prefix = make(net.IP, addrlen)
+	copy(e.Prefix, data[8:8+n])
+	return 8 + n, nil
+}
+
+func (e *ORFAddressPrefixEntry) serialize(afi uint16) []byte {
+	buf := []byte{byte(e.Action)<<6 | byte(e.Match)<<5}
+	if e.Action == ORF_ACTION_REMOVE_ALL {
+		return buf
+	}
+	tbuf := make([]byte, 7)
+	binary.BigEndian.PutUint32(tbuf[0:4], e.Sequence)
+	tbuf[4] = e.MinLen
+	tbuf[5] = e.MaxLen
+	tbuf[6] = e.Length
+	buf = append(buf, tbuf...)
+	prefix := e.Prefix.To4()
+	if afi == AFI_IP6 || prefix == nil {
+		prefix = e.Prefix.To16()
+	}
+	return append(buf, prefix[:(int(e.Length)+7)/8]...)
+}
+
+func (e *ORFAddressPrefixEntry) String() string {
+	return fmt.Sprintf("seq %d %s/%d ge %d le %d", e.Sequence, e.Prefix, e.Length, e.MinLen, e.MaxLen)
+}
+
+// RouteRefreshORF is an ORF carried in a ROUTE_REFRESH message
+// (RFC5291). The entries of the types other than the Address Prefix
+// ORF are kept undecoded in Value.
+type RouteRefreshORF struct {
+	Type    BGPORFType
+	Entries []*ORFAddressPrefixEntry
+	Value   []byte
+}
+
 type BGPRouteRefresh struct {
 	AFI         uint16
 	Demarcation uint8
 	SAFI        uint8
+	When        BGPORFWhen
+	ORFs        []*RouteRefreshORF
 }
 
 func (msg *BGPRouteRefresh) DecodeFromBytes(data []byte) error {
@@ -6251,6 +6851,40 @@ func (msg *BGPRouteRefresh) DecodeFromBytes(data []byte) error {
 	msg.AFI = binary.BigEndian.Uint16(data[0:2])
 	msg.Demarcation = data[2]
 	msg.SAFI = data[3]
+	data = data[4:]
+	if len(data) == 0 {
+		return nil
+	}
+	msg.When = BGPORFWhen(data[0])
+	data = data[1:]
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return fmt.Errorf("Not all RouteRefresh ORF bytes available")
+		}
+		orf := &RouteRefreshORF{Type: BGPORFType(data[0])}
+		l := int(binary.BigEndian.Uint16(data[1:3]))
+		data = data[3:]
+		if len(data) < l {
+			return fmt.Errorf("Not all RouteRefresh ORF bytes available")
+		}
+		value := data[:l]
+		data = data[l:]
+		if orf.Type != ORF_TYPE_ADDRESS_PREFIX {
+			orf.Value = value
+			msg.ORFs = append(msg.ORFs, orf)
+			continue
+		}
+		for len(value) > 0 {
+			e := &ORFAddressPrefixEntry{}
+			n, err := e.decodeFromBytes(msg.AFI, value)
+			if err != nil {
+				return err
+			}
+			orf.Entries = append(orf.Entries, e)
+			value = value[n:]
+		}
+		msg.ORFs = append(msg.ORFs, orf)
+	}
 	return nil
 }
 
@@ -6259,13 +6893,50 @@ func (msg *BGPRouteRefresh) Serialize() ([]byte, error) {
 	binary.BigEndian.PutUint16(buf[0:2], msg.AFI)
 	buf[2] = msg.Demarcation
 	buf[3] = msg.SAFI
+	if msg.When == 0 && len(msg.ORFs) == 0 {
+		return buf, nil
+	}
+	buf = append(buf, byte(msg.When))
+	for _, orf := range msg.ORFs {
+		value := orf.Value
+		if orf.Type == ORF_TYPE_ADDRESS_PREFIX {
+			value = make([]byte, 0, 16*len(orf.Entries))
+			for _, e := range orf.Entries {
+				value = append(value, e.serialize(msg.AFI)...)
+			}
+		}
+		hbuf := make([]byte, 3)
+		hbuf[0] = byte(orf.Type)
+		binary.BigEndian.PutUint16(hbuf[1:3], uint16(len(value)))
+		buf = append(buf, hbuf...)
+		buf = append(buf, value...)
+	}
 	return buf, nil
 }
 
 func NewBGPRouteRefreshMessage(afi uint16, demarcation uint8, safi uint8) *BGPMessage {
 	return &BGPMessage{
 		Header: BGPHeader{Type: BGP_MSG_ROUTE_REFRESH},
-		Body:   &BGPRouteRefresh{afi, demarcation, safi},
+		Body: &BGPRouteRefresh{
+			AFI:         afi,
+			Demarcation: demarcation,
+			SAFI:        safi,
+		},
+	}
+}
+
+// NewBGPRouteRefreshORFMessage returns a ROUTE_REFRESH message which
+// carries the Address Prefix ORF entries.
+func NewBGPRouteRefreshORFMessage(rf RouteFamily, when BGPORFWhen, entries []*ORFAddressPrefixEntry) *BGPMessage {
+	afi, safi := RouteFamilyToAfiSafi(rf)
+	return &BGPMessage{
+		Header: BGPHeader{Type: BGP_MSG_ROUTE_REFRESH},
+		Body: &BGPRouteRefresh{
+			AFI:  afi,
+			SAFI: safi,
+			When: when,
+			ORFs: []*RouteRefreshORF{{Type: ORF_TYPE_ADDRESS_PREFIX, Entries: entries}},
+		},
 	}
 }
 
@@ -6308,12 +6979,75 @@ func (msg *BGPHeader) Serialize() ([]byte, error) {
 	return buf, nil
 }
 
+// MarshallingOption carries the capabilities negotiated with the peer
+// which change the encoding of the messages.
+type MarshallingOption struct {
+	// Add-Path (RFC7911) mode per family from our point of view
+	AddPath map[RouteFamily]BGPAddPathMode
+}
+
+func (o *MarshallingOption) addPathReceive(rf RouteFamily) bool {
+	return o != nil && o.AddPath[rf]&BGP_ADD_PATH_RECEIVE != 0
+}
+
+func (o *MarshallingOption) addPathSend(rf RouteFamily) bool {
+	return o != nil && o.AddPath[rf]&BGP_ADD_PATH_SEND != 0
+}
+
+func marshallingOption(options []*MarshallingOption) *MarshallingOption {
+	if len(options) == 0 {
+		return nil
+	}
+	return options[0]
+}
+
+// decodeNlri decodes the prefix preceded by the path identifier when
+// Add-Path receive is in effect and returns the length consumed. Only
+// the prefixes of the unicast families can carry the identifier.
+func decodeNlri(prefix AddrPrefixInterface, data []byte, addPath bool) (int, error) {
+	p, ok := prefix.(interface {
+		setPathIdentifier(uint32)
+	})
+	if !addPath || !ok {
+		if err := prefix.DecodeFromBytes(data); err != nil {
+			return 0, err
+		}
+		return prefix.Len(), nil
+	}
+	if len(data) < 4 {
+		return 0, NewMessageError(BGP_ERROR_UPDATE_MESSAGE_ERROR, BGP_ERROR_SUB_INVALID_NETWORK_FIELD, nil, "path identifier is short")
+	}
+	if err := prefix.DecodeFromBytes(data[4:]); err != nil {
+		return 0, err
+	}
+	p.setPathIdentifier(binary.BigEndian.Uint32(data[:4]))
+	return 4 + prefix.Len(), nil
+}
+
+// encodeNlri encodes the prefix preceded by its path identifier when
+// Add-Path send is in effect.
+func encodeNlri(prefix AddrPrefixInterface, addPath bool) ([]byte, error) {
+	buf, err := prefix.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := prefix.(interface {
+		pathIdentifier() uint32
+	})
+	if !addPath || !ok {
+		return buf, nil
+	}
+	id := make([]byte, 4, 4+len(buf))
+	binary.BigEndian.PutUint32(id, p.pathIdentifier())
+	return append(id, buf...), nil
+}
+
 type BGPMessage struct {
 	Header BGPHeader
 	Body   BGPBody
 }
 
-func parseBody(h *BGPHeader, data []byte) (*BGPMessage, error) {
+func parseBody(h *BGPHeader, data []byte, options *MarshallingOption) (*BGPMessage, error) {
 	if len(data) < int(h.Len)-BGP_HEADER_LENGTH {
 		return nil, fmt.Errorf("Not all BGP message bytes available")
 	}
@@ -6333,38 +7067,57 @@ func parseBody(h *BGPHeader, data []byte) (*BGPMessage, error) {
 	default:
 		return nil, NewMessageError(BGP_ERROR_MESSAGE_HEADER_ERROR, BGP_ERROR_SUB_BAD_MESSAGE_TYPE, nil, "unknown message type")
 	}
-	err := msg.Body.DecodeFromBytes(data)
+	var err error
+	if u, ok := msg.Body.(*BGPUpdate); ok {
+		err = u.decodeFromBytes(data, options)
+	} else {
+		err = msg.Body.DecodeFromBytes(data)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return msg, nil
 }
 
-func ParseBGPMessage(data []byte) (*BGPMessage, error) {
+func ParseBGPMessage(data []byte, options ...*MarshallingOption) (*BGPMessage, error) {
 	h := &BGPHeader{}
 	err := h.DecodeFromBytes(data)
 	if err != nil {
 		return nil, err
 	}
-	return parseBody(h, data[19:h.Len])
+	return parseBody(h, data[19:h.Len], marshallingOption(options))
 }
 
-func ParseBGPBody(h *BGPHeader, data []byte) (*BGPMessage, error) {
-	return parseBody(h, data)
+func ParseBGPBody(h *BGPHeader, data []byte, options ...*MarshallingOption) (*BGPMessage, error) {
+	return parseBody(h, data, marshallingOption(options))
 }
 
-func (msg *BGPMessage) Serialize() ([]byte, error) {
-	b, err := msg.Body.Serialize()
+// Serialize encodes the message. The options are those negotiated with
+// the peer the message is sent to.
+func (msg *BGPMessage) Serialize(options ...*MarshallingOption) ([]byte, error) {
+	o := marshallingOption(options)
+	var b []byte
+	var err error
+	if u, ok := msg.Body.(*BGPUpdate); ok {
+		b, err = u.serialize(o)
+	} else {
+		b, err = msg.Body.Serialize()
+	}
 	if err != nil {
 		return nil, err
 	}
-	if msg.Header.Len == 0 {
+	header := msg.Header
+	if header.Len == 0 || o != nil {
 		if 19+len(b) > BGP_MAX_MESSAGE_LENGTH {
 			return nil, NewMessageError(0, 0, nil, fmt.Sprintf("too long message length %d", 19+len(b)))
 		}
-		msg.Header.Len = 19 + uint16(len(b))
+		header.Len = 19 + uint16(len(b))
+		// the length with the options is only for the peer
+		if o == nil {
+			msg.Header.Len = header.Len
+		}
 	}
-	h, err := msg.Header.Serialize()
+	h, err := header.Serialize()
 	if err != nil {
 		return nil, err
 	}
@@ -34,8 +34,14 @@ func open() *BGPMessage {
 		[]ParameterCapabilityInterface{NewCapFourOctetASNumber(100000)})
 	p5 := NewOptionParameterCapability(
 		[]ParameterCapabilityInterface{NewCapAddPath(RF_IPv4_UC, BGP_ADD_PATH_BOTH)})
+	p6 := NewOptionParameterCapability(
+		[]ParameterCapabilityInterface{NewCapOutboundRouteFiltering(
+			[]CapOutboundRouteFilteringValue{{RF_IPv4_UC, []CapOutboundRouteFilteringTuple{{ORF_TYPE_ADDRESS_PREFIX, ORF_BOTH}}}})})
+	p7 := NewOptionParameterCapability(
+		[]ParameterCapabilityInterface{NewCapPathsLimit(
+			[]CapPathsLimitValue{{RF_IPv4_UC, 4}, {RF_IPv6_UC, 8}})})
 	return NewBGPOpenMessage(11033, 303, "100.4.10.3",
-		[]OptionParameterInterface{p1, p2, p3, p4, p5})
+		[]OptionParameterInterface{p1, p2, p3, p4, p5, p6, p7})
 }
 
 func update() *BGPMessage {
@@ -534,3 +540,306 @@ func Test_Aigp(t *testing.T) {
 		t.Log(bytes.Equal(buf1, buf2))
 	}
 }
+
+func Test_LargeCommunities(t *testing.T) {
+	assert := assert.New(t)
+	c, err := ParseLargeCommunity("65000:4294967295:100")
+	assert.Nil(err)
+	assert.Equal(NewLargeCommunity(65000, 4294967295, 100), c)
+	for _, s := range []string{"65000:100", "65000:100:x", "65000:100:4294967296"} {
+		_, err = ParseLargeCommunity(s)
+		assert.NotNil(err, s)
+	}
+
+	a1 := NewPathAttributeLargeCommunities([]*LargeCommunity{c, NewLargeCommunity(1, 2, 3)})
+	buf1, err := a1.Serialize()
+	assert.Nil(err)
+	a2, err := GetPathAttribute(buf1)
+	assert.Nil(err)
+	assert.Nil(a2.DecodeFromBytes(buf1))
+	assert.Equal(a1.Values, a2.(*PathAttributeLargeCommunities).Values)
+	assert.Equal("{LargeCommunities: 65000:4294967295:100, 1:2:3}", a2.String())
+
+	// the length must be a multiple of 12
+	buf1[2]--
+	assert.NotNil(a2.DecodeFromBytes(buf1[:len(buf1)-1]))
+}
+
+func Test_IsEndOfRib(t *testing.T) {
+	assert := assert.New(t)
+	u := NewBGPUpdateMessage(nil, nil, nil).Body.(*BGPUpdate)
+	eor, family := u.IsEndOfRib()
+	assert.True(eor)
+	assert.Equal(RF_IPv4_UC, family)
+	unreach := NewPathAttributeMpUnreachNLRI(nil)
+	unreach.AFI, unreach.SAFI = AFI_IP6, SAFI_UNICAST
+	u = NewBGPUpdateMessage(nil, []PathAttributeInterface{unreach}, nil).Body.(*BGPUpdate)
+	eor, family = u.IsEndOfRib()
+	assert.True(eor)
+	assert.Equal(RF_IPv6_UC, family)
+
+	u = NewBGPUpdateMessage([]*IPAddrPrefix{NewIPAddrPrefix(24, "10.0.0.0")}, nil, nil).Body.(*BGPUpdate)
+	eor, _ = u.IsEndOfRib()
+	assert.False(eor)
+	u = NewBGPUpdateMessage(nil, []PathAttributeInterface{NewPathAttributeMpUnreachNLRI([]AddrPrefixInterface{NewIPv6AddrPrefix(64, "2001:db8::")})}, nil).Body.(*BGPUpdate)
+	eor, _ = u.IsEndOfRib()
+	assert.False(eor)
+}
+
+func Test_RouteRefreshORF(t *testing.T) {
+	assert := assert.New(t)
+	entries := []*ORFAddressPrefixEntry{
+		{Action: ORF_ACTION_REMOVE_ALL},
+		{
+			Action:   ORF_ACTION_ADD,
+			Match:    ORF_MATCH_PERMIT,
+			Sequence: 10,
+			MinLen:   16,
+			MaxLen:   24,
+			Length:   8,
+			Prefix:   net.ParseIP("10.0.0.0").To4(),
+		},
+		{
+			Action:   ORF_ACTION_REMOVE,
+			Match:    ORF_MATCH_DENY,
+			Sequence: 20,
+			Length:   23,
+			Prefix:   net.ParseIP("192.168.2.0").To4(),
+		},
+	}
+	m1 := NewBGPRouteRefreshORFMessage(RF_IPv4_UC, ORF_WHEN_DEFER, entries)
+	buf1, err := m1.Serialize()
+	assert.Nil(err)
+	m2, err := ParseBGPMessage(buf1)
+	assert.Nil(err)
+	rr := m2.Body.(*BGPRouteRefresh)
+	assert.Equal(RF_IPv4_UC, AfiSafiToRouteFamily(rr.AFI, rr.SAFI))
+	assert.Equal(ORF_WHEN_DEFER, rr.When)
+	assert.Equal(1, len(rr.ORFs))
+	assert.Equal(ORF_TYPE_ADDRESS_PREFIX, rr.ORFs[0].Type)
+	assert.Equal(entries, rr.ORFs[0].Entries)
+	buf2, err := m2.Serialize()
+	assert.Nil(err)
+	assert.Equal(buf1, buf2)
+
+	// prefix length beyond the address family
+	buf1[len(buf1)-4] = 33
+	_, err = ParseBGPMessage(buf1)
+	assert.NotNil(err)
+}
+
+func Test_EndOfRib(t *testing.T) {
+	assert := assert.New(t)
+	for _, rf := range []RouteFamily{RF_IPv4_UC, RF_IPv6_UC, RF_EVPN} {
+		buf, err := NewEndOfRib(rf).Serialize()
+		assert.Nil(err)
+		m, err := ParseBGPMessage(buf)
+		assert.Nil(err)
+		u := m.Body.(*BGPUpdate)
+		eor, family := u.IsEndOfRib()
+		assert.True(eor)
+		assert.Equal(rf, family)
+		assert.Equal(0, len(u.NLRI))
+		assert.Equal(0, len(u.WithdrawnRoutes))
+		if rf == RF_IPv4_UC {
+			assert.Equal(0, len(u.PathAttributes))
+			continue
+		}
+		assert.Equal(1, len(u.PathAttributes))
+		unreach := u.PathAttributes[0].(*PathAttributeMpUnreachNLRI)
+		assert.Equal(rf, AfiSafiToRouteFamily(unreach.AFI, unreach.SAFI))
+		assert.Equal(0, len(unreach.Value))
+	}
+}
+
+func Test_CapExtendedNexthop(t *testing.T) {
+	assert := assert.New(t)
+	c1 := NewCapExtendedNexthop([]CapExtendedNexthopTuple{{AFI_IP, SAFI_UNICAST, AFI_IP6}})
+	buf, err := c1.Serialize()
+	assert.Nil(err)
+	assert.Equal(2+6, len(buf))
+	c2, err := DecodeCapability(buf)
+	assert.Nil(err)
+	assert.Equal(c1.Tuples, c2.(*CapExtendedNexthop).Tuples)
+	assert.True(c2.(*CapExtendedNexthop).Has(RF_IPv4_UC, AFI_IP6))
+	assert.False(c2.(*CapExtendedNexthop).Has(RF_IPv4_VPN, AFI_IP6))
+	assert.Equal("BGP_CAP_EXTENDED_NEXTHOP", BGP_CAP_EXTENDED_NEXTHOP.String())
+
+	buf[1] = 4
+	_, err = DecodeCapability(buf)
+	assert.NotNil(err)
+}
+
+func Test_MpReachNLRIWithIPv6NexthopForIPv4(t *testing.T) {
+	assert := assert.New(t)
+	for _, linkLocal := range []net.IP{nil, net.ParseIP("fe80::1")} {
+		p1 := NewPathAttributeMpReachNLRI("2001:db8::1", []AddrPrefixInterface{NewIPAddrPrefix(24, "10.10.10.0")})
+		p1.LinkLocalNexthop = linkLocal
+		buf, err := p1.Serialize()
+		assert.Nil(err)
+		p2 := &PathAttributeMpReachNLRI{}
+		assert.Nil(p2.DecodeFromBytes(buf))
+		assert.Equal(uint16(AFI_IP), p2.AFI)
+		assert.Equal("2001:db8::1", p2.Nexthop.String())
+		assert.Equal(linkLocal.String(), p2.LinkLocalNexthop.String())
+		assert.Equal("10.10.10.0/24", p2.Value[0].String())
+	}
+}
+
+func Test_AddPathDecode(t *testing.T) {
+	assert := assert.New(t)
+	withId := func(id uint32, p AddrPrefixInterface) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, id)
+		b, _ := p.Serialize()
+		return append(buf, b...)
+	}
+
+	mp := []byte{0, AFI_IP6, SAFI_UNICAST, 16}
+	mp = append(mp, net.ParseIP("2001:db8::1")...)
+	mp = append(mp, 0)
+	mp = append(mp, withId(10, NewIPv6AddrPrefix(64, "2001:db8:1::"))...)
+	mp = append(mp, withId(20, NewIPv6AddrPrefix(64, "2001:db8:1::"))...)
+	attrs := []byte{byte(BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_EXTENDED_LENGTH), byte(BGP_ATTR_TYPE_MP_REACH_NLRI), 0, byte(len(mp))}
+	attrs = append(attrs, mp...)
+	for _, a := range []PathAttributeInterface{
+		NewPathAttributeOrigin(0),
+		NewPathAttributeAsPath([]AsPathParamInterface{NewAs4PathParam(2, []uint32{65001})}),
+		NewPathAttributeNextHop("10.0.0.1"),
+	} {
+		b, _ := a.Serialize()
+		attrs = append(attrs, b...)
+	}
+
+	withdrawn := withId(3, NewIPAddrPrefix(24, "10.10.20.0"))
+	body := []byte{0, byte(len(withdrawn))}
+	body = append(body, withdrawn...)
+	body = append(body, 0, byte(len(attrs)))
+	body = append(body, attrs...)
+	body = append(body, withId(1, NewIPAddrPrefix(24, "10.10.10.0"))...)
+	body = append(body, withId(2, NewIPAddrPrefix(24, "10.10.10.0"))...)
+	h := &BGPHeader{Len: uint16(BGP_HEADER_LENGTH + len(body)), Type: BGP_MSG_UPDATE}
+	buf, _ := h.Serialize()
+	buf = append(buf, body...)
+
+	options := &MarshallingOption{AddPath: map[RouteFamily]BGPAddPathMode{
+		RF_IPv4_UC: BGP_ADD_PATH_RECEIVE,
+		RF_IPv6_UC: BGP_ADD_PATH_RECEIVE,
+	}}
+	m, err := ParseBGPMessage(buf, options)
+	assert.Nil(err)
+	u := m.Body.(*BGPUpdate)
+	assert.Equal(1, len(u.WithdrawnRoutes))
+	assert.Equal("10.10.20.0/24", u.WithdrawnRoutes[0].String())
+	assert.Equal(uint32(3), u.WithdrawnRoutes[0].PathIdentifier)
+	assert.Equal(2, len(u.NLRI))
+	for i, n := range u.NLRI {
+		assert.Equal("10.10.10.0/24", n.String())
+		assert.Equal(uint32(i+1), n.PathIdentifier)
+	}
+	var reach *PathAttributeMpReachNLRI
+	for _, a := range u.PathAttributes {
+		if a.GetType() == BGP_ATTR_TYPE_MP_REACH_NLRI {
+			reach = a.(*PathAttributeMpReachNLRI)
+		}
+	}
+	assert.NotNil(reach)
+	assert.Equal(2, len(reach.Value))
+	for i, n := range reach.Value {
+		assert.Equal("2001:db8:1::/64", n.String())
+		assert.Equal(uint32((i+1)*10), n.(*IPv6AddrPrefix).PathIdentifier)
+	}
+
+	// the identifier isn't serialized
+	b, err := u.NLRI[0].Serialize()
+	assert.Nil(err)
+	assert.Equal(4, len(b))
+
+	// the path identifier is missing
+	body = []byte{0, 0, 0, 0, 24, 10, 10}
+	h = &BGPHeader{Len: uint16(BGP_HEADER_LENGTH + len(body)), Type: BGP_MSG_UPDATE}
+	buf, _ = h.Serialize()
+	_, err = ParseBGPMessage(append(buf, body...), options)
+	assert.NotNil(err)
+}
+
+func Test_CapPathsLimit(t *testing.T) {
+	assert := assert.New(t)
+	c1 := NewCapPathsLimit([]CapPathsLimitValue{{RF_IPv4_UC, 4}, {RF_IPv6_UC, 8}})
+	buf, err := c1.Serialize()
+	assert.Nil(err)
+	assert.Equal(2+10, len(buf))
+	c2, err := DecodeCapability(buf)
+	assert.Nil(err)
+	assert.Equal(c1.CapValue, c2.(*CapPathsLimit).CapValue)
+	assert.Equal(uint16(8), c2.(*CapPathsLimit).Limit(RF_IPv6_UC))
+	assert.Equal(uint16(0), c2.(*CapPathsLimit).Limit(RF_EVPN))
+	assert.Equal("BGP_CAP_PATHS_LIMIT", BGP_CAP_PATHS_LIMIT.String())
+
+	buf[1] = 4
+	_, err = DecodeCapability(buf)
+	assert.NotNil(err)
+}
+
+func Test_AddPathEncode(t *testing.T) {
+	assert := assert.New(t)
+	v4 := NewIPAddrPrefix(24, "10.10.10.0")
+	v4.PathIdentifier = 1
+	withdrawn := NewIPAddrPrefix(24, "10.10.20.0")
+	withdrawn.PathIdentifier = 3
+	v6 := NewIPv6AddrPrefix(64, "2001:db8:1::")
+	v6.PathIdentifier = 10
+	reach := NewPathAttributeMpReachNLRI("2001:db8::1", []AddrPrefixInterface{v6})
+	m := NewBGPUpdateMessage([]*IPAddrPrefix{withdrawn}, []PathAttributeInterface{
+		NewPathAttributeOrigin(0),
+		NewPathAttributeAsPath([]AsPathParamInterface{NewAs4PathParam(2, []uint32{65001})}),
+		NewPathAttributeNextHop("10.0.0.1"),
+		reach,
+	}, []*IPAddrPrefix{v4})
+
+	send := &MarshallingOption{AddPath: map[RouteFamily]BGPAddPathMode{
+		RF_IPv4_UC: BGP_ADD_PATH_SEND,
+		RF_IPv6_UC: BGP_ADD_PATH_SEND,
+	}}
+	buf, err := m.Serialize(send)
+	assert.Nil(err)
+	// the options of the shared attribute aren't changed
+	assert.Nil(reach.options)
+
+	receive := &MarshallingOption{AddPath: map[RouteFamily]BGPAddPathMode{
+		RF_IPv4_UC: BGP_ADD_PATH_RECEIVE,
+		RF_IPv6_UC: BGP_ADD_PATH_RECEIVE,
+	}}
+	m2, err := ParseBGPMessage(buf, receive)
+	assert.Nil(err)
+	u := m2.Body.(*BGPUpdate)
+	assert.Equal(1, len(u.WithdrawnRoutes))
+	assert.Equal(uint32(3), u.WithdrawnRoutes[0].PathIdentifier)
+	assert.Equal(1, len(u.NLRI))
+	assert.Equal("10.10.10.0/24", u.NLRI[0].String())
+	assert.Equal(uint32(1), u.NLRI[0].PathIdentifier)
+	for _, a := range u.PathAttributes {
+		if a.GetType() == BGP_ATTR_TYPE_MP_REACH_NLRI {
+			n := a.(*PathAttributeMpReachNLRI).Value[0].(*IPv6AddrPrefix)
+			assert.Equal("2001:db8:1::/64", n.String())
+			assert.Equal(uint32(10), n.PathIdentifier)
+		}
+	}
+
+	// without the options, the identifiers aren't serialized
+	plain, err := m.Serialize()
+	assert.Nil(err)
+	assert.Equal(len(buf)-3*4, len(plain))
+	_, err = ParseBGPMessage(plain)
+	assert.Nil(err)
+}
+
+func Test_ReservedCommunity(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(IsReservedCommunity(COMMUNITY_NO_EXPORT))
+	assert.True(IsWellKnownCommunity(COMMUNITY_NO_EXPORT))
+	assert.True(IsReservedCommunity(0xffff1234))
+	assert.False(IsWellKnownCommunity(0xffff1234))
+	assert.False(IsReservedCommunity(65000<<16 | 100))
+	assert.False(IsWellKnownCommunity(65000<<16 | 100))
+}
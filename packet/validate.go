@@ -37,19 +37,17 @@ func ValidateUpdateMsg(m *BGPUpdate, rfs map[RouteFamily]bool, doConfedCheck boo
 		}
 	}
 
+	// check the existence of well-known mandatory attributes. NEXT_HOP
+	// is mandatory only with the IPv4 NLRI, the NLRI in MP_REACH_NLRI
+	// carries its own next hop (RFC4760).
+	var mandatory []BGPAttrType
 	if len(m.NLRI) > 0 {
-		// check the existence of well-known mandatory attributes
-		exist := func(attrs []BGPAttrType) (bool, BGPAttrType) {
-			for _, attr := range attrs {
-				_, ok := seen[attr]
-				if !ok {
-					return false, attr
-				}
-			}
-			return true, 0
-		}
-		mandatory := []BGPAttrType{BGP_ATTR_TYPE_ORIGIN, BGP_ATTR_TYPE_AS_PATH, BGP_ATTR_TYPE_NEXT_HOP}
-		if ok, t := exist(mandatory); !ok {
+		mandatory = []BGPAttrType{BGP_ATTR_TYPE_ORIGIN, BGP_ATTR_TYPE_AS_PATH, BGP_ATTR_TYPE_NEXT_HOP}
+	} else if _, ok := seen[BGP_ATTR_TYPE_MP_REACH_NLRI]; ok {
+		mandatory = []BGPAttrType{BGP_ATTR_TYPE_ORIGIN, BGP_ATTR_TYPE_AS_PATH}
+	}
+	for _, t := range mandatory {
+		if _, ok := seen[t]; !ok {
 			eMsg := "well-known mandatory attributes are not present. type : " + strconv.Itoa(int(t))
 			data := []byte{byte(t)}
 			return false, NewMessageError(eCode, eSubCodeMissing, data, eMsg)
@@ -193,8 +191,12 @@ func ValidateBGPMessage(m *BGPMessage) error {
 }
 
 func ValidateOpenMsg(m *BGPOpen, expectedAS uint32) error {
-	if m.Version != 4 {
-		return NewMessageError(BGP_ERROR_OPEN_MESSAGE_ERROR, BGP_ERROR_SUB_UNSUPPORTED_VERSION_NUMBER, nil, fmt.Sprintf("upsuppored version %d", m.Version))
+	if m.Version != BGP_VERSION {
+		// the data is the largest locally supported version number
+		// (RFC 4271 6.2), the only one we support
+		data := make([]byte, 2)
+		binary.BigEndian.PutUint16(data, BGP_VERSION)
+		return NewMessageError(BGP_ERROR_OPEN_MESSAGE_ERROR, BGP_ERROR_SUB_UNSUPPORTED_VERSION_NUMBER, data, fmt.Sprintf("unsupported version %d", m.Version))
 	}
 
 	as := uint32(m.MyAS)
@@ -60,6 +60,20 @@ func Test_Validate_CapV6(t *testing.T) {
 	assert.Equal(false, res)
 }
 
+func Test_Validate_OpenVersion(t *testing.T) {
+	assert := assert.New(t)
+	open := NewBGPOpenMessage(65001, 90, "10.0.0.1", nil).Body.(*BGPOpen)
+	assert.NoError(ValidateOpenMsg(open, 65001))
+
+	open.Version = 3
+	err := ValidateOpenMsg(open, 65001)
+	assert.Error(err)
+	e := err.(*MessageError)
+	assert.Equal(uint8(BGP_ERROR_OPEN_MESSAGE_ERROR), e.TypeCode)
+	assert.Equal(uint8(BGP_ERROR_SUB_UNSUPPORTED_VERSION_NUMBER), e.SubTypeCode)
+	assert.Equal([]byte{0, 4}, e.Data)
+}
+
 func Test_Validate_OK(t *testing.T) {
 	assert := assert.New(t)
 	message := bgpupdate().Body.(*BGPUpdate)
@@ -185,6 +199,37 @@ func Test_Validate_mandatory_missing_nocheck(t *testing.T) {
 	assert.NoError(err)
 }
 
+func Test_Validate_mandatory_missing_nexthop(t *testing.T) {
+	assert := assert.New(t)
+	message := bgpupdate().Body.(*BGPUpdate)
+	message.PathAttributes = message.PathAttributes[:2]
+	res, err := ValidateUpdateMsg(message, map[RouteFamily]bool{RF_IPv4_UC: true}, false)
+	assert.Equal(false, res)
+	assert.Error(err)
+	e := err.(*MessageError)
+	assert.Equal(uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR), e.TypeCode)
+	assert.Equal(uint8(BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE), e.SubTypeCode)
+	assert.Equal([]byte{byte(BGP_ATTR_TYPE_NEXT_HOP)}, e.Data)
+}
+
+func Test_Validate_mandatory_missing_mp(t *testing.T) {
+	assert := assert.New(t)
+	// the NLRI in MP_REACH_NLRI doesn't need NEXT_HOP
+	message := bgpupdateV6().Body.(*BGPUpdate)
+	res, err := ValidateUpdateMsg(message, map[RouteFamily]bool{RF_IPv6_UC: true}, false)
+	assert.Equal(true, res)
+	assert.NoError(err)
+
+	// but needs the other well-known mandatory attributes
+	message.PathAttributes = message.PathAttributes[1:]
+	res, err = ValidateUpdateMsg(message, map[RouteFamily]bool{RF_IPv6_UC: true}, false)
+	assert.Equal(false, res)
+	assert.Error(err)
+	e := err.(*MessageError)
+	assert.Equal(uint8(BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE), e.SubTypeCode)
+	assert.Equal([]byte{byte(BGP_ATTR_TYPE_ORIGIN)}, e.Data)
+}
+
 func Test_Validate_invalid_origin(t *testing.T) {
 	assert := assert.New(t)
 	message := bgpupdate().Body.(*BGPUpdate)
@@ -0,0 +1,84 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+// admissionGate bounds the number of peers exchanging OPEN messages
+// at once. Peers over the limit wait in a queue and their FSM handlers
+// aren't started until a slot becomes free, deferring our OPEN on the
+// connection they hold. It's only used from the server goroutine so it
+// needs no locking.
+type admissionGate struct {
+	active map[*Peer]struct{}
+	queue  []*Peer
+}
+
+func newAdmissionGate() *admissionGate {
+	return &admissionGate{
+		active: make(map[*Peer]struct{}),
+		queue:  make([]*Peer, 0),
+	}
+}
+
+// acquire takes a slot for the peer and returns true, or queues the
+// peer and returns false when max slots are already taken. Zero max
+// means unlimited.
+func (g *admissionGate) acquire(max uint32, peer *Peer) bool {
+	if _, y := g.active[peer]; y {
+		return true
+	}
+	if max == 0 || len(g.active) < int(max) {
+		g.active[peer] = struct{}{}
+		return true
+	}
+	for _, p := range g.queue {
+		if p == peer {
+			return false
+		}
+	}
+	g.queue = append(g.queue, peer)
+	return false
+}
+
+// release frees the slot or the queue entry of the peer, and returns
+// the queued peers which can take the free slots now.
+func (g *admissionGate) release(max uint32, peer *Peer) []*Peer {
+	delete(g.active, peer)
+	g.dequeue(peer)
+	admitted := make([]*Peer, 0)
+	for len(g.queue) > 0 && (max == 0 || len(g.active) < int(max)) {
+		p := g.queue[0]
+		g.queue = g.queue[1:]
+		g.active[p] = struct{}{}
+		admitted = append(admitted, p)
+	}
+	return admitted
+}
+
+// dequeue removes the peer from the queue and tells whether it was
+// queued.
+func (g *admissionGate) dequeue(peer *Peer) bool {
+	for i, p := range g.queue {
+		if p == peer {
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (g *admissionGate) counts() (uint32, uint32) {
+	return uint32(len(g.active)), uint32(len(g.queue))
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestAdmissionGate(t *testing.T) {
+	assert := assert.New(t)
+	g := newAdmissionGate()
+	p1, p2, p3 := &Peer{}, &Peer{}, &Peer{}
+
+	assert.True(g.acquire(1, p1))
+	assert.True(g.acquire(1, p1))
+	assert.False(g.acquire(1, p2))
+	assert.False(g.acquire(1, p3))
+	assert.False(g.acquire(1, p2))
+	active, queued := g.counts()
+	assert.Equal(uint32(1), active)
+	assert.Equal(uint32(2), queued)
+
+	// a queued peer going away doesn't free a slot
+	assert.Equal(0, len(g.release(1, p3)))
+	active, queued = g.counts()
+	assert.Equal(uint32(1), active)
+	assert.Equal(uint32(1), queued)
+
+	admitted := g.release(1, p1)
+	assert.Equal([]*Peer{p2}, admitted)
+	active, queued = g.counts()
+	assert.Equal(uint32(1), active)
+	assert.Equal(uint32(0), queued)
+
+	assert.True(g.acquire(1, p2))
+	assert.False(g.acquire(1, p3))
+	assert.True(g.dequeue(p3))
+	assert.False(g.dequeue(p3))
+	_, queued = g.counts()
+	assert.Equal(uint32(0), queued)
+
+	// zero means unlimited
+	assert.True(g.acquire(0, p1))
+	assert.True(g.acquire(0, p3))
+	active, _ = g.counts()
+	assert.Equal(uint32(3), active)
+}
+
+func TestDequeueAdmission(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	server.fsmincomingCh = make(chan *FsmMsg, 16)
+	server.fsmStateCh = make(chan *FsmMsg, 16)
+	server.bgpConfig.Global.Config.MaxEstablishingPeers = 1
+	active := newTestPeer(server, testNeighbor("10.0.0.1", 65001), rfList)
+	queued := newTestPeer(server, testNeighbor("10.0.0.2", 65002), rfList)
+	assert.True(server.admission.acquire(1, active))
+	conn, remote := net.Pipe()
+	queued.fsm.conn = conn
+	queued.fsm.state = bgp.BGP_FSM_OPENSENT
+	assert.False(server.admission.acquire(1, queued))
+	assert.False(server.dequeueAdmission(active))
+
+	// the held connection is closed and the queued peer goes down
+	server.handleShutdown()
+	_, err := remote.Read(make([]byte, bgp.BGP_HEADER_LENGTH))
+	assert.Equal(io.EOF, err)
+	established, waiting := server.admission.counts()
+	assert.Equal(uint32(1), established)
+	assert.Equal(uint32(0), waiting)
+	e := <-server.fsmStateCh
+	assert.Equal(bgp.BGP_FSM_IDLE, e.MsgData.(bgp.FSMState))
+}
@@ -0,0 +1,217 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"time"
+)
+
+// defaultRouteConfig is the configuration of the default route
+// originated to the peer for a family.
+type defaultRouteConfig struct {
+	condition   string
+	med         uint32
+	communities []string
+}
+
+// defaultRouteConfig returns the configuration of the default route
+// originated to the peer for the family, or nil when it isn't.
+func (peer *Peer) defaultRouteConfig(rf bgp.RouteFamily) *defaultRouteConfig {
+	for _, af := range peer.conf.AfiSafis {
+		if family, _ := bgp.GetRouteFamily(string(af.AfiSafiName)); family != rf {
+			continue
+		}
+		switch rf {
+		case bgp.RF_IPv4_UC:
+			if c := af.Ipv4Unicast.Config; c.SendDefaultRoute {
+				return &defaultRouteConfig{
+					condition:   c.SendDefaultRouteCondition,
+					med:         c.SendDefaultRouteMed,
+					communities: c.SendDefaultRouteCommunityList,
+				}
+			}
+		case bgp.RF_IPv6_UC:
+			if c := af.Ipv6Unicast.Config; c.SendDefaultRoute {
+				return &defaultRouteConfig{
+					condition:   c.SendDefaultRouteCondition,
+					med:         c.SendDefaultRouteMed,
+					communities: c.SendDefaultRouteCommunityList,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func isDefaultRoute(path *table.Path) bool {
+	switch n := path.GetNlri().(type) {
+	case *bgp.IPAddrPrefix:
+		return n.Length == 0
+	case *bgp.IPv6AddrPrefix:
+		return n.Length == 0
+	}
+	return false
+}
+
+// originatesDefaultRoute tells whether the path is the default route
+// originated to the peer, rather than one selected in the global RIB.
+func (peer *Peer) originatesDefaultRoute(path *table.Path) bool {
+	_, ok := peer.defaultRoutes[path.GetRouteFamily()]
+	return ok && isDefaultRoute(path)
+}
+
+// newDefaultRoute builds the default route of the family originated to
+// the peer. Its attributes are updated for the peer as the paths
+// selected in the global RIB, but the export policy doesn't apply.
+func (peer *Peer) newDefaultRoute(rf bgp.RouteFamily, c *defaultRouteConfig) *table.Path {
+	var nlri bgp.AddrPrefixInterface
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(bgp.BGP_ORIGIN_ATTR_TYPE_IGP),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+	}
+	if rf == bgp.RF_IPv4_UC {
+		nlri = bgp.NewIPAddrPrefix(0, "0.0.0.0")
+		attrs = append(attrs, bgp.NewPathAttributeNextHop("0.0.0.0"))
+	} else {
+		nlri = bgp.NewIPv6AddrPrefix(0, "::")
+		attrs = append(attrs, bgp.NewPathAttributeMpReachNLRI("::", []bgp.AddrPrefixInterface{nlri}))
+	}
+	if c.med > 0 {
+		attrs = append(attrs, bgp.NewPathAttributeMultiExitDisc(c.med))
+	}
+	if len(c.communities) > 0 {
+		communities := make([]uint32, 0, len(c.communities))
+		for _, s := range c.communities {
+			v, err := table.ParseCommunity(s)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"Topic":     "Peer",
+					"Key":       peer.conf.Config.NeighborAddress,
+					"Community": s,
+				}).Warn("invalid community of the default route, ignore")
+				continue
+			}
+			communities = append(communities, v)
+		}
+		if len(communities) > 0 {
+			attrs = append(attrs, bgp.NewPathAttributeCommunities(communities))
+		}
+	}
+	path := table.NewPath(table.NewLocalPeerInfo(&peer.gConf), nlri, false, attrs, time.Now(), false)
+	path.UpdatePathAttrs(&peer.gConf, &peer.conf)
+	return path
+}
+
+// defaultRouteConditionMet tells whether a best path of the family
+// matching the condition prefix set exists in the global RIB.
+func (peer *Peer) defaultRouteConditionMet(rf bgp.RouteFamily, c *defaultRouteConfig) bool {
+	if c.condition == "" {
+		return true
+	}
+	cond, err := table.NewPrefixCondition(config.MatchPrefixSet{PrefixSet: c.condition}, peer.policy.DefinedSetMap[table.DEFINED_TYPE_PREFIX])
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Topic":  "Peer",
+			"Key":    peer.conf.Config.NeighborAddress,
+			"Family": rf,
+			"Error":  err,
+		}).Warn("invalid condition of the default route, consider it unmet")
+		return false
+	}
+	for _, path := range peer.localRib.GetBestPathList(table.GLOBAL_RIB_NAME, []bgp.RouteFamily{rf}) {
+		if cond.Evaluate(path, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultRouteConditionChanged tells whether the result of the
+// condition could have changed with the best paths changed.
+func (peer *Peer) defaultRouteConditionChanged(rf bgp.RouteFamily, c *defaultRouteConfig, changed []*table.Path) bool {
+	if c.condition == "" {
+		return false
+	}
+	cond, err := table.NewPrefixCondition(config.MatchPrefixSet{PrefixSet: c.condition}, peer.policy.DefinedSetMap[table.DEFINED_TYPE_PREFIX])
+	if err != nil {
+		return false
+	}
+	for _, path := range changed {
+		if path.GetRouteFamily() == rf && cond.Evaluate(path, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateDefaultRoutes originates or withdraws the default route to the
+// peer for each family as the configuration and the condition require.
+// With the best paths changed in the global RIB, only the families
+// whose condition they affect are checked, otherwise all of them. It
+// returns the UPDATE messages for the changes.
+func (peer *Peer) updateDefaultRoutes(changed []*table.Path) []*bgp.BGPMessage {
+	if peer.isRouteServerClient() || peer.isReceiveOnly() || peer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+		return nil
+	}
+	pathList := make([]*table.Path, 0)
+	for _, rf := range []bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC} {
+		if !peer.fsm.HasFamily(rf) {
+			continue
+		}
+		c := peer.defaultRouteConfig(rf)
+		if c != nil && changed != nil && !peer.defaultRouteConditionChanged(rf, c, changed) {
+			continue
+		}
+		old, originated := peer.defaultRoutes[rf]
+		if c != nil && peer.defaultRouteConditionMet(rf, c) {
+			if originated {
+				continue
+			}
+			if peer.defaultRoutes == nil {
+				peer.defaultRoutes = make(map[bgp.RouteFamily]*table.Path)
+			}
+			path := peer.newDefaultRoute(rf, c)
+			peer.defaultRoutes[rf] = path
+			// the default route held from the global RIB before it
+			// was configured mustn't replace the originated one
+			peer.updateBatch.discard(func(p *table.Path) bool {
+				return p.GetRouteFamily() == rf && isDefaultRoute(p)
+			})
+			pathList = append(pathList, path)
+			log.WithFields(log.Fields{
+				"Topic":  "Peer",
+				"Key":    peer.conf.Config.NeighborAddress,
+				"Family": rf,
+			}).Info("originate the default route")
+		} else if originated {
+			delete(peer.defaultRoutes, rf)
+			pathList = append(pathList, old.Clone(true))
+			log.WithFields(log.Fields{
+				"Topic":  "Peer",
+				"Key":    peer.conf.Config.NeighborAddress,
+				"Family": rf,
+			}).Info("withdraw the default route")
+		}
+	}
+	if len(pathList) == 0 {
+		return nil
+	}
+	return peer.advertise(pathList)
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func newDefaultRoutePeer(v4, v6 config.AfiSafi) (*Peer, *table.TableManager, *table.RoutingPolicy) {
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC}
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{
+		Config: config.NeighborConfig{
+			NeighborAddress: "10.0.0.2",
+			PeerAs:          65002,
+			PeerType:        config.PEER_TYPE_EXTERNAL,
+		},
+		Transport: config.Transport{Config: config.TransportConfig{LocalAddress: "10.0.0.254"}},
+		AfiSafis:  []config.AfiSafi{v4, v6},
+	}
+	rib := table.NewTableManager(rfList, 0, 0)
+	policy := table.NewRoutingPolicy()
+	policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	p := NewPeer(g, n, rib, policy)
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.fsm.rfMap[bgp.RF_IPv6_UC] = true
+	return p, rib, policy
+}
+
+func TestDefaultRouteIPv4Only(t *testing.T) {
+	assert := assert.New(t)
+	v4 := config.AfiSafi{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}
+	v4.Ipv4Unicast.Config = config.Ipv4UnicastConfig{
+		SendDefaultRoute:              true,
+		SendDefaultRouteMed:           10,
+		SendDefaultRouteCommunityList: []string{"65000:100"},
+	}
+	v6 := config.AfiSafi{AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST}
+	p, rib, _ := newDefaultRoutePeer(v4, v6)
+
+	msgs := p.updateDefaultRoutes(nil)
+	assert.Equal(1, len(msgs))
+	u := msgs[0].Body.(*bgp.BGPUpdate)
+	assert.Equal(1, len(u.NLRI))
+	assert.Equal("0.0.0.0/0", u.NLRI[0].String())
+	assert.Equal(1, p.adjRibOut.Count([]bgp.RouteFamily{bgp.RF_IPv4_UC}))
+	assert.Equal(0, p.adjRibOut.Count([]bgp.RouteFamily{bgp.RF_IPv6_UC}))
+
+	path := p.defaultRoutes[bgp.RF_IPv4_UC]
+	assert.Equal("10.0.0.254", path.GetNexthop().String())
+	assert.Equal("65000", path.GetAsString())
+	med, err := path.GetMed()
+	assert.Nil(err)
+	assert.Equal(uint32(10), med)
+	assert.Equal([]uint32{65000<<16 | 100}, path.GetCommunities())
+
+	// already originated
+	assert.Nil(p.updateDefaultRoutes(nil))
+
+	// the default route in the global RIB isn't advertised to the
+	// peer, but in the family without origination it is
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+	}
+	v4Default := table.NewPath(source, bgp.NewIPAddrPrefix(0, "0.0.0.0"), false, append(attrs, bgp.NewPathAttributeNextHop("10.0.0.1")), time.Now(), false)
+	v6Nlri := bgp.NewIPv6AddrPrefix(0, "::")
+	v6Default := table.NewPath(source, v6Nlri, false, append(attrs, bgp.NewPathAttributeMpReachNLRI("2001:db8::1", []bgp.AddrPrefixInterface{v6Nlri})), time.Now(), false)
+	assert.Nil(filterpath(p, v4Default))
+	assert.NotNil(filterpath(p, v6Default))
+
+	// the soft reset keeps the originated default route
+	rib.ProcessPaths([]*table.Path{v4Default})
+	assert.Equal(0, len(p.getOutboundDelta([]bgp.RouteFamily{bgp.RF_IPv4_UC})))
+
+	// the session went down
+	p.defaultRoutes = nil
+	p.fsm.state = bgp.BGP_FSM_IDLE
+	assert.Nil(p.updateDefaultRoutes(nil))
+}
+
+func TestDefaultRouteCondition(t *testing.T) {
+	assert := assert.New(t)
+	v4 := config.AfiSafi{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}
+	v4.Ipv4Unicast.Config = config.Ipv4UnicastConfig{
+		SendDefaultRoute:          true,
+		SendDefaultRouteCondition: "tracked",
+	}
+	v6 := config.AfiSafi{AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST}
+	v6.Ipv6Unicast.Config = config.Ipv6UnicastConfig{
+		SendDefaultRoute: true,
+	}
+	p, rib, policy := newDefaultRoutePeer(v4, v6)
+
+	// the condition prefix set doesn't exist
+	msgs := p.updateDefaultRoutes(nil)
+	assert.Equal(1, len(msgs))
+	assert.Equal(1, p.adjRibOut.Count([]bgp.RouteFamily{bgp.RF_IPv6_UC}))
+	assert.Equal(0, p.adjRibOut.Count([]bgp.RouteFamily{bgp.RF_IPv4_UC}))
+
+	set, err := table.NewPrefixSet(config.PrefixSet{
+		PrefixSetName: "tracked",
+		PrefixList:    []config.Prefix{{IpPrefix: "10.10.0.0/16"}},
+	})
+	assert.Nil(err)
+	policy.DefinedSetMap[table.DEFINED_TYPE_PREFIX] = map[string]table.DefinedSet{"tracked": set}
+	assert.Nil(p.updateDefaultRoutes(nil))
+
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string, length uint8, withdraw bool) *table.Path {
+		return table.NewPath(source, bgp.NewIPAddrPrefix(length, prefix), withdraw, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}, time.Now(), false)
+	}
+	changed := func(pathList ...*table.Path) []*table.Path {
+		l := make([]*table.Path, 0)
+		for _, dst := range rib.ProcessPaths(pathList) {
+			if p := dst.NewFeed(table.GLOBAL_RIB_NAME); p != nil {
+				l = append(l, p)
+			}
+		}
+		return l
+	}
+
+	// a change out of the prefix set doesn't matter
+	assert.Nil(p.updateDefaultRoutes(changed(path("10.20.0.0", 16, false))))
+
+	// the tracked prefix appears
+	msgs = p.updateDefaultRoutes(changed(path("10.10.0.0", 16, false)))
+	assert.Equal(1, len(msgs))
+	u := msgs[0].Body.(*bgp.BGPUpdate)
+	assert.Equal(1, len(u.NLRI))
+	assert.Equal("0.0.0.0/0", u.NLRI[0].String())
+	assert.Equal(1, p.adjRibOut.Count([]bgp.RouteFamily{bgp.RF_IPv4_UC}))
+
+	// the tracked prefix disappears, only the IPv4 default route is
+	// withdrawn
+	msgs = p.updateDefaultRoutes(changed(path("10.10.0.0", 16, true)))
+	assert.Equal(1, len(msgs))
+	u = msgs[0].Body.(*bgp.BGPUpdate)
+	assert.Equal(1, len(u.WithdrawnRoutes))
+	assert.Equal("0.0.0.0/0", u.WithdrawnRoutes[0].String())
+	assert.Equal(0, p.adjRibOut.Count([]bgp.RouteFamily{bgp.RF_IPv4_UC}))
+	assert.Equal(1, p.adjRibOut.Count([]bgp.RouteFamily{bgp.RF_IPv6_UC}))
+	_, ok := p.defaultRoutes[bgp.RF_IPv6_UC]
+	assert.True(ok)
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/packet"
+	"time"
+)
+
+// selectionDeferral defers the route selection after the start until
+// all the graceful restart capable peers send End-of-RIB or the
+// deferral time passes (RFC4724 4.1). Meanwhile the routes received
+// are only kept in the Adj-RIB-In of the peers.
+type selectionDeferral struct {
+	timer    *time.Timer
+	endOfRib map[string]map[bgp.RouteFamily]bool
+}
+
+func (server *BgpServer) startSelectionDeferral(d time.Duration) {
+	deferral := &selectionDeferral{
+		endOfRib: make(map[string]map[bgp.RouteFamily]bool),
+	}
+	ch := server.deferralCh
+	deferral.timer = time.AfterFunc(d, func() {
+		ch <- deferral
+	})
+	server.deferral = deferral
+	log.WithFields(log.Fields{
+		"Topic":    "Server",
+		"Duration": d,
+	}).Info("defer route selection until End-of-RIB is received")
+}
+
+func (d *selectionDeferral) recordEndOfRib(peer *Peer, rf bgp.RouteFamily) {
+	addr := peer.conf.Config.NeighborAddress
+	if _, ok := d.endOfRib[addr]; !ok {
+		d.endOfRib[addr] = make(map[bgp.RouteFamily]bool)
+	}
+	d.endOfRib[addr][rf] = true
+}
+
+// waiting tells whether the route selection still waits for
+// End-of-RIB from the peer.
+func (d *selectionDeferral) waiting(peer *Peer) bool {
+	if !peer.conf.GracefulRestart.Config.Enabled {
+		return false
+	}
+	if peer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+		return true
+	}
+	caps := peer.fsm.Capability(bgp.BGP_CAP_GRACEFUL_RESTART)
+	if len(caps) == 0 {
+		return false
+	}
+	// the peer restarting too defers its End-of-RIB until it
+	// selects its routes
+	if caps[0].(*bgp.CapGracefulRestart).CapValue.Flags&bgp.BGP_CAP_GRACEFUL_RESTART_FLAG_RESTART != 0 {
+		return false
+	}
+	received := d.endOfRib[peer.conf.Config.NeighborAddress]
+	for _, rf := range peer.fsm.Families() {
+		if !received[rf] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSelectionDeferral ends the deferral when End-of-RIB isn't
+// waited for from any peer.
+func (server *BgpServer) checkSelectionDeferral() []*SenderMsg {
+	for _, peer := range server.neighborMap {
+		if server.deferral.waiting(peer) {
+			return nil
+		}
+	}
+	return server.endSelectionDeferral("End-of-RIB received from all the peers")
+}
+
+// releaseSelectionDeferral ends the deferral when its time expired.
+func (server *BgpServer) releaseSelectionDeferral(d *selectionDeferral) []*SenderMsg {
+	// the deferral could have ended after the timer fired
+	if server.deferral != d {
+		return nil
+	}
+	return server.endSelectionDeferral("deferral time expired")
+}
+
+// endSelectionDeferral selects the routes kept in the Adj-RIB-In of
+// the peers and advertises the result to the established peers.
+func (server *BgpServer) endSelectionDeferral(reason string) []*SenderMsg {
+	server.deferral.timer.Stop()
+	server.deferral = nil
+	log.WithFields(log.Fields{
+		"Topic": "Server",
+	}).Infof("%s, select routes", reason)
+
+	msgs := make([]*SenderMsg, 0)
+	for _, peer := range server.neighborMap {
+		if pathList := peer.adjRibIn.PathList(peer.configuredRFlist(), true); len(pathList) > 0 {
+			m, _ := server.propagateUpdate(peer, pathList)
+			msgs = append(msgs, m...)
+		}
+	}
+	for _, peer := range server.neighborMap {
+		if peer.fsm.state == bgp.BGP_FSM_ESTABLISHED {
+			msgs = append(msgs, server.softResetOut(peer, peer.configuredRFlist())...)
+			if l := peer.updateDefaultRoutes(nil); len(l) > 0 {
+				msgs = append(msgs, newSenderMsg(peer, l))
+			}
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSelectionDeferral(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server.globalRib = table.NewTableManager(rfList, 0, 0)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IMPORT, table.ROUTE_TYPE_ACCEPT)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	server.bgpConfig.Global = g
+	newPeer := func(addr string, as uint32, gr bool) *Peer {
+		n := config.Neighbor{
+			Config:   config.NeighborConfig{NeighborAddress: addr, PeerAs: as, PeerType: config.PEER_TYPE_EXTERNAL},
+			AfiSafis: []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}},
+		}
+		n.GracefulRestart.Config.Enabled = gr
+		p := NewPeer(g, n, server.globalRib, server.policy)
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		p.adjRibIn = table.NewAdjRib(p.ID(), rfList)
+		p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+		server.neighborMap[addr] = p
+		return p
+	}
+	source := newPeer("10.0.0.1", 65001, true)
+	target := newPeer("10.0.0.2", 65002, false)
+	target.fsm.state = bgp.BGP_FSM_ESTABLISHED
+
+	server.startSelectionDeferral(time.Hour)
+	deferral := server.deferral
+	// the peer isn't established yet
+	assert.True(deferral.waiting(source))
+	assert.False(deferral.waiting(target))
+	source.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	source.fsm.capMap[bgp.BGP_CAP_GRACEFUL_RESTART] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapGracefulRestart(0, 120, []bgp.CapGracefulRestartTuples{{AFI: bgp.AFI_IP, SAFI: bgp.SAFI_UNICAST}}),
+	}
+	assert.True(deferral.waiting(source))
+
+	update := bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")})
+	msgs := server.handleFSMMessage(source, &FsmMsg{
+		MsgType:  FSM_MSG_BGP_MESSAGE,
+		MsgSrc:   source.conf.Config.NeighborAddress,
+		MsgData:  update,
+		PathList: table.ProcessMessage(update, source.fsm.peerInfo, time.Now()),
+	})
+	// kept in the Adj-RIB-In only
+	assert.Equal(0, len(msgs))
+	assert.Equal(1, source.adjRibIn.Count(rfList))
+	assert.Equal(0, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, rfList)))
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+
+	eor := bgp.NewEndOfRib(bgp.RF_IPv4_UC)
+	msgs = server.handleFSMMessage(source, &FsmMsg{
+		MsgType:  FSM_MSG_BGP_MESSAGE,
+		MsgSrc:   source.conf.Config.NeighborAddress,
+		MsgData:  eor,
+		endOfRib: bgp.RF_IPv4_UC,
+	})
+	assert.Nil(server.deferral)
+	assert.Equal(1, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, rfList)))
+	assert.Equal(1, target.adjRibOut.Count(rfList))
+	sent := 0
+	for _, m := range msgs {
+		if m.destination == target.conf.Config.NeighborAddress {
+			sent += len(m.messages)
+		}
+	}
+	assert.Equal(1, sent)
+	// the timer fired after the deferral ended
+	assert.Nil(server.releaseSelectionDeferral(deferral))
+}
+
+func TestSelectionDeferralExpired(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server.globalRib = table.NewTableManager(rfList, 0, 0)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IMPORT, table.ROUTE_TYPE_ACCEPT)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{
+		Config:   config.NeighborConfig{NeighborAddress: "10.0.0.1", PeerAs: 65001, PeerType: config.PEER_TYPE_EXTERNAL},
+		AfiSafis: []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}},
+	}
+	n.GracefulRestart.Config.Enabled = true
+	p := NewPeer(g, n, server.globalRib, server.policy)
+	p.adjRibIn = table.NewAdjRib(p.ID(), rfList)
+	server.neighborMap["10.0.0.1"] = p
+
+	p.adjRibIn.Update([]*table.Path{table.NewPath(p.fsm.peerInfo, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}, time.Now(), false)})
+
+	server.startSelectionDeferral(10 * time.Millisecond)
+	select {
+	case d := <-server.deferralCh:
+		server.releaseSelectionDeferral(d)
+	case <-time.After(time.Second):
+		t.Fatal("the deferral didn't expire")
+	}
+	assert.Nil(server.deferral)
+	assert.Equal(1, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, rfList)))
+}
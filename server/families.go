@@ -0,0 +1,143 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+)
+
+// NeighborFamiliesResult reports the change made by
+// SetNeighborFamilies.
+type NeighborFamiliesResult struct {
+	Added   []bgp.RouteFamily
+	Removed []bgp.RouteFamily
+	// the session is reset to negotiate the new families
+	ResetRequired bool
+}
+
+// SetNeighborFamilies replaces the address families enabled for the
+// neighbor. The families are compared with the negotiated ones when
+// the session is established, otherwise with the configured ones.
+func (server *BgpServer) SetNeighborFamilies(addr string, families []bgp.RouteFamily) (*NeighborFamiliesResult, error) {
+	req := NewGrpcRequest(REQ_NEIGHBOR_FAMILIES, addr, bgp.RouteFamily(0), families)
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	if res.ResponseErr != nil {
+		return nil, res.ResponseErr
+	}
+	return res.Data.(*NeighborFamiliesResult), nil
+}
+
+func (server *BgpServer) setNeighborFamilies(peer *Peer, families []bgp.RouteFamily) (*NeighborFamiliesResult, []*SenderMsg, error) {
+	if len(families) == 0 {
+		return nil, nil, fmt.Errorf("at least one family must be enabled")
+	}
+	want := make(map[bgp.RouteFamily]bool, len(families))
+	rfList := make([]bgp.RouteFamily, 0, len(families))
+	for _, rf := range families {
+		if want[rf] {
+			continue
+		}
+		name, ok := bgp.AddressFamilyNameMap[rf]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown family: %d", rf)
+		}
+		if _, ok := config.AfiSafiTypeToIntMap[config.AfiSafiType(name)]; !ok {
+			return nil, nil, fmt.Errorf("unknown family: %s", name)
+		}
+		if _, ok := server.globalRib.Tables[rf]; !ok {
+			return nil, nil, fmt.Errorf("family %s isn't enabled globally", rf)
+		}
+		want[rf] = true
+		rfList = append(rfList, rf)
+	}
+
+	established := peer.fsm.state == bgp.BGP_FSM_ESTABLISHED
+	current := make(map[bgp.RouteFamily]bool)
+	if established {
+		for _, rf := range peer.fsm.Families() {
+			current[rf] = true
+		}
+	} else {
+		for _, rf := range peer.configuredRFlist() {
+			current[rf] = true
+		}
+	}
+
+	result := &NeighborFamiliesResult{}
+	for _, rf := range rfList {
+		if !current[rf] {
+			result.Added = append(result.Added, rf)
+		}
+	}
+	for _, rf := range peer.configuredRFlist() {
+		if !want[rf] && (current[rf] || !established) {
+			result.Removed = append(result.Removed, rf)
+		}
+	}
+	if len(result.Added) == 0 && len(result.Removed) == 0 {
+		return result, nil, nil
+	}
+
+	msgs := make([]*SenderMsg, 0)
+	if len(result.Removed) > 0 {
+		msgs = append(msgs, server.dropPeerRoutes(peer, result.Removed)...)
+		peer.DropAll(result.Removed)
+	}
+	peer.adjRibIn.AddFamilies(result.Added)
+	peer.adjRibOut.AddFamilies(result.Added)
+
+	configured := make(map[config.AfiSafiType]config.AfiSafi, len(peer.conf.AfiSafis))
+	for _, a := range peer.conf.AfiSafis {
+		configured[a.AfiSafiName] = a
+	}
+	afiSafis := make([]config.AfiSafi, 0, len(rfList))
+	for _, rf := range rfList {
+		name := config.AfiSafiType(bgp.AddressFamilyNameMap[rf])
+		a, ok := configured[name]
+		if !ok {
+			a = config.AfiSafi{
+				AfiSafiName: name,
+				Config: config.AfiSafiConfig{
+					AfiSafiName: name,
+					Enabled:     true,
+				},
+			}
+		}
+		afiSafis = append(afiSafis, a)
+	}
+	peer.conf.AfiSafis = afiSafis
+	peer.fsm.setAfiSafis(afiSafis)
+
+	// dynamic capability isn't supported, the session has to be
+	// reset to negotiate the families
+	if established {
+		result.ResetRequired = true
+		log.WithFields(log.Fields{
+			"Topic":   "Peer",
+			"Key":     peer.conf.Config.NeighborAddress,
+			"Added":   result.Added,
+			"Removed": result.Removed,
+		}).Info("families changed, reset the session")
+		peer.fsm.idleHoldTime = peer.conf.Timers.Config.IdleHoldTimeAfterReset
+		m := bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_OTHER_CONFIGURATION_CHANGE, nil)
+		msgs = append(msgs, newSenderMsg(peer, []*bgp.BGPMessage{m}))
+	}
+	return result, msgs, nil
+}
@@ -16,6 +16,7 @@
 package server
 
 import (
+	"encoding/hex"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/osrg/gobgp/config"
@@ -26,6 +27,9 @@ import (
 	"math/rand"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -80,6 +84,9 @@ const (
 	_ FsmMsgType = iota
 	FSM_MSG_STATE_CHANGE
 	FSM_MSG_BGP_MESSAGE
+	FSM_MSG_STALE_TIMER_EXPIRED
+	FSM_MSG_WITHDRAW_HOLD_EXPIRED
+	FSM_MSG_UPDATE_BATCH_EXPIRED
 )
 
 type FsmMsg struct {
@@ -90,6 +97,10 @@ type FsmMsg struct {
 	PathList  []*table.Path
 	timestamp time.Time
 	payload   []byte
+	// reason of FSM_MSG_STATE_CHANGE
+	reason FsmStateReason
+	// family of the End-of-RIB marker received
+	endOfRib bgp.RouteFamily
 }
 
 const (
@@ -97,6 +108,12 @@ const (
 	HOLDTIME_IDLE     = 5
 )
 
+const KEEPALIVE_PROBE_INTERVAL = time.Second
+
+// the time to reap the FSM goroutines is extended by this for each
+// message queued to the peer
+const FSM_REAP_TIME_PER_MESSAGE = time.Second
+
 type AdminState int
 
 const (
@@ -116,9 +133,14 @@ func (s AdminState) String() string {
 }
 
 type FSM struct {
+	// the messages queued to the peer in the server's sender, not
+	// handed to the outgoing channel yet, accessed atomically; first
+	// for the alignment
+	queued           int64
 	t                tomb.Tomb
 	gConf            *config.Global
 	pConf            *config.Neighbor
+	lock             sync.RWMutex
 	state            bgp.FSMState
 	reason           FsmStateReason
 	conn             net.Conn
@@ -128,10 +150,15 @@ type FSM struct {
 	adminState       AdminState
 	adminStateCh     chan AdminState
 	getActiveCh      chan struct{}
+	expireHoldCh     chan struct{}
+	deadlines        fsmDeadlines
 	h                *FSMHandler
 	rfMap            map[bgp.RouteFamily]bool
 	capMap           map[bgp.BGPCapabilityCode][]bgp.ParameterCapabilityInterface
+	marshalOption    *bgp.MarshallingOption
 	recvOpen         *bgp.BGPMessage
+	notification     *bgp.BGPNotification
+	notificationHold notificationHold
 	peerInfo         *table.PeerInfo
 	policy           *table.RoutingPolicy
 }
@@ -199,6 +226,7 @@ func NewFSM(gConf *config.Global, pConf *config.Neighbor, policy *table.RoutingP
 		adminState:       adminState,
 		adminStateCh:     make(chan AdminState, 1),
 		getActiveCh:      make(chan struct{}),
+		expireHoldCh:     make(chan struct{}, 1),
 		rfMap:            make(map[bgp.RouteFamily]bool),
 		capMap:           make(map[bgp.BGPCapabilityCode][]bgp.ParameterCapabilityInterface),
 		peerInfo:         table.NewPeerInfo(gConf, pConf),
@@ -208,21 +236,278 @@ func NewFSM(gConf *config.Global, pConf *config.Neighbor, policy *table.RoutingP
 	return fsm
 }
 
-func (fsm *FSM) StateChange(nextState bgp.FSMState) {
+func (fsm *FSM) dumpMessage(msg string, bufs ...[]byte) {
+	if !fsm.pConf.Config.DebugMessages {
+		return
+	}
+	b := make([]byte, 0, bgp.BGP_HEADER_LENGTH)
+	for _, buf := range bufs {
+		b = append(b, buf...)
+	}
+	state, _ := fsm.State()
+	log.WithFields(log.Fields{
+		"Topic": "Peer",
+		"Key":   fsm.pConf.Config.NeighborAddress,
+		"State": state,
+	}).Debugf("%s\n%s", msg, hex.Dump(b))
+}
+
+// pathAttributeFields returns the decoded attributes of the path as
+// log fields.
+func pathAttributeFields(path *table.Path) log.Fields {
+	return attributeFields(path.GetNlri(), path.IsWithdraw, path.GetPathAttrs())
+}
+
+// attributeFields returns the prefix and the decoded attributes as log
+// fields. It works on the attributes as they are on the wire, so it
+// can be used for the messages sent to the peer as well.
+func attributeFields(nlri bgp.AddrPrefixInterface, withdraw bool, attrs []bgp.PathAttributeInterface) log.Fields {
+	fields := log.Fields{
+		"Prefix":   nlri.String(),
+		"Withdraw": withdraw,
+	}
+	if withdraw {
+		return fields
+	}
+	for _, attr := range attrs {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeNextHop:
+			fields["Nexthop"] = a.Value.String()
+		case *bgp.PathAttributeMpReachNLRI:
+			if _, ok := fields["Nexthop"]; !ok {
+				fields["Nexthop"] = a.Nexthop.String()
+			}
+		case *bgp.PathAttributeAsPath:
+			fields["AsPath"] = a.String()
+		case *bgp.PathAttributeMultiExitDisc:
+			fields["Med"] = a.Value
+		case *bgp.PathAttributeCommunities:
+			if len(a.Value) == 0 {
+				continue
+			}
+			l := make([]string, 0, len(a.Value))
+			for _, c := range a.Value {
+				if n, ok := bgp.WellKnownCommunityNameMap[bgp.WellKnownCommunity(c)]; ok {
+					l = append(l, n)
+				} else {
+					l = append(l, fmt.Sprintf("%d:%d", c>>16, c&0xffff))
+				}
+			}
+			fields["Communities"] = strings.Join(l, " ")
+		case *bgp.PathAttributeExtendedCommunities:
+			if len(a.Value) == 0 {
+				continue
+			}
+			l := make([]string, 0, len(a.Value))
+			for _, c := range a.Value {
+				l = append(l, c.String())
+			}
+			fields["ExtCommunities"] = strings.Join(l, " ")
+		}
+	}
+	return fields
+}
+
+// updateAttributeFields returns the log fields of every prefix
+// withdrawn or advertised by the UPDATE message.
+func updateAttributeFields(update *bgp.BGPUpdate) []log.Fields {
+	list := make([]log.Fields, 0, len(update.WithdrawnRoutes)+len(update.NLRI))
+	for _, nlri := range update.WithdrawnRoutes {
+		list = append(list, attributeFields(nlri, true, nil))
+	}
+	for _, attr := range update.PathAttributes {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeMpUnreachNLRI:
+			for _, nlri := range a.Value {
+				list = append(list, attributeFields(nlri, true, nil))
+			}
+		case *bgp.PathAttributeMpReachNLRI:
+			for _, nlri := range a.Value {
+				list = append(list, attributeFields(nlri, false, update.PathAttributes))
+			}
+		}
+	}
+	for _, nlri := range update.NLRI {
+		list = append(list, attributeFields(nlri, false, update.PathAttributes))
+	}
+	return list
+}
+
+// logPathAttributes logs the decoded attributes of each path in a
+// debug log line. Nothing is formatted unless the debug level is
+// enabled.
+func (fsm *FSM) logPathAttributes(msg string, pathList []*table.Path) {
+	if !fsm.pConf.Config.DebugPathAttributes || log.GetLevel() < log.DebugLevel {
+		return
+	}
+	for _, path := range pathList {
+		fields := pathAttributeFields(path)
+		fields["Topic"] = "Peer"
+		fields["Key"] = fsm.pConf.Config.NeighborAddress
+		log.WithFields(fields).Debug(msg)
+	}
+}
+
+// logUpdateAttributes logs the decoded attributes of each prefix in
+// the UPDATE message like logPathAttributes. It's used for the sent
+// messages, which aren't converted to paths.
+func (fsm *FSM) logUpdateAttributes(msg string, update *bgp.BGPUpdate) {
+	if !fsm.pConf.Config.DebugPathAttributes || log.GetLevel() < log.DebugLevel {
+		return
+	}
+	for _, fields := range updateAttributeFields(update) {
+		fields["Topic"] = "Peer"
+		fields["Key"] = fsm.pConf.Config.NeighborAddress
+		log.WithFields(fields).Debug(msg)
+	}
+}
+
+// State returns the current state and the reason of the last state
+// change. It's safe to call from any goroutine, StateChange updates
+// both under the lock.
+func (fsm *FSM) State() (bgp.FSMState, FsmStateReason) {
+	fsm.lock.RLock()
+	defer fsm.lock.RUnlock()
+	return fsm.state, fsm.reason
+}
+
+// Capability returns the capabilities of the code the peer advertised
+// in its OPEN message, or nil when it didn't. It's safe to call from
+// any goroutine.
+func (fsm *FSM) Capability(code bgp.BGPCapabilityCode) []bgp.ParameterCapabilityInterface {
+	fsm.lock.RLock()
+	defer fsm.lock.RUnlock()
+	return fsm.capMap[code]
+}
+
+// HasCapability tells whether the peer advertised the capability of
+// the code in its OPEN message. It's safe to call from any goroutine.
+func (fsm *FSM) HasCapability(code bgp.BGPCapabilityCode) bool {
+	return len(fsm.Capability(code)) > 0
+}
+
+// HasFamily tells whether the family is negotiated with the peer. It's
+// safe to call from any goroutine.
+func (fsm *FSM) HasFamily(rf bgp.RouteFamily) bool {
+	_, ok := fsm.familyMap()[rf]
+	return ok
+}
+
+// Families returns the families negotiated with the peer. It's safe to
+// call from any goroutine.
+func (fsm *FSM) Families() []bgp.RouteFamily {
+	m := fsm.familyMap()
+	l := make([]bgp.RouteFamily, 0, len(m))
+	for rf := range m {
+		l = append(l, rf)
+	}
+	return l
+}
+
+// familyMap returns the families negotiated with the peer. opensent
+// replaces the map instead of modifying it, so the map can be read
+// after the lock is released, but mustn't be modified.
+func (fsm *FSM) familyMap() map[bgp.RouteFamily]bool {
+	fsm.lock.RLock()
+	defer fsm.lock.RUnlock()
+	return fsm.rfMap
+}
+
+// PeerID returns the BGP identifier in the OPEN message of the peer.
+// It's safe to call from any goroutine.
+func (fsm *FSM) PeerID() net.IP {
+	fsm.lock.RLock()
+	defer fsm.lock.RUnlock()
+	return fsm.peerInfo.ID
+}
+
+// MarshallingOption returns the encoding of the messages negotiated
+// with the peer. It's safe to call from any goroutine.
+func (fsm *FSM) MarshallingOption() *bgp.MarshallingOption {
+	fsm.lock.RLock()
+	defer fsm.lock.RUnlock()
+	return fsm.marshalOption
+}
+
+// peerMarshallingOption returns the encoding of the messages from the
+// point of view of the peer, with the Add-Path directions swapped, to
+// parse the messages sent to it.
+func peerMarshallingOption(o *bgp.MarshallingOption) *bgp.MarshallingOption {
+	if o == nil {
+		return nil
+	}
+	addPath := make(map[bgp.RouteFamily]bgp.BGPAddPathMode, len(o.AddPath))
+	for rf, mode := range o.AddPath {
+		var m bgp.BGPAddPathMode
+		if mode&bgp.BGP_ADD_PATH_SEND != 0 {
+			m |= bgp.BGP_ADD_PATH_RECEIVE
+		}
+		if mode&bgp.BGP_ADD_PATH_RECEIVE != 0 {
+			m |= bgp.BGP_ADD_PATH_SEND
+		}
+		addPath[rf] = m
+	}
+	return &bgp.MarshallingOption{AddPath: addPath}
+}
+
+// remoteCapabilities returns all the capabilities the peer advertised.
+func (fsm *FSM) remoteCapabilities() []bgp.ParameterCapabilityInterface {
+	fsm.lock.RLock()
+	defer fsm.lock.RUnlock()
+	l := make([]bgp.ParameterCapabilityInterface, 0, len(fsm.capMap))
+	for _, c := range fsm.capMap {
+		l = append(l, c...)
+	}
+	return l
+}
+
+// setAfiSafis replaces the families configured for the peer. The FSM
+// goroutine reads them to build the OPEN message, so the FSM keeps its
+// own copy, replaced under the lock.
+func (fsm *FSM) setAfiSafis(afiSafis []config.AfiSafi) {
+	l := make([]config.AfiSafi, len(afiSafis))
+	copy(l, afiSafis)
+	fsm.lock.Lock()
+	defer fsm.lock.Unlock()
+	fsm.pConf.AfiSafis = l
+}
+
+// ExpireHoldTimer makes the hold timer of the established session
+// expire now, as if the peer were lost. The HOLD TIMER EXPIRED
+// notification is sent and the FSM goes to IDLE. It's meant for
+// testing and manual failover. It's safe to call from any goroutine
+// and does nothing unless the session is established.
+func (fsm *FSM) ExpireHoldTimer() error {
+	if state, _ := fsm.State(); state != bgp.BGP_FSM_ESTABLISHED {
+		return fmt.Errorf("neighbor %s is not established", fsm.pConf.Config.NeighborAddress)
+	}
+	select {
+	case fsm.expireHoldCh <- struct{}{}:
+	default:
+		// already requested
+	}
+	return nil
+}
+
+func (fsm *FSM) StateChange(nextState bgp.FSMState, reason FsmStateReason) {
+	fsm.lock.Lock()
+	oldState := fsm.state
+	fsm.state = nextState
+	fsm.reason = reason
+	fsm.lock.Unlock()
 	log.WithFields(log.Fields{
 		"Topic":  "Peer",
 		"Key":    fsm.pConf.Config.NeighborAddress,
-		"old":    fsm.state.String(),
+		"old":    oldState.String(),
 		"new":    nextState.String(),
-		"reason": fsm.reason.String(),
+		"reason": reason.String(),
 	}).Debug("state changed")
-	fsm.state = nextState
 	switch nextState {
 	case bgp.BGP_FSM_ESTABLISHED:
 		fsm.pConf.Timers.State.Uptime = time.Now().Unix()
 		fsm.pConf.State.EstablishedCount++
 	case bgp.BGP_FSM_ACTIVE:
-		if !fsm.pConf.Transport.Config.PassiveMode {
+		if !fsm.pConf.Transport.Config.PassiveMode && !fsm.gConf.Collector.ReceiveOnly {
 			fsm.getActiveCh <- struct{}{}
 		}
 		fallthrough
@@ -252,8 +537,53 @@ func (fsm *FSM) LocalHostPort() (string, uint16) {
 	return hostport(fsm.conn.LocalAddr())
 }
 
+// notificationHold remembers the last NOTIFICATION sent to the peer so
+// that the identical ones repeated within min-notification-interval,
+// e.g. a Cease on every fast reconnect, are suppressed. It outlives the
+// sessions, whose goroutines share it.
+type notificationHold struct {
+	lock    sync.Mutex
+	code    uint8
+	subcode uint8
+	sent    time.Time
+}
+
+// suppress tells whether the NOTIFICATION of the code and subcode
+// repeats the last one within the interval. Otherwise it's recorded as
+// sent now, so another code or subcode is never suppressed.
+func (n *notificationHold) suppress(code, subcode uint8, interval time.Duration, now time.Time) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if interval > 0 && !n.sent.IsZero() && n.code == code && n.subcode == subcode && now.Sub(n.sent) < interval {
+		return true
+	}
+	n.code, n.subcode, n.sent = code, subcode, now
+	return false
+}
+
+// suppressNotification tells whether the NOTIFICATION mustn't be sent
+// to the peer. The session is reset all the same.
+func (fsm *FSM) suppressNotification(body *bgp.BGPNotification) bool {
+	interval := time.Duration(fsm.pConf.Timers.Config.MinNotificationInterval * float64(time.Second))
+	if !fsm.notificationHold.suppress(body.ErrorCode, body.ErrorSubcode, interval, time.Now()) {
+		return false
+	}
+	log.WithFields(log.Fields{
+		"Topic":   "Peer",
+		"Key":     fsm.pConf.Config.NeighborAddress,
+		"Code":    body.ErrorCode,
+		"Subcode": body.ErrorSubcode,
+	}).Info("suppressed repeated notification")
+	return true
+}
+
 func (fsm *FSM) sendNotificatonFromErrorMsg(conn net.Conn, e *bgp.MessageError) {
 	m := bgp.NewBGPNotificationMessage(e.TypeCode, e.SubTypeCode, e.Data)
+	fsm.notification = m.Body.(*bgp.BGPNotification)
+	if fsm.suppressNotification(fsm.notification) {
+		conn.Close()
+		return
+	}
 	b, _ := m.Serialize()
 	_, err := conn.Write(b)
 	if err != nil {
@@ -273,11 +603,77 @@ func (fsm *FSM) sendNotification(conn net.Conn, code, subType uint8, data []byte
 	fsm.sendNotificatonFromErrorMsg(conn, e.(*bgp.MessageError))
 }
 
+// unexpectedMessage closes the connection on the message the peer
+// isn't expected to send in the state, answering it with the Finite
+// State Machine Error of the subcode (RFC 4271 6.6, RFC 6608). A
+// NOTIFICATION is never answered.
+func (fsm *FSM) unexpectedMessage(conn net.Conn, m *bgp.BGPMessage, subcode uint8) FsmStateReason {
+	if m.Header.Type == bgp.BGP_MSG_NOTIFICATION {
+		body := m.Body.(*bgp.BGPNotification)
+		log.WithFields(log.Fields{
+			"Topic":   "Peer",
+			"Key":     fsm.pConf.Config.NeighborAddress,
+			"Code":    body.ErrorCode,
+			"Subcode": body.ErrorSubcode,
+			"Data":    body.Data,
+		}).Warn("received notification")
+		fsm.notification = body
+		conn.Close()
+		return FSM_NOTIFICATION_RECV
+	}
+	fsm.sendNotification(conn, bgp.BGP_ERROR_FSM_ERROR, subcode, nil, fmt.Sprintf("unexpected message type %d", m.Header.Type))
+	return FSM_INVALID_MSG
+}
+
+// connectTimeout returns the timeout of a TCP connection attempt. It's
+// kept below the retry interval so that attempts don't overlap.
+func connectTimeout(pConf *config.Neighbor, tick int) time.Duration {
+	max := time.Duration(tick-1) * time.Second
+	timeout := time.Duration(pConf.Timers.Config.ConnectTimeout * float64(time.Second))
+	if timeout <= 0 {
+		timeout = time.Duration(MIN_CONNECT_RETRY-1) * time.Second
+	}
+	if timeout > max {
+		timeout = max
+	}
+	return timeout
+}
+
+// localAddr returns the configured local address and port of the
+// connections to the peer, nil if neither is configured.
+func (fsm *FSM) localAddr() (*net.TCPAddr, error) {
+	c := fsm.pConf.Transport.Config
+	if c.LocalAddress == "" && c.LocalPort == 0 {
+		return nil, nil
+	}
+	lhost := net.JoinHostPort(c.LocalAddress, strconv.Itoa(int(c.LocalPort)))
+	return net.ResolveTCPAddr("tcp", lhost)
+}
+
+// dialPeer connects to the host from the local address if any. With a
+// fixed local port, the address is reused so that reconnecting doesn't
+// fail while the previous connection from the port is in TIME_WAIT.
+func dialPeer(host string, laddr *net.TCPAddr, timeout time.Duration) (net.Conn, error) {
+	if laddr == nil {
+		return net.DialTimeout("tcp", host, timeout)
+	}
+	if laddr.Port == 0 {
+		d := net.Dialer{Timeout: timeout, LocalAddr: laddr}
+		return d.Dial("tcp", host)
+	}
+	raddr, err := net.ResolveTCPAddr("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	return dialReuseAddr(laddr, raddr, timeout)
+}
+
 func (fsm *FSM) connectLoop() error {
 	var tick int
 	if tick = int(fsm.pConf.Timers.Config.ConnectRetry); tick < MIN_CONNECT_RETRY {
 		tick = MIN_CONNECT_RETRY
 	}
+	timeout := connectTimeout(fsm.pConf, tick)
 
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
@@ -285,41 +681,24 @@ func (fsm *FSM) connectLoop() error {
 	ticker.Stop()
 
 	connect := func() {
-		if fsm.state == bgp.BGP_FSM_ACTIVE {
+		if state, _ := fsm.State(); state == bgp.BGP_FSM_ACTIVE {
 			addr := fsm.pConf.Config.NeighborAddress
 			host := net.JoinHostPort(addr, strconv.Itoa(bgp.BGP_PORT))
-			// check if LocalAddress has been configured
-			laddr := fsm.pConf.Transport.Config.LocalAddress
-			if laddr != "" {
-				lhost := net.JoinHostPort(laddr, "0")
-				ltcpaddr, err := net.ResolveTCPAddr("tcp", lhost)
-				if err != nil {
-					log.WithFields(log.Fields{
-						"Topic": "Peer",
-						"Key":   fsm.pConf.Config.NeighborAddress,
-					}).Warnf("failed to resolve ltcpaddr: %s", err)
-				} else {
-					d := net.Dialer{LocalAddr: ltcpaddr, Timeout: time.Duration(MIN_CONNECT_RETRY-1) * time.Second}
-					if conn, err := d.Dial("tcp", host); err == nil {
-						fsm.connCh <- conn
-					} else {
-						log.WithFields(log.Fields{
-							"Topic": "Peer",
-							"Key":   fsm.pConf.Config.NeighborAddress,
-						}).Debugf("failed to connect from ltcpaddr", err)
-					}
-				}
-
+			laddr, err := fsm.localAddr()
+			if err != nil {
+				log.WithFields(log.Fields{
+					"Topic": "Peer",
+					"Key":   fsm.pConf.Config.NeighborAddress,
+				}).Warnf("failed to resolve ltcpaddr: %s", err)
+				return
+			}
+			if conn, err := dialPeer(host, laddr, timeout); err == nil {
+				fsm.connCh <- conn
 			} else {
-				conn, err := net.DialTimeout("tcp", host, time.Duration(MIN_CONNECT_RETRY-1)*time.Second)
-				if err == nil {
-					fsm.connCh <- conn
-				} else {
-					log.WithFields(log.Fields{
-						"Topic": "Peer",
-						"Key":   fsm.pConf.Config.NeighborAddress,
-					}).Debugf("failed to connect: %s", err)
-				}
+				log.WithFields(log.Fields{
+					"Topic": "Peer",
+					"Key":   fsm.pConf.Config.NeighborAddress,
+				}).Debugf("failed to connect: %s", err)
 			}
 		}
 	}
@@ -332,18 +711,26 @@ func (fsm *FSM) connectLoop() error {
 				"Key":   fsm.pConf.Config.NeighborAddress,
 			}).Debug("stop connect loop")
 			ticker.Stop()
+			fsm.deadlines.set(&fsm.deadlines.connectRetry, 0)
 			return nil
 		case <-ticker.C:
+			fsm.deadlines.set(&fsm.deadlines.connectRetry, time.Duration(tick)*time.Second)
 			connect()
 		case <-fsm.getActiveCh:
-			time.Sleep(time.Duration(r.Intn(MIN_CONNECT_RETRY)+MIN_CONNECT_RETRY) * time.Second)
+			d := time.Duration(r.Intn(MIN_CONNECT_RETRY)+MIN_CONNECT_RETRY) * time.Second
+			fsm.deadlines.set(&fsm.deadlines.connectRetry, d)
+			time.Sleep(d)
 			connect()
 			ticker = time.NewTicker(time.Duration(tick) * time.Second)
+			fsm.deadlines.set(&fsm.deadlines.connectRetry, time.Duration(tick)*time.Second)
 		}
 	}
 }
 
 type FSMHandler struct {
+	// the time in UnixNano the last message resetting the hold timer
+	// was received, accessed atomically; first for the alignment
+	lastRecv         int64
 	t                tomb.Tomb
 	fsm              *FSM
 	conn             net.Conn
@@ -353,6 +740,8 @@ type FSMHandler struct {
 	stateCh          chan *FsmMsg
 	outgoing         chan *bgp.BGPMessage
 	holdTimerResetCh chan bool
+	lastHoldReset    time.Time
+	updateLimiter    *updateRateLimiter
 }
 
 func NewFSMHandler(fsm *FSM, incoming, stateCh chan *FsmMsg, outgoing chan *bgp.BGPMessage) *FSMHandler {
@@ -364,6 +753,7 @@ func NewFSMHandler(fsm *FSM, incoming, stateCh chan *FsmMsg, outgoing chan *bgp.
 		outgoing:         outgoing,
 		holdTimerResetCh: make(chan bool, 2),
 	}
+	fsm.notification = nil
 	fsm.t.Go(h.loop)
 	return h
 }
@@ -372,6 +762,8 @@ func (h *FSMHandler) idle() (bgp.FSMState, FsmStateReason) {
 	fsm := h.fsm
 
 	idleHoldTimer := time.NewTimer(time.Second * time.Duration(fsm.idleHoldTime))
+	fsm.deadlines.set(&fsm.deadlines.idleHold, time.Second*time.Duration(fsm.idleHoldTime))
+	defer fsm.deadlines.set(&fsm.deadlines.idleHold, 0)
 	for {
 		select {
 		case <-h.t.Dying():
@@ -398,6 +790,7 @@ func (h *FSMHandler) idle() (bgp.FSMState, FsmStateReason) {
 
 			} else {
 				log.Debug("IdleHoldTimer expired, but stay at idle because the admin state is DOWN")
+				fsm.deadlines.set(&fsm.deadlines.idleHold, 0)
 			}
 
 		case s := <-fsm.adminStateCh:
@@ -407,10 +800,12 @@ func (h *FSMHandler) idle() (bgp.FSMState, FsmStateReason) {
 				case ADMIN_STATE_DOWN:
 					// stop idle hold timer
 					idleHoldTimer.Stop()
+					fsm.deadlines.set(&fsm.deadlines.idleHold, 0)
 
 				case ADMIN_STATE_UP:
 					// restart idle hold timer
 					idleHoldTimer.Reset(time.Second * time.Duration(fsm.idleHoldTime))
+					fsm.deadlines.set(&fsm.deadlines.idleHold, time.Second*time.Duration(fsm.idleHoldTime))
 				}
 			}
 		}
@@ -434,7 +829,17 @@ func (h *FSMHandler) active() (bgp.FSMState, FsmStateReason) {
 					ttl = int(fsm.pConf.EbgpMultihop.Config.MultihopTtl)
 				}
 				if ttl != 0 {
-					SetTcpTTLSockopts(conn.(*net.TCPConn), ttl)
+					// the socket options can be applied only to a TCP
+					// connection, not to e.g. net.Pipe used in tests.
+					if tcp, ok := conn.(*net.TCPConn); ok {
+						SetTcpTTLSockopts(tcp, ttl)
+					} else {
+						log.WithFields(log.Fields{
+							"Topic": "Peer",
+							"Key":   fsm.pConf.Config.NeighborAddress,
+							"Type":  fmt.Sprintf("%T", conn),
+						}).Warn("not a TCP connection, TTL isn't set")
+					}
 				}
 			}
 			// we don't implement delayed open timer so move to opensent right
@@ -449,10 +854,11 @@ func (h *FSMHandler) active() (bgp.FSMState, FsmStateReason) {
 				case ADMIN_STATE_DOWN:
 					return bgp.BGP_FSM_IDLE, FSM_ADMIN_DOWN
 				case ADMIN_STATE_UP:
+					state, _ := fsm.State()
 					log.WithFields(log.Fields{
 						"Topic":      "Peer",
 						"Key":        fsm.pConf.Config.NeighborAddress,
-						"State":      fsm.state,
+						"State":      state,
 						"AdminState": s.String(),
 					}).Panic("code logic bug")
 				}
@@ -469,9 +875,271 @@ func capabilitiesFromConfig(gConf *config.Global, pConf *config.Neighbor) []bgp.
 		caps = append(caps, bgp.NewCapMultiProtocol(family))
 	}
 	caps = append(caps, bgp.NewCapFourOctetASNumber(gConf.Config.As))
+	if c := pConf.GracefulRestart.Config; c.Enabled {
+		var flags uint8
+		if c.NotificationEnabled {
+			flags |= bgp.BGP_CAP_GRACEFUL_RESTART_FLAG_NOTIFICATION
+		}
+		caps = append(caps, bgp.NewCapGracefulRestart(flags, c.RestartTime, gracefulRestartTuples(pConf)))
+	}
+	if c := prefixOrfCapability(pConf); c != nil {
+		caps = append(caps, c)
+	}
+	if c := extendedNexthopCapability(pConf); c != nil {
+		caps = append(caps, c)
+	}
+	for _, c := range addPathCapabilities(pConf) {
+		caps = append(caps, c)
+	}
+	if c := pathsLimitCapability(pConf); c != nil {
+		caps = append(caps, c)
+	}
+	return caps
+}
+
+// pathsLimitCapability returns the Paths-Limit capability telling how
+// many paths per prefix we receive for the families we advertise
+// Add-Path receive for, or nil when no limit is configured.
+func pathsLimitCapability(pConf *config.Neighbor) *bgp.CapPathsLimit {
+	limit := pConf.AddPaths.Config.PathsLimit
+	if limit == 0 || !pConf.AddPaths.Config.Receive {
+		return nil
+	}
+	values := make([]bgp.CapPathsLimitValue, 0, len(pConf.AfiSafis))
+	for _, c := range addPathCapabilities(pConf) {
+		values = append(values, bgp.CapPathsLimitValue{RouteFamily: c.RouteFamily, Limit: limit})
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return bgp.NewCapPathsLimit(values)
+}
+
+// pathsLimit returns how many paths per prefix of the family are
+// accepted from the peer, or zero for no limit. Our limit is in effect
+// for the families Add-Path receive is negotiated for.
+func (fsm *FSM) pathsLimit(rf bgp.RouteFamily) int {
+	o := fsm.MarshallingOption()
+	if o == nil || o.AddPath[rf]&bgp.BGP_ADD_PATH_RECEIVE == 0 {
+		return 0
+	}
+	if pathsLimitCapability(fsm.pConf) == nil {
+		return 0
+	}
+	return int(fsm.pConf.AddPaths.Config.PathsLimit)
+}
+
+// peerPathsLimit returns the limit the peer advertised in its
+// Paths-Limit capability for the family, or zero when it didn't.
+func (fsm *FSM) peerPathsLimit(rf bgp.RouteFamily) uint16 {
+	for _, c := range fsm.Capability(bgp.BGP_CAP_PATHS_LIMIT) {
+		if l := c.(*bgp.CapPathsLimit).Limit(rf); l > 0 {
+			return l
+		}
+	}
+	return 0
+}
+
+// addPathCapabilities returns the Add-Path capabilities (RFC7911) to
+// receive multiple paths, or to send them with send-max set, for the
+// unicast families configured for the peer.
+func addPathCapabilities(pConf *config.Neighbor) []*bgp.CapAddPath {
+	var mode bgp.BGPAddPathMode
+	if pConf.AddPaths.Config.Receive {
+		mode |= bgp.BGP_ADD_PATH_RECEIVE
+	}
+	if pConf.AddPaths.Config.SendMax > 0 {
+		mode |= bgp.BGP_ADD_PATH_SEND
+	}
+	if mode == 0 {
+		return nil
+	}
+	caps := make([]*bgp.CapAddPath, 0, len(pConf.AfiSafis))
+	for _, rf := range pConf.AfiSafis {
+		family, _ := bgp.GetRouteFamily(string(rf.AfiSafiName))
+		if family != bgp.RF_IPv4_UC && family != bgp.RF_IPv6_UC {
+			continue
+		}
+		caps = append(caps, bgp.NewCapAddPath(family, mode))
+	}
 	return caps
 }
 
+// addPathOption returns the encoding of the messages with the peer with
+// the path identifiers of the families Add-Path is negotiated for in
+// each direction, or nil when it isn't negotiated for any.
+func (fsm *FSM) addPathOption() *bgp.MarshallingOption {
+	var addPath map[bgp.RouteFamily]bgp.BGPAddPathMode
+	fsm.lock.RLock()
+	locals := addPathCapabilities(fsm.pConf)
+	fsm.lock.RUnlock()
+	for _, local := range locals {
+		if !fsm.HasFamily(local.RouteFamily) {
+			continue
+		}
+		for _, c := range fsm.Capability(bgp.BGP_CAP_ADD_PATH) {
+			remote := c.(*bgp.CapAddPath)
+			if remote.RouteFamily != local.RouteFamily {
+				continue
+			}
+			var mode bgp.BGPAddPathMode
+			if local.Mode&bgp.BGP_ADD_PATH_RECEIVE != 0 && remote.Mode&bgp.BGP_ADD_PATH_SEND != 0 {
+				mode |= bgp.BGP_ADD_PATH_RECEIVE
+			}
+			if local.Mode&bgp.BGP_ADD_PATH_SEND != 0 && remote.Mode&bgp.BGP_ADD_PATH_RECEIVE != 0 {
+				mode |= bgp.BGP_ADD_PATH_SEND
+			}
+			if mode == 0 {
+				continue
+			}
+			if addPath == nil {
+				addPath = make(map[bgp.RouteFamily]bgp.BGPAddPathMode)
+			}
+			addPath[local.RouteFamily] = mode
+		}
+	}
+	if addPath == nil {
+		return nil
+	}
+	return &bgp.MarshallingOption{AddPath: addPath}
+}
+
+// extendedNexthopCapability returns the Extended Next Hop Encoding
+// capability (RFC5549) for IPv4 unicast with IPv6 next hops when it's
+// enabled and IPv4 unicast is configured for the peer, or nil.
+func extendedNexthopCapability(pConf *config.Neighbor) *bgp.CapExtendedNexthop {
+	if !pConf.Config.ExtendedNexthop {
+		return nil
+	}
+	for _, rf := range pConf.AfiSafis {
+		if family, _ := bgp.GetRouteFamily(string(rf.AfiSafiName)); family == bgp.RF_IPv4_UC {
+			return bgp.NewCapExtendedNexthop([]bgp.CapExtendedNexthopTuple{{
+				NLRIAFI:    bgp.AFI_IP,
+				NLRISAFI:   bgp.SAFI_UNICAST,
+				NexthopAFI: bgp.AFI_IP6,
+			}})
+		}
+	}
+	return nil
+}
+
+// extendedNexthopNegotiated tells whether both sides advertised the
+// Extended Next Hop Encoding for IPv4 unicast with IPv6 next hops.
+func (fsm *FSM) extendedNexthopNegotiated() bool {
+	if !fsm.HasFamily(bgp.RF_IPv4_UC) || extendedNexthopCapability(fsm.pConf) == nil {
+		return false
+	}
+	for _, c := range fsm.Capability(bgp.BGP_CAP_EXTENDED_NEXTHOP) {
+		if c.(*bgp.CapExtendedNexthop).Has(bgp.RF_IPv4_UC, bgp.AFI_IP6) {
+			return true
+		}
+	}
+	return false
+}
+
+// gracefulRestartNotification returns true when both sides advertised
+// the N-bit of the graceful restart capability (RFC8538). Then a
+// NOTIFICATION message other than Hard Reset triggers graceful restart.
+func (fsm *FSM) gracefulRestartNotification() bool {
+	if c := fsm.pConf.GracefulRestart.Config; !c.Enabled || !c.NotificationEnabled {
+		return false
+	}
+	for _, c := range fsm.Capability(bgp.BGP_CAP_GRACEFUL_RESTART) {
+		if c.(*bgp.CapGracefulRestart).CapValue.Flags&bgp.BGP_CAP_GRACEFUL_RESTART_FLAG_NOTIFICATION != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gracefulCeaseSubcodes tells whether a Cease NOTIFICATION message
+// with the subcode lets the receiver retain the routes as stale when
+// the N-bit was negotiated. RFC8538 recommends Hard Reset for the ones
+// which mean the session isn't coming back soon.
+var gracefulCeaseSubcodes = map[uint8]bool{
+	bgp.BGP_ERROR_SUB_MAXIMUM_NUMBER_OF_PREFIXES_REACHED: false,
+	bgp.BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN:            false,
+	bgp.BGP_ERROR_SUB_PEER_DECONFIGURED:                  false,
+	bgp.BGP_ERROR_SUB_ADMINISTRATIVE_RESET:               true,
+	bgp.BGP_ERROR_SUB_CONNECTION_RESET:                   true,
+	bgp.BGP_ERROR_SUB_OTHER_CONFIGURATION_CHANGE:         true,
+	bgp.BGP_ERROR_SUB_CONNECTION_COLLISION_RESOLUTION:    true,
+	bgp.BGP_ERROR_SUB_OUT_OF_RESOURCES:                   true,
+	bgp.BGP_ERROR_SUB_HARD_RESET:                         false,
+}
+
+// retainOnNotification returns true when the routes learned from the
+// peer should be retained as stale instead of flushed on the
+// NOTIFICATION message.
+func (fsm *FSM) retainOnNotification(n *bgp.BGPNotification) bool {
+	if !fsm.gracefulRestartNotification() {
+		return false
+	}
+	if n.ErrorCode == bgp.BGP_ERROR_CEASE {
+		return gracefulCeaseSubcodes[n.ErrorSubcode]
+	}
+	return true
+}
+
+// gracefulRestartTuples returns the per-family part of the graceful
+// restart capability for the families with graceful restart enabled.
+// The Forwarding State bit is set for the families whose forwarding
+// state is configured as preserved.
+func gracefulRestartTuples(pConf *config.Neighbor) []bgp.CapGracefulRestartTuples {
+	tuples := make([]bgp.CapGracefulRestartTuples, 0, len(pConf.AfiSafis))
+	for _, a := range pConf.AfiSafis {
+		c := a.MpGracefulRestart.Config
+		if !c.Enabled {
+			continue
+		}
+		family, err := bgp.GetRouteFamily(string(a.AfiSafiName))
+		if err != nil {
+			continue
+		}
+		afi, safi := bgp.RouteFamilyToAfiSafi(family)
+		var flags uint8
+		if c.ForwardingStatePreserved {
+			flags |= bgp.BGP_CAP_GRACEFUL_RESTART_TUPLE_FLAG_FORWARDING
+		}
+		tuples = append(tuples, bgp.CapGracefulRestartTuples{AFI: afi, SAFI: safi, Flags: flags})
+	}
+	return tuples
+}
+
+// peerGracefulRestartFamilies returns the families in the graceful
+// restart capability advertised by the peer with whether the peer
+// preserved their forwarding state.
+func (fsm *FSM) peerGracefulRestartFamilies() map[bgp.RouteFamily]bool {
+	m := make(map[bgp.RouteFamily]bool)
+	for _, c := range fsm.Capability(bgp.BGP_CAP_GRACEFUL_RESTART) {
+		for _, t := range c.(*bgp.CapGracefulRestart).CapValue.Tuples {
+			m[bgp.AfiSafiToRouteFamily(t.AFI, t.SAFI)] = t.Flags&bgp.BGP_CAP_GRACEFUL_RESTART_TUPLE_FLAG_FORWARDING != 0
+		}
+	}
+	return m
+}
+
+// peerRestartTime returns the restart time in the graceful restart
+// capability advertised by the peer, or zero.
+func (fsm *FSM) peerRestartTime() uint16 {
+	for _, c := range fsm.Capability(bgp.BGP_CAP_GRACEFUL_RESTART) {
+		return c.(*bgp.CapGracefulRestart).CapValue.Time
+	}
+	return 0
+}
+
+// staleRoutesTime returns how long the routes learned from the peer are
+// retained as stale after the session went down gracefully. It's the
+// restart time advertised by the peer, or the configured
+// stale-routes-time when it's shorter.
+func (fsm *FSM) staleRoutesTime() time.Duration {
+	d := time.Duration(fsm.peerRestartTime()) * time.Second
+	if t := time.Duration(fsm.pConf.GracefulRestart.Config.StaleRoutesTime * float64(time.Second)); t > 0 && t < d {
+		return t
+	}
+	return d
+}
+
 func buildopen(gConf *config.Global, pConf *config.Neighbor) *bgp.BGPMessage {
 	caps := capabilitiesFromConfig(gConf, pConf)
 	opt := bgp.NewOptionParameterCapability(caps)
@@ -480,10 +1148,27 @@ func buildopen(gConf *config.Global, pConf *config.Neighbor) *bgp.BGPMessage {
 	if as > (1<<16)-1 {
 		as = bgp.AS_TRANS
 	}
-	return bgp.NewBGPOpenMessage(uint16(as), holdTime, gConf.Config.RouterId,
+	return bgp.NewBGPOpenMessage(uint16(as), holdTime, config.LocalRouterId(gConf, pConf),
 		[]bgp.OptionParameterInterface{opt})
 }
 
+// checkFourOctetAs refuses the peer which doesn't support the
+// four-octet AS number capability when our AS number needs four
+// octets, since the peer would take AS_TRANS in the OPEN message for
+// our AS number. The capability required is carried in the data of the
+// Unsupported Capability error (RFC 5492 5).
+func (fsm *FSM) checkFourOctetAs() error {
+	as := fsm.gConf.Config.As
+	if as <= (1<<16)-1 {
+		return nil
+	}
+	if fsm.HasCapability(bgp.BGP_CAP_FOUR_OCTET_AS_NUMBER) {
+		return nil
+	}
+	data, _ := bgp.NewCapFourOctetASNumber(as).Serialize()
+	return bgp.NewMessageError(bgp.BGP_ERROR_OPEN_MESSAGE_ERROR, bgp.BGP_ERROR_SUB_UNSUPPORTED_CAPABILITY, data, fmt.Sprintf("AS number %d needs the four-octet AS number capability, which the peer doesn't support", as))
+}
+
 func readAll(conn net.Conn, length int) ([]byte, error) {
 	buf := make([]byte, length)
 	_, err := io.ReadFull(conn, buf)
@@ -493,6 +1178,259 @@ func readAll(conn net.Conn, length int) ([]byte, error) {
 	return buf, nil
 }
 
+// fillMissingOrigin adds ORIGIN INCOMPLETE to the UPDATE message from
+// the peer carrying routes without ORIGIN, when configured to accept
+// them. The routes then enter the RIB with the mandatory attribute and
+// are advertised onward with it.
+func (fsm *FSM) fillMissingOrigin(body *bgp.BGPUpdate) {
+	if !fsm.pConf.Config.AcceptMissingOrigin {
+		return
+	}
+	reach := len(body.NLRI) > 0
+	for _, a := range body.PathAttributes {
+		switch a.GetType() {
+		case bgp.BGP_ATTR_TYPE_ORIGIN:
+			return
+		case bgp.BGP_ATTR_TYPE_MP_REACH_NLRI:
+			reach = true
+		}
+	}
+	if !reach {
+		return
+	}
+	log.WithFields(log.Fields{
+		"Topic": "Peer",
+		"Key":   fsm.pConf.Config.NeighborAddress,
+	}).Warn("ORIGIN is missing in the update, set INCOMPLETE")
+	origin := bgp.NewPathAttributeOrigin(bgp.BGP_ORIGIN_ATTR_TYPE_INCOMPLETE)
+	body.PathAttributes = append([]bgp.PathAttributeInterface{origin}, body.PathAttributes...)
+}
+
+// handleOrphanAs4Path handles the UPDATE message from the peer carrying
+// AS4_PATH without AS_PATH (RFC7606). AS4_PATH is meaningful only along
+// with AS_PATH, so it's discarded, or the routes in the message are
+// treated as withdrawn when configured so.
+func (fsm *FSM) handleOrphanAs4Path(body *bgp.BGPUpdate) {
+	as4Pos := -1
+	for i, a := range body.PathAttributes {
+		switch a.GetType() {
+		case bgp.BGP_ATTR_TYPE_AS_PATH:
+			return
+		case bgp.BGP_ATTR_TYPE_AS4_PATH:
+			as4Pos = i
+		}
+	}
+	if as4Pos < 0 {
+		return
+	}
+	action := fsm.pConf.Config.OrphanAs4PathAction
+	log.WithFields(log.Fields{
+		"Topic":  "Peer",
+		"Key":    fsm.pConf.Config.NeighborAddress,
+		"Action": action,
+	}).Warn("AS4_PATH without AS_PATH in the update")
+	if action != config.ORPHAN_AS4_PATH_ACTION_TYPE_TREAT_AS_WITHDRAW {
+		body.PathAttributes = append(body.PathAttributes[:as4Pos], body.PathAttributes[as4Pos+1:]...)
+		return
+	}
+	// turn the message into the withdrawal of all the routes in it
+	attrs := make([]bgp.PathAttributeInterface, 0, 1)
+	for _, a := range body.PathAttributes {
+		switch a := a.(type) {
+		case *bgp.PathAttributeMpReachNLRI:
+			attrs = append(attrs, bgp.NewPathAttributeMpUnreachNLRI(a.Value))
+		case *bgp.PathAttributeMpUnreachNLRI:
+			attrs = append(attrs, a)
+		}
+	}
+	body.PathAttributes = attrs
+	body.WithdrawnRoutes = append(body.WithdrawnRoutes, body.NLRI...)
+	body.NLRI = []*bgp.IPAddrPrefix{}
+}
+
+// unrecognizedCommunities returns the communities of the path in the
+// range reserved for the well-known communities which gobgp doesn't
+// recognize.
+func unrecognizedCommunities(path *table.Path) []uint32 {
+	var l []uint32
+	for _, v := range path.GetCommunities() {
+		if bgp.IsReservedCommunity(v) && !bgp.IsWellKnownCommunity(v) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// logUnrecognizedCommunities logs the reserved communities which
+// gobgp doesn't recognize, when configured to be strict about the
+// well-known communities. Only the recognized ones are acted upon, the
+// others are passed through as they are. The paths of an UPDATE message
+// share the attributes, so only the first advertised one is checked.
+func (fsm *FSM) logUnrecognizedCommunities(pathList []*table.Path) {
+	if !fsm.pConf.Config.StrictWellKnownCommunities {
+		return
+	}
+	for _, path := range pathList {
+		if path.IsWithdraw {
+			continue
+		}
+		if l := unrecognizedCommunities(path); len(l) > 0 {
+			log.WithFields(log.Fields{
+				"Topic":       "Peer",
+				"Key":         fsm.pConf.Config.NeighborAddress,
+				"Communities": bgp.NewPathAttributeCommunities(l).String(),
+			}).Info("pass through unrecognized reserved communities")
+		}
+		return
+	}
+}
+
+// checkPathLimits returns an error when the path received from the
+// peer exceeds the configured limits. Such a path is treated as
+// withdrawn (RFC7606). The communities exceeding the limits are
+// truncated instead if configured so.
+func (fsm *FSM) checkPathLimits(path *table.Path) error {
+	c := fsm.pConf.Config
+	if max := int(c.MaxAsPathLength); max > 0 {
+		if l := path.GetAsPathLen(); l > max {
+			return fmt.Errorf("AS_PATH length %d exceeds %d", l, max)
+		}
+	}
+	truncate := c.CommunityLimitAction == config.COMMUNITY_LIMIT_ACTION_TYPE_TRUNCATE
+	if max := int(c.MaxCommunities); max > 0 {
+		if l := path.GetCommunities(); len(l) > max {
+			if !truncate {
+				return fmt.Errorf("number of communities %d exceeds %d", len(l), max)
+			}
+			fsm.logTruncated(path, "communities", len(l), max)
+			path.SetCommunities(l[:max], true)
+		}
+	}
+	if max := int(c.MaxExtCommunities); max > 0 {
+		if l := path.GetExtCommunities(); len(l) > max {
+			if !truncate {
+				return fmt.Errorf("number of extended communities %d exceeds %d", len(l), max)
+			}
+			fsm.logTruncated(path, "extended communities", len(l), max)
+			path.SetExtCommunities(l[:max], true)
+		}
+	}
+	return nil
+}
+
+func (fsm *FSM) logTruncated(path *table.Path, name string, length, max int) {
+	log.WithFields(log.Fields{
+		"Topic":  "Peer",
+		"Key":    fsm.pConf.Config.NeighborAddress,
+		"Prefix": path.GetNlri().String(),
+		"Length": length,
+		"Max":    max,
+	}).Warnf("too many %s, truncate", name)
+}
+
+// rewritesNexthop tells whether the next hops of the paths received
+// from the multi-hop eBGP peer are rewritten when unresolvable.
+func (fsm *FSM) rewritesNexthop() bool {
+	c := fsm.pConf.EbgpMultihop.Config
+	return c.Enabled && c.RewriteUnresolvableNexthop && fsm.pConf.Config.PeerType == config.PEER_TYPE_EXTERNAL
+}
+
+// rewriteUnresolvableNexthop rewrites the next hop of the path received
+// from the multi-hop eBGP peer to the address of the peer when it isn't
+// on any of the connected subnets, which would blackhole the traffic.
+func (fsm *FSM) rewriteUnresolvableNexthop(path *table.Path, connected []net.Addr) {
+	nexthop := path.GetNexthop()
+	addr := net.ParseIP(fsm.pConf.Config.NeighborAddress)
+	if len(nexthop) == 0 || addr == nil || nexthop.Equal(addr) || (nexthop.To4() == nil) != (addr.To4() == nil) {
+		return
+	}
+	for _, a := range connected {
+		if n, ok := a.(*net.IPNet); ok && n.Contains(nexthop) {
+			return
+		}
+	}
+	log.WithFields(log.Fields{
+		"Topic":   "Peer",
+		"Key":     fsm.pConf.Config.NeighborAddress,
+		"Prefix":  path.GetNlri().String(),
+		"Nexthop": nexthop,
+	}).Debug("unresolvable nexthop, rewrite to the neighbor address")
+	path.SetNexthop(addr)
+}
+
+// clampMed lowers the MED of the path received from the peer to the
+// configured maximum.
+func (fsm *FSM) clampMed(path *table.Path) {
+	max := fsm.pConf.Config.MaxMed
+	if max == 0 {
+		return
+	}
+	if med, err := path.GetMed(); err == nil && med > max {
+		log.WithFields(log.Fields{
+			"Topic":  "Peer",
+			"Key":    fsm.pConf.Config.NeighborAddress,
+			"Prefix": path.GetNlri().String(),
+			"Med":    med,
+			"Max":    max,
+		}).Debug("clamp MED")
+		path.SetMed(int64(max), true)
+	}
+}
+
+// collapseAsPrepends makes the best path selection ignore the AS_PATH
+// prepends of the path received from the peer when configured.
+func (fsm *FSM) collapseAsPrepends(path *table.Path) {
+	if !fsm.pConf.Config.CollapseAsPathPrepends {
+		return
+	}
+	path.SetCollapseAsPrepends(true)
+	if l, c := path.GetAsPathLen(), table.CollapsedAsPathLen(path.GetAsPath()); l != c {
+		log.WithFields(log.Fields{
+			"Topic":     "Peer",
+			"Key":       fsm.pConf.Config.NeighborAddress,
+			"Prefix":    path.GetNlri().String(),
+			"AsPath":    path.GetAsString(),
+			"Length":    l,
+			"Collapsed": c,
+		}).Debug("collapse AS_PATH prepends for best path selection")
+	}
+}
+
+// checkAsTrans logs the path with AS_TRANS which couldn't be replaced
+// with the AS4 information, and returns an error if such paths are to
+// be rejected.
+func (fsm *FSM) checkAsTrans(path *table.Path) error {
+	if !path.HasAsTrans() {
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"Topic":  "Peer",
+		"Key":    fsm.pConf.Config.NeighborAddress,
+		"Prefix": path.GetNlri().String(),
+		"AsPath": path.GetAsPath(),
+	}).Warn("AS_TRANS remains without AS4 information")
+	if fsm.pConf.Config.RejectAsTrans {
+		return fmt.Errorf("AS_TRANS without AS4 information")
+	}
+	return nil
+}
+
+// checkOriginatorId returns an error when the path received from the
+// peer carries our BGP Identifier as the ORIGINATOR_ID, that is, the
+// route was reflected back to us (RFC4456 8). Such a path is treated
+// as withdrawn.
+func (fsm *FSM) checkOriginatorId(path *table.Path) error {
+	id := path.GetOriginatorID()
+	if id == nil {
+		return nil
+	}
+	if s := id.String(); s == fsm.gConf.Config.RouterId || s == config.LocalRouterId(fsm.gConf, fsm.pConf) {
+		fsm.pConf.State.OriginatorIdLoops++
+		return fmt.Errorf("ORIGINATOR_ID %s is mine", s)
+	}
+	return nil
+}
+
 func (h *FSMHandler) recvMessageWithError() error {
 	headerBuf, err := readAll(h.conn, bgp.BGP_HEADER_LENGTH)
 	if err != nil {
@@ -504,10 +1442,11 @@ func (h *FSMHandler) recvMessageWithError() error {
 	err = hd.DecodeFromBytes(headerBuf)
 	if err != nil {
 		h.fsm.bgpMessageStateUpdate(0, true)
+		state, _ := h.fsm.State()
 		log.WithFields(log.Fields{
 			"Topic": "Peer",
 			"Key":   h.fsm.pConf.Config.NeighborAddress,
-			"State": h.fsm.state,
+			"State": state,
 			"error": err,
 		}).Warn("malformed BGP Header")
 		h.msgCh <- &FsmMsg{
@@ -525,8 +1464,10 @@ func (h *FSMHandler) recvMessageWithError() error {
 		return err
 	}
 
+	h.fsm.dumpMessage("received", headerBuf, bodyBuf)
+
 	now := time.Now()
-	m, err := bgp.ParseBGPBody(hd, bodyBuf)
+	m, err := bgp.ParseBGPBody(hd, bodyBuf, h.fsm.MarshallingOption())
 	if err == nil {
 		h.fsm.bgpMessageStateUpdate(m.Header.Type, true)
 		err = bgp.ValidateBGPMessage(m)
@@ -540,21 +1481,29 @@ func (h *FSMHandler) recvMessageWithError() error {
 		timestamp: now,
 	}
 	if err != nil {
+		state, _ := h.fsm.State()
 		log.WithFields(log.Fields{
 			"Topic": "Peer",
 			"Key":   h.fsm.pConf.Config.NeighborAddress,
-			"State": h.fsm.state,
+			"State": state,
 			"error": err,
 		}).Warn("malformed BGP message")
 		fmsg.MsgData = err
 	} else {
 		fmsg.MsgData = m
-		if h.fsm.state == bgp.BGP_FSM_ESTABLISHED {
+		if state, _ := h.fsm.State(); state == bgp.BGP_FSM_ESTABLISHED {
 			switch m.Header.Type {
 			case bgp.BGP_MSG_UPDATE:
+				if err := h.limitUpdateRate(); err != nil {
+					fmsg.MsgData = err
+					h.msgCh <- fmsg
+					return err
+				}
 				body := m.Body.(*bgp.BGPUpdate)
 				confedCheck := !config.IsConfederationMember(h.fsm.gConf, h.fsm.pConf) && config.IsEBGPPeer(h.fsm.gConf, h.fsm.pConf)
-				_, err := bgp.ValidateUpdateMsg(body, h.fsm.rfMap, confedCheck)
+				h.fsm.fillMissingOrigin(body)
+				h.fsm.handleOrphanAs4Path(body)
+				_, err := bgp.ValidateUpdateMsg(body, h.fsm.familyMap(), confedCheck)
 				if err != nil {
 					log.WithFields(log.Fields{
 						"Topic": "Peer",
@@ -563,9 +1512,50 @@ func (h *FSMHandler) recvMessageWithError() error {
 					}).Warn("malformed BGP update message")
 					fmsg.MsgData = err
 				} else {
+					if eor, rf := body.IsEndOfRib(); eor {
+						fmsg.endOfRib = rf
+					}
 					// FIXME: we should use the original message for bmp/mrt
 					table.UpdatePathAttrs4ByteAs(body)
 					fmsg.PathList = table.ProcessMessage(m, h.fsm.peerInfo, fmsg.timestamp)
+					if confedCheck && !h.fsm.pConf.Config.HonorEbgpLocalPref {
+						for _, path := range fmsg.PathList {
+							path.RemoveLocalPref()
+						}
+					}
+					var connected []net.Addr
+					if h.fsm.rewritesNexthop() {
+						connected, _ = net.InterfaceAddrs()
+					}
+					for _, path := range fmsg.PathList {
+						if path.IsWithdraw {
+							continue
+						}
+						err := h.fsm.checkOriginatorId(path)
+						if err == nil {
+							err = h.fsm.checkPathLimits(path)
+						}
+						if err == nil {
+							err = h.fsm.checkAsTrans(path)
+						}
+						if err != nil {
+							log.WithFields(log.Fields{
+								"Topic":  "Peer",
+								"Key":    h.fsm.pConf.Config.NeighborAddress,
+								"Prefix": path.GetNlri().String(),
+								"error":  err,
+							}).Warn("treat as withdraw")
+							path.IsWithdraw = true
+							continue
+						}
+						h.fsm.clampMed(path)
+						h.fsm.collapseAsPrepends(path)
+						if h.fsm.rewritesNexthop() {
+							h.fsm.rewriteUnresolvableNexthop(path, connected)
+						}
+					}
+					h.fsm.logUnrecognizedCommunities(fmsg.PathList)
+					h.fsm.logPathAttributes("received path", fmsg.PathList)
 					id := h.fsm.pConf.Config.NeighborAddress
 					policyMutex.RLock()
 					for _, path := range fmsg.PathList {
@@ -580,12 +1570,7 @@ func (h *FSMHandler) recvMessageWithError() error {
 				copy(fmsg.payload[len(headerBuf):], bodyBuf)
 				fallthrough
 			case bgp.BGP_MSG_KEEPALIVE:
-				// if the lenght of h.holdTimerResetCh
-				// isn't zero, the timer will be reset
-				// soon anyway.
-				if len(h.holdTimerResetCh) == 0 {
-					h.holdTimerResetCh <- true
-				}
+				h.resetHoldTimer(time.Now())
 				if m.Header.Type == bgp.BGP_MSG_KEEPALIVE {
 					return nil
 				}
@@ -598,7 +1583,12 @@ func (h *FSMHandler) recvMessageWithError() error {
 					"Subcode": body.ErrorSubcode,
 					"Data":    body.Data,
 				}).Warn("received notification")
-				h.errorCh <- FSM_NOTIFICATION_RECV
+				h.fsm.notification = body
+				if state, _ := h.fsm.State(); state == bgp.BGP_FSM_ESTABLISHED && h.fsm.retainOnNotification(body) {
+					h.errorCh <- FSM_GRACEFUL_RESTART
+				} else {
+					h.errorCh <- FSM_NOTIFICATION_RECV
+				}
 				return nil
 			}
 		}
@@ -607,6 +1597,50 @@ func (h *FSMHandler) recvMessageWithError() error {
 	return err
 }
 
+// holdTimerResetInterval returns the minimum interval between the
+// resets of the hold timer, zero to reset it on every message.
+func (fsm *FSM) holdTimerResetInterval() time.Duration {
+	return time.Duration(fsm.pConf.Timers.Config.HoldTimerResetInterval * float64(time.Second))
+}
+
+// resetHoldTimer asks the established state to reset the hold timer
+// for a message received at now. Within the configured interval since
+// the last reset, only the time is recorded; the established state
+// checks it before expiring the hold timer.
+func (h *FSMHandler) resetHoldTimer(now time.Time) {
+	atomic.StoreInt64(&h.lastRecv, now.UnixNano())
+	if i := h.fsm.holdTimerResetInterval(); i > 0 {
+		if now.Sub(h.lastHoldReset) < i {
+			return
+		}
+		h.lastHoldReset = now
+	}
+	// if the lenght of h.holdTimerResetCh
+	// isn't zero, the timer will be reset
+	// soon anyway.
+	if len(h.holdTimerResetCh) == 0 {
+		h.holdTimerResetCh <- true
+	}
+}
+
+// holdTimerRemaining returns how long the hold timer has yet to run
+// since the last received message when resets were coalesced, zero
+// when it has expired.
+func (h *FSMHandler) holdTimerRemaining(now time.Time) time.Duration {
+	if h.fsm.holdTimerResetInterval() == 0 {
+		return 0
+	}
+	last := atomic.LoadInt64(&h.lastRecv)
+	if last == 0 {
+		return 0
+	}
+	hold := time.Second * time.Duration(h.fsm.pConf.Timers.State.NegotiatedHoldTime)
+	if d := time.Unix(0, last).Add(hold).Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
 func (h *FSMHandler) recvMessage() error {
 	h.recvMessageWithError()
 	return nil
@@ -640,15 +1674,29 @@ func open2Cap(open *bgp.BGPOpen, n *config.Neighbor) (map[bgp.BGPCapabilityCode]
 			}
 		}
 	} else {
+		// a peer without the multiprotocol capability exchanges
+		// the unicast routes of the family of the session, IPv4
+		// unless the session is over IPv6.
 		rfMap = make(map[bgp.RouteFamily]bool)
-		rfMap[bgp.RF_IPv4_UC] = true
+		rfMap[transportFamily(n)] = true
 	}
 	return capMap, rfMap
 }
 
+// transportFamily returns the unicast family of the address of the
+// transport session with the neighbor.
+func transportFamily(n *config.Neighbor) bgp.RouteFamily {
+	if ip := config.ParseAddress(n.Config.NeighborAddress); ip != nil && ip.To4() == nil {
+		return bgp.RF_IPv6_UC
+	}
+	return bgp.RF_IPv4_UC
+}
+
 func (h *FSMHandler) opensent() (bgp.FSMState, FsmStateReason) {
 	fsm := h.fsm
+	fsm.lock.RLock()
 	m := buildopen(fsm.gConf, fsm.pConf)
+	fsm.lock.RUnlock()
 	b, _ := m.Serialize()
 	fsm.conn.Write(b)
 	fsm.bgpMessageStateUpdate(m.Header.Type, false)
@@ -663,6 +1711,8 @@ func (h *FSMHandler) opensent() (bgp.FSMState, FsmStateReason) {
 	// A HoldTimer value of 4 minutes is suggested as a "large value"
 	// for the HoldTimer
 	holdTimer := time.NewTimer(time.Second * time.Duration(fsm.opensentHoldTime))
+	fsm.deadlines.set(&fsm.deadlines.hold, time.Second*time.Duration(fsm.opensentHoldTime))
+	defer fsm.deadlines.set(&fsm.deadlines.hold, 0)
 
 	for {
 		select {
@@ -674,10 +1724,11 @@ func (h *FSMHandler) opensent() (bgp.FSMState, FsmStateReason) {
 				break
 			}
 			conn.Close()
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 			}).Warn("Closed an accepted connection")
 		case e := <-h.msgCh:
 			switch e.MsgData.(type) {
@@ -691,27 +1742,24 @@ func (h *FSMHandler) opensent() (bgp.FSMState, FsmStateReason) {
 						fsm.sendNotificatonFromErrorMsg(h.conn, err.(*bgp.MessageError))
 						return bgp.BGP_FSM_IDLE, FSM_INVALID_MSG
 					}
+					fsm.lock.Lock()
 					fsm.peerInfo.ID = body.ID
 					fsm.capMap, fsm.rfMap = open2Cap(body, fsm.pConf)
+					fsm.lock.Unlock()
+					option := fsm.addPathOption()
+					fsm.lock.Lock()
+					fsm.marshalOption = option
+					fsm.lock.Unlock()
 
-					// calculate HoldTime
-					// RFC 4271 P.13
-					// a BGP speaker MUST calculate the value of the Hold Timer
-					// by using the smaller of its configured Hold Time and the Hold Time
-					// received in the OPEN message.
-					holdTime := float64(body.HoldTime)
-					myHoldTime := fsm.pConf.Timers.Config.HoldTime
-					if holdTime > myHoldTime {
-						fsm.pConf.Timers.State.NegotiatedHoldTime = myHoldTime
-					} else {
-						fsm.pConf.Timers.State.NegotiatedHoldTime = holdTime
+					if err := fsm.checkFourOctetAs(); err != nil {
+						fsm.sendNotificatonFromErrorMsg(h.conn, err.(*bgp.MessageError))
+						return bgp.BGP_FSM_IDLE, FSM_INVALID_MSG
 					}
 
-					keepalive := fsm.pConf.Timers.Config.KeepaliveInterval
-					if n := fsm.pConf.Timers.State.NegotiatedHoldTime; n < myHoldTime {
-						keepalive = n / 3
+					if err := fsm.negotiateHoldTime(body.HoldTime); err != nil {
+						fsm.sendNotificatonFromErrorMsg(h.conn, err.(*bgp.MessageError))
+						return bgp.BGP_FSM_IDLE, FSM_INVALID_MSG
 					}
-					fsm.pConf.Timers.State.KeepaliveInterval = keepalive
 
 					msg := bgp.NewBGPKeepAliveMessage()
 					b, _ := msg.Serialize()
@@ -719,18 +1767,17 @@ func (h *FSMHandler) opensent() (bgp.FSMState, FsmStateReason) {
 					fsm.bgpMessageStateUpdate(msg.Header.Type, false)
 					return bgp.BGP_FSM_OPENCONFIRM, 0
 				} else {
-					// send notification?
-					h.conn.Close()
-					return bgp.BGP_FSM_IDLE, FSM_INVALID_MSG
+					return bgp.BGP_FSM_IDLE, fsm.unexpectedMessage(h.conn, m, bgp.BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENSENT_STATE)
 				}
 			case *bgp.MessageError:
 				fsm.sendNotificatonFromErrorMsg(h.conn, e.MsgData.(*bgp.MessageError))
 				return bgp.BGP_FSM_IDLE, FSM_INVALID_MSG
 			default:
+				state, _ := fsm.State()
 				log.WithFields(log.Fields{
 					"Topic": "Peer",
 					"Key":   fsm.pConf.Config.NeighborAddress,
-					"State": fsm.state,
+					"State": state,
 					"Data":  e.MsgData,
 				}).Panic("unknown msg type")
 			}
@@ -749,10 +1796,11 @@ func (h *FSMHandler) opensent() (bgp.FSMState, FsmStateReason) {
 					h.conn.Close()
 					return bgp.BGP_FSM_IDLE, FSM_ADMIN_DOWN
 				case ADMIN_STATE_UP:
+					state, _ := fsm.State()
 					log.WithFields(log.Fields{
 						"Topic":      "Peer",
 						"Key":        fsm.pConf.Config.NeighborAddress,
-						"State":      fsm.state,
+						"State":      state,
 						"AdminState": s.String(),
 					}).Panic("code logic bug")
 				}
@@ -761,21 +1809,96 @@ func (h *FSMHandler) opensent() (bgp.FSMState, FsmStateReason) {
 	}
 }
 
-func keepaliveTicker(fsm *FSM) *time.Ticker {
+// negotiateHoldTime sets the negotiated hold time and keepalive
+// interval from the hold time received in the OPEN message. The hold
+// time below the configured minimum is rejected or raised to it, and
+// zero, which disables the keepalives and the hold timer, is rejected.
+func (fsm *FSM) negotiateHoldTime(received uint16) error {
+	// calculate HoldTime
+	// RFC 4271 P.13
+	// a BGP speaker MUST calculate the value of the Hold Timer
+	// by using the smaller of its configured Hold Time and the Hold Time
+	// received in the OPEN message.
+	holdTime := float64(received)
+	myHoldTime := fsm.pConf.Timers.Config.HoldTime
+	if holdTime > myHoldTime {
+		holdTime = myHoldTime
+	}
+
+	// the peer expires its hold timer at the smaller value even when
+	// ours is clamped, keep sending keepalives frequently enough for it
+	keepalive := fsm.pConf.Timers.Config.KeepaliveInterval
+	if holdTime < myHoldTime || fsm.pConf.Timers.Config.DeriveKeepaliveInterval {
+		keepalive = holdTime / 3
+	}
+
+	if min := fsm.pConf.Timers.Config.MinHoldTime; min > 0 && holdTime < min {
+		// raising zero to the minimum would expire a hold timer
+		// the peer never sends keepalives for
+		if holdTime == 0 || fsm.pConf.Timers.Config.MinHoldTimeAction != config.MIN_HOLD_TIME_ACTION_TYPE_CLAMP {
+			return bgp.NewMessageError(bgp.BGP_ERROR_OPEN_MESSAGE_ERROR, bgp.BGP_ERROR_SUB_UNACCEPTABLE_HOLD_TIME, nil, fmt.Sprintf("hold time %v is below the minimum %v", holdTime, min))
+		}
+		log.WithFields(log.Fields{
+			"Topic":    "Peer",
+			"Key":      fsm.pConf.Config.NeighborAddress,
+			"HoldTime": holdTime,
+			"Minimum":  min,
+		}).Warn("negotiated hold time is below the minimum, clamp")
+		holdTime = min
+	}
+	fsm.pConf.Timers.State.NegotiatedHoldTime = holdTime
+	fsm.pConf.Timers.State.KeepaliveInterval = keepalive
+	return nil
+}
+
+// keepaliveInterval returns the interval of the KEEPALIVE messages, or
+// zero when they aren't sent because the negotiated hold time is zero.
+func keepaliveInterval(fsm *FSM) time.Duration {
 	negotiatedTime := fsm.pConf.Timers.State.NegotiatedHoldTime
 	if negotiatedTime == 0 {
-		return &time.Ticker{}
+		return 0
 	}
 	sec := time.Second * time.Duration(fsm.pConf.Timers.State.KeepaliveInterval)
 	if sec == 0 {
 		sec = 1
 	}
-	return time.NewTicker(sec)
+	return sec
+}
+
+func keepaliveTicker(fsm *FSM) *time.Ticker {
+	interval := keepaliveInterval(fsm)
+	if interval == 0 {
+		return &time.Ticker{}
+	}
+	return time.NewTicker(interval)
+}
+
+// keepaliveJitter returns the keepalive interval reduced by a random
+// amount of up to 25% (RFC4271 10) so that the KEEPALIVE messages of
+// the sessions don't synchronize.
+func keepaliveJitter(interval time.Duration, r *rand.Rand) time.Duration {
+	return interval - time.Duration(r.Int63n(int64(interval)/4+1))
+}
+
+// keepaliveDelay returns how long the KEEPALIVE due the period after
+// the last message sent can still be skipped, or zero when it has to
+// be sent now. Any message restarts the hold timer of the peer, so no
+// KEEPALIVE is needed while other messages are being sent.
+func keepaliveDelay(period time.Duration, lastSent, now time.Time) time.Duration {
+	if lastSent.IsZero() {
+		return 0
+	}
+	if d := period - now.Sub(lastSent); d > 0 {
+		return d
+	}
+	return 0
 }
 
 func (h *FSMHandler) openconfirm() (bgp.FSMState, FsmStateReason) {
 	fsm := h.fsm
 	ticker := keepaliveTicker(fsm)
+	fsm.deadlines.set(&fsm.deadlines.keepalive, keepaliveInterval(fsm))
+	defer fsm.deadlines.set(&fsm.deadlines.keepalive, 0)
 	h.msgCh = make(chan *FsmMsg)
 	h.conn = fsm.conn
 
@@ -789,6 +1912,8 @@ func (h *FSMHandler) openconfirm() (bgp.FSMState, FsmStateReason) {
 		// sets the HoldTimer according to the negotiated value
 		holdTimer = time.NewTimer(time.Second * time.Duration(fsm.pConf.Timers.State.NegotiatedHoldTime))
 	}
+	fsm.deadlines.set(&fsm.deadlines.hold, time.Second*time.Duration(fsm.pConf.Timers.State.NegotiatedHoldTime))
+	defer fsm.deadlines.set(&fsm.deadlines.hold, 0)
 
 	for {
 		select {
@@ -800,12 +1925,14 @@ func (h *FSMHandler) openconfirm() (bgp.FSMState, FsmStateReason) {
 				break
 			}
 			conn.Close()
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 			}).Warn("Closed an accepted connection")
 		case <-ticker.C:
+			fsm.deadlines.set(&fsm.deadlines.keepalive, keepaliveInterval(fsm))
 			m := bgp.NewBGPKeepAliveMessage()
 			b, _ := m.Serialize()
 			// TODO: check error
@@ -815,22 +1942,21 @@ func (h *FSMHandler) openconfirm() (bgp.FSMState, FsmStateReason) {
 			switch e.MsgData.(type) {
 			case *bgp.BGPMessage:
 				m := e.MsgData.(*bgp.BGPMessage)
-				nextState := bgp.BGP_FSM_IDLE
 				if m.Header.Type == bgp.BGP_MSG_KEEPALIVE {
-					nextState = bgp.BGP_FSM_ESTABLISHED
-				} else {
-					// send notification ?
-					h.conn.Close()
+					return bgp.BGP_FSM_ESTABLISHED, 0
 				}
-				return nextState, 0
+				// including a duplicate OPEN, which was already
+				// received in OPENSENT (RFC 4271 8.2.2)
+				return bgp.BGP_FSM_IDLE, fsm.unexpectedMessage(h.conn, m, bgp.BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENCONFIRM_STATE)
 			case *bgp.MessageError:
 				fsm.sendNotificatonFromErrorMsg(h.conn, e.MsgData.(*bgp.MessageError))
 				return bgp.BGP_FSM_IDLE, FSM_INVALID_MSG
 			default:
+				state, _ := fsm.State()
 				log.WithFields(log.Fields{
 					"Topic": "Peer",
 					"Key":   fsm.pConf.Config.NeighborAddress,
-					"State": fsm.state,
+					"State": state,
 					"Data":  e.MsgData,
 				}).Panic("unknown msg type")
 			}
@@ -849,10 +1975,11 @@ func (h *FSMHandler) openconfirm() (bgp.FSMState, FsmStateReason) {
 					h.conn.Close()
 					return bgp.BGP_FSM_IDLE, FSM_ADMIN_DOWN
 				case ADMIN_STATE_UP:
+					state, _ := fsm.State()
 					log.WithFields(log.Fields{
 						"Topic":      "Peer",
 						"Key":        fsm.pConf.Config.NeighborAddress,
-						"State":      fsm.state,
+						"State":      state,
 						"AdminState": s.String(),
 					}).Panic("code logic bug")
 				}
@@ -864,14 +1991,33 @@ func (h *FSMHandler) openconfirm() (bgp.FSMState, FsmStateReason) {
 func (h *FSMHandler) sendMessageloop() error {
 	conn := h.conn
 	fsm := h.fsm
-	ticker := keepaliveTicker(fsm)
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	interval := keepaliveInterval(fsm)
+	var period time.Duration
+	var timer *time.Timer
+	var keepaliveCh <-chan time.Time
+	if interval > 0 {
+		period = keepaliveJitter(interval, r)
+		timer = time.NewTimer(period)
+		defer timer.Stop()
+		keepaliveCh = timer.C
+		fsm.deadlines.set(&fsm.deadlines.keepalive, period)
+		defer fsm.deadlines.set(&fsm.deadlines.keepalive, 0)
+	}
+	var lastSent time.Time
 	send := func(m *bgp.BGPMessage) error {
-		b, err := m.Serialize()
+		if m.Header.Type == bgp.BGP_MSG_NOTIFICATION && fsm.suppressNotification(m.Body.(*bgp.BGPNotification)) {
+			fsm.notification = m.Body.(*bgp.BGPNotification)
+			h.errorCh <- FSM_NOTIFICATION_SENT
+			return fmt.Errorf("closed")
+		}
+		b, err := m.Serialize(fsm.MarshallingOption())
 		if err != nil {
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 				"Data":  err,
 			}).Warn("failed to serialize")
 			fsm.bgpMessageStateUpdate(0, false)
@@ -883,31 +2029,40 @@ func (h *FSMHandler) sendMessageloop() error {
 		}
 		_, err = conn.Write(b)
 		if err != nil {
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 				"Data":  err,
 			}).Warn("failed to send")
 			h.errorCh <- FSM_WRITE_FAILED
 			return fmt.Errorf("closed")
 		}
 		fsm.bgpMessageStateUpdate(m.Header.Type, false)
+		fsm.dumpMessage("sent", b)
+		if m.Header.Type == bgp.BGP_MSG_UPDATE {
+			fsm.logUpdateAttributes("sent path", m.Body.(*bgp.BGPUpdate))
+		}
+		lastSent = time.Now()
 
 		if m.Header.Type == bgp.BGP_MSG_NOTIFICATION {
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 				"Data":  m,
 			}).Warn("sent notification")
+			fsm.notification = m.Body.(*bgp.BGPNotification)
 			h.errorCh <- FSM_NOTIFICATION_SENT
 			return fmt.Errorf("closed")
 		} else {
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 				"data":  m,
 			}).Debug("sent")
 		}
@@ -928,7 +2083,7 @@ func (h *FSMHandler) sendMessageloop() error {
 			// we always try to send. in case b), the
 			// connection was already closed so it
 			// correctly works in both cases.
-			if h.fsm.state == bgp.BGP_FSM_ESTABLISHED {
+			if state, _ := h.fsm.State(); state == bgp.BGP_FSM_ESTABLISHED {
 				send(bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_PEER_DECONFIGURED, nil))
 			}
 			return nil
@@ -936,10 +2091,19 @@ func (h *FSMHandler) sendMessageloop() error {
 			if err := send(m); err != nil {
 				return nil
 			}
-		case <-ticker.C:
+		case <-keepaliveCh:
+			if d := keepaliveDelay(period, lastSent, time.Now()); d > 0 {
+				// another message was sent meanwhile
+				timer.Reset(d)
+				fsm.deadlines.set(&fsm.deadlines.keepalive, d)
+				continue
+			}
 			if err := send(bgp.NewBGPKeepAliveMessage()); err != nil {
 				return nil
 			}
+			period = keepaliveJitter(interval, r)
+			timer.Reset(period)
+			fsm.deadlines.set(&fsm.deadlines.keepalive, period)
 
 		}
 	}
@@ -959,6 +2123,13 @@ func (h *FSMHandler) established() (bgp.FSMState, FsmStateReason) {
 	h.conn = fsm.conn
 	h.t.Go(h.sendMessageloop)
 	h.msgCh = h.incoming
+	h.updateLimiter = newUpdateRateLimiter(fsm.pConf, time.Now())
+	if fsm.pConf.Config.CollapseAsPathPrepends {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   fsm.pConf.Config.NeighborAddress,
+		}).Info("AS_PATH prepends of received routes are ignored in best path selection")
+	}
 	h.t.Go(h.recvMessageloop)
 
 	var holdTimer *time.Timer
@@ -967,6 +2138,13 @@ func (h *FSMHandler) established() (bgp.FSMState, FsmStateReason) {
 	} else {
 		holdTimer = time.NewTimer(time.Second * time.Duration(fsm.pConf.Timers.State.NegotiatedHoldTime))
 	}
+	fsm.deadlines.set(&fsm.deadlines.hold, time.Second*time.Duration(fsm.pConf.Timers.State.NegotiatedHoldTime))
+	defer fsm.deadlines.set(&fsm.deadlines.hold, 0)
+	// drop the expiry requested for the previous session
+	select {
+	case <-fsm.expireHoldCh:
+	default:
+	}
 
 	for {
 		select {
@@ -977,28 +2155,43 @@ func (h *FSMHandler) established() (bgp.FSMState, FsmStateReason) {
 				break
 			}
 			conn.Close()
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 			}).Warn("Closed an accepted connection")
 		case err := <-h.errorCh:
 			h.conn.Close()
 			h.t.Kill(nil)
 			return bgp.BGP_FSM_IDLE, err
 		case <-holdTimer.C:
+			if d := h.holdTimerRemaining(time.Now()); d > 0 {
+				// a message was received after the last reset
+				holdTimer.Reset(d)
+				fsm.deadlines.set(&fsm.deadlines.hold, d)
+				continue
+			}
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 				"data":  bgp.BGP_FSM_ESTABLISHED,
 			}).Warn("hold timer expired")
-			m := bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_HOLD_TIMER_EXPIRED, 0, nil)
-			h.outgoing <- m
-			return bgp.BGP_FSM_IDLE, FSM_HOLD_TIMER_EXPIRED
+			return h.holdTimerExpired()
+		case <-fsm.expireHoldCh:
+			state, _ := fsm.State()
+			log.WithFields(log.Fields{
+				"Topic": "Peer",
+				"Key":   fsm.pConf.Config.NeighborAddress,
+				"State": state,
+			}).Warn("hold timer expired by request")
+			return h.holdTimerExpired()
 		case <-h.holdTimerResetCh:
 			if fsm.pConf.Timers.State.NegotiatedHoldTime != 0 {
 				holdTimer.Reset(time.Second * time.Duration(fsm.pConf.Timers.State.NegotiatedHoldTime))
+				fsm.deadlines.set(&fsm.deadlines.hold, time.Second*time.Duration(fsm.pConf.Timers.State.NegotiatedHoldTime))
 			}
 		case s := <-fsm.adminStateCh:
 			err := h.changeAdminState(s)
@@ -1014,15 +2207,26 @@ func (h *FSMHandler) established() (bgp.FSMState, FsmStateReason) {
 	}
 }
 
+func (h *FSMHandler) holdTimerExpired() (bgp.FSMState, FsmStateReason) {
+	m := bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_HOLD_TIMER_EXPIRED, 0, nil)
+	h.outgoing <- m
+	if h.fsm.gracefulRestartNotification() {
+		// RFC8538 the peer starts graceful restart
+		// instead of flushing our routes.
+		return bgp.BGP_FSM_IDLE, FSM_GRACEFUL_RESTART
+	}
+	return bgp.BGP_FSM_IDLE, FSM_HOLD_TIMER_EXPIRED
+}
+
 func (h *FSMHandler) loop() error {
 	fsm := h.fsm
 	ch := make(chan bgp.FSMState)
-	oldState := fsm.state
+	oldState, _ := fsm.State()
+	var reason FsmStateReason
 
 	f := func() error {
 		nextState := bgp.FSMState(-1)
-		var reason FsmStateReason
-		switch fsm.state {
+		switch oldState {
 		case bgp.BGP_FSM_IDLE:
 			nextState, reason = h.idle()
 			// case bgp.BGP_FSM_CONNECT:
@@ -1036,7 +2240,6 @@ func (h *FSMHandler) loop() error {
 		case bgp.BGP_FSM_ESTABLISHED:
 			nextState, reason = h.established()
 		}
-		fsm.reason = reason
 		ch <- nextState
 		return nil
 	}
@@ -1046,24 +2249,35 @@ func (h *FSMHandler) loop() error {
 	nextState := <-ch
 
 	if nextState == bgp.BGP_FSM_ESTABLISHED && oldState == bgp.BGP_FSM_OPENCONFIRM {
+		state, _ := fsm.State()
 		log.WithFields(log.Fields{
 			"Topic": "Peer",
 			"Key":   fsm.pConf.Config.NeighborAddress,
-			"State": fsm.state,
+			"State": state,
 		}).Info("Peer Up")
 	}
 
 	if oldState == bgp.BGP_FSM_ESTABLISHED {
+		state, _ := fsm.State()
 		log.WithFields(log.Fields{
 			"Topic":  "Peer",
 			"Key":    fsm.pConf.Config.NeighborAddress,
-			"State":  fsm.state,
-			"Reason": fsm.reason,
+			"State":  state,
+			"Reason": reason,
 		}).Info("Peer Down")
 	}
 
-	e := time.AfterFunc(time.Second*120, func() {
-		log.Fatal("failed to free the fsm.h.t for ", fsm.pConf.Config.NeighborAddress, oldState, nextState)
+	queued := h.queuedMessages()
+	timeout := reapTime(fsm.gConf, queued)
+	e := time.AfterFunc(timeout, func() {
+		log.WithFields(log.Fields{
+			"Topic":   "Peer",
+			"Key":     fsm.pConf.Config.NeighborAddress,
+			"Old":     oldState,
+			"New":     nextState,
+			"Timeout": timeout,
+			"Queued":  queued,
+		}).Fatal("failed to free the fsm.h.t")
 	})
 	h.t.Wait()
 	e.Stop()
@@ -1075,19 +2289,43 @@ func (h *FSMHandler) loop() error {
 			MsgSrc:  fsm.pConf.Config.NeighborAddress,
 			MsgDst:  fsm.pConf.Transport.Config.LocalAddress,
 			MsgData: nextState,
+			reason:  reason,
 		}
 		h.stateCh <- e
 	}
 	return nil
 }
 
+// queuedMessages returns the number of the messages queued to the
+// peer, both in the outgoing channel and in the server's sender.
+func (h *FSMHandler) queuedMessages() int {
+	return len(h.outgoing) + int(atomic.LoadInt64(&h.fsm.queued))
+}
+
+// reapTime returns how long the FSM goroutines may take to exit on a
+// state change. The sending goroutine may be draining the messages
+// queued to the peer, so the configured time is extended by the queued
+// messages, up to config.MAX_FSM_REAP_TIME.
+func reapTime(gConf *config.Global, queued int) time.Duration {
+	base := gConf.Config.FsmReapTime
+	if base == 0 {
+		base = config.DEFAULT_FSM_REAP_TIME
+	}
+	d := time.Duration(base)*time.Second + time.Duration(queued)*FSM_REAP_TIME_PER_MESSAGE
+	if max := config.MAX_FSM_REAP_TIME * time.Second; d > max {
+		d = max
+	}
+	return d
+}
+
 func (h *FSMHandler) changeAdminState(s AdminState) error {
 	fsm := h.fsm
 	if fsm.adminState != s {
+		state, _ := fsm.State()
 		log.WithFields(log.Fields{
 			"Topic":      "Peer",
 			"Key":        fsm.pConf.Config.NeighborAddress,
-			"State":      fsm.state,
+			"State":      state,
 			"AdminState": s.String(),
 		}).Debug("admin state changed")
 
@@ -1095,25 +2333,28 @@ func (h *FSMHandler) changeAdminState(s AdminState) error {
 
 		switch s {
 		case ADMIN_STATE_UP:
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 			}).Info("Administrative start")
 
 		case ADMIN_STATE_DOWN:
+			state, _ := fsm.State()
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   fsm.pConf.Config.NeighborAddress,
-				"State": fsm.state,
+				"State": state,
 			}).Info("Administrative shutdown")
 		}
 
 	} else {
+		state, _ := fsm.State()
 		log.WithFields(log.Fields{
 			"Topic": "Peer",
 			"Key":   fsm.pConf.Config.NeighborAddress,
-			"State": fsm.state,
+			"State": state,
 		}).Warn("cannot change to the same state")
 
 		return fmt.Errorf("cannot change to the same state.")
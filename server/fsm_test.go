@@ -16,14 +16,21 @@
 package server
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
 	"github.com/osrg/gobgp/table"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"math/rand"
 	"net"
+	"os"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
@@ -36,6 +43,9 @@ type MockConnection struct {
 	readBytes int
 	isClosed  bool
 	wait      int
+	// guards sendBuf and isClosed for the tests reading them while
+	// the handler is running
+	mu sync.Mutex
 }
 
 func NewMockConnection() *MockConnection {
@@ -62,7 +72,10 @@ func (m *MockConnection) setData(data []byte) int {
 
 func (m *MockConnection) Read(buf []byte) (int, error) {
 
-	if m.isClosed {
+	m.mu.Lock()
+	closed := m.isClosed
+	m.mu.Unlock()
+	if closed {
 		return 0, fmt.Errorf("already closed")
 	}
 
@@ -89,7 +102,9 @@ func (m *MockConnection) Read(buf []byte) (int, error) {
 
 func (m *MockConnection) Write(buf []byte) (int, error) {
 	time.Sleep(time.Duration(m.wait) * time.Millisecond)
+	m.mu.Lock()
 	m.sendBuf = append(m.sendBuf, buf)
+	m.mu.Unlock()
 	msg, _ := bgp.ParseBGPMessage(buf)
 	fmt.Printf("%d bytes written by gobgp  message type : %s\n", len(buf), showMessageType(msg.Header.Type))
 	return len(buf), nil
@@ -113,6 +128,8 @@ func showMessageType(t uint8) string {
 
 func (m *MockConnection) Close() error {
 	fmt.Printf("close called\n")
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if !m.isClosed {
 		close(m.recvCh)
 		m.isClosed = true
@@ -120,6 +137,13 @@ func (m *MockConnection) Close() error {
 	return nil
 }
 
+// lastSent returns the last message written to the connection.
+func (m *MockConnection) lastSent() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sendBuf[len(m.sendBuf)-1]
+}
+
 func (m *MockConnection) LocalAddr() net.Addr {
 	return &net.TCPAddr{
 		IP:   net.ParseIP("10.10.10.10"),
@@ -155,6 +179,39 @@ func TestReadAll(t *testing.T) {
 	assert.Equal(expected2, actual2)
 }
 
+func TestOpen2CapNoMultiProtocol(t *testing.T) {
+	assert := assert.New(t)
+	open := bgp.NewBGPOpenMessage(65002, 90, "10.0.0.2", []bgp.OptionParameterInterface{
+		bgp.NewOptionParameterCapability([]bgp.ParameterCapabilityInterface{bgp.NewCapRouteRefresh()}),
+	}).Body.(*bgp.BGPOpen)
+
+	n := &config.Neighbor{
+		Config:   config.NeighborConfig{NeighborAddress: "10.0.0.2"},
+		AfiSafis: []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}},
+	}
+	_, rfMap := open2Cap(open, n)
+	assert.Equal(map[bgp.RouteFamily]bool{bgp.RF_IPv4_UC: true}, rfMap)
+
+	n = &config.Neighbor{
+		Config:   config.NeighborConfig{NeighborAddress: "2001:db8::2"},
+		AfiSafis: []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST}},
+	}
+	_, rfMap = open2Cap(open, n)
+	assert.Equal(map[bgp.RouteFamily]bool{bgp.RF_IPv6_UC: true}, rfMap)
+
+	// link-local with the zone
+	n.Config.NeighborAddress = "fe80::2%eth0"
+	_, rfMap = open2Cap(open, n)
+	assert.Equal(map[bgp.RouteFamily]bool{bgp.RF_IPv6_UC: true}, rfMap)
+
+	// the advertised families are used over IPv6 as well
+	open = bgp.NewBGPOpenMessage(65002, 90, "10.0.0.2", []bgp.OptionParameterInterface{
+		bgp.NewOptionParameterCapability([]bgp.ParameterCapabilityInterface{bgp.NewCapMultiProtocol(bgp.RF_IPv6_UC)}),
+	}).Body.(*bgp.BGPOpen)
+	_, rfMap = open2Cap(open, n)
+	assert.Equal(map[bgp.RouteFamily]bool{bgp.RF_IPv6_UC: true}, rfMap)
+}
+
 func TestFSMHandlerOpensent_HoldTimerExpired(t *testing.T) {
 	assert := assert.New(t)
 	m := NewMockConnection()
@@ -180,6 +237,96 @@ func TestFSMHandlerOpensent_HoldTimerExpired(t *testing.T) {
 
 }
 
+func TestFSMHandlerOpensent_MinHoldTime(t *testing.T) {
+	assert := assert.New(t)
+	opensent := func(action config.MinHoldTimeActionType) (bgp.FSMState, *MockConnection, *Peer) {
+		m := NewMockConnection()
+		p, h := makePeerAndHandler()
+		p.fsm.conn = m
+		p.fsm.opensentHoldTime = 10
+		p.fsm.pConf.Config.PeerAs = 65001
+		p.fsm.pConf.Timers.Config.HoldTime = 90
+		p.fsm.pConf.Timers.Config.KeepaliveInterval = 30
+		p.fsm.pConf.Timers.Config.MinHoldTime = 9
+		p.fsm.pConf.Timers.Config.MinHoldTimeAction = action
+		b, _ := bgp.NewBGPOpenMessage(65001, 3, "10.0.0.1", nil).Serialize()
+		m.setData(b)
+		state, _ := h.opensent()
+		return state, m, p
+	}
+
+	state, m, _ := opensent(config.MIN_HOLD_TIME_ACTION_TYPE_REJECT)
+	assert.Equal(bgp.BGP_FSM_IDLE, state)
+	sent, _ := bgp.ParseBGPMessage(m.sendBuf[len(m.sendBuf)-1])
+	assert.Equal(uint8(bgp.BGP_MSG_NOTIFICATION), sent.Header.Type)
+	n := sent.Body.(*bgp.BGPNotification)
+	assert.Equal(uint8(bgp.BGP_ERROR_OPEN_MESSAGE_ERROR), n.ErrorCode)
+	assert.Equal(uint8(bgp.BGP_ERROR_SUB_UNACCEPTABLE_HOLD_TIME), n.ErrorSubcode)
+
+	state, m, p := opensent(config.MIN_HOLD_TIME_ACTION_TYPE_CLAMP)
+	assert.Equal(bgp.BGP_FSM_OPENCONFIRM, state)
+	sent, _ = bgp.ParseBGPMessage(m.sendBuf[len(m.sendBuf)-1])
+	assert.Equal(uint8(bgp.BGP_MSG_KEEPALIVE), sent.Header.Type)
+	assert.Equal(float64(9), p.fsm.pConf.Timers.State.NegotiatedHoldTime)
+	// keepalives have to keep up with the hold time of the peer
+	assert.Equal(float64(1), p.fsm.pConf.Timers.State.KeepaliveInterval)
+}
+
+func TestFSMNegotiateHoldTime(t *testing.T) {
+	assert := assert.New(t)
+	_, h := makePeerAndHandler()
+	fsm := h.fsm
+	fsm.pConf.Timers.Config.HoldTime = 90
+	fsm.pConf.Timers.Config.KeepaliveInterval = 30
+
+	// no minimum, RFC behavior
+	assert.Nil(fsm.negotiateHoldTime(3))
+	assert.Equal(float64(3), fsm.pConf.Timers.State.NegotiatedHoldTime)
+	assert.Equal(float64(1), fsm.pConf.Timers.State.KeepaliveInterval)
+	assert.Nil(fsm.negotiateHoldTime(180))
+	assert.Equal(float64(90), fsm.pConf.Timers.State.NegotiatedHoldTime)
+	assert.Equal(float64(30), fsm.pConf.Timers.State.KeepaliveInterval)
+
+	fsm.pConf.Timers.Config.MinHoldTime = 9
+	fsm.pConf.Timers.Config.MinHoldTimeAction = config.MIN_HOLD_TIME_ACTION_TYPE_REJECT
+	assert.NotNil(fsm.negotiateHoldTime(3))
+	assert.Nil(fsm.negotiateHoldTime(9))
+	assert.Equal(float64(9), fsm.pConf.Timers.State.NegotiatedHoldTime)
+	// disabling keepalives is below the minimum too
+	assert.NotNil(fsm.negotiateHoldTime(0))
+
+	fsm.pConf.Timers.Config.MinHoldTimeAction = config.MIN_HOLD_TIME_ACTION_TYPE_CLAMP
+	assert.Nil(fsm.negotiateHoldTime(3))
+	assert.Equal(float64(9), fsm.pConf.Timers.State.NegotiatedHoldTime)
+	assert.Equal(float64(1), fsm.pConf.Timers.State.KeepaliveInterval)
+	// zero isn't raised, the peer would send no keepalives
+	assert.NotNil(fsm.negotiateHoldTime(0))
+
+	// no minimum, keepalives are disabled
+	fsm.pConf.Timers.Config.MinHoldTime = 0
+	assert.Nil(fsm.negotiateHoldTime(0))
+	assert.Equal(float64(0), fsm.pConf.Timers.State.NegotiatedHoldTime)
+}
+
+func TestFSMNegotiateHoldTime_DeriveKeepalive(t *testing.T) {
+	assert := assert.New(t)
+	_, h := makePeerAndHandler()
+	fsm := h.fsm
+	fsm.pConf.Timers.Config.HoldTime = 90
+	fsm.pConf.Timers.Config.KeepaliveInterval = 10
+
+	// the negotiated hold time equals ours
+	assert.Nil(fsm.negotiateHoldTime(90))
+	assert.Equal(float64(10), fsm.pConf.Timers.State.KeepaliveInterval)
+
+	fsm.pConf.Timers.Config.DeriveKeepaliveInterval = true
+	assert.Nil(fsm.negotiateHoldTime(90))
+	assert.Equal(float64(90), fsm.pConf.Timers.State.NegotiatedHoldTime)
+	assert.Equal(float64(30), fsm.pConf.Timers.State.KeepaliveInterval)
+	assert.Nil(fsm.negotiateHoldTime(30))
+	assert.Equal(float64(10), fsm.pConf.Timers.State.KeepaliveInterval)
+}
+
 func TestFSMHandlerOpenconfirm_HoldTimerExpired(t *testing.T) {
 	assert := assert.New(t)
 	m := NewMockConnection()
@@ -204,6 +351,254 @@ func TestFSMHandlerOpenconfirm_HoldTimerExpired(t *testing.T) {
 
 }
 
+func TestFSMHandlerOpenconfirm_DuplicateOpen(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+
+	p, h := makePeerAndHandler()
+	p.fsm.conn = m
+	p.fsm.pConf.Timers.State.NegotiatedHoldTime = 10
+
+	b, _ := open().Serialize()
+	m.setData(b)
+	state, reason := h.openconfirm()
+
+	assert.Equal(bgp.BGP_FSM_IDLE, state)
+	assert.Equal(FSM_INVALID_MSG, reason)
+	assert.True(m.isClosed)
+	lastMsg := m.sendBuf[len(m.sendBuf)-1]
+	sent, _ := bgp.ParseBGPMessage(lastMsg)
+	assert.Equal(uint8(bgp.BGP_MSG_NOTIFICATION), sent.Header.Type)
+	n := sent.Body.(*bgp.BGPNotification)
+	assert.Equal(uint8(bgp.BGP_ERROR_FSM_ERROR), n.ErrorCode)
+	assert.Equal(uint8(bgp.BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENCONFIRM_STATE), n.ErrorSubcode)
+}
+
+func TestFSMHandlerOpensent_FourOctetAs(t *testing.T) {
+	assert := assert.New(t)
+	opensent := func(as4 bool) (bgp.FSMState, *MockConnection) {
+		m := NewMockConnection()
+		p, h := makePeerAndHandler()
+		p.fsm.gConf.Config.As = 4200000000
+		p.fsm.conn = m
+		p.fsm.opensentHoldTime = 10
+		p.fsm.pConf.Config.PeerAs = 65001
+		p.fsm.pConf.Timers.Config.HoldTime = 90
+		caps := []bgp.ParameterCapabilityInterface{bgp.NewCapRouteRefresh()}
+		if as4 {
+			caps = append(caps, bgp.NewCapFourOctetASNumber(65001))
+		}
+		b, _ := bgp.NewBGPOpenMessage(65001, 90, "10.0.0.1", []bgp.OptionParameterInterface{bgp.NewOptionParameterCapability(caps)}).Serialize()
+		m.setData(b)
+		state, _ := h.opensent()
+		return state, m
+	}
+
+	// our OPEN carries AS_TRANS and the real AS number in the
+	// capability
+	state, m := opensent(false)
+	sent, _ := bgp.ParseBGPMessage(m.sendBuf[0])
+	assert.Equal(uint16(bgp.AS_TRANS), sent.Body.(*bgp.BGPOpen).MyAS)
+
+	assert.Equal(bgp.BGP_FSM_IDLE, state)
+	assert.True(m.isClosed)
+	sent, _ = bgp.ParseBGPMessage(m.sendBuf[len(m.sendBuf)-1])
+	assert.Equal(uint8(bgp.BGP_MSG_NOTIFICATION), sent.Header.Type)
+	n := sent.Body.(*bgp.BGPNotification)
+	assert.Equal(uint8(bgp.BGP_ERROR_OPEN_MESSAGE_ERROR), n.ErrorCode)
+	assert.Equal(uint8(bgp.BGP_ERROR_SUB_UNSUPPORTED_CAPABILITY), n.ErrorSubcode)
+	c := &bgp.CapFourOctetASNumber{}
+	assert.Nil(c.DecodeFromBytes(n.Data))
+	assert.Equal(uint32(4200000000), c.CapValue)
+
+	state, _ = opensent(true)
+	assert.Equal(bgp.BGP_FSM_OPENCONFIRM, state)
+}
+
+func TestFSMHasCapability(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+	p, h := makePeerAndHandler()
+	p.fsm.conn = m
+	p.fsm.opensentHoldTime = 10
+	p.fsm.pConf.Config.PeerAs = 65001
+	p.fsm.pConf.Timers.Config.HoldTime = 90
+	assert.False(p.fsm.HasCapability(bgp.BGP_CAP_ROUTE_REFRESH))
+
+	caps := []bgp.ParameterCapabilityInterface{
+		bgp.NewCapRouteRefresh(),
+		bgp.NewCapMultiProtocol(bgp.RF_IPv4_UC),
+		bgp.NewCapMultiProtocol(bgp.RF_IPv6_UC),
+	}
+	b, _ := bgp.NewBGPOpenMessage(65001, 90, "10.0.0.1", []bgp.OptionParameterInterface{bgp.NewOptionParameterCapability(caps)}).Serialize()
+	m.setData(b)
+
+	// read while the FSM goroutine writes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			p.fsm.HasCapability(bgp.BGP_CAP_ROUTE_REFRESH)
+		}
+	}()
+	state, _ := h.opensent()
+	<-done
+	assert.Equal(bgp.BGP_FSM_OPENCONFIRM, state)
+
+	assert.True(p.fsm.HasCapability(bgp.BGP_CAP_ROUTE_REFRESH))
+	assert.False(p.fsm.HasCapability(bgp.BGP_CAP_GRACEFUL_RESTART))
+	assert.False(p.fsm.HasCapability(bgp.BGP_CAP_ADD_PATH))
+	assert.Nil(p.fsm.Capability(bgp.BGP_CAP_ADD_PATH))
+	mp := p.fsm.Capability(bgp.BGP_CAP_MULTIPROTOCOL)
+	assert.Equal(2, len(mp))
+	assert.Equal(bgp.RF_IPv6_UC, mp[1].(*bgp.CapMultiProtocol).CapValue)
+	assert.Equal(3, len(p.fsm.remoteCapabilities()))
+}
+
+func TestFSMHandlerOpensent_UnsupportedVersion(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+
+	p, h := makePeerAndHandler()
+	p.fsm.conn = m
+	p.fsm.opensentHoldTime = 10
+
+	msg := open()
+	msg.Body.(*bgp.BGPOpen).Version = 3
+	b, _ := msg.Serialize()
+	m.setData(b)
+	state, reason := h.opensent()
+
+	assert.Equal(bgp.BGP_FSM_IDLE, state)
+	assert.Equal(FSM_INVALID_MSG, reason)
+	assert.True(m.isClosed)
+	sent, _ := bgp.ParseBGPMessage(m.sendBuf[len(m.sendBuf)-1])
+	assert.Equal(uint8(bgp.BGP_MSG_NOTIFICATION), sent.Header.Type)
+	n := sent.Body.(*bgp.BGPNotification)
+	assert.Equal(uint8(bgp.BGP_ERROR_OPEN_MESSAGE_ERROR), n.ErrorCode)
+	assert.Equal(uint8(bgp.BGP_ERROR_SUB_UNSUPPORTED_VERSION_NUMBER), n.ErrorSubcode)
+	// the supported version
+	assert.Equal([]byte{0, 4}, n.Data)
+}
+
+func TestFSMHandlerUnexpectedMessage(t *testing.T) {
+	assert := assert.New(t)
+	update := bgp.NewBGPUpdateMessage(nil, nil, nil)
+	notification := bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN, nil)
+
+	for _, c := range []struct {
+		opensent bool
+		msg      *bgp.BGPMessage
+		reason   FsmStateReason
+		subcode  uint8
+	}{
+		{true, update, FSM_INVALID_MSG, bgp.BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENSENT_STATE},
+		{true, keepalive(), FSM_INVALID_MSG, bgp.BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENSENT_STATE},
+		{false, update, FSM_INVALID_MSG, bgp.BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENCONFIRM_STATE},
+		{true, notification, FSM_NOTIFICATION_RECV, 0},
+		{false, notification, FSM_NOTIFICATION_RECV, 0},
+	} {
+		m := NewMockConnection()
+		p, h := makePeerAndHandler()
+		p.fsm.conn = m
+		p.fsm.pConf.Timers.State.NegotiatedHoldTime = 10
+
+		b, _ := c.msg.Serialize()
+		m.setData(b)
+		var state bgp.FSMState
+		var reason FsmStateReason
+		if c.opensent {
+			state, reason = h.opensent()
+		} else {
+			state, reason = h.openconfirm()
+		}
+
+		assert.Equal(bgp.BGP_FSM_IDLE, state)
+		assert.Equal(c.reason, reason)
+		assert.True(m.isClosed)
+		sent, _ := bgp.ParseBGPMessage(m.sendBuf[len(m.sendBuf)-1])
+		if c.reason == FSM_NOTIFICATION_RECV {
+			// not answered
+			assert.NotEqual(uint8(bgp.BGP_MSG_NOTIFICATION), sent.Header.Type)
+			assert.Equal(uint8(bgp.BGP_ERROR_CEASE), p.fsm.notification.ErrorCode)
+			continue
+		}
+		assert.Equal(uint8(bgp.BGP_MSG_NOTIFICATION), sent.Header.Type)
+		n := sent.Body.(*bgp.BGPNotification)
+		assert.Equal(uint8(bgp.BGP_ERROR_FSM_ERROR), n.ErrorCode)
+		assert.Equal(c.subcode, n.ErrorSubcode)
+	}
+}
+
+func TestNotificationHold(t *testing.T) {
+	assert := assert.New(t)
+	n := &notificationHold{}
+	now := time.Now()
+	cease, shutdown := uint8(bgp.BGP_ERROR_CEASE), uint8(bgp.BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN)
+
+	assert.False(n.suppress(cease, shutdown, time.Minute, now))
+	assert.True(n.suppress(cease, shutdown, time.Minute, now.Add(time.Second)))
+	// the window starts from the last notification actually sent
+	assert.True(n.suppress(cease, shutdown, time.Minute, now.Add(59*time.Second)))
+	assert.False(n.suppress(cease, shutdown, time.Minute, now.Add(time.Minute)))
+	// a new error condition is always sent
+	assert.False(n.suppress(cease, bgp.BGP_ERROR_SUB_ADMINISTRATIVE_RESET, time.Minute, now.Add(61*time.Second)))
+	assert.False(n.suppress(bgp.BGP_ERROR_HOLD_TIMER_EXPIRED, 0, time.Minute, now.Add(62*time.Second)))
+	// disabled
+	assert.False(n.suppress(bgp.BGP_ERROR_HOLD_TIMER_EXPIRED, 0, 0, now.Add(63*time.Second)))
+}
+
+func TestFSMSendNotificationSuppressed(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	p.fsm.pConf.Timers.Config.MinNotificationInterval = 60
+
+	send := func(code, subcode uint8) bool {
+		m := NewMockConnection()
+		p.fsm.sendNotification(m, code, subcode, nil, "")
+		assert.True(m.isClosed)
+		assert.Equal(subcode, p.fsm.notification.ErrorSubcode)
+		return len(m.sendBuf) > 0
+	}
+	assert.True(send(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_OUT_OF_RESOURCES))
+	// the reconnected session is reset without the notification
+	assert.False(send(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_OUT_OF_RESOURCES))
+	assert.True(send(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_OTHER_CONFIGURATION_CHANGE))
+
+	p.fsm.pConf.Timers.Config.MinNotificationInterval = 0
+	assert.True(send(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_OTHER_CONFIGURATION_CHANGE))
+}
+
+func TestFSMSendMessageloopNotificationSuppressed(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	p.fsm.pConf.Timers.Config.MinNotificationInterval = 60
+	p.fsm.pConf.Timers.State.NegotiatedHoldTime = 90
+	p.fsm.pConf.Timers.State.KeepaliveInterval = 30
+
+	send := func() bool {
+		m := NewMockConnection()
+		h := &FSMHandler{
+			fsm:      p.fsm,
+			conn:     m,
+			errorCh:  make(chan FsmStateReason, 2),
+			outgoing: make(chan *bgp.BGPMessage, 1),
+		}
+		h.outgoing <- bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_MAXIMUM_NUMBER_OF_PREFIXES_REACHED, nil)
+		assert.Nil(h.sendMessageloop())
+		assert.Equal(FSM_NOTIFICATION_SENT, <-h.errorCh)
+		assert.Equal(uint8(bgp.BGP_ERROR_CEASE), p.fsm.notification.ErrorCode)
+		for _, b := range m.sendBuf {
+			if sent, _ := bgp.ParseBGPMessage(b); sent.Header.Type == bgp.BGP_MSG_NOTIFICATION {
+				return true
+			}
+		}
+		return false
+	}
+	assert.True(send())
+	assert.False(send())
+}
+
 func TestFSMHandlerEstablish_HoldTimerExpired(t *testing.T) {
 	assert := assert.New(t)
 	m := NewMockConnection()
@@ -240,6 +635,409 @@ func TestFSMHandlerEstablish_HoldTimerExpired(t *testing.T) {
 	assert.Equal(uint8(bgp.BGP_ERROR_HOLD_TIMER_EXPIRED), sent.Body.(*bgp.BGPNotification).ErrorCode)
 }
 
+func TestFSMHandlerEstablish_HoldTimerExpiredGracefulRestart(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+
+	p, h := makePeerAndHandler()
+	p.fsm.conn = m
+	p.fsm.pConf.Timers.Config.HoldTime = 2
+	p.fsm.pConf.Timers.State.NegotiatedHoldTime = 2
+
+	// the peer advertised the N-bit
+	p.fsm.pConf.GracefulRestart.Config.Enabled = true
+	p.fsm.pConf.GracefulRestart.Config.NotificationEnabled = true
+	p.fsm.capMap[bgp.BGP_CAP_GRACEFUL_RESTART] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapGracefulRestart(bgp.BGP_CAP_GRACEFUL_RESTART_FLAG_NOTIFICATION, 120, nil),
+	}
+
+	state, reason := h.established()
+	time.Sleep(time.Second * 1)
+	assert.Equal(bgp.BGP_FSM_IDLE, state)
+	assert.Equal(FSM_GRACEFUL_RESTART, reason)
+	lastMsg := m.sendBuf[len(m.sendBuf)-1]
+	sent, _ := bgp.ParseBGPMessage(lastMsg)
+	assert.Equal(uint8(bgp.BGP_MSG_NOTIFICATION), sent.Header.Type)
+	assert.Equal(uint8(bgp.BGP_ERROR_HOLD_TIMER_EXPIRED), sent.Body.(*bgp.BGPNotification).ErrorCode)
+}
+
+func TestFSMExpireHoldTimer(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+
+	p, h := makePeerAndHandler()
+	p.fsm.conn = m
+	p.fsm.pConf.Timers.Config.HoldTime = 90
+	p.fsm.pConf.Timers.State.NegotiatedHoldTime = 90
+
+	// not established
+	assert.NotNil(p.fsm.ExpireHoldTimer())
+
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		p.fsm.ExpireHoldTimer()
+	}()
+	state, reason := h.established()
+	time.Sleep(time.Second * 1)
+	assert.Equal(bgp.BGP_FSM_IDLE, state)
+	assert.Equal(FSM_HOLD_TIMER_EXPIRED, reason)
+	sent, _ := bgp.ParseBGPMessage(m.lastSent())
+	assert.Equal(uint8(bgp.BGP_MSG_NOTIFICATION), sent.Header.Type)
+	assert.Equal(uint8(bgp.BGP_ERROR_HOLD_TIMER_EXPIRED), sent.Body.(*bgp.BGPNotification).ErrorCode)
+}
+
+func TestFSMTimers(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+
+	p, h := makePeerAndHandler()
+	p.fsm.conn = m
+	p.fsm.pConf.Timers.Config.HoldTime = 90
+	p.fsm.pConf.Timers.State.NegotiatedHoldTime = 90
+	p.fsm.pConf.Timers.State.KeepaliveInterval = 30
+	assert.Equal(FSMTimers{}, p.fsm.Timers())
+
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	timers := make(chan FSMTimers, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		timers <- p.fsm.Timers()
+		p.fsm.ExpireHoldTimer()
+	}()
+	h.established()
+	running := <-timers
+	assert.True(running.HoldTimer > 89*time.Second && running.HoldTimer <= 90*time.Second)
+	// jittered by up to 25%
+	assert.True(running.KeepaliveTimer > 22*time.Second && running.KeepaliveTimer <= 30*time.Second)
+	assert.Equal(time.Duration(0), running.IdleHoldTimer)
+	// the connect retry timer only counts in ACTIVE
+	assert.Equal(time.Duration(0), running.ConnectRetryTimer)
+	assert.Equal(time.Duration(0), p.fsm.Timers().HoldTimer)
+
+	p.fsm.deadlines.set(&p.fsm.deadlines.connectRetry, 5*time.Second)
+	assert.Equal(time.Duration(0), p.fsm.Timers().ConnectRetryTimer)
+	p.fsm.state = bgp.BGP_FSM_ACTIVE
+	assert.True(p.fsm.Timers().ConnectRetryTimer > 4*time.Second)
+	p.fsm.deadlines.set(&p.fsm.deadlines.connectRetry, 0)
+	assert.Equal(time.Duration(0), p.fsm.Timers().ConnectRetryTimer)
+}
+
+func TestFSMHoldTimerResetInterval(t *testing.T) {
+	assert := assert.New(t)
+	p, h := makePeerAndHandler()
+	h.holdTimerResetCh = make(chan bool, 2)
+	p.fsm.pConf.Timers.State.NegotiatedHoldTime = 9
+	now := time.Now()
+
+	// every message resets the hold timer by default
+	h.resetHoldTimer(now)
+	assert.Equal(1, len(h.holdTimerResetCh))
+	<-h.holdTimerResetCh
+	h.resetHoldTimer(now.Add(100 * time.Millisecond))
+	assert.Equal(1, len(h.holdTimerResetCh))
+	<-h.holdTimerResetCh
+	assert.Equal(time.Duration(0), h.holdTimerRemaining(now.Add(10*time.Second)))
+
+	p.fsm.pConf.Timers.Config.HoldTimerResetInterval = 1
+	h.resetHoldTimer(now)
+	assert.Equal(1, len(h.holdTimerResetCh))
+	<-h.holdTimerResetCh
+	// coalesced within the interval
+	h.resetHoldTimer(now.Add(500 * time.Millisecond))
+	assert.Equal(0, len(h.holdTimerResetCh))
+	h.resetHoldTimer(now.Add(time.Second))
+	assert.Equal(1, len(h.holdTimerResetCh))
+	<-h.holdTimerResetCh
+
+	// the timer reset at now+1s fires at now+10s but the message
+	// received at now+1.5s keeps it running
+	h.resetHoldTimer(now.Add(1500 * time.Millisecond))
+	assert.Equal(0, len(h.holdTimerResetCh))
+	assert.Equal(500*time.Millisecond, h.holdTimerRemaining(now.Add(10*time.Second)))
+	assert.Equal(time.Duration(0), h.holdTimerRemaining(now.Add(11*time.Second)))
+}
+
+func TestFSMRetainOnNotification(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	cease := func(subcode uint8) *bgp.BGPNotification {
+		return bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_CEASE, subcode, nil).Body.(*bgp.BGPNotification)
+	}
+	updateError := bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_UPDATE_MESSAGE_ERROR, bgp.BGP_ERROR_SUB_MALFORMED_AS_PATH, nil).Body.(*bgp.BGPNotification)
+
+	// the N-bit isn't negotiated
+	assert.False(p.fsm.retainOnNotification(cease(bgp.BGP_ERROR_SUB_ADMINISTRATIVE_RESET)))
+	assert.False(p.fsm.retainOnNotification(updateError))
+
+	p.fsm.pConf.GracefulRestart.Config.Enabled = true
+	p.fsm.pConf.GracefulRestart.Config.NotificationEnabled = true
+	p.fsm.capMap[bgp.BGP_CAP_GRACEFUL_RESTART] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapGracefulRestart(bgp.BGP_CAP_GRACEFUL_RESTART_FLAG_NOTIFICATION, 120, nil),
+	}
+	assert.Equal(uint16(120), p.fsm.peerRestartTime())
+	assert.True(p.fsm.retainOnNotification(cease(bgp.BGP_ERROR_SUB_ADMINISTRATIVE_RESET)))
+	assert.True(p.fsm.retainOnNotification(updateError))
+	assert.False(p.fsm.retainOnNotification(cease(bgp.BGP_ERROR_SUB_HARD_RESET)))
+	assert.False(p.fsm.retainOnNotification(cease(bgp.BGP_ERROR_SUB_PEER_DECONFIGURED)))
+}
+
+func TestGracefulRestartForwardingState(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	afiSafis := []config.AfiSafi{
+		{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST},
+		{AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST},
+	}
+	afiSafis[0].MpGracefulRestart.Config.Enabled = true
+	afiSafis[0].MpGracefulRestart.Config.ForwardingStatePreserved = true
+	afiSafis[1].MpGracefulRestart.Config.Enabled = true
+	p.conf.AfiSafis = afiSafis
+	p.conf.GracefulRestart.Config.Enabled = true
+	p.conf.GracefulRestart.Config.RestartTime = 120
+
+	var c *bgp.CapGracefulRestart
+	for _, cap := range capabilitiesFromConfig(&p.gConf, &p.conf) {
+		if cap.Code() == bgp.BGP_CAP_GRACEFUL_RESTART {
+			c = cap.(*bgp.CapGracefulRestart)
+		}
+	}
+	assert.NotNil(c)
+	assert.Equal([]bgp.CapGracefulRestartTuples{
+		{AFI: bgp.AFI_IP, SAFI: bgp.SAFI_UNICAST, Flags: bgp.BGP_CAP_GRACEFUL_RESTART_TUPLE_FLAG_FORWARDING},
+		{AFI: bgp.AFI_IP6, SAFI: bgp.SAFI_UNICAST, Flags: 0},
+	}, c.CapValue.Tuples)
+
+	// the peer preserved the forwarding state of IPv6 only
+	p.fsm.capMap[bgp.BGP_CAP_GRACEFUL_RESTART] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapGracefulRestart(0, 90, []bgp.CapGracefulRestartTuples{
+			{AFI: bgp.AFI_IP, SAFI: bgp.SAFI_UNICAST, Flags: 0},
+			{AFI: bgp.AFI_IP6, SAFI: bgp.SAFI_UNICAST, Flags: bgp.BGP_CAP_GRACEFUL_RESTART_TUPLE_FLAG_FORWARDING},
+		}),
+	}
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv4_UC}, p.updateGracefulRestartState())
+	for _, a := range p.conf.AfiSafis {
+		assert.True(a.MpGracefulRestart.State.Advertised)
+		assert.True(a.MpGracefulRestart.State.Received)
+	}
+	assert.False(p.conf.AfiSafis[0].MpGracefulRestart.State.PeerForwardingStatePreserved)
+	assert.True(p.conf.AfiSafis[1].MpGracefulRestart.State.PeerForwardingStatePreserved)
+}
+
+func TestGracefulRestartForwardingStateRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	p.conf.AfiSafis = []config.AfiSafi{
+		{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST},
+		{AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST},
+		{AfiSafiName: config.AFI_SAFI_TYPE_L3VPN_IPV4_UNICAST},
+	}
+	for i := range p.conf.AfiSafis {
+		p.conf.AfiSafis[i].MpGracefulRestart.Config.Enabled = true
+	}
+	p.conf.AfiSafis[0].MpGracefulRestart.Config.ForwardingStatePreserved = true
+	p.conf.AfiSafis[2].MpGracefulRestart.Config.ForwardingStatePreserved = true
+	p.conf.GracefulRestart.Config.Enabled = true
+	p.conf.GracefulRestart.Config.RestartTime = 120
+
+	// the OPEN we send is the one the peer configured the same way
+	// sends to us
+	b, err := buildopen(&p.gConf, &p.conf).Serialize()
+	assert.Nil(err)
+	m, err := bgp.ParseBGPMessage(b)
+	assert.Nil(err)
+	p.fsm.capMap, p.fsm.rfMap = open2Cap(m.Body.(*bgp.BGPOpen), &p.conf)
+
+	assert.Equal(map[bgp.RouteFamily]bool{
+		bgp.RF_IPv4_UC:  true,
+		bgp.RF_IPv6_UC:  false,
+		bgp.RF_IPv4_VPN: true,
+	}, p.fsm.peerGracefulRestartFamilies())
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv6_UC}, p.updateGracefulRestartState())
+	for i, preserved := range []bool{true, false, true} {
+		assert.True(p.conf.AfiSafis[i].MpGracefulRestart.State.Received)
+		assert.Equal(preserved, p.conf.AfiSafis[i].MpGracefulRestart.State.PeerForwardingStatePreserved)
+	}
+}
+
+func TestGracefulRestartStaleRoutesTime(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	p.conf.GracefulRestart.Config.Enabled = true
+	p.conf.GracefulRestart.Config.RestartTime = 30
+
+	// our restart time is advertised in the capability
+	var c *bgp.CapGracefulRestart
+	for _, cap := range capabilitiesFromConfig(&p.gConf, &p.conf) {
+		if cap.Code() == bgp.BGP_CAP_GRACEFUL_RESTART {
+			c = cap.(*bgp.CapGracefulRestart)
+		}
+	}
+	assert.NotNil(c)
+	assert.Equal(uint16(30), c.CapValue.Time)
+
+	// the restart time of the peer is used by default
+	p.fsm.capMap[bgp.BGP_CAP_GRACEFUL_RESTART] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapGracefulRestart(0, 90, nil),
+	}
+	assert.Equal(90*time.Second, p.fsm.staleRoutesTime())
+
+	// no longer than the restart time of the peer
+	p.fsm.pConf.GracefulRestart.Config.StaleRoutesTime = 300
+	assert.Equal(90*time.Second, p.fsm.staleRoutesTime())
+	p.fsm.pConf.GracefulRestart.Config.StaleRoutesTime = 60
+	assert.Equal(60*time.Second, p.fsm.staleRoutesTime())
+	p.fsm.pConf.GracefulRestart.Config.StaleRoutesTime = 1.5
+	assert.Equal(1500*time.Millisecond, p.fsm.staleRoutesTime())
+
+	p.updateGracefulRestartState()
+	assert.Equal(uint16(30), p.conf.GracefulRestart.State.RestartTime)
+	assert.Equal(uint16(90), p.conf.GracefulRestart.State.PeerRestartTime)
+
+	// nothing is retained when the peer's restart time is 0
+	p.fsm.capMap[bgp.BGP_CAP_GRACEFUL_RESTART] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapGracefulRestart(0, 0, nil),
+	}
+	assert.Equal(time.Duration(0), p.fsm.staleRoutesTime())
+	assert.False(p.retainsRoutes(FSM_READ_FAILED))
+}
+
+func TestBuildOpenLocalRouterId(t *testing.T) {
+	assert := assert.New(t)
+	g := &config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "1.1.1.1"}}
+	n := &config.Neighbor{}
+	assert.Equal("1.1.1.1", buildopen(g, n).Body.(*bgp.BGPOpen).ID.String())
+	n.Config.LocalRouterId = "2.2.2.2"
+	assert.Equal("2.2.2.2", buildopen(g, n).Body.(*bgp.BGPOpen).ID.String())
+}
+
+func TestConnectTimeout(t *testing.T) {
+	assert := assert.New(t)
+	n := &config.Neighbor{}
+	assert.Equal(time.Duration(MIN_CONNECT_RETRY-1)*time.Second, connectTimeout(n, MIN_CONNECT_RETRY))
+
+	n.Timers.Config.ConnectTimeout = 2.5
+	assert.Equal(2500*time.Millisecond, connectTimeout(n, MIN_CONNECT_RETRY))
+
+	// capped below the retry interval
+	n.Timers.Config.ConnectTimeout = 60
+	assert.Equal(29*time.Second, connectTimeout(n, 30))
+	assert.Equal(time.Duration(MIN_CONNECT_RETRY-1)*time.Second, connectTimeout(n, MIN_CONNECT_RETRY))
+}
+
+func TestReapTime(t *testing.T) {
+	assert := assert.New(t)
+	g := &config.Global{}
+	assert.Equal(time.Duration(config.DEFAULT_FSM_REAP_TIME)*time.Second, reapTime(g, 0))
+
+	g.Config.FsmReapTime = 300
+	assert.Equal(300*time.Second, reapTime(g, 0))
+	// extended by the queued messages
+	assert.Equal(300*time.Second+128*FSM_REAP_TIME_PER_MESSAGE, reapTime(g, 128))
+
+	// capped
+	g.Config.FsmReapTime = config.MAX_FSM_REAP_TIME
+	assert.Equal(time.Duration(config.MAX_FSM_REAP_TIME)*time.Second, reapTime(g, 128))
+}
+
+func TestQueuedMessages(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestServer([]bgp.RouteFamily{bgp.RF_IPv4_UC})
+	peer := newTestPeer(s, testNeighbor("10.0.0.1", 65001), []bgp.RouteFamily{bgp.RF_IPv4_UC})
+	peer.outgoing = make(chan *bgp.BGPMessage, 4)
+	h := &FSMHandler{fsm: peer.fsm, outgoing: peer.outgoing}
+
+	// counted while queued in the sender, and in the outgoing channel
+	// once handed to it
+	m := newSenderMsg(peer, []*bgp.BGPMessage{bgp.NewBGPKeepAliveMessage(), bgp.NewBGPKeepAliveMessage()})
+	newSenderMsg(peer, []*bgp.BGPMessage{bgp.NewBGPKeepAliveMessage()})
+	assert.Equal(3, h.queuedMessages())
+	m.send()
+	assert.Equal(2, len(h.outgoing))
+	assert.Equal(3, h.queuedMessages())
+	<-h.outgoing
+	assert.Equal(2, h.queuedMessages())
+}
+
+func TestKeepaliveDelay(t *testing.T) {
+	assert := assert.New(t)
+	period := 30 * time.Second
+	now := time.Now()
+	assert.Equal(time.Duration(0), keepaliveDelay(period, time.Time{}, now))
+	assert.Equal(20*time.Second, keepaliveDelay(period, now.Add(-10*time.Second), now))
+	assert.Equal(time.Duration(0), keepaliveDelay(period, now.Add(-30*time.Second), now))
+}
+
+func TestKeepaliveJitter(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(1))
+	interval := 30 * time.Second
+	jittered := false
+	for i := 0; i < 100; i++ {
+		d := keepaliveJitter(interval, r)
+		assert.True(d <= interval)
+		assert.True(d >= interval*3/4)
+		if d != interval {
+			jittered = true
+		}
+	}
+	assert.True(jittered)
+}
+
+func TestFSMDialerLocalPort(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+
+	laddr, err := p.fsm.localAddr()
+	assert.Nil(err)
+	assert.Nil(laddr)
+
+	// pick a free local port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	p.fsm.pConf.Transport.Config.LocalAddress = "127.0.0.1"
+	p.fsm.pConf.Transport.Config.LocalPort = uint16(port)
+
+	dial := func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(err)
+		defer l.Close()
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			if c, err := l.Accept(); err == nil {
+				ioutil.ReadAll(c)
+				c.Close()
+			}
+		}()
+		laddr, err := p.fsm.localAddr()
+		assert.Nil(err)
+		conn, err := dialPeer(l.Addr().String(), laddr, time.Second)
+		if !assert.Nil(err) {
+			return
+		}
+		assert.Equal(port, conn.LocalAddr().(*net.TCPAddr).Port)
+		// closing first leaves the local port in TIME_WAIT
+		conn.Close()
+		<-closed
+		time.Sleep(100 * time.Millisecond)
+	}
+	dial()
+	// the port is bound again for reconnecting
+	dial()
+
+	// the error of the connection is returned
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	addr := l.Addr().String()
+	l.Close()
+	laddr, _ = p.fsm.localAddr()
+	_, err = dialPeer(addr, laddr, time.Second)
+	assert.NotNil(err)
+}
+
 func TestFSMHandlerOpenconfirm_HoldtimeZero(t *testing.T) {
 	log.SetLevel(log.DebugLevel)
 	assert := assert.New(t)
@@ -282,6 +1080,553 @@ func TestFSMHandlerEstablished_HoldtimeZero(t *testing.T) {
 	assert.Equal(0, len(m.sendBuf))
 }
 
+func TestFSMHandlerEstablished_RemoveEbgpLocalPref(t *testing.T) {
+	assert := assert.New(t)
+
+	update := func() *bgp.BGPMessage {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+			bgp.NewPathAttributeLocalPref(200),
+		}
+		nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+		return bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri)
+	}
+
+	hasLocalPref := func(honor bool) bool {
+		m := NewMockConnection()
+		p, h := makePeerAndHandler()
+		p.fsm.gConf.Config.As = 65000
+		p.fsm.pConf.Config.PeerAs = 65001
+		p.fsm.pConf.Config.HonorEbgpLocalPref = honor
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+		h.conn = m
+		h.msgCh = make(chan *FsmMsg, 1)
+		h.holdTimerResetCh = make(chan bool, 2)
+
+		buf, _ := update().Serialize()
+		m.setData(buf)
+		assert.Nil(h.recvMessageWithError())
+		e := <-h.msgCh
+		assert.Equal(1, len(e.PathList))
+		for _, a := range e.PathList[0].GetPathAttrs() {
+			if a.GetType() == bgp.BGP_ATTR_TYPE_LOCAL_PREF {
+				return true
+			}
+		}
+		return false
+	}
+
+	assert.False(hasLocalPref(false))
+	assert.True(hasLocalPref(true))
+}
+
+func TestFSMHandlerEstablished_MissingOrigin(t *testing.T) {
+	assert := assert.New(t)
+
+	update := func() *bgp.BGPMessage {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+		return bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri)
+	}
+
+	recv := func(accept bool) *FsmMsg {
+		m := NewMockConnection()
+		p, h := makePeerAndHandler()
+		p.fsm.gConf.Config.As = 65000
+		p.fsm.pConf.Config.PeerAs = 65000
+		p.fsm.pConf.Config.AcceptMissingOrigin = accept
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+		h.conn = m
+		h.msgCh = make(chan *FsmMsg, 1)
+		h.holdTimerResetCh = make(chan bool, 2)
+
+		buf, _ := update().Serialize()
+		m.setData(buf)
+		assert.Nil(h.recvMessageWithError())
+		return <-h.msgCh
+	}
+
+	// the session is reset
+	e := recv(false)
+	err, ok := e.MsgData.(*bgp.MessageError)
+	assert.True(ok)
+	assert.Equal(uint8(bgp.BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE), err.SubTypeCode)
+	assert.Equal(0, len(e.PathList))
+
+	// the path enters the RIB with ORIGIN INCOMPLETE
+	e = recv(true)
+	assert.Equal(1, len(e.PathList))
+	path := e.PathList[0]
+	assert.False(path.IsWithdraw)
+	var origin *bgp.PathAttributeOrigin
+	for _, a := range path.GetPathAttrs() {
+		if o, ok := a.(*bgp.PathAttributeOrigin); ok {
+			origin = o
+		}
+	}
+	assert.NotNil(origin)
+	assert.Equal([]byte{bgp.BGP_ORIGIN_ATTR_TYPE_INCOMPLETE}, origin.Value)
+
+	// and is advertised onward with it
+	path.UpdatePathAttrs(&config.Global{Config: config.GlobalConfig{As: 65000}}, &config.Neighbor{Config: config.NeighborConfig{PeerAs: 65000}})
+	msgs := table.CreateUpdateMsgFromPaths([]*table.Path{path})
+	assert.Equal(1, len(msgs))
+	buf, _ := msgs[0].Serialize()
+	_, err3 := bgp.ParseBGPMessage(buf)
+	assert.Nil(err3)
+	found := false
+	for _, a := range msgs[0].Body.(*bgp.BGPUpdate).PathAttributes {
+		if a.GetType() == bgp.BGP_ATTR_TYPE_ORIGIN {
+			found = true
+		}
+	}
+	assert.True(found)
+}
+
+func TestFSMHandlerEstablished_MissingNexthop(t *testing.T) {
+	assert := assert.New(t)
+
+	recv := func(update *bgp.BGPMessage) *FsmMsg {
+		m := NewMockConnection()
+		p, h := makePeerAndHandler()
+		p.fsm.gConf.Config.As = 65000
+		p.fsm.pConf.Config.PeerAs = 65000
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		p.fsm.rfMap[bgp.RF_IPv6_UC] = true
+		p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+		h.conn = m
+		h.msgCh = make(chan *FsmMsg, 1)
+		h.holdTimerResetCh = make(chan bool, 2)
+
+		buf, _ := update.Serialize()
+		m.setData(buf)
+		assert.Nil(h.recvMessageWithError())
+		return <-h.msgCh
+	}
+	origin := bgp.NewPathAttributeOrigin(0)
+	aspath := bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})})
+
+	// the NLRI in MP_REACH_NLRI carries its own next hop
+	e := recv(bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		origin,
+		aspath,
+		bgp.NewPathAttributeMpReachNLRI("2001:db8::1", []bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(64, "2001:db8:1::")}),
+	}, nil))
+	_, ok := e.MsgData.(*bgp.MessageError)
+	assert.False(ok)
+	assert.Equal(1, len(e.PathList))
+	assert.Equal("2001:db8::1", e.PathList[0].GetNexthop().String())
+
+	// the session is reset for the IPv4 NLRI without NEXT_HOP
+	e = recv(bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{origin, aspath}, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}))
+	err, ok := e.MsgData.(*bgp.MessageError)
+	assert.True(ok)
+	assert.Equal(uint8(bgp.BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE), err.SubTypeCode)
+	assert.Equal([]byte{byte(bgp.BGP_ATTR_TYPE_NEXT_HOP)}, err.Data)
+	assert.Equal(0, len(e.PathList))
+}
+
+func TestFSMHandlerEstablished_OrphanAs4Path(t *testing.T) {
+	assert := assert.New(t)
+
+	recv := func(action config.OrphanAs4PathActionType, m *bgp.BGPMessage) *FsmMsg {
+		conn := NewMockConnection()
+		p, h := makePeerAndHandler()
+		p.fsm.pConf.Config.OrphanAs4PathAction = action
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		p.fsm.rfMap[bgp.RF_IPv6_UC] = true
+		p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+		h.conn = conn
+		h.msgCh = make(chan *FsmMsg, 1)
+		h.holdTimerResetCh = make(chan bool, 2)
+
+		buf, _ := m.Serialize()
+		conn.setData(buf)
+		assert.Nil(h.recvMessageWithError())
+		return <-h.msgCh
+	}
+	as4Path := bgp.NewPathAttributeAs4Path([]*bgp.As4PathParam{bgp.NewAs4PathParam(2, []uint32{4200000001})})
+	hasAs4Path := func(path *table.Path) bool {
+		for _, a := range path.GetPathAttrs() {
+			if a.GetType() == bgp.BGP_ATTR_TYPE_AS4_PATH {
+				return true
+			}
+		}
+		return false
+	}
+
+	// AS4_PATH in the withdrawal is discarded
+	withdrawn := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+	e := recv(config.ORPHAN_AS4_PATH_ACTION_TYPE_DISCARD, bgp.NewBGPUpdateMessage(withdrawn, []bgp.PathAttributeInterface{as4Path}, nil))
+	assert.IsType(&bgp.BGPMessage{}, e.MsgData)
+	assert.Equal(1, len(e.PathList))
+	assert.True(e.PathList[0].IsWithdraw)
+	assert.False(hasAs4Path(e.PathList[0]))
+
+	// the routes are treated as withdrawn instead of resetting the
+	// session for the missing AS_PATH
+	nlri := []bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(64, "2001:db8::")}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		as4Path,
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+		bgp.NewPathAttributeMpReachNLRI("2001:db8::1", nlri),
+	}
+	update := bgp.NewBGPUpdateMessage(nil, pathAttributes, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.20.20.0")})
+	e = recv(config.ORPHAN_AS4_PATH_ACTION_TYPE_TREAT_AS_WITHDRAW, update)
+	assert.IsType(&bgp.BGPMessage{}, e.MsgData)
+	assert.Equal(2, len(e.PathList))
+	prefixes := make([]string, 0, 2)
+	for _, path := range e.PathList {
+		assert.True(path.IsWithdraw)
+		assert.False(hasAs4Path(path))
+		prefixes = append(prefixes, path.GetNlri().String())
+	}
+	assert.Contains(prefixes, "10.20.20.0/24")
+	assert.Contains(prefixes, "2001:db8::/64")
+
+	// with discard, the missing AS_PATH still resets the session
+	e = recv(config.ORPHAN_AS4_PATH_ACTION_TYPE_DISCARD, update)
+	err, ok := e.MsgData.(*bgp.MessageError)
+	assert.True(ok)
+	assert.Equal(uint8(bgp.BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE), err.SubTypeCode)
+}
+
+func TestFSMHandlerEstablished_UnrecognizedCommunity(t *testing.T) {
+	assert := assert.New(t)
+	unrecognized := uint32(0xffff1234)
+	communities := []uint32{65001<<16 | 100, bgp.COMMUNITY_NO_EXPORT, unrecognized}
+
+	m := NewMockConnection()
+	p, h := makePeerAndHandler()
+	p.fsm.gConf.Config.As = 65000
+	p.fsm.pConf.Config.PeerAs = 65001
+	p.fsm.pConf.Config.StrictWellKnownCommunities = true
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	h.conn = m
+	h.msgCh = make(chan *FsmMsg, 1)
+	h.holdTimerResetCh = make(chan bool, 2)
+
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+		bgp.NewPathAttributeCommunities(communities),
+	}
+	nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+	buf, _ := bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri).Serialize()
+	m.setData(buf)
+	assert.Nil(h.recvMessageWithError())
+	e := <-h.msgCh
+	assert.Equal(1, len(e.PathList))
+	path := e.PathList[0]
+	assert.False(path.IsWithdraw)
+	assert.Equal([]uint32{unrecognized}, unrecognizedCommunities(path))
+
+	// the unrecognized community is passed through untouched
+	path.UpdatePathAttrs(&config.Global{Config: config.GlobalConfig{As: 65000}}, &config.Neighbor{Config: config.NeighborConfig{PeerAs: 65002}})
+	assert.Equal(communities, path.GetCommunities())
+	msgs := table.CreateUpdateMsgFromPaths([]*table.Path{path})
+	assert.Equal(1, len(msgs))
+	u := msgs[0].Body.(*bgp.BGPUpdate)
+	assert.Equal(1, len(u.NLRI))
+	found := false
+	for _, a := range u.PathAttributes {
+		if c, ok := a.(*bgp.PathAttributeCommunities); ok {
+			assert.Equal(communities, c.Value)
+			found = true
+		}
+	}
+	assert.True(found)
+}
+
+func TestFSMHandlerEstablished_MaxAsPathLength(t *testing.T) {
+	assert := assert.New(t)
+
+	update := func() *bgp.BGPMessage {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001, 65002, 65003})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+		return bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri)
+	}
+
+	isWithdraw := func(max uint32) bool {
+		m := NewMockConnection()
+		p, h := makePeerAndHandler()
+		p.fsm.gConf.Config.As = 65000
+		p.fsm.pConf.Config.PeerAs = 65001
+		p.fsm.pConf.Config.MaxAsPathLength = max
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+		h.conn = m
+		h.msgCh = make(chan *FsmMsg, 1)
+		h.holdTimerResetCh = make(chan bool, 2)
+
+		buf, _ := update().Serialize()
+		m.setData(buf)
+		assert.Nil(h.recvMessageWithError())
+		e := <-h.msgCh
+		assert.Equal(1, len(e.PathList))
+		return e.PathList[0].IsWithdraw
+	}
+
+	assert.False(isWithdraw(0))
+	assert.False(isWithdraw(3))
+	assert.True(isWithdraw(2))
+}
+
+func TestFSMHandlerEstablished_OriginatorIdLoop(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+	p, h := makePeerAndHandler()
+	p.fsm.gConf.Config.As = 65000
+	p.fsm.gConf.Config.RouterId = "10.0.0.254"
+	p.fsm.pConf.Config.PeerAs = 65000
+	p.fsm.pConf.Config.LocalRouterId = "10.0.0.253"
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	h.conn = m
+	h.msgCh = make(chan *FsmMsg, 1)
+	h.holdTimerResetCh = make(chan bool, 2)
+
+	isWithdraw := func(originatorId string) bool {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+			bgp.NewPathAttributeLocalPref(100),
+			bgp.NewPathAttributeOriginatorId(originatorId),
+			bgp.NewPathAttributeClusterList([]string{"10.0.0.2"}),
+		}
+		nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+		buf, _ := bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri).Serialize()
+		m.setData(buf)
+		assert.Nil(h.recvMessageWithError())
+		e := <-h.msgCh
+		assert.Equal(1, len(e.PathList))
+		return e.PathList[0].IsWithdraw
+	}
+
+	assert.False(isWithdraw("10.0.0.3"))
+	assert.Equal(uint32(0), p.fsm.pConf.State.OriginatorIdLoops)
+	// the router-id used for the session
+	assert.True(isWithdraw("10.0.0.253"))
+	assert.True(isWithdraw("10.0.0.254"))
+	assert.Equal(uint32(2), p.fsm.pConf.State.OriginatorIdLoops)
+}
+
+func TestFSMCheckPathLimits(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+
+	communities := make([]uint32, 0, 10)
+	for i := 0; i < 10; i++ {
+		communities = append(communities, uint32(65001<<16|i))
+	}
+	exts := []bgp.ExtendedCommunityInterface{
+		bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65001, 100, true),
+		bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65001, 200, true),
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+		bgp.NewPathAttributeCommunities(communities),
+		bgp.NewPathAttributeExtendedCommunities(exts),
+	}
+	path := table.NewPath(p.fsm.peerInfo, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, pathAttributes, time.Now(), false)
+
+	assert.Nil(p.fsm.checkPathLimits(path))
+
+	p.fsm.pConf.Config.MaxCommunities = 10
+	p.fsm.pConf.Config.MaxExtCommunities = 2
+	assert.Nil(p.fsm.checkPathLimits(path))
+
+	p.fsm.pConf.Config.MaxCommunities = 9
+	assert.NotNil(p.fsm.checkPathLimits(path))
+
+	p.fsm.pConf.Config.MaxCommunities = 0
+	p.fsm.pConf.Config.MaxExtCommunities = 1
+	assert.NotNil(p.fsm.checkPathLimits(path))
+
+	p.fsm.pConf.Config.CommunityLimitAction = config.COMMUNITY_LIMIT_ACTION_TYPE_TRUNCATE
+	p.fsm.pConf.Config.MaxCommunities = 4
+	assert.Nil(p.fsm.checkPathLimits(path))
+	assert.Equal(communities[:4], path.GetCommunities())
+	assert.Equal(exts[:1], path.GetExtCommunities())
+}
+
+func TestFSMClampMed(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	path := func(med uint32) *table.Path {
+		return table.NewPath(p.fsm.peerInfo, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+			bgp.NewPathAttributeMultiExitDisc(med),
+		}, time.Now(), false)
+	}
+	med := func(path *table.Path) uint32 {
+		m, err := path.GetMed()
+		assert.Nil(err)
+		return m
+	}
+
+	// disabled by default
+	high := path(math.MaxUint32)
+	p.fsm.clampMed(high)
+	assert.Equal(uint32(math.MaxUint32), med(high))
+
+	p.fsm.pConf.Config.MaxMed = 1000
+	p.fsm.clampMed(high)
+	assert.Equal(uint32(1000), med(high))
+
+	low := path(999)
+	p.fsm.clampMed(low)
+	assert.Equal(uint32(999), med(low))
+
+	// no MED
+	none := table.NewPath(p.fsm.peerInfo, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}, time.Now(), false)
+	p.fsm.clampMed(none)
+	_, err := none.GetMed()
+	assert.NotNil(err)
+}
+
+func TestFSMRewriteUnresolvableNexthop(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	p.fsm.pConf.Config.NeighborAddress = "192.168.1.1"
+	p.fsm.pConf.Config.PeerType = config.PEER_TYPE_EXTERNAL
+	path := func(nexthop string) *table.Path {
+		return table.NewPath(p.fsm.peerInfo, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop(nexthop),
+		}, time.Now(), false)
+	}
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	connected := []net.Addr{subnet}
+
+	// off by default
+	assert.False(p.fsm.rewritesNexthop())
+	p.fsm.pConf.EbgpMultihop.Config.RewriteUnresolvableNexthop = true
+	assert.False(p.fsm.rewritesNexthop())
+	p.fsm.pConf.EbgpMultihop.Config.Enabled = true
+	assert.True(p.fsm.rewritesNexthop())
+
+	unresolvable := path("172.16.0.1")
+	p.fsm.rewriteUnresolvableNexthop(unresolvable, connected)
+	assert.Equal("192.168.1.1", unresolvable.GetNexthop().String())
+
+	resolvable := path("10.0.0.1")
+	p.fsm.rewriteUnresolvableNexthop(resolvable, connected)
+	assert.Equal("10.0.0.1", resolvable.GetNexthop().String())
+
+	// the address family differs from the neighbor address
+	p.fsm.pConf.Config.NeighborAddress = "2001:db8::1"
+	other := path("172.16.0.1")
+	p.fsm.rewriteUnresolvableNexthop(other, connected)
+	assert.Equal("172.16.0.1", other.GetNexthop().String())
+
+	p.fsm.pConf.Config.PeerType = config.PEER_TYPE_INTERNAL
+	assert.False(p.fsm.rewritesNexthop())
+}
+
+func TestPathAttributeFields(t *testing.T) {
+	assert := assert.New(t)
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001, 65002})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+		bgp.NewPathAttributeMultiExitDisc(100),
+		bgp.NewPathAttributeCommunities([]uint32{65001<<16 | 10, uint32(bgp.COMMUNITY_NO_EXPORT)}),
+		bgp.NewPathAttributeExtendedCommunities([]bgp.ExtendedCommunityInterface{bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65001, 100, true)}),
+	}
+	path := table.NewPath(&table.PeerInfo{}, bgp.NewIPAddrPrefix(24, "10.10.0.0"), false, attrs, time.Now(), false)
+	fields := pathAttributeFields(path)
+	assert.Equal("10.10.0.0/24", fields["Prefix"])
+	assert.Equal(false, fields["Withdraw"])
+	assert.Equal("10.0.0.1", fields["Nexthop"])
+	assert.Equal("65001 65002", fields["AsPath"])
+	assert.Equal(uint32(100), fields["Med"])
+	assert.Equal("65001:10 no-export", fields["Communities"])
+	assert.Equal("65001:100", fields["ExtCommunities"])
+
+	fields = pathAttributeFields(table.NewPath(&table.PeerInfo{}, bgp.NewIPAddrPrefix(24, "10.10.0.0"), true, nil, time.Now(), false))
+	assert.Equal(true, fields["Withdraw"])
+	assert.Equal(2, len(fields))
+}
+
+func TestUpdateAttributeFields(t *testing.T) {
+	assert := assert.New(t)
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		// a 2-octet AS_PATH as sent to an OLD speaker
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAsPathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint16{65001})}),
+		bgp.NewPathAttributeMpReachNLRI("2001:db8::1", []bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(64, "2001:db8:1::")}),
+		bgp.NewPathAttributeMpUnreachNLRI([]bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(64, "2001:db8:2::")}),
+	}
+	update := bgp.NewBGPUpdateMessage([]*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.20.0.0")}, attrs, nil).Body.(*bgp.BGPUpdate)
+	list := updateAttributeFields(update)
+	assert.Equal(3, len(list))
+	assert.Equal("10.20.0.0/24", list[0]["Prefix"])
+	assert.Equal(true, list[0]["Withdraw"])
+	assert.Equal("2001:db8:1::/64", list[1]["Prefix"])
+	assert.Equal(false, list[1]["Withdraw"])
+	assert.Equal("2001:db8::1", list[1]["Nexthop"])
+	assert.Equal("65001", list[1]["AsPath"])
+	assert.Equal("2001:db8:2::/64", list[2]["Prefix"])
+	assert.Equal(true, list[2]["Withdraw"])
+}
+
+func TestFSMCheckAsTrans(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+
+	// an OLD speaker dropped AS4_PATH
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAsPathParam(2, []uint16{65001, bgp.AS_TRANS})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	m := bgp.NewBGPUpdateMessage(nil, pathAttributes, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")})
+	table.UpdatePathAttrs4ByteAs(m.Body.(*bgp.BGPUpdate))
+	path := table.ProcessMessage(m, p.fsm.peerInfo, time.Now())[0]
+
+	buf := bytes.NewBuffer(nil)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	assert.Nil(p.fsm.checkAsTrans(path))
+	assert.Contains(buf.String(), "AS_TRANS remains without AS4 information")
+
+	p.fsm.pConf.Config.RejectAsTrans = true
+	assert.NotNil(p.fsm.checkAsTrans(path))
+
+	// AS4_PATH replaces AS_TRANS
+	pathAttributes = append(pathAttributes, bgp.NewPathAttributeAs4Path([]*bgp.As4PathParam{bgp.NewAs4PathParam(2, []uint32{4200000000})}))
+	m = bgp.NewBGPUpdateMessage(nil, pathAttributes, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")})
+	table.UpdatePathAttrs4ByteAs(m.Body.(*bgp.BGPUpdate))
+	path = table.ProcessMessage(m, p.fsm.peerInfo, time.Now())[0]
+	assert.Equal([]uint32{65001, 4200000000}, path.GetAsList())
+	assert.Nil(p.fsm.checkAsTrans(path))
+}
+
 func makePeerAndHandler() (*Peer, *FSMHandler) {
 	gConf := config.Global{}
 	pConf := config.Neighbor{}
@@ -325,3 +1670,284 @@ func open() *bgp.BGPMessage {
 func keepalive() *bgp.BGPMessage {
 	return bgp.NewBGPKeepAliveMessage()
 }
+
+func TestFSMPipeTransport(t *testing.T) {
+	assert := assert.New(t)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{
+		Config: config.NeighborConfig{NeighborAddress: "10.0.0.1", PeerAs: 65001},
+		Timers: config.Timers{Config: config.TimersConfig{HoldTime: 90, KeepaliveInterval: 30}},
+		AfiSafis: []config.AfiSafi{{
+			AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST,
+			Config:      config.AfiSafiConfig{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST, Enabled: true},
+		}},
+	}
+	n.Transport.Config.PassiveMode = true
+	p := NewPeer(g, n, table.NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0), table.NewRoutingPolicy())
+	incoming := make(chan *FsmMsg, 16)
+	stateCh := make(chan *FsmMsg, 16)
+	p.startFSMHandler(incoming, stateCh)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	// the remote end of the pipe plays the peer
+	readType := func() uint8 {
+		hd, err := readAll(remote, bgp.BGP_HEADER_LENGTH)
+		if err != nil {
+			return 0
+		}
+		h := &bgp.BGPHeader{}
+		if err := h.DecodeFromBytes(hd); err != nil {
+			return 0
+		}
+		if _, err := readAll(remote, int(h.Len)-bgp.BGP_HEADER_LENGTH); err != nil {
+			return 0
+		}
+		return h.Type
+	}
+	write := func(m *bgp.BGPMessage) {
+		b, _ := m.Serialize()
+		remote.Write(b)
+	}
+	go func() {
+		assert.Equal(uint8(bgp.BGP_MSG_OPEN), readType())
+		write(bgp.NewBGPOpenMessage(65001, 90, "10.0.0.1", []bgp.OptionParameterInterface{
+			bgp.NewOptionParameterCapability([]bgp.ParameterCapabilityInterface{bgp.NewCapMultiProtocol(bgp.RF_IPv4_UC)}),
+		}))
+		assert.Equal(uint8(bgp.BGP_MSG_KEEPALIVE), readType())
+		write(bgp.NewBGPKeepAliveMessage())
+	}()
+
+	states := []bgp.FSMState{}
+	for state, _ := p.fsm.State(); state != bgp.BGP_FSM_ESTABLISHED; state, _ = p.fsm.State() {
+		select {
+		case e := <-stateCh:
+			nextState := e.MsgData.(bgp.FSMState)
+			states = append(states, nextState)
+			p.fsm.StateChange(nextState, e.reason)
+			p.startFSMHandler(incoming, stateCh)
+			// the idle state closes a connection passed to it
+			if nextState == bgp.BGP_FSM_ACTIVE {
+				p.PassConn(local)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the session didn't get established")
+		}
+	}
+	assert.Equal([]bgp.FSMState{bgp.BGP_FSM_ACTIVE, bgp.BGP_FSM_OPENSENT, bgp.BGP_FSM_OPENCONFIRM, bgp.BGP_FSM_ESTABLISHED}, states)
+	assert.True(p.fsm.rfMap[bgp.RF_IPv4_UC])
+	assert.Equal("10.0.0.1", p.fsm.peerInfo.ID.String())
+
+	remote.Close()
+	p.fsm.h.t.Kill(nil)
+	p.fsm.h.t.Wait()
+}
+
+func TestFSMStateConcurrentRead(t *testing.T) {
+	_, h := makePeerAndHandler()
+	fsm := h.fsm
+	transitions := map[bgp.FSMState]FsmStateReason{
+		bgp.BGP_FSM_IDLE:        FSM_HOLD_TIMER_EXPIRED,
+		bgp.BGP_FSM_OPENSENT:    FSM_NOTIFICATION_SENT,
+		bgp.BGP_FSM_OPENCONFIRM: FSM_NOTIFICATION_RECV,
+		bgp.BGP_FSM_ESTABLISHED: FSM_READ_FAILED,
+	}
+	fsm.StateChange(bgp.BGP_FSM_IDLE, FSM_HOLD_TIMER_EXPIRED)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			for state, reason := range transitions {
+				fsm.StateChange(state, reason)
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		// the state and the reason are always read as a pair
+		state, reason := fsm.State()
+		if transitions[state] != reason {
+			t.Fatalf("inconsistent state %s and reason %s", state, reason)
+		}
+	}
+}
+
+func TestExtendedNexthopNegotiated(t *testing.T) {
+	assert := assert.New(t)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{
+		Config: config.NeighborConfig{NeighborAddress: "2001:db8::2", PeerAs: 65001},
+		AfiSafis: []config.AfiSafi{{
+			AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST,
+			Config:      config.AfiSafiConfig{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST, Enabled: true},
+		}},
+	}
+	fsm := NewFSM(&g, &n, table.NewRoutingPolicy())
+	hasCap := func() bool {
+		for _, c := range capabilitiesFromConfig(&g, &n) {
+			if c.Code() == bgp.BGP_CAP_EXTENDED_NEXTHOP {
+				return true
+			}
+		}
+		return false
+	}
+	assert.False(hasCap())
+
+	n.Config.ExtendedNexthop = true
+	assert.True(hasCap())
+	fsm.rfMap[bgp.RF_IPv4_UC] = true
+	assert.False(fsm.extendedNexthopNegotiated())
+	fsm.capMap[bgp.BGP_CAP_EXTENDED_NEXTHOP] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapExtendedNexthop([]bgp.CapExtendedNexthopTuple{{NLRIAFI: bgp.AFI_IP, NLRISAFI: bgp.SAFI_UNICAST, NexthopAFI: bgp.AFI_IP6}}),
+	}
+	assert.True(fsm.extendedNexthopNegotiated())
+
+	n.Config.ExtendedNexthop = false
+	assert.False(fsm.extendedNexthopNegotiated())
+}
+
+func TestPathsLimit(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	p.fsm.pConf.AfiSafis = []config.AfiSafi{
+		{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST},
+		{AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST},
+	}
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.fsm.rfMap[bgp.RF_IPv6_UC] = true
+	p.fsm.pConf.AddPaths.Config.PathsLimit = 4
+	// add-paths receive isn't configured
+	assert.Nil(pathsLimitCapability(p.fsm.pConf))
+
+	p.fsm.pConf.AddPaths.Config.Receive = true
+	var limit *bgp.CapPathsLimit
+	for _, c := range capabilitiesFromConfig(p.fsm.gConf, p.fsm.pConf) {
+		if c.Code() == bgp.BGP_CAP_PATHS_LIMIT {
+			limit = c.(*bgp.CapPathsLimit)
+		}
+	}
+	assert.NotNil(limit)
+	assert.Equal(uint16(4), limit.Limit(bgp.RF_IPv4_UC))
+	assert.Equal(uint16(4), limit.Limit(bgp.RF_IPv6_UC))
+
+	// add-paths isn't negotiated yet
+	assert.Equal(0, p.fsm.pathsLimit(bgp.RF_IPv4_UC))
+	assert.Equal(uint16(0), p.fsm.peerPathsLimit(bgp.RF_IPv4_UC))
+
+	// the peer sends multiple paths of IPv4 unicast only and receives
+	// up to 3 and 2 paths
+	p.fsm.capMap[bgp.BGP_CAP_ADD_PATH] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapAddPath(bgp.RF_IPv4_UC, bgp.BGP_ADD_PATH_BOTH),
+		bgp.NewCapAddPath(bgp.RF_IPv6_UC, bgp.BGP_ADD_PATH_RECEIVE),
+	}
+	p.fsm.capMap[bgp.BGP_CAP_PATHS_LIMIT] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapPathsLimit([]bgp.CapPathsLimitValue{{RouteFamily: bgp.RF_IPv4_UC, Limit: 3}, {RouteFamily: bgp.RF_IPv6_UC, Limit: 2}}),
+	}
+	p.fsm.marshalOption = p.fsm.addPathOption()
+	assert.Equal(4, p.fsm.pathsLimit(bgp.RF_IPv4_UC))
+	assert.Equal(0, p.fsm.pathsLimit(bgp.RF_IPv6_UC))
+	assert.Equal(uint16(3), p.fsm.peerPathsLimit(bgp.RF_IPv4_UC))
+	assert.Equal(uint16(2), p.fsm.peerPathsLimit(bgp.RF_IPv6_UC))
+}
+
+func TestFSMHandlerEstablished_AddPathReceive(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	m := NewMockConnection()
+	p, h := makePeerAndHandler()
+	p.fsm.gConf.Config.As = 65000
+	p.fsm.pConf.Config.PeerAs = 65001
+	p.fsm.pConf.AddPaths.Config.Receive = true
+	p.fsm.pConf.AfiSafis = []config.AfiSafi{{
+		AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST,
+		Config:      config.AfiSafiConfig{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST, Enabled: true},
+	}}
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	h.conn = m
+	h.msgCh = make(chan *FsmMsg, 1)
+	h.holdTimerResetCh = make(chan bool, 2)
+
+	hasCap := false
+	for _, c := range capabilitiesFromConfig(p.fsm.gConf, p.fsm.pConf) {
+		if c.Code() == bgp.BGP_CAP_ADD_PATH {
+			hasCap = true
+			assert.Equal(bgp.BGP_ADD_PATH_RECEIVE, c.(*bgp.CapAddPath).Mode)
+		}
+	}
+	assert.True(hasCap)
+
+	// the peer doesn't send multiple paths
+	assert.Nil(p.fsm.addPathOption())
+	p.fsm.capMap[bgp.BGP_CAP_ADD_PATH] = []bgp.ParameterCapabilityInterface{bgp.NewCapAddPath(bgp.RF_IPv4_UC, bgp.BGP_ADD_PATH_BOTH)}
+	p.fsm.marshalOption = p.fsm.addPathOption()
+	assert.NotNil(p.fsm.marshalOption)
+
+	update := func(withdraw bool, ids ...uint32) []byte {
+		nlri := make([]byte, 0)
+		for _, id := range ids {
+			b := make([]byte, 4)
+			binary.BigEndian.PutUint32(b, id)
+			nlri = append(nlri, b...)
+			b, _ = bgp.NewIPAddrPrefix(24, "10.10.10.0").Serialize()
+			nlri = append(nlri, b...)
+		}
+		body := make([]byte, 0)
+		if withdraw {
+			body = append(body, 0, byte(len(nlri)))
+			body = append(body, nlri...)
+			body = append(body, 0, 0)
+		} else {
+			attrs := make([]byte, 0)
+			for _, a := range []bgp.PathAttributeInterface{
+				bgp.NewPathAttributeOrigin(0),
+				bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+				bgp.NewPathAttributeNextHop("10.0.0.1"),
+			} {
+				b, _ := a.Serialize()
+				attrs = append(attrs, b...)
+			}
+			body = append(body, 0, 0, 0, byte(len(attrs)))
+			body = append(body, attrs...)
+			body = append(body, nlri...)
+		}
+		hd := &bgp.BGPHeader{Len: uint16(bgp.BGP_HEADER_LENGTH + len(body)), Type: bgp.BGP_MSG_UPDATE}
+		buf, _ := hd.Serialize()
+		return append(buf, body...)
+	}
+	recv := func(buf []byte) []*table.Path {
+		m.setData(buf)
+		assert.Nil(h.recvMessageWithError())
+		e := <-h.msgCh
+		return e.PathList
+	}
+
+	pathList := recv(update(false, 1, 2))
+	assert.Equal(2, len(pathList))
+	assert.Equal(uint32(1), pathList[0].GetPathIdentifier())
+	assert.Equal(uint32(2), pathList[1].GetPathIdentifier())
+
+	adjRibIn := table.NewAdjRib("10.0.0.1", rfList)
+	adjRibIn.Update(pathList)
+	assert.Equal(2, adjRibIn.Count(rfList))
+	rib := table.NewTableManager(rfList, 0, 0)
+	rib.ProcessPaths(pathList)
+	assert.Equal(2, len(rib.Tables[bgp.RF_IPv4_UC].GetDestination("10.10.10.0/24").GetAllKnownPathList()))
+
+	// only the path with the identifier is withdrawn
+	pathList = recv(update(true, 1))
+	assert.Equal(1, len(pathList))
+	assert.True(pathList[0].IsWithdraw)
+	adjRibIn.Update(pathList)
+	assert.Equal(1, adjRibIn.Count(rfList))
+	rib.ProcessPaths(pathList)
+	known := rib.Tables[bgp.RF_IPv4_UC].GetDestination("10.10.10.0/24").GetAllKnownPathList()
+	assert.Equal(1, len(known))
+	assert.Equal(uint32(2), known[0].GetPathIdentifier())
+}
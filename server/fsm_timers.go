@@ -0,0 +1,96 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"sync"
+	"time"
+)
+
+// fsmDeadlines are the times the FSM timers fire next. The timers are
+// locals of the FSM handler goroutines, which record the deadlines
+// here whenever they start, reset or stop a timer. The zero time means
+// that the timer isn't running.
+type fsmDeadlines struct {
+	lock         sync.Mutex
+	hold         time.Time
+	keepalive    time.Time
+	connectRetry time.Time
+	idleHold     time.Time
+}
+
+// set records that the timer fires after d, or that it's stopped when
+// d isn't positive.
+func (d *fsmDeadlines) set(deadline *time.Time, after time.Duration) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if after <= 0 {
+		*deadline = time.Time{}
+		return
+	}
+	*deadline = time.Now().Add(after)
+}
+
+// FSMTimers are the remaining times until the FSM timers of a neighbor
+// fire. Zero means that the timer isn't running.
+type FSMTimers struct {
+	HoldTimer         time.Duration
+	KeepaliveTimer    time.Duration
+	ConnectRetryTimer time.Duration
+	IdleHoldTimer     time.Duration
+}
+
+func remaining(deadline, now time.Time) time.Duration {
+	if deadline.IsZero() || !deadline.After(now) {
+		return 0
+	}
+	return deadline.Sub(now)
+}
+
+// Timers returns the remaining times of the FSM timers. All of them
+// are read at once so that they are consistent with each other. It's
+// safe to call from any goroutine.
+func (fsm *FSM) Timers() FSMTimers {
+	state, _ := fsm.State()
+	d := &fsm.deadlines
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := time.Now()
+	t := FSMTimers{
+		HoldTimer:      remaining(d.hold, now),
+		KeepaliveTimer: remaining(d.keepalive, now),
+		IdleHoldTimer:  remaining(d.idleHold, now),
+	}
+	// the connect retry ticker keeps running in the other states but
+	// tries to connect only in ACTIVE
+	if state == bgp.BGP_FSM_ACTIVE {
+		t.ConnectRetryTimer = remaining(d.connectRetry, now)
+	}
+	return t
+}
+
+// NeighborTimers returns the remaining times of the FSM timers of the
+// neighbor.
+func (server *BgpServer) NeighborTimers(addr string) (FSMTimers, error) {
+	req := NewGrpcRequest(REQ_NEIGHBOR_TIMERS, addr, bgp.RouteFamily(0), nil)
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	if res.ResponseErr != nil {
+		return FSMTimers{}, res.ResponseErr
+	}
+	return res.Data.(FSMTimers), nil
+}
@@ -71,6 +71,17 @@ const (
 	REQ_BMP_NEIGHBORS
 	REQ_BMP_GLOBAL
 	REQ_BMP_ADJ_IN
+	REQ_NEIGHBOR_SEND_KEEPALIVE
+	REQ_NEIGHBOR_FAMILIES
+	REQ_NEXTHOP_HOLD_DOWN
+	REQ_NEIGHBOR_PREFIX_ORF
+	REQ_MONITOR_ROUTE_CHANGE
+	REQ_METRICS
+	REQ_NEIGHBOR_TIMERS
+	REQ_NEIGHBOR_INJECT_NOTIFICATION
+	REQ_MONITOR_RIB_JOURNAL
+	REQ_RIB_JOURNAL
+	REQ_RIB_SNAPSHOT
 )
 
 type Server struct {
@@ -448,6 +459,35 @@ func (s *Server) ModGlobalConfig(ctx context.Context, arg *api.ModGlobalConfigAr
 	return s.mod(REQ_MOD_GLOBAL_CONFIG, arg)
 }
 
+func (s *Server) MonitorRibJournal(arg *api.Arguments, stream api.GobgpApi_MonitorRibJournalServer) error {
+	req := NewGrpcRequest(REQ_MONITOR_RIB_JOURNAL, "", bgp.RouteFamily(0), nil)
+	s.bgpServerCh <- req
+	return handleMultipleResponses(req, func(res *GrpcResponse) error {
+		return stream.Send(res.Data.(*RibJournalEntry).ToApiStruct())
+	})
+}
+
+func (s *Server) GetRibJournal(arg *api.RibJournalArguments, stream api.GobgpApi_GetRibJournalServer) error {
+	d, err := s.get(REQ_RIB_JOURNAL, arg.Since)
+	if err != nil {
+		return err
+	}
+	for _, e := range d.([]*RibJournalEntry) {
+		if err := stream.Send(e.ToApiStruct()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) GetRibSnapshot(ctx context.Context, arg *api.Arguments) (*api.RibSnapshot, error) {
+	d, err := s.get(REQ_RIB_SNAPSHOT, nil)
+	if err != nil {
+		return nil, err
+	}
+	return d.(*RibSnapshot).ToApiStruct(), nil
+}
+
 type GrpcRequest struct {
 	RequestType int
 	Name        string
@@ -0,0 +1,79 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"net"
+	"time"
+)
+
+// newTestServer returns a server with the global RIB of the families and
+// the default policies accepting every route.
+func newTestServer(rfList []bgp.RouteFamily) *BgpServer {
+	server := NewBgpServer()
+	server.globalRib = table.NewTableManager(rfList, 0, 0)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IMPORT, table.ROUTE_TYPE_ACCEPT)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	server.bgpConfig.Global.Config = config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}
+	return server
+}
+
+func testNeighbor(addr string, as uint32) config.Neighbor {
+	return config.Neighbor{Config: config.NeighborConfig{NeighborAddress: addr, PeerAs: as}}
+}
+
+// newTestPeer registers the peer of the neighbor to the server as
+// established on the families, with empty Adj-RIBs.
+func newTestPeer(server *BgpServer, n config.Neighbor, rfList []bgp.RouteFamily) *Peer {
+	p := NewPeer(server.bgpConfig.Global, n, server.globalRib, server.policy)
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	for _, rf := range rfList {
+		p.fsm.rfMap[rf] = true
+	}
+	p.adjRibIn = table.NewAdjRib(p.ID(), rfList)
+	p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+	server.neighborMap[n.Config.NeighborAddress] = p
+	return p
+}
+
+// newTestPath returns the IPv4 unicast path of the prefix from the source
+// with ORIGIN, AS_PATH 65001 and NEXT_HOP 10.0.0.1. The attributes replace
+// the ones of the same type or are added.
+func newTestPath(source *table.PeerInfo, prefix string, withdraw bool, attrs ...bgp.PathAttributeInterface) *table.Path {
+	_, n, _ := net.ParseCIDR(prefix)
+	length, _ := n.Mask.Size()
+	pattrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	for _, a := range attrs {
+		replaced := false
+		for i, p := range pattrs {
+			if p.GetType() == a.GetType() {
+				pattrs[i] = a
+				replaced = true
+			}
+		}
+		if !replaced {
+			pattrs = append(pattrs, a)
+		}
+	}
+	return table.NewPath(source, bgp.NewIPAddrPrefix(uint8(length), n.IP.String()), withdraw, pattrs, time.Now(), false)
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// FamilyMetrics are the statistics of the global rib of a family.
+type FamilyMetrics struct {
+	Family bgp.RouteFamily
+	// the number of the prefixes having a best path
+	Prefixes int
+	// the number of the best path changes by the reason
+	BestPathChanges map[table.BestPathReason]uint64
+	// the maximum and the average parent depths of the paths
+	MaxParentDepth     int
+	AverageParentDepth float64
+}
+
+// NeighborMetrics are the statistics of a neighbor. The sizes of the
+// Adj-RIBs are the numbers of the prefixes by the family.
+type NeighborMetrics struct {
+	Address   string
+	Messages  config.Messages
+	AdjRibIn  map[bgp.RouteFamily]int
+	AdjRibOut map[bgp.RouteFamily]int
+}
+
+// Metrics is a snapshot of the statistics exported as metrics.
+type Metrics struct {
+	Families  []*FamilyMetrics
+	Neighbors []*NeighborMetrics
+}
+
+// Metrics returns a snapshot of the statistics. The snapshot is taken
+// in the server goroutine from counters kept up to date by the tables,
+// so taking it doesn't walk the ribs.
+func (server *BgpServer) Metrics() *Metrics {
+	req := NewGrpcRequest(REQ_METRICS, "", bgp.RouteFamily(0), nil)
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	return res.Data.(*Metrics)
+}
+
+func (server *BgpServer) metrics() *Metrics {
+	m := &Metrics{
+		Families:  make([]*FamilyMetrics, 0, len(server.globalRib.Tables)),
+		Neighbors: make([]*NeighborMetrics, 0, len(server.neighborMap)),
+	}
+	for rf, t := range server.globalRib.Tables {
+		max, avg := t.ParentDepths()
+		m.Families = append(m.Families, &FamilyMetrics{
+			Family:             rf,
+			Prefixes:           t.BestCount(),
+			BestPathChanges:    t.BestPathChanges(),
+			MaxParentDepth:     max,
+			AverageParentDepth: avg,
+		})
+	}
+	sort.Sort(familyMetrics(m.Families))
+	for _, peer := range server.neighborMap {
+		n := &NeighborMetrics{
+			Address:   peer.ID(),
+			Messages:  peer.fsm.pConf.State.Messages,
+			AdjRibIn:  make(map[bgp.RouteFamily]int),
+			AdjRibOut: make(map[bgp.RouteFamily]int),
+		}
+		for _, rf := range peer.configuredRFlist() {
+			n.AdjRibIn[rf] = peer.adjRibIn.PrefixCount(rf)
+			n.AdjRibOut[rf] = peer.adjRibOut.PrefixCount(rf)
+		}
+		m.Neighbors = append(m.Neighbors, n)
+	}
+	sort.Sort(neighborMetrics(m.Neighbors))
+	return m
+}
+
+type familyMetrics []*FamilyMetrics
+
+func (l familyMetrics) Len() int           { return len(l) }
+func (l familyMetrics) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l familyMetrics) Less(i, j int) bool { return l[i].Family < l[j].Family }
+
+type neighborMetrics []*NeighborMetrics
+
+func (l neighborMetrics) Len() int           { return len(l) }
+func (l neighborMetrics) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l neighborMetrics) Less(i, j int) bool { return l[i].Address < l[j].Address }
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+func (w *metricsWriter) header(name, typ, help string) {
+	fmt.Fprintf(w.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample with the labels given as name and value pairs.
+func (w *metricsWriter) sample(name string, value interface{}, labels ...string) {
+	w.w.WriteString(name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		sep := ","
+		if i == 0 {
+			sep = "{"
+		}
+		fmt.Fprintf(w.w, `%s%s="%s"`, sep, labels[i], metricLabelEscaper.Replace(labels[i+1]))
+	}
+	if len(labels) > 0 {
+		w.w.WriteString("}")
+	}
+	fmt.Fprintf(w.w, " %v\n", value)
+}
+
+func sortedFamilies(m map[bgp.RouteFamily]int) []bgp.RouteFamily {
+	l := make([]bgp.RouteFamily, 0, len(m))
+	for rf := range m {
+		l = append(l, rf)
+	}
+	sort.Sort(routeFamilies(l))
+	return l
+}
+
+type routeFamilies []bgp.RouteFamily
+
+func (l routeFamilies) Len() int           { return len(l) }
+func (l routeFamilies) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l routeFamilies) Less(i, j int) bool { return l[i] < l[j] }
+
+// WritePrometheus writes the metrics in the Prometheus text format.
+func (m *Metrics) WritePrometheus(out io.Writer) error {
+	w := &metricsWriter{w: bufio.NewWriter(out)}
+
+	w.header("gobgp_rib_prefixes", "gauge", "Number of prefixes having a best path in the global RIB.")
+	for _, f := range m.Families {
+		w.sample("gobgp_rib_prefixes", f.Prefixes, "family", bgp.AddressFamilyNameMap[f.Family])
+	}
+	w.header("gobgp_rib_best_path_changes_total", "counter", "Number of best path changes in the global RIB by the reason the new best path was selected.")
+	for _, f := range m.Families {
+		reasons := make([]string, 0, len(f.BestPathChanges))
+		for reason := range f.BestPathChanges {
+			reasons = append(reasons, string(reason))
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			w.sample("gobgp_rib_best_path_changes_total", f.BestPathChanges[table.BestPathReason(reason)], "family", bgp.AddressFamilyNameMap[f.Family], "reason", reason)
+		}
+	}
+	w.header("gobgp_rib_path_parent_depth_max", "gauge", "Maximum number of the parents the paths in the RIB are cloned from.")
+	for _, f := range m.Families {
+		w.sample("gobgp_rib_path_parent_depth_max", f.MaxParentDepth, "family", bgp.AddressFamilyNameMap[f.Family])
+	}
+	w.header("gobgp_rib_path_parent_depth_average", "gauge", "Average number of the parents the paths in the RIB are cloned from.")
+	for _, f := range m.Families {
+		w.sample("gobgp_rib_path_parent_depth_average", f.AverageParentDepth, "family", bgp.AddressFamilyNameMap[f.Family])
+	}
+
+	w.header("gobgp_neighbor_adj_rib_in_prefixes", "gauge", "Number of prefixes in the Adj-RIB-In of the neighbor.")
+	for _, n := range m.Neighbors {
+		for _, rf := range sortedFamilies(n.AdjRibIn) {
+			w.sample("gobgp_neighbor_adj_rib_in_prefixes", n.AdjRibIn[rf], "neighbor", n.Address, "family", bgp.AddressFamilyNameMap[rf])
+		}
+	}
+	w.header("gobgp_neighbor_adj_rib_out_prefixes", "gauge", "Number of prefixes in the Adj-RIB-Out of the neighbor.")
+	for _, n := range m.Neighbors {
+		for _, rf := range sortedFamilies(n.AdjRibOut) {
+			w.sample("gobgp_neighbor_adj_rib_out_prefixes", n.AdjRibOut[rf], "neighbor", n.Address, "family", bgp.AddressFamilyNameMap[rf])
+		}
+	}
+
+	w.header("gobgp_neighbor_messages_received_total", "counter", "Number of messages received from the neighbor by the type.")
+	for _, n := range m.Neighbors {
+		r := n.Messages.Received
+		for _, c := range []struct {
+			typ   string
+			value uint64
+		}{{"open", r.Open}, {"update", r.Update}, {"notification", r.Notification}, {"keepalive", r.Keepalive}, {"refresh", r.Refresh}} {
+			w.sample("gobgp_neighbor_messages_received_total", c.value, "neighbor", n.Address, "type", c.typ)
+		}
+	}
+	w.header("gobgp_neighbor_messages_sent_total", "counter", "Number of messages sent to the neighbor by the type.")
+	for _, n := range m.Neighbors {
+		s := n.Messages.Sent
+		for _, c := range []struct {
+			typ   string
+			value uint64
+		}{{"open", s.Open}, {"update", s.Update}, {"notification", s.Notification}, {"keepalive", s.Keepalive}, {"refresh", s.Refresh}} {
+			w.sample("gobgp_neighbor_messages_sent_total", c.value, "neighbor", n.Address, "type", c.typ)
+		}
+	}
+	return w.w.Flush()
+}
+
+// MetricsHandler returns the HTTP handler serving the metrics to
+// Prometheus. The metrics are formatted out of the server goroutine.
+func (server *BgpServer) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		server.Metrics().WritePrometheus(w)
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server.globalRib = table.NewTableManager(rfList, 0, 0)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{
+		Config:   config.NeighborConfig{NeighborAddress: "10.0.0.1", PeerAs: 65001},
+		AfiSafis: []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}},
+	}
+	p := NewPeer(g, n, server.globalRib, server.policy)
+	p.adjRibIn = table.NewAdjRib(p.ID(), rfList)
+	p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+	p.fsm.pConf.State.Messages.Received.Update = 2
+	server.neighborMap[p.ID()] = p
+
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string) *table.Path {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		return table.NewPath(source, bgp.NewIPAddrPrefix(24, prefix), false, pathAttributes, time.Now(), false)
+	}
+	pathList := []*table.Path{path("10.10.10.0"), path("10.10.20.0")}
+	p.adjRibIn.Update(pathList)
+	server.globalRib.ProcessPaths(pathList)
+
+	m := server.metrics()
+	assert.Equal(1, len(m.Families))
+	assert.Equal(2, m.Families[0].Prefixes)
+	assert.Equal(uint64(2), m.Families[0].BestPathChanges[table.BPR_ONLY_PATH])
+	assert.Equal(1, len(m.Neighbors))
+	assert.Equal(2, m.Neighbors[0].AdjRibIn[bgp.RF_IPv4_UC])
+	assert.Equal(0, m.Neighbors[0].AdjRibOut[bgp.RF_IPv4_UC])
+
+	var b bytes.Buffer
+	assert.Nil(m.WritePrometheus(&b))
+	lines := strings.Split(b.String(), "\n")
+	for _, l := range []string{
+		"# TYPE gobgp_rib_prefixes gauge",
+		`gobgp_rib_prefixes{family="ipv4-unicast"} 2`,
+		`gobgp_rib_best_path_changes_total{family="ipv4-unicast",reason="Only Path"} 2`,
+		`gobgp_rib_path_parent_depth_max{family="ipv4-unicast"} 0`,
+		`gobgp_neighbor_adj_rib_in_prefixes{neighbor="10.0.0.1",family="ipv4-unicast"} 2`,
+		`gobgp_neighbor_adj_rib_out_prefixes{neighbor="10.0.0.1",family="ipv4-unicast"} 0`,
+		`gobgp_neighbor_messages_received_total{neighbor="10.0.0.1",type="update"} 2`,
+	} {
+		assert.Contains(lines, l)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/packet"
+	"net"
+	"time"
+)
+
+// nexthopHoldDown keeps a nexthop which became reachable again
+// unresolved until the timer expires so that a flapping nexthop
+// doesn't cause micro-loops during reconvergence.
+type nexthopHoldDown struct {
+	nexthop net.IP
+	metric  uint32
+	since   time.Time
+	until   time.Time
+	timer   *time.Timer
+}
+
+// NexthopHoldDownState describes a nexthop being held down.
+type NexthopHoldDownState struct {
+	Nexthop net.IP
+	Since   time.Time
+	Until   time.Time
+}
+
+// NexthopHoldDowns returns the nexthops currently held down.
+func (server *BgpServer) NexthopHoldDowns() []NexthopHoldDownState {
+	req := NewGrpcRequest(REQ_NEXTHOP_HOLD_DOWN, "", bgp.RouteFamily(0), nil)
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	return res.Data.([]NexthopHoldDownState)
+}
+
+func (server *BgpServer) nexthopHoldDownStates() []NexthopHoldDownState {
+	l := make([]NexthopHoldDownState, 0, len(server.nexthopHolds))
+	for _, h := range server.nexthopHolds {
+		l = append(l, NexthopHoldDownState{
+			Nexthop: h.nexthop,
+			Since:   h.since,
+			Until:   h.until,
+		})
+	}
+	return l
+}
+
+// handleNexthopLookup applies the hold-down to the reachability of the
+// nexthop and the IGP metric to it reported by zebra.
+func (server *BgpServer) handleNexthopLookup(nexthop net.IP, reachable bool, metric uint32) []*SenderMsg {
+	key := nexthop.String()
+	if h, ok := server.nexthopHolds[key]; ok {
+		if reachable {
+			h.metric = metric
+			return nil
+		}
+		h.timer.Stop()
+		delete(server.nexthopHolds, key)
+		log.WithFields(log.Fields{
+			"Topic":   "Zebra",
+			"Nexthop": key,
+		}).Info("nexthop became unreachable during hold-down")
+		return nil
+	}
+
+	d := time.Duration(server.bgpConfig.Global.Zebra.NexthopHoldDownTime) * time.Millisecond
+	if reachable && d > 0 && server.globalRib.IsNexthopUnreachable(nexthop) {
+		now := time.Now()
+		h := &nexthopHoldDown{
+			nexthop: nexthop,
+			metric:  metric,
+			since:   now,
+			until:   now.Add(d),
+		}
+		ch := server.nexthopHoldCh
+		h.timer = time.AfterFunc(d, func() {
+			ch <- h
+		})
+		server.nexthopHolds[key] = h
+		log.WithFields(log.Fields{
+			"Topic":    "Zebra",
+			"Nexthop":  key,
+			"Duration": d,
+		}).Info("nexthop became reachable, hold down")
+		return nil
+	}
+	return server.handleNexthopReachability(nexthop, reachable, metric)
+}
+
+// releaseNexthopHoldDown resolves the nexthop again when its hold-down
+// expired.
+func (server *BgpServer) releaseNexthopHoldDown(h *nexthopHoldDown) []*SenderMsg {
+	key := h.nexthop.String()
+	// the hold-down could have been canceled after the timer fired
+	if server.nexthopHolds[key] != h {
+		return nil
+	}
+	delete(server.nexthopHolds, key)
+	log.WithFields(log.Fields{
+		"Topic":   "Zebra",
+		"Nexthop": key,
+	}).Info("nexthop hold-down expired")
+	return server.handleNexthopReachability(h.nexthop, true, h.metric)
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNexthopHoldDown(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	server.globalRib = table.NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	server.bgpConfig.Global.Zebra.NexthopHoldDownTime = 10
+
+	nexthop := net.ParseIP("10.0.0.1")
+	source := &table.PeerInfo{AS: 65001, Address: nexthop}
+	path := table.NewPath(source, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}, time.Now(), false)
+	server.globalRib.ProcessPaths([]*table.Path{path})
+	best := func() *table.Path {
+		return server.globalRib.Tables[bgp.RF_IPv4_UC].GetDestination("10.10.10.0/24").GetBestPath(table.GLOBAL_RIB_NAME)
+	}
+	assert.NotNil(best())
+
+	// no hold-down when the nexthop wasn't unreachable
+	server.handleNexthopLookup(nexthop, true, 0)
+	assert.Equal(0, len(server.nexthopHoldDownStates()))
+
+	server.handleNexthopLookup(nexthop, false, 0)
+	assert.True(server.globalRib.IsNexthopUnreachable(nexthop))
+	assert.Nil(best())
+
+	server.handleNexthopLookup(nexthop, true, 0)
+	assert.True(server.globalRib.IsNexthopUnreachable(nexthop))
+	assert.Nil(best())
+	states := server.nexthopHoldDownStates()
+	assert.Equal(1, len(states))
+	assert.Equal("10.0.0.1", states[0].Nexthop.String())
+	assert.Equal(10*time.Millisecond, states[0].Until.Sub(states[0].Since))
+
+	// down again during the hold-down cancels it
+	server.handleNexthopLookup(nexthop, false, 0)
+	assert.Equal(0, len(server.nexthopHoldDownStates()))
+	server.handleNexthopLookup(nexthop, true, 0)
+	assert.Equal(1, len(server.nexthopHoldDownStates()))
+	// the latest metric is applied when the hold-down expires
+	server.handleNexthopLookup(nexthop, true, 10)
+	h := <-server.nexthopHoldCh
+	assert.Equal("10.0.0.1", h.nexthop.String())
+	// a stale expiry is ignored
+	server.releaseNexthopHoldDown(&nexthopHoldDown{nexthop: nexthop})
+	assert.True(server.globalRib.IsNexthopUnreachable(nexthop))
+
+	server.releaseNexthopHoldDown(h)
+	assert.False(server.globalRib.IsNexthopUnreachable(nexthop))
+	assert.Equal(0, len(server.nexthopHoldDownStates()))
+	assert.Equal(uint32(10), best().GetIgpMetric())
+	assert.NotNil(best())
+}
@@ -0,0 +1,296 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"net"
+	"sort"
+)
+
+func orfModeFromConfig(m config.OrfModeType) bgp.BGPORFMode {
+	switch m {
+	case config.ORF_MODE_TYPE_SEND:
+		return bgp.ORF_SEND
+	case config.ORF_MODE_TYPE_RECEIVE:
+		return bgp.ORF_RECEIVE
+	case config.ORF_MODE_TYPE_BOTH:
+		return bgp.ORF_BOTH
+	}
+	return 0
+}
+
+// prefixOrfCapability returns the ORF capability advertising the
+// Address Prefix ORF for the unicast families configured for the
+// peer, or nil.
+func prefixOrfCapability(pConf *config.Neighbor) *bgp.CapOutboundRouteFiltering {
+	mode := orfModeFromConfig(pConf.Config.PrefixOrf)
+	if mode == 0 {
+		return nil
+	}
+	values := make([]bgp.CapOutboundRouteFilteringValue, 0, len(pConf.AfiSafis))
+	for _, rf := range pConf.AfiSafis {
+		family, _ := bgp.GetRouteFamily(string(rf.AfiSafiName))
+		if family != bgp.RF_IPv4_UC && family != bgp.RF_IPv6_UC {
+			continue
+		}
+		values = append(values, bgp.CapOutboundRouteFilteringValue{
+			RouteFamily: family,
+			Tuples:      []bgp.CapOutboundRouteFilteringTuple{{Type: bgp.ORF_TYPE_ADDRESS_PREFIX, Mode: mode}},
+		})
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return bgp.NewCapOutboundRouteFiltering(values)
+}
+
+// prefixOrfNegotiated tells whether we may send Address Prefix ORF
+// entries to the peer and accept them from the peer for the family.
+func (fsm *FSM) prefixOrfNegotiated(rf bgp.RouteFamily) (send, receive bool) {
+	if !fsm.HasFamily(rf) {
+		return false, false
+	}
+	local := orfModeFromConfig(fsm.pConf.Config.PrefixOrf)
+	for _, c := range fsm.Capability(bgp.BGP_CAP_OUTBOUND_ROUTE_FILTERING) {
+		remote := c.(*bgp.CapOutboundRouteFiltering).Mode(rf, bgp.ORF_TYPE_ADDRESS_PREFIX)
+		send = send || (local&bgp.ORF_SEND != 0 && remote&bgp.ORF_RECEIVE != 0)
+		receive = receive || (local&bgp.ORF_RECEIVE != 0 && remote&bgp.ORF_SEND != 0)
+	}
+	return send, receive
+}
+
+// prefixOrf is the Address Prefix ORF (RFC5292) received from a peer
+// for a family. The entries are kept sorted by their sequence.
+type prefixOrf struct {
+	entries []*bgp.ORFAddressPrefixEntry
+}
+
+func (o *prefixOrf) update(entries []*bgp.ORFAddressPrefixEntry) {
+	for _, e := range entries {
+		switch e.Action {
+		case bgp.ORF_ACTION_REMOVE_ALL:
+			o.entries = nil
+		case bgp.ORF_ACTION_ADD, bgp.ORF_ACTION_REMOVE:
+			i := sort.Search(len(o.entries), func(i int) bool {
+				return o.entries[i].Sequence >= e.Sequence
+			})
+			found := i < len(o.entries) && o.entries[i].Sequence == e.Sequence
+			switch {
+			case e.Action == bgp.ORF_ACTION_REMOVE && found:
+				o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			case e.Action == bgp.ORF_ACTION_ADD && found:
+				o.entries[i] = e
+			case e.Action == bgp.ORF_ACTION_ADD:
+				o.entries = append(o.entries, nil)
+				copy(o.entries[i+1:], o.entries[i:])
+				o.entries[i] = e
+			}
+		}
+	}
+}
+
+func prefixOrfEntryMatch(e *bgp.ORFAddressPrefixEntry, prefix net.IP, length uint8) bool {
+	bits := uint8(len(e.Prefix) * 8)
+	if len(prefix) != len(e.Prefix) || length < e.Length {
+		return false
+	}
+	min, max := e.MinLen, e.MaxLen
+	if min == 0 {
+		min = e.Length
+	}
+	if max == 0 {
+		max = bits
+		if e.MinLen == 0 {
+			max = e.Length
+		}
+	}
+	if length < min || length > max {
+		return false
+	}
+	mask := net.CIDRMask(int(e.Length), int(bits))
+	return prefix.Mask(mask).Equal(e.Prefix.Mask(mask))
+}
+
+// match tells whether the peer accepts the path. A path matching no
+// entry is denied unless there is no entry at all.
+func (o *prefixOrf) match(path *table.Path) bool {
+	if len(o.entries) == 0 {
+		return true
+	}
+	var prefix net.IP
+	var length uint8
+	switch n := path.GetNlri().(type) {
+	case *bgp.IPAddrPrefix:
+		prefix, length = n.Prefix.To4(), n.Length
+	case *bgp.IPv6AddrPrefix:
+		prefix, length = n.Prefix.To16(), n.Length
+	default:
+		return true
+	}
+	for _, e := range o.entries {
+		if prefixOrfEntryMatch(e, prefix, length) {
+			return e.Match == bgp.ORF_MATCH_PERMIT
+		}
+	}
+	return false
+}
+
+// prefixOrfAccept tells whether the Address Prefix ORF received from
+// the peer lets the path be advertised to it.
+func (peer *Peer) prefixOrfAccept(path *table.Path) bool {
+	o, ok := peer.prefixOrf[path.GetRouteFamily()]
+	if !ok {
+		return true
+	}
+	return o.match(path)
+}
+
+// handleRouteRefreshORF installs the ORF entries carried in the
+// ROUTE_REFRESH message and returns the UPDATE messages to re-advertise
+// the family under the new filter unless the refresh is deferred.
+func (peer *Peer) handleRouteRefreshORF(rf bgp.RouteFamily, rr *bgp.BGPRouteRefresh) []*bgp.BGPMessage {
+	if _, receive := peer.fsm.prefixOrfNegotiated(rf); !receive {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   peer.conf.Config.NeighborAddress,
+			"Data":  rf,
+		}).Warn("ORF received but the capability wasn't negotiated, ignore")
+		return nil
+	}
+	for _, orf := range rr.ORFs {
+		if orf.Type != bgp.ORF_TYPE_ADDRESS_PREFIX {
+			log.WithFields(log.Fields{
+				"Topic": "Peer",
+				"Key":   peer.conf.Config.NeighborAddress,
+				"Type":  orf.Type,
+			}).Warn("unsupported ORF type, ignore")
+			continue
+		}
+		if peer.prefixOrf == nil {
+			peer.prefixOrf = make(map[bgp.RouteFamily]*prefixOrf)
+		}
+		o, ok := peer.prefixOrf[rf]
+		if !ok {
+			o = &prefixOrf{}
+			peer.prefixOrf[rf] = o
+		}
+		o.update(orf.Entries)
+		log.WithFields(log.Fields{
+			"Topic":   "Peer",
+			"Key":     peer.conf.Config.NeighborAddress,
+			"Family":  rf,
+			"Entries": len(o.entries),
+			"When":    rr.When,
+		}).Info("prefix ORF updated")
+	}
+	if rr.When == bgp.ORF_WHEN_DEFER {
+		return nil
+	}
+	// the held advertisements passed the previous filter
+	peer.updateBatch.discardFamilies([]bgp.RouteFamily{rf})
+	pathList := peer.getOutboundDelta([]bgp.RouteFamily{rf})
+	if len(pathList) == 0 {
+		return nil
+	}
+	return peer.advertise(pathList)
+}
+
+// prefixOrfEntries converts the prefixes of the family in the set to
+// Address Prefix ORF entries permitting them.
+func prefixOrfEntries(set *table.PrefixSet, rf bgp.RouteFamily) []*bgp.ORFAddressPrefixEntry {
+	entries := make([]*bgp.ORFAddressPrefixEntry, 0)
+	for _, p := range set.List() {
+		if p.AddressFamily != rf {
+			continue
+		}
+		l, _ := p.Prefix.Mask.Size()
+		length := uint8(l)
+		e := &bgp.ORFAddressPrefixEntry{
+			Action:   bgp.ORF_ACTION_ADD,
+			Match:    bgp.ORF_MATCH_PERMIT,
+			Sequence: uint32(len(entries)+1) * 5,
+			Length:   length,
+			Prefix:   p.Prefix.IP,
+		}
+		if p.MasklengthRangeMin > length {
+			e.MinLen = p.MasklengthRangeMin
+		}
+		if p.MasklengthRangeMax > length {
+			e.MaxLen = p.MasklengthRangeMax
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+type prefixOrfRequest struct {
+	when    bgp.BGPORFWhen
+	entries []*bgp.ORFAddressPrefixEntry
+}
+
+// SendPrefixOrf sends the Address Prefix ORF entries for the family to
+// the neighbor in a ROUTE_REFRESH message. The neighbor has to be
+// established with sending Address Prefix ORF negotiated.
+func (server *BgpServer) SendPrefixOrf(addr string, rf bgp.RouteFamily, when bgp.BGPORFWhen, entries []*bgp.ORFAddressPrefixEntry) error {
+	req := NewGrpcRequest(REQ_NEIGHBOR_PREFIX_ORF, addr, rf, &prefixOrfRequest{
+		when:    when,
+		entries: entries,
+	})
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	return res.ResponseErr
+}
+
+// prefixOrfMessage validates the entries and builds the ROUTE_REFRESH
+// message carrying them.
+func (peer *Peer) prefixOrfMessage(rf bgp.RouteFamily, when bgp.BGPORFWhen, entries []*bgp.ORFAddressPrefixEntry) (*bgp.BGPMessage, error) {
+	if peer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+		return nil, fmt.Errorf("neighbor %s isn't established", peer.conf.Config.NeighborAddress)
+	}
+	if send, _ := peer.fsm.prefixOrfNegotiated(rf); !send {
+		return nil, fmt.Errorf("sending prefix ORF for %s isn't negotiated with %s", rf, peer.conf.Config.NeighborAddress)
+	}
+	if when != bgp.ORF_WHEN_IMMEDIATE && when != bgp.ORF_WHEN_DEFER {
+		return nil, fmt.Errorf("invalid ORF when-to-refresh: %d", when)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no ORF entry")
+	}
+	size := net.IPv4len
+	if rf == bgp.RF_IPv6_UC {
+		size = net.IPv6len
+	}
+	for _, e := range entries {
+		if e.Action == bgp.ORF_ACTION_REMOVE_ALL {
+			continue
+		}
+		if len(e.Prefix) != size {
+			return nil, fmt.Errorf("ORF entry %d doesn't match the family %s", e.Sequence, rf)
+		}
+		bits := uint8(size * 8)
+		if e.Length > bits || e.MinLen > bits || e.MaxLen > bits ||
+			(e.MinLen != 0 && e.MinLen < e.Length) || (e.MaxLen != 0 && e.MaxLen < e.Length) ||
+			(e.MinLen != 0 && e.MaxLen != 0 && e.MinLen > e.MaxLen) {
+			return nil, fmt.Errorf("invalid prefix length of ORF entry %d", e.Sequence)
+		}
+	}
+	return bgp.NewBGPRouteRefreshORFMessage(rf, when, entries), nil
+}
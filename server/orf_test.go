@@ -0,0 +1,225 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPrefixOrfMatch(t *testing.T) {
+	assert := assert.New(t)
+	path := func(prefix string, length uint8) *table.Path {
+		return table.NewPath(&table.PeerInfo{}, bgp.NewIPAddrPrefix(length, prefix), true, nil, time.Now(), false)
+	}
+	entry := func(action bgp.BGPORFAction, match bgp.BGPORFMatch, seq uint32, prefix string, length, min, max uint8) *bgp.ORFAddressPrefixEntry {
+		return &bgp.ORFAddressPrefixEntry{
+			Action:   action,
+			Match:    match,
+			Sequence: seq,
+			MinLen:   min,
+			MaxLen:   max,
+			Length:   length,
+			Prefix:   net.ParseIP(prefix).To4(),
+		}
+	}
+
+	o := &prefixOrf{}
+	assert.True(o.match(path("10.0.0.0", 8)))
+
+	o.update([]*bgp.ORFAddressPrefixEntry{
+		entry(bgp.ORF_ACTION_ADD, bgp.ORF_MATCH_PERMIT, 20, "10.0.0.0", 8, 0, 24),
+		entry(bgp.ORF_ACTION_ADD, bgp.ORF_MATCH_DENY, 10, "10.1.0.0", 16, 0, 0),
+		entry(bgp.ORF_ACTION_ADD, bgp.ORF_MATCH_PERMIT, 30, "172.16.0.0", 12, 0, 0),
+	})
+	assert.Equal(3, len(o.entries))
+	assert.Equal(uint32(10), o.entries[0].Sequence)
+	// exact match of the deny entry comes first
+	assert.False(o.match(path("10.1.0.0", 16)))
+	assert.True(o.match(path("10.1.1.0", 24)))
+	assert.True(o.match(path("10.2.0.0", 16)))
+	// longer than maxlen
+	assert.False(o.match(path("10.2.0.0", 25)))
+	// exact length only
+	assert.True(o.match(path("172.16.0.0", 12)))
+	assert.False(o.match(path("172.16.0.0", 16)))
+	// no entry matches
+	assert.False(o.match(path("192.168.0.0", 16)))
+
+	o.update([]*bgp.ORFAddressPrefixEntry{entry(bgp.ORF_ACTION_REMOVE, bgp.ORF_MATCH_DENY, 10, "10.1.0.0", 16, 0, 0)})
+	assert.Equal(2, len(o.entries))
+	assert.True(o.match(path("10.1.0.0", 16)))
+
+	o.update([]*bgp.ORFAddressPrefixEntry{{Action: bgp.ORF_ACTION_REMOVE_ALL}})
+	assert.Equal(0, len(o.entries))
+	assert.True(o.match(path("192.168.0.0", 16)))
+}
+
+func TestHandleRouteRefreshORF(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{Config: config.NeighborConfig{
+		NeighborAddress: "10.0.0.2",
+		PeerAs:          65002,
+		PrefixOrf:       config.ORF_MODE_TYPE_RECEIVE,
+	}}
+	rib := table.NewTableManager(rfList, 0, 0)
+	policy := table.NewRoutingPolicy()
+	policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	p := NewPeer(g, n, rib, policy)
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string) *table.Path {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		return table.NewPath(source, bgp.NewIPAddrPrefix(24, prefix), false, pathAttributes, time.Now(), false)
+	}
+	rib.ProcessPaths([]*table.Path{path("10.10.10.0"), path("10.20.10.0")})
+	pathList, _ := p.getBestFromLocal(rfList)
+	p.adjRibOut.Update(pathList)
+	assert.Equal(2, p.adjRibOut.Count(rfList))
+
+	entries := []*bgp.ORFAddressPrefixEntry{{
+		Action:   bgp.ORF_ACTION_ADD,
+		Sequence: 5,
+		MaxLen:   24,
+		Length:   16,
+		Prefix:   net.ParseIP("10.10.0.0").To4(),
+	}}
+	rr := bgp.NewBGPRouteRefreshORFMessage(bgp.RF_IPv4_UC, bgp.ORF_WHEN_IMMEDIATE, entries).Body.(*bgp.BGPRouteRefresh)
+
+	// the peer didn't advertise that it sends ORF
+	assert.Nil(p.handleRouteRefreshORF(bgp.RF_IPv4_UC, rr))
+	assert.Nil(p.prefixOrf)
+
+	p.fsm.capMap[bgp.BGP_CAP_OUTBOUND_ROUTE_FILTERING] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapOutboundRouteFiltering([]bgp.CapOutboundRouteFilteringValue{{
+			RouteFamily: bgp.RF_IPv4_UC,
+			Tuples:      []bgp.CapOutboundRouteFilteringTuple{{Type: bgp.ORF_TYPE_ADDRESS_PREFIX, Mode: bgp.ORF_SEND}},
+		}}),
+	}
+	send, receive := p.fsm.prefixOrfNegotiated(bgp.RF_IPv4_UC)
+	assert.False(send)
+	assert.True(receive)
+
+	// deferred, the filter is installed but nothing is sent
+	rr.When = bgp.ORF_WHEN_DEFER
+	assert.Nil(p.handleRouteRefreshORF(bgp.RF_IPv4_UC, rr))
+	assert.Equal(1, len(p.prefixOrf[bgp.RF_IPv4_UC].entries))
+	assert.Equal(2, p.adjRibOut.Count(rfList))
+
+	// the route out of the filter is withdrawn
+	rr.When = bgp.ORF_WHEN_IMMEDIATE
+	msgs := p.handleRouteRefreshORF(bgp.RF_IPv4_UC, rr)
+	assert.Equal(1, len(msgs))
+	u := msgs[0].Body.(*bgp.BGPUpdate)
+	assert.Equal(1, len(u.WithdrawnRoutes))
+	assert.Equal("10.20.10.0/24", u.WithdrawnRoutes[0].String())
+	assert.Equal(1, p.adjRibOut.Count(rfList))
+
+	assert.Nil(filterpath(p, path("10.20.10.0")))
+	assert.NotNil(filterpath(p, path("10.10.10.0")))
+}
+
+func TestPrefixOrfEntries(t *testing.T) {
+	assert := assert.New(t)
+	set, err := table.NewPrefixSet(config.PrefixSet{
+		PrefixSetName: "ps0",
+		PrefixList: []config.Prefix{
+			{IpPrefix: "10.0.0.0/8", MasklengthRange: "16..24"},
+			{IpPrefix: "192.168.0.0/16"},
+			{IpPrefix: "2001:db8::/32"},
+		},
+	})
+	assert.Nil(err)
+	entries := prefixOrfEntries(set, bgp.RF_IPv4_UC)
+	assert.Equal(2, len(entries))
+	assert.Equal("10.0.0.0", entries[0].Prefix.String())
+	assert.Equal(uint8(8), entries[0].Length)
+	assert.Equal(uint8(16), entries[0].MinLen)
+	assert.Equal(uint8(24), entries[0].MaxLen)
+	assert.Equal("192.168.0.0", entries[1].Prefix.String())
+	assert.Equal(uint8(0), entries[1].MinLen)
+	assert.Equal(uint8(0), entries[1].MaxLen)
+	assert.Equal(uint32(10), entries[1].Sequence)
+	assert.Equal(1, len(prefixOrfEntries(set, bgp.RF_IPv6_UC)))
+}
+
+func TestPrefixOrfMessage(t *testing.T) {
+	assert := assert.New(t)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{Config: config.NeighborConfig{
+		NeighborAddress: "10.0.0.2",
+		PeerAs:          65002,
+		PrefixOrf:       config.ORF_MODE_TYPE_SEND,
+	}}
+	p := NewPeer(g, n, nil, table.NewRoutingPolicy())
+	entries := []*bgp.ORFAddressPrefixEntry{{
+		Action:   bgp.ORF_ACTION_ADD,
+		Sequence: 5,
+		MaxLen:   24,
+		Length:   16,
+		Prefix:   net.ParseIP("10.10.0.0").To4(),
+	}}
+
+	_, err := p.prefixOrfMessage(bgp.RF_IPv4_UC, bgp.ORF_WHEN_IMMEDIATE, entries)
+	assert.NotNil(err)
+
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	_, err = p.prefixOrfMessage(bgp.RF_IPv4_UC, bgp.ORF_WHEN_IMMEDIATE, entries)
+	assert.NotNil(err)
+
+	p.fsm.capMap[bgp.BGP_CAP_OUTBOUND_ROUTE_FILTERING] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapOutboundRouteFiltering([]bgp.CapOutboundRouteFilteringValue{{
+			RouteFamily: bgp.RF_IPv4_UC,
+			Tuples:      []bgp.CapOutboundRouteFilteringTuple{{Type: bgp.ORF_TYPE_ADDRESS_PREFIX, Mode: bgp.ORF_BOTH}},
+		}}),
+	}
+	m, err := p.prefixOrfMessage(bgp.RF_IPv4_UC, bgp.ORF_WHEN_IMMEDIATE, entries)
+	assert.Nil(err)
+	rr := m.Body.(*bgp.BGPRouteRefresh)
+	assert.Equal(bgp.ORF_WHEN_IMMEDIATE, rr.When)
+	assert.Equal(1, len(rr.ORFs))
+	assert.Equal(entries, rr.ORFs[0].Entries)
+
+	_, err = p.prefixOrfMessage(bgp.RF_IPv4_UC, bgp.ORF_WHEN_IMMEDIATE, nil)
+	assert.NotNil(err)
+	_, err = p.prefixOrfMessage(bgp.RF_IPv4_UC, bgp.BGPORFWhen(3), entries)
+	assert.NotNil(err)
+	// IPv6 prefix for IPv4
+	entries[0].Prefix = net.ParseIP("2001:db8::")
+	_, err = p.prefixOrfMessage(bgp.RF_IPv4_UC, bgp.ORF_WHEN_IMMEDIATE, entries)
+	assert.NotNil(err)
+	entries[0].Prefix = net.ParseIP("10.10.0.0").To4()
+	entries[0].MaxLen = 8
+	_, err = p.prefixOrfMessage(bgp.RF_IPv4_UC, bgp.ORF_WHEN_IMMEDIATE, entries)
+	assert.NotNil(err)
+	entries[0].MaxLen = 24
+	_, err = p.prefixOrfMessage(bgp.RF_IPv4_UC, bgp.ORF_WHEN_DEFER, append(entries, &bgp.ORFAddressPrefixEntry{Action: bgp.ORF_ACTION_REMOVE_ALL}))
+	assert.Nil(err)
+}
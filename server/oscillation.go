@@ -0,0 +1,167 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"time"
+)
+
+type oscillationCause int
+
+const (
+	_ oscillationCause = iota
+	OSCILLATION_OPEN_REJECTED
+	OSCILLATION_OPEN_REJECTING
+	OSCILLATION_HOLD_TIMER_EXPIRED
+	OSCILLATION_CONNECTION_RESET
+	OSCILLATION_NOTIFICATION_LOOP
+)
+
+func (c oscillationCause) String() string {
+	switch c {
+	case OSCILLATION_OPEN_REJECTED:
+		return "open-rejected"
+	case OSCILLATION_OPEN_REJECTING:
+		return "open-rejecting"
+	case OSCILLATION_HOLD_TIMER_EXPIRED:
+		return "hold-timer-expired"
+	case OSCILLATION_CONNECTION_RESET:
+		return "connection-reset"
+	case OSCILLATION_NOTIFICATION_LOOP:
+		return "notification-loop"
+	}
+	return "unknown"
+}
+
+// classifyOscillation tells how a session failed from the state the
+// peer was in, the reason of the state change and the notification
+// sent or received. Zero is returned for state changes that aren't
+// failures.
+func classifyOscillation(oldState bgp.FSMState, reason FsmStateReason, n *bgp.BGPNotification) oscillationCause {
+	switch reason {
+	case FSM_NOTIFICATION_RECV:
+		if oldState == bgp.BGP_FSM_ESTABLISHED {
+			return OSCILLATION_NOTIFICATION_LOOP
+		}
+		return OSCILLATION_OPEN_REJECTED
+	case FSM_NOTIFICATION_SENT:
+		if oldState == bgp.BGP_FSM_ESTABLISHED {
+			return OSCILLATION_NOTIFICATION_LOOP
+		}
+	case FSM_INVALID_MSG:
+		if oldState == bgp.BGP_FSM_ESTABLISHED {
+			return OSCILLATION_NOTIFICATION_LOOP
+		}
+		if n != nil && n.ErrorCode == bgp.BGP_ERROR_OPEN_MESSAGE_ERROR {
+			return OSCILLATION_OPEN_REJECTING
+		}
+	case FSM_HOLD_TIMER_EXPIRED:
+		return OSCILLATION_HOLD_TIMER_EXPIRED
+	case FSM_READ_FAILED, FSM_WRITE_FAILED:
+		return OSCILLATION_CONNECTION_RESET
+	}
+	return 0
+}
+
+type oscillationEvent struct {
+	cause        oscillationCause
+	notification *bgp.BGPNotification
+	timestamp    time.Time
+}
+
+// oscillationDetector watches the session failures of a peer and
+// reports the likely cause when the peer keeps failing in the same way.
+type oscillationDetector struct {
+	events     []oscillationEvent
+	lastReport time.Time
+}
+
+// record adds a session failure and returns a description of the
+// likely cause when it should be reported, or an empty string.
+func (d *oscillationDetector) record(g *config.Global, peer *config.Neighbor, ev oscillationEvent) string {
+	if ev.cause == 0 {
+		return ""
+	}
+	c := g.OscillationDetector
+	threshold := int(c.Threshold)
+	if threshold == 0 {
+		threshold = config.DEFAULT_OSCILLATION_THRESHOLD
+	}
+	interval := time.Duration(c.Interval) * time.Second
+	if interval == 0 {
+		interval = config.DEFAULT_OSCILLATION_INTERVAL * time.Second
+	}
+	reportInterval := time.Duration(c.ReportInterval) * time.Second
+	if reportInterval == 0 {
+		reportInterval = config.DEFAULT_OSCILLATION_REPORT * time.Second
+	}
+
+	if len(d.events) > 0 && d.events[len(d.events)-1].cause != ev.cause {
+		d.events = d.events[:0]
+	}
+	d.events = append(d.events, ev)
+	for len(d.events) > 0 && ev.timestamp.Sub(d.events[0].timestamp) > interval {
+		d.events = d.events[1:]
+	}
+	if len(d.events) < threshold {
+		return ""
+	}
+	if !d.lastReport.IsZero() && ev.timestamp.Sub(d.lastReport) < reportInterval {
+		return ""
+	}
+	d.lastReport = ev.timestamp
+	d.events = d.events[:0]
+	return oscillationAdvice(g, peer, ev)
+}
+
+func oscillationAdvice(g *config.Global, peer *config.Neighbor, ev oscillationEvent) string {
+	n := ev.notification
+	switch ev.cause {
+	case OSCILLATION_OPEN_REJECTED:
+		if n == nil || n.ErrorCode != bgp.BGP_ERROR_OPEN_MESSAGE_ERROR {
+			return "peer keeps closing the session before it gets established; check the configuration of the peer for us"
+		}
+		switch n.ErrorSubcode {
+		case bgp.BGP_ERROR_SUB_BAD_PEER_AS:
+			return fmt.Sprintf("peer is rejecting our AS %d; check the peer-as configured on the peer for us", g.Config.As)
+		case bgp.BGP_ERROR_SUB_BAD_BGP_IDENTIFIER:
+			return "peer is rejecting our router-id; check that the router-ids are unique"
+		case bgp.BGP_ERROR_SUB_UNSUPPORTED_OPTIONAL_PARAMETER:
+			return "peer doesn't support our capabilities; check the afi-safis configured for the peer"
+		case bgp.BGP_ERROR_SUB_UNACCEPTABLE_HOLD_TIME:
+			return "peer is rejecting our hold time; check the hold-time configured for the peer"
+		}
+		return fmt.Sprintf("peer is rejecting our OPEN message (subcode %d)", n.ErrorSubcode)
+	case OSCILLATION_OPEN_REJECTING:
+		if n.ErrorSubcode == bgp.BGP_ERROR_SUB_BAD_PEER_AS {
+			return fmt.Sprintf("we are rejecting the AS of the peer; check that the peer-as %d matches the AS of the peer", peer.Config.PeerAs)
+		}
+		return fmt.Sprintf("we are rejecting the OPEN message of the peer (subcode %d)", n.ErrorSubcode)
+	case OSCILLATION_HOLD_TIMER_EXPIRED:
+		return "hold timer keeps expiring; check the hold-time and keepalive-interval, and the reachability and MTU of the path to the peer"
+	case OSCILLATION_CONNECTION_RESET:
+		return "connection keeps being closed without notification; check that the peer is configured for our address and the passwords match"
+	case OSCILLATION_NOTIFICATION_LOOP:
+		if n != nil {
+			return fmt.Sprintf("established session keeps being torn down by notification (code %d, subcode %d); check the routes and attributes exchanged with the peer", n.ErrorCode, n.ErrorSubcode)
+		}
+		return "established session keeps being torn down by notification; check the routes and attributes exchanged with the peer"
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOscillationDetector(t *testing.T) {
+	assert := assert.New(t)
+	g := &config.Global{
+		Config: config.GlobalConfig{As: 65000},
+		OscillationDetector: config.OscillationDetector{
+			Enabled:        true,
+			Threshold:      3,
+			Interval:       60,
+			ReportInterval: 600,
+		},
+	}
+	n := &config.Neighbor{Config: config.NeighborConfig{PeerAs: 65001}}
+	d := &oscillationDetector{}
+
+	badAs := bgp.NewBGPNotificationMessage(bgp.BGP_ERROR_OPEN_MESSAGE_ERROR, bgp.BGP_ERROR_SUB_BAD_PEER_AS, nil).Body.(*bgp.BGPNotification)
+	now := time.Now()
+	event := func(reason FsmStateReason, notification *bgp.BGPNotification, elapsed time.Duration) oscillationEvent {
+		return oscillationEvent{
+			cause:        classifyOscillation(bgp.BGP_FSM_OPENSENT, reason, notification),
+			notification: notification,
+			timestamp:    now.Add(elapsed),
+		}
+	}
+
+	assert.Equal("", d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, 0)))
+	assert.Equal("", d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, time.Second)))
+	// a different failure restarts counting
+	assert.Equal("", d.record(g, n, event(FSM_HOLD_TIMER_EXPIRED, nil, 2*time.Second)))
+	assert.Equal("", d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, 3*time.Second)))
+	assert.Equal("", d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, 4*time.Second)))
+	// state changes which aren't failures are ignored
+	assert.Equal("", d.record(g, n, event(FSM_IDLE_HOLD_TIMER_EXPIRED, nil, 5*time.Second)))
+	advice := d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, 6*time.Second))
+	assert.Contains(advice, "rejecting our AS 65000")
+
+	// reports are rate-limited
+	for i := 0; i < 3; i++ {
+		assert.Equal("", d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, time.Duration(10+i)*time.Second)))
+	}
+	// failures spread over a longer time than the interval aren't reported
+	for i := 0; i < 3; i++ {
+		assert.Equal("", d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, time.Duration(700+i*100)*time.Second)))
+	}
+	for i := 0; i < 2; i++ {
+		assert.Equal("", d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, time.Duration(1000+i)*time.Second)))
+	}
+	assert.NotEqual("", d.record(g, n, event(FSM_NOTIFICATION_RECV, badAs, 1002*time.Second)))
+}
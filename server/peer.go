@@ -17,18 +17,23 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	api "github.com/osrg/gobgp/api"
 	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
 	"github.com/osrg/gobgp/table"
 	"net"
+	"strings"
 	"time"
 )
 
 const (
 	FLOP_THRESHOLD    = time.Second * 30
 	MIN_CONNECT_RETRY = 10
+	// the number of the best paths advertised at a time after the
+	// session is established
+	INITIAL_DUMP_CHUNK = 1024
 )
 
 type Peer struct {
@@ -41,6 +46,27 @@ type Peer struct {
 	outgoing  chan *bgp.BGPMessage
 	policy    *table.RoutingPolicy
 	localRib  *table.TableManager
+	// detects the peer repeatedly failing in the same way
+	oscillation oscillationDetector
+	staleTimer  *time.Timer
+	// when the last keepalive probe was sent
+	lastProbe time.Time
+	// withdrawals received from the peer and not propagated yet
+	withdrawHold withdrawHold
+	// advertisements to the peer waiting for the batch window
+	updateBatch updateBatch
+	// the best paths not advertised yet after the session came up
+	initialDump *table.BestPathCursor
+	// Address Prefix ORF received from the peer per family
+	prefixOrf map[bgp.RouteFamily]*prefixOrf
+	// default routes originated to the peer per family
+	defaultRoutes map[bgp.RouteFamily]*table.Path
+	// parsed required-community-list
+	requiredCommunities []uint32
+	requiredLarge       []*bgp.LargeCommunity
+	// parsed communities tagging the received routes by the RPKI
+	// validation state
+	validationCommunities map[config.RpkiValidationResultType]uint32
 }
 
 func NewPeer(g config.Global, conf config.Neighbor, loc *table.TableManager, policy *table.RoutingPolicy) *Peer {
@@ -62,6 +88,11 @@ func NewPeer(g config.Global, conf config.Neighbor, loc *table.TableManager, pol
 	peer.adjRibIn = table.NewAdjRib(peer.ID(), rfs)
 	peer.adjRibOut = table.NewAdjRib(peer.ID(), rfs)
 	peer.fsm = NewFSM(&g, &conf, policy)
+	// the server goroutine updates the state of the families in
+	// peer.conf while the FSM goroutine reads them
+	peer.fsm.setAfiSafis(conf.AfiSafis)
+	peer.requiredCommunities, peer.requiredLarge = parseRequiredCommunities(&conf)
+	peer.validationCommunities = parseValidationCommunities(&conf)
 	return peer
 }
 
@@ -89,6 +120,12 @@ func (peer *Peer) isRouteServerClient() bool {
 	return peer.conf.RouteServer.Config.RouteServerClient
 }
 
+// isReceiveOnly tells whether no route is advertised to the peer
+// because of the receive-only collector mode.
+func (peer *Peer) isReceiveOnly() bool {
+	return peer.gConf.Collector.ReceiveOnly
+}
+
 func (peer *Peer) isRouteReflectorClient() bool {
 	return peer.conf.RouteReflector.Config.RouteReflectorClient
 }
@@ -98,29 +135,106 @@ func (peer *Peer) configuredRFlist() []bgp.RouteFamily {
 	return rfs
 }
 
+// retainsRoutes tells whether the routes learned from the peer are
+// retained as stale when the established session went down for the
+// reason. RFC4724 4.2, they are when graceful restart was negotiated
+// and the transport failed. RFC8538 adds the NOTIFICATION messages
+// which allow graceful restart.
+func (peer *Peer) retainsRoutes(reason FsmStateReason) bool {
+	if !peer.conf.GracefulRestart.Config.Enabled || peer.fsm.peerRestartTime() == 0 {
+		return false
+	}
+	switch reason {
+	case FSM_GRACEFUL_RESTART, FSM_READ_FAILED, FSM_WRITE_FAILED:
+		return true
+	}
+	return false
+}
+
+// updateGracefulRestartState records per family whether the graceful
+// restart capability was advertised and received and whether the peer
+// preserved the forwarding state. It returns the families whose
+// forwarding state the peer didn't preserve.
+func (peer *Peer) updateGracefulRestartState() []bgp.RouteFamily {
+	received := peer.fsm.peerGracefulRestartFamilies()
+	advertised := peer.conf.GracefulRestart.Config.Enabled
+	state := &peer.conf.GracefulRestart.State
+	state.Enabled = advertised
+	state.RestartTime = peer.conf.GracefulRestart.Config.RestartTime
+	state.StaleRoutesTime = peer.conf.GracefulRestart.Config.StaleRoutesTime
+	state.PeerRestartTime = peer.fsm.peerRestartTime()
+	rfList := make([]bgp.RouteFamily, 0, len(peer.conf.AfiSafis))
+	for i, a := range peer.conf.AfiSafis {
+		family, _ := bgp.GetRouteFamily(string(a.AfiSafiName))
+		preserved, ok := received[family]
+		state := &peer.conf.AfiSafis[i].MpGracefulRestart.State
+		state.Enabled = a.MpGracefulRestart.Config.Enabled
+		state.Advertised = advertised && a.MpGracefulRestart.Config.Enabled
+		state.Received = ok
+		state.PeerForwardingStatePreserved = preserved
+		if !preserved {
+			rfList = append(rfList, family)
+		}
+	}
+	return rfList
+}
+
+// updatePathsLimitState records the limit in effect for the paths
+// received from the peer and the limit the peer advertised, per family.
+func (peer *Peer) updatePathsLimitState() {
+	for i, a := range peer.conf.AfiSafis {
+		family, _ := bgp.GetRouteFamily(string(a.AfiSafiName))
+		state := &peer.conf.AfiSafis[i].State
+		state.PathsLimit = uint16(peer.fsm.pathsLimit(family))
+		state.PeerPathsLimit = peer.fsm.peerPathsLimit(family)
+	}
+}
+
+// unnegotiatedFamilies returns the configured families which weren't
+// negotiated with the peer.
+func (peer *Peer) unnegotiatedFamilies() []bgp.RouteFamily {
+	rfList := make([]bgp.RouteFamily, 0)
+	for _, rf := range peer.configuredRFlist() {
+		if !peer.fsm.HasFamily(rf) {
+			rfList = append(rfList, rf)
+		}
+	}
+	return rfList
+}
+
 func (peer *Peer) getAccepted(rfList []bgp.RouteFamily) []*table.Path {
 	return peer.adjRibIn.PathList(rfList, true)
 }
 
 func (peer *Peer) getBestFromLocal(rfList []bgp.RouteFamily) ([]*table.Path, []*table.Path) {
-	pathList := []*table.Path{}
-	filtered := []*table.Path{}
-	options := &table.PolicyOptions{
-		Neighbor: peer.fsm.peerInfo.Address,
-	}
 	var source []*table.Path
 	if peer.gConf.Collector.Enabled {
 		source = peer.localRib.GetPathList(peer.TableID(), rfList)
 	} else {
 		source = peer.localRib.GetBestPathList(peer.TableID(), rfList)
 	}
+	return peer.exportPaths(source)
+}
+
+// exportPaths runs the paths through the export policy and returns the
+// ones to be advertised to the peer and the filtered ones.
+func (peer *Peer) exportPaths(source []*table.Path) ([]*table.Path, []*table.Path) {
+	pathList := []*table.Path{}
+	filtered := []*table.Path{}
+	options := &table.PolicyOptions{
+		Neighbor: peer.fsm.peerInfo.Address,
+	}
 	for _, path := range source {
 		p := peer.policy.ApplyPolicy(peer.TableID(), table.POLICY_DIRECTION_EXPORT, filterpath(peer, path), options)
-		if p == nil {
+		// the best paths filterpath turned into withdrawals are
+		// withdrawn by the callers if they were advertised
+		if p == nil || p.IsWithdraw {
 			filtered = append(filtered, path)
 			continue
 		}
-		if !peer.gConf.Collector.Enabled && !peer.isRouteServerClient() {
+		if peer.isRouteServerClient() {
+			p = p.StripAttributes(&peer.conf)
+		} else if !peer.gConf.Collector.Enabled {
 			p = p.Clone(p.IsWithdraw)
 			p.UpdatePathAttrs(&peer.gConf, &peer.conf)
 		}
@@ -129,6 +243,270 @@ func (peer *Peer) getBestFromLocal(rfList []bgp.RouteFamily) ([]*table.Path, []*
 	return pathList, filtered
 }
 
+// startInitialDump starts advertising the local RIB to the peer which
+// has just been established. A large RIB is advertised in chunks by
+// nextInitialDump so that the other peers aren't blocked meanwhile.
+func (peer *Peer) startInitialDump() []*bgp.BGPMessage {
+	if peer.gConf.Collector.Enabled {
+		// all the paths are advertised at once
+		msgs := make([]*bgp.BGPMessage, 0)
+		if pathList, _ := peer.getBestFromLocal(peer.configuredRFlist()); len(pathList) > 0 {
+			msgs = append(msgs, peer.advertise(pathList)...)
+		}
+		return append(msgs, peer.updateDefaultRoutes(nil)...)
+	}
+	peer.initialDump = peer.localRib.NewBestPathCursor(peer.TableID(), peer.configuredRFlist())
+	return peer.nextInitialDump()
+}
+
+// nextInitialDump returns the messages advertising the next chunk of
+// the local RIB. The routes changed meanwhile have been advertised by
+// propagateUpdate and the cursor returns the current best paths so the
+// peer ends up with the current RIB.
+func (peer *Peer) nextInitialDump() []*bgp.BGPMessage {
+	c := peer.initialDump
+	if c == nil {
+		return nil
+	}
+	msgs := make([]*bgp.BGPMessage, 0)
+	for len(msgs) == 0 && !c.Done() {
+		if pathList, _ := peer.exportPaths(c.Next(INITIAL_DUMP_CHUNK)); len(pathList) > 0 {
+			msgs = append(msgs, peer.advertise(pathList)...)
+		}
+	}
+	if c.Done() {
+		peer.initialDump = nil
+		msgs = append(msgs, peer.updateDefaultRoutes(nil)...)
+	}
+	return msgs
+}
+
+// advertise records the paths in the Adj-RIB-Out and returns the UPDATE
+// messages advertising them to the peer. The paths advertised before
+// with another path identifier than the ones replacing them are
+// withdrawn for the families Add-Path send is negotiated for, the peer
+// would keep them as other paths. They are just forgotten for the
+// others, whose paths are replaced implicitly.
+func (peer *Peer) advertise(pathList []*table.Path) []*bgp.BGPMessage {
+	o := peer.fsm.MarshallingOption()
+	var withdrawn, forgotten []*table.Path
+	for _, path := range peer.adjRibOut.ReplacedPaths(pathList) {
+		if o != nil && o.AddPath[path.GetRouteFamily()]&bgp.BGP_ADD_PATH_SEND != 0 {
+			withdrawn = append(withdrawn, path)
+		} else {
+			forgotten = append(forgotten, path)
+		}
+	}
+	peer.adjRibOut.Update(forgotten)
+	if len(withdrawn) > 0 {
+		pathList = append(withdrawn, pathList...)
+	}
+	peer.adjRibOut.Update(pathList)
+	return table.CreateUpdateMsgFromPaths(pathList)
+}
+
+// getOutboundDelta runs the best paths through the export policy again
+// and returns only the paths whose advertisement to the peer changes:
+// new or modified paths to be advertised and withdrawals of the
+// advertised paths which the peer shouldn't receive any more.
+func (peer *Peer) getOutboundDelta(rfList []bgp.RouteFamily) []*table.Path {
+	type key struct {
+		family bgp.RouteFamily
+		prefix string
+	}
+	keyOf := func(path *table.Path) key {
+		return key{path.GetRouteFamily(), path.GetNlri().String()}
+	}
+
+	sentList := peer.adjRibOut.PathList(rfList, false)
+	sent := make(map[key]*table.Path, len(sentList))
+	for _, path := range sentList {
+		// the originated default route is maintained separately
+		if peer.originatesDefaultRoute(path) {
+			continue
+		}
+		sent[keyOf(path)] = path
+	}
+
+	pathList, filtered := peer.getBestFromLocal(rfList)
+	delta := make([]*table.Path, 0)
+	for _, path := range pathList {
+		k := keyOf(path)
+		if old, ok := sent[k]; !ok || old.Fingerprint() != path.Fingerprint() {
+			delta = append(delta, path)
+		}
+		delete(sent, k)
+	}
+	for _, path := range filtered {
+		k := keyOf(path)
+		if old, ok := sent[k]; ok {
+			path = old.Clone(true)
+			path.Filter(peer.ID(), table.POLICY_DIRECTION_EXPORT)
+			delta = append(delta, path)
+			delete(sent, k)
+		}
+	}
+	for _, path := range sentList {
+		if _, ok := sent[keyOf(path)]; ok {
+			delta = append(delta, path.Clone(true))
+		}
+	}
+	return delta
+}
+
+// clearCommunities removes the communities of the type configured for
+// the peer from the path to be advertised to it. It runs before the
+// export policy so that the policy actions set the communities from
+// scratch. Transparently reflected paths are left untouched.
+func (peer *Peer) clearCommunities(path *table.Path) *table.Path {
+	if path.IsWithdraw || path.IsTransparentlyReflected(&peer.conf) {
+		return path
+	}
+	switch peer.conf.Config.ClearCommunities {
+	case config.COMMUNITY_TYPE_STANDARD:
+		return path.ClearCommunities(true, false)
+	case config.COMMUNITY_TYPE_EXTENDED:
+		return path.ClearCommunities(false, true)
+	case config.COMMUNITY_TYPE_BOTH:
+		return path.ClearCommunities(true, true)
+	}
+	return path
+}
+
+// parseRequiredCommunities parses the standard and large communities
+// the routes must carry to be advertised to the neighbor. The invalid
+// ones are ignored.
+func parseRequiredCommunities(n *config.Neighbor) ([]uint32, []*bgp.LargeCommunity) {
+	l := make([]uint32, 0, len(n.Config.RequiredCommunityList))
+	large := make([]*bgp.LargeCommunity, 0)
+	for _, s := range n.Config.RequiredCommunityList {
+		var err error
+		if strings.Count(s, ":") == 2 {
+			var c *bgp.LargeCommunity
+			if c, err = bgp.ParseLargeCommunity(s); err == nil {
+				large = append(large, c)
+			}
+		} else {
+			var v uint32
+			if v, err = table.ParseCommunity(s); err == nil {
+				l = append(l, v)
+			}
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Topic":     "Peer",
+				"Key":       n.Config.NeighborAddress,
+				"Community": s,
+			}).Warn("invalid required community, ignore")
+		}
+	}
+	return l, large
+}
+
+// parseValidationCommunities parses the communities tagging the routes
+// received from the neighbor by the RPKI validation state. The invalid
+// ones are ignored.
+func parseValidationCommunities(n *config.Neighbor) map[config.RpkiValidationResultType]uint32 {
+	m := make(map[config.RpkiValidationResultType]uint32)
+	for state, s := range map[config.RpkiValidationResultType]string{
+		config.RPKI_VALIDATION_RESULT_TYPE_VALID:     n.Config.RpkiValidCommunity,
+		config.RPKI_VALIDATION_RESULT_TYPE_INVALID:   n.Config.RpkiInvalidCommunity,
+		config.RPKI_VALIDATION_RESULT_TYPE_NOT_FOUND: n.Config.RpkiNotFoundCommunity,
+	} {
+		if s == "" {
+			continue
+		}
+		v, err := table.ParseCommunity(s)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Topic":     "Peer",
+				"Key":       n.Config.NeighborAddress,
+				"State":     state,
+				"Community": s,
+			}).Warn("invalid validation community, ignore")
+			continue
+		}
+		m[state] = v
+	}
+	return m
+}
+
+// tagValidation tags the path received from the peer with the
+// community configured for its RPKI validation state. The communities
+// configured for the states are removed first, so that the neighbor
+// can't forge them and a revalidated path doesn't carry two of them.
+func (peer *Peer) tagValidation(path *table.Path) {
+	if len(peer.validationCommunities) == 0 || path.IsWithdraw {
+		return
+	}
+	l := make([]uint32, 0, len(peer.validationCommunities))
+	for _, v := range peer.validationCommunities {
+		l = append(l, v)
+	}
+	path.RemoveCommunities(l)
+	if v, ok := peer.validationCommunities[path.Validation()]; ok {
+		path.SetCommunities([]uint32{v}, false)
+	}
+}
+
+// rejectsUnusableNexthop tells whether the path has to be withdrawn
+// from the iBGP peer because its nexthop is unusable and the peer is
+// configured to reject such paths.
+func (peer *Peer) rejectsUnusableNexthop(path *table.Path) bool {
+	if peer.conf.Config.UnusableNexthopAction != config.UNUSABLE_NEXTHOP_ACTION_TYPE_REJECT || !peer.isIBGPPeer() || peer.isRouteServerClient() {
+		return false
+	}
+	if path.IsWithdraw || path.IsLocal() || peer.conf.Config.AttributeTransparency || path.IsTransparentlyReflected(&peer.conf) {
+		return false
+	}
+	return path.HasUnusableNexthop(net.ParseIP(peer.conf.Transport.Config.LocalAddress))
+}
+
+// hasRequiredCommunity tells whether the path carries one of the
+// standard or large communities required to be advertised to the peer.
+// With only invalid ones configured, no path does.
+func (peer *Peer) hasRequiredCommunity(path *table.Path) bool {
+	if len(peer.conf.Config.RequiredCommunityList) == 0 {
+		return true
+	}
+	for _, c := range path.GetCommunities() {
+		for _, r := range peer.requiredCommunities {
+			if c == r {
+				return true
+			}
+		}
+	}
+	for _, c := range path.GetLargeCommunities() {
+		for _, r := range peer.requiredLarge {
+			if c.Equal(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterUnsentWithdrawals drops the withdrawals of the prefixes which
+// have never been advertised to the peer.
+func (peer *Peer) filterUnsentWithdrawals(pathList []*table.Path) []*table.Path {
+	l := make([]*table.Path, 0, len(pathList))
+	for _, path := range pathList {
+		if path == nil {
+			continue
+		}
+		if path.IsWithdraw && !peer.adjRibOut.Exists(path) {
+			log.WithFields(log.Fields{
+				"Topic":  "Peer",
+				"Key":    peer.conf.Config.NeighborAddress,
+				"Prefix": path.GetNlri().String(),
+			}).Debug("skip withdrawal of a prefix never advertised")
+			continue
+		}
+		l = append(l, path)
+	}
+	return l
+}
+
 func (peer *Peer) handleBGPmessage(e *FsmMsg) ([]*table.Path, []*bgp.BGPMessage) {
 	m := e.MsgData.(*bgp.BGPMessage)
 	log.WithFields(log.Fields{
@@ -141,7 +519,7 @@ func (peer *Peer) handleBGPmessage(e *FsmMsg) ([]*table.Path, []*bgp.BGPMessage)
 	case bgp.BGP_MSG_ROUTE_REFRESH:
 		rr := m.Body.(*bgp.BGPRouteRefresh)
 		rf := bgp.AfiSafiToRouteFamily(rr.AFI, rr.SAFI)
-		if _, ok := peer.fsm.rfMap[rf]; !ok {
+		if !peer.fsm.HasFamily(rf) {
 			log.WithFields(log.Fields{
 				"Topic": "Peer",
 				"Key":   peer.conf.Config.NeighborAddress,
@@ -149,7 +527,13 @@ func (peer *Peer) handleBGPmessage(e *FsmMsg) ([]*table.Path, []*bgp.BGPMessage)
 			}).Warn("Route family isn't supported")
 			break
 		}
-		if _, ok := peer.fsm.capMap[bgp.BGP_CAP_ROUTE_REFRESH]; ok {
+		if peer.isReceiveOnly() {
+			break
+		}
+		if len(rr.ORFs) > 0 {
+			return nil, peer.handleRouteRefreshORF(rf, rr)
+		}
+		if peer.fsm.HasCapability(bgp.BGP_CAP_ROUTE_REFRESH) {
 			rfList := []bgp.RouteFamily{rf}
 			peer.adjRibOut.Drop(rfList)
 			accepted, filtered := peer.getBestFromLocal(rfList)
@@ -169,6 +553,16 @@ func (peer *Peer) handleBGPmessage(e *FsmMsg) ([]*table.Path, []*bgp.BGPMessage)
 	case bgp.BGP_MSG_UPDATE:
 		peer.conf.Timers.State.UpdateRecvTime = time.Now().Unix()
 		if len(e.PathList) > 0 {
+			var dropped int
+			e.PathList, dropped = peer.adjRibIn.LimitPaths(e.PathList, peer.fsm.pathsLimit)
+			if dropped > 0 {
+				peer.conf.AddPaths.State.PathsLimitExceeded += uint32(dropped)
+				log.WithFields(log.Fields{
+					"Topic": "Peer",
+					"Key":   peer.conf.Config.NeighborAddress,
+					"Count": dropped,
+				}).Warn("paths beyond the paths-limit are dropped")
+			}
 			peer.adjRibIn.Update(e.PathList)
 			paths := make([]*table.Path, 0, len(e.PathList))
 			for _, path := range e.PathList {
@@ -182,11 +576,52 @@ func (peer *Peer) handleBGPmessage(e *FsmMsg) ([]*table.Path, []*bgp.BGPMessage)
 	return nil, nil
 }
 
+// injectedNotification builds the NOTIFICATION message the operator
+// injects to the peer. It's sent through the established session like
+// the others, so the session is reset after sending it.
+func (peer *Peer) injectedNotification(n *bgp.BGPNotification) (*bgp.BGPMessage, error) {
+	if peer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+		return nil, fmt.Errorf("neighbor %s isn't established", peer.conf.Config.NeighborAddress)
+	}
+	log.WithFields(log.Fields{
+		"Topic":   "Operation",
+		"Key":     peer.conf.Config.NeighborAddress,
+		"Code":    n.ErrorCode,
+		"Subcode": n.ErrorSubcode,
+		"Data":    n.Data,
+	}).Warn("inject notification on operator request, not a protocol error")
+	return bgp.NewBGPNotificationMessage(n.ErrorCode, n.ErrorSubcode, n.Data), nil
+}
+
+// keepaliveProbe builds the KEEPALIVE message the operator sends to the
+// peer out of the keepalive interval, to check if the session survives.
+// The probes are rate-limited.
+func (peer *Peer) keepaliveProbe() (*bgp.BGPMessage, error) {
+	addr := peer.conf.Config.NeighborAddress
+	if peer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   addr,
+			"State": peer.fsm.state,
+		}).Warn("can't send keepalive probe to a peer not established")
+		return nil, fmt.Errorf("neighbor %s is not established", addr)
+	}
+	now := time.Now()
+	if now.Sub(peer.lastProbe) < KEEPALIVE_PROBE_INTERVAL {
+		return nil, fmt.Errorf("keepalive probe to %s is rate-limited", addr)
+	}
+	peer.lastProbe = now
+	return bgp.NewBGPKeepAliveMessage(), nil
+}
+
 func (peer *Peer) startFSMHandler(incoming, stateCh chan *FsmMsg) {
 	peer.fsm.h = NewFSMHandler(peer.fsm, incoming, stateCh, peer.outgoing)
 }
 
-func (peer *Peer) PassConn(conn *net.TCPConn) {
+// PassConn hands an established connection to the FSM of the peer,
+// which uses it when it's in the active state. Any net.Conn works, so
+// the FSM can be driven over net.Pipe without network.
+func (peer *Peer) PassConn(conn net.Conn) {
 	select {
 	case peer.fsm.connCh <- conn:
 	default:
@@ -207,12 +642,11 @@ func (peer *Peer) ToApiStruct() *api.Peer {
 	f := peer.fsm
 	c := f.pConf
 
-	remoteCap := make([][]byte, 0, len(peer.fsm.capMap))
-	for _, c := range peer.fsm.capMap {
-		for _, m := range c {
-			buf, _ := m.Serialize()
-			remoteCap = append(remoteCap, buf)
-		}
+	remote := peer.fsm.remoteCapabilities()
+	remoteCap := make([][]byte, 0, len(remote))
+	for _, m := range remote {
+		buf, _ := m.Serialize()
+		remoteCap = append(remoteCap, buf)
 	}
 
 	caps := capabilitiesFromConfig(&peer.gConf, &peer.conf)
@@ -224,7 +658,7 @@ func (peer *Peer) ToApiStruct() *api.Peer {
 
 	conf := &api.PeerConf{
 		NeighborAddress:  c.Config.NeighborAddress,
-		Id:               peer.fsm.peerInfo.ID.To4().String(),
+		Id:               peer.fsm.PeerID().To4().String(),
 		PeerAs:           c.Config.PeerAs,
 		LocalAs:          c.Config.LocalAs,
 		PeerType:         uint32(c.Config.PeerType.ToInt()),
@@ -266,11 +700,16 @@ func (peer *Peer) ToApiStruct() *api.Peer {
 		KeepaliveInterval: uint64(timer.Config.KeepaliveInterval),
 	}
 
+	fsmTimers := f.Timers()
 	timerstate := &api.TimersState{
 		KeepaliveInterval:  uint64(timer.State.KeepaliveInterval),
 		NegotiatedHoldTime: uint64(timer.State.NegotiatedHoldTime),
 		Uptime:             uint64(uptime),
 		Downtime:           uint64(downtime),
+		HoldTimer:          uint64(fsmTimers.HoldTimer / time.Millisecond),
+		KeepaliveTimer:     uint64(fsmTimers.KeepaliveTimer / time.Millisecond),
+		ConnectRetryTimer:  uint64(fsmTimers.ConnectRetryTimer / time.Millisecond),
+		IdleHoldTimer:      uint64(fsmTimers.IdleHoldTimer / time.Millisecond),
 	}
 
 	apitimer := &api.Timers{
@@ -0,0 +1,162 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"time"
+)
+
+// peerStartup staggers the start of the FSMs of the configured
+// neighbors. Every FSM runs a few goroutines and tries to connect right
+// away, so with many neighbors they are started a batch at a time and
+// no more than max-peers of them run at once. The neighbors not started
+// yet wait in pending. It's only used from the server goroutine so it
+// needs no locking.
+type peerStartup struct {
+	pending []config.Neighbor
+	timer   *time.Timer
+}
+
+func newPeerStartup() *peerStartup {
+	return &peerStartup{
+		pending: make([]config.Neighbor, 0),
+	}
+}
+
+func (s *peerStartup) find(addr string) int {
+	for i, c := range s.pending {
+		if c.Config.NeighborAddress == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove drops the neighbor from the pending ones and tells whether it
+// was pending.
+func (s *peerStartup) remove(addr string) bool {
+	i := s.find(addr)
+	if i < 0 {
+		return false
+	}
+	s.pending = append(s.pending[:i], s.pending[i+1:]...)
+	return true
+}
+
+// update replaces the configuration of the neighbor if it's pending.
+func (s *peerStartup) update(c config.Neighbor) bool {
+	i := s.find(c.Config.NeighborAddress)
+	if i < 0 {
+		return false
+	}
+	s.pending[i] = c
+	return true
+}
+
+// next takes the pending neighbors to start now, up to the batch size
+// and the room left under max-peers. Zero batch means all of them.
+func (s *peerStartup) next(batch, room int) []config.Neighbor {
+	n := len(s.pending)
+	if batch > 0 && n > batch {
+		n = batch
+	}
+	if room < n {
+		n = room
+	}
+	if n <= 0 {
+		return nil
+	}
+	l := s.pending[:n]
+	s.pending = s.pending[n:]
+	return l
+}
+
+// addPeer queues the configured neighbor and starts the pending
+// neighbors which can be now.
+func (server *BgpServer) addPeer(c config.Neighbor) {
+	addr := c.Config.NeighborAddress
+	if _, found := server.neighborMap[addr]; found || server.startup.find(addr) >= 0 {
+		log.Warn("Can't overwrite the exising peer ", addr)
+		return
+	}
+	if server.bgpConfig.Global.ListenConfig.Port > 0 {
+		for _, l := range server.Listeners(addr) {
+			SetTcpMD5SigSockopts(l, addr, c.Config.AuthPassword)
+		}
+	}
+	server.startup.pending = append(server.startup.pending, c)
+	server.startPendingPeers()
+	if server.startup.find(addr) >= 0 {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   addr,
+		}).Debug("wait for the FSM to be started")
+	}
+}
+
+// startPendingPeers starts a batch of the pending neighbors within
+// max-peers, and arms the timer for the next batch when some are left.
+// It does nothing while the timer is running.
+func (server *BgpServer) startPendingPeers() {
+	s := server.startup
+	if s.timer != nil {
+		return
+	}
+	c := server.bgpConfig.Global.Config
+	room := len(s.pending)
+	if c.MaxPeers > 0 {
+		room = int(c.MaxPeers) - len(server.neighborMap)
+	}
+	l := s.next(int(c.PeerStartupBatchSize), room)
+	for _, n := range l {
+		server.startPeer(n)
+	}
+	if len(l) > 0 && len(s.pending) > 0 && c.PeerStartupBatchSize > 0 {
+		ch := server.startupCh
+		s.timer = time.AfterFunc(time.Duration(c.PeerStartupInterval*float64(time.Second)), func() {
+			ch <- struct{}{}
+		})
+	}
+	server.bgpConfig.Global.State.PendingPeers = uint32(len(s.pending))
+}
+
+// startPeer creates the peer of the neighbor and starts its FSM.
+func (server *BgpServer) startPeer(c config.Neighbor) *Peer {
+	peer := NewPeer(server.bgpConfig.Global, c, server.globalRib, server.policy)
+	server.setPolicyByConfig(peer.ID(), c.ApplyPolicy, c.AfiSafis)
+	if peer.isRouteServerClient() {
+		pathList := make([]*table.Path, 0)
+		rfList := peer.configuredRFlist()
+		for _, p := range server.neighborMap {
+			if !p.isRouteServerClient() {
+				continue
+			}
+			pathList = append(pathList, p.getAccepted(rfList)...)
+		}
+		moded := server.RSimportPaths(peer, pathList)
+		if len(moded) > 0 {
+			server.globalRib.ProcessPaths(moded)
+		}
+	}
+	server.neighborMap[c.Config.NeighborAddress] = peer
+	peer.startFSMHandler(server.fsmincomingCh, server.fsmStateCh)
+	server.broadcastPeerState(peer, bgp.BGP_FSM_IDLE)
+	return peer
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPeerStartup(t *testing.T) {
+	assert := assert.New(t)
+	s := newPeerStartup()
+	addrs := func(l []config.Neighbor) []string {
+		a := make([]string, 0, len(l))
+		for _, c := range l {
+			a = append(a, c.Config.NeighborAddress)
+		}
+		return a
+	}
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"} {
+		s.pending = append(s.pending, config.Neighbor{Config: config.NeighborConfig{NeighborAddress: addr}})
+	}
+
+	// in batches of two
+	assert.Equal([]string{"10.0.0.1", "10.0.0.2"}, addrs(s.next(2, 5)))
+
+	// the pending neighbor can be updated and deleted
+	c := config.Neighbor{Config: config.NeighborConfig{NeighborAddress: "10.0.0.4", PeerAs: 65004}}
+	assert.True(s.update(c))
+	assert.True(s.remove("10.0.0.3"))
+	assert.False(s.remove("10.0.0.1"))
+	assert.False(s.update(config.Neighbor{Config: config.NeighborConfig{NeighborAddress: "10.0.0.1"}}))
+
+	// within the room left under max-peers
+	l := s.next(2, 1)
+	assert.Equal([]string{"10.0.0.4"}, addrs(l))
+	assert.Equal(uint32(65004), l[0].Config.PeerAs)
+	assert.Nil(s.next(2, 0))
+	assert.Nil(s.next(2, -1))
+
+	// zero batch takes all
+	assert.Equal([]string{"10.0.0.5"}, addrs(s.next(0, 5)))
+	assert.Equal(0, len(s.pending))
+	assert.Nil(s.next(0, 5))
+}
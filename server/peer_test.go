@@ -0,0 +1,255 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFilterUnsentWithdrawals(t *testing.T) {
+	assert := assert.New(t)
+	p, _ := makePeerAndHandler()
+	p.adjRibOut = table.NewAdjRib("10.0.0.2", []bgp.RouteFamily{bgp.RF_IPv4_UC})
+
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string, withdraw bool) *table.Path {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		return table.NewPath(source, bgp.NewIPAddrPrefix(24, prefix), withdraw, pathAttributes, time.Now(), false)
+	}
+
+	advertised := p.filterUnsentWithdrawals([]*table.Path{path("10.10.10.0", false), nil})
+	assert.Equal(1, len(advertised))
+	p.adjRibOut.Update(advertised)
+
+	pathList := p.filterUnsentWithdrawals([]*table.Path{path("10.10.10.0", true), path("10.10.20.0", true), path("10.10.30.0", false)})
+	assert.Equal(2, len(pathList))
+	assert.Equal("10.10.10.0/24", pathList[0].GetNlri().String())
+	assert.True(pathList[0].IsWithdraw)
+	assert.Equal("10.10.30.0/24", pathList[1].GetNlri().String())
+}
+
+func TestGetOutboundDelta(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{Config: config.NeighborConfig{NeighborAddress: "10.0.0.2", PeerAs: 65002}}
+	rib := table.NewTableManager(rfList, 0, 0)
+	policy := table.NewRoutingPolicy()
+	policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	p := NewPeer(g, n, rib, policy)
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string, med uint32, withdraw bool) *table.Path {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+			bgp.NewPathAttributeMultiExitDisc(med),
+		}
+		return table.NewPath(source, bgp.NewIPAddrPrefix(24, prefix), withdraw, pathAttributes, time.Now(), false)
+	}
+
+	rib.ProcessPaths([]*table.Path{path("10.10.10.0", 0, false), path("10.10.20.0", 0, false)})
+	delta := p.getOutboundDelta(rfList)
+	assert.Equal(2, len(delta))
+	p.adjRibOut.Update(delta)
+
+	// nothing changed
+	assert.Equal(0, len(p.getOutboundDelta(rfList)))
+
+	rib.ProcessPaths([]*table.Path{path("10.10.10.0", 100, false), path("10.10.20.0", 0, true)})
+	delta = p.getOutboundDelta(rfList)
+	assert.Equal(2, len(delta))
+	assert.Equal("10.10.10.0/24", delta[0].GetNlri().String())
+	assert.False(delta[0].IsWithdraw)
+	assert.Equal("10.10.20.0/24", delta[1].GetNlri().String())
+	assert.True(delta[1].IsWithdraw)
+	p.adjRibOut.Update(delta)
+	assert.Equal(1, p.adjRibOut.Count(rfList))
+
+	// the export policy now rejects everything
+	policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_REJECT)
+	delta = p.getOutboundDelta(rfList)
+	assert.Equal(1, len(delta))
+	assert.True(delta[0].IsWithdraw)
+	p.adjRibOut.Update(delta)
+	assert.Equal(0, p.adjRibOut.Count(rfList))
+}
+
+func TestInitialDump(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	src := newTestPeer(server, testNeighbor("10.0.0.1", 65001), rfList)
+	n := testNeighbor("10.0.0.2", 65002)
+	n.AfiSafis = []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}}
+	dst := newTestPeer(server, n, rfList)
+
+	prefixes := make([]string, 0, INITIAL_DUMP_CHUNK+2)
+	pathList := make([]*table.Path, 0, INITIAL_DUMP_CHUNK+2)
+	for i := 0; i < INITIAL_DUMP_CHUNK+2; i++ {
+		prefix := fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)
+		prefixes = append(prefixes, prefix)
+		pathList = append(pathList, newTestPath(src.fsm.peerInfo, prefix, false))
+	}
+	server.globalRib.ProcessPaths(pathList)
+
+	assert.True(len(dst.startInitialDump()) > 0)
+	assert.NotNil(dst.initialDump)
+	assert.Equal(INITIAL_DUMP_CHUNK, dst.adjRibOut.Count(rfList))
+
+	// a route not advertised yet is withdrawn meanwhile
+	sent := make(map[string]bool)
+	for _, path := range dst.adjRibOut.PathList(rfList, false) {
+		sent[path.GetNlri().String()] = true
+	}
+	var withdrawn string
+	for _, prefix := range prefixes {
+		if !sent[prefix] {
+			withdrawn = prefix
+			break
+		}
+	}
+	msgs, _ := server.propagateUpdate(src, []*table.Path{newTestPath(src.fsm.peerInfo, withdrawn, true)})
+	for _, m := range msgs {
+		if m.sendCh == dst.outgoing {
+			assert.Equal(0, len(m.messages))
+		}
+	}
+
+	msgs = server.continueInitialDumps()
+	assert.Equal(1, len(msgs))
+	assert.Equal(dst.outgoing, msgs[0].sendCh)
+	assert.Nil(dst.initialDump)
+	assert.Equal(INITIAL_DUMP_CHUNK+1, dst.adjRibOut.Count(rfList))
+	assert.Equal(0, len(server.continueInitialDumps()))
+}
+
+func TestPathsLimitExceeded(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	n := testNeighbor("10.0.0.1", 65001)
+	n.AddPaths.Config.Receive = true
+	n.AddPaths.Config.PathsLimit = 2
+	n.AfiSafis = []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}}
+	p := newTestPeer(server, n, rfList)
+	p.fsm.capMap[bgp.BGP_CAP_ADD_PATH] = []bgp.ParameterCapabilityInterface{bgp.NewCapAddPath(bgp.RF_IPv4_UC, bgp.BGP_ADD_PATH_SEND)}
+	p.fsm.capMap[bgp.BGP_CAP_PATHS_LIMIT] = []bgp.ParameterCapabilityInterface{bgp.NewCapPathsLimit([]bgp.CapPathsLimitValue{{RouteFamily: bgp.RF_IPv4_UC, Limit: 8}})}
+	p.fsm.marshalOption = p.fsm.addPathOption()
+	p.updatePathsLimitState()
+	assert.Equal(uint16(2), p.conf.AfiSafis[0].State.PathsLimit)
+	assert.Equal(uint16(8), p.conf.AfiSafis[0].State.PeerPathsLimit)
+
+	pathList := make([]*table.Path, 0, 3)
+	for id := uint32(1); id <= 3; id++ {
+		path := newTestPath(p.fsm.peerInfo, "10.10.10.0/24", false)
+		path.SetPathIdentifier(id)
+		pathList = append(pathList, path)
+	}
+	paths, _ := p.handleBGPmessage(&FsmMsg{
+		MsgType:  FSM_MSG_BGP_MESSAGE,
+		MsgData:  bgp.NewBGPUpdateMessage(nil, nil, nil),
+		PathList: pathList,
+	})
+	assert.Equal(2, len(paths))
+	assert.Equal(2, p.adjRibIn.Count(rfList))
+	assert.Equal(uint32(1), p.conf.AddPaths.State.PathsLimitExceeded)
+}
+
+func TestInjectedNotification(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+	p, h := makePeerAndHandler()
+	p.fsm.conn = m
+	p.fsm.pConf.Timers.State.NegotiatedHoldTime = 90
+	n := &bgp.BGPNotification{
+		ErrorCode:    bgp.BGP_ERROR_UPDATE_MESSAGE_ERROR,
+		ErrorSubcode: bgp.BGP_ERROR_SUB_MALFORMED_AS_PATH,
+		Data:         []byte{1, 2},
+	}
+
+	_, err := p.injectedNotification(n)
+	assert.NotNil(err)
+
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	msg, err := p.injectedNotification(n)
+	assert.Nil(err)
+
+	// the session is reset after sending it
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		p.outgoing <- msg
+	}()
+	state, reason := h.established()
+	assert.Equal(bgp.BGP_FSM_IDLE, state)
+	assert.Equal(FSM_NOTIFICATION_SENT, reason)
+	sent, _ := bgp.ParseBGPMessage(m.sendBuf[len(m.sendBuf)-1])
+	body := sent.Body.(*bgp.BGPNotification)
+	assert.Equal(n.ErrorCode, body.ErrorCode)
+	assert.Equal(n.ErrorSubcode, body.ErrorSubcode)
+	assert.Equal(n.Data, body.Data)
+}
+
+func TestSendKeepalive(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	p := newTestPeer(server, testNeighbor("10.0.0.1", 65001), rfList)
+	active := newTestPeer(server, testNeighbor("10.0.0.3", 65003), rfList)
+	active.fsm.state = bgp.BGP_FSM_ACTIVE
+	server.SetGlobalType(server.bgpConfig.Global)
+	go server.Serve()
+
+	assert.Nil(server.SendKeepalive("10.0.0.1"))
+	// rate-limited
+	assert.NotNil(server.SendKeepalive("10.0.0.1"))
+	assert.NotNil(server.SendKeepalive("10.0.0.3"))
+	assert.NotNil(server.SendKeepalive("10.0.0.2"))
+
+	m := <-p.outgoing
+	assert.Equal(uint8(bgp.BGP_MSG_KEEPALIVE), m.Header.Type)
+	assert.Equal(0, len(p.outgoing))
+	assert.Equal(0, len(active.outgoing))
+}
+
+func TestPeerTimersState(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	s := newTestServer(rfList)
+	p := newTestPeer(s, testNeighbor("10.0.0.1", 65001), rfList)
+	p.fsm.state = bgp.BGP_FSM_ACTIVE
+	p.fsm.deadlines.set(&p.fsm.deadlines.connectRetry, 5*time.Second)
+
+	state := p.ToApiStruct().Timers.State
+	assert.True(state.ConnectRetryTimer > 4000 && state.ConnectRetryTimer <= 5000)
+	assert.Equal(uint64(0), state.HoldTimer)
+	assert.Equal(uint64(0), state.KeepaliveTimer)
+	assert.Equal(uint64(0), state.IdleHoldTimer)
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"time"
+)
+
+// updateRateLimiter is a token bucket limiting the rate of UPDATE
+// messages received from a peer. The bucket starts full so that the
+// initial table transfer can use the whole burst.
+type updateRateLimiter struct {
+	rate      float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	throttled bool
+}
+
+func newUpdateRateLimiter(pConf *config.Neighbor, now time.Time) *updateRateLimiter {
+	c := pConf.Config
+	if c.UpdateRateLimit == 0 {
+		return nil
+	}
+	burst := c.UpdateRateBurst
+	if burst == 0 {
+		burst = c.UpdateRateLimit * config.DEFAULT_UPDATE_RATE_BURST_SECONDS
+	}
+	return &updateRateLimiter{
+		rate:   float64(c.UpdateRateLimit),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// reserve takes a token for a message and returns how long to wait
+// until the token is available, zero when it's available now.
+func (l *updateRateLimiter) reserve(now time.Time) time.Duration {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// limitUpdateRate is called for every UPDATE message received from the
+// peer. Above the rate and the burst, it stops reading from the peer
+// until the message fits in the rate, or returns the error to close the
+// session with when configured so.
+func (h *FSMHandler) limitUpdateRate() error {
+	l := h.updateLimiter
+	if l == nil {
+		return nil
+	}
+	d := l.reserve(time.Now())
+	if d == 0 {
+		if l.throttled {
+			l.throttled = false
+			log.WithFields(log.Fields{
+				"Topic": "Peer",
+				"Key":   h.fsm.pConf.Config.NeighborAddress,
+			}).Info("update rate fell within the limit")
+		}
+		return nil
+	}
+	if h.fsm.pConf.Config.UpdateRateLimitAction == config.UPDATE_RATE_LIMIT_ACTION_TYPE_CEASE {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   h.fsm.pConf.Config.NeighborAddress,
+			"Limit": h.fsm.pConf.Config.UpdateRateLimit,
+		}).Warn("update rate limit exceeded, cease the session")
+		return bgp.NewMessageError(bgp.BGP_ERROR_CEASE, bgp.BGP_ERROR_SUB_OUT_OF_RESOURCES, nil, "update rate limit exceeded")
+	}
+	if !l.throttled {
+		l.throttled = true
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   h.fsm.pConf.Config.NeighborAddress,
+			"Limit": h.fsm.pConf.Config.UpdateRateLimit,
+		}).Warn("update rate limit exceeded, throttle")
+	}
+	select {
+	case <-time.After(d):
+	case <-h.t.Dying():
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUpdateRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+	n := &config.Neighbor{}
+	now := time.Now()
+	assert.Nil(newUpdateRateLimiter(n, now))
+
+	n.Config.UpdateRateLimit = 10
+	l := newUpdateRateLimiter(n, now)
+	// the burst defaults to a minute worth of the rate
+	assert.Equal(float64(600), l.burst)
+
+	n.Config.UpdateRateBurst = 5
+	l = newUpdateRateLimiter(n, now)
+	for i := 0; i < 5; i++ {
+		assert.Equal(time.Duration(0), l.reserve(now))
+	}
+	assert.Equal(100*time.Millisecond, l.reserve(now))
+	assert.Equal(200*time.Millisecond, l.reserve(now))
+	// refilled, but not above the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 5; i++ {
+		assert.Equal(time.Duration(0), l.reserve(now))
+	}
+	assert.Equal(100*time.Millisecond, l.reserve(now))
+}
+
+func TestFSMHandlerEstablished_UpdateRateLimitCease(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMockConnection()
+	p, h := makePeerAndHandler()
+	p.fsm.gConf.Config.As = 65000
+	p.fsm.pConf.Config.PeerAs = 65001
+	p.fsm.pConf.Config.UpdateRateLimit = 1
+	p.fsm.pConf.Config.UpdateRateBurst = 1
+	p.fsm.pConf.Config.UpdateRateLimitAction = config.UPDATE_RATE_LIMIT_ACTION_TYPE_CEASE
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	h.conn = m
+	h.msgCh = make(chan *FsmMsg, 1)
+	h.holdTimerResetCh = make(chan bool, 2)
+	h.updateLimiter = newUpdateRateLimiter(p.fsm.pConf, time.Now())
+
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+	buf, _ := bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri).Serialize()
+
+	m.setData(buf)
+	assert.Nil(h.recvMessageWithError())
+	e := <-h.msgCh
+	assert.Equal(1, len(e.PathList))
+
+	m.setData(buf)
+	assert.NotNil(h.recvMessageWithError())
+	e = <-h.msgCh
+	err, ok := e.MsgData.(*bgp.MessageError)
+	assert.True(ok)
+	assert.Equal(uint8(bgp.BGP_ERROR_CEASE), err.TypeCode)
+	assert.Equal(uint8(bgp.BGP_ERROR_SUB_OUT_OF_RESOURCES), err.SubTypeCode)
+}
@@ -0,0 +1,229 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	api "github.com/osrg/gobgp/api"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"time"
+)
+
+type RibJournalEntryType string
+
+const (
+	RIB_JOURNAL_ADD      RibJournalEntryType = "add"
+	RIB_JOURNAL_MODIFY   RibJournalEntryType = "modify"
+	RIB_JOURNAL_WITHDRAW RibJournalEntryType = "withdraw"
+)
+
+// RibJournalEntry is a change of the best path of a prefix in the
+// global rib. The sequence numbers start from 1 and increase by one
+// with every entry, so a standby following the journal detects the
+// entries it missed by a gap. Path is the new best path, or the
+// withdrawn one.
+type RibJournalEntry struct {
+	Sequence  uint64
+	Type      RibJournalEntryType
+	Prefix    string
+	Family    bgp.RouteFamily
+	Path      *api.Path
+	Timestamp time.Time
+}
+
+func (e *RibJournalEntry) ToApiStruct() *api.RibJournalEntry {
+	return &api.RibJournalEntry{
+		Sequence:  e.Sequence,
+		Type:      string(e.Type),
+		Prefix:    e.Prefix,
+		Family:    uint32(e.Family),
+		Path:      e.Path,
+		Timestamp: e.Timestamp.Unix(),
+	}
+}
+
+// RibSnapshot is the best paths in the global rib as of the journal
+// entry Sequence. A standby which detected a gap replaces its copy
+// with the snapshot and applies the entries after Sequence.
+type RibSnapshot struct {
+	Sequence uint64
+	Paths    []*api.Path
+}
+
+func (s *RibSnapshot) ToApiStruct() *api.RibSnapshot {
+	return &api.RibSnapshot{
+		Sequence: s.Sequence,
+		Paths:    s.Paths,
+	}
+}
+
+// ribJournal keeps the latest entries in a ring so that a standby
+// which missed a few can catch up without a snapshot. It's only used
+// from the server goroutine.
+type ribJournal struct {
+	seq     uint64
+	entries []*RibJournalEntry
+}
+
+func newRibJournal(size int) *ribJournal {
+	return &ribJournal{
+		entries: make([]*RibJournalEntry, size),
+	}
+}
+
+// record appends the entry for the change of the best path of the
+// destination, and returns it. It returns nil when the best path
+// didn't change.
+func (j *ribJournal) record(dst *table.Destination, now time.Time) *RibJournalEntry {
+	old, best, _, changed := dst.BestPathChange(table.GLOBAL_RIB_NAME)
+	if !changed {
+		return nil
+	}
+	nlri := dst.GetNlri()
+	e := &RibJournalEntry{
+		Prefix:    nlri.String(),
+		Family:    bgp.AfiSafiToRouteFamily(nlri.AFI(), nlri.SAFI()),
+		Timestamp: now,
+	}
+	switch {
+	case best == nil:
+		e.Type = RIB_JOURNAL_WITHDRAW
+		e.Path = old.ToApiStruct(table.GLOBAL_RIB_NAME)
+		e.Path.IsWithdraw = true
+	case old == nil:
+		e.Type = RIB_JOURNAL_ADD
+		e.Path = best.ToApiStruct(table.GLOBAL_RIB_NAME)
+	default:
+		e.Type = RIB_JOURNAL_MODIFY
+		e.Path = best.ToApiStruct(table.GLOBAL_RIB_NAME)
+	}
+	j.seq++
+	e.Sequence = j.seq
+	j.entries[int((j.seq-1)%uint64(len(j.entries)))] = e
+	return e
+}
+
+// since returns the entries after the sequence number. It fails when
+// some of them are no longer kept, then the standby needs a snapshot.
+func (j *ribJournal) since(seq uint64) ([]*RibJournalEntry, error) {
+	if seq > j.seq {
+		return nil, fmt.Errorf("sequence %d is ahead of the journal at %d", seq, j.seq)
+	}
+	if j.seq-seq > uint64(len(j.entries)) {
+		return nil, fmt.Errorf("entries after sequence %d are no longer kept, take a snapshot", seq)
+	}
+	l := make([]*RibJournalEntry, 0, j.seq-seq)
+	for s := seq + 1; s <= j.seq; s++ {
+		l = append(l, j.entries[int((s-1)%uint64(len(j.entries)))])
+	}
+	return l, nil
+}
+
+// journalRouteChanges records the changes of the best paths in the
+// journal and sends them to the REQ_MONITOR_RIB_JOURNAL requests.
+func (server *BgpServer) journalRouteChanges(dsts []*table.Destination) {
+	if server.ribJournal == nil {
+		return
+	}
+	now := time.Now()
+	for _, dst := range dsts {
+		e := server.ribJournal.record(dst, now)
+		if e == nil {
+			continue
+		}
+		result := &GrpcResponse{
+			Data: e,
+		}
+		remainReqs := make([]*GrpcRequest, 0, len(server.broadcastReqs))
+		for _, req := range server.broadcastReqs {
+			select {
+			case <-req.EndCh:
+				continue
+			default:
+			}
+			remainReqs = append(remainReqs, req)
+			if req.RequestType != REQ_MONITOR_RIB_JOURNAL {
+				continue
+			}
+			server.broadcastMsgs = append(server.broadcastMsgs, &broadcastGrpcMsg{
+				req:    req,
+				result: result,
+			})
+		}
+		server.broadcastReqs = remainReqs
+	}
+}
+
+func (server *BgpServer) ribSnapshot() *RibSnapshot {
+	rfList := server.globalRib.GetRFlist()
+	best := server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, rfList)
+	s := &RibSnapshot{
+		Sequence: server.ribJournal.seq,
+		Paths:    make([]*api.Path, 0, len(best)),
+	}
+	for _, path := range best {
+		s.Paths = append(s.Paths, path.ToApiStruct(table.GLOBAL_RIB_NAME))
+	}
+	return s
+}
+
+func (server *BgpServer) handleRibJournalRequest(grpcReq *GrpcRequest) {
+	if server.ribJournal == nil {
+		grpcReq.ResponseCh <- &GrpcResponse{
+			ResponseErr: fmt.Errorf("rib journal isn't enabled"),
+		}
+		close(grpcReq.ResponseCh)
+		return
+	}
+	result := &GrpcResponse{}
+	switch grpcReq.RequestType {
+	case REQ_MONITOR_RIB_JOURNAL:
+		server.broadcastReqs = append(server.broadcastReqs, grpcReq)
+		return
+	case REQ_RIB_JOURNAL:
+		result.Data, result.ResponseErr = server.ribJournal.since(grpcReq.Data.(uint64))
+	case REQ_RIB_SNAPSHOT:
+		result.Data = server.ribSnapshot()
+	}
+	grpcReq.ResponseCh <- result
+	close(grpcReq.ResponseCh)
+}
+
+// RibJournal returns the entries of the rib journal after the sequence
+// number. It fails when some of them are no longer kept, then the
+// caller needs to take a snapshot by RibSnapshot.
+func (server *BgpServer) RibJournal(since uint64) ([]*RibJournalEntry, error) {
+	req := NewGrpcRequest(REQ_RIB_JOURNAL, "", bgp.RouteFamily(0), since)
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	if res.ResponseErr != nil {
+		return nil, res.ResponseErr
+	}
+	return res.Data.([]*RibJournalEntry), nil
+}
+
+// RibSnapshot returns the best paths in the global rib along with the
+// sequence number of the last journal entry they reflect.
+func (server *BgpServer) RibSnapshot() (*RibSnapshot, error) {
+	req := NewGrpcRequest(REQ_RIB_SNAPSHOT, "", bgp.RouteFamily(0), nil)
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	if res.ResponseErr != nil {
+		return nil, res.ResponseErr
+	}
+	return res.Data.(*RibSnapshot), nil
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	api "github.com/osrg/gobgp/api"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRibJournal(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	server.globalRib = table.NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	server.ribJournal = newRibJournal(3)
+	req := NewGrpcRequest(REQ_MONITOR_RIB_JOURNAL, "", bgp.RouteFamily(0), nil)
+	server.handleRibJournalRequest(req)
+	assert.Equal(1, len(server.broadcastReqs))
+
+	path := func(addr, prefix string, med uint32, withdraw bool) *table.Path {
+		source := &table.PeerInfo{AS: 65001, ID: net.ParseIP(addr), Address: net.ParseIP(addr)}
+		return table.NewPath(source, bgp.NewIPAddrPrefix(24, prefix), withdraw, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop(addr),
+			bgp.NewPathAttributeMultiExitDisc(med),
+		}, time.Now(), false)
+	}
+	process := func(l ...*table.Path) []*RibJournalEntry {
+		server.broadcastMsgs = nil
+		server.broadcastRouteChanges(server.globalRib.ProcessPaths(l))
+		r := make([]*RibJournalEntry, 0)
+		for _, m := range server.broadcastMsgs {
+			r = append(r, m.(*broadcastGrpcMsg).result.Data.(*RibJournalEntry))
+		}
+		return r
+	}
+
+	e := process(path("10.0.0.1", "10.10.10.0", 100, false))
+	assert.Equal(1, len(e))
+	assert.Equal(uint64(1), e[0].Sequence)
+	assert.Equal(RIB_JOURNAL_ADD, e[0].Type)
+	assert.Equal("10.10.10.0/24", e[0].Prefix)
+	assert.Equal("10.0.0.1", e[0].Path.SourceId)
+
+	// worse path, the best doesn't change and no sequence is used
+	assert.Equal(0, len(process(path("10.0.0.2", "10.10.10.0", 200, false))))
+
+	e = process(path("10.0.0.3", "10.10.10.0", 50, false), path("10.0.0.1", "10.20.20.0", 100, false))
+	assert.Equal(2, len(e))
+	assert.Equal(uint64(2), e[0].Sequence)
+	assert.Equal(RIB_JOURNAL_MODIFY, e[0].Type)
+	assert.Equal("10.0.0.3", e[0].Path.SourceId)
+	assert.Equal(uint64(3), e[1].Sequence)
+	assert.Equal(RIB_JOURNAL_ADD, e[1].Type)
+
+	// a standby which saw the first entry catches up
+	l, err := server.ribJournal.since(1)
+	assert.Nil(err)
+	assert.Equal(2, len(l))
+	assert.Equal(uint64(2), l[0].Sequence)
+	assert.Equal(uint64(3), l[1].Sequence)
+	l, err = server.ribJournal.since(3)
+	assert.Nil(err)
+	assert.Equal(0, len(l))
+	_, err = server.ribJournal.since(4)
+	assert.NotNil(err)
+
+	e = process(path("10.0.0.1", "10.20.20.0", 100, true))
+	assert.Equal(1, len(e))
+	assert.Equal(uint64(4), e[0].Sequence)
+	assert.Equal(RIB_JOURNAL_WITHDRAW, e[0].Type)
+	assert.Equal("10.20.20.0/24", e[0].Prefix)
+	assert.True(e[0].Path.IsWithdraw)
+
+	// the first entry has been overwritten, a standby which missed
+	// it needs a snapshot
+	_, err = server.ribJournal.since(0)
+	assert.NotNil(err)
+	l, err = server.ribJournal.since(1)
+	assert.Nil(err)
+	assert.Equal(3, len(l))
+
+	s := server.ribSnapshot()
+	assert.Equal(uint64(4), s.Sequence)
+	assert.Equal(1, len(s.Paths))
+	assert.Equal("10.0.0.3", s.Paths[0].SourceId)
+
+	// finished requests are dropped
+	req.EndCh <- struct{}{}
+	assert.Equal(0, len(process(path("10.0.0.1", "10.30.30.0", 100, false))))
+	assert.Equal(0, len(server.broadcastReqs))
+	assert.Equal(uint64(5), server.ribJournal.seq)
+}
+
+func TestRibJournalDisabled(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	req := NewGrpcRequest(REQ_RIB_SNAPSHOT, "", bgp.RouteFamily(0), nil)
+	server.handleRibJournalRequest(req)
+	res := <-req.ResponseCh
+	assert.NotNil(res.ResponseErr)
+}
+
+type testRibJournalStream struct {
+	grpc.ServerStream
+	entries []*api.RibJournalEntry
+}
+
+func (s *testRibJournalStream) Send(e *api.RibJournalEntry) error {
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func TestRibJournalGrpc(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	server.globalRib = table.NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	server.ribJournal = newRibJournal(3)
+	source := &table.PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.1"), Address: net.ParseIP("10.0.0.1")}
+	server.journalRouteChanges(server.globalRib.ProcessPaths([]*table.Path{
+		newTestPath(source, "10.10.10.0/24", false),
+		newTestPath(source, "10.20.20.0/24", false),
+	}))
+
+	ch := make(chan *GrpcRequest)
+	go func() {
+		for req := range ch {
+			server.handleRibJournalRequest(req)
+		}
+	}()
+	defer close(ch)
+	s := NewGrpcServer(0, ch)
+
+	stream := &testRibJournalStream{}
+	assert.Nil(s.GetRibJournal(&api.RibJournalArguments{Since: 1}, stream))
+	assert.Equal(1, len(stream.entries))
+	assert.Equal(uint64(2), stream.entries[0].Sequence)
+	assert.Equal(string(RIB_JOURNAL_ADD), stream.entries[0].Type)
+	assert.Equal("10.20.20.0/24", stream.entries[0].Prefix)
+	assert.Equal(uint32(bgp.RF_IPv4_UC), stream.entries[0].Family)
+	assert.Equal("10.0.0.1", stream.entries[0].Path.SourceId)
+
+	assert.NotNil(s.GetRibJournal(&api.RibJournalArguments{Since: 3}, &testRibJournalStream{}))
+
+	snapshot, err := s.GetRibSnapshot(context.Background(), &api.Arguments{})
+	assert.Nil(err)
+	assert.Equal(uint64(2), snapshot.Sequence)
+	assert.Equal(2, len(snapshot.Paths))
+}
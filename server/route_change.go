@@ -0,0 +1,106 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	api "github.com/osrg/gobgp/api"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"time"
+)
+
+// RouteChange is sent to the REQ_MONITOR_ROUTE_CHANGE requests when the
+// best path of a prefix in the global rib is added, replaced or
+// withdrawn. Old is nil when the prefix is added and New is nil when
+// it's withdrawn.
+type RouteChange struct {
+	Prefix    string
+	Family    bgp.RouteFamily
+	Old       *api.Path
+	New       *api.Path
+	Reason    table.BestPathReason
+	Timestamp time.Time
+}
+
+func describeApiPath(p *api.Path) string {
+	return fmt.Sprintf("%s (AS %d)", p.SourceId, p.SourceAsn)
+}
+
+func (c *RouteChange) String() string {
+	switch {
+	case c.Old == nil:
+		return fmt.Sprintf("prefix %s best path added: %s because %s", c.Prefix, describeApiPath(c.New), c.Reason)
+	case c.New == nil:
+		return fmt.Sprintf("prefix %s best path withdrawn: %s", c.Prefix, describeApiPath(c.Old))
+	}
+	return fmt.Sprintf("prefix %s best path changed from %s to %s because %s", c.Prefix, describeApiPath(c.Old), describeApiPath(c.New), c.Reason)
+}
+
+func (server *BgpServer) broadcastRouteChanges(dsts []*table.Destination) {
+	server.journalRouteChanges(dsts)
+	watching := false
+	for _, req := range server.broadcastReqs {
+		if req.RequestType == REQ_MONITOR_ROUTE_CHANGE {
+			watching = true
+			break
+		}
+	}
+	if !watching {
+		return
+	}
+	now := time.Now()
+	for _, dst := range dsts {
+		old, best, reason, changed := dst.BestPathChange(table.GLOBAL_RIB_NAME)
+		if !changed {
+			continue
+		}
+		c := &RouteChange{
+			Prefix:    dst.GetNlri().String(),
+			Family:    bgp.AfiSafiToRouteFamily(dst.GetNlri().AFI(), dst.GetNlri().SAFI()),
+			Reason:    reason,
+			Timestamp: now,
+		}
+		if old != nil {
+			c.Old = old.ToApiStruct(table.GLOBAL_RIB_NAME)
+		}
+		if best != nil {
+			c.New = best.ToApiStruct(table.GLOBAL_RIB_NAME)
+		}
+		result := &GrpcResponse{
+			Data: c,
+		}
+		remainReqs := make([]*GrpcRequest, 0, len(server.broadcastReqs))
+		for _, req := range server.broadcastReqs {
+			select {
+			case <-req.EndCh:
+				continue
+			default:
+			}
+			remainReqs = append(remainReqs, req)
+			if req.RequestType != REQ_MONITOR_ROUTE_CHANGE {
+				continue
+			}
+			if req.RouteFamily == bgp.RouteFamily(0) || req.RouteFamily == c.Family {
+				server.broadcastMsgs = append(server.broadcastMsgs, &broadcastGrpcMsg{
+					req:    req,
+					result: result,
+				})
+			}
+		}
+		server.broadcastReqs = remainReqs
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBroadcastRouteChanges(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	server.globalRib = table.NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	req := NewGrpcRequest(REQ_MONITOR_ROUTE_CHANGE, "", bgp.RouteFamily(0), nil)
+	server.broadcastReqs = append(server.broadcastReqs, req)
+
+	path := func(addr string, med uint32, withdraw bool) *table.Path {
+		source := &table.PeerInfo{AS: 65001, ID: net.ParseIP(addr), Address: net.ParseIP(addr)}
+		return table.NewPath(source, bgp.NewIPAddrPrefix(24, "10.10.10.0"), withdraw, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop(addr),
+			bgp.NewPathAttributeMultiExitDisc(med),
+		}, time.Now(), false)
+	}
+	changes := func(l []*table.Path) []*RouteChange {
+		server.broadcastMsgs = nil
+		server.broadcastRouteChanges(server.globalRib.ProcessPaths(l))
+		r := make([]*RouteChange, 0)
+		for _, m := range server.broadcastMsgs {
+			r = append(r, m.(*broadcastGrpcMsg).result.Data.(*RouteChange))
+		}
+		return r
+	}
+
+	c := changes([]*table.Path{path("10.0.0.1", 100, false)})
+	assert.Equal(1, len(c))
+	assert.Nil(c[0].Old)
+	assert.Equal("10.0.0.1", c[0].New.SourceId)
+	assert.Equal(table.BPR_ONLY_PATH, c[0].Reason)
+	assert.Equal("prefix 10.10.10.0/24 best path added: 10.0.0.1 (AS 65001) because Only Path", c[0].String())
+
+	// worse path, the best doesn't change
+	assert.Equal(0, len(changes([]*table.Path{path("10.0.0.2", 200, false)})))
+
+	c = changes([]*table.Path{path("10.0.0.3", 50, false)})
+	assert.Equal(1, len(c))
+	assert.Equal("10.0.0.1", c[0].Old.SourceId)
+	assert.Equal("10.0.0.3", c[0].New.SourceId)
+	assert.Equal(table.BPR_MED, c[0].Reason)
+	assert.Equal("prefix 10.10.10.0/24 best path changed from 10.0.0.1 (AS 65001) to 10.0.0.3 (AS 65001) because MED", c[0].String())
+
+	changes([]*table.Path{path("10.0.0.1", 100, true), path("10.0.0.2", 200, true)})
+	c = changes([]*table.Path{path("10.0.0.3", 50, true)})
+	assert.Equal(1, len(c))
+	assert.Equal("10.0.0.3", c[0].Old.SourceId)
+	assert.Nil(c[0].New)
+	assert.Equal("prefix 10.10.10.0/24 best path withdrawn: 10.0.0.3 (AS 65001)", c[0].String())
+
+	// finished requests are dropped
+	req.EndCh <- struct{}{}
+	assert.Equal(0, len(changes([]*table.Path{path("10.0.0.1", 100, false)})))
+	assert.Equal(0, len(server.broadcastReqs))
+}
@@ -19,11 +19,13 @@ import (
 	"github.com/armon/go-radix"
 	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
 	"github.com/stretchr/testify/assert"
 	"net"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func strToASParam(str string) *bgp.PathAttributeAsPath {
@@ -247,3 +249,81 @@ func TestValidate8(t *testing.T) {
 	r = validateOne(tree, "10.0.0.0/24", "65001")
 	assert.Equal(r, config.RPKI_VALIDATION_RESULT_TYPE_INVALID)
 }
+
+func TestTagValidation(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	server.globalRib = table.NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	server.roaManager, _ = newROAManager(65000, nil)
+	client := &roaClient{
+		records:  make(map[int]uint32),
+		prefixes: make(map[int]uint32),
+	}
+	server.roaManager.clientMap["10.0.0.100:323"] = client
+	addROA(client, bgp.AFI_IP, server.roaManager.roas[bgp.RF_IPv4_UC], 65001, net.ParseIP("10.10.0.0").To4(), 16, 24)
+
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{Config: config.NeighborConfig{
+		NeighborAddress:       "10.0.0.1",
+		PeerAs:                65001,
+		RpkiValidCommunity:    "65000:1",
+		RpkiInvalidCommunity:  "65000:2",
+		RpkiNotFoundCommunity: "invalid",
+	}}
+	peer := NewPeer(g, n, server.globalRib, server.policy)
+	server.neighborMap[n.Config.NeighborAddress] = peer
+	valid, invalid := uint32(65000<<16|1), uint32(65000<<16|2)
+	assert.Equal(map[config.RpkiValidationResultType]uint32{
+		config.RPKI_VALIDATION_RESULT_TYPE_VALID:   valid,
+		config.RPKI_VALIDATION_RESULT_TYPE_INVALID: invalid,
+	}, peer.validationCommunities)
+
+	receiveFrom := func(peer *Peer, prefix string, origin uint32, communities ...uint32) *table.Path {
+		path := table.NewPath(peer.fsm.peerInfo, bgp.NewIPAddrPrefix(24, prefix), false, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001, origin})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+			bgp.NewPathAttributeCommunities(append([]uint32{65001<<16 | 100}, communities...)),
+		}, time.Now(), false)
+		server.validatePaths(server.globalRib.ProcessPaths([]*table.Path{path}), false)
+		return path
+	}
+	receive := func(prefix string, origin uint32, communities ...uint32) *table.Path {
+		return receiveFrom(peer, prefix, origin, communities...)
+	}
+
+	path := receive("10.10.1.0", 65001)
+	assert.Equal(config.RPKI_VALIDATION_RESULT_TYPE_VALID, path.Validation())
+	assert.Equal([]uint32{65001<<16 | 100, valid}, path.GetCommunities())
+
+	// the forged tag is replaced
+	path = receive("10.10.2.0", 65002, valid)
+	assert.Equal(config.RPKI_VALIDATION_RESULT_TYPE_INVALID, path.Validation())
+	assert.Equal([]uint32{65001<<16 | 100, invalid}, path.GetCommunities())
+
+	// no community is configured for not-found
+	path = receive("10.20.1.0", 65001, invalid)
+	assert.Equal(config.RPKI_VALIDATION_RESULT_TYPE_NOT_FOUND, path.Validation())
+	assert.Equal([]uint32{65001<<16 | 100}, path.GetCommunities())
+
+	// the tag is advertised to the iBGP peers
+	best := server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv4_UC})
+	assert.Equal(3, len(best))
+	tagged := 0
+	for _, p := range best {
+		msgs := table.CreateUpdateMsgFromPaths([]*table.Path{p})
+		for _, a := range msgs[0].Body.(*bgp.BGPUpdate).PathAttributes {
+			if c, ok := a.(*bgp.PathAttributeCommunities); ok && len(c.Value) == 2 {
+				tagged++
+			}
+		}
+	}
+	assert.Equal(2, tagged)
+
+	// the routes of a link-local neighbor are tagged as well
+	n.Config.NeighborAddress = "fe80::1%eth0"
+	zoned := NewPeer(g, n, server.globalRib, server.policy)
+	server.neighborMap[n.Config.NeighborAddress] = zoned
+	path = receiveFrom(zoned, "10.10.3.0", 65001)
+	assert.Equal([]uint32{65001<<16 | 100, valid}, path.GetCommunities())
+}
@@ -31,6 +31,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -41,6 +42,10 @@ type SenderMsg struct {
 	sendCh      chan *bgp.BGPMessage
 	destination string
 	twoBytesAs  bool
+	notified    bool
+	// the counter of the messages queued to the peer, decremented
+	// as each message is handed to sendCh
+	queued *int64
 }
 
 type broadcastMsg interface {
@@ -107,6 +112,16 @@ type BgpServer struct {
 	globalRib      *table.TableManager
 	zclient        *zebra.Client
 	roaManager     *roaManager
+	admission      *admissionGate
+	startup        *peerStartup
+	startupCh      chan struct{}
+	shutdownCh     chan struct{}
+	nexthopHolds   map[string]*nexthopHoldDown
+	nexthopHoldCh  chan *nexthopHoldDown
+	nexthops       map[string]bool
+	deferral       *selectionDeferral
+	deferralCh     chan *selectionDeferral
+	ribJournal     *ribJournal
 	shutdown       bool
 	watchers       Watchers
 }
@@ -124,6 +139,14 @@ func NewBgpServer() *BgpServer {
 	b.watchers = Watchers(make(map[watcherType]watcher))
 	b.roaManager, _ = newROAManager(0, nil)
 	b.policy = table.NewRoutingPolicy()
+	b.admission = newAdmissionGate()
+	b.startup = newPeerStartup()
+	b.startupCh = make(chan struct{})
+	b.shutdownCh = make(chan struct{})
+	b.nexthopHolds = make(map[string]*nexthopHoldDown)
+	b.nexthopHoldCh = make(chan *nexthopHoldDown)
+	b.nexthops = make(map[string]bool)
+	b.deferralCh = make(chan *selectionDeferral)
 	return &b
 }
 
@@ -209,7 +232,7 @@ func (server *BgpServer) Serve() {
 	server.roaManager, _ = newROAManager(g.Config.As, nil)
 
 	if g.Mrt.FileName != "" {
-		w, err := newMrtWatcher(g.Mrt.FileName)
+		w, err := newMrtWatcher(g.Mrt)
 		if err != nil {
 			log.Warn(err)
 		} else {
@@ -248,24 +271,7 @@ func (server *BgpServer) Serve() {
 	go func(ch chan *SenderMsg) {
 		for {
 			// TODO: must be more clever. Slow peer makes other peers slow too.
-			m := <-ch
-			w := func(c chan *bgp.BGPMessage, msg *bgp.BGPMessage) {
-				// nasty but the peer could already become non established state before here.
-				defer func() { recover() }()
-				c <- msg
-			}
-
-			for _, b := range m.messages {
-				if m.twoBytesAs == false && b.Header.Type == bgp.BGP_MSG_UPDATE {
-					log.WithFields(log.Fields{
-						"Topic": "Peer",
-						"Key":   m.destination,
-						"Data":  b,
-					}).Debug("update for 2byte AS peer")
-					table.UpdatePathAttrs2ByteAs(b.Body.(*bgp.BGPUpdate))
-				}
-				w(m.sendCh, b)
-			}
+			(<-ch).send()
 		}
 	}(senderCh)
 
@@ -279,6 +285,14 @@ func (server *BgpServer) Serve() {
 
 	rfs, _ := config.AfiSafis(g.AfiSafis).ToRfList()
 	server.globalRib = table.NewTableManager(rfs, g.MplsLabelRange.MinLabel, g.MplsLabelRange.MaxLabel)
+	server.globalRib.SetRouteSelectionOptions(g.RouteSelectionOptions.Config)
+	server.globalRib.SetMaxParentDepth(int(g.Config.MaxPathParentDepth))
+	if g.RibJournal.Enabled {
+		server.ribJournal = newRibJournal(int(g.RibJournal.Size))
+	}
+	if c := g.GracefulRestart.Config; c.Enabled && c.DeferralTime > 0 {
+		server.startSelectionDeferral(time.Duration(c.DeferralTime) * time.Second)
+	}
 	server.listeners = make([]*net.TCPListener, 0, 2)
 	acceptCh := make(chan *net.TCPConn, 4096)
 	if g.ListenConfig.Port > 0 {
@@ -318,11 +332,22 @@ func (server *BgpServer) Serve() {
 	}
 
 	for {
+		// the next chunk of the initial advertisements is queued
+		// after the previous ones are handed to the sender
+		if len(senderMsgs) == 0 {
+			senderMsgs = append(senderMsgs, server.continueInitialDumps()...)
+		}
 		var firstMsg *SenderMsg
 		var sCh chan *SenderMsg
 		if len(senderMsgs) > 0 {
 			sCh = senderCh
 			firstMsg = senderMsgs[0]
+			if !firstMsg.notified {
+				firstMsg.notified = true
+				if server.watchers.watching(WATCHER_EVENT_UPDATE_MSG_SENT) {
+					server.notifySentUpdates(firstMsg)
+				}
+			}
 		}
 		var firstBroadcastMsg broadcastMsg
 		var bCh chan broadcastMsg
@@ -400,47 +425,38 @@ func (server *BgpServer) Serve() {
 			if len(m) > 0 {
 				senderMsgs = append(senderMsgs, m...)
 			}
+		case h := <-server.nexthopHoldCh:
+			m := server.releaseNexthopHoldDown(h)
+			if len(m) > 0 {
+				senderMsgs = append(senderMsgs, m...)
+			}
+		case d := <-server.deferralCh:
+			m := server.releaseSelectionDeferral(d)
+			if len(m) > 0 {
+				senderMsgs = append(senderMsgs, m...)
+			}
 		case conn := <-acceptCh:
 			passConn(conn)
 		case config := <-server.addedPeerCh:
-			addr := config.Config.NeighborAddress
-			_, found := server.neighborMap[addr]
-			if found {
-				log.Warn("Can't overwrite the exising peer ", addr)
-				continue
-			}
-			if g.ListenConfig.Port > 0 {
-				for _, l := range server.Listeners(addr) {
-					SetTcpMD5SigSockopts(l, addr, config.Config.AuthPassword)
-				}
-			}
-			peer := NewPeer(g, config, server.globalRib, server.policy)
-			server.setPolicyByConfig(peer.ID(), config.ApplyPolicy)
-			if peer.isRouteServerClient() {
-				pathList := make([]*table.Path, 0)
-				rfList := peer.configuredRFlist()
-				for _, p := range server.neighborMap {
-					if !p.isRouteServerClient() {
-						continue
-					}
-					pathList = append(pathList, p.getAccepted(rfList)...)
-				}
-				moded := server.RSimportPaths(peer, pathList)
-				if len(moded) > 0 {
-					server.globalRib.ProcessPaths(moded)
-				}
-			}
-			server.neighborMap[addr] = peer
-			peer.startFSMHandler(server.fsmincomingCh, server.fsmStateCh)
-			server.broadcastPeerState(peer, bgp.BGP_FSM_IDLE)
+			server.addPeer(config)
+		case <-server.startupCh:
+			server.startup.timer = nil
+			server.startPendingPeers()
+		case <-server.shutdownCh:
+			server.handleShutdown()
 		case config := <-server.deletedPeerCh:
 			addr := config.Config.NeighborAddress
 			for _, l := range server.Listeners(addr) {
 				SetTcpMD5SigSockopts(l, addr, "")
 			}
 			peer, found := server.neighborMap[addr]
-			if found {
+			if server.startup.remove(addr) {
+				log.Info("Delete a pending peer configuration for ", addr)
+				server.bgpConfig.Global.State.PendingPeers = uint32(len(server.startup.pending))
+			} else if found {
 				log.Info("Delete a peer configuration for ", addr)
+				server.dequeueAdmission(peer)
+				server.stopStaleTimer(peer)
 				go func(addr string) {
 					t := time.AfterFunc(time.Minute*5, func() { log.Fatal("failed to free the fsm.h.t for ", addr) })
 					peer.fsm.h.t.Kill(nil)
@@ -452,19 +468,26 @@ func (server *BgpServer) Serve() {
 					t.Stop()
 				}(addr)
 
+				server.releaseAdmission(peer)
 				m := server.dropPeerAllRoutes(peer)
 				if len(m) > 0 {
 					senderMsgs = append(senderMsgs, m...)
 				}
 				delete(server.neighborMap, addr)
+				server.startPendingPeers()
 			} else {
 				log.Info("Can't delete a peer configuration for ", addr)
 			}
 		case config := <-server.updatedPeerCh:
 			addr := config.Config.NeighborAddress
+			if server.startup.update(config) {
+				continue
+			}
 			peer := server.neighborMap[addr]
 			peer.conf = config
-			server.setPolicyByConfig(peer.ID(), config.ApplyPolicy)
+			peer.requiredCommunities, peer.requiredLarge = parseRequiredCommunities(&config)
+			peer.validationCommunities = parseValidationCommunities(&config)
+			server.setPolicyByConfig(peer.ID(), config.ApplyPolicy, config.AfiSafis)
 		case e := <-server.fsmincomingCh:
 			handleFsmMsg(e)
 		case e := <-server.fsmStateCh:
@@ -484,13 +507,84 @@ func (server *BgpServer) Serve() {
 	}
 }
 
+// notifySentUpdates passes the UPDATE messages to be sent to the
+// watchers, encoded as they go on the wire.
+func (server *BgpServer) notifySentUpdates(m *SenderMsg) {
+	peer, found := server.neighborMap[m.destination]
+	if !found || peer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+		return
+	}
+	l, _ := peer.fsm.LocalHostPort()
+	// encoded as sent, with the path identifiers of Add-Path
+	option := peer.fsm.MarshallingOption()
+	now := time.Now()
+	for _, msg := range m.messages {
+		if msg.Header.Type != bgp.BGP_MSG_UPDATE {
+			continue
+		}
+		payload, err := msg.Serialize(option)
+		if err == nil && m.twoBytesAs == false {
+			// the sender rewrites the AS_PATH for 2 bytes AS
+			// peers, do the same on a copy
+			if msg, err = bgp.ParseBGPMessage(payload, peerMarshallingOption(option)); err == nil {
+				table.UpdatePathAttrs2ByteAs(msg.Body.(*bgp.BGPUpdate))
+				payload, err = msg.Serialize(option)
+			}
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Topic": "Peer",
+				"Key":   m.destination,
+			}).Warn(err)
+			continue
+		}
+		server.notify2watchers(WATCHER_EVENT_UPDATE_MSG_SENT, &watcherEventUpdateMsg{
+			message:      msg,
+			peerAS:       peer.fsm.peerInfo.AS,
+			localAS:      peer.fsm.peerInfo.LocalAS,
+			peerAddress:  peer.fsm.peerInfo.Address,
+			localAddress: net.ParseIP(l),
+			peerID:       peer.fsm.PeerID(),
+			fourBytesAs:  m.twoBytesAs,
+			timestamp:    now,
+			payload:      payload,
+			isLocal:      true,
+		})
+	}
+}
+
+// send hands the messages to the peer's outgoing channel. It's called
+// from the sender goroutine.
+func (m *SenderMsg) send() {
+	w := func(c chan *bgp.BGPMessage, msg *bgp.BGPMessage) {
+		// nasty but the peer could already become non established state before here.
+		defer func() { recover() }()
+		c <- msg
+	}
+
+	for _, b := range m.messages {
+		if m.twoBytesAs == false && b.Header.Type == bgp.BGP_MSG_UPDATE {
+			log.WithFields(log.Fields{
+				"Topic": "Peer",
+				"Key":   m.destination,
+				"Data":  b,
+			}).Debug("update for 2byte AS peer")
+			table.UpdatePathAttrs2ByteAs(b.Body.(*bgp.BGPUpdate))
+		}
+		w(m.sendCh, b)
+		atomic.AddInt64(m.queued, -1)
+	}
+}
+
 func newSenderMsg(peer *Peer, messages []*bgp.BGPMessage) *SenderMsg {
-	_, y := peer.fsm.capMap[bgp.BGP_CAP_FOUR_OCTET_AS_NUMBER]
+	y := peer.fsm.HasCapability(bgp.BGP_CAP_FOUR_OCTET_AS_NUMBER)
+	atomic.AddInt64(&peer.fsm.queued, int64(len(messages)))
 	return &SenderMsg{
 		messages:    messages,
 		sendCh:      peer.outgoing,
 		destination: peer.conf.Config.NeighborAddress,
 		twoBytesAs:  y,
+		queued:      &peer.fsm.queued,
 	}
 }
 
@@ -507,12 +601,30 @@ func filterpath(peer *Peer, path *table.Path) *table.Path {
 	if path == nil {
 		return nil
 	}
-	if _, ok := peer.fsm.rfMap[path.GetRouteFamily()]; !ok {
+	if !peer.fsm.HasFamily(path.GetRouteFamily()) {
 		return nil
 	}
 
 	remoteAddr := peer.conf.Config.NeighborAddress
 
+	if isDefaultRoute(path) && peer.defaultRouteConfig(path.GetRouteFamily()) != nil {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   remoteAddr,
+			"Data":  path,
+		}).Debug("the default route is originated to the peer, ignore")
+		return nil
+	}
+
+	if !path.IsWithdraw && !peer.prefixOrfAccept(path) {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   remoteAddr,
+			"Data":  path,
+		}).Debug("filtered by prefix ORF, ignore")
+		return nil
+	}
+
 	//iBGP handling
 	if !path.IsLocal() && peer.isIBGPPeer() {
 		ignore := true
@@ -525,7 +637,7 @@ func filterpath(peer *Peer, path *table.Path) *table.Path {
 		// RFC4456 8. Avoiding Routing Information Loops
 		// A router that recognizes the ORIGINATOR_ID attribute SHOULD
 		// ignore a route received with its BGP Identifier as the ORIGINATOR_ID.
-		if id := path.GetOriginatorID(); peer.gConf.Config.RouterId == id.String() {
+		if id := path.GetOriginatorID().String(); id == peer.gConf.Config.RouterId || id == config.LocalRouterId(&peer.gConf, &peer.conf) {
 			log.WithFields(log.Fields{
 				"Topic":        "Peer",
 				"Key":          remoteAddr,
@@ -565,7 +677,7 @@ func filterpath(peer *Peer, path *table.Path) *table.Path {
 		}
 	}
 
-	if remoteAddr == path.GetSource().Address.String() {
+	if !peer.conf.Config.AdvertiseToSource && remoteAddr == path.GetSource().Address.String() {
 		log.WithFields(log.Fields{
 			"Topic": "Peer",
 			"Key":   remoteAddr,
@@ -577,22 +689,47 @@ func filterpath(peer *Peer, path *table.Path) *table.Path {
 	if !peer.isRouteServerClient() && isASLoop(peer, path) {
 		return nil
 	}
-	return path
+
+	if peer.rejectsUnusableNexthop(path) {
+		log.WithFields(log.Fields{
+			"Topic":   "Peer",
+			"Key":     remoteAddr,
+			"Nexthop": path.GetNexthop(),
+			"Data":    path,
+		}).Info("withdraw path with unusable nexthop")
+		return path.Clone(true)
+	}
+
+	if !path.IsWithdraw && !peer.hasRequiredCommunity(path) {
+		// the path it replaces is withdrawn if it was advertised,
+		// batchUpdates drops the withdrawal otherwise
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   remoteAddr,
+			"Data":  path,
+		}).Debug("without the required community, withdraw")
+		return path.Clone(true)
+	}
+	return peer.clearCommunities(path)
 }
 
 func (server *BgpServer) dropPeerAllRoutes(peer *Peer) []*SenderMsg {
+	return server.dropPeerRoutes(peer, peer.configuredRFlist())
+}
+
+func (server *BgpServer) dropPeerRoutes(peer *Peer, rfList []bgp.RouteFamily) []*SenderMsg {
 	msgs := make([]*SenderMsg, 0)
 
 	options := &table.PolicyOptions{}
-	for _, rf := range peer.configuredRFlist() {
+	for _, rf := range rfList {
 		dsts := server.globalRib.DeletePathsByPeer(peer.fsm.peerInfo, rf)
 		server.validatePaths(dsts, true)
 		if peer.isRouteServerClient() {
 			for _, targetPeer := range server.neighborMap {
-				if !targetPeer.isRouteServerClient() || targetPeer == peer || targetPeer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+				if !targetPeer.isRouteServerClient() || targetPeer == peer || targetPeer.isReceiveOnly() || targetPeer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
 					continue
 				}
-				if _, ok := targetPeer.fsm.rfMap[rf]; !ok {
+				if !targetPeer.fsm.HasFamily(rf) {
 					continue
 				}
 				pathList := make([]*table.Path, 0, len(dsts))
@@ -602,11 +739,11 @@ func (server *BgpServer) dropPeerAllRoutes(peer *Peer) []*SenderMsg {
 						pathList = append(pathList, path)
 					}
 				}
-				msgList := table.CreateUpdateMsgFromPaths(pathList)
+				msgList := server.batchUpdates(targetPeer, pathList)
 				msgs = append(msgs, newSenderMsg(targetPeer, msgList))
-				targetPeer.adjRibOut.Update(pathList)
 			}
 		} else {
+			server.broadcastRouteChanges(dsts)
 			sendPathList := make([]*table.Path, 0, len(dsts))
 			for _, dst := range dsts {
 				path := dst.NewFeed(table.GLOBAL_RIB_NAME)
@@ -615,16 +752,16 @@ func (server *BgpServer) dropPeerAllRoutes(peer *Peer) []*SenderMsg {
 				}
 			}
 			if len(sendPathList) == 0 {
-				return msgs
+				continue
 			}
 
 			server.broadcastBests(sendPathList)
 
 			for _, targetPeer := range server.neighborMap {
-				if targetPeer.isRouteServerClient() || targetPeer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+				if targetPeer.isRouteServerClient() || targetPeer.isReceiveOnly() || targetPeer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
 					continue
 				}
-				if _, ok := targetPeer.fsm.rfMap[rf]; !ok {
+				if !targetPeer.fsm.HasFamily(rf) {
 					continue
 				}
 				pathList := make([]*table.Path, 0, len(sendPathList))
@@ -634,8 +771,7 @@ func (server *BgpServer) dropPeerAllRoutes(peer *Peer) []*SenderMsg {
 						pathList = append(pathList, path)
 					}
 				}
-				targetPeer.adjRibOut.Update(pathList)
-				msgList := table.CreateUpdateMsgFromPaths(pathList)
+				msgList := server.batchUpdates(targetPeer, pathList)
 
 				msgs = append(msgs, newSenderMsg(targetPeer, msgList))
 			}
@@ -644,6 +780,53 @@ func (server *BgpServer) dropPeerAllRoutes(peer *Peer) []*SenderMsg {
 	return msgs
 }
 
+// lookupNexthops starts tracking the nexthops of the paths and looks up
+// the ones which weren't tracked yet.
+func (server *BgpServer) lookupNexthops(pathList []*table.Path) {
+	if server.zclient == nil {
+		return
+	}
+	for _, path := range pathList {
+		if path == nil || path.IsWithdraw || path.IsLocal() || path.IsFromZebra() {
+			continue
+		}
+		nexthop := path.GetNexthop()
+		if server.nexthops[nexthop.String()] {
+			continue
+		}
+		server.nexthops[nexthop.String()] = true
+		if z := newNexthopLookupMsg(server.zclient, nexthop); z != nil {
+			server.broadcastMsgs = append(server.broadcastMsgs, z)
+		}
+	}
+}
+
+// refreshNexthops looks up the tracked nexthops in the prefix again, or
+// all of them when it's nil. zebra notifies only the result of each
+// lookup, so this is done whenever the routes or the interfaces it
+// reports changed, which can change the reachability. The nexthops no
+// path uses any more aren't tracked from now on.
+func (server *BgpServer) refreshNexthops(prefix *net.IPNet) {
+	if server.zclient == nil {
+		return
+	}
+	m := make(map[string]bool)
+	for _, nexthop := range server.globalRib.Nexthops() {
+		m[nexthop.String()] = true
+		if prefix != nil && !prefix.Contains(nexthop) {
+			continue
+		}
+		if z := newNexthopLookupMsg(server.zclient, nexthop); z != nil {
+			server.broadcastMsgs = append(server.broadcastMsgs, z)
+		}
+	}
+	server.nexthops = m
+}
+
+func (server *BgpServer) handleNexthopReachability(nexthop net.IP, reachable bool, metric uint32) []*SenderMsg {
+	return server.propagateBestPaths(server.globalRib.UpdateNexthopReachability(nexthop, reachable, metric), nil)
+}
+
 func (server *BgpServer) broadcastValidationResults(results []*api.ROAResult) {
 	for _, result := range results {
 		remainReqs := make([]*GrpcRequest, 0, len(server.broadcastReqs))
@@ -756,7 +939,7 @@ func (server *BgpServer) broadcastPeerState(peer *Peer, oldState bgp.FSMState) {
 				localAddress: net.ParseIP(laddr),
 				peerPort:     rport,
 				localPort:    lport,
-				peerID:       peer.fsm.peerInfo.ID,
+				peerID:       peer.fsm.PeerID(),
 				sentOpen:     sentOpen,
 				recvOpen:     recvOpen,
 				state:        newState,
@@ -802,6 +985,25 @@ func (server *BgpServer) isRpkiMonitored() bool {
 	return false
 }
 
+// sourcePeer returns the peer the path was received from. The source
+// address of the path lacks the zone of a link-local neighbor, so it's
+// compared with the configured neighbor addresses.
+func (server *BgpServer) sourcePeer(path *table.Path) *Peer {
+	addr := path.GetSource().Address
+	if addr == nil {
+		return nil
+	}
+	if peer, ok := server.neighborMap[addr.String()]; ok {
+		return peer
+	}
+	for _, peer := range server.neighborMap {
+		if addr.Equal(config.ParseAddress(peer.conf.Config.NeighborAddress)) {
+			return peer
+		}
+	}
+	return nil
+}
+
 func (server *BgpServer) validatePaths(dsts []*table.Destination, peerDown bool) {
 	isMonitor := server.isRpkiMonitored()
 	for _, dst := range dsts {
@@ -830,11 +1032,17 @@ func (server *BgpServer) validatePaths(dsts []*table.Destination, peerDown bool)
 			}
 			server.broadcastValidationResults(rrList)
 		}
-		if vResults := server.roaManager.validate(dst.UpdatedPathList, isMonitor); isMonitor {
+		vResults := server.roaManager.validate(dst.UpdatedPathList, isMonitor)
+		for _, path := range dst.UpdatedPathList {
+			if peer := server.sourcePeer(path); peer != nil {
+				peer.tagValidation(path)
+			}
+		}
+		if isMonitor {
 			for i, path := range dst.UpdatedPathList {
 				old := func() config.RpkiValidationResultType {
 					for _, withdrawn := range dst.WithdrawnList {
-						if path.GetSource().Equal(withdrawn.GetSource()) {
+						if path.GetSource().Equal(withdrawn.GetSource()) && path.GetPathIdentifier() == withdrawn.GetPathIdentifier() {
 							return withdrawn.Validation()
 						}
 					}
@@ -874,7 +1082,7 @@ func (server *BgpServer) propagateUpdate(peer *Peer, pathList []*table.Path) ([]
 		dsts := rib.ProcessPaths(append(pathList, moded...))
 		server.validatePaths(dsts, false)
 		for _, targetPeer := range server.neighborMap {
-			if !targetPeer.isRouteServerClient() || targetPeer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+			if !targetPeer.isRouteServerClient() || targetPeer.isReceiveOnly() || targetPeer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
 				continue
 			}
 			sendPathList := make([]*table.Path, 0, len(dsts))
@@ -882,11 +1090,10 @@ func (server *BgpServer) propagateUpdate(peer *Peer, pathList []*table.Path) ([]
 			for _, dst := range dsts {
 				path := server.policy.ApplyPolicy(targetPeer.TableID(), table.POLICY_DIRECTION_EXPORT, filterpath(targetPeer, dst.NewFeed(targetPeer.TableID())), options)
 				if path != nil {
-					sendPathList = append(sendPathList, path)
+					sendPathList = append(sendPathList, path.StripAttributes(&targetPeer.conf))
 				}
 			}
-			msgList := table.CreateUpdateMsgFromPaths(sendPathList)
-			targetPeer.adjRibOut.Update(sendPathList)
+			msgList := server.batchUpdates(targetPeer, sendPathList)
 			msgs = append(msgs, newSenderMsg(targetPeer, msgList))
 		}
 	} else {
@@ -894,46 +1101,271 @@ func (server *BgpServer) propagateUpdate(peer *Peer, pathList []*table.Path) ([]
 			pathList[idx] = server.policy.ApplyPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IMPORT, path, nil)
 		}
 		alteredPathList = pathList
-		dsts := rib.ProcessPaths(pathList)
-		server.validatePaths(dsts, false)
-		sendPathList := make([]*table.Path, 0, len(dsts))
-		if server.bgpConfig.Global.Collector.Enabled {
-			sendPathList = pathList
-		} else {
-			for _, dst := range dsts {
-				path := dst.NewFeed(table.GLOBAL_RIB_NAME)
-				if path != nil {
-					sendPathList = append(sendPathList, path)
+		if server.bgpConfig.Global.Config.SuppressDuplicateUpdates {
+			uniq := make([]*table.Path, 0, len(pathList))
+			for _, path := range pathList {
+				if path != nil && rib.IsDuplicate(path) {
+					log.WithFields(log.Fields{
+						"Topic":  "Peer",
+						"Key":    path.GetSource().Address,
+						"Prefix": path.GetNlri().String(),
+					}).Debug("ignore duplicate path")
+					continue
 				}
+				uniq = append(uniq, path)
 			}
-			if len(sendPathList) == 0 {
-				return msgs, alteredPathList
+			pathList = uniq
+		}
+		dsts := rib.ProcessPaths(pathList)
+		server.validatePaths(dsts, false)
+		server.lookupNexthops(pathList)
+		msgs = server.propagateBestPaths(dsts, pathList)
+	}
+	return msgs, alteredPathList
+}
+
+// propagateBestPaths advertises the new best paths of the destinations
+// in the global RIB to the peers. In the collector mode, the processed
+// paths are advertised as they are instead.
+func (server *BgpServer) propagateBestPaths(dsts []*table.Destination, pathList []*table.Path) []*SenderMsg {
+	msgs := make([]*SenderMsg, 0)
+	server.broadcastRouteChanges(dsts)
+	sendPathList := make([]*table.Path, 0, len(dsts))
+	if server.bgpConfig.Global.Collector.Enabled {
+		sendPathList = pathList
+	} else {
+		for _, dst := range dsts {
+			path := dst.NewFeed(table.GLOBAL_RIB_NAME)
+			if path != nil {
+				sendPathList = append(sendPathList, path)
 			}
-			server.broadcastBests(sendPathList)
 		}
+	}
+	if len(sendPathList) == 0 {
+		return msgs
+	}
+	if !server.bgpConfig.Global.Collector.Enabled {
+		server.broadcastBests(sendPathList)
+	}
 
-		for _, targetPeer := range server.neighborMap {
-			if targetPeer.isRouteServerClient() || targetPeer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
-				continue
+	options := &table.PolicyOptions{}
+	updater := table.NewPathAttrsUpdater(&server.bgpConfig.Global)
+	for _, targetPeer := range server.neighborMap {
+		if targetPeer.isRouteServerClient() || targetPeer.isReceiveOnly() || targetPeer.fsm.state != bgp.BGP_FSM_ESTABLISHED {
+			continue
+		}
+		pathList := make([]*table.Path, len(sendPathList))
+		copy(pathList, sendPathList)
+		options.Neighbor = targetPeer.fsm.peerInfo.Address
+		for idx, path := range pathList {
+			path = server.policy.ApplyPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, filterpath(targetPeer, path), options)
+			if path != nil && !server.bgpConfig.Global.Collector.Enabled {
+				path = updater.Update(path, &targetPeer.conf)
 			}
-			pathList := make([]*table.Path, len(sendPathList))
-			copy(pathList, sendPathList)
-			options.Neighbor = targetPeer.fsm.peerInfo.Address
-			for idx, path := range pathList {
-				path = server.policy.ApplyPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, filterpath(targetPeer, path), options)
-				if path != nil && !server.bgpConfig.Global.Collector.Enabled {
-					path = path.Clone(path.IsWithdraw)
-					path.UpdatePathAttrs(&server.bgpConfig.Global, &targetPeer.conf)
+			pathList[idx] = path
+		}
+		msgList := server.batchUpdates(targetPeer, pathList)
+
+		msgs = append(msgs, newSenderMsg(targetPeer, msgList))
+		if l := targetPeer.updateDefaultRoutes(sendPathList); len(l) > 0 {
+			msgs = append(msgs, newSenderMsg(targetPeer, l))
+		}
+	}
+	return msgs
+}
+
+// softResetOut sends the peer only the differences between what it
+// has been advertised and what it should receive under the current
+// export policy.
+func (server *BgpServer) softResetOut(peer *Peer, families []bgp.RouteFamily) []*SenderMsg {
+	if peer.isReceiveOnly() {
+		return nil
+	}
+	// the delta from the Adj-RIB-Out covers the held advertisements
+	peer.updateBatch.discardFamilies(families)
+	pathList := peer.getOutboundDelta(families)
+	if len(pathList) == 0 {
+		return nil
+	}
+	return []*SenderMsg{newSenderMsg(peer, peer.advertise(pathList))}
+}
+
+// retainStaleRoutes keeps the routes learned from the peer as stale
+// when the session went down gracefully, until they are re-advertised
+// or the stale routes time passes.
+func (server *BgpServer) retainStaleRoutes(peer *Peer) {
+	rfList := peer.configuredRFlist()
+	peer.adjRibIn.MarkStale(rfList)
+	peer.adjRibOut.Drop(rfList)
+
+	d := peer.fsm.staleRoutesTime()
+	log.WithFields(log.Fields{
+		"Topic":    "Peer",
+		"Key":      peer.conf.Config.NeighborAddress,
+		"Duration": d,
+	}).Info("graceful restart, retain routes as stale")
+	server.armStaleTimer(peer, d)
+}
+
+// armStaleTimer (re)starts the timer to delete the stale routes
+// retained for the peer.
+func (server *BgpServer) armStaleTimer(peer *Peer, d time.Duration) {
+	server.stopStaleTimer(peer)
+	addr := peer.conf.Config.NeighborAddress
+	ch := server.fsmStateCh
+	peer.staleTimer = time.AfterFunc(d, func() {
+		ch <- &FsmMsg{
+			MsgType: FSM_MSG_STALE_TIMER_EXPIRED,
+			MsgSrc:  addr,
+		}
+	})
+}
+
+// stopStaleTimer stops the timer to delete the stale routes retained
+// for the peer, if any.
+func (server *BgpServer) stopStaleTimer(peer *Peer) {
+	if peer.staleTimer != nil {
+		peer.staleTimer.Stop()
+		peer.staleTimer = nil
+	}
+}
+
+// purgeStaleRoutes deletes the stale routes retained for the peer which
+// can't be refreshed in the new session. RFC4724 4.2, those of the
+// families whose forwarding state wasn't preserved are deleted right
+// away. So are those of the families the peer doesn't negotiate any
+// more, which would be kept until the restart timer expires otherwise.
+func (server *BgpServer) purgeStaleRoutes(peer *Peer) []*SenderMsg {
+	rfList := peer.updateGracefulRestartState()
+	if l := peer.unnegotiatedFamilies(); len(l) > 0 {
+		log.WithFields(log.Fields{
+			"Topic":    "Peer",
+			"Key":      peer.conf.Config.NeighborAddress,
+			"Families": l,
+		}).Info("families not negotiated any more, purge stale routes")
+		rfList = append(rfList, l...)
+	}
+	if len(rfList) == 0 {
+		return nil
+	}
+	l := peer.adjRibIn.DropStale(rfList)
+	if len(l) == 0 {
+		return nil
+	}
+	msgs, _ := server.propagateUpdate(peer, l)
+	return msgs
+}
+
+// armWithdrawHold starts the timer to propagate the withdrawals held
+// for the peer unless it's already running.
+func (server *BgpServer) armWithdrawHold(peer *Peer, d time.Duration) {
+	if peer.withdrawHold.timer != nil || len(peer.withdrawHold.held) == 0 {
+		return
+	}
+	addr := peer.conf.Config.NeighborAddress
+	ch := server.fsmStateCh
+	peer.withdrawHold.timer = time.AfterFunc(d, func() {
+		ch <- &FsmMsg{
+			MsgType: FSM_MSG_WITHDRAW_HOLD_EXPIRED,
+			MsgSrc:  addr,
+		}
+	})
+}
+
+// batchUpdates returns the UPDATE messages to send the peer for the
+// paths and records the sent ones in the Adj-RIB-Out. With the batch
+// window configured, the advertisements are held for the window and
+// only the withdrawals are sent now. The held ones are recorded when
+// they are flushed.
+func (server *BgpServer) batchUpdates(peer *Peer, pathList []*table.Path) []*bgp.BGPMessage {
+	if d := time.Duration(peer.conf.Config.UpdateBatchWindow) * time.Millisecond; d > 0 {
+		// the withdrawals of the held paths must reach the batch
+		// before they are filtered by the Adj-RIB-Out
+		pathList = peer.updateBatch.add(pathList)
+		if peer.updateBatch.timer == nil && len(peer.updateBatch.index) > 0 {
+			addr := peer.conf.Config.NeighborAddress
+			ch := server.fsmStateCh
+			peer.updateBatch.timer = time.AfterFunc(d, func() {
+				ch <- &FsmMsg{
+					MsgType: FSM_MSG_UPDATE_BATCH_EXPIRED,
+					MsgSrc:  addr,
 				}
-				pathList[idx] = path
-			}
-			targetPeer.adjRibOut.Update(pathList)
-			msgList := table.CreateUpdateMsgFromPaths(pathList)
+			})
+		}
+	}
+	return peer.advertise(peer.filterUnsentWithdrawals(pathList))
+}
 
-			msgs = append(msgs, newSenderMsg(targetPeer, msgList))
+// releaseAdmission frees the establishing slot of the peer and starts
+// the FSM handlers of the queued peers which can take it.
+func (server *BgpServer) releaseAdmission(peer *Peer) {
+	for _, p := range server.admission.release(server.bgpConfig.Global.Config.MaxEstablishingPeers, peer) {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   p.conf.Config.NeighborAddress,
+		}).Info("admitted to establish the session")
+		p.startFSMHandler(server.fsmincomingCh, server.fsmStateCh)
+	}
+	server.updateAdmissionState()
+}
+
+// dequeueAdmission takes the peer out of the admission queue and closes
+// the connection it was holding, for the peer being deleted, disabled
+// or shut down. It returns false when the peer wasn't queued.
+func (server *BgpServer) dequeueAdmission(peer *Peer) bool {
+	if !server.admission.dequeue(peer) {
+		return false
+	}
+	peer.fsm.conn.Close()
+	server.updateAdmissionState()
+	return true
+}
+
+// prefixOrfMessages returns the ROUTE_REFRESH messages pushing the
+// prefix-set configured for the peer as Address Prefix ORF entries for
+// the families where sending them is negotiated.
+func (server *BgpServer) prefixOrfMessages(peer *Peer) []*bgp.BGPMessage {
+	name := peer.conf.Config.PrefixOrfSet
+	if name == "" {
+		return nil
+	}
+	set, ok := server.policy.DefinedSetMap[table.DEFINED_TYPE_PREFIX][name].(*table.PrefixSet)
+	if !ok {
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   peer.conf.Config.NeighborAddress,
+			"Name":  name,
+		}).Warn("prefix-set for ORF not found")
+		return nil
+	}
+	msgs := make([]*bgp.BGPMessage, 0)
+	for _, rf := range peer.configuredRFlist() {
+		if send, _ := peer.fsm.prefixOrfNegotiated(rf); !send {
+			continue
 		}
+		msgs = append(msgs, bgp.NewBGPRouteRefreshORFMessage(rf, bgp.ORF_WHEN_IMMEDIATE, prefixOrfEntries(set, rf)))
 	}
-	return msgs, alteredPathList
+	return msgs
+}
+
+func (server *BgpServer) updateAdmissionState() {
+	state := &server.bgpConfig.Global.State
+	state.EstablishingPeers, state.QueuedPeers = server.admission.counts()
+}
+
+// continueInitialDumps returns the next chunk of the initial
+// advertisements to the established peers.
+func (server *BgpServer) continueInitialDumps() []*SenderMsg {
+	msgs := make([]*SenderMsg, 0)
+	for _, peer := range server.neighborMap {
+		if peer.initialDump == nil {
+			continue
+		}
+		if l := peer.nextInitialDump(); len(l) > 0 {
+			msgs = append(msgs, newSenderMsg(peer, l))
+		}
+	}
+	return msgs
 }
 
 func (server *BgpServer) handleFSMMessage(peer *Peer, e *FsmMsg) []*SenderMsg {
@@ -944,7 +1376,33 @@ func (server *BgpServer) handleFSMMessage(peer *Peer, e *FsmMsg) []*SenderMsg {
 		nextState := e.MsgData.(bgp.FSMState)
 		oldState := bgp.FSMState(peer.conf.State.SessionState.ToInt())
 		peer.conf.State.SessionState = config.IntToSessionStateMap[int(nextState)]
-		peer.fsm.StateChange(nextState)
+		reason := e.reason
+		peer.fsm.StateChange(nextState, reason)
+
+		admitted := true
+		switch nextState {
+		case bgp.BGP_FSM_OPENSENT:
+			admitted = server.admission.acquire(server.bgpConfig.Global.Config.MaxEstablishingPeers, peer)
+			server.updateAdmissionState()
+		case bgp.BGP_FSM_OPENCONFIRM:
+		default:
+			server.releaseAdmission(peer)
+		}
+
+		if peer.gConf.OscillationDetector.Enabled {
+			ev := oscillationEvent{
+				cause:        classifyOscillation(oldState, reason, peer.fsm.notification),
+				notification: peer.fsm.notification,
+				timestamp:    time.Now(),
+			}
+			if advice := peer.oscillation.record(&peer.gConf, &peer.conf, ev); advice != "" {
+				log.WithFields(log.Fields{
+					"Topic": "Peer",
+					"Key":   peer.conf.Config.NeighborAddress,
+					"Cause": ev.cause,
+				}).Warn(advice)
+			}
+		}
 
 		if oldState == bgp.BGP_FSM_ESTABLISHED {
 			t := time.Now()
@@ -952,9 +1410,21 @@ func (server *BgpServer) handleFSMMessage(peer *Peer, e *FsmMsg) []*SenderMsg {
 				peer.conf.State.Flops++
 			}
 
-			peer.DropAll(peer.configuredRFlist())
+			peer.prefixOrf = nil
+			peer.defaultRoutes = nil
+			peer.initialDump = nil
+			peer.updateBatch.flush()
+			if l := peer.withdrawHold.flush(); len(l) > 0 {
+				m, _ := server.propagateUpdate(peer, l)
+				msgs = append(msgs, m...)
+			}
+			if peer.retainsRoutes(reason) {
+				server.retainStaleRoutes(peer)
+			} else {
+				peer.DropAll(peer.configuredRFlist())
 
-			msgs = append(msgs, server.dropPeerAllRoutes(peer)...)
+				msgs = append(msgs, server.dropPeerAllRoutes(peer)...)
+			}
 		}
 
 		close(peer.outgoing)
@@ -963,10 +1433,38 @@ func (server *BgpServer) handleFSMMessage(peer *Peer, e *FsmMsg) []*SenderMsg {
 			// update for export policy
 			laddr, _ := peer.fsm.LocalHostPort()
 			peer.conf.Transport.Config.LocalAddress = laddr
-			pathList, _ := peer.getBestFromLocal(peer.configuredRFlist())
-			if len(pathList) > 0 {
-				peer.adjRibOut.Update(pathList)
-				msgs = append(msgs, newSenderMsg(peer, table.CreateUpdateMsgFromPaths(pathList)))
+			peer.conf.State.ExtendedNexthop = peer.fsm.extendedNexthopNegotiated()
+			peer.updatePathsLimitState()
+			msgs = append(msgs, server.purgeStaleRoutes(peer)...)
+			if peer.staleTimer != nil {
+				// the peer came back in time. RFC4724 4.2, the
+				// rest of the stale routes are deleted on the
+				// End-of-RIB, or when it doesn't come in time.
+				server.armStaleTimer(peer, peer.fsm.staleRoutesTime())
+			}
+			if l := server.prefixOrfMessages(peer); len(l) > 0 {
+				msgs = append(msgs, newSenderMsg(peer, l))
+			}
+			if peer.isReceiveOnly() {
+				// nothing is advertised but the End-of-RIB lets the
+				// peer consider us converged
+				rfList := peer.configuredRFlist()
+				l := make([]*bgp.BGPMessage, 0, len(rfList))
+				for _, rf := range rfList {
+					if peer.fsm.HasFamily(rf) {
+						l = append(l, bgp.NewEndOfRib(rf))
+					}
+				}
+				msgs = append(msgs, newSenderMsg(peer, l))
+			} else if server.deferral == nil {
+				if l := peer.startInitialDump(); len(l) > 0 {
+					msgs = append(msgs, newSenderMsg(peer, l))
+				}
+			}
+			// the routes are advertised when the deferral ends
+			if server.deferral != nil {
+				delete(server.deferral.endOfRib, peer.conf.Config.NeighborAddress)
+				msgs = append(msgs, server.checkSelectionDeferral()...)
 			}
 		} else {
 			if server.shutdown && nextState == bgp.BGP_FSM_IDLE {
@@ -988,9 +1486,47 @@ func (server *BgpServer) handleFSMMessage(peer *Peer, e *FsmMsg) []*SenderMsg {
 			peer.conf.State = config.NeighborState{}
 			peer.conf.Timers.State = config.TimersState{}
 		}
-		peer.startFSMHandler(server.fsmincomingCh, server.fsmStateCh)
+		if admitted {
+			peer.startFSMHandler(server.fsmincomingCh, server.fsmStateCh)
+		} else {
+			log.WithFields(log.Fields{
+				"Topic":  "Peer",
+				"Key":    peer.conf.Config.NeighborAddress,
+				"Queued": server.bgpConfig.Global.State.QueuedPeers,
+			}).Info("too many peers establishing sessions, wait for a free slot")
+		}
 		server.broadcastPeerState(peer, oldState)
 
+	case FSM_MSG_STALE_TIMER_EXPIRED:
+		peer.staleTimer = nil
+		withdrawn := peer.adjRibIn.DropStale(peer.configuredRFlist())
+		log.WithFields(log.Fields{
+			"Topic": "Peer",
+			"Key":   peer.conf.Config.NeighborAddress,
+			"Count": len(withdrawn),
+		}).Info("restart timer expired, purge stale routes")
+		if len(withdrawn) > 0 {
+			m, _ := server.propagateUpdate(peer, withdrawn)
+			msgs = append(msgs, m...)
+		}
+
+	case FSM_MSG_WITHDRAW_HOLD_EXPIRED:
+		peer.withdrawHold.timer = nil
+		pathList, next := peer.withdrawHold.expire(time.Now())
+		if len(pathList) > 0 {
+			m, _ := server.propagateUpdate(peer, pathList)
+			msgs = append(msgs, m...)
+		}
+		if next > 0 {
+			server.armWithdrawHold(peer, next)
+		}
+
+	case FSM_MSG_UPDATE_BATCH_EXPIRED:
+		peer.updateBatch.timer = nil
+		if l := peer.updateBatch.flush(); len(l) > 0 && peer.fsm.state == bgp.BGP_FSM_ESTABLISHED {
+			msgs = append(msgs, newSenderMsg(peer, peer.advertise(l)))
+		}
+
 	case FSM_MSG_BGP_MESSAGE:
 		switch m := e.MsgData.(type) {
 		case *bgp.MessageError:
@@ -999,7 +1535,7 @@ func (server *BgpServer) handleFSMMessage(peer *Peer, e *FsmMsg) []*SenderMsg {
 			pathList, msgList := peer.handleBGPmessage(e)
 
 			if m.Header.Type == bgp.BGP_MSG_UPDATE && server.watchers.watching(WATCHER_EVENT_UPDATE_MSG) {
-				_, y := peer.fsm.capMap[bgp.BGP_CAP_FOUR_OCTET_AS_NUMBER]
+				y := peer.fsm.HasCapability(bgp.BGP_CAP_FOUR_OCTET_AS_NUMBER)
 				l, _ := peer.fsm.LocalHostPort()
 				ev := &watcherEventUpdateMsg{
 					message:      m,
@@ -1007,7 +1543,7 @@ func (server *BgpServer) handleFSMMessage(peer *Peer, e *FsmMsg) []*SenderMsg {
 					localAS:      peer.fsm.peerInfo.LocalAS,
 					peerAddress:  peer.fsm.peerInfo.Address,
 					localAddress: net.ParseIP(l),
-					peerID:       peer.fsm.peerInfo.ID,
+					peerID:       peer.fsm.PeerID(),
 					fourBytesAs:  y,
 					timestamp:    e.timestamp,
 					payload:      e.payload,
@@ -1021,18 +1557,49 @@ func (server *BgpServer) handleFSMMessage(peer *Peer, e *FsmMsg) []*SenderMsg {
 				msgs = append(msgs, newSenderMsg(peer, msgList))
 			}
 
+			var stale []*table.Path
+			if e.endOfRib != 0 {
+				// RFC4724 4.2, the routes the peer didn't
+				// advertise again before the End-of-RIB are gone
+				stale = peer.adjRibIn.DropStale([]bgp.RouteFamily{e.endOfRib})
+				if len(stale) > 0 {
+					log.WithFields(log.Fields{
+						"Topic":  "Peer",
+						"Key":    peer.conf.Config.NeighborAddress,
+						"Family": e.endOfRib,
+						"Count":  len(stale),
+					}).Info("End-of-RIB received, purge stale routes")
+				}
+			}
+
+			if server.deferral != nil {
+				// the routes are kept in the Adj-RIB-In and
+				// selected when the deferral ends
+				pathList, stale = nil, nil
+				if e.endOfRib != 0 {
+					server.deferral.recordEndOfRib(peer, e.endOfRib)
+					msgs = append(msgs, server.checkSelectionDeferral()...)
+				}
+			}
+
+			if d := time.Duration(peer.conf.Config.WithdrawHoldTime) * time.Millisecond; d > 0 {
+				pathList = peer.withdrawHold.filter(pathList, d, time.Now())
+				server.armWithdrawHold(peer, d)
+			}
+			pathList = append(pathList, stale...)
+
 			if len(pathList) > 0 {
 				m, altered := server.propagateUpdate(peer, pathList)
 				msgs = append(msgs, m...)
 				if server.watchers.watching(WATCHER_EVENT_POST_POLICY_UPDATE_MSG) {
-					_, y := peer.fsm.capMap[bgp.BGP_CAP_FOUR_OCTET_AS_NUMBER]
+					y := peer.fsm.HasCapability(bgp.BGP_CAP_FOUR_OCTET_AS_NUMBER)
 					l, _ := peer.fsm.LocalHostPort()
 					ev := &watcherEventUpdateMsg{
 						peerAS:       peer.fsm.peerInfo.AS,
 						localAS:      peer.fsm.peerInfo.LocalAS,
 						peerAddress:  peer.fsm.peerInfo.Address,
 						localAddress: net.ParseIP(l),
-						peerID:       peer.fsm.peerInfo.ID,
+						peerID:       peer.fsm.PeerID(),
 						fourBytesAs:  y,
 						timestamp:    e.timestamp,
 						postPolicy:   true,
@@ -1078,10 +1645,47 @@ func (server *BgpServer) PeerUpdate(peer config.Neighbor) {
 	server.updatedPeerCh <- peer
 }
 
+// InjectNotification sends a NOTIFICATION message with the code, the
+// subcode and the data to the established neighbor, which resets the
+// session. It's meant for testing how the neighbor handles the errors.
+func (server *BgpServer) InjectNotification(addr string, code, subcode uint8, data []byte) error {
+	req := NewGrpcRequest(REQ_NEIGHBOR_INJECT_NOTIFICATION, addr, bgp.RouteFamily(0), &bgp.BGPNotification{
+		ErrorCode:    code,
+		ErrorSubcode: subcode,
+		Data:         data,
+	})
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	return res.ResponseErr
+}
+
+// SendKeepalive sends a KEEPALIVE message to the established neighbor
+// immediately, regardless of the keepalive interval, to check if the
+// session survives.
+func (server *BgpServer) SendKeepalive(addr string) error {
+	req := NewGrpcRequest(REQ_NEIGHBOR_SEND_KEEPALIVE, addr, bgp.RouteFamily(0), nil)
+	server.GrpcReqCh <- req
+	res := <-req.ResponseCh
+	return res.ResponseErr
+}
+
 func (server *BgpServer) Shutdown() {
+	server.shutdownCh <- struct{}{}
+}
+
+// handleShutdown brings all the peers down. The server exits when all of
+// them are idle.
+func (server *BgpServer) handleShutdown() {
 	server.shutdown = true
 	for _, p := range server.neighborMap {
-		p.fsm.adminStateCh <- ADMIN_STATE_DOWN
+		if server.dequeueAdmission(p) {
+			p.startFSMHandler(server.fsmincomingCh, server.fsmStateCh)
+		}
+		select {
+		case p.fsm.adminStateCh <- ADMIN_STATE_DOWN:
+		default:
+			log.Warning("previous request is still remaining. : ", p.conf.Config.NeighborAddress)
+		}
 	}
 }
 
@@ -1089,7 +1693,7 @@ func (server *BgpServer) UpdatePolicy(policy config.RoutingPolicy) {
 	server.policyUpdateCh <- policy
 }
 
-func (server *BgpServer) setPolicyByConfig(id string, c config.ApplyPolicy) {
+func (server *BgpServer) setPolicyByConfig(id string, c config.ApplyPolicy, afiSafis []config.AfiSafi) {
 	for _, dir := range []table.PolicyDirection{table.POLICY_DIRECTION_IN, table.POLICY_DIRECTION_IMPORT, table.POLICY_DIRECTION_EXPORT} {
 		ps, def, err := server.policy.GetAssignmentFromConfig(dir, c)
 		if err != nil {
@@ -1099,7 +1703,16 @@ func (server *BgpServer) setPolicyByConfig(id string, c config.ApplyPolicy) {
 			}).Errorf("failed to get policy info: %s", err)
 			continue
 		}
+		defs, err := server.policy.GetFamilyDefaultsFromConfig(dir, afiSafis)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Topic": "Policy",
+				"Dir":   dir,
+			}).Errorf("failed to get family default policy: %s", err)
+			continue
+		}
 		server.policy.SetDefaultPolicy(id, dir, def)
+		server.policy.SetFamilyDefaultPolicies(id, dir, defs)
 		server.policy.SetPolicy(id, dir, ps)
 	}
 }
@@ -1111,7 +1724,7 @@ func (server *BgpServer) SetRoutingPolicy(pl config.RoutingPolicy) error {
 		}).Errorf("failed to create routing policy: %s", err)
 		return err
 	}
-	server.setPolicyByConfig(table.GLOBAL_RIB_NAME, server.bgpConfig.Global.ApplyPolicy)
+	server.setPolicyByConfig(table.GLOBAL_RIB_NAME, server.bgpConfig.Global.ApplyPolicy, server.bgpConfig.Global.AfiSafis)
 	return nil
 }
 
@@ -1127,7 +1740,7 @@ func (server *BgpServer) handlePolicy(pl config.RoutingPolicy) error {
 			"Topic": "Peer",
 			"Key":   peer.conf.Config.NeighborAddress,
 		}).Info("call set policy")
-		server.setPolicyByConfig(peer.ID(), peer.conf.ApplyPolicy)
+		server.setPolicyByConfig(peer.ID(), peer.conf.ApplyPolicy, peer.conf.AfiSafis)
 	}
 	return nil
 }
@@ -1235,10 +1848,7 @@ func (server *BgpServer) Api2PathList(resource api.Resource, name string, ApiPat
 				LocalID: net.ParseIP(path.SourceId),
 			}
 		} else {
-			pi = &table.PeerInfo{
-				AS:      server.bgpConfig.Global.Config.As,
-				LocalID: net.ParseIP(server.bgpConfig.Global.Config.RouterId).To4(),
-			}
+			pi = table.NewLocalPeerInfo(&server.bgpConfig.Global)
 		}
 
 		if len(path.Nlri) > 0 {
@@ -1444,10 +2054,7 @@ func (server *BgpServer) handleVrfMod(arg *api.ModVrfArguments) ([]*table.Path,
 		if err != nil {
 			return nil, err
 		}
-		pi := &table.PeerInfo{
-			AS:      server.bgpConfig.Global.Config.As,
-			LocalID: net.ParseIP(server.bgpConfig.Global.Config.RouterId).To4(),
-		}
+		pi := table.NewLocalPeerInfo(&server.bgpConfig.Global)
 		msgs, err = rib.AddVrf(arg.Vrf.Name, rd, importRt, exportRt, pi)
 		if err != nil {
 			return nil, err
@@ -1805,6 +2412,9 @@ func (server *BgpServer) handleGrpc(grpcReq *GrpcRequest) []*SenderMsg {
 		}
 
 		rf := bgp.RouteFamily(arg.Family)
+		// the paths rejected by the in policy are marked with the
+		// neighbor's ID, not the table ID.
+		id := peer.ID()
 		var paths []*table.Path
 		if grpcReq.RequestType == REQ_ADJ_RIB_IN {
 			paths = peer.adjRibIn.PathList([]bgp.RouteFamily{rf}, false)
@@ -1834,11 +2444,11 @@ func (server *BgpServer) handleGrpc(grpcReq *GrpcRequest) []*SenderMsg {
 					if b == nil {
 						r.Insert(table.CidrToRadixkey(key), &api.Destination{
 							Prefix: key,
-							Paths:  []*api.Path{p.ToApiStruct(peer.TableID())},
+							Paths:  []*api.Path{p.ToApiStruct(id)},
 						})
 					} else {
 						d := b.(*api.Destination)
-						d.Paths = append(d.Paths, p.ToApiStruct(peer.TableID()))
+						d.Paths = append(d.Paths, p.ToApiStruct(id))
 					}
 				}
 			}
@@ -1850,7 +2460,7 @@ func (server *BgpServer) handleGrpc(grpcReq *GrpcRequest) []*SenderMsg {
 			for _, p := range paths {
 				results = append(results, &api.Destination{
 					Prefix: p.GetNlri().String(),
-					Paths:  []*api.Path{p.ToApiStruct(peer.TableID())},
+					Paths:  []*api.Path{p.ToApiStruct(id)},
 				})
 			}
 		}
@@ -1902,6 +2512,60 @@ func (server *BgpServer) handleGrpc(grpcReq *GrpcRequest) []*SenderMsg {
 		grpcReq.ResponseCh <- &GrpcResponse{}
 		close(grpcReq.ResponseCh)
 
+	case REQ_NEIGHBOR_INJECT_NOTIFICATION:
+		peer, err := server.checkNeighborRequest(grpcReq)
+		if err != nil {
+			break
+		}
+		m, err := peer.injectedNotification(grpcReq.Data.(*bgp.BGPNotification))
+		if err == nil {
+			msgs = append(msgs, newSenderMsg(peer, []*bgp.BGPMessage{m}))
+		}
+		grpcReq.ResponseCh <- &GrpcResponse{
+			ResponseErr: err,
+		}
+		close(grpcReq.ResponseCh)
+
+	case REQ_NEIGHBOR_PREFIX_ORF:
+		peer, err := server.checkNeighborRequest(grpcReq)
+		if err != nil {
+			break
+		}
+		logOp(grpcReq.Name, "Neighbor prefix ORF")
+		req := grpcReq.Data.(*prefixOrfRequest)
+		m, err := peer.prefixOrfMessage(grpcReq.RouteFamily, req.when, req.entries)
+		if err == nil {
+			msgs = append(msgs, newSenderMsg(peer, []*bgp.BGPMessage{m}))
+		}
+		grpcReq.ResponseCh <- &GrpcResponse{
+			ResponseErr: err,
+		}
+		close(grpcReq.ResponseCh)
+
+	case REQ_NEIGHBOR_TIMERS:
+		peer, err := server.checkNeighborRequest(grpcReq)
+		if err != nil {
+			break
+		}
+		grpcReq.ResponseCh <- &GrpcResponse{
+			Data: peer.fsm.Timers(),
+		}
+		close(grpcReq.ResponseCh)
+
+	case REQ_NEIGHBOR_FAMILIES:
+		peer, err := server.checkNeighborRequest(grpcReq)
+		if err != nil {
+			break
+		}
+		logOp(grpcReq.Name, "Neighbor families update")
+		result, m, err := server.setNeighborFamilies(peer, grpcReq.Data.([]bgp.RouteFamily))
+		msgs = append(msgs, m...)
+		grpcReq.ResponseCh <- &GrpcResponse{
+			ResponseErr: err,
+			Data:        result,
+		}
+		close(grpcReq.ResponseCh)
+
 	case REQ_NEIGHBOR_SOFT_RESET, REQ_NEIGHBOR_SOFT_RESET_IN:
 		peers, err := reqToPeers(grpcReq)
 		if err != nil {
@@ -1958,34 +2622,25 @@ func (server *BgpServer) handleGrpc(grpcReq *GrpcRequest) []*SenderMsg {
 			if families[0] == bgp.RouteFamily(0) {
 				families = peer.configuredRFlist()
 			}
-			sentPathList := peer.adjRibOut.PathList(families, false)
-			peer.adjRibOut.Drop(families)
-			pathList, filtered := peer.getBestFromLocal(families)
-			if len(pathList) > 0 {
-				peer.adjRibOut.Update(pathList)
-				msgs = append(msgs, newSenderMsg(peer, table.CreateUpdateMsgFromPaths(pathList)))
-			}
-			if len(filtered) > 0 {
-				withdrawnList := make([]*table.Path, 0, len(filtered))
-				for _, p := range filtered {
-					found := false
-					for _, sentPath := range sentPathList {
-						if p.GetNlri() == sentPath.GetNlri() {
-							found = true
-							break
-						}
-					}
-					if found {
-						p.IsWithdraw = true
-						withdrawnList = append(withdrawnList, p)
-					}
-				}
-				msgs = append(msgs, newSenderMsg(peer, table.CreateUpdateMsgFromPaths(withdrawnList)))
-			}
+			msgs = append(msgs, server.softResetOut(peer, families)...)
 		}
 		grpcReq.ResponseCh <- &GrpcResponse{}
 		close(grpcReq.ResponseCh)
 
+	case REQ_NEIGHBOR_SEND_KEEPALIVE:
+		peer, err := server.checkNeighborRequest(grpcReq)
+		if err != nil {
+			break
+		}
+		m, err := peer.keepaliveProbe()
+		if err == nil {
+			msgs = append(msgs, newSenderMsg(peer, []*bgp.BGPMessage{m}))
+		}
+		grpcReq.ResponseCh <- &GrpcResponse{
+			ResponseErr: err,
+		}
+		close(grpcReq.ResponseCh)
+
 	case REQ_NEIGHBOR_ENABLE, REQ_NEIGHBOR_DISABLE:
 		peer, err1 := server.checkNeighborRequest(grpcReq)
 		if err1 != nil {
@@ -2008,6 +2663,10 @@ func (server *BgpServer) handleGrpc(grpcReq *GrpcRequest) []*SenderMsg {
 				err.Msg = "previous request is still remaining"
 			}
 		} else {
+			// the queued peer needs its FSM handler to go down
+			if server.dequeueAdmission(peer) {
+				peer.startFSMHandler(server.fsmincomingCh, server.fsmStateCh)
+			}
 			select {
 			case peer.fsm.adminStateCh <- ADMIN_STATE_DOWN:
 				log.WithFields(log.Fields{
@@ -2086,7 +2745,7 @@ func (server *BgpServer) handleGrpc(grpcReq *GrpcRequest) []*SenderMsg {
 			ResponseErr: err,
 		}
 		close(grpcReq.ResponseCh)
-	case REQ_MONITOR_GLOBAL_BEST_CHANGED, REQ_MONITOR_NEIGHBOR_PEER_STATE, REQ_MONITOR_ROA_VALIDATION_RESULT:
+	case REQ_MONITOR_GLOBAL_BEST_CHANGED, REQ_MONITOR_NEIGHBOR_PEER_STATE, REQ_MONITOR_ROA_VALIDATION_RESULT, REQ_MONITOR_ROUTE_CHANGE:
 		server.broadcastReqs = append(server.broadcastReqs, grpcReq)
 	case REQ_MONITOR_INCOMING:
 		if grpcReq.Name != "" {
@@ -2111,6 +2770,18 @@ func (server *BgpServer) handleGrpc(grpcReq *GrpcRequest) []*SenderMsg {
 		if len(pathList) > 0 {
 			msgs, _ = server.propagateUpdate(nil, pathList)
 		}
+	case REQ_NEXTHOP_HOLD_DOWN:
+		grpcReq.ResponseCh <- &GrpcResponse{
+			Data: server.nexthopHoldDownStates(),
+		}
+		close(grpcReq.ResponseCh)
+	case REQ_METRICS:
+		grpcReq.ResponseCh <- &GrpcResponse{
+			Data: server.metrics(),
+		}
+		close(grpcReq.ResponseCh)
+	case REQ_MONITOR_RIB_JOURNAL, REQ_RIB_JOURNAL, REQ_RIB_SNAPSHOT:
+		server.handleRibJournalRequest(grpcReq)
 	default:
 		err = fmt.Errorf("Unknown request type: %v", grpcReq.RequestType)
 		goto ERROR
@@ -2160,8 +2831,12 @@ func (server *BgpServer) handleGrpcModNeighbor(grpcReq *GrpcRequest) (sMsgs []*S
 
 	switch arg.Operation {
 	case api.Operation_ADD:
-		if ok {
+		if ok || server.startup.find(addr) >= 0 {
 			return nil, fmt.Errorf("Can't overwrite the exising peer %s", addr)
+		} else if max := server.bgpConfig.Global.Config.MaxPeers; max > 0 && len(server.neighborMap) >= int(max) {
+			return nil, fmt.Errorf("Can't add the peer %s over max-peers %d", addr, max)
+		} else if config.ParseAddress(addr) == nil {
+			return nil, fmt.Errorf("invalid neighbor address %q", addr)
 		} else {
 			log.Infof("Peer %s is added", addr)
 		}
@@ -2257,30 +2932,15 @@ func (server *BgpServer) handleGrpcModNeighbor(grpcReq *GrpcRequest) (sMsgs []*S
 		if err != nil {
 			return nil, err
 		}
-		peer := NewPeer(server.bgpConfig.Global, configneigh, server.globalRib, server.policy)
-		server.setPolicyByConfig(peer.ID(), configneigh.ApplyPolicy)
-		if peer.isRouteServerClient() {
-			pathList := make([]*table.Path, 0)
-			rfList := peer.configuredRFlist()
-			for _, p := range server.neighborMap {
-				if !p.isRouteServerClient() {
-					continue
-				}
-				pathList = append(pathList, p.getAccepted(rfList)...)
-			}
-			moded := server.RSimportPaths(peer, pathList)
-			if len(moded) > 0 {
-				server.globalRib.ProcessPaths(moded)
-			}
-		}
-		server.neighborMap[addr] = peer
-		peer.startFSMHandler(server.fsmincomingCh, server.fsmStateCh)
-		server.broadcastPeerState(peer, bgp.BGP_FSM_IDLE)
+		// the peer added by the operator is started right away
+		server.startPeer(configneigh)
 	case api.Operation_DEL:
 		for _, l := range server.Listeners(addr) {
 			SetTcpMD5SigSockopts(l, addr, "")
 		}
 		log.Info("Delete a peer configuration for ", addr)
+		server.dequeueAdmission(n)
+		server.stopStaleTimer(n)
 		go func(addr string) {
 			t := time.AfterFunc(time.Minute*5, func() { log.Fatal("failed to free the fsm.h.t for ", addr) })
 			n.fsm.h.t.Kill(nil)
@@ -2291,11 +2951,13 @@ func (server *BgpServer) handleGrpcModNeighbor(grpcReq *GrpcRequest) (sMsgs []*S
 			n.fsm.t.Wait()
 			t.Stop()
 		}(addr)
+		server.releaseAdmission(n)
 		m := server.dropPeerAllRoutes(n)
 		if len(m) > 0 {
 			sMsgs = append(sMsgs, m...)
 		}
 		delete(server.neighborMap, addr)
+		server.startPendingPeers()
 	}
 	return sMsgs, err
 }
@@ -2637,7 +3299,9 @@ func (server *BgpServer) handleModMrt(grpcReq *GrpcRequest) {
 	}
 	switch arg.Operation {
 	case api.Operation_ADD:
-		w, err := newMrtWatcher(arg.Filename)
+		c := server.bgpConfig.Global.Mrt
+		c.FileName = arg.Filename
+		w, err := newMrtWatcher(c)
 		if err == nil {
 			server.watchers[WATCHER_MRT] = w
 		}
@@ -2822,7 +3486,7 @@ func (server *BgpServer) handleMrt(grpcReq *GrpcRequest) {
 func (server *BgpServer) mkMrtPeerIndexTableMsg(t uint32, view string) (*bgp.MRTMessage, error) {
 	peers := make([]*bgp.Peer, 0, len(server.neighborMap))
 	for _, peer := range server.neighborMap {
-		id := peer.fsm.peerInfo.ID.To4().String()
+		id := peer.fsm.PeerID().To4().String()
 		ipaddr := peer.conf.Config.NeighborAddress
 		asn := peer.conf.Config.PeerAs
 		peers = append(peers, bgp.NewPeer(id, ipaddr, asn, true))
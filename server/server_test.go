@@ -0,0 +1,796 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFilterpathToSource(t *testing.T) {
+	assert := assert.New(t)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	newPeer := func(addr string, as uint32, rs bool) *Peer {
+		n := config.Neighbor{Config: config.NeighborConfig{NeighborAddress: addr, PeerAs: as}}
+		n.RouteServer.Config.RouteServerClient = rs
+		p := NewPeer(g, n, nil, table.NewRoutingPolicy())
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		return p
+	}
+	path := table.NewPath(&table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}, time.Now(), false)
+
+	for _, rs := range []bool{false, true} {
+		source := newPeer("10.0.0.1", 65001, rs)
+		other := newPeer("10.0.0.2", 65002, rs)
+		assert.Nil(filterpath(source, path))
+		assert.Equal(path, filterpath(other, path))
+
+		source.conf.Config.AdvertiseToSource = true
+		if rs {
+			assert.Equal(path, filterpath(source, path))
+		} else {
+			// still dropped by the AS loop check
+			assert.Nil(filterpath(source, path))
+		}
+	}
+}
+
+func TestFilterpathUnusableNexthop(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	source := newTestPeer(server, testNeighbor("10.0.0.1", 65001), rfList)
+	n := testNeighbor("10.0.0.2", 65000)
+	n.Transport.Config.LocalAddress = "10.0.0.254"
+	n.Config.UnusableNexthopAction = config.UNUSABLE_NEXTHOP_ACTION_TYPE_REJECT
+	target := newTestPeer(server, n, rfList)
+	newPath := func(nexthop string) *table.Path {
+		return newTestPath(source.fsm.peerInfo, "10.10.10.0/24", false, bgp.NewPathAttributeNextHop(nexthop))
+	}
+
+	path := newPath("10.0.0.1")
+	assert.Equal(path, filterpath(target, path))
+	for _, nexthop := range []string{"0.0.0.0", "10.0.0.254", "127.0.0.1"} {
+		path := newPath(nexthop)
+		assert.True(filterpath(target, path).IsWithdraw, nexthop)
+		assert.False(path.IsWithdraw)
+	}
+
+	// the advertised path is withdrawn when the nexthop becomes unusable
+	server.propagateUpdate(source, []*table.Path{newPath("10.0.0.1")})
+	assert.Equal(1, target.adjRibOut.Count(rfList))
+	server.propagateUpdate(source, []*table.Path{newPath("0.0.0.0")})
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+
+	// the nexthop is rewritten by UpdatePathAttrs
+	target.conf.Config.UnusableNexthopAction = config.UNUSABLE_NEXTHOP_ACTION_TYPE_SELF
+	path = newPath("0.0.0.0")
+	assert.Equal(path, filterpath(target, path))
+	target.conf.Config.UnusableNexthopAction = config.UNUSABLE_NEXTHOP_ACTION_TYPE_REJECT
+	target.conf.Config.AttributeTransparency = true
+	assert.Equal(path, filterpath(target, path))
+}
+
+func TestFilterpathRequiredCommunity(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	source := newTestPeer(server, testNeighbor("10.0.0.1", 65001), rfList)
+	n := testNeighbor("10.0.0.2", 65002)
+	n.Config.RequiredCommunityList = []string{"65000:100", "65000:1:100", "invalid", "65000:1:x"}
+	p := newTestPeer(server, n, rfList)
+	assert.Equal([]uint32{65000<<16 | 100}, p.requiredCommunities)
+	assert.Equal([]*bgp.LargeCommunity{bgp.NewLargeCommunity(65000, 1, 100)}, p.requiredLarge)
+
+	path := func(withdraw bool, communities ...uint32) *table.Path {
+		if len(communities) == 0 {
+			return newTestPath(source.fsm.peerInfo, "10.10.10.0/24", withdraw)
+		}
+		return newTestPath(source.fsm.peerInfo, "10.10.10.0/24", withdraw, bgp.NewPathAttributeCommunities(communities))
+	}
+	large := func(c *bgp.LargeCommunity) *table.Path {
+		return newTestPath(source.fsm.peerInfo, "10.10.10.0/24", false, bgp.NewPathAttributeLargeCommunities([]*bgp.LargeCommunity{c}))
+	}
+	withdrawn := func(msgs []*SenderMsg) int {
+		count := 0
+		for _, m := range msgs {
+			if m.destination != p.conf.Config.NeighborAddress {
+				continue
+			}
+			for _, msg := range m.messages {
+				count += len(msg.Body.(*bgp.BGPUpdate).WithdrawnRoutes)
+			}
+		}
+		return count
+	}
+
+	assert.False(filterpath(p, path(false, 65000<<16|200, 65000<<16|100)).IsWithdraw)
+	assert.True(filterpath(p, path(false, 65000<<16|200)).IsWithdraw)
+	assert.True(filterpath(p, path(false)).IsWithdraw)
+	assert.False(filterpath(p, large(bgp.NewLargeCommunity(65000, 1, 100))).IsWithdraw)
+	assert.True(filterpath(p, large(bgp.NewLargeCommunity(65000, 1, 200))).IsWithdraw)
+	// the withdrawal isn't suppressed
+	assert.NotNil(filterpath(p, path(true)))
+
+	// the advertised path is withdrawn when the best path loses the
+	// community, the withdrawal of the prefix never advertised isn't
+	// sent
+	msgs, _ := server.propagateUpdate(source, []*table.Path{path(false)})
+	assert.Equal(0, withdrawn(msgs))
+	server.propagateUpdate(source, []*table.Path{path(false, 65000<<16|100)})
+	assert.Equal(1, p.adjRibOut.Count(rfList))
+	msgs, _ = server.propagateUpdate(source, []*table.Path{path(false)})
+	assert.Equal(1, withdrawn(msgs))
+	assert.Equal(0, p.adjRibOut.Count(rfList))
+
+	// nor is it advertised by a soft reset
+	assert.Equal(0, len(server.softResetOut(p, rfList)))
+
+	p.conf.Config.RequiredCommunityList = nil
+	assert.False(filterpath(p, path(false)).IsWithdraw)
+}
+
+func TestSetNeighborFamilies(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	server.globalRib = table.NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC}, 0, 0)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{
+		Config: config.NeighborConfig{NeighborAddress: "10.0.0.1", PeerAs: 65001},
+		AfiSafis: []config.AfiSafi{{
+			AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST,
+			Config:      config.AfiSafiConfig{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST, Enabled: true},
+		}},
+	}
+	p := NewPeer(g, n, server.globalRib, server.policy)
+	p.adjRibIn = table.NewAdjRib(p.ID(), p.configuredRFlist())
+	p.adjRibOut = table.NewAdjRib(p.ID(), p.configuredRFlist())
+
+	_, _, err := server.setNeighborFamilies(p, nil)
+	assert.NotNil(err)
+	// not enabled globally
+	_, _, err = server.setNeighborFamilies(p, []bgp.RouteFamily{bgp.RF_EVPN})
+	assert.NotNil(err)
+	_, _, err = server.setNeighborFamilies(p, []bgp.RouteFamily{bgp.RouteFamily(0)})
+	assert.NotNil(err)
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv4_UC}, p.configuredRFlist())
+
+	r, _, err := server.setNeighborFamilies(p, []bgp.RouteFamily{bgp.RF_IPv6_UC})
+	assert.Nil(err)
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv6_UC}, r.Added)
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv4_UC}, r.Removed)
+	assert.False(r.ResetRequired)
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv6_UC}, p.configuredRFlist())
+	assert.Equal(p.conf.AfiSafis, p.fsm.pConf.AfiSafis)
+	// the FSM goroutine reads its own copy
+	p.conf.AfiSafis[0].State.PathsLimit = 10
+	assert.Equal(uint16(0), p.fsm.pConf.AfiSafis[0].State.PathsLimit)
+
+	r, msgs, err := server.setNeighborFamilies(p, []bgp.RouteFamily{bgp.RF_IPv6_UC})
+	assert.Nil(err)
+	assert.Equal(0, len(r.Added)+len(r.Removed))
+	assert.Equal(0, len(msgs))
+
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	p.fsm.rfMap[bgp.RF_IPv6_UC] = true
+	r, msgs, err = server.setNeighborFamilies(p, []bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC, bgp.RF_IPv4_UC})
+	assert.Nil(err)
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv4_UC}, r.Added)
+	assert.Equal(0, len(r.Removed))
+	assert.True(r.ResetRequired)
+	assert.Equal(1, len(msgs))
+	assert.Equal(uint8(bgp.BGP_MSG_NOTIFICATION), msgs[0].messages[0].Header.Type)
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC}, p.configuredRFlist())
+}
+
+func TestReceiveOnly(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server.globalRib = table.NewTableManager(rfList, 0, 0)
+	server.bgpConfig.Global.Collector.ReceiveOnly = true
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IMPORT, table.ROUTE_TYPE_ACCEPT)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	g.Collector.ReceiveOnly = true
+	newPeer := func(addr string, as uint32) *Peer {
+		n := config.Neighbor{Config: config.NeighborConfig{NeighborAddress: addr, PeerAs: as}}
+		p := NewPeer(g, n, server.globalRib, server.policy)
+		p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		p.adjRibIn = table.NewAdjRib(p.ID(), rfList)
+		p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+		server.neighborMap[addr] = p
+		return p
+	}
+	source := newPeer("10.0.0.1", 65001)
+	target := newPeer("10.0.0.2", 65002)
+	assert.True(target.isReceiveOnly())
+
+	path := table.NewPath(source.fsm.peerInfo, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}, time.Now(), false)
+	msgs, _ := server.propagateUpdate(source, []*table.Path{path})
+	assert.Equal(0, len(msgs))
+	assert.Equal(1, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, rfList)))
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+	assert.Nil(server.softResetOut(target, rfList))
+
+	assert.Equal(0, len(server.dropPeerAllRoutes(source)))
+}
+
+func TestNexthopOnlyChange(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server.globalRib = table.NewTableManager(rfList, 0, 0)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IMPORT, table.ROUTE_TYPE_ACCEPT)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	server.bgpConfig.Global.Config = config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}
+	newPeer := func(addr string, as uint32) *Peer {
+		n := config.Neighbor{Config: config.NeighborConfig{NeighborAddress: addr, PeerAs: as}}
+		p := NewPeer(server.bgpConfig.Global, n, server.globalRib, server.policy)
+		p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+		p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+		p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+		server.neighborMap[addr] = p
+		return p
+	}
+	source := newPeer("10.0.0.1", 65001)
+	ibgp := newPeer("10.0.0.2", 65000)
+
+	path := func(nexthop string) *table.Path {
+		return table.NewPath(source.fsm.peerInfo, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop(nexthop),
+		}, time.Now(), false)
+	}
+	advertised := func(msgs []*SenderMsg) []string {
+		l := make([]string, 0)
+		for _, m := range msgs {
+			if m.destination != ibgp.ID() {
+				continue
+			}
+			for _, msg := range m.messages {
+				for _, a := range msg.Body.(*bgp.BGPUpdate).PathAttributes {
+					if n, ok := a.(*bgp.PathAttributeNextHop); ok {
+						l = append(l, n.Value.String())
+					}
+				}
+			}
+		}
+		return l
+	}
+
+	msgs, _ := server.propagateUpdate(source, []*table.Path{path("10.0.0.1")})
+	assert.Equal([]string{"10.0.0.1"}, advertised(msgs))
+
+	// the same attributes except the next hop are advertised again
+	// with the new next hop
+	msgs, _ = server.propagateUpdate(source, []*table.Path{path("10.0.0.3")})
+	assert.Equal([]string{"10.0.0.3"}, advertised(msgs))
+
+	// the soft reset compares the next hop advertised to the peer too
+	sent := ibgp.adjRibOut.PathList(rfList, false)
+	assert.Equal(1, len(sent))
+	server.globalRib.ProcessPaths([]*table.Path{path("10.0.0.4")})
+	delta := ibgp.getOutboundDelta(rfList)
+	assert.Equal(1, len(delta))
+	assert.Equal("10.0.0.4", delta[0].GetNexthop().String())
+}
+
+func TestPurgeStaleVPNv4(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_VPN}
+	server.globalRib = table.NewTableManager(rfList, 0, 0)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IMPORT, table.ROUTE_TYPE_ACCEPT)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	newPeer := func(addr string, as uint32) *Peer {
+		n := config.Neighbor{
+			Config: config.NeighborConfig{NeighborAddress: addr, PeerAs: as, PeerType: config.PEER_TYPE_EXTERNAL},
+			AfiSafis: []config.AfiSafi{{
+				AfiSafiName: config.AFI_SAFI_TYPE_L3VPN_IPV4_UNICAST,
+				Config:      config.AfiSafiConfig{AfiSafiName: config.AFI_SAFI_TYPE_L3VPN_IPV4_UNICAST, Enabled: true},
+			}},
+		}
+		p := NewPeer(g, n, server.globalRib, server.policy)
+		p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+		p.fsm.rfMap[bgp.RF_IPv4_VPN] = true
+		p.adjRibIn = table.NewAdjRib(p.ID(), rfList)
+		p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+		server.neighborMap[addr] = p
+		return p
+	}
+	source := newPeer("10.0.0.1", 65001)
+	target := newPeer("10.0.0.2", 65002)
+
+	// the same prefix under two route distinguishers
+	nlri := bgp.NewLabeledVPNIPAddrPrefix(24, "10.10.10.0", *bgp.NewMPLSLabelStack(100), bgp.NewRouteDistinguisherTwoOctetAS(65001, 100))
+	other := bgp.NewLabeledVPNIPAddrPrefix(24, "10.10.10.0", *bgp.NewMPLSLabelStack(200), bgp.NewRouteDistinguisherTwoOctetAS(65001, 200))
+	update := bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeMpReachNLRI("10.0.0.1", []bgp.AddrPrefixInterface{nlri, other}),
+	}, nil)
+	pathList := table.ProcessMessage(update, source.fsm.peerInfo, time.Now())
+	source.adjRibIn.Update(pathList)
+	server.propagateUpdate(source, pathList)
+	assert.Equal(2, source.adjRibIn.Count(rfList))
+	assert.Equal(2, target.adjRibOut.Count(rfList))
+
+	// the session went down gracefully and the restart timer expired
+	source.adjRibIn.MarkStale(rfList)
+	var sent []*bgp.BGPMessage
+	for _, m := range server.handleFSMMessage(source, &FsmMsg{
+		MsgType: FSM_MSG_STALE_TIMER_EXPIRED,
+		MsgSrc:  source.conf.Config.NeighborAddress,
+	}) {
+		if m.destination == target.conf.Config.NeighborAddress {
+			sent = append(sent, m.messages...)
+		}
+	}
+	assert.Equal(0, source.adjRibIn.Count(rfList))
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+	var l []string
+	for _, msg := range sent {
+		buf, err := msg.Serialize()
+		assert.Nil(err)
+		m, err := bgp.ParseBGPMessage(buf)
+		assert.Nil(err)
+		for _, a := range m.Body.(*bgp.BGPUpdate).PathAttributes {
+			assert.NotEqual(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI, a.GetType())
+			if u, ok := a.(*bgp.PathAttributeMpUnreachNLRI); ok {
+				for _, p := range u.Value {
+					l = append(l, p.String())
+				}
+			}
+		}
+	}
+	assert.Equal(2, len(l))
+	assert.Contains(l, nlri.String())
+	assert.Contains(l, other.String())
+}
+
+func TestPurgeStaleUnnegotiatedFamily(t *testing.T) {
+	assert := assert.New(t)
+	server := NewBgpServer()
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC}
+	server.globalRib = table.NewTableManager(rfList, 0, 0)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IMPORT, table.ROUTE_TYPE_ACCEPT)
+	server.policy.SetDefaultPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, table.ROUTE_TYPE_ACCEPT)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{
+		Config: config.NeighborConfig{NeighborAddress: "10.0.0.1", PeerAs: 65001, PeerType: config.PEER_TYPE_EXTERNAL},
+		AfiSafis: []config.AfiSafi{
+			{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST},
+			{AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST},
+		},
+	}
+	n.GracefulRestart.Config.Enabled = true
+	for i := range n.AfiSafis {
+		n.AfiSafis[i].MpGracefulRestart.Config.Enabled = true
+	}
+	p := NewPeer(g, n, server.globalRib, server.policy)
+	p.fsm.state = bgp.BGP_FSM_ESTABLISHED
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+	p.fsm.rfMap[bgp.RF_IPv6_UC] = true
+	p.adjRibIn = table.NewAdjRib(p.ID(), rfList)
+	p.adjRibOut = table.NewAdjRib(p.ID(), rfList)
+	server.neighborMap[p.conf.Config.NeighborAddress] = p
+
+	aspath := bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})})
+	pathList := []*table.Path{
+		table.NewPath(p.fsm.peerInfo, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			aspath,
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}, time.Now(), false),
+		table.NewPath(p.fsm.peerInfo, bgp.NewIPv6AddrPrefix(64, "2001:db8::"), false, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			aspath,
+			bgp.NewPathAttributeMpReachNLRI("2001:db8::1", []bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(64, "2001:db8::")}),
+		}, time.Now(), false),
+	}
+	p.adjRibIn.Update(pathList)
+	server.propagateUpdate(p, pathList)
+	assert.Equal(2, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, rfList)))
+
+	// the session went down gracefully
+	p.adjRibIn.MarkStale(rfList)
+
+	// the peer reconnected preserving the forwarding state but without
+	// IPv6 unicast
+	p.fsm.rfMap = map[bgp.RouteFamily]bool{bgp.RF_IPv4_UC: true}
+	p.fsm.capMap[bgp.BGP_CAP_GRACEFUL_RESTART] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapGracefulRestart(0, 90, []bgp.CapGracefulRestartTuples{
+			{AFI: bgp.AFI_IP, SAFI: bgp.SAFI_UNICAST, Flags: bgp.BGP_CAP_GRACEFUL_RESTART_TUPLE_FLAG_FORWARDING},
+			{AFI: bgp.AFI_IP6, SAFI: bgp.SAFI_UNICAST, Flags: bgp.BGP_CAP_GRACEFUL_RESTART_TUPLE_FLAG_FORWARDING},
+		}),
+	}
+	assert.Equal([]bgp.RouteFamily{bgp.RF_IPv6_UC}, p.unnegotiatedFamilies())
+	server.purgeStaleRoutes(p)
+	assert.Equal(1, p.adjRibIn.Count([]bgp.RouteFamily{bgp.RF_IPv4_UC}))
+	assert.Equal(0, p.adjRibIn.Count([]bgp.RouteFamily{bgp.RF_IPv6_UC}))
+	assert.Equal(1, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv4_UC})))
+	assert.Equal(0, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv6_UC})))
+
+	// nothing is left to purge
+	p.fsm.rfMap[bgp.RF_IPv6_UC] = true
+	assert.Nil(server.purgeStaleRoutes(p))
+	assert.Equal(1, p.adjRibIn.Count([]bgp.RouteFamily{bgp.RF_IPv4_UC}))
+}
+
+func TestPurgeStaleOnEndOfRib(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	n := testNeighbor("10.0.0.1", 65001)
+	n.Config.PeerType = config.PEER_TYPE_EXTERNAL
+	n.AfiSafis = []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}}
+	source := newTestPeer(server, n, rfList)
+	target := newTestPeer(server, testNeighbor("10.0.0.2", 65002), rfList)
+
+	// graceful restart isn't negotiated yet
+	assert.False(source.retainsRoutes(FSM_READ_FAILED))
+	source.conf.GracefulRestart.Config.Enabled = true
+	source.fsm.capMap[bgp.BGP_CAP_GRACEFUL_RESTART] = []bgp.ParameterCapabilityInterface{
+		bgp.NewCapGracefulRestart(0, 120, []bgp.CapGracefulRestartTuples{{AFI: bgp.AFI_IP, SAFI: bgp.SAFI_UNICAST}}),
+	}
+	for _, reason := range []FsmStateReason{FSM_GRACEFUL_RESTART, FSM_READ_FAILED, FSM_WRITE_FAILED} {
+		assert.True(source.retainsRoutes(reason), reason.String())
+	}
+	for _, reason := range []FsmStateReason{FSM_NOTIFICATION_RECV, FSM_NOTIFICATION_SENT, FSM_HOLD_TIMER_EXPIRED, FSM_ADMIN_DOWN} {
+		assert.False(source.retainsRoutes(reason), reason.String())
+	}
+
+	pathList := []*table.Path{
+		newTestPath(source.fsm.peerInfo, "10.10.10.0/24", false),
+		newTestPath(source.fsm.peerInfo, "10.10.20.0/24", false),
+	}
+	source.adjRibIn.Update(pathList)
+	server.propagateUpdate(source, pathList)
+	assert.Equal(2, target.adjRibOut.Count(rfList))
+
+	// the session went down and came back, only one route is
+	// advertised again
+	server.retainStaleRoutes(source)
+	defer source.staleTimer.Stop()
+	assert.Equal(2, source.adjRibIn.Count(rfList))
+	update := bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")})
+	server.handleFSMMessage(source, &FsmMsg{
+		MsgType:  FSM_MSG_BGP_MESSAGE,
+		MsgSrc:   source.conf.Config.NeighborAddress,
+		MsgData:  update,
+		PathList: table.ProcessMessage(update, source.fsm.peerInfo, time.Now()),
+	})
+	assert.Equal(2, source.adjRibIn.Count(rfList))
+
+	msgs := server.handleFSMMessage(source, &FsmMsg{
+		MsgType:  FSM_MSG_BGP_MESSAGE,
+		MsgSrc:   source.conf.Config.NeighborAddress,
+		MsgData:  bgp.NewBGPUpdateMessage(nil, nil, nil),
+		endOfRib: bgp.RF_IPv4_UC,
+	})
+	assert.Equal(1, source.adjRibIn.Count(rfList))
+	assert.Equal(1, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, rfList)))
+	assert.Equal(1, target.adjRibOut.Count(rfList))
+	sent := 0
+	for _, m := range msgs {
+		if m.destination == target.conf.Config.NeighborAddress {
+			sent += len(m.messages)
+		}
+	}
+	assert.Equal(1, sent)
+}
+
+func TestStaleTimerExpired(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	n := testNeighbor("10.0.0.1", 65001)
+	n.AfiSafis = []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}}
+	source := newTestPeer(server, n, rfList)
+
+	pathList := []*table.Path{newTestPath(source.fsm.peerInfo, "10.10.10.0/24", false)}
+	source.adjRibIn.Update(pathList)
+	server.propagateUpdate(source, pathList)
+	server.retainStaleRoutes(source)
+	assert.NotNil(source.staleTimer)
+
+	server.handleFSMMessage(source, &FsmMsg{
+		MsgType: FSM_MSG_STALE_TIMER_EXPIRED,
+		MsgSrc:  source.conf.Config.NeighborAddress,
+	})
+	assert.Nil(source.staleTimer)
+	assert.Equal(0, source.adjRibIn.Count(rfList))
+}
+
+func TestDropPeerAllRoutes(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC}
+	server := newTestServer(rfList)
+	n := testNeighbor("10.0.0.1", 65001)
+	n.AfiSafis = []config.AfiSafi{
+		{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST},
+		{AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST},
+	}
+	source := newTestPeer(server, n, rfList)
+	target := newTestPeer(server, testNeighbor("10.0.0.2", 65002), rfList)
+
+	// nothing is learned for IPv4 unicast, the first family
+	pathList := []*table.Path{table.NewPath(source.fsm.peerInfo, bgp.NewIPv6AddrPrefix(64, "2001:db8::"), false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeMpReachNLRI("2001:db8::1", []bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(64, "2001:db8::")}),
+	}, time.Now(), false)}
+	source.adjRibIn.Update(pathList)
+	server.propagateUpdate(source, pathList)
+	assert.Equal(1, target.adjRibOut.Count(rfList))
+
+	sent := 0
+	for _, m := range server.dropPeerAllRoutes(source) {
+		if m.destination == target.conf.Config.NeighborAddress {
+			sent += len(m.messages)
+		}
+	}
+	assert.Equal(1, sent)
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+	assert.Equal(0, len(server.globalRib.GetBestPathList(table.GLOBAL_RIB_NAME, rfList)))
+}
+
+func TestClearCommunities(t *testing.T) {
+	assert := assert.New(t)
+	g := config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.254"}}
+	n := config.Neighbor{Config: config.NeighborConfig{
+		NeighborAddress: "10.0.0.2",
+		PeerAs:          65002,
+		PeerType:        config.PEER_TYPE_EXTERNAL,
+	}}
+	policy := table.NewRoutingPolicy()
+	assert.Nil(policy.Reload(config.RoutingPolicy{
+		PolicyDefinitions: []config.PolicyDefinition{{
+			Name: "pd0",
+			Statements: []config.Statement{{
+				Name: "st0",
+				Actions: config.Actions{
+					RouteDisposition: config.RouteDisposition{AcceptRoute: true},
+					BgpActions: config.BgpActions{
+						SetCommunity: config.SetCommunity{
+							SetCommunityMethod: config.SetCommunityMethod{CommunitiesList: []string{"65000:200"}},
+							Options:            "ADD",
+						},
+					},
+				},
+			}},
+		}},
+	}))
+	policy.SetPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, []*table.Policy{policy.PolicyMap["pd0"]})
+	p := NewPeer(g, n, nil, policy)
+	p.fsm.rfMap[bgp.RF_IPv4_UC] = true
+
+	rt := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65001, 100, true)
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+		bgp.NewPathAttributeCommunities([]uint32{65001<<16 | 100}),
+		bgp.NewPathAttributeExtendedCommunities([]bgp.ExtendedCommunityInterface{rt}),
+	}
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := table.NewPath(source, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, pathAttributes, time.Now(), false)
+
+	export := func(clear config.CommunityType) *table.Path {
+		p.conf.Config.ClearCommunities = clear
+		return policy.ApplyPolicy(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_EXPORT, filterpath(p, path), &table.PolicyOptions{})
+	}
+
+	// the policy adds the community after the clearing
+	out := export(config.COMMUNITY_TYPE_NONE)
+	assert.Equal([]uint32{65001<<16 | 100, 65000<<16 | 200}, out.GetCommunities())
+	assert.Equal(1, len(out.GetExtCommunities()))
+
+	out = export(config.COMMUNITY_TYPE_STANDARD)
+	assert.Equal([]uint32{65000<<16 | 200}, out.GetCommunities())
+	assert.Equal(1, len(out.GetExtCommunities()))
+
+	out = export(config.COMMUNITY_TYPE_EXTENDED)
+	assert.Equal([]uint32{65001<<16 | 100, 65000<<16 | 200}, out.GetCommunities())
+	assert.Equal(0, len(out.GetExtCommunities()))
+
+	out = export(config.COMMUNITY_TYPE_BOTH)
+	assert.Equal([]uint32{65000<<16 | 200}, out.GetCommunities())
+	assert.Equal(0, len(out.GetExtCommunities()))
+
+	// the path in the rib isn't modified
+	assert.Equal([]uint32{65001<<16 | 100}, path.GetCommunities())
+	assert.Equal(1, len(path.GetExtCommunities()))
+
+	// the filtering state of the path is kept
+	path.Filter(table.GLOBAL_RIB_NAME, table.POLICY_DIRECTION_IN)
+	assert.Nil(export(config.COMMUNITY_TYPE_BOTH))
+}
+
+func TestRouteServerStripAttributes(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	newPeer := func(addr string, as uint32) *Peer {
+		n := testNeighbor(addr, as)
+		n.AfiSafis = []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}}
+		n.RouteServer.Config.RouteServerClient = true
+		n.Config.StripAttributeOnEgressList = []uint8{uint8(bgp.BGP_ATTR_TYPE_AIGP)}
+		p := newTestPeer(server, n, rfList)
+		for _, dir := range []table.PolicyDirection{table.POLICY_DIRECTION_IMPORT, table.POLICY_DIRECTION_EXPORT} {
+			server.policy.SetDefaultPolicy(p.TableID(), dir, table.ROUTE_TYPE_ACCEPT)
+		}
+		return p
+	}
+	src := newPeer("10.0.0.1", 65001)
+	dst := newPeer("10.0.0.2", 65002)
+	aigp := bgp.NewPathAttributeAigp([]bgp.AigpTLV{bgp.NewAigpTLVIgpMetric(10)})
+	path := newTestPath(src.fsm.peerInfo, "10.10.10.0/24", false, aigp)
+	hasAigp := func(path *table.Path) bool {
+		for _, a := range path.GetPathAttrs() {
+			if a.GetType() == bgp.BGP_ATTR_TYPE_AIGP {
+				return true
+			}
+		}
+		return false
+	}
+
+	msgs, _ := server.propagateUpdate(src, []*table.Path{path})
+	sent := 0
+	for _, m := range msgs {
+		if m.destination != dst.conf.Config.NeighborAddress {
+			continue
+		}
+		for _, msg := range m.messages {
+			for _, p := range table.ProcessMessage(msg, src.fsm.peerInfo, time.Now()) {
+				assert.False(hasAigp(p))
+				sent++
+			}
+		}
+	}
+	assert.Equal(1, sent)
+	for _, p := range dst.adjRibOut.PathList(rfList, false) {
+		assert.False(hasAigp(p))
+	}
+
+	pathList, _ := dst.getBestFromLocal(rfList)
+	assert.Equal(1, len(pathList))
+	assert.False(hasAigp(pathList[0]))
+
+	// the path in the RIB isn't modified
+	assert.True(hasAigp(path))
+}
+
+func TestUpdateBatchAdjRibOut(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	source := newTestPeer(server, testNeighbor("10.0.0.1", 65001), rfList)
+	n := testNeighbor("10.0.0.2", 65002)
+	n.Config.UpdateBatchWindow = 60000
+	target := newTestPeer(server, n, rfList)
+	sent := func(msgs []*SenderMsg) int {
+		count := 0
+		for _, m := range msgs {
+			if m.destination == target.conf.Config.NeighborAddress {
+				count += len(m.messages)
+			}
+		}
+		return count
+	}
+
+	// the held advertisement isn't in the Adj-RIB-Out
+	msgs, _ := server.propagateUpdate(source, []*table.Path{newTestPath(source.fsm.peerInfo, "10.10.10.0/24", false)})
+	assert.Equal(0, sent(msgs))
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+
+	// the withdrawal drops the held one, nothing is sent
+	msgs, _ = server.propagateUpdate(source, []*table.Path{newTestPath(source.fsm.peerInfo, "10.10.10.0/24", true)})
+	assert.Equal(0, sent(msgs))
+	assert.Equal(0, len(target.updateBatch.flush()))
+
+	// recorded when flushed
+	server.propagateUpdate(source, []*table.Path{newTestPath(source.fsm.peerInfo, "10.10.20.0/24", false)})
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+	msgs = server.handleFSMMessage(target, &FsmMsg{MsgType: FSM_MSG_UPDATE_BATCH_EXPIRED})
+	assert.Equal(1, sent(msgs))
+	assert.Equal(1, target.adjRibOut.Count(rfList))
+
+	// the withdrawal of the sent one is sent now
+	msgs, _ = server.propagateUpdate(source, []*table.Path{newTestPath(source.fsm.peerInfo, "10.10.20.0/24", true)})
+	assert.Equal(1, sent(msgs))
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+
+	// the soft reset advertises the held one now
+	server.propagateUpdate(source, []*table.Path{newTestPath(source.fsm.peerInfo, "10.10.30.0/24", false)})
+	assert.Equal(1, sent(server.softResetOut(target, rfList)))
+	assert.Equal(1, target.adjRibOut.Count(rfList))
+	assert.Equal(0, len(target.updateBatch.flush()))
+}
+
+func TestAddPathReplacedIdentifier(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	source := newTestPeer(server, testNeighbor("10.0.0.1", 65001), rfList)
+	target := newTestPeer(server, testNeighbor("10.0.0.2", 65002), rfList)
+	target.fsm.marshalOption = &bgp.MarshallingOption{AddPath: map[bgp.RouteFamily]bgp.BGPAddPathMode{bgp.RF_IPv4_UC: bgp.BGP_ADD_PATH_SEND}}
+	path := func(id uint32, withdraw bool, attrs ...bgp.PathAttributeInterface) *table.Path {
+		p := newTestPath(source.fsm.peerInfo, "10.10.10.0/24", withdraw, attrs...)
+		p.SetPathIdentifier(id)
+		return p
+	}
+	sent := func(msgs []*SenderMsg) (withdrawn, nlri []uint32) {
+		for _, m := range msgs {
+			if m.destination != target.conf.Config.NeighborAddress {
+				continue
+			}
+			for _, msg := range m.messages {
+				u := msg.Body.(*bgp.BGPUpdate)
+				for _, p := range u.WithdrawnRoutes {
+					withdrawn = append(withdrawn, p.PathIdentifier)
+				}
+				for _, p := range u.NLRI {
+					nlri = append(nlri, p.PathIdentifier)
+				}
+			}
+		}
+		return withdrawn, nlri
+	}
+
+	// the path with the lower MED is the best
+	msgs, _ := server.propagateUpdate(source, []*table.Path{path(1, false, bgp.NewPathAttributeMultiExitDisc(10)), path(2, false, bgp.NewPathAttributeMultiExitDisc(20))})
+	withdrawn, nlri := sent(msgs)
+	assert.Equal(0, len(withdrawn))
+	assert.Equal([]uint32{1}, nlri)
+
+	// the best path changes, the one with the old identifier is
+	// withdrawn
+	msgs, _ = server.propagateUpdate(source, []*table.Path{path(1, true)})
+	withdrawn, nlri = sent(msgs)
+	assert.Equal([]uint32{1}, withdrawn)
+	assert.Equal([]uint32{2}, nlri)
+	assert.Equal(1, target.adjRibOut.Count(rfList))
+
+	// without Add-Path, the path is just replaced
+	target.fsm.marshalOption = nil
+	msgs, _ = server.propagateUpdate(source, []*table.Path{path(1, false, bgp.NewPathAttributeMultiExitDisc(10))})
+	withdrawn, nlri = sent(msgs)
+	assert.Equal(0, len(withdrawn))
+	assert.Equal(1, len(nlri))
+	assert.Equal(1, target.adjRibOut.Count(rfList))
+}
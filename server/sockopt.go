@@ -1,11 +1,13 @@
 package server
 
 import (
+	"fmt"
 	"net"
 	"os"
 	"reflect"
 	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -72,3 +74,81 @@ func SetTcpTTLSockopts(conn *net.TCPConn, ttl int) error {
 	}
 	return os.NewSyscallError("setsockopt", syscall.SetsockoptInt(tcpConnToFd(conn), level, name, ttl))
 }
+
+func tcpSockaddr(family int, addr *net.TCPAddr) (syscall.Sockaddr, error) {
+	if family == syscall.AF_INET {
+		sa := &syscall.SockaddrInet4{Port: addr.Port}
+		if addr.IP != nil {
+			copy(sa.Addr[:], addr.IP.To4())
+		}
+		return sa, nil
+	}
+	sa := &syscall.SockaddrInet6{Port: addr.Port}
+	if addr.IP != nil {
+		copy(sa.Addr[:], addr.IP.To16())
+	}
+	if addr.Zone != "" {
+		ifi, err := net.InterfaceByName(addr.Zone)
+		if err != nil {
+			return nil, err
+		}
+		sa.ZoneId = uint32(ifi.Index)
+	}
+	return sa, nil
+}
+
+// dialReuseAddr connects to the remote address from the local one,
+// setting SO_REUSEADDR on the socket before it's bound so that the
+// local port of the previous connection in TIME_WAIT can be bound
+// again. net.Dialer can't set an option before binding, so the socket
+// is connected here and handed to the net package by net.FileConn.
+func dialReuseAddr(laddr, raddr *net.TCPAddr, timeout time.Duration) (net.Conn, error) {
+	family := syscall.AF_INET
+	if raddr.IP.To4() == nil {
+		family = syscall.AF_INET6
+	}
+	lsa, err := tcpSockaddr(family, laddr)
+	if err != nil {
+		return nil, err
+	}
+	rsa, err := tcpSockaddr(family, raddr)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	syscall.CloseOnExec(fd)
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("tcp:%s->%s", laddr, raddr))
+	defer f.Close()
+
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	if err := syscall.Bind(fd, lsa); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+	switch err := syscall.Connect(fd, rsa); err {
+	case nil:
+	case syscall.EINPROGRESS:
+		if err := waitWritable(fd, timeout); err != nil {
+			return nil, err
+		}
+		v, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ERROR)
+		if err != nil {
+			return nil, os.NewSyscallError("getsockopt", err)
+		}
+		if v != 0 {
+			return nil, os.NewSyscallError("connect", syscall.Errno(v))
+		}
+	default:
+		return nil, os.NewSyscallError("connect", err)
+	}
+	// the descriptor is duplicated, the one of f is closed
+	return net.FileConn(f)
+}
@@ -0,0 +1,57 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// waitWritable waits for the non-blocking socket being connected to
+// become writable, which it does when the connection completes or
+// fails.
+func waitWritable(fd int, timeout time.Duration) error {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return os.NewSyscallError("kqueue", err)
+	}
+	defer syscall.Close(kq)
+	changes := make([]syscall.Kevent_t, 1)
+	syscall.SetKevent(&changes[0], fd, syscall.EVFILT_WRITE, syscall.EV_ADD|syscall.EV_ONESHOT)
+	deadline := time.Now().Add(timeout)
+	events := make([]syscall.Kevent_t, 1)
+	for {
+		d := deadline.Sub(time.Now())
+		if d <= 0 {
+			return fmt.Errorf("connect: timeout after %s", timeout)
+		}
+		ts := syscall.NsecToTimespec(int64(d))
+		n, err := syscall.Kevent(kq, changes, events, &ts)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return os.NewSyscallError("kevent", err)
+		}
+		if n > 0 {
+			return nil
+		}
+	}
+}
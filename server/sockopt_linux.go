@@ -0,0 +1,56 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// waitWritable waits for the non-blocking socket being connected to
+// become writable, which it does when the connection completes or
+// fails.
+func waitWritable(fd int, timeout time.Duration) error {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return os.NewSyscallError("epoll_create1", err)
+	}
+	defer syscall.Close(epfd)
+	ev := syscall.EpollEvent{Events: syscall.EPOLLOUT, Fd: int32(fd)}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &ev); err != nil {
+		return os.NewSyscallError("epoll_ctl", err)
+	}
+	deadline := time.Now().Add(timeout)
+	events := make([]syscall.EpollEvent, 1)
+	for {
+		d := deadline.Sub(time.Now())
+		if d <= 0 {
+			return fmt.Errorf("connect: timeout after %s", timeout)
+		}
+		n, err := syscall.EpollWait(epfd, events, int(d/time.Millisecond)+1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return os.NewSyscallError("epoll_wait", err)
+		}
+		if n > 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"time"
+)
+
+// updateBatch holds the advertisements to a peer for the batch window
+// so that the ones from many small changes are merged into fewer
+// UPDATE messages by their path attributes. Only the latest path per
+// prefix is held. It's only used from the server goroutine.
+type updateBatch struct {
+	held  []*table.Path
+	index map[string]int
+	timer *time.Timer
+}
+
+// add holds the advertisements in the path list, replacing the ones
+// held for the same prefixes, and drops the held ones withdrawn. It
+// returns the withdrawals, which are sent now.
+func (b *updateBatch) add(pathList []*table.Path) []*table.Path {
+	if b.index == nil {
+		b.index = make(map[string]int)
+	}
+	l := make([]*table.Path, 0)
+	for _, path := range pathList {
+		if path == nil {
+			continue
+		}
+		key := withdrawHoldKey(path)
+		i, held := b.index[key]
+		if path.IsWithdraw {
+			if held {
+				b.held[i] = nil
+				delete(b.index, key)
+			}
+			l = append(l, path)
+			continue
+		}
+		if held {
+			b.held[i] = path
+			continue
+		}
+		b.index[key] = len(b.held)
+		b.held = append(b.held, path)
+	}
+	return l
+}
+
+// discard drops the held advertisements matching, for the ones
+// advertised to the peer by other means meanwhile. The timer is left
+// running, the flush finds nothing to send.
+func (b *updateBatch) discard(match func(*table.Path) bool) {
+	for key, i := range b.index {
+		if match(b.held[i]) {
+			b.held[i] = nil
+			delete(b.index, key)
+		}
+	}
+}
+
+// discardFamilies drops the held advertisements of the families, which
+// are advertised again from the global RIB.
+func (b *updateBatch) discardFamilies(rfList []bgp.RouteFamily) {
+	b.discard(func(path *table.Path) bool {
+		for _, rf := range rfList {
+			if path.GetRouteFamily() == rf {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// flush returns the held advertisements in the order they were first
+// held and stops the timer.
+func (b *updateBatch) flush() []*table.Path {
+	l := make([]*table.Path, 0, len(b.index))
+	for _, path := range b.held {
+		if path != nil {
+			l = append(l, path)
+		}
+	}
+	b.held = nil
+	b.index = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return l
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUpdateBatch(t *testing.T) {
+	assert := assert.New(t)
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string, withdraw bool) *table.Path {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		return table.NewPath(source, bgp.NewIPAddrPrefix(24, prefix), withdraw, pathAttributes, time.Now(), false)
+	}
+
+	b := &updateBatch{}
+	l := b.add([]*table.Path{path("10.10.10.0", false), path("10.10.20.0", true), nil})
+	assert.Equal(1, len(l))
+	assert.Equal("10.10.20.0/24", l[0].GetNlri().String())
+
+	// the later advertisement replaces the held one, the withdrawal
+	// drops it
+	latest := path("10.10.10.0", false)
+	assert.Equal(0, len(b.add([]*table.Path{path("10.10.30.0", false), latest, path("10.10.40.0", false)})))
+	assert.Equal(1, len(b.add([]*table.Path{path("10.10.30.0", true)})))
+
+	l = b.flush()
+	assert.Equal(2, len(l))
+	assert.Equal(latest, l[0])
+	assert.Equal("10.10.40.0/24", l[1].GetNlri().String())
+	assert.Equal(0, len(b.flush()))
+
+	// the batches are merged into one message
+	b.add([]*table.Path{path("10.10.50.0", false)})
+	b.add([]*table.Path{path("10.10.60.0", false)})
+	msgs := table.CreateUpdateMsgFromPaths(b.flush())
+	assert.Equal(1, len(msgs))
+	assert.Equal(2, len(msgs[0].Body.(*bgp.BGPUpdate).NLRI))
+
+	// the discarded ones aren't flushed
+	v6 := table.NewPath(source, bgp.NewIPv6AddrPrefix(32, "2001:db8::"), false, []bgp.PathAttributeInterface{bgp.NewPathAttributeOrigin(0)}, time.Now(), false)
+	b.add([]*table.Path{path("10.10.70.0", false), v6})
+	b.discardFamilies([]bgp.RouteFamily{bgp.RF_IPv4_UC})
+	l = b.flush()
+	assert.Equal(1, len(l))
+	assert.Equal(bgp.RF_IPv6_UC, l[0].GetRouteFamily())
+}
+
+func TestUpdateBatchAddPath(t *testing.T) {
+	assert := assert.New(t)
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	newPath := func(id uint32, withdraw bool) *table.Path {
+		path := newTestPath(source, "10.10.10.0/24", withdraw)
+		path.SetPathIdentifier(id)
+		return path
+	}
+
+	b := &updateBatch{}
+	assert.Equal(0, len(b.add([]*table.Path{newPath(1, false), newPath(2, false)})))
+	// the withdrawal drops only the held path with the same identifier
+	assert.Equal(1, len(b.add([]*table.Path{newPath(1, true)})))
+
+	l := b.flush()
+	assert.Equal(1, len(l))
+	assert.Equal(uint32(2), l[0].GetPathIdentifier())
+}
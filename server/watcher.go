@@ -18,11 +18,14 @@ package server
 import (
 	"fmt"
 	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
 	"github.com/osrg/gobgp/table"
 	"gopkg.in/tomb.v2"
 	"net"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -54,6 +57,7 @@ const (
 	WATCHER_EVENT_STATE_CHANGE
 	WATCHER_EVENT_BESTPATH_CHANGE
 	WATCHER_EVENT_POST_POLICY_UPDATE_MSG
+	WATCHER_EVENT_UPDATE_MSG_SENT
 )
 
 type watcherEvent interface {
@@ -71,6 +75,7 @@ type watcherEventUpdateMsg struct {
 	payload      []byte
 	postPolicy   bool
 	pathList     []*table.Path
+	isLocal      bool
 }
 
 type watcherEventStateChangedMsg struct {
@@ -99,16 +104,27 @@ type mrtWatcherOp struct {
 	result   chan error
 }
 
+// mrtWatcher writes the UPDATE messages received from and optionally
+// sent to the peers as a stream of BGP4MP records. The events are
+// queued in a bounded buffer and dropped when the writer can't keep up
+// so that a slow disk never blocks the server.
 type mrtWatcher struct {
-	t        tomb.Tomb
-	filename string
-	file     *os.File
-	ch       chan watcherEvent
-	opCh     chan *mrtWatcherOp
+	t         tomb.Tomb
+	conf      config.Mrt
+	filename  string
+	file      *os.File
+	index     *os.File
+	offset    int64
+	ch        chan watcherEvent
+	buf       chan watcherEvent
+	dropped   uint64
+	neighbors map[string]bool
+	families  map[bgp.RouteFamily]bool
+	opCh      chan *mrtWatcherOp
 }
 
 func (w *mrtWatcher) notify(t watcherEventType) chan watcherEvent {
-	if t == WATCHER_EVENT_UPDATE_MSG {
+	if t == WATCHER_EVENT_UPDATE_MSG || (t == WATCHER_EVENT_UPDATE_MSG_SENT && w.conf.IncludeSent) {
 		return w.ch
 	}
 	return nil
@@ -131,42 +147,222 @@ func (w *mrtWatcher) restart(filename string) error {
 	return <-adminOp.result
 }
 
-func (w *mrtWatcher) loop() error {
-	defer w.file.Close()
+func updateFamilies(u *bgp.BGPUpdate) []bgp.RouteFamily {
+	l := make([]bgp.RouteFamily, 0, 1)
+	if len(u.NLRI) > 0 || len(u.WithdrawnRoutes) > 0 {
+		l = append(l, bgp.RF_IPv4_UC)
+	}
+	for _, a := range u.PathAttributes {
+		switch attr := a.(type) {
+		case *bgp.PathAttributeMpReachNLRI:
+			l = append(l, bgp.AfiSafiToRouteFamily(attr.AFI, attr.SAFI))
+		case *bgp.PathAttributeMpUnreachNLRI:
+			l = append(l, bgp.AfiSafiToRouteFamily(attr.AFI, attr.SAFI))
+		}
+	}
+	if len(l) == 0 {
+		// End-of-RIB marker of IPv4 unicast
+		l = append(l, bgp.RF_IPv4_UC)
+	}
+	return l
+}
+
+func (w *mrtWatcher) match(m *watcherEventUpdateMsg) bool {
+	if len(w.neighbors) > 0 && !w.neighbors[m.peerAddress.String()] {
+		return false
+	}
+	if len(w.families) == 0 {
+		return true
+	}
+	for _, rf := range updateFamilies(m.message.Body.(*bgp.BGPUpdate)) {
+		if w.families[rf] {
+			return true
+		}
+	}
+	return false
+}
+
+// recv moves the events to the buffer without blocking the sender.
+func (w *mrtWatcher) recv() error {
 	for {
-		write := func(ev watcherEvent) {
-			m := ev.(*watcherEventUpdateMsg)
-			subtype := bgp.MESSAGE_AS4
-			mp := bgp.NewBGP4MPMessage(m.peerAS, m.localAS, 0, m.peerAddress.String(), m.localAddress.String(), m.fourBytesAs, nil)
-			mp.BGPMessagePayload = m.payload
-			if m.fourBytesAs == false {
-				subtype = bgp.MESSAGE
-			}
-			bm, err := bgp.NewMRTMessage(uint32(m.timestamp.Unix()), bgp.BGP4MP, subtype, mp)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"Topic": "mrt",
-					"Data":  m,
-				}).Warn(err)
-				return
+		select {
+		case <-w.t.Dying():
+			return nil
+		case ev := <-w.ch:
+			if !w.match(ev.(*watcherEventUpdateMsg)) {
+				continue
 			}
-			buf, err := bm.Serialize()
-			if err == nil {
-				_, err = w.file.Write(buf)
+			select {
+			case w.buf <- ev:
+			default:
+				if n := atomic.AddUint64(&w.dropped, 1); n == 1 || n%1000 == 0 {
+					log.WithFields(log.Fields{
+						"Topic":   "mrt",
+						"Dropped": n,
+					}).Warn("mrt writer is too slow, dropped messages")
+				}
 			}
+		}
+	}
+}
 
-			if err != nil {
-				log.WithFields(log.Fields{
-					"Topic": "mrt",
-					"Data":  m,
-				}).Warn(err)
+func (w *mrtWatcher) open() error {
+	file, err := mrtFileOpen(w.filename)
+	if err != nil {
+		return err
+	}
+	var index *os.File
+	if w.conf.WriteIndex {
+		if index, err = mrtFileOpen(w.filename + ".idx"); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		if index != nil {
+			index.Close()
+		}
+		return err
+	}
+	w.close()
+	w.file = file
+	w.index = index
+	w.offset = info.Size()
+	return nil
+}
+
+func (w *mrtWatcher) close() {
+	if w.file != nil {
+		w.file.Close()
+	}
+	if w.index != nil {
+		w.index.Close()
+	}
+}
+
+// rotate keeps the current files with the given name and starts new
+// ones. The new files are created with temporary names and renamed
+// over the current ones, so the file name always refers to a complete
+// stream and nothing changes when rotation fails. Without a name the
+// files are just reopened.
+func (w *mrtWatcher) rotate(filename string) error {
+	if filename == "" {
+		return w.open()
+	}
+	names := []string{w.filename}
+	if w.index != nil {
+		names = append(names, w.filename+".idx")
+	}
+	files := make([]*os.File, 0, len(names))
+	rotated := make([]string, 0, len(names))
+	abort := func(err error) error {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		for _, name := range rotated {
+			os.Remove(name)
+		}
+		return err
+	}
+	for _, name := range names {
+		f, err := os.OpenFile(name+".tmp", os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+		if err != nil {
+			return abort(err)
+		}
+		files = append(files, f)
+	}
+	for _, name := range names {
+		to := filename + strings.TrimPrefix(name, w.filename)
+		if err := os.Link(name, to); err != nil {
+			return abort(err)
+		}
+		rotated = append(rotated, to)
+	}
+	for i, f := range files {
+		if err := os.Rename(f.Name(), names[i]); err != nil {
+			if i == 0 {
+				return abort(err)
 			}
+			// the new stream is in place already, keep going
+			// with the index under the temporary name
+			log.WithFields(log.Fields{
+				"Topic": "mrt",
+				"Key":   w.filename,
+			}).Warn(err)
+		}
+	}
+	w.close()
+	w.file = files[0]
+	if len(files) > 1 {
+		w.index = files[1]
+	}
+	w.offset = 0
+	return nil
+}
+
+func (w *mrtWatcher) write(ev watcherEvent) {
+	m := ev.(*watcherEventUpdateMsg)
+	var subtype bgp.MRTSubTypeBGP4MP
+	var mp *bgp.BGP4MPMessage
+	if m.isLocal {
+		subtype = bgp.MESSAGE_AS4_LOCAL
+		if m.fourBytesAs == false {
+			subtype = bgp.MESSAGE_LOCAL
+		}
+		mp = bgp.NewBGP4MPMessageLocal(m.peerAS, m.localAS, 0, m.peerAddress.String(), m.localAddress.String(), m.fourBytesAs, nil)
+	} else {
+		subtype = bgp.MESSAGE_AS4
+		if m.fourBytesAs == false {
+			subtype = bgp.MESSAGE
 		}
+		mp = bgp.NewBGP4MPMessage(m.peerAS, m.localAS, 0, m.peerAddress.String(), m.localAddress.String(), m.fourBytesAs, nil)
+	}
+	mp.BGPMessagePayload = m.payload
+	bm, err := bgp.NewMRTMessage(uint32(m.timestamp.Unix()), bgp.BGP4MP, subtype, mp)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Topic": "mrt",
+			"Data":  m,
+		}).Warn(err)
+		return
+	}
+	buf, err := bm.Serialize()
+	if err == nil {
+		_, err = w.file.Write(buf)
+	}
+	if err == nil && w.index != nil {
+		dir := "recv"
+		if m.isLocal {
+			dir = "sent"
+		}
+		_, err = fmt.Fprintf(w.index, "%d %d %d %s %s\n", m.timestamp.Unix(), w.offset, len(buf), m.peerAddress, dir)
+	}
+	w.offset += int64(len(buf))
 
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Topic": "mrt",
+			"Data":  m,
+		}).Warn(err)
+	}
+}
+
+func (w *mrtWatcher) loop() error {
+	defer w.close()
+	var rotationCh <-chan time.Time
+	if w.conf.RotationInterval > 0 {
+		ticker := time.NewTicker(time.Duration(w.conf.RotationInterval) * time.Second)
+		defer ticker.Stop()
+		rotationCh = ticker.C
+	}
+	for {
 		drain := func() {
-			for len(w.ch) > 0 {
-				m := <-w.ch
-				write(m)
+			for len(w.buf) > 0 {
+				m := <-w.buf
+				w.write(m)
 			}
 		}
 
@@ -174,27 +370,25 @@ func (w *mrtWatcher) loop() error {
 		case <-w.t.Dying():
 			drain()
 			return nil
-		case m := <-w.ch:
-			write(m)
-		case adminOp := <-w.opCh:
-			var err error
-			if adminOp.filename != "" {
-				err = os.Rename(w.file.Name(), adminOp.filename)
-			}
-			if err == nil {
-				var file *os.File
-				file, err = mrtFileOpen(w.file.Name())
-				if err == nil {
-					w.file.Close()
-					w.file = file
-				}
+		case m := <-w.buf:
+			w.write(m)
+		case t := <-rotationCh:
+			if err := w.rotate(w.filename + "." + t.Format("20060102.150405")); err != nil {
+				log.WithFields(log.Fields{
+					"Topic": "mrt",
+					"Key":   w.filename,
+				}).Warn(err)
 			}
-			adminOp.result <- err
+		case adminOp := <-w.opCh:
+			adminOp.result <- w.rotate(adminOp.filename)
 		}
 	}
 }
 
 func (w *mrtWatcher) watchingEventTypes() []watcherEventType {
+	if w.conf.IncludeSent {
+		return []watcherEventType{WATCHER_EVENT_UPDATE_MSG, WATCHER_EVENT_UPDATE_MSG_SENT}
+	}
 	return []watcherEventType{WATCHER_EVENT_UPDATE_MSG}
 }
 
@@ -206,17 +400,38 @@ func mrtFileOpen(filename string) (*os.File, error) {
 	return file, err
 }
 
-func newMrtWatcher(filename string) (*mrtWatcher, error) {
-	file, err := mrtFileOpen(filename)
-	if err != nil {
-		return nil, err
+func newMrtWatcher(c config.Mrt) (*mrtWatcher, error) {
+	size := c.BufferSize
+	if size == 0 {
+		size = config.DEFAULT_MRT_BUFFER_SIZE
 	}
 	w := mrtWatcher{
-		filename: filename,
-		file:     file,
-		ch:       make(chan watcherEvent),
-		opCh:     make(chan *mrtWatcherOp, 1),
+		conf:      c,
+		filename:  c.FileName,
+		ch:        make(chan watcherEvent),
+		buf:       make(chan watcherEvent, size),
+		neighbors: make(map[string]bool),
+		families:  make(map[bgp.RouteFamily]bool),
+		opCh:      make(chan *mrtWatcherOp, 1),
+	}
+	for _, addr := range c.NeighborAddressList {
+		ip := config.ParseAddress(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid mrt neighbor address %q", addr)
+		}
+		w.neighbors[ip.String()] = true
+	}
+	for _, name := range c.AfiSafiNameList {
+		rf, err := bgp.GetRouteFamily(name)
+		if err != nil {
+			return nil, err
+		}
+		w.families[rf] = true
+	}
+	if err := w.open(); err != nil {
+		return nil, err
 	}
+	w.t.Go(w.recv)
 	w.t.Go(w.loop)
 	return &w, nil
 }
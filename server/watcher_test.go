@@ -0,0 +1,195 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"github.com/osrg/gobgp/config"
+	"github.com/osrg/gobgp/packet"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMrtWatcherStream(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "mrt")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "updates.dump")
+
+	w, err := newMrtWatcher(config.Mrt{
+		FileName:            filename,
+		IncludeSent:         true,
+		WriteIndex:          true,
+		NeighborAddressList: []string{"10.0.0.1"},
+		AfiSafiNameList:     []string{"ipv4-unicast"},
+	})
+	assert.Nil(err)
+	assert.NotNil(w.notify(WATCHER_EVENT_UPDATE_MSG_SENT))
+
+	event := func(peer string, isLocal bool, msg *bgp.BGPMessage) *watcherEventUpdateMsg {
+		payload, _ := msg.Serialize()
+		return &watcherEventUpdateMsg{
+			message:      msg,
+			peerAS:       65001,
+			localAS:      65000,
+			peerAddress:  net.ParseIP(peer),
+			localAddress: net.ParseIP("10.0.0.254"),
+			fourBytesAs:  true,
+			timestamp:    time.Now(),
+			payload:      payload,
+			isLocal:      isLocal,
+		}
+	}
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	announce := bgp.NewBGPUpdateMessage(nil, attrs, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")})
+	withdraw := bgp.NewBGPUpdateMessage([]*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}, nil, nil)
+	v6 := bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeMpUnreachNLRI([]bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(64, "2001:db8::")}),
+	}, nil)
+
+	w.ch <- event("10.0.0.1", false, announce)
+	w.ch <- event("10.0.0.1", true, withdraw)
+	// filtered out by the neighbor and the family
+	w.ch <- event("10.0.0.2", false, announce)
+	w.ch <- event("10.0.0.1", false, v6)
+	time.Sleep(100 * time.Millisecond)
+	w.stop()
+	w.t.Wait()
+
+	file, err := os.Open(filename)
+	assert.Nil(err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bgp.SplitMrt)
+	subtypes := make([]bgp.MRTSubTypeBGP4MP, 0)
+	for scanner.Scan() {
+		b := scanner.Bytes()
+		h := &bgp.MRTHeader{}
+		assert.Nil(h.DecodeFromBytes(b[:bgp.MRT_COMMON_HEADER_LEN]))
+		subtypes = append(subtypes, bgp.MRTSubTypeBGP4MP(h.SubType))
+	}
+	assert.Equal([]bgp.MRTSubTypeBGP4MP{bgp.MESSAGE_AS4, bgp.MESSAGE_AS4_LOCAL}, subtypes)
+
+	index, err := ioutil.ReadFile(filename + ".idx")
+	assert.Nil(err)
+	lines := strings.Split(strings.TrimSpace(string(index)), "\n")
+	assert.Equal(2, len(lines))
+	assert.True(strings.HasSuffix(lines[0], "10.0.0.1 recv"))
+	assert.True(strings.HasSuffix(lines[1], "10.0.0.1 sent"))
+	assert.Equal("0", strings.Fields(lines[0])[1])
+}
+
+func TestMrtWatcherRotate(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "mrt")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "updates.dump")
+
+	w := &mrtWatcher{
+		conf:     config.Mrt{WriteIndex: true},
+		filename: filename,
+	}
+	assert.Nil(w.open())
+	defer w.close()
+	msg := bgp.NewBGPUpdateMessage([]*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}, nil, nil)
+	payload, _ := msg.Serialize()
+	event := &watcherEventUpdateMsg{
+		message:      msg,
+		peerAS:       65001,
+		localAS:      65000,
+		peerAddress:  net.ParseIP("10.0.0.1"),
+		localAddress: net.ParseIP("10.0.0.254"),
+		fourBytesAs:  true,
+		timestamp:    time.Now(),
+		payload:      payload,
+	}
+	w.write(event)
+	info, err := os.Stat(filename)
+	assert.Nil(err)
+	size := info.Size()
+
+	rotated := filepath.Join(dir, "updates.dump.1")
+	assert.Nil(w.rotate(rotated))
+	w.write(event)
+	w.write(event)
+	for name, expected := range map[string]int64{rotated: size, filename: size * 2} {
+		info, err := os.Stat(name)
+		assert.Nil(err)
+		assert.Equal(expected, info.Size(), name)
+	}
+	for name, expected := range map[string]int{rotated + ".idx": 1, filename + ".idx": 2} {
+		index, err := ioutil.ReadFile(name)
+		assert.Nil(err)
+		assert.Equal(expected, len(strings.Split(strings.TrimSpace(string(index)), "\n")), name)
+	}
+	// the offsets restart in the new file
+	index, _ := ioutil.ReadFile(filename + ".idx")
+	assert.Equal("0", strings.Fields(string(index))[1])
+
+	// nothing changes when the rotated file can't be created
+	assert.NotNil(w.rotate(rotated))
+	info, err = os.Stat(filename)
+	assert.Nil(err)
+	assert.Equal(size*2, info.Size())
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	assert.Equal(0, len(matches))
+}
+
+func TestNotifySentUpdatesAddPath(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	p := newTestPeer(server, testNeighbor("10.0.0.2", 65002), rfList)
+	p.fsm.conn = NewMockConnection()
+	option := &bgp.MarshallingOption{AddPath: map[bgp.RouteFamily]bgp.BGPAddPathMode{bgp.RF_IPv4_UC: bgp.BGP_ADD_PATH_SEND}}
+	p.fsm.marshalOption = option
+	w := &mrtWatcher{
+		conf: config.Mrt{IncludeSent: true},
+		ch:   make(chan watcherEvent),
+	}
+	server.watchers[WATCHER_MRT] = w
+
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	nlri.PathIdentifier = 10
+	msg := bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65000})}),
+		bgp.NewPathAttributeNextHop("10.0.0.254"),
+	}, []*bgp.IPAddrPrefix{nlri})
+	for _, twoBytesAs := range []bool{false, true} {
+		server.broadcastMsgs = nil
+		m := newSenderMsg(p, []*bgp.BGPMessage{msg})
+		m.twoBytesAs = twoBytesAs
+		server.notifySentUpdates(m)
+		assert.Equal(1, len(server.broadcastMsgs))
+		ev := server.broadcastMsgs[0].(*broadcastWatcherMsg).event.(*watcherEventUpdateMsg)
+		sent, err := bgp.ParseBGPMessage(ev.payload, peerMarshallingOption(option))
+		assert.Nil(err)
+		assert.Equal(uint32(10), sent.Body.(*bgp.BGPUpdate).NLRI[0].PathIdentifier)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"github.com/osrg/gobgp/table"
+	"time"
+)
+
+// MAX_HELD_WITHDRAWALS bounds the number of withdrawals held per peer.
+// Withdrawals over the limit are propagated immediately.
+const MAX_HELD_WITHDRAWALS = 4096
+
+type heldWithdrawal struct {
+	path     *table.Path
+	deadline time.Time
+}
+
+// withdrawHold delays the withdrawals received from a peer so that a
+// route withdrawn and re-advertised shortly doesn't cause churn to the
+// other peers. It's only used from the server goroutine.
+type withdrawHold struct {
+	held  map[string]heldWithdrawal
+	timer *time.Timer
+}
+
+// withdrawHoldKey identifies a path by its family, prefix and path
+// identifier, so the paths of a prefix received or sent with Add-Path
+// are held separately.
+func withdrawHoldKey(path *table.Path) string {
+	return fmt.Sprintf("%d:%d:%s", path.GetRouteFamily(), path.GetPathIdentifier(), path.GetNlri().String())
+}
+
+// filter holds the withdrawals in the path list and cancels the held
+// ones re-advertised. It returns the paths to be propagated now.
+func (w *withdrawHold) filter(pathList []*table.Path, hold time.Duration, now time.Time) []*table.Path {
+	if w.held == nil {
+		w.held = make(map[string]heldWithdrawal)
+	}
+	l := make([]*table.Path, 0, len(pathList))
+	for _, path := range pathList {
+		key := withdrawHoldKey(path)
+		if !path.IsWithdraw {
+			delete(w.held, key)
+			l = append(l, path)
+			continue
+		}
+		if _, y := w.held[key]; y {
+			continue
+		}
+		if len(w.held) >= MAX_HELD_WITHDRAWALS {
+			l = append(l, path)
+			continue
+		}
+		w.held[key] = heldWithdrawal{
+			path:     path,
+			deadline: now.Add(hold),
+		}
+	}
+	return l
+}
+
+// expire returns the held withdrawals whose deadline has passed, and
+// how long to wait for the next one, or zero if nothing is held.
+func (w *withdrawHold) expire(now time.Time) ([]*table.Path, time.Duration) {
+	l := make([]*table.Path, 0)
+	var next time.Duration
+	for key, h := range w.held {
+		if d := h.deadline.Sub(now); d > 0 {
+			if next == 0 || d < next {
+				next = d
+			}
+			continue
+		}
+		l = append(l, h.path)
+		delete(w.held, key)
+	}
+	return l, next
+}
+
+// flush returns all the held withdrawals and stops the timer.
+func (w *withdrawHold) flush() []*table.Path {
+	l := make([]*table.Path, 0, len(w.held))
+	for _, h := range w.held {
+		l = append(l, h.path)
+	}
+	w.held = nil
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	return l
+}
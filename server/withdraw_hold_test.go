@@ -0,0 +1,95 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"github.com/osrg/gobgp/table"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWithdrawHold(t *testing.T) {
+	assert := assert.New(t)
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string, withdraw bool) *table.Path {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		return table.NewPath(source, bgp.NewIPAddrPrefix(24, prefix), withdraw, pathAttributes, time.Now(), false)
+	}
+
+	w := &withdrawHold{}
+	now := time.Now()
+	hold := time.Second
+
+	l := w.filter([]*table.Path{path("10.10.10.0", true), path("10.10.20.0", true), path("10.10.30.0", false)}, hold, now)
+	assert.Equal(1, len(l))
+	assert.Equal("10.10.30.0/24", l[0].GetNlri().String())
+	assert.Equal(2, len(w.held))
+
+	// re-advertised shortly, the withdrawal is cancelled
+	l = w.filter([]*table.Path{path("10.10.10.0", false)}, hold, now.Add(hold/2))
+	assert.Equal(1, len(l))
+	assert.Equal(1, len(w.held))
+
+	l, next := w.expire(now.Add(hold / 2))
+	assert.Equal(0, len(l))
+	assert.Equal(hold/2, next)
+
+	l, next = w.expire(now.Add(hold))
+	assert.Equal(1, len(l))
+	assert.Equal("10.10.20.0/24", l[0].GetNlri().String())
+	assert.True(l[0].IsWithdraw)
+	assert.Equal(time.Duration(0), next)
+
+	w.filter([]*table.Path{path("10.10.40.0", true)}, hold, now)
+	assert.Equal(1, len(w.flush()))
+	assert.Equal(0, len(w.held))
+}
+
+func TestWithdrawHoldAddPath(t *testing.T) {
+	assert := assert.New(t)
+	source := &table.PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	newPath := func(id uint32, withdraw bool) *table.Path {
+		path := newTestPath(source, "10.10.10.0/24", withdraw)
+		path.SetPathIdentifier(id)
+		return path
+	}
+
+	w := &withdrawHold{}
+	now := time.Now()
+	hold := time.Second
+
+	// the paths of the prefix are held separately
+	l := w.filter([]*table.Path{newPath(1, true), newPath(2, true)}, hold, now)
+	assert.Equal(0, len(l))
+	assert.Equal(2, len(w.held))
+
+	// re-advertising one of them cancels only its withdrawal
+	l = w.filter([]*table.Path{newPath(1, false)}, hold, now)
+	assert.Equal(1, len(l))
+	assert.Equal(1, len(w.held))
+
+	l, _ = w.expire(now.Add(hold))
+	assert.Equal(1, len(l))
+	assert.Equal(uint32(2), l[0].GetPathIdentifier())
+	assert.True(l[0].IsWithdraw)
+}
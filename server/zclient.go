@@ -146,20 +146,77 @@ func newBroadcastZapiBestMsg(cli *zebra.Client, path *table.Path) *broadcastZapi
 	}
 }
 
+func newNexthopLookupMsg(cli *zebra.Client, nexthop net.IP) *broadcastZapiMsg {
+	if cli == nil {
+		return nil
+	}
+	command := zebra.IPV4_NEXTHOP_LOOKUP
+	if nexthop.To4() == nil {
+		command = zebra.IPV6_NEXTHOP_LOOKUP
+	}
+	return &broadcastZapiMsg{
+		client: cli,
+		msg: &zebra.Message{
+			Header: zebra.Header{
+				Len:     zebra.HEADER_SIZE,
+				Marker:  zebra.HEADER_MARKER,
+				Version: zebra.VERSION,
+				Command: command,
+			},
+			Body: &zebra.NexthopLookupBody{
+				Api:  command,
+				Addr: nexthop,
+			},
+		},
+	}
+}
+
+// zapiPrefix returns the prefix of the address and the length zebra
+// reports, or nil when the address is missing.
+func zapiPrefix(addr net.IP, length uint8) *net.IPNet {
+	if addr == nil {
+		return nil
+	}
+	bits := net.IPv6len * 8
+	if addr.To4() != nil {
+		addr = addr.To4()
+		bits = net.IPv4len * 8
+	}
+	mask := net.CIDRMask(int(length), bits)
+	if mask == nil {
+		return nil
+	}
+	return &net.IPNet{IP: addr.Mask(mask), Mask: mask}
+}
+
 func handleZapiMsg(msg *zebra.Message, server *BgpServer) []*SenderMsg {
 
 	switch b := msg.Body.(type) {
 	case *zebra.IPRouteBody:
-		pi := &table.PeerInfo{
-			AS:      server.bgpConfig.Global.Config.As,
-			LocalID: net.ParseIP(server.bgpConfig.Global.Config.RouterId).To4(),
-		}
+		pi := table.NewLocalPeerInfo(&server.bgpConfig.Global)
 
+		var msgs []*SenderMsg
 		if b.Prefix != nil && len(b.Nexthops) > 0 && b.Type != zebra.ROUTE_KERNEL {
 			p := createPathFromIPRouteMessage(msg, pi)
-			msgs, _ := server.propagateUpdate(nil, []*table.Path{p})
-			return msgs
+			msgs, _ = server.propagateUpdate(nil, []*table.Path{p})
 		}
+		server.refreshNexthops(zapiPrefix(b.Prefix, b.PrefixLength))
+		return msgs
+	case *zebra.InterfaceAddressUpdateBody:
+		server.refreshNexthops(zapiPrefix(b.Prefix, b.Length))
+	case *zebra.InterfaceUpdateBody:
+		// the routes via the interface are gone or back, whichever
+		// nexthops they resolved
+		server.refreshNexthops(nil)
+	case *zebra.NexthopLookupBody:
+		reachable := len(b.Nexthops) > 0
+		log.WithFields(log.Fields{
+			"Topic":     "Zebra",
+			"Nexthop":   b.Addr,
+			"Reachable": reachable,
+			"Metric":    b.Metric,
+		}).Debug("nexthop lookup result")
+		return server.handleNexthopLookup(b.Addr, reachable, b.Metric)
 	}
 
 	return nil
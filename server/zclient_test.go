@@ -16,6 +16,7 @@
 package server
 
 import (
+	"github.com/osrg/gobgp/packet"
 	"github.com/osrg/gobgp/table"
 	"github.com/osrg/gobgp/zebra"
 	"github.com/stretchr/testify/assert"
@@ -102,3 +103,63 @@ func Test_createPathFromIPRouteMessage(t *testing.T) {
 	assert.True(p.IsWithdraw)
 
 }
+
+func TestNexthopTracking(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	server := newTestServer(rfList)
+	server.zclient = &zebra.Client{}
+	source := newTestPeer(server, testNeighbor("10.0.0.1", 65001), rfList)
+	target := newTestPeer(server, testNeighbor("10.0.0.2", 65002), rfList)
+	lookups := func() []string {
+		l := make([]string, 0)
+		for _, m := range server.broadcastMsgs {
+			if b, ok := m.(*broadcastZapiMsg).msg.Body.(*zebra.NexthopLookupBody); ok {
+				l = append(l, b.Addr.String())
+			}
+		}
+		server.broadcastMsgs = nil
+		return l
+	}
+
+	// looked up once when it's used first
+	server.propagateUpdate(source, []*table.Path{newTestPath(source.fsm.peerInfo, "10.10.10.0/24", false)})
+	assert.Equal([]string{"10.0.0.1"}, lookups())
+	server.propagateUpdate(source, []*table.Path{newTestPath(source.fsm.peerInfo, "10.10.20.0/24", false)})
+	assert.Equal([]string{}, lookups())
+	assert.Equal(2, target.adjRibOut.Count(rfList))
+
+	// and again on the changes zebra reports
+	handleZapiMsg(&zebra.Message{Body: &zebra.InterfaceUpdateBody{}}, server)
+	assert.Equal([]string{"10.0.0.1"}, lookups())
+	handleZapiMsg(&zebra.Message{Body: &zebra.IPRouteBody{Prefix: net.ParseIP("10.0.0.0"), PrefixLength: 24}}, server)
+	assert.Equal([]string{"10.0.0.1"}, lookups())
+	handleZapiMsg(&zebra.Message{Body: &zebra.InterfaceAddressUpdateBody{Prefix: net.ParseIP("10.0.0.2"), Length: 30}}, server)
+	assert.Equal([]string{"10.0.0.1"}, lookups())
+
+	// but only for the nexthops in the prefix which changed
+	handleZapiMsg(&zebra.Message{Body: &zebra.IPRouteBody{Prefix: net.ParseIP("192.168.0.0"), PrefixLength: 16}}, server)
+	assert.Equal([]string{}, lookups())
+	handleZapiMsg(&zebra.Message{Body: &zebra.InterfaceAddressUpdateBody{Prefix: net.ParseIP("10.0.0.5"), Length: 32}}, server)
+	assert.Equal([]string{}, lookups())
+
+	// the withdrawals are advertised
+	withdrawn := 0
+	for _, m := range server.handleNexthopReachability(net.ParseIP("10.0.0.1"), false, 0) {
+		if m.destination == target.ID() {
+			for _, msg := range m.messages {
+				withdrawn += len(msg.Body.(*bgp.BGPUpdate).WithdrawnRoutes)
+			}
+		}
+	}
+	assert.Equal(2, withdrawn)
+	assert.Equal(0, target.adjRibOut.Count(rfList))
+
+	// not tracked any more
+	server.propagateUpdate(source, []*table.Path{
+		newTestPath(source.fsm.peerInfo, "10.10.10.0/24", true),
+		newTestPath(source.fsm.peerInfo, "10.10.20.0/24", true),
+	})
+	handleZapiMsg(&zebra.Message{Body: &zebra.InterfaceUpdateBody{}}, server)
+	assert.Equal([]string{}, lookups())
+}
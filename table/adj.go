@@ -42,6 +42,15 @@ func NewAdjRib(id string, rfList []bgp.RouteFamily) *AdjRib {
 	}
 }
 
+// AddFamilies prepares the tables for the families not carried yet.
+func (adj *AdjRib) AddFamilies(rfList []bgp.RouteFamily) {
+	for _, rf := range rfList {
+		if _, ok := adj.table[rf]; !ok {
+			adj.table[rf] = make(map[string]*Dest)
+		}
+	}
+}
+
 func (adj *AdjRib) Update(pathList []*Path) {
 	for _, path := range pathList {
 		if path == nil {
@@ -53,12 +62,15 @@ func (adj *AdjRib) Update(pathList []*Path) {
 		var old *Path
 		oldIdx := 0
 		if dst == nil {
+			if path.IsWithdraw {
+				continue
+			}
 			dst = &Dest{}
 			dst.pathList = make([]*Path, 0)
 			adj.table[rf][key] = dst
 		} else {
 			for i, known := range dst.pathList {
-				if known.GetSource() == path.GetSource() {
+				if known.GetSource() == path.GetSource() && known.GetPathIdentifier() == path.GetPathIdentifier() {
 					old = known
 					oldIdx = i
 				}
@@ -104,6 +116,83 @@ func (adj *AdjRib) Update(pathList []*Path) {
 	}
 }
 
+// ReplacedPaths returns the withdrawals of the paths in the Adj-RIB-Out
+// of the prefixes of the paths which have another path identifier
+// (RFC7911), which the paths don't replace by themselves.
+func (adj *AdjRib) ReplacedPaths(pathList []*Path) []*Path {
+	l := make([]*Path, 0)
+	for _, path := range pathList {
+		if path == nil || path.IsWithdraw {
+			continue
+		}
+		dst := adj.table[path.GetRouteFamily()][path.getPrefix()]
+		if dst == nil {
+			continue
+		}
+		for _, known := range dst.pathList {
+			if known.GetPathIdentifier() != path.GetPathIdentifier() {
+				l = append(l, known.Clone(true))
+			}
+		}
+	}
+	return l
+}
+
+// LimitPaths drops the paths which would make the paths of a prefix
+// exceed the limit of the family, zero for no limit, and returns the
+// rest and the number of the dropped paths. The paths replacing known
+// ones and the withdrawals are always kept.
+func (adj *AdjRib) LimitPaths(pathList []*Path, limit func(bgp.RouteFamily) int) ([]*Path, int) {
+	type key struct {
+		rf     bgp.RouteFamily
+		prefix string
+	}
+	ids := make(map[key]map[uint32]bool)
+	l := make([]*Path, 0, len(pathList))
+	dropped := 0
+	for _, path := range pathList {
+		if path == nil {
+			continue
+		}
+		rf := path.GetRouteFamily()
+		max := limit(rf)
+		if max == 0 {
+			l = append(l, path)
+			continue
+		}
+		k := key{rf, path.getPrefix()}
+		known, ok := ids[k]
+		if !ok {
+			known = make(map[uint32]bool)
+			if dst := adj.table[rf][k.prefix]; dst != nil {
+				for _, p := range dst.pathList {
+					known[p.GetPathIdentifier()] = true
+				}
+			}
+			ids[k] = known
+		}
+		id := path.GetPathIdentifier()
+		if path.IsWithdraw {
+			delete(known, id)
+		} else if !known[id] {
+			if len(known) >= max {
+				dropped++
+				continue
+			}
+			known[id] = true
+		}
+		l = append(l, path)
+	}
+	return l, dropped
+}
+
+// Exists returns true if the adj-rib has a path for the prefix of the
+// given path.
+func (adj *AdjRib) Exists(path *Path) bool {
+	_, ok := adj.table[path.GetRouteFamily()][path.getPrefix()]
+	return ok
+}
+
 func (adj *AdjRib) RefreshAcceptedNumber(rfList []bgp.RouteFamily) {
 	for _, rf := range rfList {
 		adj.accepted[rf] = 0
@@ -144,6 +233,12 @@ func (adj *AdjRib) Count(rfList []bgp.RouteFamily) int {
 	return count
 }
 
+// PrefixCount returns the number of the prefixes of the family without
+// walking the table.
+func (adj *AdjRib) PrefixCount(rf bgp.RouteFamily) int {
+	return len(adj.table[rf])
+}
+
 func (adj *AdjRib) Accepted(rfList []bgp.RouteFamily) int {
 	count := 0
 	for _, rf := range rfList {
@@ -154,6 +249,106 @@ func (adj *AdjRib) Accepted(rfList []bgp.RouteFamily) int {
 	return count
 }
 
+// MarkStale marks all the paths of the families as stale.
+func (adj *AdjRib) MarkStale(rfList []bgp.RouteFamily) {
+	for _, rf := range rfList {
+		for _, dst := range adj.table[rf] {
+			for _, p := range dst.pathList {
+				p.SetStale(true)
+			}
+		}
+	}
+}
+
+// DropStale removes the stale paths of the families and returns the
+// withdrawals for them.
+func (adj *AdjRib) DropStale(rfList []bgp.RouteFamily) []*Path {
+	withdrawn := make([]*Path, 0)
+	for _, rf := range rfList {
+		for key, dst := range adj.table[rf] {
+			pathList := make([]*Path, 0, len(dst.pathList))
+			for _, p := range dst.pathList {
+				if !p.IsStale() {
+					pathList = append(pathList, p)
+					continue
+				}
+				if p.Filtered(adj.id) == POLICY_DIRECTION_NONE {
+					adj.accepted[rf]--
+				}
+				withdrawn = append(withdrawn, p.Clone(true))
+			}
+			if len(pathList) == 0 {
+				delete(adj.table[rf], key)
+			} else {
+				dst.pathList = pathList
+			}
+		}
+	}
+	return withdrawn
+}
+
+// routeTargetConstrainedFamilies are the families whose routes are
+// advertised along the route target membership by RTC (RFC4684).
+var routeTargetConstrainedFamilies = map[bgp.RouteFamily]bool{
+	bgp.RF_IPv4_VPN:    true,
+	bgp.RF_IPv6_VPN:    true,
+	bgp.RF_IPv4_VPN_MC: true,
+	bgp.RF_IPv6_VPN_MC: true,
+	bgp.RF_EVPN:        true,
+	bgp.RF_FS_IPv4_VPN: true,
+	bgp.RF_FS_IPv6_VPN: true,
+}
+
+// WithdrawByRouteTarget removes the VPN paths carrying the route target
+// but none of the route targets in interests, and returns the
+// withdrawals for them. It's for the peer which withdrew its interest
+// in the route target by RTC, interests are the route targets it's
+// still interested in. Nil target is for the default RTC route, then
+// the paths carrying none of interests are removed.
+func (adj *AdjRib) WithdrawByRouteTarget(target bgp.ExtendedCommunityInterface, interests map[string]bool) []*Path {
+	withdrawn := make([]*Path, 0)
+	for rf, table := range adj.table {
+		if !routeTargetConstrainedFamilies[rf] {
+			continue
+		}
+		for key, dst := range table {
+			pathList := make([]*Path, 0, len(dst.pathList))
+			for _, p := range dst.pathList {
+				if !isOutOfInterests(p, target, interests) {
+					pathList = append(pathList, p)
+					continue
+				}
+				if p.Filtered(adj.id) == POLICY_DIRECTION_NONE {
+					adj.accepted[rf]--
+				}
+				withdrawn = append(withdrawn, p.Clone(true))
+			}
+			if len(pathList) == 0 {
+				delete(table, key)
+			} else {
+				dst.pathList = pathList
+			}
+		}
+	}
+	return withdrawn
+}
+
+// isOutOfInterests returns true if the path carries the route target
+// and none of the route targets in interests.
+func isOutOfInterests(path *Path, target bgp.ExtendedCommunityInterface, interests map[string]bool) bool {
+	found := target == nil
+	for _, rt := range path.ClassifyExtCommunities()[EXT_COMMUNITY_ROUTE_TARGET] {
+		s := rt.String()
+		if interests[s] {
+			return false
+		}
+		if target != nil && s == target.String() {
+			found = true
+		}
+	}
+	return found
+}
+
 func (adj *AdjRib) Drop(rfList []bgp.RouteFamily) {
 	for _, rf := range rfList {
 		if _, ok := adj.table[rf]; ok {
@@ -0,0 +1,129 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAdjRibStale(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	adj := NewAdjRib("10.0.0.1", rfList)
+	source := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string) *Path {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		return NewPath(source, bgp.NewIPAddrPrefix(24, prefix), false, pathAttributes, time.Now(), false)
+	}
+
+	adj.Update([]*Path{path("10.10.10.0"), path("10.10.20.0")})
+	adj.MarkStale(rfList)
+	for _, p := range adj.PathList(rfList, false) {
+		assert.True(p.IsStale())
+	}
+
+	// re-advertised after the restart
+	adj.Update([]*Path{path("10.10.10.0")})
+
+	withdrawn := adj.DropStale(rfList)
+	assert.Equal(1, len(withdrawn))
+	assert.Equal("10.10.20.0/24", withdrawn[0].GetNlri().String())
+	assert.True(withdrawn[0].IsWithdraw)
+	assert.Equal(1, adj.Count(rfList))
+	assert.Equal(1, adj.Accepted(rfList))
+	assert.Equal(1, adj.PrefixCount(bgp.RF_IPv4_UC))
+
+	// withdrawing an unknown prefix leaves no empty entry
+	adj.Update([]*Path{path("10.10.30.0").Clone(true)})
+	assert.Equal(1, adj.PrefixCount(bgp.RF_IPv4_UC))
+}
+
+func TestAdjRibLimitPaths(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC}
+	adj := NewAdjRib("10.0.0.1", rfList)
+	source := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(id uint32, withdraw bool) *Path {
+		nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+		nlri.PathIdentifier = id
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		return NewPath(source, nlri, withdraw, pathAttributes, time.Now(), false)
+	}
+	limit := func(rf bgp.RouteFamily) int {
+		if rf == bgp.RF_IPv4_UC {
+			return 2
+		}
+		return 0
+	}
+
+	l, dropped := adj.LimitPaths([]*Path{path(1, false), path(2, false), path(3, false)}, limit)
+	assert.Equal(2, len(l))
+	assert.Equal(1, dropped)
+	adj.Update(l)
+	assert.Equal(2, adj.Count(rfList))
+
+	// replacing a known path and making room with a withdrawal
+	l, dropped = adj.LimitPaths([]*Path{path(2, false), path(3, false), path(1, true), path(3, false)}, limit)
+	assert.Equal(3, len(l))
+	assert.Equal(1, dropped)
+	adj.Update(l)
+	assert.Equal(2, adj.Count(rfList))
+
+	// no limit for the family
+	l, dropped = adj.LimitPaths([]*Path{path(4, false), path(5, false)}, func(bgp.RouteFamily) int { return 0 })
+	assert.Equal(2, len(l))
+	assert.Equal(0, dropped)
+}
+
+func TestAdjRibReplacedPaths(t *testing.T) {
+	assert := assert.New(t)
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_UC}
+	adj := NewAdjRib("10.0.0.1", rfList)
+	source := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	path := func(prefix string, id uint32) *Path {
+		p := NewPath(source, bgp.NewIPAddrPrefix(24, prefix), false, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}, time.Now(), false)
+		p.SetPathIdentifier(id)
+		return p
+	}
+	adj.Update([]*Path{path("10.10.10.0", 1), path("10.10.20.0", 1)})
+
+	// same identifier or a new prefix
+	assert.Equal(0, len(adj.ReplacedPaths([]*Path{path("10.10.10.0", 1), path("10.10.30.0", 2)})))
+
+	l := adj.ReplacedPaths([]*Path{path("10.10.10.0", 2)})
+	assert.Equal(1, len(l))
+	assert.True(l[0].IsWithdraw)
+	assert.Equal(uint32(1), l[0].GetPathIdentifier())
+	assert.Equal("10.10.10.0/24", l[0].GetNlri().String())
+
+	adj.Update(append(l, path("10.10.10.0", 2)))
+	assert.Equal(2, adj.Count(rfList))
+}
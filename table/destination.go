@@ -36,6 +36,7 @@ const (
 	BPR_HIGHEST_WEIGHT     BestPathReason = "Highest Weight"
 	BPR_LOCAL_PREF         BestPathReason = "Local Pref"
 	BPR_LOCAL_ORIGIN       BestPathReason = "Local Origin"
+	BPR_AIGP               BestPathReason = "AIGP"
 	BPR_ASPATH             BestPathReason = "AS Path"
 	BPR_ORIGIN             BestPathReason = "Origin"
 	BPR_MED                BestPathReason = "MED"
@@ -103,13 +104,25 @@ func NewPeerInfo(g *config.Global, p *config.Neighbor) *PeerInfo {
 	return &PeerInfo{
 		AS:                      p.Config.PeerAs,
 		LocalAS:                 g.Config.As,
-		LocalID:                 net.ParseIP(g.Config.RouterId).To4(),
-		Address:                 net.ParseIP(p.Config.NeighborAddress),
+		LocalID:                 net.ParseIP(config.LocalRouterId(g, p)).To4(),
+		Address:                 config.ParseAddress(p.Config.NeighborAddress),
 		RouteReflectorClient:    p.RouteReflector.Config.RouteReflectorClient,
 		RouteReflectorClusterID: id,
 	}
 }
 
+// NewLocalPeerInfo returns the PeerInfo of paths originated by this
+// router, e.g. via the API or zebra.
+func NewLocalPeerInfo(g *config.Global) *PeerInfo {
+	id := net.ParseIP(g.Config.RouterId).To4()
+	return &PeerInfo{
+		AS:      g.Config.As,
+		ID:      id,
+		LocalAS: g.Config.As,
+		LocalID: id,
+	}
+}
+
 type Destination struct {
 	routeFamily           bgp.RouteFamily
 	nlri                  bgp.AddrPrefixInterface
@@ -121,6 +134,12 @@ type Destination struct {
 	ImplicitWithdrawnList paths
 	UpdatedPathList       paths
 	RadixKey              string
+	// counted in the number of the bests of the table
+	hasBest bool
+	// the known paths and their parent depths counted in the table
+	depths parentDepths
+	// the keys of the sources of the known paths indexed in the table
+	sources []string
 }
 
 func NewDestination(nlri bgp.AddrPrefixInterface) *Destination {
@@ -197,7 +216,7 @@ func (dd *Destination) GetKnownPathList(id string) []*Path {
 
 func (dd *Destination) GetBestPath(id string) *Path {
 	for _, p := range dd.knownPathList {
-		if p.Filtered(id) == POLICY_DIRECTION_NONE {
+		if p.Filtered(id) == POLICY_DIRECTION_NONE && !p.IsNexthopInvalid() {
 			return p
 		}
 	}
@@ -206,13 +225,33 @@ func (dd *Destination) GetBestPath(id string) *Path {
 
 func (dd *Destination) oldBest(id string) *Path {
 	for _, p := range dd.oldKnownPathList {
-		if p.Filtered(id) == POLICY_DIRECTION_NONE {
+		if p.Filtered(id) == POLICY_DIRECTION_NONE && !p.IsNexthopInvalid() {
 			return p
 		}
 	}
 	return nil
 }
 
+// BestPathChange returns the previous and the current best path for
+// the id and the reason the current one was selected, if the best path
+// changed in the last calculation.
+func (dd *Destination) BestPathChange(id string) (old, best *Path, reason BestPathReason, changed bool) {
+	old = dd.oldBest(id)
+	best = dd.GetBestPath(id)
+	if best.Equal(old) {
+		return nil, nil, BPR_UNKNOWN, false
+	}
+	reason = BPR_UNKNOWN
+	if best != nil {
+		if len(dd.GetKnownPathList(id)) == 1 {
+			reason = BPR_ONLY_PATH
+		} else {
+			reason = best.reason
+		}
+	}
+	return old, best, reason, true
+}
+
 func (dd *Destination) addWithdraw(withdraw *Path) {
 	dd.validatePath(withdraw)
 	dd.withdrawList = append(dd.withdrawList, withdraw)
@@ -242,6 +281,12 @@ func (dd *Destination) validatePath(path *Path) {
 // Modifies destination's state related to stored paths. Removes withdrawn
 // paths from known paths. Also, adds new paths to known paths.
 func (dest *Destination) Calculate() {
+	dest.calculate(nil)
+}
+
+// calculate computes the best path with the route selection options,
+// nil for the defaults.
+func (dest *Destination) calculate(options *config.RouteSelectionOptionsConfig) {
 	dest.oldKnownPathList = dest.knownPathList
 	dest.UpdatedPathList = dest.newPathList
 	// First remove the withdrawn paths.
@@ -253,7 +298,7 @@ func (dest *Destination) Calculate() {
 	// Clear new paths as we copied them.
 	dest.newPathList = make([]*Path, 0)
 	// Compute new best path
-	dest.computeKnownBestPath()
+	dest.computeKnownBestPath(options)
 }
 
 func (dest *Destination) NewFeed(id string) *Path {
@@ -314,8 +359,9 @@ func (dest *Destination) explicitWithdraw() paths {
 	for _, withdraw := range dest.withdrawList {
 		isFound := false
 		for _, path := range dest.knownPathList {
-			// We have a match if the source are same.
-			if path.GetSource().Equal(withdraw.GetSource()) {
+			// We have a match if the source and the path identifier
+			// are same.
+			if path.GetSource().Equal(withdraw.GetSource()) && path.GetPathIdentifier() == withdraw.GetPathIdentifier() {
 				isFound = true
 				path.IsWithdraw = true
 				matches = append(matches, path)
@@ -369,11 +415,11 @@ func (dest *Destination) implicitWithdraw() paths {
 			if newPath.NoImplicitWithdraw() {
 				continue
 			}
-			// Here we just check if source is same and not check if path
-			// version num. as newPaths are implicit withdrawal of old
+			// Here we just check if source and path identifier are same
+			// and not check if path version num. as newPaths are implicit withdrawal of old
 			// paths and when doing RouteRefresh (not EnhancedRouteRefresh)
 			// we get same paths again.
-			if newPath.GetSource().Equal(path.GetSource()) {
+			if newPath.GetSource().Equal(path.GetSource()) && newPath.GetPathIdentifier() == path.GetPathIdentifier() {
 				log.WithFields(log.Fields{
 					"Topic": "Table",
 					"Key":   dest.GetNlri().String(),
@@ -394,7 +440,7 @@ func (dest *Destination) implicitWithdraw() paths {
 	return implicitWithdrawn
 }
 
-func (dest *Destination) computeKnownBestPath() (*Path, BestPathReason, error) {
+func (dest *Destination) computeKnownBestPath(options *config.RouteSelectionOptionsConfig) (*Path, BestPathReason, error) {
 
 	// If we do not have any paths to this destination, then we do not have
 	// new best path.
@@ -410,7 +456,7 @@ func (dest *Destination) computeKnownBestPath() (*Path, BestPathReason, error) {
 	if len(dest.knownPathList) == 1 {
 		return dest.knownPathList[0], BPR_ONLY_PATH, nil
 	}
-	sort.Sort(dest.knownPathList)
+	sort.Sort(&pathSorter{paths: dest.knownPathList, options: options})
 	newBest := dest.knownPathList[0]
 	return newBest, newBest.reason, nil
 }
@@ -426,6 +472,20 @@ func (p paths) Swap(i, j int) {
 }
 
 func (p paths) Less(i, j int) bool {
+	return lessPath(p[i], p[j], nil)
+}
+
+// pathSorter sorts the paths with the route selection options.
+type pathSorter struct {
+	paths
+	options *config.RouteSelectionOptionsConfig
+}
+
+func (s *pathSorter) Less(i, j int) bool {
+	return lessPath(s.paths[i], s.paths[j], s.options)
+}
+
+func lessPath(path1, path2 *Path, options *config.RouteSelectionOptionsConfig) bool {
 
 	//Compares given paths and returns best path.
 	//
@@ -440,16 +500,19 @@ func (p paths) Less(i, j int) bool {
 	//	local preference value.
 	//	4.  Prefer locally originated routes (network routes, redistributed
 	//	routes, or aggregated routes) over received routes.
-	//	5.  Select the route with the shortest AS-path length.
-	//	6.  If all paths have the same AS-path length, select the path based
+	//	5.  If enable-aigp is configured and both paths carry the AIGP
+	//	attribute, select the path with the lowest AIGP metric plus the
+	//	IGP cost to the next hop (RFC7311 4.1).
+	//	6.  Select the route with the shortest AS-path length.
+	//	7.  If all paths have the same AS-path length, select the path based
 	//	on origin: IGP is preferred over EGP; EGP is preferred over
 	//	Incomplete.
-	//	7.  If the origins are the same, select the path with lowest MED
+	//	8.  If the origins are the same, select the path with lowest MED
 	//	value.
-	//	8.  If the paths have the same MED values, select the path learned
+	//	9.  If the paths have the same MED values, select the path learned
 	//	via EBGP over one learned via IBGP.
-	//	9.  Select the route with the lowest IGP cost to the next hop.
-	//	10. Select the route received from the peer with the lowest BGP
+	//	10. Select the route with the lowest IGP cost to the next hop.
+	//	11. Select the route received from the peer with the lowest BGP
 	//	router ID.
 	//
 	//	Returns None if best-path among given paths cannot be computed else best
@@ -457,9 +520,6 @@ func (p paths) Less(i, j int) bool {
 	//	Assumes paths from NC has source equal to None.
 	//
 
-	path1 := p[i]
-	path2 := p[j]
-
 	var better *Path
 	reason := BPR_UNKNOWN
 
@@ -481,6 +541,10 @@ func (p paths) Less(i, j int) bool {
 		better = compareByLocalOrigin(path1, path2)
 		reason = BPR_LOCAL_ORIGIN
 	}
+	if better == nil && options != nil && options.EnableAigp {
+		better = compareByAigp(path1, path2)
+		reason = BPR_AIGP
+	}
 	if better == nil {
 		better = compareByASPath(path1, path2)
 		reason = BPR_ASPATH
@@ -526,8 +590,14 @@ func compareByReachableNexthop(path1, path2 *Path) *Path {
 	//	Compares given paths and selects best path based on reachable next-hop.
 	//
 	//	If no path matches this criteria, return None.
-	//  However RouteServer doesn't need to check reachability, so return nil.
+	//  Reachability is reported by next-hop tracking. Paths which have never
+	//  been checked (e.g. RouteServer) are considered reachable.
 	log.Debugf("enter compareByReachableNexthop -- path1: %s, path2: %s", path1, path2)
+	if path1.IsNexthopInvalid() && !path2.IsNexthopInvalid() {
+		return path2
+	} else if !path1.IsNexthopInvalid() && path2.IsNexthopInvalid() {
+		return path1
+	}
 	return nil
 }
 
@@ -597,6 +667,29 @@ func compareByLocalOrigin(path1, path2 *Path) *Path {
 	return nil
 }
 
+func compareByAigp(path1, path2 *Path) *Path {
+	//	Select the path with the lowest AIGP metric plus the IGP cost to
+	//	the next hop.
+	//
+	//	If either path doesn't carry the AIGP attribute or both have the
+	//	same metric, return None.
+	//	The IGP cost is the one reported by next-hop tracking, zero
+	//	when it's unknown.
+	log.Debugf("enter compareByAigp")
+	aigp1, err1 := path1.GetAigp()
+	aigp2, err2 := path2.GetAigp()
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	log.Debugf("compareByAigp -- aigp1: %d, aigp2: %d", aigp1, aigp2)
+	if aigp1 == aigp2 {
+		return nil
+	} else if aigp1 < aigp2 {
+		return path1
+	}
+	return path2
+}
+
 func compareByASPath(path1, path2 *Path) *Path {
 	// Calculated the best-paths by comparing as-path lengths.
 	//
@@ -615,8 +708,8 @@ func compareByASPath(path1, path2 *Path) *Path {
 		}).Warn("can't compare ASPath because it's not present")
 	}
 
-	l1 := path1.GetAsPathLen()
-	l2 := path2.GetAsPathLen()
+	l1 := path1.getSelectionAsPathLen()
+	l2 := path2.getSelectionAsPathLen()
 
 	log.Debugf("compareByASPath -- l1: %d, l2: %d", l1, l2)
 	if l1 > l2 {
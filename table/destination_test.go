@@ -17,6 +17,7 @@ package table
 
 import (
 	//"fmt"
+	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
 	"github.com/stretchr/testify/assert"
 	"net"
@@ -154,3 +155,115 @@ func TestRadixkey(t *testing.T) {
 	assert.Equal(t, "000010100000001100100000", IpToRadixkey(net.ParseIP("10.3.32.0").To4(), 24))
 	assert.Equal(t, "000010100000001100100000", IpToRadixkey(net.ParseIP("10.3.32.0").To4(), 24))
 }
+
+func TestNewLocalPeerInfo(t *testing.T) {
+	g := &config.Global{
+		Config: config.GlobalConfig{
+			As:       65001,
+			RouterId: "10.0.0.1",
+		},
+	}
+	pi := NewLocalPeerInfo(g)
+	assert.Equal(t, uint32(65001), pi.AS)
+	assert.Equal(t, uint32(65001), pi.LocalAS)
+	assert.True(t, pi.ID.Equal(net.ParseIP("10.0.0.1")))
+	assert.True(t, pi.LocalID.Equal(net.ParseIP("10.0.0.1")))
+	assert.Nil(t, pi.Address)
+
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.0.0")
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	path := NewPath(pi, nlri, false, attrs, time.Now(), false)
+	assert.True(t, path.IsLocal())
+	assert.True(t, path.IsIBGP())
+}
+
+func TestCompareByASPathCollapsePrepends(t *testing.T) {
+	assert := assert.New(t)
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	path := func(as uint32, seq ...uint32) *Path {
+		return NewPath(&PeerInfo{AS: as, Address: net.ParseIP("10.0.0.1")}, nlri, false, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, seq)}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}, time.Now(), false)
+	}
+	prepended := path(65001, 65001, 65001, 65001, 65010)
+	other := path(65002, 65002, 65020)
+	assert.Equal(other, compareByASPath(prepended, other))
+
+	prepended.SetCollapseAsPrepends(true)
+	assert.Equal(4, prepended.GetAsPathLen())
+	assert.Equal(2, prepended.getSelectionAsPathLen())
+	assert.Nil(compareByASPath(prepended, other))
+	// the AS_PATH isn't modified
+	assert.Equal("65001 65001 65001 65010", prepended.GetAsString())
+
+	aspath := bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001, 65001}),
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001, 65002, 65001}),
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SET, []uint32{65003, 65004}),
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65004, 65004}),
+	})
+	assert.Equal(5, CollapsedAsPathLen(aspath))
+	assert.Equal(0, CollapsedAsPathLen(nil))
+}
+
+func TestCompareByAigp(t *testing.T) {
+	assert := assert.New(t)
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	path := func(addr string, med uint32, aigp ...uint64) *Path {
+		attrs := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop(addr),
+			bgp.NewPathAttributeMultiExitDisc(med),
+		}
+		for _, m := range aigp {
+			attrs = append(attrs, bgp.NewPathAttributeAigp([]bgp.AigpTLV{bgp.NewAigpTLVIgpMetric(m)}))
+		}
+		return NewPath(&PeerInfo{AS: 65001, Address: net.ParseIP(addr)}, nlri, false, attrs, time.Now(), false)
+	}
+	low := path("10.0.0.2", 100, 10)
+	high := path("10.0.0.1", 0, 20)
+	none := path("10.0.0.3", 0)
+
+	m, err := low.GetAigp()
+	assert.Nil(err)
+	assert.Equal(uint64(10), m)
+	_, err = none.GetAigp()
+	assert.NotNil(err)
+
+	assert.Equal(low, compareByAigp(low, high))
+	assert.Equal(low, compareByAigp(high, low))
+	assert.Nil(compareByAigp(low, none))
+	assert.Nil(compareByAigp(low, low))
+
+	calculate := func(options *config.RouteSelectionOptionsConfig) (*Path, BestPathReason) {
+		d := NewDestination(nlri)
+		d.addNewPath(high)
+		d.addNewPath(low)
+		d.calculate(options)
+		_, best, reason, _ := d.BestPathChange(GLOBAL_RIB_NAME)
+		return best, reason
+	}
+	// the lower MED wins without the option
+	best, reason := calculate(nil)
+	assert.Equal(high, best)
+	assert.Equal(BPR_MED, reason)
+	best, reason = calculate(&config.RouteSelectionOptionsConfig{EnableAigp: true})
+	assert.Equal(low, best)
+	assert.Equal(BPR_AIGP, reason)
+
+	// plus the IGP metric to the next hop
+	low.SetIgpMetric(15)
+	m, err = low.GetAigp()
+	assert.Nil(err)
+	assert.Equal(uint64(25), m)
+	assert.Equal(high, compareByAigp(low, high))
+	best, reason = calculate(&config.RouteSelectionOptionsConfig{EnableAigp: true})
+	assert.Equal(high, best)
+	assert.Equal(BPR_AIGP, reason)
+}
@@ -0,0 +1,50 @@
+// Copyright (C) 2016 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/osrg/gobgp/packet"
+	"time"
+)
+
+// newTestPath returns the path of the NLRI from the source with ORIGIN,
+// AS_PATH 65001 and the nexthop 10.0.0.1, in MP_REACH_NLRI for the
+// families other than IPv4 unicast. The attributes replace the ones of
+// the same type or are added.
+func newTestPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, withdraw bool, attrs ...bgp.PathAttributeInterface) *Path {
+	pattrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+	}
+	if bgp.AfiSafiToRouteFamily(nlri.AFI(), nlri.SAFI()) == bgp.RF_IPv4_UC {
+		pattrs = append(pattrs, bgp.NewPathAttributeNextHop("10.0.0.1"))
+	} else {
+		pattrs = append(pattrs, bgp.NewPathAttributeMpReachNLRI("10.0.0.1", []bgp.AddrPrefixInterface{nlri}))
+	}
+	for _, a := range attrs {
+		replaced := false
+		for i, p := range pattrs {
+			if p.GetType() == a.GetType() {
+				pattrs[i] = a
+				replaced = true
+			}
+		}
+		if !replaced {
+			pattrs = append(pattrs, a)
+		}
+	}
+	return NewPath(source, nlri, withdraw, pattrs, time.Now(), false)
+}
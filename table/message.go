@@ -198,11 +198,36 @@ func UpdatePathAttrs4ByteAs(msg *bgp.BGPUpdate) error {
 	return nil
 }
 
+// advertisedNlri returns the NLRI of the path with its path identifier,
+// which is encoded for the peers Add-Path send is negotiated with. The
+// NLRI is shared with the received message, so it's copied when the
+// identifier differs.
+func advertisedNlri(path *Path) bgp.AddrPrefixInterface {
+	id := path.GetPathIdentifier()
+	switch n := path.GetNlri().(type) {
+	case *bgp.IPAddrPrefix:
+		if n.PathIdentifier != id {
+			c := *n
+			c.PathIdentifier = id
+			return &c
+		}
+	case *bgp.IPv6AddrPrefix:
+		if n.PathIdentifier != id {
+			c := *n
+			c.PathIdentifier = id
+			return &c
+		}
+	}
+	return path.GetNlri()
+}
+
 func createUpdateMsgFromPath(path *Path, msg *bgp.BGPMessage) *bgp.BGPMessage {
 	rf := path.GetRouteFamily()
 
-	if rf == bgp.RF_IPv4_UC {
-		nlri := path.GetNlri().(*bgp.IPAddrPrefix)
+	// IPv4 NLRI with an IPv6 next hop (RFC5549) is advertised in
+	// MP_REACH_NLRI
+	if rf == bgp.RF_IPv4_UC && (path.IsWithdraw || path.getPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI) == nil) {
+		nlri := advertisedNlri(path).(*bgp.IPAddrPrefix)
 		if path.IsWithdraw {
 			if msg != nil {
 				u := msg.Body.(*bgp.BGPUpdate)
@@ -227,27 +252,30 @@ func createUpdateMsgFromPath(path *Path, msg *bgp.BGPMessage) *bgp.BGPMessage {
 				for _, p := range u.PathAttributes {
 					if p.GetType() == bgp.BGP_ATTR_TYPE_MP_UNREACH_NLRI {
 						unreach := p.(*bgp.PathAttributeMpUnreachNLRI)
-						unreach.Value = append(unreach.Value, path.GetNlri())
+						unreach.Value = append(unreach.Value, advertisedNlri(path))
 					}
 				}
 			} else {
-				var nlris []bgp.AddrPrefixInterface
-				attr := path.getPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
-				if attr == nil {
-					// for bmp post-policy
-					attr = path.getPathAttr(bgp.BGP_ATTR_TYPE_MP_UNREACH_NLRI)
-					nlris = attr.(*bgp.PathAttributeMpUnreachNLRI).Value
-				} else {
-					nlris = attr.(*bgp.PathAttributeMpReachNLRI).Value
-				}
-
+				// the MP_REACH_NLRI (or MP_UNREACH_NLRI for bmp
+				// post-policy) attribute is shared by all the
+				// paths in the received message. withdraw only
+				// the NLRI of this path.
+				nlris := []bgp.AddrPrefixInterface{advertisedNlri(path)}
+				unreach := bgp.NewPathAttributeMpUnreachNLRI(nlris)
 				clonedAttrs := path.GetPathAttrs()
+				found := false
 				for i, a := range clonedAttrs {
 					if a.GetType() == bgp.BGP_ATTR_TYPE_MP_UNREACH_NLRI || a.GetType() == bgp.BGP_ATTR_TYPE_MP_REACH_NLRI {
-						clonedAttrs[i] = bgp.NewPathAttributeMpUnreachNLRI(nlris)
+						clonedAttrs[i] = unreach
+						found = true
 						break
 					}
 				}
+				// a path retained only with its NLRI, e.g. a stale
+				// route being purged, has no MP attribute to replace
+				if !found {
+					clonedAttrs = append(clonedAttrs, unreach)
+				}
 				return bgp.NewBGPUpdateMessage(nil, clonedAttrs, nil)
 			}
 		} else {
@@ -256,14 +284,27 @@ func createUpdateMsgFromPath(path *Path, msg *bgp.BGPMessage) *bgp.BGPMessage {
 				for _, p := range u.PathAttributes {
 					if p.GetType() == bgp.BGP_ATTR_TYPE_MP_REACH_NLRI {
 						reach := p.(*bgp.PathAttributeMpReachNLRI)
-						reach.Value = append(reach.Value, path.GetNlri())
+						reach.Value = append(reach.Value, advertisedNlri(path))
 					}
 				}
 			} else {
-				// we don't need to clone here but we
-				// might merge path to this message in
-				// the future so let's clone anyway.
-				return bgp.NewBGPUpdateMessage(nil, path.GetPathAttrs(), nil)
+				// the MP_REACH_NLRI attribute is shared by all
+				// the paths in the received message. create a
+				// new one having only the NLRI of this path as
+				// it is, including its route distinguisher. we
+				// might merge path to this message in the
+				// future so it also must not be shared.
+				clonedAttrs := path.GetPathAttrs()
+				for i, a := range clonedAttrs {
+					if a.GetType() == bgp.BGP_ATTR_TYPE_MP_REACH_NLRI {
+						reach := a.(*bgp.PathAttributeMpReachNLRI)
+						nreach := bgp.NewPathAttributeMpReachNLRI(reach.Nexthop.String(), []bgp.AddrPrefixInterface{advertisedNlri(path)})
+						nreach.LinkLocalNexthop = reach.LinkLocalNexthop
+						clonedAttrs[i] = nreach
+						break
+					}
+				}
+				return bgp.NewBGPUpdateMessage(nil, clonedAttrs, nil)
 			}
 		}
 	}
@@ -271,13 +312,65 @@ func createUpdateMsgFromPath(path *Path, msg *bgp.BGPMessage) *bgp.BGPMessage {
 }
 
 type bucket struct {
-	attrs []byte
-	paths []*Path
+	attrs     []byte
+	paths     []*Path
+	messages  []int
+	mergeable bool
+}
+
+// PackBucket is a group of paths in the report of the packing. The
+// IPv4 unicast paths having the same path attributes are merged into
+// the same UPDATE messages. The other paths are packed alone.
+type PackBucket struct {
+	Paths []*Path
+	// Messages[i] is the index of the message Paths[i] is packed in.
+	// The paths of a bucket are split into more than one message when
+	// a message would exceed the maximum length.
+	Messages  []int
+	Mergeable bool
+}
+
+// PackReport tells how the paths were packed into the UPDATE messages,
+// in the order the buckets were first seen in the path list.
+type PackReport struct {
+	Buckets []PackBucket
+}
+
+// Splits returns the number of the additional messages built because
+// the paths sharing the path attributes didn't fit in one message.
+func (r PackReport) Splits() int {
+	n := 0
+	for _, b := range r.Buckets {
+		for i := 1; i < len(b.Messages); i++ {
+			if b.Messages[i] != b.Messages[i-1] {
+				n++
+			}
+		}
+	}
+	return n
 }
 
 func CreateUpdateMsgFromPaths(pathList []*Path) []*bgp.BGPMessage {
+	msgs, _ := CreateUpdateMsgFromPathsWithReport(pathList, bgp.BGP_MAX_MESSAGE_LENGTH)
+	return msgs
+}
+
+// CreateUpdateMsgFromPathsWithReport builds the UPDATE messages for the
+// paths, merging the paths sharing the path attributes into messages up
+// to maxLen bytes, and reports how the paths were grouped. The output
+// is deterministic for a given path list: the messages of the paths
+// which can't be merged come first, then the ones of the buckets in the
+// order they were first seen. maxLen is BGP_MAX_MESSAGE_LENGTH if not
+// positive.
+func CreateUpdateMsgFromPathsWithReport(pathList []*Path, maxLen int) ([]*bgp.BGPMessage, PackReport) {
 	var msgs []*bgp.BGPMessage
+	if maxLen <= 0 || maxLen > bgp.BGP_MAX_MESSAGE_LENGTH {
+		maxLen = bgp.BGP_MAX_MESSAGE_LENGTH
+	}
 
+	// the buckets in the order they were first seen, including the
+	// ones of the paths which can't be merged
+	buckets := make([]*bucket, 0)
 	pathByAttrs := make(map[uint32][]*bucket)
 	for _, path := range pathList {
 		if path == nil {
@@ -290,6 +383,9 @@ func CreateUpdateMsgFromPaths(pathList []*Path) []*bgp.BGPMessage {
 			if p.IsWithdraw {
 				return false
 			}
+			if p.getPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI) != nil {
+				return false
+			}
 			return true
 		}(path)
 
@@ -305,65 +401,75 @@ func CreateUpdateMsgFromPaths(pathList []*Path) []*bgp.BGPMessage {
 				return h.Sum32(), total.Bytes()
 			}(path)
 
-			if bl, y := pathByAttrs[key]; y {
-				found := false
-				for _, b := range bl {
-					if bytes.Compare(b.attrs, attrs) == 0 {
-						b.paths = append(b.paths, path)
-						found = true
-						break
-					}
-				}
-				if found == false {
-					nb := &bucket{
-						attrs: attrs,
-						paths: []*Path{path},
-					}
-					pathByAttrs[key] = append(pathByAttrs[key], nb)
+			found := false
+			for _, b := range pathByAttrs[key] {
+				if bytes.Compare(b.attrs, attrs) == 0 {
+					b.paths = append(b.paths, path)
+					found = true
+					break
 				}
-			} else {
+			}
+			if found == false {
 				nb := &bucket{
-					attrs: attrs,
-					paths: []*Path{path},
+					attrs:     attrs,
+					paths:     []*Path{path},
+					mergeable: true,
 				}
-				pathByAttrs[key] = []*bucket{nb}
+				pathByAttrs[key] = append(pathByAttrs[key], nb)
+				buckets = append(buckets, nb)
 			}
 		} else {
 			msg := createUpdateMsgFromPath(path, nil)
 			msgs = append(msgs, msg)
+			buckets = append(buckets, &bucket{
+				paths:    []*Path{path},
+				messages: []int{len(msgs) - 1},
+			})
 		}
 	}
 
-	for _, bList := range pathByAttrs {
-		for _, b := range bList {
-			var msg *bgp.BGPMessage
-			for i, path := range b.paths {
-				if i == 0 {
+	for _, b := range buckets {
+		if !b.mergeable {
+			continue
+		}
+		var msg *bgp.BGPMessage
+		for i, path := range b.paths {
+			if i == 0 {
+				msg = createUpdateMsgFromPath(path, nil)
+				msgs = append(msgs, msg)
+			} else {
+				msgLen := func(u *bgp.BGPUpdate) int {
+					attrsLen := 0
+					for _, a := range u.PathAttributes {
+						attrsLen += a.Len()
+					}
+					// Header + Update (WithdrawnRoutesLen +
+					// TotalPathAttributeLen + attributes + maxlen of
+					// NLRI). Note that we try to add one NLRI.
+					return 19 + 2 + 2 + attrsLen + (len(u.NLRI)+1)*5
+				}(msg.Body.(*bgp.BGPUpdate))
+
+				if msgLen+32 > maxLen {
+					// don't marge
 					msg = createUpdateMsgFromPath(path, nil)
 					msgs = append(msgs, msg)
 				} else {
-					msgLen := func(u *bgp.BGPUpdate) int {
-						attrsLen := 0
-						for _, a := range u.PathAttributes {
-							attrsLen += a.Len()
-						}
-						// Header + Update (WithdrawnRoutesLen +
-						// TotalPathAttributeLen + attributes + maxlen of
-						// NLRI). Note that we try to add one NLRI.
-						return 19 + 2 + 2 + attrsLen + (len(u.NLRI)+1)*5
-					}(msg.Body.(*bgp.BGPUpdate))
-
-					if msgLen+32 > bgp.BGP_MAX_MESSAGE_LENGTH {
-						// don't marge
-						msg = createUpdateMsgFromPath(path, nil)
-						msgs = append(msgs, msg)
-					} else {
-						createUpdateMsgFromPath(path, msg)
-					}
+					createUpdateMsgFromPath(path, msg)
 				}
 			}
+			b.messages = append(b.messages, len(msgs)-1)
 		}
 	}
 
-	return msgs
+	report := PackReport{
+		Buckets: make([]PackBucket, 0, len(buckets)),
+	}
+	for _, b := range buckets {
+		report.Buckets = append(report.Buckets, PackBucket{
+			Paths:     b.paths,
+			Messages:  b.messages,
+			Mergeable: b.mergeable,
+		})
+	}
+	return msgs, report
 }
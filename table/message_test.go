@@ -16,8 +16,10 @@
 package table
 
 import (
+	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
 	"github.com/stretchr/testify/assert"
+	"net"
 	"testing"
 	"time"
 )
@@ -345,3 +347,259 @@ func TestBMP(t *testing.T) {
 	pList := ProcessMessage(msg, peerR1(), time.Now())
 	CreateUpdateMsgFromPaths(pList)
 }
+
+func TestReflectVPNv4RouteDistinguisher(t *testing.T) {
+	assert := assert.New(t)
+	rd1 := bgp.NewRouteDistinguisherTwoOctetAS(65000, 100)
+	rd2 := bgp.NewRouteDistinguisherIPAddressAS("10.0.0.1", 200)
+	nlris := []bgp.AddrPrefixInterface{
+		bgp.NewLabeledVPNIPAddrPrefix(24, "10.10.10.0", *bgp.NewMPLSLabelStack(100), rd1),
+		bgp.NewLabeledVPNIPAddrPrefix(24, "10.10.10.0", *bgp.NewMPLSLabelStack(200), rd2),
+	}
+	p := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI("10.0.0.1", nlris),
+		bgp.NewPathAttributeLocalPref(100),
+	}
+	msg := bgp.NewBGPUpdateMessage(nil, p, nil)
+	pList := ProcessMessage(msg, peerR1(), time.Now())
+	assert.Equal(2, len(pList))
+	assert.Equal(rd1, pList[0].GetRouteDistinguisher())
+	assert.Equal(rd2, pList[1].GetRouteDistinguisher())
+
+	global := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	n := &config.Neighbor{
+		Config: config.NeighborConfig{
+			PeerAs:   65000,
+			PeerType: config.PEER_TYPE_INTERNAL,
+		},
+		RouteReflector: config.RouteReflector{
+			Config: config.RouteReflectorConfig{
+				RouteReflectorClient:    true,
+				RouteReflectorClusterId: "10.0.0.254",
+			},
+		},
+	}
+	for _, withdraw := range []bool{false, true} {
+		// reflect only the first path
+		path := pList[0].Clone(withdraw)
+		path.UpdatePathAttrs(global, n)
+		msgs := CreateUpdateMsgFromPaths([]*Path{path})
+		assert.Equal(1, len(msgs))
+		buf, err := msgs[0].Serialize()
+		assert.Nil(err)
+		m, err := bgp.ParseBGPMessage(buf)
+		assert.Nil(err)
+
+		var sent []bgp.AddrPrefixInterface
+		for _, a := range m.Body.(*bgp.BGPUpdate).PathAttributes {
+			switch a.(type) {
+			case *bgp.PathAttributeMpReachNLRI:
+				sent = a.(*bgp.PathAttributeMpReachNLRI).Value
+			case *bgp.PathAttributeMpUnreachNLRI:
+				sent = a.(*bgp.PathAttributeMpUnreachNLRI).Value
+			}
+		}
+		assert.Equal(1, len(sent))
+		expected, _ := nlris[0].(*bgp.LabeledVPNIPAddrPrefix).RD.Serialize()
+		actual, _ := sent[0].(*bgp.LabeledVPNIPAddrPrefix).RD.Serialize()
+		assert.Equal(expected, actual)
+		assert.Equal(nlris[0].String(), sent[0].String())
+	}
+}
+
+func TestIPv4NexthopIPv6(t *testing.T) {
+	assert := assert.New(t)
+	p := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0"), bgp.NewIPAddrPrefix(24, "10.10.20.0")}
+	pList := ProcessMessage(bgp.NewBGPUpdateMessage(nil, p, nlri), peerR1(), time.Now())
+	assert.Equal(2, len(pList))
+
+	global := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	n := &config.Neighbor{
+		Config: config.NeighborConfig{
+			PeerAs:   65002,
+			PeerType: config.PEER_TYPE_EXTERNAL,
+		},
+	}
+	n.Transport.Config.LocalAddress = "2001:db8::1"
+
+	// not negotiated
+	path := pList[0].Clone(false)
+	path.UpdatePathAttrs(global, n)
+	assert.True(path.IsWithdraw)
+
+	n.State.ExtendedNexthop = true
+	paths := []*Path{pList[0].Clone(false), pList[1].Clone(false)}
+	for _, path := range paths {
+		path.UpdatePathAttrs(global, n)
+		assert.False(path.IsWithdraw)
+		assert.Equal("2001:db8::1", path.GetNexthop().String())
+		assert.Nil(path.getPathAttr(bgp.BGP_ATTR_TYPE_NEXT_HOP))
+	}
+	// the NLRI is carried in MP_REACH_NLRI one by one
+	msgs := CreateUpdateMsgFromPaths(paths)
+	assert.Equal(2, len(msgs))
+	for i, msg := range msgs {
+		buf, err := msg.Serialize()
+		assert.Nil(err)
+		m, err := bgp.ParseBGPMessage(buf)
+		assert.Nil(err)
+		u := m.Body.(*bgp.BGPUpdate)
+		assert.Equal(0, len(u.NLRI))
+		var reach *bgp.PathAttributeMpReachNLRI
+		for _, a := range u.PathAttributes {
+			assert.NotEqual(bgp.BGP_ATTR_TYPE_NEXT_HOP, a.GetType())
+			if r, ok := a.(*bgp.PathAttributeMpReachNLRI); ok {
+				reach = r
+			}
+		}
+		assert.NotNil(reach)
+		assert.Equal(uint16(bgp.AFI_IP), reach.AFI)
+		assert.Equal("2001:db8::1", reach.Nexthop.String())
+		assert.Equal(nlri[i].String(), reach.Value[0].String())
+	}
+
+	// back to an IPv4 next hop
+	path = paths[0].Clone(false)
+	path.SetNexthop(net.ParseIP("10.0.0.254"))
+	assert.Nil(path.getPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI))
+	assert.Equal("10.0.0.254", path.GetNexthop().String())
+	msgs = CreateUpdateMsgFromPaths([]*Path{path})
+	assert.Equal(1, len(msgs[0].Body.(*bgp.BGPUpdate).NLRI))
+}
+
+func TestMpWithdrawFromNlri(t *testing.T) {
+	assert := assert.New(t)
+	rd := bgp.NewRouteDistinguisherTwoOctetAS(65000, 100)
+	nlri := bgp.NewLabeledVPNIPAddrPrefix(24, "10.10.10.0", *bgp.NewMPLSLabelStack(100), rd)
+	// only the NLRI is retained
+	path := NewPath(peerR1(), nlri, true, nil, time.Now(), false)
+	msgs := CreateUpdateMsgFromPaths([]*Path{path})
+	assert.Equal(1, len(msgs))
+	buf, err := msgs[0].Serialize()
+	assert.Nil(err)
+	m, err := bgp.ParseBGPMessage(buf)
+	assert.Nil(err)
+	u := m.Body.(*bgp.BGPUpdate)
+	assert.Equal(1, len(u.PathAttributes))
+	unreach, ok := u.PathAttributes[0].(*bgp.PathAttributeMpUnreachNLRI)
+	assert.True(ok)
+	assert.Equal(1, len(unreach.Value))
+	assert.Equal(nlri.String(), unreach.Value[0].String())
+}
+
+func TestPathIdentifier(t *testing.T) {
+	assert := assert.New(t)
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	paths := make([]*Path, 0, 2)
+	for _, id := range []uint32{1, 2} {
+		path := NewPath(peerR1(), nlri, false, attrs, time.Now(), false)
+		path.SetPathIdentifier(id)
+		assert.Equal(id, path.GetPathIdentifier())
+		paths = append(paths, path)
+	}
+	v6nlri := bgp.NewIPv6AddrPrefix(64, "2001:db8:1::")
+	v6 := NewPath(peerR1(), v6nlri, false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI("2001:db8::1", []bgp.AddrPrefixInterface{v6nlri}),
+	}, time.Now(), false)
+	v6.SetPathIdentifier(10)
+	paths = append(paths, v6, paths[0].Clone(true))
+
+	options := &bgp.MarshallingOption{AddPath: map[bgp.RouteFamily]bgp.BGPAddPathMode{
+		bgp.RF_IPv4_UC: bgp.BGP_ADD_PATH_BOTH,
+		bgp.RF_IPv6_UC: bgp.BGP_ADD_PATH_BOTH,
+	}}
+	ids := make(map[string][]uint32)
+	for _, msg := range CreateUpdateMsgFromPaths(paths) {
+		buf, err := msg.Serialize(options)
+		assert.Nil(err)
+		m, err := bgp.ParseBGPMessage(buf, options)
+		assert.Nil(err)
+		u := m.Body.(*bgp.BGPUpdate)
+		for _, n := range u.NLRI {
+			ids["nlri"] = append(ids["nlri"], n.PathIdentifier)
+		}
+		for _, n := range u.WithdrawnRoutes {
+			ids["withdrawn"] = append(ids["withdrawn"], n.PathIdentifier)
+		}
+		for _, a := range u.PathAttributes {
+			if reach, ok := a.(*bgp.PathAttributeMpReachNLRI); ok {
+				for _, n := range reach.Value {
+					ids["reach"] = append(ids["reach"], n.(*bgp.IPv6AddrPrefix).PathIdentifier)
+				}
+			}
+		}
+	}
+	assert.Equal([]uint32{1, 2}, ids["nlri"])
+	assert.Equal([]uint32{1}, ids["withdrawn"])
+	assert.Equal([]uint32{10}, ids["reach"])
+	// the NLRI shared by the paths is left as it is
+	assert.Equal(uint32(0), nlri.PathIdentifier)
+	assert.Equal(uint32(0), v6nlri.PathIdentifier)
+}
+
+func TestCreateUpdateMsgFromPathsWithReport(t *testing.T) {
+	assert := assert.New(t)
+	path := func(prefix string, med uint32) *Path {
+		return newTestPath(peerR1(), bgp.NewIPAddrPrefix(24, prefix), false, bgp.NewPathAttributeMultiExitDisc(med))
+	}
+	v6nlri := bgp.NewIPv6AddrPrefix(64, "2001:db8:1::")
+	v6 := newTestPath(peerR1(), v6nlri, false, bgp.NewPathAttributeMpReachNLRI("2001:db8::1", []bgp.AddrPrefixInterface{v6nlri}))
+	paths := []*Path{
+		path("10.10.1.0", 10),
+		path("10.10.2.0", 20),
+		v6,
+		path("10.10.3.0", 10),
+		nil,
+		path("10.10.4.0", 10),
+		path("10.10.5.0", 10),
+		path("10.10.6.0", 20),
+	}
+
+	msgs, report := CreateUpdateMsgFromPathsWithReport(paths, 0)
+	assert.Equal(3, len(msgs))
+	assert.Equal(3, len(report.Buckets))
+	assert.Equal([]*Path{v6}, report.Buckets[2].Paths)
+	assert.Equal([]int{0}, report.Buckets[2].Messages)
+	assert.False(report.Buckets[2].Mergeable)
+	assert.Equal([]*Path{paths[0], paths[3], paths[5], paths[6]}, report.Buckets[0].Paths)
+	assert.Equal([]int{1, 1, 1, 1}, report.Buckets[0].Messages)
+	assert.True(report.Buckets[0].Mergeable)
+	assert.Equal([]int{2, 2}, report.Buckets[1].Messages)
+	assert.Equal(0, report.Splits())
+	assert.Equal(4, len(msgs[1].Body.(*bgp.BGPUpdate).NLRI))
+
+	// the same output for the same input
+	again, _ := CreateUpdateMsgFromPathsWithReport(paths, 0)
+	for i := range msgs {
+		b1, _ := msgs[i].Serialize()
+		b2, _ := again[i].Serialize()
+		assert.Equal(b1, b2)
+	}
+
+	// a message fits two NLRIs, the ones of the first bucket are split
+	b, _ := msgs[1].Serialize()
+	maxLen := len(b) - 2*4 + 32 + 5
+	msgs, report = CreateUpdateMsgFromPathsWithReport(paths, maxLen)
+	assert.Equal([]int{1, 1, 2, 2}, report.Buckets[0].Messages)
+	assert.Equal([]int{3, 3}, report.Buckets[1].Messages)
+	assert.Equal(1, report.Splits())
+	assert.Equal(4, len(msgs))
+	for _, m := range msgs {
+		b, _ := m.Serialize()
+		assert.True(len(b) <= maxLen)
+	}
+}
@@ -22,8 +22,10 @@ import (
 	api "github.com/osrg/gobgp/api"
 	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
+	"hash/fnv"
 	"math"
 	"net"
+	"sort"
 	"time"
 )
 
@@ -52,18 +54,26 @@ type originInfo struct {
 	noImplicitWithdraw bool
 	validation         config.RpkiValidationResultType
 	isFromZebra        bool
+	collapsePrepends   bool
 	key                string
 	uuid               []byte
+	pathIdentifier     uint32
 }
 
 type Path struct {
-	info       *originInfo
-	IsWithdraw bool
-	pathAttrs  []bgp.PathAttributeInterface
-	reason     BestPathReason
-	parent     *Path
-	dels       []bgp.BGPAttrType
-	filtered   map[string]PolicyDirection
+	info           *originInfo
+	IsWithdraw     bool
+	pathAttrs      []bgp.PathAttributeInterface
+	reason         BestPathReason
+	parent         *Path
+	dels           []bgp.BGPAttrType
+	filtered       map[string]PolicyDirection
+	nexthopInvalid bool
+	igpMetric      uint32
+	stale          bool
+	// AS_PATH allocated by PrependAsn for this path only, which
+	// further prepends can modify in place
+	ownedAsPath *bgp.PathAttributeAsPath
 }
 
 func NewPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, pattrs []bgp.PathAttributeInterface, timestamp time.Time, noImplicitWithdraw bool) *Path {
@@ -82,6 +92,7 @@ func NewPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, pa
 			source:             source,
 			timestamp:          timestamp,
 			noImplicitWithdraw: noImplicitWithdraw,
+			pathIdentifier:     nlriPathIdentifier(nlri),
 		},
 		IsWithdraw: isWithdraw,
 		pathAttrs:  pattrs,
@@ -102,35 +113,48 @@ func cloneAsPath(asAttr *bgp.PathAttributeAsPath) *bgp.PathAttributeAsPath {
 
 func (path *Path) UpdatePathAttrs(global *config.Global, peer *config.Neighbor) {
 
+	// the paths to the route server clients are advertised unmodified
+	// but for the attributes StripAttributes removes
 	if peer.RouteServer.Config.RouteServerClient {
 		return
 	}
 
 	localAddress := net.ParseIP(peer.Transport.Config.LocalAddress)
+	reflected := path.IsTransparentlyReflected(peer)
+	transparent := peer.Config.AttributeTransparency || reflected
 	if peer.Config.PeerType == config.PEER_TYPE_EXTERNAL {
-		// NEXTHOP handling
-		path.SetNexthop(localAddress)
-
-		// AS_PATH handling
-		path.PrependAsn(global.Config.As, 1)
-
-		// MED Handling
-		if med := path.getPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC); med != nil && !path.IsLocal() {
-			path.delPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC)
-		}
+		if transparent {
+			// only the mandatory AS_PATH handling. a locally
+			// generated path has no next hop to pass through.
+			path.PrependAsn(global.Config.As, 1)
+			if path.IsLocal() && (len(path.GetNexthop()) == 0 || path.hasZeroNexthop()) {
+				path.SetNexthop(localAddress)
+			}
+		} else {
+			// NEXTHOP handling
+			path.SetNexthop(localAddress)
 
-		// remove local-pref attribute
-		if pref := path.getPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF); pref != nil && !config.IsConfederationMember(global, peer) {
-			path.delPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+			path.updateEbgpAttrs(global, config.IsConfederationMember(global, peer))
 		}
 
 	} else if peer.Config.PeerType == config.PEER_TYPE_INTERNAL {
 		// NEXTHOP handling for iBGP
 		// if the path generated locally set local address as nexthop.
-		// if not, don't modify it.
+		// if not, don't modify it unless its nexthop is unusable and
+		// configured to do so.
 		// TODO: NEXT-HOP-SELF support
 		nexthop := path.GetNexthop()
-		if path.IsLocal() && (nexthop.Equal(net.ParseIP("0.0.0.0")) || nexthop.Equal(net.ParseIP("::"))) {
+		if path.IsLocal() {
+			if path.hasZeroNexthop() {
+				path.SetNexthop(localAddress)
+			}
+		} else if !transparent && peer.Config.UnusableNexthopAction == config.UNUSABLE_NEXTHOP_ACTION_TYPE_SELF && path.HasUnusableNexthop(localAddress) {
+			log.WithFields(log.Fields{
+				"Topic":   "Peer",
+				"Key":     peer.Config.NeighborAddress,
+				"Prefix":  path.GetNlri().String(),
+				"Nexthop": nexthop,
+			}).Debug("rewrite unusable nexthop to self")
 			path.SetNexthop(localAddress)
 		}
 
@@ -144,10 +168,26 @@ func (path *Path) UpdatePathAttrs(global *config.Global, peer *config.Neighbor)
 		// For iBGP peers we are required to send local-pref attribute
 		// for connected or local prefixes.
 		// We set default local-pref 100.
-		if pref := path.getPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF); pref == nil || !path.IsLocal() {
+		if pref := path.getPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF); pref == nil || (!path.IsLocal() && !transparent) {
 			path.setPathAttr(bgp.NewPathAttributeLocalPref(100))
 		}
 
+		// MED handling for iBGP
+		medAction := peer.Config.IbgpMedAction
+		if transparent {
+			medAction = config.IBGP_MED_ACTION_TYPE_PRESERVE
+		}
+		switch medAction {
+		case config.IBGP_MED_ACTION_TYPE_ZERO:
+			path.setPathAttr(bgp.NewPathAttributeMultiExitDisc(0))
+		case config.IBGP_MED_ACTION_TYPE_IGP:
+			// routes redistributed from zebra carry the IGP metric
+			// as MED. the others get the metric to their nexthop.
+			if !path.IsFromZebra() {
+				path.setPathAttr(bgp.NewPathAttributeMultiExitDisc(path.GetIgpMetric()))
+			}
+		}
+
 		// RFC4456: BGP Route Reflection
 		// 8. Avoiding Routing Information Loops
 		info := path.GetSource()
@@ -155,7 +195,11 @@ func (path *Path) UpdatePathAttrs(global *config.Global, peer *config.Neighbor)
 			// This attribute will carry the BGP Identifier of the originator of the route in the local AS.
 			// A BGP speaker SHOULD NOT create an ORIGINATOR_ID attribute if one already exists.
 			if path.getPathAttr(bgp.BGP_ATTR_TYPE_ORIGINATOR_ID) == nil {
-				path.setPathAttr(bgp.NewPathAttributeOriginatorId(info.ID.String()))
+				id := info.ID.String()
+				if path.IsLocal() {
+					id = config.LocalRouterId(global, peer)
+				}
+				path.setPathAttr(bgp.NewPathAttributeOriginatorId(id))
 			}
 			// When an RR reflects a route, it MUST prepend the local CLUSTER_ID to the CLUSTER_LIST.
 			// If the CLUSTER_LIST is empty, it MUST create a new one.
@@ -178,6 +222,170 @@ func (path *Path) UpdatePathAttrs(global *config.Global, peer *config.Neighbor)
 			"Key":   peer.Config.NeighborAddress,
 		}).Warnf("invalid peer type: %d", peer.Config.PeerType)
 	}
+
+	path.checkExtendedNexthop(peer)
+
+	if peer.Config.SortExtCommunities && !reflected {
+		path.SortExtCommunities()
+	}
+
+	path.stripAttributes(peer)
+}
+
+// stripAttributes removes the attributes configured to be stripped on
+// egress to the peer.
+func (path *Path) stripAttributes(peer *config.Neighbor) {
+	for _, typ := range peer.Config.StripAttributeOnEgressList {
+		if path.getPathAttr(bgp.BGPAttrType(typ)) != nil {
+			path.delPathAttr(bgp.BGPAttrType(typ))
+		}
+	}
+}
+
+// StripAttributes returns the path without the attributes configured
+// to be stripped on egress to the peer. It's for the route server
+// clients, whose paths are advertised without UpdatePathAttrs. The path
+// is cloned when there is something to strip, so the one in the RIB
+// isn't modified.
+func (path *Path) StripAttributes(peer *config.Neighbor) *Path {
+	if path.IsWithdraw || len(peer.Config.StripAttributeOnEgressList) == 0 {
+		return path
+	}
+	p := path.Clone(path.IsWithdraw)
+	p.stripAttributes(peer)
+	return p
+}
+
+// IsTransparentlyReflected tells whether the path learned from an iBGP
+// peer is reflected to the route reflector client without modifying
+// its attributes other than ORIGINATOR_ID and CLUSTER_LIST.
+func (path *Path) IsTransparentlyReflected(peer *config.Neighbor) bool {
+	if c := peer.RouteReflector.Config; !c.RouteReflectorClient || !c.TransparentReflection {
+		return false
+	}
+	info := path.GetSource()
+	return !path.IsLocal() && info.AS == info.LocalAS
+}
+
+func (path *Path) hasZeroNexthop() bool {
+	nexthop := path.GetNexthop()
+	return nexthop.Equal(net.ParseIP("0.0.0.0")) || nexthop.Equal(net.ParseIP("::"))
+}
+
+// HasUnusableNexthop tells whether the nexthop of the path received from
+// a peer can't be used by iBGP peers: unset, our own address or a martian
+// address.
+func (path *Path) HasUnusableNexthop(localAddress net.IP) bool {
+	nexthop := path.GetNexthop()
+	if path.hasZeroNexthop() || (localAddress != nil && nexthop.Equal(localAddress)) {
+		return true
+	}
+	if nexthop.IsLoopback() || nexthop.IsMulticast() {
+		return true
+	}
+	if ip := nexthop.To4(); ip != nil {
+		// 0.0.0.0/8 and 240.0.0.0/4 including the limited broadcast
+		return ip[0] == 0 || ip[0] >= 240
+	}
+	return false
+}
+
+// updateEbgpAttrs does the part of UpdatePathAttrs for eBGP peers which
+// doesn't depend on the peer, except whether the peer is a member of
+// our confederation.
+func (path *Path) updateEbgpAttrs(global *config.Global, confed bool) {
+	// AS_PATH handling
+	path.PrependAsn(global.Config.As, 1)
+
+	// MED Handling
+	if med := path.getPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC); med != nil && !path.IsLocal() {
+		path.delPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC)
+	}
+
+	// remove local-pref attribute
+	if pref := path.getPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF); pref != nil && !confed {
+		path.delPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	}
+}
+
+// checkExtendedNexthop withdraws the path which can't be advertised to
+// the peer because of its next hop.
+func (path *Path) checkExtendedNexthop(peer *config.Neighbor) {
+	// RFC5549, IPv4 NLRI can't be advertised with an IPv6 next hop
+	// unless the extended next hop encoding is negotiated
+	if nexthop := path.GetNexthop(); !path.IsWithdraw && path.GetRouteFamily() == bgp.RF_IPv4_UC && len(nexthop) > 0 && nexthop.To4() == nil && !peer.State.ExtendedNexthop {
+		log.WithFields(log.Fields{
+			"Topic":   "Peer",
+			"Key":     peer.Config.NeighborAddress,
+			"Prefix":  path.GetNlri().String(),
+			"Nexthop": nexthop,
+		}).Debug("withdraw path with IPv6 nexthop, extended nexthop isn't negotiated")
+		path.IsWithdraw = true
+	}
+}
+
+type sharedAttrsKey struct {
+	path   *Path
+	confed bool
+	sort   bool
+}
+
+// PathAttrsUpdater transforms paths for many peers as UpdatePathAttrs
+// does. The transformation common to eBGP peers (AS_PATH prepend, MED
+// and LOCAL_PREF removal, sorting extended communities) is done once
+// per path on a shared clone, and only the next hop is set on a clone
+// of it per peer. The updater holds the shared clones, so it should be
+// used for a batch of advertisements and dropped.
+type PathAttrsUpdater struct {
+	global *config.Global
+	shared map[sharedAttrsKey]*Path
+}
+
+func NewPathAttrsUpdater(global *config.Global) *PathAttrsUpdater {
+	return &PathAttrsUpdater{
+		global: global,
+		shared: make(map[sharedAttrsKey]*Path),
+	}
+}
+
+// Update returns a clone of the path with the attributes updated to be
+// advertised to the peer. The path isn't modified.
+func (u *PathAttrsUpdater) Update(path *Path, peer *config.Neighbor) *Path {
+	if peer.RouteServer.Config.RouteServerClient || peer.Config.AttributeTransparency || peer.Config.PeerType != config.PEER_TYPE_EXTERNAL {
+		p := path.Clone(path.IsWithdraw)
+		p.UpdatePathAttrs(u.global, peer)
+		return p
+	}
+	key := sharedAttrsKey{
+		path:   path,
+		confed: config.IsConfederationMember(u.global, peer),
+		sort:   peer.Config.SortExtCommunities,
+	}
+	shared, ok := u.shared[key]
+	if !ok {
+		shared = path.Clone(path.IsWithdraw)
+		shared.updateEbgpAttrs(u.global, key.confed)
+		if key.sort {
+			shared.SortExtCommunities()
+		}
+		u.shared[key] = shared
+	}
+	p := shared.Clone(path.IsWithdraw)
+	p.SetNexthop(net.ParseIP(peer.Transport.Config.LocalAddress))
+	p.checkExtendedNexthop(peer)
+	p.stripAttributes(peer)
+	return p
+}
+
+// UpdatePathAttrsForPeers returns the clones of the path with the
+// attributes updated for each peer, in the order of the peers.
+func (path *Path) UpdatePathAttrsForPeers(global *config.Global, peers []*config.Neighbor) []*Path {
+	u := NewPathAttrsUpdater(global)
+	paths := make([]*Path, 0, len(peers))
+	for _, peer := range peers {
+		paths = append(paths, u.Update(path, peer))
+	}
+	return paths
 }
 
 func (path *Path) GetTimestamp() time.Time {
@@ -227,6 +435,7 @@ func (path *Path) Clone(isWithdraw bool) *Path {
 		parent:     path,
 		IsWithdraw: isWithdraw,
 		filtered:   make(map[string]PolicyDirection),
+		igpMetric:  path.igpMetric,
 	}
 }
 
@@ -238,6 +447,31 @@ func (path *Path) root() *Path {
 	return p
 }
 
+// ParentDepth returns the number of the parents walked from the path
+// up to the one holding the origin info.
+func (path *Path) ParentDepth() int {
+	depth := 0
+	for p := path.parent; p != nil; p = p.parent {
+		depth++
+	}
+	return depth
+}
+
+// Compact copies the path attributes inherited from the parents into
+// the path and drops the parents, so that looking up the attributes
+// doesn't walk them. The attributes and the origin info are the same
+// as before, and so are the paths cloned from it.
+func (path *Path) Compact() {
+	if path.parent == nil {
+		return
+	}
+	// the attributes are complete at each step for the readers
+	path.info = path.OriginInfo()
+	path.pathAttrs = path.GetPathAttrs()
+	path.dels = nil
+	path.parent = nil
+}
+
 func (path *Path) OriginInfo() *originInfo {
 	return path.root().info
 }
@@ -262,6 +496,45 @@ func (path *Path) SetIsFromZebra(y bool) {
 	path.OriginInfo().isFromZebra = y
 }
 
+// SetCollapseAsPrepends makes the best path selection count the AS_PATH
+// length with consecutive duplicate ASNs collapsed to one, to
+// neutralize the prepends of the neighbor. The AS_PATH itself isn't
+// modified, so it's advertised and matched by policies as received.
+func (path *Path) SetCollapseAsPrepends(y bool) {
+	path.OriginInfo().collapsePrepends = y
+}
+
+// IsNexthopInvalid returns true if the next hop of the path was reported
+// unreachable by next-hop tracking. Such a path is never selected as best.
+func (path *Path) IsNexthopInvalid() bool {
+	return path.nexthopInvalid
+}
+
+func (path *Path) SetNexthopInvalid(y bool) {
+	path.nexthopInvalid = y
+}
+
+// GetIgpMetric returns the IGP metric to the next hop of the path
+// reported by next-hop tracking, zero when it's unknown.
+func (path *Path) GetIgpMetric() uint32 {
+	return path.igpMetric
+}
+
+func (path *Path) SetIgpMetric(metric uint32) {
+	path.igpMetric = metric
+}
+
+// IsStale returns true if the path was retained after the session
+// with the peer went down gracefully (RFC4724) and hasn't been
+// re-advertised since.
+func (path *Path) IsStale() bool {
+	return path.stale
+}
+
+func (path *Path) SetStale(y bool) {
+	path.stale = y
+}
+
 func (path *Path) UUID() []byte {
 	return path.OriginInfo().uuid
 }
@@ -318,6 +591,20 @@ func (path *Path) GetNexthop() net.IP {
 }
 
 func (path *Path) SetNexthop(nexthop net.IP) {
+	if path.GetRouteFamily() == bgp.RF_IPv4_UC {
+		// RFC5549, the IPv6 next hop of IPv4 NLRI is carried in
+		// MP_REACH_NLRI instead of NEXT_HOP
+		if nexthop.To4() == nil && len(nexthop) == net.IPv6len {
+			path.delPathAttr(bgp.BGP_ATTR_TYPE_NEXT_HOP)
+			path.setPathAttr(bgp.NewPathAttributeMpReachNLRI(nexthop.String(), []bgp.AddrPrefixInterface{path.GetNlri()}))
+			return
+		}
+		if path.getPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI) != nil {
+			path.delPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+			path.setPathAttr(bgp.NewPathAttributeNextHop(nexthop.String()))
+			return
+		}
+	}
 	attr := path.getPathAttr(bgp.BGP_ATTR_TYPE_NEXT_HOP)
 	if attr != nil {
 		path.setPathAttr(bgp.NewPathAttributeNextHop(nexthop.String()))
@@ -333,6 +620,44 @@ func (path *Path) GetNlri() bgp.AddrPrefixInterface {
 	return path.OriginInfo().nlri
 }
 
+// Fingerprint returns a hash of the NLRI and the path attributes. Paths
+// having the same fingerprint differ only in metadata like the timestamp.
+// The next hop is included wherever it's carried, so paths differing
+// only in the next hop never look the same.
+func (path *Path) Fingerprint() uint64 {
+	h := fnv.New64a()
+	b, _ := path.GetNlri().Serialize()
+	h.Write(b)
+	for _, a := range path.GetPathAttrs() {
+		switch a.GetType() {
+		case bgp.BGP_ATTR_TYPE_MP_REACH_NLRI:
+			// shared by all the NLRIs in the received message
+			reach := a.(*bgp.PathAttributeMpReachNLRI)
+			h.Write(reach.Nexthop)
+			h.Write(reach.LinkLocalNexthop)
+		case bgp.BGP_ATTR_TYPE_MP_UNREACH_NLRI:
+		default:
+			b, _ := a.Serialize()
+			h.Write(b)
+		}
+	}
+	return h.Sum64()
+}
+
+// GetRouteDistinguisher returns the route distinguisher of the NLRI or
+// nil if the NLRI doesn't have one.
+func (path *Path) GetRouteDistinguisher() bgp.RouteDistinguisherInterface {
+	switch nlri := path.GetNlri().(type) {
+	case *bgp.LabeledVPNIPAddrPrefix:
+		return nlri.RD
+	case *bgp.LabeledVPNIPv6AddrPrefix:
+		return nlri.RD
+	case *bgp.EVPNNLRI:
+		return nlri.RD()
+	}
+	return nil
+}
+
 func (path *Path) GetPathAttrs() []bgp.PathAttributeInterface {
 	seen := NewBitmap(math.MaxUint8)
 	list := make([]bgp.PathAttributeInterface, 0, 4)
@@ -375,6 +700,12 @@ func (path *Path) getPathAttr(typ bgp.BGPAttrType) bgp.PathAttributeInterface {
 }
 
 func (path *Path) setPathAttr(a bgp.PathAttributeInterface) {
+	for i, t := range path.dels {
+		if t == a.GetType() {
+			path.dels = append(path.dels[:i], path.dels[i+1:]...)
+			break
+		}
+	}
 	if len(path.pathAttrs) == 0 {
 		path.pathAttrs = []bgp.PathAttributeInterface{a}
 	} else {
@@ -409,6 +740,32 @@ func (path *Path) String() string {
 	return s.String()
 }
 
+func nlriPathIdentifier(nlri bgp.AddrPrefixInterface) uint32 {
+	switch n := nlri.(type) {
+	case *bgp.IPAddrPrefix:
+		return n.PathIdentifier
+	case *bgp.IPv6AddrPrefix:
+		return n.PathIdentifier
+	}
+	return 0
+}
+
+// GetPathIdentifier returns the path identifier (RFC7911) received with
+// the NLRI or set with SetPathIdentifier, or zero. Paths from a peer
+// are distinguished by it when Add-Path receive is negotiated.
+func (path *Path) GetPathIdentifier() uint32 {
+	return path.OriginInfo().pathIdentifier
+}
+
+// SetPathIdentifier sets the path identifier the path is advertised
+// with to the peers Add-Path send is negotiated with. Setting distinct
+// identifiers on the local paths of a prefix lets them be added,
+// replaced and withdrawn individually. It must be set before the path
+// is processed.
+func (path *Path) SetPathIdentifier(id uint32) {
+	path.OriginInfo().pathIdentifier = id
+}
+
 func (path *Path) getPrefix() string {
 	if path.OriginInfo().key == "" {
 		path.OriginInfo().key = path.GetNlri().String()
@@ -436,6 +793,42 @@ func (path *Path) GetAsPathLen() int {
 	return length
 }
 
+// getSelectionAsPathLen returns the AS_PATH length compared in the best
+// path selection, see SetCollapseAsPrepends.
+func (path *Path) getSelectionAsPathLen() int {
+	if !path.OriginInfo().collapsePrepends {
+		return path.GetAsPathLen()
+	}
+	return CollapsedAsPathLen(path.GetAsPath())
+}
+
+// CollapsedAsPathLen returns the length of the AS_PATH counting
+// consecutive duplicate ASNs in AS_SEQUENCE segments once.
+func CollapsedAsPathLen(aspath *bgp.PathAttributeAsPath) int {
+	if aspath == nil {
+		return 0
+	}
+	length := 0
+	var last uint32
+	seq := false
+	for _, param := range aspath.Value {
+		segment := param.(*bgp.As4PathParam)
+		if segment.Type != bgp.BGP_ASPATH_ATTR_TYPE_SEQ {
+			length += segment.ASLen()
+			seq = false
+			continue
+		}
+		for _, as := range segment.AS {
+			if !seq || as != last {
+				length++
+			}
+			last = as
+			seq = true
+		}
+	}
+	return length
+}
+
 func (path *Path) GetAsString() string {
 	s := bytes.NewBuffer(make([]byte, 0, 64))
 	if aspath := path.GetAsPath(); aspath != nil {
@@ -495,6 +888,23 @@ func (path *Path) getAsListofSpecificType(getAsSeq, getAsSet bool) []uint32 {
 	return asList
 }
 
+// HasAsTrans returns true if AS_TRANS remains in the AS_PATH or the
+// AGGREGATOR after the AS4 attributes are merged, which means an OLD
+// BGP speaker on the way dropped them (RFC6793).
+func (path *Path) HasAsTrans() bool {
+	for _, as := range path.GetAsList() {
+		if as == bgp.AS_TRANS {
+			return true
+		}
+	}
+	if attr := path.getPathAttr(bgp.BGP_ATTR_TYPE_AGGREGATOR); attr != nil {
+		if attr.(*bgp.PathAttributeAggregator).Value.AS == bgp.AS_TRANS && path.getPathAttr(bgp.BGP_ATTR_TYPE_AS4_AGGREGATOR) == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // PrependAsn prepends AS number.
 // This function updates the AS_PATH attribute as follows.
 //  1) if the first path segment of the AS_PATH is of type
@@ -525,9 +935,14 @@ func (path *Path) PrependAsn(asn uint32, repeat uint8) {
 	}
 
 	var asPath *bgp.PathAttributeAsPath
-	if original == nil {
+	switch original {
+	case nil:
 		asPath = bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{})
-	} else {
+	case path.ownedAsPath:
+		// allocated by the previous prepend and not shared with
+		// any other path, no need to copy it again
+		asPath = original
+	default:
 		asPath = cloneAsPath(original)
 	}
 
@@ -548,6 +963,7 @@ func (path *Path) PrependAsn(asn uint32, repeat uint8) {
 		asPath.Value = append([]bgp.AsPathParamInterface{p}, asPath.Value...)
 	}
 	path.setPathAttr(asPath)
+	path.ownedAsPath = asPath
 }
 
 func (path *Path) GetCommunities() []uint32 {
@@ -559,6 +975,13 @@ func (path *Path) GetCommunities() []uint32 {
 	return communityList
 }
 
+func (path *Path) GetLargeCommunities() []*bgp.LargeCommunity {
+	if attr := path.getPathAttr(bgp.BGP_ATTR_TYPE_LARGE_COMMUNITY); attr != nil {
+		return attr.(*bgp.PathAttributeLargeCommunities).Values
+	}
+	return nil
+}
+
 // SetCommunities adds or replaces communities with new ones.
 // If the length of communities is 0 and doReplace is true, it clears communities.
 func (path *Path) SetCommunities(communities []uint32, doReplace bool) {
@@ -586,6 +1009,30 @@ func (path *Path) SetCommunities(communities []uint32, doReplace bool) {
 
 }
 
+// ClearCommunities returns a copy of the path without the standard
+// and/or extended communities, or the path itself when it carries none
+// of them. The copy keeps the policy filtering state of the path.
+func (path *Path) ClearCommunities(standard, extended bool) *Path {
+	types := make([]bgp.BGPAttrType, 0, 2)
+	if standard && path.getPathAttr(bgp.BGP_ATTR_TYPE_COMMUNITIES) != nil {
+		types = append(types, bgp.BGP_ATTR_TYPE_COMMUNITIES)
+	}
+	if extended && path.getPathAttr(bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES) != nil {
+		types = append(types, bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES)
+	}
+	if len(types) == 0 {
+		return path
+	}
+	p := path.Clone(path.IsWithdraw)
+	for id, dir := range path.filtered {
+		p.filtered[id] = dir
+	}
+	for _, t := range types {
+		p.delPathAttr(t)
+	}
+	return p
+}
+
 // RemoveCommunities removes specific communities.
 // If the length of communites is 0, it does nothing.
 // If all communities are removed, it removes Communities path attribute itself.
@@ -654,6 +1101,122 @@ func (path *Path) SetExtCommunities(exts []bgp.ExtendedCommunityInterface, doRep
 	}
 }
 
+type extCommunities []bgp.ExtendedCommunityInterface
+
+func (e extCommunities) Len() int {
+	return len(e)
+}
+
+func (e extCommunities) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+func (e extCommunities) Less(i, j int) bool {
+	b1, _ := e[i].Serialize()
+	b2, _ := e[j].Serialize()
+	return bytes.Compare(b1, b2) < 0
+}
+
+// SortExtCommunities sorts extended communities by type, sub-type and
+// value so that they are serialized in a deterministic order.
+func (path *Path) SortExtCommunities() {
+	exts := path.GetExtCommunities()
+	if len(exts) < 2 || sort.IsSorted(extCommunities(exts)) {
+		return
+	}
+	sort.Sort(extCommunities(exts))
+	path.SetExtCommunities(exts, true)
+}
+
+// categories of the extended communities by ClassifyExtCommunities
+const (
+	EXT_COMMUNITY_ROUTE_TARGET  = "route-target"
+	EXT_COMMUNITY_ROUTE_ORIGIN  = "route-origin"
+	EXT_COMMUNITY_ENCAPSULATION = "encapsulation"
+	EXT_COMMUNITY_COLOR         = "color"
+	EXT_COMMUNITY_BANDWIDTH     = "bandwidth"
+	EXT_COMMUNITY_OPAQUE        = "opaque"
+	EXT_COMMUNITY_OTHER         = "other"
+)
+
+// ExtCommunityCategory returns the category of the extended community,
+// one of the EXT_COMMUNITY_* constants.
+func ExtCommunityCategory(e bgp.ExtendedCommunityInterface) string {
+	if o, ok := e.(*bgp.OpaqueExtended); ok {
+		// the sub-type of an opaque one built locally isn't set
+		// until it's serialized
+		switch o.Value.(type) {
+		case *bgp.EncapExtended:
+			return EXT_COMMUNITY_ENCAPSULATION
+		case *bgp.ColorExtended:
+			return EXT_COMMUNITY_COLOR
+		}
+		return EXT_COMMUNITY_OPAQUE
+	}
+	if _, ok := linkBandwidth(e); ok {
+		return EXT_COMMUNITY_BANDWIDTH
+	}
+	t, st := e.GetTypes()
+	switch t {
+	case bgp.EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, bgp.EC_TYPE_TRANSITIVE_IP4_SPECIFIC, bgp.EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC:
+		switch st {
+		case bgp.EC_SUBTYPE_ROUTE_TARGET:
+			return EXT_COMMUNITY_ROUTE_TARGET
+		case bgp.EC_SUBTYPE_ROUTE_ORIGIN:
+			return EXT_COMMUNITY_ROUTE_ORIGIN
+		}
+	}
+	return EXT_COMMUNITY_OTHER
+}
+
+// ClassifyExtCommunities returns the extended communities of the path
+// by their categories, keeping the order within each category.
+func (path *Path) ClassifyExtCommunities() map[string][]bgp.ExtendedCommunityInterface {
+	m := make(map[string][]bgp.ExtendedCommunityInterface)
+	for _, e := range path.GetExtCommunities() {
+		c := ExtCommunityCategory(e)
+		m[c] = append(m[c], e)
+	}
+	return m
+}
+
+// linkBandwidth returns the link bandwidth extended community in the
+// non-transitive form of draft-ietf-idr-link-bandwidth or in the
+// transitive form some vendors use.
+func linkBandwidth(e bgp.ExtendedCommunityInterface) (*bgp.TwoOctetAsSpecificExtended, bool) {
+	if c, ok := e.(*bgp.TwoOctetAsSpecificExtended); ok && c.SubType == bgp.EC_SUBTYPE_LINK_BANDWIDTH {
+		return c, true
+	}
+	return nil, false
+}
+
+// GetLinkBandwidth returns the bandwidth in bytes per second carried by
+// the first link bandwidth extended community of the path in either
+// form.
+func (path *Path) GetLinkBandwidth() (float32, bool) {
+	for _, e := range path.GetExtCommunities() {
+		if c, ok := linkBandwidth(e); ok {
+			return math.Float32frombits(c.LocalAdmin), true
+		}
+	}
+	return 0, false
+}
+
+// SetLinkBandwidth replaces the link bandwidth extended communities of
+// the path in either form with the non-transitive one carrying the
+// bandwidth in bytes per second.
+func (path *Path) SetLinkBandwidth(asn uint16, bw float32) {
+	exts := path.GetExtCommunities()
+	l := make([]bgp.ExtendedCommunityInterface, 0, len(exts)+1)
+	for _, e := range exts {
+		if _, ok := linkBandwidth(e); !ok {
+			l = append(l, e)
+		}
+	}
+	l = append(l, bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_LINK_BANDWIDTH, asn, math.Float32bits(bw), false))
+	path.SetExtCommunities(l, true)
+}
+
 func (path *Path) GetMed() (uint32, error) {
 	attr := path.getPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC)
 	if attr == nil {
@@ -662,6 +1225,21 @@ func (path *Path) GetMed() (uint32, error) {
 	return attr.(*bgp.PathAttributeMultiExitDisc).Value, nil
 }
 
+// GetAigp returns the IGP metric in the AIGP attribute (RFC7311) plus
+// the IGP metric to the next hop of the path.
+func (path *Path) GetAigp() (uint64, error) {
+	attr := path.getPathAttr(bgp.BGP_ATTR_TYPE_AIGP)
+	if attr == nil {
+		return 0, fmt.Errorf("no aigp path attr")
+	}
+	for _, tlv := range attr.(*bgp.PathAttributeAigp).Values {
+		if m, ok := tlv.(*bgp.AigpTLVIgpMetric); ok {
+			return m.Metric + uint64(path.GetIgpMetric()), nil
+		}
+	}
+	return 0, fmt.Errorf("no igp metric in aigp path attr")
+}
+
 // SetMed replace, add or subtraction med with new ones.
 func (path *Path) SetMed(med int64, doReplace bool) error {
 
@@ -692,6 +1270,14 @@ func (path *Path) SetMed(med int64, doReplace bool) error {
 	return nil
 }
 
+// RemoveLocalPref removes LOCAL_PREF attribute. LOCAL_PREF received from
+// an external peer must be ignored (RFC4271 5.1.5).
+func (path *Path) RemoveLocalPref() {
+	if path.getPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF) != nil {
+		path.delPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	}
+}
+
 func (path *Path) GetOriginatorID() net.IP {
 	if attr := path.getPathAttr(bgp.BGP_ATTR_TYPE_ORIGINATOR_ID); attr != nil {
 		return attr.(*bgp.PathAttributeOriginatorId).Value
@@ -4,9 +4,11 @@ package table
 import (
 	//"fmt"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
 	"github.com/stretchr/testify/assert"
 )
@@ -240,6 +242,551 @@ func TestPathPrependAsnToFullPathAttr(t *testing.T) {
 	fmt.Printf("asns: %v", p.GetAsSeqList())
 }
 
+func TestPathPrependAsnInPlace(t *testing.T) {
+	assert := assert.New(t)
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("192.168.50.1"),
+	}
+	p := NewPath(PathCreatePeer()[0], bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, attrs, time.Now(), false)
+
+	c := p.Clone(false)
+	c.PrependAsn(65000, 1)
+	first := c.GetAsPath()
+	c.PrependAsn(65002, 2)
+	// the AS_PATH allocated by the first prepend is reused
+	assert.True(first == c.GetAsPath())
+	assert.Equal([]uint32{65002, 65002, 65000, 65001}, c.GetAsSeqList())
+	assert.Equal([]uint32{65001}, p.GetAsSeqList())
+
+	// owned by the parent, copied
+	cc := c.Clone(false)
+	cc.PrependAsn(65003, 1)
+	assert.False(first == cc.GetAsPath())
+	assert.Equal([]uint32{65003, 65002, 65002, 65000, 65001}, cc.GetAsSeqList())
+	assert.Equal([]uint32{65002, 65002, 65000, 65001}, c.GetAsSeqList())
+}
+
+func TestPathSortExtCommunities(t *testing.T) {
+	assert := assert.New(t)
+	soo := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_ORIGIN, 65000, 100, true)
+	rt1 := bgp.NewIPv4AddressSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, "10.0.0.1", 100, true)
+	rt2 := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 200, true)
+	rt3 := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 100, true)
+
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("192.168.50.1"),
+		bgp.NewPathAttributeExtendedCommunities([]bgp.ExtendedCommunityInterface{soo, rt1, rt2, rt3}),
+	}
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	peer := PathCreatePeer()
+	p := NewPath(peer[1], nlri, false, pathAttributes, time.Now(), false)
+
+	global := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	n := &config.Neighbor{Config: config.NeighborConfig{PeerAs: 65001, PeerType: config.PEER_TYPE_EXTERNAL}}
+
+	// default preserves the order
+	q := p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.Equal([]bgp.ExtendedCommunityInterface{soo, rt1, rt2, rt3}, q.GetExtCommunities())
+
+	n.Config.SortExtCommunities = true
+	q = p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.Equal([]bgp.ExtendedCommunityInterface{rt3, rt2, soo, rt1}, q.GetExtCommunities())
+	// the original path isn't modified
+	assert.Equal([]bgp.ExtendedCommunityInterface{soo, rt1, rt2, rt3}, p.GetExtCommunities())
+}
+
+func TestPathUnusableNexthopAction(t *testing.T) {
+	assert := assert.New(t)
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeNextHop("0.0.0.0"),
+	}
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	peer := &PeerInfo{AS: 65000, Address: net.ParseIP("10.0.0.2")}
+	p := NewPath(peer, nlri, false, pathAttributes, time.Now(), false)
+
+	global := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	n := &config.Neighbor{
+		Config: config.NeighborConfig{
+			PeerAs:   65000,
+			PeerType: config.PEER_TYPE_INTERNAL,
+		},
+		Transport: config.Transport{
+			Config: config.TransportConfig{LocalAddress: "10.0.0.1"},
+		},
+	}
+
+	// default leaves the nexthop unchanged
+	q := p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.False(q.IsWithdraw)
+	assert.Equal("0.0.0.0", q.GetNexthop().String())
+
+	n.Config.UnusableNexthopAction = config.UNUSABLE_NEXTHOP_ACTION_TYPE_SELF
+	q = p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.False(q.IsWithdraw)
+	assert.Equal("10.0.0.1", q.GetNexthop().String())
+
+	// rejected in the server, the nexthop is left unchanged here
+	n.Config.UnusableNexthopAction = config.UNUSABLE_NEXTHOP_ACTION_TYPE_REJECT
+	q = p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.False(q.IsWithdraw)
+	assert.Equal("0.0.0.0", q.GetNexthop().String())
+
+	// our own address and the martians are unusable too
+	for _, nexthop := range []string{"0.0.0.0", "10.0.0.1", "127.0.0.1", "0.1.2.3", "224.0.0.5", "240.0.0.1", "255.255.255.255"} {
+		q = p.Clone(false)
+		q.SetNexthop(net.ParseIP(nexthop))
+		assert.True(q.HasUnusableNexthop(net.ParseIP("10.0.0.1")), nexthop)
+	}
+	q = p.Clone(false)
+	q.SetNexthop(net.ParseIP("10.0.0.3"))
+	assert.False(q.HasUnusableNexthop(net.ParseIP("10.0.0.1")))
+
+	v6 := NewPath(peer, bgp.NewIPv6AddrPrefix(64, "2001:db8::"), false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI("::1", []bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(64, "2001:db8::")}),
+	}, time.Now(), false)
+	n.Transport.Config.LocalAddress = "2001:db8::1"
+	n.Config.UnusableNexthopAction = config.UNUSABLE_NEXTHOP_ACTION_TYPE_SELF
+	for _, nexthop := range []string{"::", "::1", "ff02::5", "2001:db8::1"} {
+		q = v6.Clone(false)
+		q.SetNexthop(net.ParseIP(nexthop))
+		q.UpdatePathAttrs(global, n)
+		assert.Equal("2001:db8::1", q.GetNexthop().String(), nexthop)
+	}
+	q = v6.Clone(false)
+	q.SetNexthop(net.ParseIP("fe80::1"))
+	q.UpdatePathAttrs(global, n)
+	assert.Equal("fe80::1", q.GetNexthop().String())
+}
+
+func TestPathIbgpMedAction(t *testing.T) {
+	assert := assert.New(t)
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeNextHop("10.0.0.2"),
+		bgp.NewPathAttributeMultiExitDisc(100),
+	}
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	peer := &PeerInfo{AS: 65000, Address: net.ParseIP("10.0.0.2")}
+	p := NewPath(peer, nlri, false, pathAttributes, time.Now(), false)
+	z := NewPath(peer, nlri, false, pathAttributes, time.Now(), false)
+	z.SetIsFromZebra(true)
+
+	global := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	n := &config.Neighbor{Config: config.NeighborConfig{PeerAs: 65000, PeerType: config.PEER_TYPE_INTERNAL}}
+	med := func(path *Path) uint32 {
+		q := path.Clone(false)
+		q.UpdatePathAttrs(global, n)
+		m, err := q.GetMed()
+		assert.Nil(err)
+		return m
+	}
+
+	// default preserves the MED
+	assert.Equal(uint32(100), med(p))
+
+	n.Config.IbgpMedAction = config.IBGP_MED_ACTION_TYPE_PRESERVE
+	assert.Equal(uint32(100), med(p))
+
+	n.Config.IbgpMedAction = config.IBGP_MED_ACTION_TYPE_ZERO
+	assert.Equal(uint32(0), med(p))
+	assert.Equal(uint32(0), med(z))
+
+	n.Config.IbgpMedAction = config.IBGP_MED_ACTION_TYPE_IGP
+	assert.Equal(uint32(0), med(p))
+	assert.Equal(uint32(100), med(z))
+	// the metric to the nexthop reported by next-hop tracking
+	p.SetIgpMetric(20)
+	z.SetIgpMetric(20)
+	assert.Equal(uint32(20), med(p))
+	assert.Equal(uint32(100), med(z))
+	// the original path isn't modified
+	m, _ := p.GetMed()
+	assert.Equal(uint32(100), m)
+}
+
+func TestPathAttributeTransparency(t *testing.T) {
+	assert := assert.New(t)
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.2"),
+		bgp.NewPathAttributeMultiExitDisc(100),
+		bgp.NewPathAttributeLocalPref(200),
+	}
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	peer := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.2")}
+	p := NewPath(peer, nlri, false, pathAttributes, time.Now(), false)
+
+	global := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	ebgp := &config.Neighbor{
+		Config:    config.NeighborConfig{PeerAs: 65002, PeerType: config.PEER_TYPE_EXTERNAL, AttributeTransparency: true},
+		Transport: config.Transport{Config: config.TransportConfig{LocalAddress: "10.0.0.1"}},
+	}
+	ibgp := &config.Neighbor{
+		Config: config.NeighborConfig{
+			PeerAs:                65000,
+			PeerType:              config.PEER_TYPE_INTERNAL,
+			UnusableNexthopAction: config.UNUSABLE_NEXTHOP_ACTION_TYPE_SELF,
+			IbgpMedAction:         config.IBGP_MED_ACTION_TYPE_ZERO,
+		},
+		Transport: config.Transport{Config: config.TransportConfig{LocalAddress: "10.0.0.1"}},
+	}
+
+	check := func(q *Path, asPath string) {
+		assert.Equal("10.0.0.2", q.GetNexthop().String())
+		med, err := q.GetMed()
+		assert.Nil(err)
+		assert.Equal(uint32(100), med)
+		pref := q.getPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+		assert.NotNil(pref)
+		assert.Equal(uint32(200), pref.(*bgp.PathAttributeLocalPref).Value)
+		assert.Equal(asPath, q.GetAsString())
+	}
+
+	// eBGP, only our AS is prepended
+	q := p.Clone(false)
+	q.UpdatePathAttrs(global, ebgp)
+	check(q, "65000 65001")
+	check(NewPathAttrsUpdater(global).Update(p, ebgp), "65000 65001")
+
+	// iBGP, the MED action is ignored
+	ibgp.Config.AttributeTransparency = true
+	q = p.Clone(false)
+	q.UpdatePathAttrs(global, ibgp)
+	check(q, "65001")
+
+	// a zero next hop isn't rewritten to self
+	z := p.Clone(false)
+	z.SetNexthop(net.ParseIP("0.0.0.0"))
+	z.UpdatePathAttrs(global, ibgp)
+	assert.Equal("0.0.0.0", z.GetNexthop().String())
+
+	// a locally generated path gets our address as the next hop
+	l := NewPath(NewLocalPeerInfo(global), nlri, false, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeNextHop("0.0.0.0"),
+	}, time.Now(), false)
+	l.UpdatePathAttrs(global, ebgp)
+	assert.Equal("10.0.0.1", l.GetNexthop().String())
+	assert.Equal("65000", l.GetAsString())
+
+	// without transparency
+	ebgp.Config.AttributeTransparency = false
+	q = p.Clone(false)
+	q.UpdatePathAttrs(global, ebgp)
+	assert.Equal("10.0.0.1", q.GetNexthop().String())
+	_, err := q.GetMed()
+	assert.NotNil(err)
+	assert.Nil(q.getPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF))
+}
+
+func TestPathTransparentReflection(t *testing.T) {
+	assert := assert.New(t)
+	ext := []bgp.ExtendedCommunityInterface{
+		bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 200, true),
+		bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 100, true),
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("0.0.0.0"),
+		bgp.NewPathAttributeMultiExitDisc(100),
+		bgp.NewPathAttributeLocalPref(200),
+		bgp.NewPathAttributeCommunities([]uint32{100, 200}),
+		bgp.NewPathAttributeExtendedCommunities(ext),
+	}
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	peer := &PeerInfo{AS: 65000, LocalAS: 65000, ID: net.ParseIP("10.0.0.2"), Address: net.ParseIP("10.0.0.2")}
+	p := NewPath(peer, nlri, false, pathAttributes, time.Now(), false)
+
+	global := &config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "10.0.0.1"}}
+	n := &config.Neighbor{
+		Config: config.NeighborConfig{
+			PeerAs:                65000,
+			PeerType:              config.PEER_TYPE_INTERNAL,
+			UnusableNexthopAction: config.UNUSABLE_NEXTHOP_ACTION_TYPE_SELF,
+			IbgpMedAction:         config.IBGP_MED_ACTION_TYPE_ZERO,
+			SortExtCommunities:    true,
+		},
+		Transport: config.Transport{Config: config.TransportConfig{LocalAddress: "10.0.0.1"}},
+		RouteReflector: config.RouteReflector{
+			Config: config.RouteReflectorConfig{
+				RouteReflectorClient:    true,
+				RouteReflectorClusterId: "10.0.0.1",
+				TransparentReflection:   true,
+			},
+		},
+	}
+	assert.True(p.IsTransparentlyReflected(n))
+
+	q := p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	for _, typ := range []bgp.BGPAttrType{
+		bgp.BGP_ATTR_TYPE_ORIGIN,
+		bgp.BGP_ATTR_TYPE_AS_PATH,
+		bgp.BGP_ATTR_TYPE_NEXT_HOP,
+		bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC,
+		bgp.BGP_ATTR_TYPE_LOCAL_PREF,
+		bgp.BGP_ATTR_TYPE_COMMUNITIES,
+		bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES,
+	} {
+		before, err := p.getPathAttr(typ).Serialize()
+		assert.Nil(err)
+		after, err := q.getPathAttr(typ).Serialize()
+		assert.Nil(err)
+		assert.Equal(before, after, typ.String())
+	}
+	assert.Equal("10.0.0.2", q.getPathAttr(bgp.BGP_ATTR_TYPE_ORIGINATOR_ID).(*bgp.PathAttributeOriginatorId).Value.String())
+	assert.Equal(1, len(q.getPathAttr(bgp.BGP_ATTR_TYPE_CLUSTER_LIST).(*bgp.PathAttributeClusterList).Value))
+
+	// routes from eBGP peers aren't reflected
+	e := NewPath(&PeerInfo{AS: 65001, LocalAS: 65000, Address: net.ParseIP("10.0.1.2")}, nlri, false, pathAttributes, time.Now(), false)
+	assert.False(e.IsTransparentlyReflected(n))
+
+	n.RouteReflector.Config.TransparentReflection = false
+	assert.False(p.IsTransparentlyReflected(n))
+	q = p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.Equal("10.0.0.1", q.GetNexthop().String())
+	med, _ := q.GetMed()
+	assert.Equal(uint32(0), med)
+}
+
+func TestPathStripAttributes(t *testing.T) {
+	assert := assert.New(t)
+	unknown := &bgp.PathAttributeUnknown{
+		PathAttribute: bgp.PathAttribute{
+			Flags: bgp.BGP_ATTR_FLAG_OPTIONAL | bgp.BGP_ATTR_FLAG_TRANSITIVE,
+			Type:  bgp.BGPAttrType(40),
+			Value: []byte{1, 2, 3},
+		},
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.2"),
+		bgp.NewPathAttributeCommunities([]uint32{100}),
+		bgp.NewPathAttributeAigp([]bgp.AigpTLV{bgp.NewAigpTLVIgpMetric(10)}),
+		unknown,
+	}
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	p := NewPath(&PeerInfo{AS: 65001, LocalAS: 65000, Address: net.ParseIP("10.0.0.2")}, nlri, false, pathAttributes, time.Now(), false)
+
+	global := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	n := &config.Neighbor{
+		Config: config.NeighborConfig{
+			PeerAs:                     65002,
+			PeerType:                   config.PEER_TYPE_EXTERNAL,
+			StripAttributeOnEgressList: []uint8{uint8(bgp.BGP_ATTR_TYPE_AIGP), 40},
+		},
+		Transport: config.Transport{Config: config.TransportConfig{LocalAddress: "10.0.0.1"}},
+	}
+	stripped := func(q *Path) {
+		assert.Nil(q.getPathAttr(bgp.BGP_ATTR_TYPE_AIGP))
+		assert.Nil(q.getPathAttr(bgp.BGPAttrType(40)))
+		assert.NotNil(q.getPathAttr(bgp.BGP_ATTR_TYPE_COMMUNITIES))
+		assert.Equal(4, len(q.GetPathAttrs()))
+	}
+
+	q := p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	stripped(q)
+	stripped(NewPathAttrsUpdater(global).Update(p, n))
+
+	n.RouteServer.Config.RouteServerClient = true
+	stripped(p.StripAttributes(n))
+	// UpdatePathAttrs leaves the paths to route server clients alone
+	q = p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.Equal(len(pathAttributes), len(q.GetPathAttrs()))
+	assert.Equal("10.0.0.2", q.GetNexthop().String())
+
+	// the original path isn't modified
+	assert.NotNil(p.getPathAttr(bgp.BGP_ATTR_TYPE_AIGP))
+	assert.NotNil(p.getPathAttr(bgp.BGPAttrType(40)))
+}
+
+func TestPathLocalRouterId(t *testing.T) {
+	assert := assert.New(t)
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	nlri := bgp.NewIPAddrPrefix(24, "10.10.10.0")
+	global := &config.Global{Config: config.GlobalConfig{As: 65000, RouterId: "1.1.1.1"}}
+	p := NewPath(NewLocalPeerInfo(global), nlri, false, pathAttributes, time.Now(), false)
+	n := &config.Neighbor{
+		Config: config.NeighborConfig{
+			PeerAs:   65000,
+			PeerType: config.PEER_TYPE_INTERNAL,
+		},
+		RouteReflector: config.RouteReflector{
+			Config: config.RouteReflectorConfig{
+				RouteReflectorClient:    true,
+				RouteReflectorClusterId: "1.1.1.1",
+			},
+		},
+	}
+
+	q := p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.Equal("1.1.1.1", q.GetOriginatorID().String())
+
+	n.Config.LocalRouterId = "2.2.2.2"
+	q = p.Clone(false)
+	q.UpdatePathAttrs(global, n)
+	assert.Equal("2.2.2.2", q.GetOriginatorID().String())
+	assert.Equal("2.2.2.2", NewPeerInfo(global, n).LocalID.String())
+}
+
+// BenchmarkOutboundPrepend processes 100k external advertisements the
+// way they are sent to an eBGP neighbor with an export policy
+// prepending the AS twice.
+func BenchmarkOutboundPrepend(b *testing.B) {
+	g := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	n := &config.Neighbor{Config: config.NeighborConfig{PeerType: config.PEER_TYPE_EXTERNAL}}
+	n.Transport.Config.LocalAddress = "10.0.0.254"
+	source := &PeerInfo{AS: 65001, LocalAS: 65000, Address: net.ParseIP("10.0.0.1")}
+	paths := make([]*Path, 100000)
+	for i := range paths {
+		attrs := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001, 65100, 65200})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+		}
+		prefix := fmt.Sprintf("10.%d.%d.0", i>>8&0xff, i&0xff)
+		paths[i] = NewPath(source, bgp.NewIPAddrPrefix(24, prefix), false, attrs, time.Now(), false)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range paths {
+			p := path.Clone(false)
+			p.PrependAsn(65000, 2)
+			p.UpdatePathAttrs(g, n)
+		}
+	}
+}
+
+func TestPathUpdatePathAttrsForPeers(t *testing.T) {
+	assert := assert.New(t)
+	g := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	g.Confederation.Config.MemberAsList = []uint32{65010}
+	neighbor := func(as uint32, typ config.PeerType, local string) *config.Neighbor {
+		n := &config.Neighbor{Config: config.NeighborConfig{PeerAs: as, PeerType: typ}}
+		n.Transport.Config.LocalAddress = local
+		return n
+	}
+	peers := []*config.Neighbor{
+		neighbor(65001, config.PEER_TYPE_EXTERNAL, "10.0.0.254"),
+		neighbor(65002, config.PEER_TYPE_EXTERNAL, "10.0.1.254"),
+		neighbor(65010, config.PEER_TYPE_EXTERNAL, "10.0.2.254"),
+		neighbor(65003, config.PEER_TYPE_EXTERNAL, "2001:db8::1"),
+		neighbor(65004, config.PEER_TYPE_EXTERNAL, "10.0.3.254"),
+		neighbor(65000, config.PEER_TYPE_INTERNAL, "10.0.4.254"),
+	}
+	peers[4].Config.SortExtCommunities = true
+
+	rt1 := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 200, true)
+	rt2 := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 100, true)
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65100})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+		bgp.NewPathAttributeMultiExitDisc(10),
+		bgp.NewPathAttributeLocalPref(200),
+		bgp.NewPathAttributeExtendedCommunities([]bgp.ExtendedCommunityInterface{rt1, rt2}),
+	}
+	source := &PeerInfo{AS: 65100, LocalAS: 65000, Address: net.ParseIP("10.0.0.1")}
+	p := NewPath(source, bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, pathAttributes, time.Now(), false)
+
+	attrs := func(path *Path) map[bgp.BGPAttrType][]byte {
+		m := make(map[bgp.BGPAttrType][]byte)
+		for _, a := range path.GetPathAttrs() {
+			m[a.GetType()], _ = a.Serialize()
+		}
+		return m
+	}
+
+	paths := p.UpdatePathAttrsForPeers(g, peers)
+	assert.Equal(len(peers), len(paths))
+	for i, peer := range peers {
+		q := p.Clone(false)
+		q.UpdatePathAttrs(g, peer)
+		assert.Equal(attrs(q), attrs(paths[i]))
+		assert.Equal(q.IsWithdraw, paths[i].IsWithdraw)
+	}
+	assert.Equal("10.0.1.254", paths[1].GetNexthop().String())
+	assert.Equal(uint32(65000), paths[1].GetAsSeqList()[0])
+	// withdrawn, the extended nexthop encoding isn't negotiated
+	assert.True(paths[3].IsWithdraw)
+	// the eBGP peers out of the confederation share the transformation
+	assert.True(paths[0].parent == paths[1].parent)
+	assert.False(paths[0].parent == paths[2].parent)
+	// the original path isn't modified
+	assert.Equal(attrs(NewPath(source, p.GetNlri(), false, pathAttributes, time.Now(), false)), attrs(p))
+}
+
+// benchmarkAdvertise updates the attributes of 1k paths to be
+// advertised to 100 eBGP neighbors.
+func benchmarkAdvertise(b *testing.B, update func(g *config.Global, path *Path, peers []*config.Neighbor)) {
+	g := &config.Global{Config: config.GlobalConfig{As: 65000}}
+	peers := make([]*config.Neighbor, 100)
+	for i := range peers {
+		n := &config.Neighbor{Config: config.NeighborConfig{PeerAs: uint32(65100 + i), PeerType: config.PEER_TYPE_EXTERNAL}}
+		n.Transport.Config.LocalAddress = fmt.Sprintf("10.0.%d.254", i)
+		peers[i] = n
+	}
+	source := &PeerInfo{AS: 65001, LocalAS: 65000, Address: net.ParseIP("10.0.0.1")}
+	paths := make([]*Path, 1000)
+	for i := range paths {
+		attrs := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001, 65100, 65200})}),
+			bgp.NewPathAttributeNextHop("10.0.0.1"),
+			bgp.NewPathAttributeMultiExitDisc(10),
+		}
+		prefix := fmt.Sprintf("10.%d.%d.0", i>>8&0xff, i&0xff)
+		paths[i] = NewPath(source, bgp.NewIPAddrPrefix(24, prefix), false, attrs, time.Now(), false)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range paths {
+			update(g, path, peers)
+		}
+	}
+}
+
+func BenchmarkUpdatePathAttrsPerPeer(b *testing.B) {
+	benchmarkAdvertise(b, func(g *config.Global, path *Path, peers []*config.Neighbor) {
+		for _, peer := range peers {
+			p := path.Clone(false)
+			p.UpdatePathAttrs(g, peer)
+		}
+	})
+}
+
+func BenchmarkUpdatePathAttrsForPeers(b *testing.B) {
+	benchmarkAdvertise(b, func(g *config.Global, path *Path, peers []*config.Neighbor) {
+		path.UpdatePathAttrsForPeers(g, peers)
+	})
+}
+
 func PathCreatePeer() []*PeerInfo {
 	peerP1 := &PeerInfo{AS: 65000}
 	peerP2 := &PeerInfo{AS: 65001}
@@ -319,3 +866,91 @@ func updateMsgP3() *bgp.BGPMessage {
 	withdrawnRoutes := []*bgp.IPAddrPrefix{w1}
 	return bgp.NewBGPUpdateMessage(withdrawnRoutes, pathAttributes, nlri)
 }
+
+func TestPathClassifyExtCommunities(t *testing.T) {
+	assert := assert.New(t)
+	rt1 := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 100, true)
+	rt2 := bgp.NewFourOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 4200000000, 100, true)
+	soo := bgp.NewIPv4AddressSpecificExtended(bgp.EC_SUBTYPE_ROUTE_ORIGIN, "10.0.0.1", 100, true)
+	encap := &bgp.OpaqueExtended{IsTransitive: true, Value: &bgp.EncapExtended{TunnelType: bgp.TUNNEL_TYPE_VXLAN}}
+	color := &bgp.OpaqueExtended{IsTransitive: true, Value: &bgp.ColorExtended{Value: 10}}
+	bandwidth := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_LINK_BANDWIDTH, 65000, 0x4e6e6b28, false)
+	validation := &bgp.OpaqueExtended{Value: &bgp.ValidationExtended{Value: bgp.VALIDATION_STATE_VALID}}
+	mobility := &bgp.MacMobilityExtended{Sequence: 1}
+	// a route target has to be transitive
+	nonTransitive := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 100, false)
+
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("192.168.50.1"),
+		bgp.NewPathAttributeExtendedCommunities([]bgp.ExtendedCommunityInterface{rt1, soo, encap, color, bandwidth, validation, mobility, nonTransitive, rt2}),
+	}
+	peer := PathCreatePeer()
+	p := NewPath(peer[1], bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, pathAttributes, time.Now(), false)
+
+	m := p.ClassifyExtCommunities()
+	assert.Equal(7, len(m))
+	assert.Equal([]bgp.ExtendedCommunityInterface{rt1, rt2}, m[EXT_COMMUNITY_ROUTE_TARGET])
+	assert.Equal([]bgp.ExtendedCommunityInterface{soo}, m[EXT_COMMUNITY_ROUTE_ORIGIN])
+	assert.Equal([]bgp.ExtendedCommunityInterface{encap}, m[EXT_COMMUNITY_ENCAPSULATION])
+	assert.Equal([]bgp.ExtendedCommunityInterface{color}, m[EXT_COMMUNITY_COLOR])
+	assert.Equal([]bgp.ExtendedCommunityInterface{bandwidth}, m[EXT_COMMUNITY_BANDWIDTH])
+	assert.Equal([]bgp.ExtendedCommunityInterface{validation}, m[EXT_COMMUNITY_OPAQUE])
+	assert.Equal([]bgp.ExtendedCommunityInterface{mobility, nonTransitive}, m[EXT_COMMUNITY_OTHER])
+
+	// the same once decoded from the wire
+	buf, err := pathAttributes[3].Serialize()
+	assert.Nil(err)
+	attr := &bgp.PathAttributeExtendedCommunities{}
+	assert.Nil(attr.DecodeFromBytes(buf))
+	for i, e := range attr.Value {
+		assert.Equal(ExtCommunityCategory(pathAttributes[3].(*bgp.PathAttributeExtendedCommunities).Value[i]), ExtCommunityCategory(e))
+	}
+
+	p = NewPath(peer[1], bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, pathAttributes[:3], time.Now(), false)
+	assert.Equal(0, len(p.ClassifyExtCommunities()))
+}
+
+func TestPathLinkBandwidth(t *testing.T) {
+	assert := assert.New(t)
+	rt := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 100, true)
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("192.168.50.1"),
+	}
+	peer := PathCreatePeer()
+	p := NewPath(peer[1], bgp.NewIPAddrPrefix(24, "10.10.10.0"), false, pathAttributes, time.Now(), false)
+	_, ok := p.GetLinkBandwidth()
+	assert.False(ok)
+
+	// 1Gbps
+	p.SetLinkBandwidth(65001, 125000000)
+	bw, ok := p.GetLinkBandwidth()
+	assert.True(ok)
+	assert.Equal(float32(125000000), bw)
+	exts := p.GetExtCommunities()
+	assert.Equal(1, len(exts))
+	typ, subtype := exts[0].GetTypes()
+	assert.Equal(bgp.EC_TYPE_NON_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, typ)
+	assert.Equal(bgp.EC_SUBTYPE_LINK_BANDWIDTH, subtype)
+	buf, _ := exts[0].Serialize()
+	assert.Equal([]byte{0x40, 0x04, 0xfd, 0xe9, 0x4c, 0xee, 0x6b, 0x28}, buf)
+
+	// the transitive form some vendors send is read and replaced
+	transitive, err := bgp.ParseExtended([]byte{0x00, 0x04, 0xfd, 0xe9, 0x4d, 0x6e, 0x6b, 0x28})
+	assert.Nil(err)
+	p.SetExtCommunities([]bgp.ExtendedCommunityInterface{transitive, rt}, true)
+	bw, ok = p.GetLinkBandwidth()
+	assert.True(ok)
+	assert.Equal(float32(250000000), bw)
+	assert.Equal(EXT_COMMUNITY_BANDWIDTH, ExtCommunityCategory(transitive))
+
+	p.SetLinkBandwidth(65001, 125000000)
+	exts = p.GetExtCommunities()
+	assert.Equal(2, len(exts))
+	assert.Equal(rt, exts[0])
+	bw, _ = p.GetLinkBandwidth()
+	assert.Equal(float32(125000000), bw)
+}
@@ -163,6 +163,7 @@ const (
 	CONDITION_EXT_COMMUNITY
 	CONDITION_AS_PATH_LENGTH
 	CONDITION_RPKI
+	CONDITION_SOURCE
 )
 
 type ActionType int
@@ -375,6 +376,16 @@ func (lhs *PrefixSet) Replace(arg DefinedSet) error {
 	return nil
 }
 
+// List returns the prefixes of the set in the order of the radix tree.
+func (s *PrefixSet) List() []*Prefix {
+	list := make([]*Prefix, 0, s.tree.Len())
+	s.tree.Walk(func(s string, v interface{}) bool {
+		list = append(list, v.(*Prefix))
+		return false
+	})
+	return list
+}
+
 func (s *PrefixSet) ToApiStruct() *api.DefinedSet {
 	list := make([]*api.Prefix, 0, s.tree.Len())
 	s.tree.Walk(func(s string, v interface{}) bool {
@@ -1591,6 +1602,42 @@ func NewRpkiValidationCondition(c config.RpkiValidationResultType) (*RpkiValidat
 	}, nil
 }
 
+type SourceCondition struct {
+	source config.RouteSourceType
+}
+
+func (c *SourceCondition) Type() ConditionType {
+	return CONDITION_SOURCE
+}
+
+// zebra matches the routes redistributed from zebra. bgp matches
+// the others, i.e. the routes learned from peers or added via API.
+func (c *SourceCondition) Evaluate(path *Path, _ *PolicyOptions) bool {
+	switch c.source {
+	case config.ROUTE_SOURCE_TYPE_ZEBRA:
+		return path.IsFromZebra()
+	case config.ROUTE_SOURCE_TYPE_BGP:
+		return !path.IsFromZebra()
+	}
+	return false
+}
+
+func (c *SourceCondition) Set() DefinedSet {
+	return nil
+}
+
+func NewSourceCondition(c config.RouteSourceType) (*SourceCondition, error) {
+	if c == config.ROUTE_SOURCE_TYPE_NONE || c == "" {
+		return nil, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &SourceCondition{
+		source: c,
+	}, nil
+}
+
 type Action interface {
 	Type() ActionType
 	Apply(*Path) *Path
@@ -2389,6 +2436,9 @@ func NewStatement(c config.Statement, dmap DefinedSetMap) (*Statement, error) {
 		func() (Condition, error) {
 			return NewExtCommunityCondition(c.Conditions.BgpConditions.MatchExtCommunitySet, dmap[DEFINED_TYPE_EXT_COMMUNITY])
 		},
+		func() (Condition, error) {
+			return NewSourceCondition(c.Conditions.BgpConditions.MatchSource)
+		},
 	}
 	cs = make([]Condition, 0, len(cfs))
 	for _, f := range cfs {
@@ -2564,6 +2614,8 @@ type Assignment struct {
 	defaultImportPolicy RouteType
 	exportPolicies      []*Policy
 	defaultExportPolicy RouteType
+	// default policies overriding the ones above for the families
+	familyDefaults map[PolicyDirection]map[bgp.RouteFamily]RouteType
 }
 
 type RoutingPolicy struct {
@@ -2589,7 +2641,7 @@ func (r *RoutingPolicy) ApplyPolicy(id string, dir PolicyDirection, before *Path
 		}
 	}
 	if result == ROUTE_TYPE_NONE {
-		result = r.GetDefaultPolicy(id, dir)
+		result = r.GetFamilyDefaultPolicy(id, dir, before.GetRouteFamily())
 	}
 	switch result {
 	case ROUTE_TYPE_ACCEPT:
@@ -2634,6 +2686,18 @@ func (r *RoutingPolicy) GetDefaultPolicy(id string, dir PolicyDirection) RouteTy
 
 }
 
+// GetFamilyDefaultPolicy returns the default policy for the paths of
+// the family, which is the default policy of the direction unless set
+// for the family.
+func (r *RoutingPolicy) GetFamilyDefaultPolicy(id string, dir PolicyDirection, family bgp.RouteFamily) RouteType {
+	if a, ok := r.AssignmentMap[id]; ok {
+		if typ, ok := a.familyDefaults[dir][family]; ok {
+			return typ
+		}
+	}
+	return r.GetDefaultPolicy(id, dir)
+}
+
 func (r *RoutingPolicy) SetPolicy(id string, dir PolicyDirection, policies []*Policy) error {
 	a, ok := r.AssignmentMap[id]
 	if !ok {
@@ -2668,6 +2732,59 @@ func (r *RoutingPolicy) SetDefaultPolicy(id string, dir PolicyDirection, typ Rou
 	return nil
 }
 
+// SetFamilyDefaultPolicies replaces the default policies of the
+// direction set for families.
+func (r *RoutingPolicy) SetFamilyDefaultPolicies(id string, dir PolicyDirection, defaults map[bgp.RouteFamily]RouteType) error {
+	a, ok := r.AssignmentMap[id]
+	if !ok {
+		a = &Assignment{}
+	}
+	if a.familyDefaults == nil {
+		a.familyDefaults = make(map[PolicyDirection]map[bgp.RouteFamily]RouteType)
+	}
+	if len(defaults) == 0 {
+		delete(a.familyDefaults, dir)
+	} else {
+		a.familyDefaults[dir] = defaults
+	}
+	r.AssignmentMap[id] = a
+	return nil
+}
+
+// GetFamilyDefaultsFromConfig returns the default policies of the
+// direction configured in the apply-policy of the families. The
+// families without one use the default policy of the direction.
+func (r *RoutingPolicy) GetFamilyDefaultsFromConfig(dir PolicyDirection, afiSafis []config.AfiSafi) (map[bgp.RouteFamily]RouteType, error) {
+	defaults := make(map[bgp.RouteFamily]RouteType)
+	for _, afiSafi := range afiSafis {
+		var cdef config.DefaultPolicyType
+		c := afiSafi.ApplyPolicy.Config
+		switch dir {
+		case POLICY_DIRECTION_IN:
+			cdef = c.DefaultInPolicy
+		case POLICY_DIRECTION_IMPORT:
+			cdef = c.DefaultImportPolicy
+		case POLICY_DIRECTION_EXPORT:
+			cdef = c.DefaultExportPolicy
+		default:
+			return nil, fmt.Errorf("invalid policy direction")
+		}
+		if cdef == "" {
+			continue
+		}
+		family, err := bgp.GetRouteFamily(string(afiSafi.AfiSafiName))
+		if err != nil {
+			return nil, err
+		}
+		if cdef == config.DEFAULT_POLICY_TYPE_REJECT_ROUTE {
+			defaults[family] = ROUTE_TYPE_REJECT
+		} else {
+			defaults[family] = ROUTE_TYPE_ACCEPT
+		}
+	}
+	return defaults, nil
+}
+
 func (r *RoutingPolicy) GetAssignmentFromConfig(dir PolicyDirection, a config.ApplyPolicy) ([]*Policy, RouteType, error) {
 	var names []string
 	var cdef config.DefaultPolicyType
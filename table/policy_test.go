@@ -277,6 +277,80 @@ func TestPolicyMatchAndReject(t *testing.T) {
 	assert.Equal(t, newPath, path)
 }
 
+func TestPolicyExportRpkiInvalid(t *testing.T) {
+	// create path
+	peer := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	origin := bgp.NewPathAttributeOrigin(0)
+	aspathParam := []bgp.AsPathParamInterface{bgp.NewAsPathParam(2, []uint16{65001})}
+	aspath := bgp.NewPathAttributeAsPath(aspathParam)
+	nexthop := bgp.NewPathAttributeNextHop("10.0.0.1")
+	med := bgp.NewPathAttributeMultiExitDisc(0)
+	pathAttributes := []bgp.PathAttributeInterface{origin, aspath, nexthop, med}
+	nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.0.101")}
+	updateMsg := bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri)
+	path := ProcessMessage(updateMsg, peer, time.Now())[0]
+	path.SetValidation(config.RPKI_VALIDATION_RESULT_TYPE_INVALID)
+
+	// reject invalid routes toward the customer 10.0.0.2 only
+	ns := createNeighborSet("customers", "10.0.0.2")
+	ds := config.DefinedSets{}
+	ds.NeighborSets = []config.NeighborSet{ns}
+	s := createStatement("statement1", "", "customers", false)
+	s.Conditions.BgpConditions.RpkiValidationResult = config.RPKI_VALIDATION_RESULT_TYPE_INVALID
+	pd := createPolicyDefinition("pd1", s)
+	pl := createRoutingPolicy(ds, pd)
+
+	r := NewRoutingPolicy()
+	err := r.Reload(pl)
+	assert.Nil(t, err)
+	r.SetPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_EXPORT, []*Policy{r.PolicyMap["pd1"]})
+	r.SetDefaultPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_EXPORT, ROUTE_TYPE_ACCEPT)
+
+	customer := &PolicyOptions{Neighbor: net.ParseIP("10.0.0.2")}
+	transit := &PolicyOptions{Neighbor: net.ParseIP("10.0.0.3")}
+	assert.Nil(t, r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_EXPORT, path, customer))
+	assert.Equal(t, path, r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_EXPORT, path, transit))
+
+	// valid routes are sent to the customer
+	path.SetValidation(config.RPKI_VALIDATION_RESULT_TYPE_VALID)
+	assert.Equal(t, path, r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_EXPORT, path, customer))
+}
+
+func TestPolicyFamilyDefault(t *testing.T) {
+	assert := assert.New(t)
+	peer := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})}),
+		bgp.NewPathAttributeNextHop("10.0.0.1"),
+	}
+	path4 := NewPath(peer, bgp.NewIPAddrPrefix(24, "10.10.0.0"), false, pathAttributes, time.Now(), false)
+	path6 := NewPath(peer, bgp.NewIPv6AddrPrefix(64, "2001:db8::"), false, pathAttributes, time.Now(), false)
+
+	r := NewRoutingPolicy()
+	r.SetDefaultPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, ROUTE_TYPE_ACCEPT)
+	r.SetDefaultPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_EXPORT, ROUTE_TYPE_ACCEPT)
+	assert.Equal(path4, r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, path4, nil))
+
+	afiSafis := []config.AfiSafi{{AfiSafiName: config.AFI_SAFI_TYPE_IPV4_UNICAST}, {AfiSafiName: config.AFI_SAFI_TYPE_IPV6_UNICAST}}
+	afiSafis[0].ApplyPolicy.Config.DefaultImportPolicy = config.DEFAULT_POLICY_TYPE_REJECT_ROUTE
+	defs, err := r.GetFamilyDefaultsFromConfig(POLICY_DIRECTION_IMPORT, afiSafis)
+	assert.Nil(err)
+	assert.Equal(map[bgp.RouteFamily]RouteType{bgp.RF_IPv4_UC: ROUTE_TYPE_REJECT}, defs)
+	r.SetFamilyDefaultPolicies(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, defs)
+
+	// rejected by the default of the family only
+	assert.Nil(r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, path4, nil))
+	assert.Equal(path6, r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, path6, nil))
+	assert.Equal(path4, r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_EXPORT, path4, nil))
+	// kept when the default of the direction is set
+	r.SetDefaultPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, ROUTE_TYPE_ACCEPT)
+	assert.Nil(r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, path4, nil))
+
+	r.SetFamilyDefaultPolicies(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, nil)
+	assert.Equal(path4, r.ApplyPolicy(GLOBAL_RIB_NAME, POLICY_DIRECTION_IMPORT, path4, nil))
+}
+
 func TestPolicyMatchAndAccept(t *testing.T) {
 	// create path
 	peer := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
@@ -1644,6 +1718,54 @@ func TestPolicyMatchAndAddCommunities(t *testing.T) {
 	assert.Equal(t, []uint32{stringToCommunityValue(community)}, newPath.GetCommunities())
 }
 
+func TestPolicyMatchSourceAndAddCommunities(t *testing.T) {
+
+	// create path
+	peer := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.1")}
+	origin := bgp.NewPathAttributeOrigin(0)
+	aspathParam := []bgp.AsPathParamInterface{bgp.NewAsPathParam(2, []uint16{65001})}
+	aspath := bgp.NewPathAttributeAsPath(aspathParam)
+	nexthop := bgp.NewPathAttributeNextHop("10.0.0.1")
+	med := bgp.NewPathAttributeMultiExitDisc(0)
+	pathAttributes := []bgp.PathAttributeInterface{origin, aspath, nexthop, med}
+	nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.0.101")}
+	updateMsg := bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri)
+	path := ProcessMessage(updateMsg, peer, time.Now())[0]
+	zpath := ProcessMessage(updateMsg, peer, time.Now())[0]
+	zpath.SetIsFromZebra(true)
+
+	// create policy
+	ps := createPrefixSet("ps1", "10.10.0.0/16", "21..24")
+	ns := createNeighborSet("ns1", "10.0.0.1")
+
+	ds := config.DefinedSets{}
+	ds.PrefixSets = []config.PrefixSet{ps}
+	ds.NeighborSets = []config.NeighborSet{ns}
+
+	community := "65000:100"
+
+	s := createStatement("statement1", "ps1", "ns1", true)
+	s.Conditions.BgpConditions.MatchSource = config.ROUTE_SOURCE_TYPE_ZEBRA
+	s.Actions.BgpActions.SetCommunity = createSetCommunity("ADD", community)
+
+	pd := createPolicyDefinition("pd1", s)
+	pl := createRoutingPolicy(ds, pd)
+
+	//test
+	r := NewRoutingPolicy()
+	err := r.Reload(pl)
+	assert.Nil(t, err)
+	p := r.PolicyMap["pd1"]
+
+	pType, newPath := p.Apply(zpath, nil)
+	assert.Equal(t, ROUTE_TYPE_ACCEPT, pType)
+	assert.Equal(t, []uint32{stringToCommunityValue(community)}, newPath.GetCommunities())
+
+	pType, newPath = p.Apply(path, nil)
+	assert.Equal(t, ROUTE_TYPE_NONE, pType)
+	assert.Equal(t, 0, len(newPath.GetCommunities()))
+}
+
 func TestPolicyMatchAndReplaceCommunities(t *testing.T) {
 
 	// create path
@@ -23,15 +23,47 @@ import (
 type Table struct {
 	routeFamily  bgp.RouteFamily
 	destinations map[string]*Destination
+	// the number of the destinations having a best path in the
+	// global rib and of the best path changes by the reason
+	bests       int
+	bestChanges map[BestPathReason]uint64
+	// the number of the known paths in the table and the sum of
+	// their parent depths, and the number of the destinations by the
+	// maximum parent depth of their known paths
+	paths     int
+	depthSum  int
+	depthMaxs map[int]int
+	// the destinations having known paths by the key of their source
+	sources map[string]map[*Destination]struct{}
+}
+
+// parentDepths are the parent depths of the known paths of a
+// destination.
+type parentDepths struct {
+	paths int
+	sum   int
+	max   int
 }
 
 func NewTable(rf bgp.RouteFamily) *Table {
 	return &Table{
 		routeFamily:  rf,
 		destinations: make(map[string]*Destination),
+		bestChanges:  make(map[BestPathReason]uint64),
+		depthMaxs:    make(map[int]int),
+		sources:      make(map[string]map[*Destination]struct{}),
 	}
 }
 
+// sourceKey is the key of the source in the index of the table. It's
+// the address of the source, which stays the same while the BGP
+// Identifier is updated from the OPEN message of each session. The
+// sources sharing the address, like the local ones, share the key, so
+// the paths found through the index are still compared with Equal.
+func sourceKey(source *PeerInfo) string {
+	return source.Address.String()
+}
+
 func (t *Table) GetRoutefamily() bgp.RouteFamily {
 	return t.routeFamily
 }
@@ -52,7 +84,7 @@ func (t *Table) insert(path *Path) *Destination {
 
 func (t *Table) DeleteDestByPeer(peerInfo *PeerInfo) []*Destination {
 	dsts := []*Destination{}
-	for _, dst := range t.destinations {
+	for dst := range t.sources[sourceKey(peerInfo)] {
 		match := false
 		for _, p := range dst.knownPathList {
 			if p.GetSource().Equal(peerInfo) {
@@ -71,16 +103,8 @@ func (t *Table) deletePathsByVrf(vrf *Vrf) []*Path {
 	pathList := make([]*Path, 0)
 	for _, dest := range t.destinations {
 		for _, p := range dest.knownPathList {
-			var rd bgp.RouteDistinguisherInterface
-			nlri := p.GetNlri()
-			switch nlri.(type) {
-			case *bgp.LabeledVPNIPAddrPrefix:
-				rd = nlri.(*bgp.LabeledVPNIPAddrPrefix).RD
-			case *bgp.LabeledVPNIPv6AddrPrefix:
-				rd = nlri.(*bgp.LabeledVPNIPv6AddrPrefix).RD
-			case *bgp.EVPNNLRI:
-				rd = nlri.(*bgp.EVPNNLRI).RD()
-			default:
+			rd := p.GetRouteDistinguisher()
+			if rd == nil {
 				return pathList
 			}
 			if p.IsLocal() && vrf.Rd.String() == rd.String() {
@@ -122,13 +146,15 @@ func (t *Table) deleteDestByNlri(nlri bgp.AddrPrefixInterface) *Destination {
 	dest := destinations[t.tableKey(nlri)]
 	if dest != nil {
 		delete(destinations, t.tableKey(nlri))
+		if dest.hasBest {
+			t.bests--
+		}
 	}
 	return dest
 }
 
 func (t *Table) deleteDest(dest *Destination) {
-	destinations := t.GetDestinations()
-	delete(destinations, t.tableKey(dest.GetNlri()))
+	t.deleteDestByNlri(dest.GetNlri())
 }
 
 func (t *Table) validatePath(path *Path) {
@@ -211,6 +237,126 @@ func (t *Table) tableKey(nlri bgp.AddrPrefixInterface) string {
 	return nlri.String()
 }
 
+// updateStats counts the best path change of the destination in the
+// global rib after the best path is calculated.
+func (t *Table) updateStats(dest *Destination) {
+	if _, _, reason, changed := dest.BestPathChange(GLOBAL_RIB_NAME); changed {
+		t.bestChanges[reason]++
+	}
+	if has := dest.GetBestPath(GLOBAL_RIB_NAME) != nil; has != dest.hasBest {
+		if has {
+			t.bests++
+		} else {
+			t.bests--
+		}
+		dest.hasBest = has
+	}
+	d := parentDepths{paths: len(dest.knownPathList)}
+	for _, p := range dest.knownPathList {
+		depth := p.ParentDepth()
+		d.sum += depth
+		if depth > d.max {
+			d.max = depth
+		}
+	}
+	if old := dest.depths; old.paths > 0 {
+		t.paths -= old.paths
+		t.depthSum -= old.sum
+		if t.depthMaxs[old.max]--; t.depthMaxs[old.max] == 0 {
+			delete(t.depthMaxs, old.max)
+		}
+	}
+	if d.paths > 0 {
+		t.paths += d.paths
+		t.depthSum += d.sum
+		t.depthMaxs[d.max]++
+	}
+	dest.depths = d
+
+	sources := make([]string, 0, 1)
+	for _, p := range dest.knownPathList {
+		if p.GetSource() == nil {
+			continue
+		}
+		key := sourceKey(p.GetSource())
+		found := false
+		for _, s := range sources {
+			if s == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sources = append(sources, key)
+		}
+	}
+	for _, key := range dest.sources {
+		if m := t.sources[key]; m != nil {
+			delete(m, dest)
+			if len(m) == 0 {
+				delete(t.sources, key)
+			}
+		}
+	}
+	for _, key := range sources {
+		m, ok := t.sources[key]
+		if !ok {
+			m = make(map[*Destination]struct{})
+			t.sources[key] = m
+		}
+		m[dest] = struct{}{}
+	}
+	dest.sources = sources
+}
+
+// PathsFromSource returns the known paths learned from the source. Only
+// the destinations indexed by the source are looked into, so it doesn't
+// walk the table.
+func (t *Table) PathsFromSource(source *PeerInfo) []*Path {
+	dsts := t.sources[sourceKey(source)]
+	paths := make([]*Path, 0, len(dsts))
+	for dst := range dsts {
+		for _, p := range dst.knownPathList {
+			if p.GetSource().Equal(source) {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+// BestCount returns the number of the prefixes having a best path in
+// the global rib without walking the table.
+func (t *Table) BestCount() int {
+	return t.bests
+}
+
+// ParentDepths returns the maximum and the average of the parent depths
+// of the known paths in the table without walking it.
+func (t *Table) ParentDepths() (int, float64) {
+	max := 0
+	for depth := range t.depthMaxs {
+		if depth > max {
+			max = depth
+		}
+	}
+	if t.paths == 0 {
+		return max, 0
+	}
+	return max, float64(t.depthSum) / float64(t.paths)
+}
+
+// BestPathChanges returns the number of the best path changes in the
+// global rib by the reason the new best path was selected. Withdrawn
+// best paths are counted as BPR_UNKNOWN.
+func (t *Table) BestPathChanges() map[BestPathReason]uint64 {
+	m := make(map[BestPathReason]uint64, len(t.bestChanges))
+	for reason, n := range t.bestChanges {
+		m[reason] = n
+	}
+	return m
+}
+
 func (t *Table) Bests(id string) []*Path {
 	paths := make([]*Path, 0, len(t.destinations))
 	for _, dst := range t.destinations {
@@ -19,8 +19,10 @@ import (
 	"bytes"
 	"fmt"
 	log "github.com/Sirupsen/logrus"
+	"github.com/osrg/gobgp/config"
 	"github.com/osrg/gobgp/packet"
 	"net"
+	"sort"
 	"time"
 )
 
@@ -105,26 +107,75 @@ func ProcessMessage(m *bgp.BGPMessage, peerInfo *PeerInfo, timestamp time.Time)
 	pathList = append(pathList, withdraw2Path(m, peerInfo, timestamp)...)
 	pathList = append(pathList, mpreachNlri2Path(m, peerInfo, timestamp)...)
 	pathList = append(pathList, mpunreachNlri2Path(m, peerInfo, timestamp)...)
-	return pathList
+	return dedupNlri(pathList, peerInfo)
+}
+
+// dedupNlri removes the NLRIs which appear more than once in the
+// advertised or withdrawn routes of an UPDATE message, keeping the
+// last occurrence.
+func dedupNlri(pathList []*Path, peerInfo *PeerInfo) []*Path {
+	type key struct {
+		withdraw bool
+		family   bgp.RouteFamily
+		prefix   string
+		id       uint32
+	}
+	seen := make(map[key]bool, len(pathList))
+	dup := false
+	l := make([]*Path, len(pathList))
+	i := len(pathList)
+	for j := len(pathList) - 1; j >= 0; j-- {
+		path := pathList[j]
+		k := key{path.IsWithdraw, path.GetRouteFamily(), path.GetNlri().String(), path.GetPathIdentifier()}
+		if seen[k] {
+			log.WithFields(log.Fields{
+				"Topic":    "Table",
+				"Key":      peerInfo.Address,
+				"Prefix":   k.prefix,
+				"Withdraw": k.withdraw,
+			}).Warn("duplicate NLRI in an UPDATE message")
+			dup = true
+			continue
+		}
+		seen[k] = true
+		i--
+		l[i] = path
+	}
+	if !dup {
+		return pathList
+	}
+	return l[i:]
 }
 
 type TableManager struct {
-	Tables    map[bgp.RouteFamily]*Table
-	Vrfs      map[string]*Vrf
-	minLabel  uint32
-	maxLabel  uint32
-	nextLabel uint32
-	rfList    []bgp.RouteFamily
+	Tables              map[bgp.RouteFamily]*Table
+	Vrfs                map[string]*Vrf
+	minLabel            uint32
+	maxLabel            uint32
+	nextLabel           uint32
+	rfList              []bgp.RouteFamily
+	unreachableNexthops map[string]bool
+	// the IGP metric to each reachable nexthop
+	nexthopMetrics map[string]uint32
+	// the destinations having paths via each nexthop. the entries,
+	// and the ones in unreachableNexthops and nexthopMetrics, are
+	// removed lazily when the paths went away.
+	nexthopDsts      map[string]map[*Destination]bool
+	selectionOptions config.RouteSelectionOptionsConfig
+	maxParentDepth   int
 }
 
 func NewTableManager(rfList []bgp.RouteFamily, minLabel, maxLabel uint32) *TableManager {
 	t := &TableManager{
-		Tables:    make(map[bgp.RouteFamily]*Table),
-		Vrfs:      make(map[string]*Vrf),
-		minLabel:  minLabel,
-		maxLabel:  maxLabel,
-		nextLabel: minLabel,
-		rfList:    rfList,
+		Tables:              make(map[bgp.RouteFamily]*Table),
+		Vrfs:                make(map[string]*Vrf),
+		minLabel:            minLabel,
+		maxLabel:            maxLabel,
+		nextLabel:           minLabel,
+		rfList:              rfList,
+		unreachableNexthops: make(map[string]bool),
+		nexthopMetrics:      make(map[string]uint32),
+		nexthopDsts:         make(map[string]map[*Destination]bool),
 	}
 	for _, rf := range rfList {
 		t.Tables[rf] = NewTable(rf)
@@ -213,13 +264,28 @@ func (manager *TableManager) DeleteVrf(name string) ([]*Path, error) {
 	return msgs, nil
 }
 
+// SetRouteSelectionOptions sets the options of the best path selection.
+// They apply to the destinations calculated later.
+func (manager *TableManager) SetRouteSelectionOptions(c config.RouteSelectionOptionsConfig) {
+	manager.selectionOptions = c
+}
+
+// SetMaxParentDepth sets the depth of the parents above which the
+// paths are compacted when they are processed. Zero disables it.
+func (manager *TableManager) SetMaxParentDepth(depth int) {
+	manager.maxParentDepth = depth
+}
+
 func (manager *TableManager) calculate(destinations []*Destination) {
 	for _, destination := range destinations {
 		log.WithFields(log.Fields{
 			"Topic": "table",
 			"Key":   destination.GetNlri().String(),
 		}).Debug("Processing destination")
-		destination.Calculate()
+		destination.calculate(&manager.selectionOptions)
+		if t, ok := manager.Tables[destination.routeFamily]; ok {
+			t.updateStats(destination)
+		}
 	}
 }
 
@@ -232,6 +298,53 @@ func (manager *TableManager) DeletePathsByPeer(info *PeerInfo, rf bgp.RouteFamil
 	return nil
 }
 
+// RouteTargetInterests returns the route targets the source is
+// interested in by the RTC routes it advertised. all is true when it
+// advertised the default RTC route, the interest in all the route
+// targets.
+func (manager *TableManager) RouteTargetInterests(source *PeerInfo) (interests map[string]bool, all bool) {
+	interests = make(map[string]bool)
+	t, ok := manager.Tables[bgp.RF_RTC_UC]
+	if !ok {
+		return interests, false
+	}
+	for _, p := range t.PathsFromSource(source) {
+		rt := p.GetNlri().(*bgp.RouteTargetMembershipNLRI).RouteTarget
+		if rt == nil {
+			return interests, true
+		}
+		interests[rt.String()] = true
+	}
+	return interests, false
+}
+
+// WithdrawByRouteTarget returns the withdrawals of the VPN paths in the
+// adj-rib-out of the peer which no longer match its interests after it
+// withdrew the RTC route, and removes them from the adj-rib-out. The
+// withdrawal has to be processed in the table first. Nothing is
+// withdrawn while the peer keeps the interest in all the route targets.
+func (manager *TableManager) WithdrawByRouteTarget(withdrawal *Path, adjRibOut *AdjRib) []*Path {
+	if !withdrawal.IsWithdraw || withdrawal.GetRouteFamily() != bgp.RF_RTC_UC {
+		return nil
+	}
+	interests, all := manager.RouteTargetInterests(withdrawal.GetSource())
+	if all {
+		return nil
+	}
+	target := withdrawal.GetNlri().(*bgp.RouteTargetMembershipNLRI).RouteTarget
+	return adjRibOut.WithdrawByRouteTarget(target, interests)
+}
+
+// PathsFromSource returns the paths learned from the source in all the
+// families, for the operations on a peer such as clearing its routes.
+func (manager *TableManager) PathsFromSource(source *PeerInfo) []*Path {
+	paths := make([]*Path, 0)
+	for _, t := range manager.Tables {
+		paths = append(paths, t.PathsFromSource(source)...)
+	}
+	return paths
+}
+
 func (manager *TableManager) ProcessPaths(pathList []*Path) []*Destination {
 	m := make(map[string]bool, len(pathList))
 	dsts := make([]*Destination, 0, len(pathList))
@@ -241,7 +354,20 @@ func (manager *TableManager) ProcessPaths(pathList []*Path) []*Destination {
 		}
 		rf := path.GetRouteFamily()
 		if t, ok := manager.Tables[rf]; ok {
+			if !path.IsWithdraw && !path.IsLocal() {
+				key := path.GetNexthop().String()
+				if manager.unreachableNexthops[key] {
+					path.SetNexthopInvalid(true)
+				}
+				path.SetIgpMetric(manager.nexthopMetrics[key])
+			}
+			if manager.maxParentDepth > 0 && path.ParentDepth() > manager.maxParentDepth {
+				path.Compact()
+			}
 			dst := t.insert(path)
+			if !path.IsWithdraw && !path.IsLocal() {
+				manager.trackNexthop(path.GetNexthop(), dst)
+			}
 			key := dst.GetNlri().String()
 			if !m[key] {
 				m[key] = true
@@ -262,6 +388,142 @@ func (manager *TableManager) ProcessPaths(pathList []*Path) []*Destination {
 	return dsts
 }
 
+func (manager *TableManager) trackNexthop(nexthop net.IP, dst *Destination) {
+	key := nexthop.String()
+	m, ok := manager.nexthopDsts[key]
+	if !ok {
+		m = make(map[*Destination]bool)
+		manager.nexthopDsts[key] = m
+	}
+	m[dst] = true
+}
+
+// nexthopDestinations returns the destinations having paths via the
+// nexthop, forgetting the ones which don't any more.
+func (manager *TableManager) nexthopDestinations(nexthop net.IP) []*Destination {
+	key := nexthop.String()
+	dsts := make([]*Destination, 0, len(manager.nexthopDsts[key]))
+	for dst := range manager.nexthopDsts[key] {
+		used := false
+		for _, path := range dst.knownPathList {
+			if !path.IsLocal() && path.GetNexthop().Equal(nexthop) {
+				used = true
+				break
+			}
+		}
+		if used {
+			dsts = append(dsts, dst)
+		} else {
+			delete(manager.nexthopDsts[key], dst)
+		}
+	}
+	if len(dsts) == 0 {
+		delete(manager.nexthopDsts, key)
+		delete(manager.unreachableNexthops, key)
+		delete(manager.nexthopMetrics, key)
+	}
+	return dsts
+}
+
+// Nexthops returns the nexthops of the paths in the tables, which need
+// to be tracked for their reachability.
+func (manager *TableManager) Nexthops() []net.IP {
+	l := make([]net.IP, 0, len(manager.nexthopDsts))
+	for key := range manager.nexthopDsts {
+		nexthop := net.ParseIP(key)
+		if len(manager.nexthopDestinations(nexthop)) > 0 {
+			l = append(l, nexthop)
+		}
+	}
+	return l
+}
+
+// IsDuplicate returns true if the table already has the path learned from
+// the same source with the same NLRI and path attributes. Processing
+// such a path again changes nothing but its timestamp.
+func (manager *TableManager) IsDuplicate(path *Path) bool {
+	if path.IsWithdraw || path.NoImplicitWithdraw() {
+		return false
+	}
+	t, ok := manager.Tables[path.GetRouteFamily()]
+	if !ok {
+		return false
+	}
+	dst := t.GetDestination(t.tableKey(path.GetNlri()))
+	if dst == nil {
+		return false
+	}
+	for _, p := range dst.knownPathList {
+		if p.GetSource().Equal(path.GetSource()) && p.GetPathIdentifier() == path.GetPathIdentifier() {
+			return p.Fingerprint() == path.Fingerprint()
+		}
+	}
+	return false
+}
+
+// IsNexthopUnreachable tells whether the nexthop is marked as
+// unreachable.
+func (manager *TableManager) IsNexthopUnreachable(nexthop net.IP) bool {
+	return manager.unreachableNexthops[nexthop.String()]
+}
+
+// UpdateNexthopReachability marks the paths using the given nexthop as
+// reachable or unreachable, with the IGP metric to the nexthop when
+// it's reachable, and recomputes the best path of the affected
+// destinations. Paths with an unreachable nexthop are never selected as
+// best path so they are withdrawn from peers until the nexthop comes back.
+func (manager *TableManager) UpdateNexthopReachability(nexthop net.IP, reachable bool, metric uint32) []*Destination {
+	key := nexthop.String()
+	if reachable {
+		if !manager.unreachableNexthops[key] && manager.nexthopMetrics[key] == metric {
+			return nil
+		}
+		delete(manager.unreachableNexthops, key)
+		manager.nexthopMetrics[key] = metric
+	} else {
+		if manager.unreachableNexthops[key] {
+			return nil
+		}
+		manager.unreachableNexthops[key] = true
+		metric = manager.nexthopMetrics[key]
+	}
+
+	dsts := make([]*Destination, 0)
+	for _, dst := range manager.nexthopDestinations(nexthop) {
+		updated := false
+		for _, path := range dst.knownPathList {
+			if path.IsLocal() || path.NoImplicitWithdraw() || !path.GetNexthop().Equal(nexthop) {
+				continue
+			}
+			if path.IsNexthopInvalid() != reachable && path.GetIgpMetric() == metric {
+				continue
+			}
+			// a new path object is needed here so that NewFeed()
+			// can tell the change from the old best path.
+			newPath := path.Clone(false)
+			for id, dir := range path.filtered {
+				newPath.Filter(id, dir)
+			}
+			newPath.SetNexthopInvalid(!reachable)
+			newPath.SetIgpMetric(metric)
+			dst.addNewPath(newPath)
+			updated = true
+		}
+		if updated {
+			dsts = append(dsts, dst)
+		}
+	}
+	log.WithFields(log.Fields{
+		"Topic":     "Table",
+		"Key":       key,
+		"Reachable": reachable,
+		"Metric":    metric,
+		"Length":    len(dsts),
+	}).Debug("Nexthop reachability changed")
+	manager.calculate(dsts)
+	return dsts
+}
+
 // EVPN MAC MOBILITY HANDLING
 //
 // RFC7432 15. MAC Mobility
@@ -323,6 +585,70 @@ func (manager *TableManager) GetBestPathList(id string, rfList []bgp.RouteFamily
 	return paths
 }
 
+type cursorKey struct {
+	rf  bgp.RouteFamily
+	key string
+}
+
+// BestPathCursor iterates over the best paths of the tables in chunks
+// so that a large table can be dumped incrementally. The destinations
+// are snapshotted when the cursor is created: destinations added later
+// aren't returned and ones deleted later are skipped. The best path of
+// each destination is looked up when its chunk is returned so it
+// reflects the table at that time. The cursor must be used in the same
+// goroutine as the one modifying the tables.
+type BestPathCursor struct {
+	manager *TableManager
+	id      string
+	keys    []cursorKey
+	pos     int
+}
+
+func (manager *TableManager) NewBestPathCursor(id string, rfList []bgp.RouteFamily) *BestPathCursor {
+	keys := make([]cursorKey, 0, manager.getDestinationCount(rfList))
+	for _, rf := range rfList {
+		if t, ok := manager.Tables[rf]; ok {
+			l := make([]string, 0, len(t.GetDestinations()))
+			for k := range t.GetDestinations() {
+				l = append(l, k)
+			}
+			sort.Strings(l)
+			for _, k := range l {
+				keys = append(keys, cursorKey{rf: rf, key: k})
+			}
+		}
+	}
+	return &BestPathCursor{
+		manager: manager,
+		id:      id,
+		keys:    keys,
+	}
+}
+
+// Next returns at most n best paths. An empty list is returned when all
+// the destinations are visited.
+func (c *BestPathCursor) Next(n int) []*Path {
+	paths := make([]*Path, 0, n)
+	for ; c.pos < len(c.keys) && len(paths) < n; c.pos++ {
+		k := c.keys[c.pos]
+		t, ok := c.manager.Tables[k.rf]
+		if !ok {
+			continue
+		}
+		if dst := t.GetDestination(k.key); dst != nil {
+			if path := dst.GetBestPath(c.id); path != nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// Done returns true when all the destinations are visited.
+func (c *BestPathCursor) Done() bool {
+	return c.pos >= len(c.keys)
+}
+
 func (manager *TableManager) GetPathList(id string, rfList []bgp.RouteFamily) []*Path {
 	c := 0
 	for _, rf := range rfList {
@@ -16,12 +16,13 @@
 package table
 
 import (
-	_ "fmt"
+	"fmt"
 	log "github.com/Sirupsen/logrus"
 	"github.com/osrg/gobgp/packet"
 	"github.com/stretchr/testify/assert"
 	"net"
 	"os"
+	"sort"
 	"testing"
 	"time"
 )
@@ -2163,6 +2164,360 @@ func TestProcessBGPUpdate_Timestamp(t *testing.T) {
 	assert.Equal(t, inList[0].GetTimestamp(), t3)
 }
 
+func TestProcessBGPUpdate_unreachable_nexthop_ipv4(t *testing.T) {
+
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+
+	origin1 := bgp.NewPathAttributeOrigin(0)
+	aspath1 := createAsPathAttribute([]uint32{65000})
+	nexthop1 := bgp.NewPathAttributeNextHop("192.168.50.1")
+	localpref1 := bgp.NewPathAttributeLocalPref(200)
+	pathAttributes1 := []bgp.PathAttributeInterface{origin1, aspath1, nexthop1, localpref1}
+	nlri1 := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+	bgpMessage1 := bgp.NewBGPUpdateMessage(nil, pathAttributes1, nlri1)
+
+	origin2 := bgp.NewPathAttributeOrigin(0)
+	aspath2 := createAsPathAttribute([]uint32{65100, 65000})
+	nexthop2 := bgp.NewPathAttributeNextHop("192.168.100.1")
+	localpref2 := bgp.NewPathAttributeLocalPref(100)
+	pathAttributes2 := []bgp.PathAttributeInterface{origin2, aspath2, nexthop2, localpref2}
+	nlri2 := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+	bgpMessage2 := bgp.NewBGPUpdateMessage(nil, pathAttributes2, nlri2)
+
+	_, err := tm.ProcessUpdate(peerR1(), bgpMessage1)
+	assert.NoError(t, err)
+	_, err = tm.ProcessUpdate(peerR2(), bgpMessage2)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.50.1", tm.GetBestPathList(GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv4_UC})[0].GetNexthop().String())
+
+	// the best path loses its nexthop, the other path takes over
+	dsts := tm.UpdateNexthopReachability(net.ParseIP("192.168.50.1").To4(), false, 0)
+	assert.Equal(t, 1, len(dsts))
+	path := dsts[0].NewFeed(GLOBAL_RIB_NAME)
+	assert.NotNil(t, path)
+	assert.False(t, path.IsWithdraw)
+	assert.Equal(t, "192.168.100.1", path.GetNexthop().String())
+
+	// no paths are reachable, the prefix is withdrawn
+	dsts = tm.UpdateNexthopReachability(net.ParseIP("192.168.100.1").To4(), false, 0)
+	assert.Equal(t, 1, len(dsts))
+	path = dsts[0].NewFeed(GLOBAL_RIB_NAME)
+	assert.NotNil(t, path)
+	assert.True(t, path.IsWithdraw)
+	assert.Equal(t, 0, len(tm.GetBestPathList(GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv4_UC})))
+
+	// reachability comes back, the prefix is restored
+	dsts = tm.UpdateNexthopReachability(net.ParseIP("192.168.50.1").To4(), true, 0)
+	assert.Equal(t, 1, len(dsts))
+	path = dsts[0].NewFeed(GLOBAL_RIB_NAME)
+	assert.NotNil(t, path)
+	assert.False(t, path.IsWithdraw)
+	assert.Equal(t, "192.168.50.1", path.GetNexthop().String())
+
+	// no change
+	dsts = tm.UpdateNexthopReachability(net.ParseIP("192.168.50.1").To4(), true, 0)
+	assert.Equal(t, 0, len(dsts))
+}
+
+func TestNexthops(t *testing.T) {
+	assert := assert.New(t)
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	update := func(prefix, nexthop string) *bgp.BGPMessage {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			createAsPathAttribute([]uint32{65000}),
+			bgp.NewPathAttributeNextHop(nexthop),
+		}
+		return bgp.NewBGPUpdateMessage(nil, pathAttributes, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, prefix)})
+	}
+	nexthops := func() []string {
+		l := make([]string, 0)
+		for _, nexthop := range tm.Nexthops() {
+			l = append(l, nexthop.String())
+		}
+		sort.Strings(l)
+		return l
+	}
+
+	tm.ProcessUpdate(peerR1(), update("10.10.10.0", "192.168.50.1"))
+	tm.ProcessUpdate(peerR1(), update("10.10.20.0", "192.168.50.1"))
+	tm.ProcessUpdate(peerR2(), update("10.10.10.0", "192.168.100.1"))
+	assert.Equal([]string{"192.168.100.1", "192.168.50.1"}, nexthops())
+
+	// only the destinations via the nexthop are affected
+	assert.Equal(2, len(tm.UpdateNexthopReachability(net.ParseIP("192.168.50.1"), false, 0)))
+	assert.Equal(1, len(tm.UpdateNexthopReachability(net.ParseIP("192.168.100.1"), false, 0)))
+
+	// replaced with another nexthop
+	tm.ProcessUpdate(peerR2(), update("10.10.10.0", "192.168.50.1"))
+	assert.Equal([]string{"192.168.50.1"}, nexthops())
+	assert.False(tm.IsNexthopUnreachable(net.ParseIP("192.168.100.1")))
+	assert.Equal(0, len(tm.UpdateNexthopReachability(net.ParseIP("192.168.100.1"), true, 0)))
+
+	tm.ProcessUpdate(peerR1(), bgp.NewBGPUpdateMessage([]*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0"), bgp.NewIPAddrPrefix(24, "10.10.20.0")}, nil, nil))
+	tm.ProcessUpdate(peerR2(), bgp.NewBGPUpdateMessage([]*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}, nil, nil))
+	assert.Equal([]string{}, nexthops())
+	assert.False(tm.IsNexthopUnreachable(net.ParseIP("192.168.50.1")))
+}
+
+func TestNexthopIgpMetric(t *testing.T) {
+	assert := assert.New(t)
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	update := func(prefix, nexthop string) *bgp.BGPMessage {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			createAsPathAttribute([]uint32{65000}),
+			bgp.NewPathAttributeNextHop(nexthop),
+		}
+		return bgp.NewBGPUpdateMessage(nil, pathAttributes, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, prefix)})
+	}
+	best := func() *Path {
+		return tm.GetBestPathList(GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv4_UC})[0]
+	}
+	nexthop := net.ParseIP("192.168.50.1")
+
+	tm.ProcessUpdate(peerR1(), update("10.10.10.0", "192.168.50.1"))
+	assert.Equal(uint32(0), best().GetIgpMetric())
+
+	dsts := tm.UpdateNexthopReachability(nexthop, true, 10)
+	assert.Equal(1, len(dsts))
+	assert.NotNil(dsts[0].NewFeed(GLOBAL_RIB_NAME))
+	assert.Equal(uint32(10), best().GetIgpMetric())
+	// the clones advertised keep the metric
+	assert.Equal(uint32(10), best().Clone(false).GetIgpMetric())
+
+	// no change
+	assert.Equal(0, len(tm.UpdateNexthopReachability(nexthop, true, 10)))
+
+	// the paths learned later get the metric of their nexthop
+	tm.ProcessUpdate(peerR1(), update("10.10.20.0", "192.168.50.1"))
+	for _, path := range tm.GetBestPathList(GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv4_UC}) {
+		assert.Equal(uint32(10), path.GetIgpMetric())
+	}
+
+	assert.Equal(2, len(tm.UpdateNexthopReachability(nexthop, true, 20)))
+	for _, path := range tm.GetBestPathList(GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv4_UC}) {
+		assert.Equal(uint32(20), path.GetIgpMetric())
+	}
+}
+
+func TestIsDuplicate(t *testing.T) {
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+
+	update := func(localPref uint32) *bgp.BGPMessage {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			createAsPathAttribute([]uint32{65000}),
+			bgp.NewPathAttributeNextHop("192.168.50.1"),
+			bgp.NewPathAttributeLocalPref(localPref),
+		}
+		nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.10.10.0")}
+		return bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri)
+	}
+
+	path1 := ProcessMessage(update(100), peerR1(), time.Now())[0]
+	assert.False(t, tm.IsDuplicate(path1))
+	tm.ProcessPaths([]*Path{path1})
+
+	// only the timestamp differs
+	path2 := ProcessMessage(update(100), peerR1(), time.Now().Add(time.Second))[0]
+	assert.Equal(t, path1.Fingerprint(), path2.Fingerprint())
+	assert.True(t, tm.IsDuplicate(path2))
+
+	// the same path from another peer
+	assert.False(t, tm.IsDuplicate(ProcessMessage(update(100), peerR2(), time.Now())[0]))
+
+	// the attributes differ
+	path3 := ProcessMessage(update(200), peerR1(), time.Now())[0]
+	assert.NotEqual(t, path1.Fingerprint(), path3.Fingerprint())
+	assert.False(t, tm.IsDuplicate(path3))
+
+	assert.False(t, tm.IsDuplicate(path1.Clone(true)))
+}
+
+func TestTableStats(t *testing.T) {
+	assert := assert.New(t)
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	table := tm.Tables[bgp.RF_IPv4_UC]
+
+	update := func(prefix string, localPref uint32) *bgp.BGPMessage {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			createAsPathAttribute([]uint32{65000}),
+			bgp.NewPathAttributeNextHop("192.168.50.1"),
+			bgp.NewPathAttributeLocalPref(localPref),
+		}
+		nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, prefix)}
+		return bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri)
+	}
+
+	path1 := ProcessMessage(update("10.10.10.0", 100), peerR1(), time.Now())[0]
+	path2 := ProcessMessage(update("10.10.20.0", 100), peerR1(), time.Now())[0]
+	tm.ProcessPaths([]*Path{path1, path2})
+	assert.Equal(2, table.BestCount())
+	assert.Equal(map[BestPathReason]uint64{BPR_ONLY_PATH: 2}, table.BestPathChanges())
+
+	// the better path from another peer
+	tm.ProcessPaths([]*Path{ProcessMessage(update("10.10.10.0", 200), peerR2(), time.Now())[0]})
+	assert.Equal(2, table.BestCount())
+	assert.Equal(uint64(1), table.BestPathChanges()[BPR_LOCAL_PREF])
+
+	// the worse path doesn't change the best path
+	tm.ProcessPaths([]*Path{ProcessMessage(update("10.10.20.0", 50), peerR2(), time.Now())[0]})
+	assert.Equal(uint64(3), table.BestPathChanges()[BPR_ONLY_PATH]+table.BestPathChanges()[BPR_LOCAL_PREF])
+
+	tm.ProcessPaths([]*Path{path2.Clone(true)})
+	assert.Equal(2, table.BestCount())
+	tm.DeletePathsByPeer(peerR2(), bgp.RF_IPv4_UC)
+	assert.Equal(1, table.BestCount())
+	assert.Equal(uint64(1), table.BestPathChanges()[BPR_UNKNOWN])
+
+	// the counters are copied
+	table.BestPathChanges()[BPR_UNKNOWN] = 100
+	assert.Equal(uint64(1), table.BestPathChanges()[BPR_UNKNOWN])
+}
+
+func TestPathsFromSource(t *testing.T) {
+	assert := assert.New(t)
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC}, 0, 0)
+
+	r1 := ProcessMessage(update_fromR1(), peerR1(), time.Now())
+	r1 = append(r1, ProcessMessage(update_fromR1_ipv6(), peerR1(), time.Now())...)
+	r2 := ProcessMessage(update_fromR2(), peerR2(), time.Now())
+	tm.ProcessPaths(append(r1, r2...))
+
+	// the source is looked up by value
+	assert.Equal(2, len(tm.PathsFromSource(peerR1())))
+	assert.Equal(1, len(tm.Tables[bgp.RF_IPv6_UC].PathsFromSource(peerR1())))
+	paths := tm.PathsFromSource(peerR2())
+	assert.Equal(1, len(paths))
+	assert.Equal(r2[0], paths[0])
+	assert.Equal(0, len(tm.PathsFromSource(peerR3())))
+
+	tm.ProcessPaths([]*Path{r1[0].Clone(true)})
+	paths = tm.PathsFromSource(peerR1())
+	assert.Equal(1, len(paths))
+	assert.Equal(bgp.RF_IPv6_UC, paths[0].GetRouteFamily())
+
+	// the source learned another BGP Identifier from the OPEN
+	source := r2[0].GetSource()
+	source.ID = net.ParseIP("10.0.0.9").To4()
+	assert.Equal(1, len(tm.PathsFromSource(source)))
+
+	// the peer went down
+	dsts := tm.DeletePathsByPeer(source, bgp.RF_IPv4_UC)
+	assert.Equal(1, len(dsts))
+	assert.Equal(0, len(tm.PathsFromSource(source)))
+	assert.Equal(0, len(tm.Tables[bgp.RF_IPv4_UC].sources))
+}
+
+func TestParentDepth(t *testing.T) {
+	assert := assert.New(t)
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+	table := tm.Tables[bgp.RF_IPv4_UC]
+
+	update := func(prefix string) *bgp.BGPMessage {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			createAsPathAttribute([]uint32{65000}),
+			bgp.NewPathAttributeNextHop("192.168.50.1"),
+			bgp.NewPathAttributeLocalPref(100),
+		}
+		nlri := []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, prefix)}
+		return bgp.NewBGPUpdateMessage(nil, pathAttributes, nlri)
+	}
+	clone := func(path *Path, depth int) *Path {
+		for i := 0; i < depth; i++ {
+			path = path.Clone(false)
+			switch i {
+			case 1:
+				path.SetMed(10, true)
+			case 2:
+				path.RemoveLocalPref()
+			}
+		}
+		return path
+	}
+
+	path1 := ProcessMessage(update("10.10.10.0"), peerR1(), time.Now())[0]
+	path2 := clone(ProcessMessage(update("10.10.10.0"), peerR2(), time.Now())[0], 5)
+	assert.Equal(5, path2.ParentDepth())
+	tm.ProcessPaths([]*Path{path1, path2})
+	max, avg := table.ParentDepths()
+	assert.Equal(5, max)
+	assert.Equal(2.5, avg)
+
+	// compacted when it's processed
+	tm.SetMaxParentDepth(3)
+	path3 := clone(path2, 1)
+	attrs, fingerprint := path3.GetPathAttrs(), path3.Fingerprint()
+	tm.ProcessPaths([]*Path{path3})
+	assert.Equal(0, path3.ParentDepth())
+	assert.Equal(attrs, path3.GetPathAttrs())
+	assert.Equal(fingerprint, path3.Fingerprint())
+	med, err := path3.GetMed()
+	assert.Nil(err)
+	assert.Equal(uint32(10), med)
+	assert.Nil(path3.getPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF))
+	assert.Equal(peerR2(), path3.GetSource())
+	max, avg = table.ParentDepths()
+	assert.Equal(0, max)
+	assert.Equal(0.0, avg)
+
+	// the parents and the paths cloned from them don't change
+	assert.Equal(5, path2.ParentDepth())
+	assert.NotNil(path2.getPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC))
+
+	// within the depth
+	tm.ProcessPaths([]*Path{clone(path3, 3)})
+	max, avg = table.ParentDepths()
+	assert.Equal(3, max)
+	assert.Equal(1.5, avg)
+
+	tm.DeletePathsByPeer(peerR2(), bgp.RF_IPv4_UC)
+	max, avg = table.ParentDepths()
+	assert.Equal(0, max)
+	assert.Equal(0.0, avg)
+	tm.ProcessPaths([]*Path{path1.Clone(true)})
+	max, avg = table.ParentDepths()
+	assert.Equal(0, max)
+	assert.Equal(0.0, avg)
+}
+
+func TestBestPathCursor(t *testing.T) {
+	assert := assert.New(t)
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_IPv4_UC}, 0, 0)
+
+	path := func(prefix string, withdraw bool) *Path {
+		pathAttributes := []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			createAsPathAttribute([]uint32{65000}),
+			bgp.NewPathAttributeNextHop("192.168.50.1"),
+		}
+		return NewPath(peerR1(), bgp.NewIPAddrPrefix(24, prefix), withdraw, pathAttributes, time.Now(), false)
+	}
+	for i := 0; i < 5; i++ {
+		tm.ProcessPaths([]*Path{path(fmt.Sprintf("10.0.%d.0", i), false)})
+	}
+
+	c := tm.NewBestPathCursor(GLOBAL_RIB_NAME, []bgp.RouteFamily{bgp.RF_IPv4_UC})
+	paths := c.Next(2)
+	assert.Equal(2, len(paths))
+	assert.Equal("10.0.0.0/24", paths[0].GetNlri().String())
+	assert.Equal("10.0.1.0/24", paths[1].GetNlri().String())
+	assert.False(c.Done())
+
+	// the table is modified in the middle of the iteration
+	tm.ProcessPaths([]*Path{path("10.0.3.0", true), path("10.0.9.0", false)})
+
+	paths = c.Next(2)
+	assert.Equal(2, len(paths))
+	assert.Equal("10.0.2.0/24", paths[0].GetNlri().String())
+	assert.Equal("10.0.4.0/24", paths[1].GetNlri().String())
+	assert.True(c.Done())
+	assert.Equal(0, len(c.Next(2)))
+}
+
 func update_fromR1() *bgp.BGPMessage {
 
 	origin := bgp.NewPathAttributeOrigin(0)
@@ -2289,3 +2644,94 @@ func update_fromR2viaR1_ipv6() *bgp.BGPMessage {
 	return bgp.NewBGPUpdateMessage(nil, pathAttributes, nil)
 
 }
+
+func TestProcessMessageDuplicateNlri(t *testing.T) {
+	assert := assert.New(t)
+	origin := bgp.NewPathAttributeOrigin(0)
+	aspath := bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, []uint32{65001})})
+	nexthop := bgp.NewPathAttributeNextHop("192.168.50.1")
+	pathAttributes := []bgp.PathAttributeInterface{origin, aspath, nexthop}
+	nlri := []*bgp.IPAddrPrefix{
+		bgp.NewIPAddrPrefix(24, "10.10.10.0"),
+		bgp.NewIPAddrPrefix(24, "10.10.20.0"),
+		bgp.NewIPAddrPrefix(24, "10.10.10.0"),
+	}
+	withdrawnRoutes := []*bgp.IPAddrPrefix{
+		bgp.NewIPAddrPrefix(24, "10.10.30.0"),
+		bgp.NewIPAddrPrefix(24, "10.10.30.0"),
+	}
+	m := bgp.NewBGPUpdateMessage(withdrawnRoutes, pathAttributes, nlri)
+	pathList := ProcessMessage(m, peerR1(), time.Now())
+	assert.Equal(3, len(pathList))
+	assert.Equal("10.10.20.0/24", pathList[0].GetNlri().String())
+	assert.Equal("10.10.10.0/24", pathList[1].GetNlri().String())
+	assert.Equal("10.10.30.0/24", pathList[2].GetNlri().String())
+	assert.True(pathList[2].IsWithdraw)
+}
+
+func TestWithdrawByRouteTarget(t *testing.T) {
+	assert := assert.New(t)
+	tm := NewTableManager([]bgp.RouteFamily{bgp.RF_RTC_UC, bgp.RF_IPv4_VPN}, 0, 0)
+	peer := peerR1()
+	rt1 := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 100, true)
+	rt2 := bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, 65000, 200, true)
+	rtc := func(target bgp.ExtendedCommunityInterface) *Path {
+		return newTestPath(peer, bgp.NewRouteTargetMembershipNLRI(65000, target), false)
+	}
+	source := &PeerInfo{AS: 65002, Address: net.ParseIP("10.0.0.2")}
+	path := func(rf bgp.RouteFamily, prefix string, targets ...bgp.ExtendedCommunityInterface) *Path {
+		var nlri bgp.AddrPrefixInterface
+		if rf == bgp.RF_IPv4_VPN {
+			nlri = bgp.NewLabeledVPNIPAddrPrefix(24, prefix, *bgp.NewMPLSLabelStack(100), bgp.NewRouteDistinguisherTwoOctetAS(65000, 100))
+		} else {
+			nlri = bgp.NewIPAddrPrefix(24, prefix)
+		}
+		return newTestPath(source, nlri, false, bgp.NewPathAttributeExtendedCommunities(targets))
+	}
+
+	rtc1, rtc2 := rtc(rt1), rtc(rt2)
+	tm.ProcessPaths([]*Path{rtc1, rtc2})
+	interests, all := tm.RouteTargetInterests(peer)
+	assert.False(all)
+	assert.Equal(map[string]bool{rt1.String(): true, rt2.String(): true}, interests)
+
+	rfList := []bgp.RouteFamily{bgp.RF_IPv4_VPN, bgp.RF_IPv4_UC}
+	adj := NewAdjRib(peer.Address.String(), rfList)
+	adj.Update([]*Path{
+		path(bgp.RF_IPv4_VPN, "10.10.10.0", rt1),
+		path(bgp.RF_IPv4_VPN, "10.10.20.0", rt1, rt2),
+		path(bgp.RF_IPv4_VPN, "10.10.30.0", rt2),
+		// not constrained by RTC
+		path(bgp.RF_IPv4_UC, "10.10.40.0", rt1),
+	})
+
+	// only the path carrying no other route target of interest
+	w := rtc1.Clone(true)
+	assert.Equal(0, len(tm.WithdrawByRouteTarget(w, adj)))
+	tm.ProcessPaths([]*Path{w})
+	withdrawn := tm.WithdrawByRouteTarget(w, adj)
+	assert.Equal(1, len(withdrawn))
+	assert.True(withdrawn[0].IsWithdraw)
+	assert.Equal("65000:100:10.10.10.0/24", withdrawn[0].GetNlri().String())
+	assert.Equal(2, adj.Count([]bgp.RouteFamily{bgp.RF_IPv4_VPN}))
+	assert.Equal(1, adj.Count([]bgp.RouteFamily{bgp.RF_IPv4_UC}))
+	assert.Equal(3, adj.Accepted(rfList))
+
+	// nothing is withdrawn while the default route keeps the interest
+	// in all
+	def := rtc(nil)
+	tm.ProcessPaths([]*Path{def})
+	w = rtc2.Clone(true)
+	tm.ProcessPaths([]*Path{w})
+	_, all = tm.RouteTargetInterests(peer)
+	assert.True(all)
+	assert.Equal(0, len(tm.WithdrawByRouteTarget(w, adj)))
+
+	w = def.Clone(true)
+	tm.ProcessPaths([]*Path{w})
+	assert.Equal(2, len(tm.WithdrawByRouteTarget(w, adj)))
+	assert.Equal(0, adj.Count([]bgp.RouteFamily{bgp.RF_IPv4_VPN}))
+
+	// a withdrawal of another family
+	assert.Nil(tm.WithdrawByRouteTarget(path(bgp.RF_IPv4_VPN, "10.10.10.0", rt1).Clone(true), adj))
+}